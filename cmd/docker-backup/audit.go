@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/shyim/docker-backup/internal/audit"
+	"github.com/spf13/cobra"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Audit log commands",
+	Long:  "Commands for the audit log written by the daemon with --audit-log.",
+}
+
+var auditVerifyCmd = &cobra.Command{
+	Use:   "verify <file>",
+	Short: "Check an audit log for changed or removed records",
+	Long: `Check the hash chain of an audit log. Every record carries the hash of the record before it,
+so a record that was changed, removed or reordered breaks the chain. The command exits non-zero
+at the first record that doesn't match. Records removed from the end of the log can't be detected.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE:         runAuditVerify,
+}
+
+func init() {
+	auditCmd.AddCommand(auditVerifyCmd)
+	rootCmd.AddCommand(auditCmd)
+}
+
+func runAuditVerify(cmd *cobra.Command, args []string) error {
+	file, err := os.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	n, err := audit.Verify(file)
+	if err != nil {
+		return fmt.Errorf("audit log %s is not intact after %d record(s): %w", args[0], n, err)
+	}
+
+	fmt.Printf("%d record(s) verified\n", n)
+	return nil
+}
@@ -1,27 +1,38 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	neturl "net/url"
 	"os"
+	"path/filepath"
+	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/shyim/docker-backup/internal/api"
+	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/shyim/docker-backup/internal/config"
+	"github.com/shyim/docker-backup/internal/storage"
 	"github.com/spf13/cobra"
 )
 
 var backupCmd = &cobra.Command{
 	Use:   "backup",
 	Short: "Backup management commands",
-	Long:  "Commands for managing backups: run, list, delete, restore.",
+	Long:  "Commands for managing backups: run, list, delete, restore, restore-url, reencrypt, diff, extract, download, verify.",
 }
 
 var backupRunCmd = &cobra.Command{
-	Use:   "run <container-name>",
+	Use:   "run <container-name> [config-name]",
 	Short: "Trigger an immediate backup",
-	Long:  "Trigger an immediate backup for a container by communicating with the running daemon.",
-	Args:  cobra.ExactArgs(1),
+	Long:  "Trigger an immediate backup for a container by communicating with the running daemon. With a config name only that backup config runs, otherwise all of them.",
+	Args:  cobra.RangeArgs(1, 2),
 	RunE:  runBackupRun,
 }
 
@@ -43,18 +54,107 @@ var backupDeleteCmd = &cobra.Command{
 }
 
 var backupRestoreCmd = &cobra.Command{
-	Use:   "restore <container-name> <backup-key>",
+	Use:   "restore <container-name> [backup-key]",
 	Short: "Restore a backup to a container",
-	Long:  "Restore a specific backup to a running container.",
-	Args:  cobra.ExactArgs(2),
+	Long:  "Restore a specific backup to a running container, or with --latest the newest backup of the config selected with --config.",
+	Args:  cobra.RangeArgs(1, 2),
 	RunE:  runBackupRestore,
 }
 
+var backupRestoreURLCmd = &cobra.Command{
+	Use:   "restore-url <container-name>",
+	Short: "Restore a backup from an HTTP(S) URL",
+	Long:  "Restore a backup that is not in a configured storage pool, e.g. from a presigned S3 URL. The daemon fetches the archive and streams it into the restore.",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runBackupRestoreURL,
+}
+
+var backupReencryptCmd = &cobra.Command{
+	Use:   "reencrypt <container-name>",
+	Short: "Re-encrypt backups with the current encryption key",
+	Long:  "Rewrite a container's backups that were encrypted with an older key so they use the daemon's current encryption key. Once no backup uses an old key, it can be removed from DOCKER_BACKUP_ENCRYPTION_KEYS.",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runBackupReencrypt,
+}
+
+var backupDiffCmd = &cobra.Command{
+	Use:   "diff <container-name> <from-key> <to-key>",
+	Short: "Compare two backups of a container",
+	Long:  "Compare two backups of the same backup config without restoring them. Volume backups are compared file by file, database backups by their databases and dump sizes.",
+	Args:  cobra.ExactArgs(3),
+	RunE:  runBackupDiff,
+}
+
+var backupExtractCmd = &cobra.Command{
+	Use:   "extract <container-name> <backup-key> --dest <path>",
+	Short: "Extract a backup to a directory",
+	Long:  "Write the contents of a backup to a directory instead of restoring it into the container, which keeps running untouched. Volume backups are written as one directory per volume, database backups as one .sql file per database. The daemon writes the files, so the path refers to the daemon's filesystem.",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runBackupExtract,
+}
+
+var backupDownloadCmd = &cobra.Command{
+	Use:   "download <container-name> <backup-key>",
+	Short: "Download a backup to a local file",
+	Long:  "Copy a stored backup from the daemon's storage to a local file, unchanged (compressed and, if configured, encrypted). Without --output the file is named after the key and written to the current directory; use --output - to write to stdout.",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runBackupDownload,
+}
+
+var backupVerifyCmd = &cobra.Command{
+	Use:   "verify <container-name> <backup-key>",
+	Short: "Check a backup against its stored checksum",
+	Long:  "Download a stored backup and the .sha256 file written next to it and compare the backup's SHA-256 digest with the recorded one. The command fails when they differ or the backup has no checksum file.",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runBackupVerify,
+}
+
+var (
+	downloadOutput    string
+	extractDest       string
+	restoreMode       string
+	restoreMerge      bool
+	restoreLatest     bool
+	restoreConfig     string
+	restoreURL        string
+	restoreURLType    string
+	restoreURLHeaders []string
+)
+
+// readyTimeout is how long "backup run" waits for a freshly started daemon
+const readyTimeout = 30 * time.Second
+
 func init() {
 	backupCmd.AddCommand(backupRunCmd)
 	backupCmd.AddCommand(backupListCmd)
 	backupCmd.AddCommand(backupDeleteCmd)
 	backupCmd.AddCommand(backupRestoreCmd)
+	backupCmd.AddCommand(backupRestoreURLCmd)
+	backupCmd.AddCommand(backupReencryptCmd)
+	backupCmd.AddCommand(backupDiffCmd)
+	backupCmd.AddCommand(backupExtractCmd)
+	backupCmd.AddCommand(backupDownloadCmd)
+	backupCmd.AddCommand(backupVerifyCmd)
+
+	backupDownloadCmd.Flags().StringVarP(&downloadOutput, "output", "o", "", "File to write the backup to, - for stdout (default: the key's file name)")
+
+	backupExtractCmd.Flags().StringVar(&extractDest, "dest", "", "Directory to extract the backup to, created if missing")
+	_ = backupExtractCmd.MarkFlagRequired("dest")
+
+	backupRestoreCmd.Flags().StringVar(&restoreMode, "restore-mode", "", "Volume restore mode: merge (keep existing files) or clear (delete volume contents first); overrides the restore-mode label")
+	backupRestoreCmd.Flags().BoolVar(&restoreMerge, "merge", false, "Shorthand for --restore-mode=merge")
+	backupRestoreCmd.MarkFlagsMutuallyExclusive("merge", "restore-mode")
+	backupRestoreCmd.Flags().BoolVar(&restoreLatest, "latest", false, "Restore the newest backup instead of the one given by key")
+	backupRestoreCmd.Flags().StringVar(&restoreConfig, "config", "", "Backup config whose newest backup --latest restores (default: the container's only config)")
+
+	backupRestoreURLCmd.Flags().StringVar(&restoreURL, "url", "", "HTTP(S) URL of the backup archive")
+	backupRestoreURLCmd.Flags().StringVar(&restoreURLType, "type", "", "Backup type of the archive (e.g., volume, postgres)")
+	backupRestoreURLCmd.Flags().StringArrayVar(&restoreURLHeaders, "header", nil, "Header sent with the request (format: \"Name: value\", repeatable)")
+	backupRestoreURLCmd.Flags().StringVar(&restoreMode, "restore-mode", "", "Volume restore mode: merge (keep existing files) or clear (delete volume contents first); overrides the restore-mode label")
+	backupRestoreURLCmd.Flags().BoolVar(&restoreMerge, "merge", false, "Shorthand for --restore-mode=merge")
+	backupRestoreURLCmd.MarkFlagsMutuallyExclusive("merge", "restore-mode")
+	_ = backupRestoreURLCmd.MarkFlagRequired("url")
+	_ = backupRestoreURLCmd.MarkFlagRequired("type")
 }
 
 func runBackupRun(cmd *cobra.Command, args []string) error {
@@ -62,10 +162,17 @@ func runBackupRun(cmd *cobra.Command, args []string) error {
 
 	client := createSocketClient()
 
+	if err := waitForDaemonReady(client, readyTimeout); err != nil {
+		return err
+	}
+
 	url := fmt.Sprintf("http://localhost/backup/run/%s", containerName)
+	if len(args) > 1 {
+		url += "?config=" + neturl.QueryEscape(args[1])
+	}
 	resp, err := client.Post(url, "application/json", nil)
 	if err != nil {
-		return fmt.Errorf("failed to connect to daemon at %s: %w", socketPath, err)
+		return fmt.Errorf("failed to connect to daemon at %s: %w", apiEndpoint(), err)
 	}
 	defer func() {
 		_ = resp.Body.Close()
@@ -80,7 +187,11 @@ func runBackupRun(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("backup failed: %s", result.Error)
 	}
 
-	fmt.Printf("Backup completed successfully for container: %s\n", containerName)
+	if len(args) > 1 {
+		fmt.Printf("Backup completed successfully for container: %s (config: %s)\n", containerName, args[1])
+	} else {
+		fmt.Printf("Backup completed successfully for container: %s\n", containerName)
+	}
 	if result.Message != "" {
 		fmt.Printf("Message: %s\n", result.Message)
 	}
@@ -96,7 +207,7 @@ func runBackupList(cmd *cobra.Command, args []string) error {
 	url := fmt.Sprintf("http://localhost/backup/list/%s", containerName)
 	resp, err := client.Get(url)
 	if err != nil {
-		return fmt.Errorf("failed to connect to daemon at %s: %w", socketPath, err)
+		return fmt.Errorf("failed to connect to daemon at %s: %w", apiEndpoint(), err)
 	}
 	defer func() {
 		_ = resp.Body.Close()
@@ -121,7 +232,7 @@ func runBackupList(cmd *cobra.Command, args []string) error {
 	_, _ = fmt.Fprintln(w, "---\t----\t----")
 
 	for _, b := range result.Backups {
-		size := formatSize(b.Size)
+		size := config.FormatSize(b.Size)
 		date := b.LastModified.Format("2006-01-02 15:04:05")
 		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", b.Key, size, date)
 	}
@@ -146,7 +257,7 @@ func runBackupDelete(cmd *cobra.Command, args []string) error {
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to connect to daemon at %s: %w", socketPath, err)
+		return fmt.Errorf("failed to connect to daemon at %s: %w", apiEndpoint(), err)
 	}
 	defer func() {
 		_ = resp.Body.Close()
@@ -165,16 +276,106 @@ func runBackupDelete(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// restoreModeQuery returns the query overriding the restore mode set by
+// --restore-mode or --merge, if any
+func restoreModeQuery() neturl.Values {
+	query := neturl.Values{}
+	mode := restoreMode
+	if restoreMerge {
+		mode = "merge"
+	}
+	if mode != "" {
+		query.Set("restore-mode", mode)
+	}
+	return query
+}
+
+// encodeQuery returns query as a URL suffix, empty if there is nothing to send
+func encodeQuery(query neturl.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	return "?" + query.Encode()
+}
+
 func runBackupRestore(cmd *cobra.Command, args []string) error {
 	containerName := args[0]
-	backupKey := args[1]
+
+	var backupKey string
+	switch {
+	case restoreLatest && len(args) == 2:
+		return fmt.Errorf("pass either a backup key or --latest, not both")
+	case restoreLatest:
+		backupKey = backup.LatestKey
+	case len(args) == 2:
+		backupKey = args[1]
+	default:
+		return fmt.Errorf("a backup key or --latest is required")
+	}
+	if restoreConfig != "" && !restoreLatest {
+		return fmt.Errorf("--config only selects the config for --latest")
+	}
+
+	query := restoreModeQuery()
+	if restoreConfig != "" {
+		query.Set("config", restoreConfig)
+	}
 
 	client := createSocketClient()
 
-	url := fmt.Sprintf("http://localhost/backup/restore/%s/%s", containerName, backupKey)
+	url := fmt.Sprintf("http://localhost/backup/restore/%s/%s", containerName, backupKey) + encodeQuery(query)
 	resp, err := client.Post(url, "application/json", nil)
 	if err != nil {
-		return fmt.Errorf("failed to connect to daemon at %s: %w", socketPath, err)
+		return fmt.Errorf("failed to connect to daemon at %s: %w", apiEndpoint(), err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var result api.RestoreResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if !result.Success {
+		return fmt.Errorf("restore failed: %s", result.Error)
+	}
+
+	fmt.Printf("Backup %s restored successfully to container: %s\n", result.Key, containerName)
+	if result.Message != "" {
+		fmt.Printf("Message: %s\n", result.Message)
+	}
+
+	return nil
+}
+
+func runBackupRestoreURL(cmd *cobra.Command, args []string) error {
+	containerName := args[0]
+
+	headers := make(map[string]string, len(restoreURLHeaders))
+	for _, h := range restoreURLHeaders {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return fmt.Errorf("invalid header %q: expected \"Name: value\"", h)
+		}
+		headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+
+	body, err := json.Marshal(backup.RemoteSource{
+		URL:        restoreURL,
+		BackupType: restoreURLType,
+		Headers:    headers,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	client := createSocketClient()
+
+	url := fmt.Sprintf("http://localhost/backup/restore-url/%s", containerName) + encodeQuery(restoreModeQuery())
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to connect to daemon at %s: %w", apiEndpoint(), err)
 	}
 	defer func() {
 		_ = resp.Body.Close()
@@ -196,3 +397,240 @@ func runBackupRestore(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func runBackupReencrypt(cmd *cobra.Command, args []string) error {
+	containerName := args[0]
+
+	client := createSocketClient()
+	// Every outdated backup is downloaded and uploaded again
+	client.Timeout = 0
+
+	url := fmt.Sprintf("http://localhost/backup/reencrypt/%s", containerName)
+	resp, err := client.Post(url, "application/json", nil)
+	if err != nil {
+		return fmt.Errorf("failed to connect to daemon at %s: %w", apiEndpoint(), err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var result api.ReencryptResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if r := result.Result; r != nil {
+		for _, key := range r.Reencrypted {
+			fmt.Printf("Re-encrypted: %s\n", key)
+		}
+		for _, f := range r.Failed {
+			fmt.Printf("Failed: %s: %s\n", f.Key, f.Error)
+		}
+		fmt.Printf("\nCurrent key: %s\n", r.CurrentKey)
+		fmt.Printf("Re-encrypted %d, already current %d, unencrypted %d, failed %d\n",
+			len(r.Reencrypted), r.Current, r.Plain, len(r.Failed))
+	}
+
+	if !result.Success {
+		if result.Error != "" {
+			return fmt.Errorf("reencrypt failed: %s", result.Error)
+		}
+		return fmt.Errorf("reencrypt failed for %d backup(s)", len(result.Result.Failed))
+	}
+
+	return nil
+}
+
+func runBackupDiff(cmd *cobra.Command, args []string) error {
+	containerName := args[0]
+
+	client := createSocketClient()
+	// Both archives are read in full by the daemon
+	client.Timeout = 0
+
+	url := fmt.Sprintf("http://localhost/backup/diff/%s?from=%s&to=%s", containerName, neturl.QueryEscape(args[1]), neturl.QueryEscape(args[2]))
+	resp, err := client.Get(url)
+	if err != nil {
+		return fmt.Errorf("failed to connect to daemon at %s: %w", apiEndpoint(), err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var result api.DiffResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if !result.Success || result.Diff == nil {
+		return fmt.Errorf("diff failed: %s", result.Error)
+	}
+	diff := result.Diff
+
+	for _, e := range diff.Added {
+		fmt.Printf("+ %s (%s)\n", e.Name, config.FormatSize(e.Size))
+	}
+	for _, e := range diff.Removed {
+		fmt.Printf("- %s (%s)\n", e.Name, config.FormatSize(e.Size))
+	}
+	for _, e := range diff.Changed {
+		fmt.Printf("~ %s (%s -> %s)\n", e.Name, config.FormatSize(e.FromSize), config.FormatSize(e.ToSize))
+	}
+
+	if diff.Truncated {
+		fmt.Println("\nToo many differences, the list is truncated")
+	}
+	fmt.Printf("\nAdded %d, removed %d, changed %d, unchanged %d\n",
+		len(diff.Added), len(diff.Removed), len(diff.Changed), diff.Unchanged)
+
+	return nil
+}
+
+func runBackupExtract(cmd *cobra.Command, args []string) error {
+	containerName := args[0]
+	backupKey := args[1]
+
+	dest, err := filepath.Abs(extractDest)
+	if err != nil {
+		return fmt.Errorf("failed to resolve destination: %w", err)
+	}
+
+	client := createSocketClient()
+	// The daemon reads the whole archive before answering
+	client.Timeout = 0
+
+	url := fmt.Sprintf("http://localhost/backup/extract/%s/%s?dest=%s", containerName, backupKey, neturl.QueryEscape(dest))
+	resp, err := client.Post(url, "application/json", nil)
+	if err != nil {
+		return fmt.Errorf("failed to connect to daemon at %s: %w", apiEndpoint(), err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var result api.ExtractResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if !result.Success {
+		return fmt.Errorf("extract failed: %s", result.Error)
+	}
+
+	fmt.Printf("Backup extracted successfully to: %s\n", dest)
+	return nil
+}
+
+func runBackupDownload(cmd *cobra.Command, args []string) error {
+	containerName := args[0]
+	backupKey := args[1]
+
+	output := downloadOutput
+	if output == "" {
+		output = filepath.Base(backupKey)
+	}
+
+	body, err := openDownload(containerName, backupKey)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = body.Close()
+	}()
+
+	if output == "-" {
+		if _, err := io.Copy(os.Stdout, body); err != nil {
+			return fmt.Errorf("download failed: %w", err)
+		}
+		return nil
+	}
+
+	// Write next to the target first so an interrupted download leaves no partial backup behind
+	tmp, err := os.CreateTemp(filepath.Dir(output), "."+filepath.Base(output)+".*.partial")
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	size, err := io.Copy(tmp, body)
+	if err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("download failed: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), output); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+
+	fmt.Printf("Downloaded %s to %s (%s)\n", backupKey, output, config.FormatSize(size))
+	return nil
+}
+
+// openDownload requests a stored object from the daemon and returns its content
+func openDownload(containerName, key string) (io.ReadCloser, error) {
+	client := createSocketClient()
+	// Large backups take a while to transfer
+	client.Timeout = 0
+
+	url := fmt.Sprintf("http://localhost/backup/download/%s/%s", containerName, key)
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to daemon at %s: %w", apiEndpoint(), err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		var result api.BackupResponse
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return nil, fmt.Errorf("download failed with status %d", resp.StatusCode)
+		}
+		return nil, fmt.Errorf("download failed: %s", result.Error)
+	}
+	return resp.Body, nil
+}
+
+func runBackupVerify(cmd *cobra.Command, args []string) error {
+	containerName := args[0]
+	backupKey := args[1]
+
+	sidecar, err := openDownload(containerName, storage.ChecksumKey(backupKey))
+	if err != nil {
+		return fmt.Errorf("failed to get checksum: %w", err)
+	}
+	content, err := io.ReadAll(io.LimitReader(sidecar, 4096))
+	_ = sidecar.Close()
+	if err != nil {
+		return fmt.Errorf("failed to get checksum: %w", err)
+	}
+	expected, err := storage.ParseChecksum(content)
+	if err != nil {
+		return err
+	}
+
+	body, err := openDownload(containerName, backupKey)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = body.Close()
+	}()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, body)
+	if err != nil {
+		return fmt.Errorf("download failed: %w", err)
+	}
+
+	if got := hex.EncodeToString(hash.Sum(nil)); got != expected {
+		return fmt.Errorf("backup %s is corrupt: sha256 %s, expected %s", backupKey, got, expected)
+	}
+
+	fmt.Printf("Backup %s is intact (%s, sha256 %s)\n", backupKey, config.FormatSize(size), expected)
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/shyim/docker-backup/internal/api"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Configuration commands",
+	Long:  "Commands for inspecting the daemon's configuration.",
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the daemon's effective configuration",
+	Long:  "Show the configuration the running daemon uses, with the source of each value (flag, env, default or auto). Secrets are redacted.",
+	Args:  cobra.NoArgs,
+	RunE:  runConfigShow,
+}
+
+var configShowJSON bool
+
+func init() {
+	configShowCmd.Flags().BoolVar(&configShowJSON, "json", false, "Print the configuration as JSON")
+	configCmd.AddCommand(configShowCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+func runConfigShow(cmd *cobra.Command, args []string) error {
+	client := createSocketClient()
+
+	resp, err := client.Get("http://localhost/config")
+	if err != nil {
+		return fmt.Errorf("failed to connect to daemon at %s: %w", apiEndpoint(), err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var result api.ConfigResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if !result.Success || result.Config == nil {
+		return fmt.Errorf("failed to get configuration: %s", result.Error)
+	}
+	eff := result.Config
+
+	if configShowJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(eff)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "SETTING\tVALUE\tSOURCE")
+	for _, s := range eff.Settings {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", s.Name, displayValue(s.Value), s.Source)
+	}
+	_ = w.Flush()
+
+	fmt.Println()
+	if len(eff.StoragePools) == 0 {
+		fmt.Println("No storage pools configured")
+	}
+	for _, pool := range eff.StoragePools {
+		title := fmt.Sprintf("Storage pool %q (type %s, %s)", pool.Name, pool.Type, pool.TypeSource)
+		if pool.Default {
+			title += " [default]"
+		}
+		fmt.Println(title)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, opt := range pool.Options {
+			_, _ = fmt.Fprintf(w, "  %s\t%s\t%s\n", opt.Name, displayValue(opt.Value), opt.Source)
+		}
+		_ = w.Flush()
+	}
+
+	fmt.Println()
+	if len(eff.Notifiers) == 0 {
+		fmt.Println("No notification providers configured")
+		return nil
+	}
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NOTIFIER\tTYPE\tSOURCE")
+	for _, n := range eff.Notifiers {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", n.Name, n.Type, n.Source)
+	}
+	return w.Flush()
+}
+
+// displayValue shows unset values as a dash so table columns stay aligned
+func displayValue(value string) string {
+	if strings.TrimSpace(value) == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/shyim/docker-backup/internal/config"
+	"github.com/shyim/docker-backup/internal/docker"
+	"github.com/shyim/docker-backup/internal/storage"
+)
+
+// configCheckTimeout bounds each storage connectivity check
+const configCheckTimeout = 30 * time.Second
+
+// runConfigCheck validates storage connectivity and the labels of all running
+// containers, prints a summary and reports whether anything failed. It only
+// reads: nothing is scheduled, stored or deleted.
+func runConfigCheck(ctx context.Context, poolManager *storage.PoolManager, dockerClient *docker.MultiClient) error {
+	problems := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	status := func(err error) string {
+		if err != nil {
+			problems++
+			return "FAIL"
+		}
+		return "ok"
+	}
+
+	_, _ = fmt.Fprintln(w, "STORAGE POOL\tTYPE\tSTATUS\tDETAIL")
+	for _, name := range poolManager.List() {
+		err := checkStorage(ctx, poolManager, name)
+		detail := ""
+		if name == cfg.DefaultStorage {
+			detail = "default"
+		}
+		if err != nil {
+			detail = err.Error()
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, cfg.StoragePools[name].Type, status(err), detail)
+	}
+	_ = w.Flush()
+
+	fmt.Println()
+	if len(cfg.NotifyDSNs) == 0 {
+		fmt.Println("No notification providers configured")
+	} else {
+		names := make([]string, 0, len(cfg.NotifyDSNs))
+		for name := range cfg.NotifyDSNs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		// Notifiers were created from their DSNs already; nothing is sent
+		_, _ = fmt.Fprintln(w, "NOTIFIER\tTYPE\tSTATUS")
+		for _, name := range names {
+			scheme, _, _ := strings.Cut(cfg.NotifyDSNs[name], "://")
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", name, scheme, "ok")
+		}
+		_ = w.Flush()
+	}
+
+	fmt.Println()
+	containers, nodeErrs, err := dockerClient.ListContainers(ctx, cfg.IncludeStoppedContainers)
+	if err != nil {
+		problems++
+		fmt.Printf("Failed to list containers: %v\n", err)
+	} else {
+		for node, err := range nodeErrs {
+			problems++
+			fmt.Printf("Failed to list containers of node %s: %v\n", node, err)
+		}
+	}
+
+	sort.Slice(containers, func(i, j int) bool { return containers[i].Name < containers[j].Name })
+
+	enabled := 0
+	_, _ = fmt.Fprintln(w, "CONTAINER\tCONFIG\tTYPE\tSCHEDULE\tSTORAGE\tSTATUS\tDETAIL")
+	for i := range containers {
+		container := &containers[i]
+		parsed, err := config.ParseConfigForContainer(cfg.LabelPrefix, cfg.BackupDefaults(), cfg.Containers, container.Key(), container.Name, container.Labels)
+		if err != nil {
+			_, _ = fmt.Fprintf(w, "%s\t-\t-\t-\t-\t%s\t%s\n", container.Name, status(err), err)
+			continue
+		}
+		if !container.Running {
+			parsed = parsed.Stopped()
+		}
+		if !parsed.Enabled || len(parsed.Backups) == 0 {
+			continue
+		}
+		enabled++
+
+		for _, b := range parsed.Backups {
+			check := backup.CheckBackupConfig(b, cfg.StoragePools, cfg.DefaultStorage)
+			if backupType, ok := backup.Get(b.BackupType); ok {
+				// Catches what only the container can tell, e.g. missing credentials
+				if err := backupType.Validate(container, backup.Options(b.Options)); err != nil {
+					check.Errors = append(check.Errors, err.Error())
+				}
+			}
+
+			var checkErr error
+			if len(check.Errors) > 0 {
+				checkErr = fmt.Errorf("%s", strings.Join(check.Errors, "; "))
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				container.Name, check.Name, check.Type, check.Schedule, displayValue(check.Storage), status(checkErr), errorDetail(checkErr))
+		}
+	}
+	_ = w.Flush()
+	if enabled == 0 {
+		fmt.Println("No running containers with backups enabled")
+	}
+
+	fmt.Println()
+	if problems > 0 {
+		return fmt.Errorf("configuration check failed: %d problem(s)", problems)
+	}
+	fmt.Println("Configuration OK")
+	return nil
+}
+
+// checkStorage lists a prefix that holds no backups to verify the pool is reachable
+// and the credentials allow reading
+func checkStorage(ctx context.Context, poolManager *storage.PoolManager, name string) error {
+	store, err := poolManager.Get(name)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, configCheckTimeout)
+	defer cancel()
+
+	return storage.Check(ctx, store)
+}
+
+func errorDetail(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
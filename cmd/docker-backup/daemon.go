@@ -2,21 +2,32 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sort"
 	"syscall"
 
 	"github.com/shyim/docker-backup/internal/api"
+	"github.com/shyim/docker-backup/internal/audit"
 	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/shyim/docker-backup/internal/config"
 	"github.com/shyim/docker-backup/internal/dashboard"
 	"github.com/shyim/docker-backup/internal/docker"
+	"github.com/shyim/docker-backup/internal/encryption"
+	"github.com/shyim/docker-backup/internal/health"
+	"github.com/shyim/docker-backup/internal/httpclient"
+	"github.com/shyim/docker-backup/internal/instance"
 	"github.com/shyim/docker-backup/internal/notification"
 	"github.com/shyim/docker-backup/internal/retention"
 	"github.com/shyim/docker-backup/internal/scheduler"
 	"github.com/shyim/docker-backup/internal/storage"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var daemonCmd = &cobra.Command{
@@ -26,14 +37,60 @@ var daemonCmd = &cobra.Command{
 	RunE:  runDaemon,
 }
 
+var (
+	daemonConfigFile  string
+	daemonConfigCheck bool
+	daemonOnce        bool
+	daemonOutput      string
+)
+
 func init() {
+	daemonCmd.Flags().StringVar(&daemonConfigFile, "config", "", "YAML file with daemon settings, flags and environment variables override it")
+	daemonCmd.Flags().BoolVar(&daemonConfigCheck, "config-check", false, "Validate the configuration, storage access and container labels, print a summary and exit")
+	daemonCmd.Flags().BoolVar(&daemonOnce, "once", false, "Run every enabled backup config once, print a summary and exit (exit code 0: all succeeded, 1: some failed, 2: all failed)")
+	daemonCmd.Flags().StringVar(&daemonOutput, "output", "text", "Summary format of --once: text or json")
 	daemonCmd.Flags().DurationVar(&cfg.PollInterval, "poll-interval", cfg.PollInterval, "How often to scan for container changes")
+	daemonCmd.Flags().StringArrayVar(&cfg.DockerNodeArgs, "docker-node", []string{}, "Watch a named Docker endpoint instead of --docker-host (format: name=host, repeatable)")
+	daemonCmd.Flags().StringVar(&cfg.DockerTLSCA, "docker-tls-ca", "", "CA certificate (PEM) that signed the certificate of tcp:// Docker hosts")
+	daemonCmd.Flags().StringVar(&cfg.DockerTLSCert, "docker-tls-cert", "", "Client certificate (PEM) for tcp:// Docker hosts")
+	daemonCmd.Flags().StringVar(&cfg.DockerTLSKey, "docker-tls-key", "", "Private key (PEM) of the Docker client certificate")
+	daemonCmd.Flags().StringVar(&cfg.LabelPrefix, "label-prefix", cfg.LabelPrefix, "Prefix of container labels to react to (e.g., docker-backup-staging)")
+	daemonCmd.Flags().StringVar(&cfg.InstanceName, "instance-name", cfg.InstanceName, "Name of this daemon shown in notifications (default: hostname)")
+	daemonCmd.Flags().BoolVar(&cfg.InstanceKeyPrefix, "instance-key-prefix", false, "Start backup keys with the instance name, to keep daemons writing to the same storage apart")
 	daemonCmd.Flags().StringVar(&cfg.DefaultStorage, "default-storage", "", "Default storage pool name")
+	daemonCmd.Flags().IntVar(&cfg.DefaultRetention, "default-retention", cfg.DefaultRetention, "Number of backups to keep for configs without a retention label")
+	daemonCmd.Flags().StringVar(&cfg.DefaultSchedule, "default-schedule", "", "Cron schedule for configs without a schedule label (e.g., \"0 3 * * *\")")
+	daemonCmd.Flags().StringVar(&cfg.Timezone, "timezone", "", "Time zone schedules are evaluated in (e.g., Europe/Berlin, default: local time)")
+	daemonCmd.Flags().DurationVar(&cfg.ScheduleJitter, "schedule-jitter", 0, "Delay each scheduled backup by a random duration up to this, to spread backups sharing a schedule (e.g., 10m)")
+	daemonCmd.Flags().BoolVar(&cfg.RunMissedOnStartup, "run-missed-on-startup", false, "Back up configs at startup whose scheduled run was missed since their newest backup")
+	daemonCmd.Flags().BoolVar(&cfg.IncludeStoppedContainers, "include-stopped-containers", false, "Also schedule stopped containers, for backup configs with a when-stopped label of backup or start")
+	daemonCmd.Flags().IntVar(&cfg.RetentionMinKeep, "retention-min-keep", 0, "Newest backups of each config that retention never deletes, overridable with a min-keep label")
+	daemonCmd.Flags().IntVar(&cfg.FailureHistory, "failure-history", cfg.FailureHistory, "Number of failed runs remembered per backup config (0 disables)")
+	daemonCmd.Flags().BoolVar(&cfg.RetentionDryRun, "retention-dry-run", false, "Only log the backups retention would delete instead of deleting them")
+	daemonCmd.Flags().BoolVar(&cfg.VerifyBackups, "verify-backups", false, "Read every backup back after storing it and compare its SHA-256 checksum")
+	daemonCmd.Flags().IntVar(&cfg.MaxConcurrentBackups, "max-concurrent-backups", 0, "Backups running at the same time across all containers, the rest wait for a free slot (0 means no limit)")
+	daemonCmd.Flags().IntVar(&cfg.BackupRetries, "backup-retries", 0, "Retries of a failed scheduled backup before its failure is notified (0 disables them)")
+	daemonCmd.Flags().DurationVar(&cfg.BackupRetryDelay, "backup-retry-delay", cfg.BackupRetryDelay, "Wait before the first retry of a failed scheduled backup, doubled for each further one")
+	daemonCmd.Flags().DurationVar(&cfg.BackupRetryMaxDelay, "backup-retry-max-delay", cfg.BackupRetryMaxDelay, "Maximum wait between retries of a failed scheduled backup")
+	daemonCmd.Flags().DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", cfg.ShutdownTimeout, "How long shutdown waits for running backups before cancelling them")
 	daemonCmd.Flags().StringVar(&cfg.TempDir, "temp-dir", os.TempDir(), "Temporary directory for backup files")
+	daemonCmd.Flags().StringVar(&cfg.AuditLog, "audit-log", "", "Append a JSON record of every backup, restore and delete to this file")
+	daemonCmd.Flags().StringVar(&cfg.StateDir, "state-dir", "", "Directory recording the containers stopped for backups, so they are started again after a crash (default: directory of --socket)")
+	daemonCmd.Flags().StringArrayVar(&cfg.EncryptionAgeRecipients, "encryption-age-recipient", []string{}, "Encrypt backups to this age public key (age1..., repeatable)")
+	daemonCmd.Flags().StringVar(&cfg.EncryptionAgeIdentityFile, "encryption-age-identity", "", "Path of the age identity file used to decrypt backups on restore")
 	daemonCmd.Flags().StringArrayVar(&cfg.StorageArgs, "storage", []string{}, "Storage pool configuration (format: pool.option=value)")
+	daemonCmd.Flags().IntVar(&cfg.StorageRetries, "storage-retries", cfg.StorageRetries, "Retries of storage operations after timeouts, network errors and 5xx responses")
+	daemonCmd.Flags().DurationVar(&cfg.StorageRetryDelay, "storage-retry-delay", cfg.StorageRetryDelay, "Wait before the first retry of a storage operation, doubled for each further one")
+	daemonCmd.Flags().DurationVar(&cfg.StorageRetryMaxDelay, "storage-retry-max-delay", cfg.StorageRetryMaxDelay, "Maximum wait between retries of storage operations")
+	daemonCmd.Flags().StringVar(&cfg.UploadRateLimit, "upload-rate-limit", "", "Maximum upload speed per second shared by all uploads (e.g., 10MB, default: no limit)")
 	daemonCmd.Flags().StringArrayVar(&cfg.NotifyArgs, "notify", []string{}, "Notification provider configuration (format: provider.option=value)")
+	daemonCmd.Flags().StringVar(&cfg.HealthAddr, "health-addr", "", "Serve the /healthz and /readyz probes on this address (e.g., :8081)")
 	daemonCmd.Flags().StringVar(&cfg.DashboardAddr, "dashboard", "", "Enable dashboard on address (e.g., :8080)")
 	daemonCmd.Flags().StringVar(&cfg.DashboardBasicAuth, "dashboard.auth.basic", "", "Dashboard basic auth (htpasswd file path or inline user:hash)")
+	daemonCmd.Flags().StringVar(&cfg.DashboardSessionSecret, "dashboard.session-secret", "", "Secret signing dashboard session cookies, keeps sessions valid across restarts (random if unset)")
+	daemonCmd.Flags().StringVar(&cfg.DashboardTLSCert, "dashboard.tls-cert", "", "Serve the dashboard over HTTPS with this certificate file (PEM)")
+	daemonCmd.Flags().StringVar(&cfg.DashboardTLSKey, "dashboard.tls-key", "", "Private key file (PEM) of the dashboard certificate")
+	daemonCmd.Flags().StringVar(&cfg.DashboardHTTPRedirect, "dashboard.http-redirect", "", "Redirect plain HTTP on this address to the HTTPS dashboard (e.g., :80)")
 	daemonCmd.Flags().StringVar(&cfg.DashboardOIDCProvider, "dashboard.auth.oidc.provider", "", "OIDC provider (google, github, or oidc)")
 	daemonCmd.Flags().StringVar(&cfg.DashboardOIDCIssuerURL, "dashboard.auth.oidc.issuer-url", "", "OIDC issuer URL (required for generic 'oidc' provider)")
 	daemonCmd.Flags().StringVar(&cfg.DashboardOIDCClientID, "dashboard.auth.oidc.client-id", "", "OIDC client ID")
@@ -41,23 +98,137 @@ func init() {
 	daemonCmd.Flags().StringVar(&cfg.DashboardOIDCRedirectURL, "dashboard.auth.oidc.redirect-url", "", "OIDC redirect URL (e.g., http://localhost:8080/auth/callback)")
 	daemonCmd.Flags().StringSliceVar(&cfg.DashboardOIDCAllowedUsers, "dashboard.auth.oidc.allowed-users", nil, "Allowed user emails (comma-separated)")
 	daemonCmd.Flags().StringSliceVar(&cfg.DashboardOIDCAllowedDomains, "dashboard.auth.oidc.allowed-domains", nil, "Allowed email domains (comma-separated)")
+	daemonCmd.Flags().StringVar(&cfg.TLSCAFile, "tls-ca-file", "", "Additional CA certificates for OIDC and notifier HTTPS (PEM file or directory)")
+	daemonCmd.Flags().DurationVar(&cfg.HTTPTimeout, "http-timeout", cfg.HTTPTimeout, "Time limit for outgoing OIDC and notifier requests, including retries (0 disables)")
+	daemonCmd.Flags().IntVar(&cfg.HTTPRetries, "http-retries", cfg.HTTPRetries, "Retries of outgoing requests after network errors and 429/5xx responses")
+	daemonCmd.Flags().DurationVar(&cfg.HTTPRetryMaxDelay, "http-retry-max-delay", cfg.HTTPRetryMaxDelay, "Maximum wait between retries of outgoing requests")
 }
 
-func runDaemon(cmd *cobra.Command, args []string) error {
-	setupLogging()
+func runDaemon(cmd *cobra.Command, args []string) (err error) {
+	// With --once, nothing has run when setup fails, which counts as all failed
+	defer func() {
+		var exitErr *exitError
+		if daemonOnce && err != nil && !errors.As(err, &exitErr) {
+			err = &exitError{code: onceExitAllFailed, err: err}
+		}
+	}()
+
+	// The file goes first, everything else reads the environment on top of it
+	if daemonConfigFile != "" {
+		file, err := config.LoadFile(daemonConfigFile)
+		if err != nil {
+			return err
+		}
+		cfg.ApplyFile(file, cmd.Flags().Changed)
+	}
+
+	// Keep stdout for the summary of --once
+	if daemonOnce {
+		setupLogging(os.Stderr)
+	} else {
+		setupLogging(os.Stdout)
+	}
+
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		cfg.SetSource(f.Name, config.SourceFlag)
+	})
 
 	slog.Info("starting docker-backup daemon",
 		"docker_host", cfg.DockerHost,
 		"poll_interval", cfg.PollInterval,
+		"label_prefix", cfg.LabelPrefix,
 	)
 
+	if daemonOnce && daemonConfigCheck {
+		return fmt.Errorf("--once and --config-check cannot be combined")
+	}
+	if daemonOutput != outputText && daemonOutput != outputJSON {
+		return fmt.Errorf("invalid output %q: must be %q or %q", daemonOutput, outputText, outputJSON)
+	}
+
+	if err := config.ValidateLabelPrefix(cfg.LabelPrefix); err != nil {
+		return err
+	}
+
+	if err := cfg.ParseDockerNodes(); err != nil {
+		return err
+	}
+
+	if err := cfg.LoadBackupDefaults(cmd.Flags().Changed("default-retention"), cmd.Flags().Changed("default-schedule")); err != nil {
+		return err
+	}
+	if err := cfg.LoadRetentionMinKeep(cmd.Flags().Changed("retention-min-keep")); err != nil {
+		return err
+	}
+	if err := cfg.LoadVerifyBackups(cmd.Flags().Changed("verify-backups")); err != nil {
+		return err
+	}
+	if err := cfg.LoadRetentionDryRun(cmd.Flags().Changed("retention-dry-run")); err != nil {
+		return err
+	}
+	location, err := cfg.LoadTimezone(cmd.Flags().Changed("timezone"))
+	if err != nil {
+		return err
+	}
+	if cfg.DefaultSchedule != "" {
+		if err := scheduler.ValidateSchedule(cfg.DefaultSchedule); err != nil {
+			return fmt.Errorf("invalid default schedule %q: %w", cfg.DefaultSchedule, err)
+		}
+	}
+
+	if err := cfg.ValidateInstanceName(); err != nil {
+		return err
+	}
+
+	if cfg.FailureHistory < 0 || cfg.FailureHistory > config.MaxFailureHistory {
+		return fmt.Errorf("failure history must be between 0 and %d, got %d", config.MaxFailureHistory, cfg.FailureHistory)
+	}
+	if cfg.ScheduleJitter < 0 {
+		return fmt.Errorf("schedule jitter must not be negative, got %s", cfg.ScheduleJitter)
+	}
+	if cfg.BackupRetries < 0 {
+		return fmt.Errorf("backup retries must not be negative, got %d", cfg.BackupRetries)
+	}
+	if cfg.MaxConcurrentBackups < 0 {
+		return fmt.Errorf("max concurrent backups must not be negative, got %d", cfg.MaxConcurrentBackups)
+	}
+	uploadRateLimit, err := cfg.UploadRateLimitBytes()
+	if err != nil {
+		return err
+	}
+
+	// Advisory instance lock next to the socket: warn when another daemon on this
+	// host would schedule the same containers. A config check runs next to the
+	// real daemon, so it takes no lock.
+	if !daemonConfigCheck {
+		lockDir := filepath.Dir(socketPath)
+		instanceLock, err := instance.Acquire(lockDir, cfg.LabelPrefix)
+		switch {
+		case errors.Is(err, instance.ErrPrefixInUse):
+			slog.Warn("another docker-backup instance uses the same label prefix, containers may be backed up twice",
+				"label_prefix", cfg.LabelPrefix,
+			)
+		case err != nil:
+			slog.Warn("failed to acquire instance lock", "dir", lockDir, "error", err)
+		default:
+			defer func() {
+				_ = instanceLock.Release()
+			}()
+		}
+		for _, other := range instance.Overlapping(lockDir, cfg.LabelPrefix) {
+			slog.Warn("another docker-backup instance uses an overlapping label prefix",
+				"label_prefix", cfg.LabelPrefix,
+				"other_prefix", other,
+			)
+		}
+	}
+
 	if err := cfg.ParseStoragePools(); err != nil {
 		return err
 	}
 
 	if len(cfg.StoragePools) == 0 {
-		slog.Error("no storage pools configured, use --storage flag to configure at least one")
-		os.Exit(1)
+		return fmt.Errorf("no storage pools configured, use --storage flag to configure at least one")
 	}
 
 	slog.Info("configured storage pools", "count", len(cfg.StoragePools))
@@ -69,9 +240,30 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if cfg.StorageRetries < 0 {
+		return fmt.Errorf("storage retries must not be negative, got %d", cfg.StorageRetries)
+	}
+	if cfg.HTTPRetries < 0 {
+		return fmt.Errorf("http retries must not be negative, got %d", cfg.HTTPRetries)
+	}
+	httpOpts := httpclient.DefaultOptions()
+	httpOpts.CAFile = cfg.TLSCAFile
+	httpOpts.Timeout = cfg.HTTPTimeout
+	httpOpts.Retry.Retries = cfg.HTTPRetries
+	httpOpts.Retry.MaxDelay = cfg.HTTPRetryMaxDelay
+	httpClient, err := httpclient.New(httpOpts)
+	if err != nil {
+		slog.Error("failed to load CA certificates", "path", cfg.TLSCAFile, "error", err)
+		return err
+	}
+	if cfg.TLSCAFile != "" {
+		slog.Info("custom CA certificates loaded", "path", cfg.TLSCAFile)
+	}
+
 	notifyMgr := notification.NewManager()
+	notifyMgr.SetInstance(cfg.InstanceName)
 	for name, dsn := range cfg.NotifyDSNs {
-		notifier, err := notification.CreateNotifierFromDSN(name, dsn)
+		notifier, err := notification.CreateNotifierFromDSN(name, dsn, httpClient)
 		if err != nil {
 			slog.Error("failed to create notifier", "name", name, "error", err)
 			return err
@@ -84,6 +276,32 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 		slog.Info("configured notification providers", "count", notifyMgr.NotifierCount())
 	}
 
+	cfg.LoadEncryptionKeys()
+	keyring, err := encryption.ParseKeyring(cfg.EncryptionKeys, cfg.EncryptionCurrentKey)
+	if err != nil {
+		slog.Error("invalid encryption keys", "error", err)
+		return err
+	}
+	if keyring != nil {
+		slog.Info("backup encryption enabled", "keys", len(keyring.IDs()), "current_key", keyring.Current())
+	}
+
+	cfg.LoadAgeEncryption(cmd.Flags().Changed("encryption-age-recipient"), cmd.Flags().Changed("encryption-age-identity"))
+	ageEncryption, err := encryption.ParseAge(cfg.EncryptionAgeRecipients, cfg.EncryptionAgeIdentityFile)
+	if err != nil {
+		slog.Error("invalid age encryption settings", "error", err)
+		return err
+	}
+	if ageEncryption.CanEncrypt() {
+		slog.Info("age backup encryption enabled", "recipients", ageEncryption.Recipients())
+		if keyring != nil {
+			slog.Info("new backups are encrypted with age, the encryption keys are only used to restore older backups")
+		}
+	}
+	if ageEncryption.CanDecrypt() {
+		slog.Info("age identity loaded for restores", "path", cfg.EncryptionAgeIdentityFile)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -92,19 +310,68 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 		slog.Error("failed to initialize storage pools", "error", err)
 		return err
 	}
+	poolManager.SetRetryPolicy(storage.RetryPolicy{
+		Retries:   cfg.StorageRetries,
+		BaseDelay: cfg.StorageRetryDelay,
+		MaxDelay:  cfg.StorageRetryMaxDelay,
+	})
 
-	dockerClient, err := docker.NewClient(cfg.DockerHost)
-	if err != nil {
-		slog.Error("failed to connect to Docker", "error", err)
+	if err := cfg.ValidateDockerTLS(); err != nil {
+		slog.Error("invalid docker TLS settings", "error", err)
 		return err
 	}
+	dockerTLS := docker.TLSOptions{CAFile: cfg.DockerTLSCA, CertFile: cfg.DockerTLSCert, KeyFile: cfg.DockerTLSKey}
+
+	var dockerClient *docker.MultiClient
+	if len(cfg.DockerNodes) > 0 {
+		if cmd.Flags().Changed("docker-host") {
+			slog.Warn("--docker-host is ignored when docker nodes are configured")
+		}
+		dockerClient, err = docker.ConnectNodes(cfg.DockerNodes, cfg.DockerNodeHost, dockerTLS)
+		if err != nil {
+			slog.Error("failed to set up docker nodes", "error", err)
+			return err
+		}
+		slog.Info("watching docker nodes", "nodes", cfg.DockerNodes)
+	} else {
+		client, err := docker.NewClientWithTLS(cfg.DockerHost, dockerTLS)
+		if err != nil {
+			slog.Error("failed to connect to Docker", "error", err)
+			return err
+		}
+		dockerClient = docker.SingleClient(client)
+	}
 	defer func() {
 		_ = dockerClient.Close()
 	}()
 
-	sched := scheduler.New()
+	if daemonConfigCheck {
+		cmd.SilenceUsage = true
+		return runConfigCheck(ctx, poolManager, dockerClient)
+	}
+
+	// Containers stopped for a backup are recorded until they run again, so
+	// those a killed daemon left stopped are started now
+	stateDir := cfg.StateDir
+	if stateDir == "" {
+		stateDir = filepath.Dir(socketPath)
+	}
+	stopJournal, err := docker.OpenStopJournal(filepath.Join(stateDir, "docker-backup-"+cfg.LabelPrefix+".stopped.json"))
+	if err != nil {
+		slog.Error("failed to open stop journal", "dir", stateDir, "error", err)
+		return err
+	}
+	dockerClient.SetStopJournal(stopJournal)
+	dockerClient.RestartStopped(ctx, stopJournal)
+
+	sched := scheduler.NewInLocation(ctx, location)
+	sched.SetJitter(cfg.ScheduleJitter)
 
 	retentionMgr := retention.New(poolManager)
+	retentionMgr.SetDryRun(cfg.RetentionDryRun)
+	if cfg.RetentionDryRun {
+		slog.Info("retention dry run enabled, expired backups are only logged")
+	}
 
 	backupMgr := backup.NewManager(
 		dockerClient,
@@ -112,14 +379,59 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 		sched,
 		retentionMgr,
 		notifyMgr,
+		keyring,
+		ageEncryption,
 		cfg,
 	)
 
+	if cfg.AuditLog != "" {
+		auditLog, err := audit.Open(cfg.AuditLog)
+		if err != nil {
+			slog.Error("failed to open audit log", "path", cfg.AuditLog, "error", err)
+			return err
+		}
+		defer func() {
+			_ = auditLog.Close()
+		}()
+		backupMgr.SetAuditLog(auditLog)
+		slog.Info("audit log enabled", "path", cfg.AuditLog)
+	}
+	if uploadRateLimit > 0 {
+		backupMgr.SetUploadRateLimit(uploadRateLimit)
+		slog.Info("upload rate limit enabled", "limit", config.FormatSize(uploadRateLimit)+"/s")
+	}
+
+	// The scheduler is never started, so only the manual runs happen
+	if daemonOnce {
+		cmd.SilenceUsage = true
+		return runOnce(ctx, backupMgr)
+	}
+
+	cfg.LoadAPIToken(cmd.Flags().Changed("api-token"))
 	apiServer := api.NewServer(socketPath)
+	if cfg.APIAddr != "" {
+		if err := apiServer.ListenTCP(cfg.APIAddr, cfg.APIToken); err != nil {
+			slog.Error("refusing to serve the API over TCP without --api-token or DOCKER_BACKUP_API_TOKEN", "addr", cfg.APIAddr)
+			return err
+		}
+	}
 	apiServer.SetBackupTrigger(backupMgr.TriggerBackup)
 	apiServer.SetBackupLister(backupMgr.ListBackups)
 	apiServer.SetBackupDeleter(backupMgr.DeleteBackup)
 	apiServer.SetBackupRestorer(backupMgr.RestoreBackup)
+	apiServer.SetLatestKeyResolver(backupMgr.LatestBackupKey)
+	apiServer.SetURLRestorer(backupMgr.RestoreFromURL)
+	apiServer.SetReencrypter(backupMgr.Reencrypt)
+	apiServer.SetBackupDiffer(backupMgr.DiffBackups)
+	apiServer.SetBackupExtractor(backupMgr.ExtractBackup)
+	apiServer.SetRestoreTester(backupMgr.RestoreTest)
+	apiServer.SetBackupDownloader(backupMgr.GetBackup)
+	apiServer.SetRetentionPreviewer(backupMgr.PreviewRetention)
+	apiServer.SetJobLister(backupMgr.Jobs)
+	apiServer.SetContainerLister(backupMgr.Containers)
+	apiServer.SetFailureLister(backupMgr.Failures)
+	apiServer.SetReadyCheck(backupMgr.IsReady)
+	apiServer.SetConfigProvider(cfg.Effective)
 
 	go func() {
 		if err := apiServer.Start(); err != nil && err != http.ErrServerClosed {
@@ -127,11 +439,15 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 		}
 	}()
 
-	cfg.LoadSessionSecret()
+	cfg.LoadSessionSecret(cmd.Flags().Changed("dashboard.session-secret"))
 
 	var dashboardServer *dashboard.Server
 	if cfg.DashboardAddr != "" {
-		dashboardServer = dashboard.NewServer(cfg.DashboardAddr, backupMgr, poolManager, sched, notifyMgr, cfg)
+		if err := cfg.ValidateDashboardTLS(); err != nil {
+			slog.Error("invalid dashboard TLS settings", "error", err)
+			return err
+		}
+		dashboardServer = dashboard.NewServer(cfg.DashboardAddr, backupMgr, poolManager, sched, notifyMgr, httpClient, cfg)
 		go func() {
 			if err := dashboardServer.Start(); err != nil && err != http.ErrServerClosed {
 				slog.Error("dashboard server error", "error", err)
@@ -139,6 +455,16 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 		}()
 	}
 
+	var healthServer *health.Server
+	if cfg.HealthAddr != "" {
+		healthServer = health.NewServer(cfg.HealthAddr, healthChecks(backupMgr, dockerClient, poolManager)...)
+		go func() {
+			if err := healthServer.Start(); err != nil && err != http.ErrServerClosed {
+				slog.Error("health server error", "error", err)
+			}
+		}()
+	}
+
 	sched.Start()
 
 	if err := backupMgr.Start(ctx); err != nil {
@@ -152,9 +478,18 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 	sig := <-sigChan
 	slog.Info("received shutdown signal", "signal", sig)
 
+	// Running backups finish first, they only see the cancellation after the timeout
+	if err := sched.Shutdown(cfg.ShutdownTimeout); err != nil {
+		slog.Warn("scheduler shutdown error", "error", err)
+	}
 	cancel()
 
-	sched.Stop()
+	// Backups that didn't finish in time may still have containers stopped
+	dockerClient.RestartStopped(context.Background(), stopJournal)
+
+	// Including the failures of retries dropped by the shutdown
+	backupMgr.WaitNotifications()
+
 	if err := apiServer.Shutdown(context.Background()); err != nil {
 		slog.Warn("API server shutdown error", "error", err)
 	}
@@ -163,7 +498,40 @@ func runDaemon(cmd *cobra.Command, args []string) error {
 			slog.Warn("dashboard server shutdown error", "error", err)
 		}
 	}
+	if healthServer != nil {
+		if err := healthServer.Shutdown(context.Background()); err != nil {
+			slog.Warn("health server shutdown error", "error", err)
+		}
+	}
 
 	slog.Info("daemon stopped")
 	return nil
 }
+
+// healthChecks returns the readiness checks of the daemon: the initial
+// container sync has completed, every Docker node responds and every storage
+// pool can be listed
+func healthChecks(backupMgr *backup.Manager, dockerClient *docker.MultiClient, poolManager *storage.PoolManager) []health.Check {
+	checks := []health.Check{
+		{Name: "containers", Run: func(context.Context) error {
+			if !backupMgr.IsReady() {
+				return errors.New("initial container sync has not completed")
+			}
+			return nil
+		}},
+		{Name: "docker", Run: dockerClient.Ping},
+	}
+
+	pools := poolManager.List()
+	sort.Strings(pools)
+	for _, name := range pools {
+		checks = append(checks, health.Check{Name: "storage:" + name, Run: func(ctx context.Context) error {
+			store, err := poolManager.Get(name)
+			if err != nil {
+				return err
+			}
+			return storage.Check(ctx, store)
+		}})
+	}
+	return checks
+}
@@ -3,15 +3,32 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"net"
 	"net/http"
-	"os"
 	"time"
 )
 
-// createSocketClient creates an HTTP client that connects via Unix socket
+// createSocketClient creates an HTTP client that connects via Unix socket, or
+// via TCP with the API token when --api-addr is set
 func createSocketClient() *http.Client {
+	if cfg.APIAddr != "" {
+		cfg.LoadAPIToken(rootCmd.PersistentFlags().Changed("api-token"))
+		return &http.Client{
+			Transport: &tokenTransport{
+				token: cfg.APIToken,
+				base: &http.Transport{
+					DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+						var d net.Dialer
+						return d.DialContext(ctx, "tcp", cfg.APIAddr)
+					},
+				},
+			},
+			Timeout: 5 * time.Minute,
+		}
+	}
+
 	return &http.Client{
 		Transport: &http.Transport{
 			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
@@ -22,22 +39,53 @@ func createSocketClient() *http.Client {
 	}
 }
 
-// formatSize formats bytes into human-readable size
-func formatSize(bytes int64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
+// apiEndpoint describes where createSocketClient connects to, for error messages
+func apiEndpoint() string {
+	if cfg.APIAddr != "" {
+		return cfg.APIAddr
 	}
-	div, exp := int64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
+	return socketPath
+}
+
+// tokenTransport sends the API token with every request
+type tokenTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	return t.base.RoundTrip(req)
+}
+
+// waitForDaemonReady polls the daemon's readiness endpoint until it reports ready or the timeout expires.
+// A daemon that was just started needs a moment to scan containers before it can resolve them by name.
+func waitForDaemonReady(client *http.Client, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		resp, err := client.Get("http://localhost/ready")
+		if err != nil {
+			return fmt.Errorf("failed to connect to daemon at %s: %w", apiEndpoint(), err)
+		}
+		_ = resp.Body.Close()
+
+		// Older daemons without a readiness endpoint are considered ready
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("daemon is still starting up after %s", timeout)
+		}
+
+		time.Sleep(500 * time.Millisecond)
 	}
-	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
 }
 
-// setupLogging configures the global logger based on config
-func setupLogging() {
+// setupLogging configures the global logger based on config, writing to w
+func setupLogging(w io.Writer) {
 	var level slog.Level
 	switch cfg.LogLevel {
 	case "debug":
@@ -54,9 +102,9 @@ func setupLogging() {
 
 	var handler slog.Handler
 	if cfg.LogFormat == "json" {
-		handler = slog.NewJSONHandler(os.Stdout, opts)
+		handler = slog.NewJSONHandler(w, opts)
 	} else {
-		handler = slog.NewTextHandler(os.Stdout, opts)
+		handler = slog.NewTextHandler(w, opts)
 	}
 
 	slog.SetDefault(slog.New(handler))
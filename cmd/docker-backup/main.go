@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"os"
 
 	"github.com/shyim/docker-backup/internal/api"
@@ -32,14 +33,34 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cfg.LogLevel, "log-level", "info", "Log level (debug, info, warn, error)")
 	rootCmd.PersistentFlags().StringVar(&cfg.LogFormat, "log-format", "text", "Log format (text, json)")
 	rootCmd.PersistentFlags().StringVar(&socketPath, "socket", api.DefaultSocketPath, "Unix socket path for API")
+	rootCmd.PersistentFlags().StringVar(&cfg.APIAddr, "api-addr", "", "TCP address of the API (e.g., :9000): the daemon listens on it, other commands connect to it instead of the socket")
+	rootCmd.PersistentFlags().StringVar(&cfg.APIToken, "api-token", "", "Bearer token of the TCP API (or DOCKER_BACKUP_API_TOKEN)")
 
 	// Add commands
 	rootCmd.AddCommand(daemonCmd)
 	rootCmd.AddCommand(backupCmd)
 }
 
+// exitError makes the process exit with code instead of 1
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
 func main() {
 	if err := rootCmd.Execute(); err != nil {
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/shyim/docker-backup/internal/config"
+)
+
+// Summary formats of --once
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// Exit codes of --once
+const (
+	onceExitSomeFailed = 1
+	onceExitAllFailed  = 2
+)
+
+// onceSummary is the machine-readable summary of --once --output json
+type onceSummary struct {
+	Success   bool               `json:"success"`
+	Succeeded int                `json:"succeeded"`
+	Failed    int                `json:"failed"`
+	Skipped   int                `json:"skipped"`
+	Results   []backup.RunResult `json:"results"`
+	Error     string             `json:"error,omitempty"`
+}
+
+// runOnce runs every enabled backup config once and prints a summary. The
+// returned error carries the exit code: 1 if some runs failed, 2 if all failed
+// or nothing could be run.
+func runOnce(ctx context.Context, backupMgr *backup.Manager) error {
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	results, err := backupMgr.RunOnce(ctx)
+	if err != nil {
+		printOnceSummary(onceSummary{Results: []backup.RunResult{}, Error: err.Error()})
+		return &exitError{code: onceExitAllFailed, err: err}
+	}
+
+	summary := onceSummary{Results: results}
+	for _, r := range results {
+		switch r.Status {
+		case backup.RunSucceeded:
+			summary.Succeeded++
+		case backup.RunFailed:
+			summary.Failed++
+		case backup.RunSkipped:
+			summary.Skipped++
+		}
+	}
+	summary.Success = summary.Failed == 0
+	if summary.Results == nil {
+		summary.Results = []backup.RunResult{}
+	}
+	printOnceSummary(summary)
+
+	switch {
+	case summary.Failed == 0:
+		return nil
+	case summary.Succeeded == 0:
+		return &exitError{code: onceExitAllFailed, err: fmt.Errorf("all %d backup(s) failed", summary.Failed)}
+	default:
+		return &exitError{code: onceExitSomeFailed, err: fmt.Errorf("%d of %d backup(s) failed", summary.Failed, summary.Failed+summary.Succeeded)}
+	}
+}
+
+func printOnceSummary(summary onceSummary) {
+	if daemonOutput == outputJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(summary)
+		return
+	}
+
+	if summary.Error != "" {
+		return
+	}
+	if len(summary.Results) == 0 {
+		fmt.Println("No running containers with backups enabled")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "CONTAINER\tCONFIG\tSTATUS\tSIZE\tDURATION\tDETAIL")
+	for _, r := range summary.Results {
+		size, duration := "-", "-"
+		if r.Status == backup.RunSucceeded {
+			size = config.FormatSize(r.Size)
+			duration = r.Duration.Round(time.Millisecond).String()
+		}
+		detail := r.Error
+		if detail == "" {
+			detail = r.BackupKey
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", r.Container, displayValue(r.Config), r.Status, size, duration, detail)
+	}
+	_ = w.Flush()
+
+	fmt.Printf("\nSucceeded %d, failed %d, skipped %d\n", summary.Succeeded, summary.Failed, summary.Skipped)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/api"
+	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/spf13/cobra"
+)
+
+var restoreTestCmd = &cobra.Command{
+	Use:   "restore-test <container-name> <backup-key|latest>",
+	Short: "Restore a backup into a throwaway container and check it",
+	Long: `Restore a backup into a throwaway copy of a container and report whether it passed, without
+touching the container itself. The copy runs from the same image (or --image) with the same
+environment, but without networks, and with fresh volumes in place of the container's mounts.
+Database types wait for the server in the copy to start before restoring into it.
+
+After the restore, --query runs a query against the restored database and --exec a shell
+command in the copy; the test fails if either fails. The copy and its volumes are removed
+afterwards. The command exits non-zero if the test failed, so it can run in CI.
+
+Pass "latest" as the key to test the newest backup of the config selected with --config.`,
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE:         runRestoreTest,
+}
+
+var (
+	restoreTestConfig       string
+	restoreTestImage        string
+	restoreTestQuery        string
+	restoreTestExec         string
+	restoreTestStartTimeout time.Duration
+	restoreTestJSON         bool
+)
+
+func init() {
+	restoreTestCmd.Flags().StringVar(&restoreTestConfig, "config", "", "Backup config whose newest backup is tested with the key \"latest\" (default: the container's only config)")
+	restoreTestCmd.Flags().StringVar(&restoreTestImage, "image", "", "Image of the throwaway container (default: the container's image)")
+	restoreTestCmd.Flags().StringVar(&restoreTestQuery, "query", "", "Query run against the restored database, e.g. \"SELECT count(*) FROM users\" (database types only)")
+	restoreTestCmd.Flags().StringVar(&restoreTestExec, "exec", "", "Shell command run in the throwaway container after the restore, must exit with 0")
+	restoreTestCmd.Flags().DurationVar(&restoreTestStartTimeout, "start-timeout", backup.DefaultRestoreTestStartTimeout, "How long to wait for the database in the throwaway container to start")
+	restoreTestCmd.Flags().BoolVar(&restoreTestJSON, "json", false, "Print the result as JSON")
+	rootCmd.AddCommand(restoreTestCmd)
+}
+
+func runRestoreTest(cmd *cobra.Command, args []string) error {
+	containerName := args[0]
+	backupKey := args[1]
+
+	body, err := json.Marshal(backup.RestoreTestOptions{
+		Config:       restoreTestConfig,
+		Image:        restoreTestImage,
+		Query:        restoreTestQuery,
+		Exec:         restoreTestExec,
+		StartTimeout: restoreTestStartTimeout,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	client := createSocketClient()
+	// Pulling the image and restoring a large backup can take a long time
+	client.Timeout = 0
+
+	url := fmt.Sprintf("http://localhost/backup/restore-test/%s/%s", containerName, backupKey)
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to connect to daemon at %s: %w", apiEndpoint(), err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var response api.RestoreTestResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if !response.Success {
+		return fmt.Errorf("restore test failed to run: %s", response.Error)
+	}
+	result := response.Result
+
+	if restoreTestJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			return err
+		}
+	} else {
+		status := "PASSED"
+		if !result.Passed {
+			status = "FAILED"
+		}
+		fmt.Printf("Restore test %s: %s\n", status, result.Key)
+		fmt.Printf("Image:    %s\n", result.Image)
+		fmt.Printf("Duration: %s\n", result.Duration.Round(time.Millisecond))
+		if result.Error != "" {
+			fmt.Printf("Error:    %s\n", result.Error)
+		}
+		if output := strings.TrimSpace(result.Output); output != "" {
+			fmt.Printf("\n%s\n", output)
+		}
+	}
+
+	if !result.Passed {
+		return fmt.Errorf("restore test of %s failed", result.Key)
+	}
+	return nil
+}
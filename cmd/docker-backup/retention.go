@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/shyim/docker-backup/internal/api"
+	"github.com/shyim/docker-backup/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var retentionCmd = &cobra.Command{
+	Use:   "retention",
+	Short: "Retention commands",
+	Long:  "Commands for inspecting retention policies.",
+}
+
+var retentionPreviewCmd = &cobra.Command{
+	Use:   "preview <container-name>",
+	Short: "Show the backups retention would delete",
+	Long:  "Show the backups of a container that the running daemon's retention policies would delete now, for each backup config and storage pool. Nothing is deleted.",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runRetentionPreview,
+}
+
+var retentionPreviewJSON bool
+
+func init() {
+	retentionPreviewCmd.Flags().BoolVar(&retentionPreviewJSON, "json", false, "Print the preview as JSON")
+	retentionCmd.AddCommand(retentionPreviewCmd)
+	rootCmd.AddCommand(retentionCmd)
+}
+
+func runRetentionPreview(cmd *cobra.Command, args []string) error {
+	containerName := args[0]
+
+	client := createSocketClient()
+
+	url := fmt.Sprintf("http://localhost/retention/preview/%s", containerName)
+	resp, err := client.Get(url)
+	if err != nil {
+		return fmt.Errorf("failed to connect to daemon at %s: %w", apiEndpoint(), err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var result api.RetentionPreviewResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if !result.Success {
+		return fmt.Errorf("failed to preview retention: %s", result.Error)
+	}
+
+	if retentionPreviewJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result.Previews)
+	}
+
+	total := 0
+	for _, p := range result.Previews {
+		name := p.Config
+		if p.Part != "" {
+			name += "/" + p.Part
+		}
+		if p.Error != "" {
+			fmt.Printf("%s (storage %s): failed to list backups: %s\n", name, p.Storage, p.Error)
+			continue
+		}
+		if len(p.Backups) == 0 {
+			fmt.Printf("%s (storage %s): nothing to delete\n", name, p.Storage)
+			continue
+		}
+		fmt.Printf("%s (storage %s): %d backup(s) would be deleted\n", name, p.Storage, len(p.Backups))
+		for _, b := range p.Backups {
+			fmt.Printf("  %s  %s  %s\n", b.Key, config.FormatSize(b.Size), b.LastModified.Format("2006-01-02 15:04:05"))
+		}
+		total += len(p.Backups)
+	}
+
+	fmt.Printf("\n%d backup(s) would be deleted\n", total)
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/api"
+	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/spf13/cobra"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the containers the daemon manages",
+	Long:  "Show every container the running daemon tracks with its backup configs as parsed from the labels, their state, the next scheduled run and the result of the last run. Use it to check that label changes took effect.",
+	Args:  cobra.NoArgs,
+	RunE:  runStatus,
+}
+
+var statusJSON bool
+
+func init() {
+	statusCmd.Flags().BoolVar(&statusJSON, "json", false, "Print the status as JSON")
+	rootCmd.AddCommand(statusCmd)
+}
+
+func runStatus(cmd *cobra.Command, args []string) error {
+	client := createSocketClient()
+
+	resp, err := client.Get("http://localhost/containers")
+	if err != nil {
+		return fmt.Errorf("failed to connect to daemon at %s: %w", apiEndpoint(), err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var result api.ContainersResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if !result.Success {
+		return fmt.Errorf("failed to get status: %s", result.Error)
+	}
+
+	if statusJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result.Containers)
+	}
+
+	if len(result.Containers) == 0 {
+		fmt.Println("No containers with backups enabled")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "CONTAINER\tCONFIG\tTYPE\tSCHEDULE\tRETENTION\tSTORAGE\tSTATE\tNEXT RUN\tLAST RUN")
+	for _, c := range result.Containers {
+		for _, cfg := range c.Configs {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				c.ContainerName,
+				cfg.Name,
+				cfg.BackupType,
+				cfg.Schedule,
+				cfg.Retention,
+				storageColumn(cfg),
+				cfg.State,
+				formatNextRun(cfg.NextRun),
+				formatLastRun(cfg),
+			)
+		}
+	}
+	return w.Flush()
+}
+
+// formatNextRun shows the next run in local time, or a dash when none is scheduled
+func formatNextRun(next *time.Time) string {
+	if next == nil {
+		return "-"
+	}
+	return next.Local().Format("2006-01-02 15:04:05")
+}
+
+// formatLastRun summarizes the last finished run of a config
+func formatLastRun(cfg backup.ConfigStatus) string {
+	last := cfg.LastResult
+	if last == nil {
+		return "-"
+	}
+	finished := last.FinishedAt.Local().Format("2006-01-02 15:04:05")
+	if last.Success {
+		return finished + " ok"
+	}
+	if cfg.ConsecutiveFailures > 1 {
+		return fmt.Sprintf("%s failed (%d in a row)", finished, cfg.ConsecutiveFailures)
+	}
+	return finished + " failed"
+}
+
+// storageColumn shows the storage chain of a config, followed by its mirrors
+func storageColumn(cfg backup.ConfigStatus) string {
+	storage := displayValue(strings.Join(append([]string{cfg.Storage}, cfg.Fallback...), ","))
+	if len(cfg.Mirrors) > 0 {
+		storage += " +" + strings.Join(cfg.Mirrors, ",")
+	}
+	return storage
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/shyim/docker-backup/internal/config"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate backup labels without deploying",
+	Long: `Validate backup labels from a JSON labels file or a Docker Compose file and print the result as JSON.
+
+Each backup config is checked for a valid cron schedule, a registered backup type and an existing storage pool.
+Storage pools are read from --storage flags and DOCKER_BACKUP_STORAGE_* environment variables like the daemon does;
+without any pools the storage check is skipped. The command exits non-zero if any config is invalid.`,
+	SilenceUsage: true,
+	RunE:         runValidate,
+}
+
+var (
+	validateLabelsFile  string
+	validateComposeFile string
+	validateName        string
+)
+
+func init() {
+	validateCmd.Flags().StringVar(&validateLabelsFile, "labels-file", "", "JSON file with labels: an object of labels, or an object mapping container names to labels")
+	validateCmd.Flags().StringVar(&validateComposeFile, "compose-file", "", "Docker Compose file whose service labels are validated")
+	validateCmd.Flags().StringVar(&validateName, "name", "container", "Container name used for a labels file with a single set of labels")
+	validateCmd.Flags().StringVar(&cfg.LabelPrefix, "label-prefix", cfg.LabelPrefix, "Prefix of container labels to validate")
+	validateCmd.Flags().StringVar(&cfg.DefaultStorage, "default-storage", "", "Default storage pool name")
+	validateCmd.Flags().IntVar(&cfg.DefaultRetention, "default-retention", cfg.DefaultRetention, "Number of backups to keep for configs without a retention label")
+	validateCmd.Flags().StringVar(&cfg.DefaultSchedule, "default-schedule", "", "Cron schedule for configs without a schedule label")
+	validateCmd.Flags().StringArrayVar(&cfg.StorageArgs, "storage", []string{}, "Storage pool configuration (format: pool.option=value)")
+
+	rootCmd.AddCommand(validateCmd)
+}
+
+// validationResult is the JSON output of the validate command
+type validationResult struct {
+	Valid          bool              `json:"valid"`
+	StorageChecked bool              `json:"storage_checked"`
+	Containers     []containerResult `json:"containers"`
+}
+
+// containerResult is the validation result of one container's labels
+type containerResult struct {
+	Container string               `json:"container"`
+	Enabled   bool                 `json:"enabled"`
+	Valid     bool                 `json:"valid"`
+	Error     string               `json:"error,omitempty"` // Labels that could not be parsed
+	Configs   []backup.ConfigCheck `json:"configs,omitempty"`
+}
+
+func runValidate(cmd *cobra.Command, args []string) error {
+	if (validateLabelsFile == "") == (validateComposeFile == "") {
+		return fmt.Errorf("exactly one of --labels-file or --compose-file is required")
+	}
+
+	if err := config.ValidateLabelPrefix(cfg.LabelPrefix); err != nil {
+		return err
+	}
+	if err := cfg.LoadBackupDefaults(cmd.Flags().Changed("default-retention"), cmd.Flags().Changed("default-schedule")); err != nil {
+		return err
+	}
+	if err := cfg.ParseStoragePools(); err != nil {
+		return err
+	}
+
+	var containers map[string]map[string]string
+	var err error
+	if validateLabelsFile != "" {
+		containers, err = loadLabelsFile(validateLabelsFile, validateName)
+	} else {
+		containers, err = loadComposeLabels(validateComposeFile)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Storage can only be checked against pools configured for this run
+	var pools map[string]*config.StoragePool
+	if len(cfg.StoragePools) > 0 {
+		pools = cfg.StoragePools
+	}
+
+	result := validationResult{
+		Valid:          true,
+		StorageChecked: pools != nil,
+		Containers:     make([]containerResult, 0, len(containers)),
+	}
+
+	names := make([]string, 0, len(containers))
+	for name := range containers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	invalid := 0
+	for _, name := range names {
+		res := containerResult{Container: name, Valid: true}
+
+		parsed, err := config.ParseLabelsWithDefaults(cfg.LabelPrefix, cfg.BackupDefaults(), "", name, containers[name])
+		if err != nil {
+			res.Valid = false
+			res.Error = err.Error()
+			invalid++
+		} else {
+			res.Enabled = parsed.Enabled
+			for _, b := range parsed.Backups {
+				check := backup.CheckBackupConfig(b, pools, cfg.DefaultStorage)
+				if !check.Valid {
+					res.Valid = false
+					invalid++
+				}
+				res.Configs = append(res.Configs, check)
+			}
+		}
+
+		result.Valid = result.Valid && res.Valid
+		result.Containers = append(result.Containers, res)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(result); err != nil {
+		return fmt.Errorf("failed to encode result: %w", err)
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("validation failed: %d invalid backup config(s)", invalid)
+	}
+	return nil
+}
+
+// loadLabelsFile reads a JSON object of labels for a single container, or an
+// object mapping container names to their labels
+func loadLabelsFile(path, name string) (map[string]map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read labels file: %w", err)
+	}
+
+	var flat map[string]string
+	if err := json.Unmarshal(data, &flat); err == nil {
+		return map[string]map[string]string{name: flat}, nil
+	}
+
+	var byContainer map[string]map[string]string
+	if err := json.Unmarshal(data, &byContainer); err != nil {
+		return nil, fmt.Errorf("failed to parse labels file %s: expected an object of labels or an object of containers with labels: %w", path, err)
+	}
+	return byContainer, nil
+}
+
+// composeFile is the part of a Docker Compose file the validate command reads
+type composeFile struct {
+	Services map[string]struct {
+		ContainerName string    `yaml:"container_name"`
+		Labels        yaml.Node `yaml:"labels"`
+	} `yaml:"services"`
+}
+
+// loadComposeLabels reads the labels of every service in a Compose file, keyed by
+// container_name if set and the service name otherwise
+func loadComposeLabels(path string) (map[string]map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compose file: %w", err)
+	}
+
+	var compose composeFile
+	if err := yaml.Unmarshal(data, &compose); err != nil {
+		return nil, fmt.Errorf("failed to parse compose file %s: %w", path, err)
+	}
+
+	containers := make(map[string]map[string]string, len(compose.Services))
+	for service, svc := range compose.Services {
+		name := service
+		if svc.ContainerName != "" {
+			name = svc.ContainerName
+		}
+
+		labels, err := composeLabels(&svc.Labels)
+		if err != nil {
+			return nil, fmt.Errorf("invalid labels of service %s: %w", service, err)
+		}
+		containers[name] = labels
+	}
+	return containers, nil
+}
+
+// composeLabels decodes labels given either as a map or as a list of key=value items
+func composeLabels(node *yaml.Node) (map[string]string, error) {
+	labels := make(map[string]string)
+	switch node.Kind {
+	case 0:
+		// No labels
+	case yaml.MappingNode:
+		if err := node.Decode(&labels); err != nil {
+			return nil, err
+		}
+	case yaml.SequenceNode:
+		var items []string
+		if err := node.Decode(&items); err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			key, value, _ := strings.Cut(item, "=")
+			labels[key] = value
+		}
+	default:
+		return nil, fmt.Errorf("labels must be a map or a list")
+	}
+	return labels, nil
+}
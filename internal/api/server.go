@@ -2,14 +2,23 @@ package api
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/shyim/docker-backup/internal/audit"
+	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/shyim/docker-backup/internal/config"
+	"github.com/shyim/docker-backup/internal/metrics"
 	"github.com/shyim/docker-backup/internal/storage"
 )
 
@@ -26,8 +35,50 @@ type BackupLister func(ctx context.Context, containerName string) ([]storage.Bac
 // BackupDeleter is a function that deletes a backup
 type BackupDeleter func(ctx context.Context, containerName, backupKey string) error
 
-// BackupRestorer is a function that restores a backup
-type BackupRestorer func(ctx context.Context, containerName, backupKey string) error
+// BackupRestorer is a function that restores a backup, with overrides replacing
+// backup config options (e.g. restore-mode) for this restore only
+type BackupRestorer func(ctx context.Context, containerName, backupKey string, overrides map[string]string) error
+
+// LatestKeyResolver is a function that returns the key of the newest backup of
+// a container's backup config, which may be empty for containers with a single config
+type LatestKeyResolver func(ctx context.Context, containerName, configName string) (string, error)
+
+// URLRestorer is a function that restores a container from a backup fetched over HTTP(S)
+type URLRestorer func(ctx context.Context, containerName string, src backup.RemoteSource, overrides map[string]string) error
+
+// JobLister is a function that returns the status of all scheduled backup configs
+type JobLister func() []backup.JobStatus
+
+// ContainerLister is a function that returns the tracked containers with the status of their backup configs
+type ContainerLister func() []backup.ContainerStatus
+
+// FailureLister is a function that returns the recent failures of a container's backup configs
+type FailureLister func(ctx context.Context, containerName string) (map[string][]backup.FailureRecord, error)
+
+// Reencrypter is a function that re-encrypts a container's backups with the current key
+type Reencrypter func(ctx context.Context, containerName string) (*backup.ReencryptResult, error)
+
+// BackupDiffer is a function that compares two backups of a container
+type BackupDiffer func(ctx context.Context, containerName, fromKey, toKey string) (*backup.BackupDiff, error)
+
+// BackupExtractor is a function that writes the contents of a backup to a
+// directory on the daemon's filesystem
+type BackupExtractor func(ctx context.Context, containerName, backupKey, dest string) error
+
+// RestoreTester is a function that restores a backup into a throwaway copy of a container and checks it
+type RestoreTester func(ctx context.Context, containerName, backupKey string, opts backup.RestoreTestOptions) (*backup.RestoreTestResult, error)
+
+// BackupDownloader is a function that opens a stored backup for reading
+type BackupDownloader func(ctx context.Context, containerName, backupKey string) (io.ReadCloser, error)
+
+// RetentionPreviewer is a function that returns the backups retention would delete for a container
+type RetentionPreviewer func(ctx context.Context, containerName string) ([]backup.RetentionPreview, error)
+
+// ConfigProvider returns the daemon's effective configuration with secrets redacted
+type ConfigProvider func() config.EffectiveConfig
+
+// ReadyCheck reports whether the daemon has finished its initial container sync
+type ReadyCheck func() bool
 
 // BackupResponse is the response for a backup trigger request
 type BackupResponse struct {
@@ -63,15 +114,110 @@ type RestoreResponse struct {
 	Error     string `json:"error,omitempty"`
 }
 
-// Server provides HTTP API over Unix socket
+// JobsResponse is the response for a job status request
+type JobsResponse struct {
+	Success bool               `json:"success"`
+	Jobs    []backup.JobStatus `json:"jobs"`
+	Error   string             `json:"error,omitempty"`
+}
+
+// ContainersResponse is the response for a container status request
+type ContainersResponse struct {
+	Success    bool                     `json:"success"`
+	Containers []backup.ContainerStatus `json:"containers"`
+	Error      string                   `json:"error,omitempty"`
+}
+
+// FailuresResponse is the response for a failure history request
+type FailuresResponse struct {
+	Success   bool                              `json:"success"`
+	Container string                            `json:"container"`
+	Failures  map[string][]backup.FailureRecord `json:"failures,omitempty"` // Keyed by config name, newest first
+	Error     string                            `json:"error,omitempty"`
+}
+
+// ReencryptResponse is the response for a reencrypt request
+type ReencryptResponse struct {
+	Success   bool                    `json:"success"`
+	Container string                  `json:"container"`
+	Result    *backup.ReencryptResult `json:"result,omitempty"`
+	Error     string                  `json:"error,omitempty"`
+}
+
+// DiffResponse is the response for a backup diff request
+type DiffResponse struct {
+	Success   bool               `json:"success"`
+	Container string             `json:"container"`
+	Diff      *backup.BackupDiff `json:"diff,omitempty"`
+	Error     string             `json:"error,omitempty"`
+}
+
+// RetentionPreviewResponse is the response for a retention preview request
+type RetentionPreviewResponse struct {
+	Success   bool                      `json:"success"`
+	Container string                    `json:"container"`
+	Previews  []backup.RetentionPreview `json:"previews,omitempty"` // One per backup config and storage pool
+	Error     string                    `json:"error,omitempty"`
+}
+
+// ExtractResponse is the response for a backup extract request
+type ExtractResponse struct {
+	Success   bool   `json:"success"`
+	Container string `json:"container"`
+	Key       string `json:"key,omitempty"`
+	Dest      string `json:"dest,omitempty"`
+	Message   string `json:"message,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// RestoreTestResponse is the response for a restore test request. Success
+// only says the test ran, Result.Passed whether the backup restored.
+type RestoreTestResponse struct {
+	Success   bool                      `json:"success"`
+	Container string                    `json:"container"`
+	Key       string                    `json:"key,omitempty"`
+	Result    *backup.RestoreTestResult `json:"result,omitempty"`
+	Error     string                    `json:"error,omitempty"`
+}
+
+// ConfigResponse is the response for an effective configuration request
+type ConfigResponse struct {
+	Success bool                    `json:"success"`
+	Config  *config.EffectiveConfig `json:"config,omitempty"`
+	Error   string                  `json:"error,omitempty"`
+}
+
+// ReadyResponse is the response for a readiness request
+type ReadyResponse struct {
+	Ready   bool   `json:"ready"`
+	Message string `json:"message,omitempty"`
+}
+
+// Server provides HTTP API over Unix socket and optionally over TCP
 type Server struct {
-	socketPath     string
-	server         *http.Server
-	listener       net.Listener
-	backupTrigger  BackupTrigger
-	backupLister   BackupLister
-	backupDeleter  BackupDeleter
-	backupRestorer BackupRestorer
+	socketPath       string
+	server           *http.Server
+	listener         net.Listener
+	tcpAddr          string
+	tcpToken         string
+	tcpServer        *http.Server
+	backupTrigger    BackupTrigger
+	backupLister     BackupLister
+	backupDeleter    BackupDeleter
+	backupRestorer   BackupRestorer
+	latestResolver   LatestKeyResolver
+	urlRestorer      URLRestorer
+	reencrypter      Reencrypter
+	backupDiffer     BackupDiffer
+	extractor        BackupExtractor
+	restoreTester    RestoreTester
+	downloader       BackupDownloader
+	retentionPreview RetentionPreviewer
+	configProvider   ConfigProvider
+	jobLister        JobLister
+	containerLister  ContainerLister
+	failureLister    FailureLister
+	readyCheck       ReadyCheck
 }
 
 // NewServer creates a new API server
@@ -104,8 +250,92 @@ func (s *Server) SetBackupRestorer(restorer BackupRestorer) {
 	s.backupRestorer = restorer
 }
 
-// Start begins serving API endpoints on Unix socket
+// SetLatestKeyResolver sets the function to call when restoring the latest backup
+func (s *Server) SetLatestKeyResolver(resolver LatestKeyResolver) {
+	s.latestResolver = resolver
+}
+
+// SetURLRestorer sets the function to call when restoring from a URL
+func (s *Server) SetURLRestorer(restorer URLRestorer) {
+	s.urlRestorer = restorer
+}
+
+// SetReencrypter sets the function to call when re-encrypting backups
+func (s *Server) SetReencrypter(reencrypter Reencrypter) {
+	s.reencrypter = reencrypter
+}
+
+// SetBackupDiffer sets the function to call when comparing two backups
+func (s *Server) SetBackupDiffer(differ BackupDiffer) {
+	s.backupDiffer = differ
+}
+
+// SetBackupExtractor sets the function to call when extracting a backup to a directory
+func (s *Server) SetBackupExtractor(extractor BackupExtractor) {
+	s.extractor = extractor
+}
+
+// SetRestoreTester sets the function to call when testing a restore
+func (s *Server) SetRestoreTester(tester RestoreTester) {
+	s.restoreTester = tester
+}
+
+// SetBackupDownloader sets the function to call when downloading a backup
+func (s *Server) SetBackupDownloader(downloader BackupDownloader) {
+	s.downloader = downloader
+}
+
+// SetRetentionPreviewer sets the function to call when previewing retention
+func (s *Server) SetRetentionPreviewer(previewer RetentionPreviewer) {
+	s.retentionPreview = previewer
+}
+
+// SetConfigProvider sets the function that returns the effective configuration
+func (s *Server) SetConfigProvider(provider ConfigProvider) {
+	s.configProvider = provider
+}
+
+// SetJobLister sets the function to call when listing job statuses
+func (s *Server) SetJobLister(lister JobLister) {
+	s.jobLister = lister
+}
+
+// SetContainerLister sets the function to call when listing tracked containers
+func (s *Server) SetContainerLister(lister ContainerLister) {
+	s.containerLister = lister
+}
+
+// SetFailureLister sets the function to call when listing recent failures
+func (s *Server) SetFailureLister(lister FailureLister) {
+	s.failureLister = lister
+}
+
+// SetReadyCheck sets the function used to decide whether container-scoped
+// operations can be served. Without one the server always reports ready.
+func (s *Server) SetReadyCheck(check ReadyCheck) {
+	s.readyCheck = check
+}
+
+// ListenTCP makes Start also serve the API on a TCP address. Unlike the
+// socket, TCP requests must carry token as a bearer token, so an empty token
+// is refused. It must be called before Start.
+func (s *Server) ListenTCP(addr, token string) error {
+	if token == "" {
+		return fmt.Errorf("the TCP API at %s requires a token", addr)
+	}
+	s.tcpAddr = addr
+	s.tcpToken = token
+	return nil
+}
+
+// Start begins serving API endpoints on Unix socket, and on TCP if enabled
 func (s *Server) Start() error {
+	// Refuse to take over a socket another running instance is still serving on
+	if conn, err := net.DialTimeout("unix", s.socketPath, time.Second); err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("socket %s is already in use by another docker-backup instance, use --socket to choose a different path", s.socketPath)
+	}
+
 	if err := os.RemoveAll(s.socketPath); err != nil {
 		return err
 	}
@@ -123,21 +353,83 @@ func (s *Server) Start() error {
 
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("/backup/run/", s.handleBackupRun)
-	mux.HandleFunc("/backup/list/", s.handleBackupList)
-	mux.HandleFunc("/backup/delete/", s.handleBackupDelete)
-	mux.HandleFunc("/backup/restore/", s.handleBackupRestore)
+	mux.HandleFunc("/ready", s.handleReady)
+	mux.HandleFunc("/config", s.handleConfig)
+	mux.Handle("/metrics", metrics.Default.Handler())
+	mux.HandleFunc("/backup/run/", s.requireReady(s.handleBackupRun))
+	mux.HandleFunc("/backup/list/", s.requireReady(s.handleBackupList))
+	mux.HandleFunc("/backup/delete/", s.requireReady(s.handleBackupDelete))
+	mux.HandleFunc("/backup/restore/", s.requireReady(s.handleBackupRestore))
+	mux.HandleFunc("/backup/restore-url/", s.requireReady(s.handleBackupRestoreURL))
+	mux.HandleFunc("/backup/failures/", s.requireReady(s.handleBackupFailures))
+	mux.HandleFunc("/backup/reencrypt/", s.requireReady(s.handleBackupReencrypt))
+	mux.HandleFunc("/backup/diff/", s.requireReady(s.handleBackupDiff))
+	mux.HandleFunc("/backup/extract/", s.requireReady(s.handleBackupExtract))
+	mux.HandleFunc("/backup/restore-test/", s.requireReady(s.handleRestoreTest))
+	mux.HandleFunc("/backup/download/", s.requireReady(s.handleBackupDownload))
+	mux.HandleFunc("/retention/preview/", s.requireReady(s.handleRetentionPreview))
+	mux.HandleFunc("/jobs", s.requireReady(s.handleJobs))
+	mux.HandleFunc("/containers", s.requireReady(s.handleContainers))
 
 	s.server = &http.Server{
-		Handler:      mux,
+		Handler:      withAuditActor("socket", mux),
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 5 * time.Minute,
 	}
 
+	if s.tcpAddr != "" {
+		tcpListener, err := net.Listen("tcp", s.tcpAddr)
+		if err != nil {
+			_ = listener.Close()
+			return fmt.Errorf("failed to listen on %s: %w", s.tcpAddr, err)
+		}
+		s.tcpServer = &http.Server{
+			Handler:      requireToken(s.tcpToken, withAuditActor("tcp", mux)),
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 5 * time.Minute,
+		}
+		go func() {
+			slog.Info("starting API server", "addr", s.tcpAddr)
+			if err := s.tcpServer.Serve(tcpListener); err != nil && err != http.ErrServerClosed {
+				slog.Error("TCP API server error", "error", err)
+			}
+		}()
+	}
+
 	slog.Info("starting API server", "socket", s.socketPath)
 	return s.server.Serve(listener)
 }
 
+// requireToken rejects requests without "Authorization: Bearer <token>"
+func requireToken(token string, next http.Handler) http.Handler {
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(BackupResponse{
+				Success: false,
+				Error:   "missing or invalid API token",
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withAuditActor records the operations of API requests in the audit log as
+// done through transport, along with the client address over TCP
+func withAuditActor(transport string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor := "api:" + transport
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil && host != "" {
+			actor += ":" + host
+		}
+		next.ServeHTTP(w, r.WithContext(audit.WithActor(r.Context(), actor)))
+	})
+}
+
 // Shutdown gracefully stops the server
 func (s *Server) Shutdown(ctx context.Context) error {
 	if s.server == nil {
@@ -145,6 +437,9 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	}
 
 	err := s.server.Shutdown(ctx)
+	if s.tcpServer != nil {
+		err = errors.Join(err, s.tcpServer.Shutdown(ctx))
+	}
 
 	_ = os.RemoveAll(s.socketPath)
 
@@ -156,6 +451,44 @@ func (s *Server) SocketPath() string {
 	return s.socketPath
 }
 
+func (s *Server) isReady() bool {
+	return s.readyCheck == nil || s.readyCheck()
+}
+
+// requireReady rejects requests with 503 until the initial container sync has completed,
+// so callers don't get "container not found" errors for containers that simply weren't scanned yet
+func (s *Server) requireReady(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.isReady() {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_ = json.NewEncoder(w).Encode(BackupResponse{
+				Success: false,
+				Error:   "daemon is starting up, try again shortly",
+			})
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.isReady() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(ReadyResponse{
+			Ready:   false,
+			Message: "starting up",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(ReadyResponse{Ready: true})
+}
+
 func (s *Server) handleBackupRun(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -180,9 +513,11 @@ func (s *Server) handleBackupRun(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	slog.Info("backup triggered via API", "container", containerName)
+	// Without a config every backup config of the container runs
+	configName := r.URL.Query().Get("config")
+	slog.Info("backup triggered via API", "container", containerName, "config", configName)
 
-	if err := s.backupTrigger(r.Context(), containerName); err != nil {
+	if err := s.backupTrigger(r.Context(), containerName, configName); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		_ = json.NewEncoder(w).Encode(BackupResponse{
 			Success:   false,
@@ -319,9 +654,38 @@ func (s *Server) handleBackupRestore(w http.ResponseWriter, r *http.Request) {
 	containerName := strings.TrimSpace(parts[0])
 	backupKey := strings.TrimSpace(parts[1])
 
-	slog.Info("backup restore requested via API", "container", containerName, "key", backupKey)
+	// ?config= selects the config whose latest backup is restored, it's no override
+	query := r.URL.Query()
+	configName := query.Get("config")
+	query.Del("config")
+
+	overrides, err := restoreOverrides(query)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(RestoreResponse{
+			Success:   false,
+			Container: containerName,
+			Error:     err.Error(),
+		})
+		return
+	}
+
+	if backupKey == backup.LatestKey {
+		backupKey, err = s.latestResolver(r.Context(), containerName, configName)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(RestoreResponse{
+				Success:   false,
+				Container: containerName,
+				Error:     err.Error(),
+			})
+			return
+		}
+	}
+
+	slog.Info("backup restore requested via API", "container", containerName, "key", backupKey, "overrides", overrides)
 
-	if err := s.backupRestorer(r.Context(), containerName, backupKey); err != nil {
+	if err := s.backupRestorer(r.Context(), containerName, backupKey, overrides); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		_ = json.NewEncoder(w).Encode(RestoreResponse{
 			Success:   false,
@@ -340,3 +704,585 @@ func (s *Server) handleBackupRestore(w http.ResponseWriter, r *http.Request) {
 		Message:   "backup restored successfully",
 	})
 }
+
+// restoreOverrides returns the backup config options overridden by the query,
+// e.g. ?restore-mode=clear. Only the restore mode may be overridden.
+func restoreOverrides(query url.Values) (map[string]string, error) {
+	overrides := make(map[string]string)
+	for name, values := range query {
+		if len(values) > 0 {
+			overrides[name] = values[0]
+		}
+	}
+	return overrides, backup.ValidateRestoreOverrides(overrides)
+}
+
+func (s *Server) handleBackupRestoreURL(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_ = json.NewEncoder(w).Encode(RestoreResponse{
+			Success: false,
+			Error:   "method not allowed, use POST",
+		})
+		return
+	}
+
+	containerName := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/backup/restore-url/"))
+	if containerName == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(RestoreResponse{
+			Success: false,
+			Error:   "container name is required (format: /backup/restore-url/{container})",
+		})
+		return
+	}
+
+	var src backup.RemoteSource
+	if err := json.NewDecoder(r.Body).Decode(&src); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(RestoreResponse{
+			Success:   false,
+			Container: containerName,
+			Error:     fmt.Sprintf("invalid request body: %v", err),
+		})
+		return
+	}
+	if src.URL == "" || src.BackupType == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(RestoreResponse{
+			Success:   false,
+			Container: containerName,
+			Error:     "url and type are required",
+		})
+		return
+	}
+
+	overrides, err := restoreOverrides(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(RestoreResponse{
+			Success:   false,
+			Container: containerName,
+			Error:     err.Error(),
+		})
+		return
+	}
+
+	slog.Info("backup restore from URL requested via API", "container", containerName, "type", src.BackupType, "overrides", overrides)
+
+	if err := s.urlRestorer(r.Context(), containerName, src, overrides); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(RestoreResponse{
+			Success:   false,
+			Container: containerName,
+			Error:     err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(RestoreResponse{
+		Success:   true,
+		Container: containerName,
+		Message:   "backup restored successfully",
+	})
+}
+
+func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_ = json.NewEncoder(w).Encode(JobsResponse{
+			Success: false,
+			Error:   "method not allowed, use GET",
+		})
+		return
+	}
+
+	jobs := []backup.JobStatus{}
+	if s.jobLister != nil {
+		if listed := s.jobLister(); listed != nil {
+			jobs = listed
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(JobsResponse{
+		Success: true,
+		Jobs:    jobs,
+	})
+}
+
+func (s *Server) handleContainers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_ = json.NewEncoder(w).Encode(ContainersResponse{
+			Success: false,
+			Error:   "method not allowed, use GET",
+		})
+		return
+	}
+
+	containers := []backup.ContainerStatus{}
+	if s.containerLister != nil {
+		containers = s.containerLister()
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(ContainersResponse{
+		Success:    true,
+		Containers: containers,
+	})
+}
+
+func (s *Server) handleBackupFailures(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_ = json.NewEncoder(w).Encode(FailuresResponse{
+			Success: false,
+			Error:   "method not allowed, use GET",
+		})
+		return
+	}
+
+	containerName := strings.TrimPrefix(r.URL.Path, "/backup/failures/")
+	containerName = strings.TrimSpace(containerName)
+
+	if containerName == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(FailuresResponse{
+			Success: false,
+			Error:   "container name is required",
+		})
+		return
+	}
+
+	failures, err := s.failureLister(r.Context(), containerName)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(FailuresResponse{
+			Success:   false,
+			Container: containerName,
+			Error:     err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(FailuresResponse{
+		Success:   true,
+		Container: containerName,
+		Failures:  failures,
+	})
+}
+
+func (s *Server) handleRetentionPreview(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_ = json.NewEncoder(w).Encode(RetentionPreviewResponse{
+			Success: false,
+			Error:   "method not allowed, use GET",
+		})
+		return
+	}
+
+	containerName := strings.TrimPrefix(r.URL.Path, "/retention/preview/")
+	containerName = strings.TrimSpace(containerName)
+
+	if containerName == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(RetentionPreviewResponse{
+			Success: false,
+			Error:   "container name is required",
+		})
+		return
+	}
+
+	if s.retentionPreview == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		_ = json.NewEncoder(w).Encode(RetentionPreviewResponse{
+			Success:   false,
+			Container: containerName,
+			Error:     "retention preview is not supported",
+		})
+		return
+	}
+
+	previews, err := s.retentionPreview(r.Context(), containerName)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(RetentionPreviewResponse{
+			Success:   false,
+			Container: containerName,
+			Error:     err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(RetentionPreviewResponse{
+		Success:   true,
+		Container: containerName,
+		Previews:  previews,
+	})
+}
+
+func (s *Server) handleBackupReencrypt(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_ = json.NewEncoder(w).Encode(ReencryptResponse{
+			Success: false,
+			Error:   "method not allowed, use POST",
+		})
+		return
+	}
+
+	containerName := strings.TrimPrefix(r.URL.Path, "/backup/reencrypt/")
+	containerName = strings.TrimSpace(containerName)
+
+	if containerName == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(ReencryptResponse{
+			Success: false,
+			Error:   "container name is required",
+		})
+		return
+	}
+
+	if s.reencrypter == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		_ = json.NewEncoder(w).Encode(ReencryptResponse{
+			Success:   false,
+			Container: containerName,
+			Error:     "reencrypt is not supported",
+		})
+		return
+	}
+
+	slog.Info("reencrypt triggered via API", "container", containerName)
+
+	result, err := s.reencrypter(r.Context(), containerName)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(ReencryptResponse{
+			Success:   false,
+			Container: containerName,
+			Result:    result,
+			Error:     err.Error(),
+		})
+		return
+	}
+
+	// Per-backup failures are reported in the result but still fail the request
+	status := http.StatusOK
+	if len(result.Failed) > 0 {
+		status = http.StatusInternalServerError
+	}
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(ReencryptResponse{
+		Success:   len(result.Failed) == 0,
+		Container: containerName,
+		Result:    result,
+	})
+}
+
+func (s *Server) handleBackupDiff(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_ = json.NewEncoder(w).Encode(DiffResponse{
+			Success: false,
+			Error:   "method not allowed, use GET",
+		})
+		return
+	}
+
+	containerName := strings.TrimPrefix(r.URL.Path, "/backup/diff/")
+	containerName = strings.TrimSpace(containerName)
+	fromKey := r.URL.Query().Get("from")
+	toKey := r.URL.Query().Get("to")
+
+	if containerName == "" || fromKey == "" || toKey == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(DiffResponse{
+			Success:   false,
+			Container: containerName,
+			Error:     "container name, from and to are required",
+		})
+		return
+	}
+
+	if s.backupDiffer == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		_ = json.NewEncoder(w).Encode(DiffResponse{
+			Success:   false,
+			Container: containerName,
+			Error:     "diff is not supported",
+		})
+		return
+	}
+
+	diff, err := s.backupDiffer(r.Context(), containerName, fromKey, toKey)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(DiffResponse{
+			Success:   false,
+			Container: containerName,
+			Error:     err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(DiffResponse{
+		Success:   true,
+		Container: containerName,
+		Diff:      diff,
+	})
+}
+
+func (s *Server) handleBackupExtract(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_ = json.NewEncoder(w).Encode(ExtractResponse{
+			Success: false,
+			Error:   "method not allowed, use POST",
+		})
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/backup/extract/")
+	parts := strings.SplitN(path, "/", 2)
+	dest := r.URL.Query().Get("dest")
+
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" || dest == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(ExtractResponse{
+			Success: false,
+			Error:   "container name, backup key and dest are required (format: /backup/extract/{container}/{key}?dest={path})",
+		})
+		return
+	}
+
+	containerName := strings.TrimSpace(parts[0])
+	backupKey := strings.TrimSpace(parts[1])
+
+	if s.extractor == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		_ = json.NewEncoder(w).Encode(ExtractResponse{
+			Success:   false,
+			Container: containerName,
+			Key:       backupKey,
+			Error:     "extract is not supported",
+		})
+		return
+	}
+
+	slog.Info("backup extract requested via API", "container", containerName, "key", backupKey, "dest", dest)
+
+	if err := s.extractor(r.Context(), containerName, backupKey, dest); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(ExtractResponse{
+			Success:   false,
+			Container: containerName,
+			Key:       backupKey,
+			Dest:      dest,
+			Error:     err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(ExtractResponse{
+		Success:   true,
+		Container: containerName,
+		Key:       backupKey,
+		Dest:      dest,
+		Message:   "backup extracted successfully",
+	})
+}
+
+func (s *Server) handleRestoreTest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_ = json.NewEncoder(w).Encode(RestoreTestResponse{
+			Success: false,
+			Error:   "method not allowed, use POST",
+		})
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/backup/restore-test/")
+	parts := strings.SplitN(path, "/", 2)
+
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(RestoreTestResponse{
+			Success: false,
+			Error:   "container name and backup key are required (format: /backup/restore-test/{container}/{key})",
+		})
+		return
+	}
+
+	containerName := strings.TrimSpace(parts[0])
+	backupKey := strings.TrimSpace(parts[1])
+
+	// The options are optional, an empty body tests with the defaults
+	var opts backup.RestoreTestOptions
+	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil && !errors.Is(err, io.EOF) {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(RestoreTestResponse{
+			Success:   false,
+			Container: containerName,
+			Key:       backupKey,
+			Error:     fmt.Sprintf("invalid request body: %v", err),
+		})
+		return
+	}
+
+	if s.restoreTester == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		_ = json.NewEncoder(w).Encode(RestoreTestResponse{
+			Success:   false,
+			Container: containerName,
+			Key:       backupKey,
+			Error:     "restore tests are not supported",
+		})
+		return
+	}
+
+	slog.Info("restore test requested via API", "container", containerName, "key", backupKey)
+
+	result, err := s.restoreTester(r.Context(), containerName, backupKey, opts)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(RestoreTestResponse{
+			Success:   false,
+			Container: containerName,
+			Key:       backupKey,
+			Error:     err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(RestoreTestResponse{
+		Success:   true,
+		Container: containerName,
+		Key:       result.Key,
+		Result:    result,
+	})
+}
+
+// handleBackupDownload streams the stored backup file as is. Errors before the
+// first byte are answered with a JSON BackupResponse.
+func (s *Server) handleBackupDownload(w http.ResponseWriter, r *http.Request) {
+	fail := func(status int, resp BackupResponse) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+
+	if r.Method != http.MethodGet {
+		fail(http.StatusMethodNotAllowed, BackupResponse{
+			Success: false,
+			Error:   "method not allowed, use GET",
+		})
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/backup/download/")
+	parts := strings.SplitN(path, "/", 2)
+
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		fail(http.StatusBadRequest, BackupResponse{
+			Success: false,
+			Error:   "container name and backup key are required (format: /backup/download/{container}/{key})",
+		})
+		return
+	}
+
+	containerName := strings.TrimSpace(parts[0])
+	backupKey := strings.TrimSpace(parts[1])
+
+	if s.downloader == nil {
+		fail(http.StatusNotImplemented, BackupResponse{
+			Success:   false,
+			Container: containerName,
+			Error:     "download is not supported",
+		})
+		return
+	}
+
+	slog.Info("backup download requested via API", "container", containerName, "key", backupKey)
+
+	reader, err := s.downloader(r.Context(), containerName, backupKey)
+	if err != nil {
+		fail(http.StatusInternalServerError, BackupResponse{
+			Success:   false,
+			Container: containerName,
+			Error:     err.Error(),
+		})
+		return
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	// Large backups take longer than the server's write timeout
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	filename := backupKey[strings.LastIndex(backupKey, "/")+1:]
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := io.Copy(w, reader); err != nil {
+		slog.Error("backup download failed", "container", containerName, "key", backupKey, "error", err)
+	}
+}
+
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_ = json.NewEncoder(w).Encode(ConfigResponse{
+			Success: false,
+			Error:   "method not allowed, use GET",
+		})
+		return
+	}
+
+	if s.configProvider == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		_ = json.NewEncoder(w).Encode(ConfigResponse{
+			Success: false,
+			Error:   "configuration is not available",
+		})
+		return
+	}
+
+	effective := s.configProvider()
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(ConfigResponse{
+		Success: true,
+		Config:  &effective,
+	})
+}
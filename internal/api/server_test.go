@@ -0,0 +1,164 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireToken(t *testing.T) {
+	handler := requireToken("s3cret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for header, want := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"s3cret":        http.StatusUnauthorized,
+		"Basic s3cret":  http.StatusUnauthorized,
+		"Bearer s3cret": http.StatusNoContent,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/jobs", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, want, rec.Code, header)
+	}
+}
+
+func TestListenTCP_RequiresToken(t *testing.T) {
+	s := NewServer("")
+	assert.Error(t, s.ListenTCP(":9000", ""))
+	assert.NoError(t, s.ListenTCP(":9000", "s3cret"))
+}
+
+func TestHandleBackupRun_Config(t *testing.T) {
+	var gotContainer, gotConfig string
+	s := NewServer("")
+	s.SetBackupTrigger(func(_ context.Context, containerName string, configName ...string) error {
+		gotContainer = containerName
+		gotConfig = configName[0]
+		return nil
+	})
+
+	rec := httptest.NewRecorder()
+	s.handleBackupRun(rec, httptest.NewRequest(http.MethodPost, "/backup/run/app?config=hourly", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "app", gotContainer)
+	assert.Equal(t, "hourly", gotConfig)
+
+	rec = httptest.NewRecorder()
+	s.handleBackupRun(rec, httptest.NewRequest(http.MethodPost, "/backup/run/app", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, gotConfig, "all configs run without a config")
+}
+
+func TestHandleRestoreTest(t *testing.T) {
+	var gotKey string
+	var gotOpts backup.RestoreTestOptions
+	s := NewServer("")
+	s.SetRestoreTester(func(_ context.Context, containerName, backupKey string, opts backup.RestoreTestOptions) (*backup.RestoreTestResult, error) {
+		gotKey, gotOpts = backupKey, opts
+		return &backup.RestoreTestResult{Container: containerName, Key: "db/postgres/2024-01-15/030000.tar.zst", Passed: false, Error: "query failed"}, nil
+	})
+
+	body := strings.NewReader(`{"config":"postgres","query":"SELECT count(*) FROM users"}`)
+	rec := httptest.NewRecorder()
+	s.handleRestoreTest(rec, httptest.NewRequest(http.MethodPost, "/backup/restore-test/db/latest", body))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "latest", gotKey)
+	assert.Equal(t, "SELECT count(*) FROM users", gotOpts.Query)
+
+	var resp RestoreTestResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.True(t, resp.Success)
+	assert.Equal(t, "db/postgres/2024-01-15/030000.tar.zst", resp.Key)
+	require.NotNil(t, resp.Result)
+	assert.False(t, resp.Result.Passed)
+
+	// Without a body the defaults are used
+	rec = httptest.NewRecorder()
+	s.handleRestoreTest(rec, httptest.NewRequest(http.MethodPost, "/backup/restore-test/db/latest", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	s.handleRestoreTest(rec, httptest.NewRequest(http.MethodPost, "/backup/restore-test/db", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestHandleBackupRestore_Overrides(t *testing.T) {
+	var gotOverrides map[string]string
+	s := NewServer("")
+	s.SetBackupRestorer(func(_ context.Context, _, _ string, overrides map[string]string) error {
+		gotOverrides = overrides
+		return nil
+	})
+	s.SetURLRestorer(func(_ context.Context, _ string, _ backup.RemoteSource, overrides map[string]string) error {
+		gotOverrides = overrides
+		return nil
+	})
+
+	rec := httptest.NewRecorder()
+	s.handleBackupRestore(rec, httptest.NewRequest(http.MethodPost, "/backup/restore/app/app/data/2026-01-15/030000.tar.zst?restore-mode=clear", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, map[string]string{"restore-mode": "clear"}, gotOverrides)
+
+	// Other options, e.g. the helper image or credentials, can't be overridden
+	for _, query := range []string{"helper-image=evil:latest", "password=guess", "restore-mode=clear&path=/etc"} {
+		gotOverrides = nil
+		rec = httptest.NewRecorder()
+		s.handleBackupRestore(rec, httptest.NewRequest(http.MethodPost, "/backup/restore/app/app/data/2026-01-15/030000.tar.zst?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+		assert.Nil(t, gotOverrides, "the restore didn't run")
+
+		rec = httptest.NewRecorder()
+		body := strings.NewReader(`{"url":"https://example.com/backup.tar.zst","type":"volume"}`)
+		s.handleBackupRestoreURL(rec, httptest.NewRequest(http.MethodPost, "/backup/restore-url/app?"+query, body))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+		assert.Nil(t, gotOverrides, "the restore didn't run")
+	}
+}
+
+func TestHandleBackupRestore_Latest(t *testing.T) {
+	var gotKey, gotConfig string
+	var gotOverrides map[string]string
+	s := NewServer("")
+	s.SetBackupRestorer(func(_ context.Context, _, backupKey string, overrides map[string]string) error {
+		gotKey, gotOverrides = backupKey, overrides
+		return nil
+	})
+	s.SetLatestKeyResolver(func(_ context.Context, containerName, configName string) (string, error) {
+		gotConfig = configName
+		if configName == "missing" {
+			return "", errors.New("backup config \"missing\" not found")
+		}
+		return containerName + "/data/2026-01-15/030000.tar.zst", nil
+	})
+
+	rec := httptest.NewRecorder()
+	s.handleBackupRestore(rec, httptest.NewRequest(http.MethodPost, "/backup/restore/app/latest?config=data&restore-mode=clear", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "data", gotConfig)
+	assert.Equal(t, "app/data/2026-01-15/030000.tar.zst", gotKey)
+	assert.Equal(t, map[string]string{"restore-mode": "clear"}, gotOverrides, "config is no override")
+
+	var resp RestoreResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, "app/data/2026-01-15/030000.tar.zst", resp.Key)
+
+	gotKey = ""
+	rec = httptest.NewRecorder()
+	s.handleBackupRestore(rec, httptest.NewRequest(http.MethodPost, "/backup/restore/app/latest?config=missing", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Empty(t, gotKey, "the restore didn't run")
+}
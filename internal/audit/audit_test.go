@@ -0,0 +1,71 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeRecords(t *testing.T, path string, keys ...string) {
+	t.Helper()
+	log, err := Open(path)
+	require.NoError(t, err)
+	for _, key := range keys {
+		require.NoError(t, log.Write(Record{Operation: OperationBackup, Result: ResultSuccess, Container: "app", Key: key}))
+	}
+	require.NoError(t, log.Close())
+}
+
+func TestLog_Chain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	writeRecords(t, path, "app/db/1", "app/db/2")
+	// Reopening continues the chain
+	writeRecords(t, path, "app/db/3")
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	n, err := Verify(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	lines := strings.SplitAfter(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 3)
+
+	// A changed record
+	changed := strings.Replace(string(data), "app/db/2", "app/db/9", 1)
+	n, err = Verify(strings.NewReader(changed))
+	assert.ErrorContains(t, err, "line 2: hash mismatch")
+	assert.Equal(t, 1, n)
+
+	// A removed record
+	n, err = Verify(strings.NewReader(lines[0] + lines[2]))
+	assert.ErrorContains(t, err, "line 2: chain broken")
+	assert.Equal(t, 1, n)
+}
+
+func TestLog_Nil(t *testing.T) {
+	var log *Log
+	assert.NoError(t, log.Write(Record{Operation: OperationDelete}))
+	assert.NoError(t, log.Close())
+}
+
+func TestOpen_InvalidLastRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte("not json\n"), 0600))
+
+	_, err := Open(path)
+	assert.ErrorContains(t, err, "invalid last record")
+}
+
+func TestActorFrom(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, ActorScheduler, ActorFrom(ctx, ActorScheduler))
+	assert.Equal(t, "dashboard:alice", ActorFrom(WithActor(ctx, "dashboard:alice"), ActorScheduler))
+}
@@ -0,0 +1,99 @@
+package backup
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/shyim/docker-backup/internal/audit"
+	"github.com/shyim/docker-backup/internal/notification"
+	"github.com/shyim/docker-backup/internal/storage"
+)
+
+// SetAuditLog records every backup, restore and delete in log, including the
+// deletes of retention. It must be called before the manager is started.
+func (m *Manager) SetAuditLog(log *audit.Log) {
+	m.audit = log
+	if m.retention != nil {
+		m.retention.SetOnDelete(m.auditRetentionDelete)
+	}
+}
+
+// auditRecord writes rec to the audit log. A record that can't be written
+// doesn't fail the operation, it already happened.
+func (m *Manager) auditRecord(rec audit.Record) {
+	if err := m.audit.Write(rec); err != nil {
+		slog.Error("failed to write audit log",
+			"operation", rec.Operation,
+			"container", rec.Container,
+			"key", rec.Key,
+			"error", err,
+		)
+	}
+}
+
+// auditBackup records a finished backup run. Runs nobody triggered are
+// recorded as done by the scheduler.
+func (m *Manager) auditBackup(ctx context.Context, configName string, event notification.Event, secrets []string) {
+	rec := audit.Record{
+		Time:      event.Timestamp,
+		Operation: audit.OperationBackup,
+		Result:    audit.ResultSuccess,
+		Actor:     audit.ActorFrom(ctx, audit.ActorScheduler),
+		Container: event.ContainerName,
+		Config:    configName,
+		Key:       event.BackupKey,
+		Size:      event.Size,
+		Duration:  event.Duration.Seconds(),
+	}
+	if event.Error != nil {
+		rec.Result = audit.ResultFailure
+		rec.Error = maskSecrets(event.Error.Error(), secrets)
+	} else {
+		rec.Storage = m.poolManager.PoolName(event.Storage)
+	}
+	m.auditRecord(rec)
+}
+
+// auditRetentionDelete records a backup deleted by retention
+func (m *Manager) auditRetentionDelete(pool string, file storage.BackupFile) {
+	container, config := m.keyNames(file.Key)
+
+	m.auditRecord(audit.Record{
+		Operation: audit.OperationDelete,
+		Result:    audit.ResultSuccess,
+		Actor:     audit.ActorRetention,
+		Container: container,
+		Config:    config,
+		Key:       file.Key,
+		Storage:   m.poolManager.PoolName(pool),
+		Size:      file.Size,
+	})
+}
+
+// keyNames returns the container and config name of a backup key. Keys of
+// tracked containers are matched against their configs, which accounts for
+// path prefixes; other keys are read as container/config/...
+func (m *Manager) keyNames(key string) (container, config string) {
+	m.mu.RLock()
+	for _, cfg := range m.containers {
+		backup := backupConfigForKey(cfg, key)
+		if backup != nil && strings.HasPrefix(key, backup.KeyPrefix()+cfg.ContainerName+"/") {
+			m.mu.RUnlock()
+			return cfg.ContainerName, backup.Name
+		}
+	}
+	m.mu.RUnlock()
+
+	container, config, _ = strings.Cut(key, "/")
+	config, _, _ = strings.Cut(config, "/")
+	return container, config
+}
+
+// auditResult returns the recorded result and error message of an operation
+func auditResult(err error) (string, string) {
+	if err != nil {
+		return audit.ResultFailure, err.Error()
+	}
+	return audit.ResultSuccess, ""
+}
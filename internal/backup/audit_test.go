@@ -0,0 +1,94 @@
+package backup
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/audit"
+	"github.com/shyim/docker-backup/internal/config"
+	"github.com/shyim/docker-backup/internal/retention"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readAuditLog(t *testing.T, path string) []audit.Record {
+	t.Helper()
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var records []audit.Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var rec audit.Record
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &rec))
+		records = append(records, rec)
+	}
+	require.NoError(t, scanner.Err())
+	return records
+}
+
+func TestRunBackup_Audit(t *testing.T) {
+	pm := newFailoverManager(t).poolManager
+	m := NewManager(newFakeDocker(t), pm, nil, retention.New(pm), nil, nil, nil, config.New())
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	log, err := audit.Open(path)
+	require.NoError(t, err)
+	defer func() {
+		_ = log.Close()
+	}()
+	m.SetAuditLog(log)
+
+	cfg := &config.ContainerConfig{ContainerName: "app"}
+	backup := config.BackupConfig{Name: "db", BackupType: "split", Storage: "s3", Retention: 1, Options: map[string]string{OptionSplit: "true"}}
+
+	s3 := memPools["s3"]
+	s3.modified = map[string]time.Time{"app/db/shop/2026-01-01/030000.tar.zst": time.Now().Add(-time.Hour)}
+	s3.objects["app/db/shop/2026-01-01/030000.tar.zst"] = []byte("old")
+
+	ctx := audit.WithActor(context.Background(), "api:socket")
+	m.runBackup(ctx, "app", cfg, backup, &splitBackup{parts: []string{"shop"}})
+
+	// A scheduled run that fails
+	failing := &blockingBackup{release: make(chan struct{})}
+	close(failing.release)
+	m.runBackup(context.Background(), "app", cfg, config.BackupConfig{Name: "files", BackupType: "blocking", Storage: "s3"}, failing)
+
+	records := readAuditLog(t, path)
+	require.Len(t, records, 3)
+
+	assert.Equal(t, audit.OperationBackup, records[0].Operation)
+	assert.Equal(t, audit.ResultSuccess, records[0].Result)
+	assert.Equal(t, "api:socket", records[0].Actor)
+	assert.Equal(t, "app", records[0].Container)
+	assert.Equal(t, "db", records[0].Config)
+	assert.Regexp(t, `^app/db/shop/`, records[0].Key)
+	assert.Equal(t, "s3", records[0].Storage)
+	assert.Equal(t, int64(len("shop")), records[0].Size)
+
+	assert.Equal(t, audit.OperationDelete, records[1].Operation)
+	assert.Equal(t, audit.ActorRetention, records[1].Actor)
+	assert.Equal(t, "db", records[1].Config)
+	assert.Equal(t, "app/db/shop/2026-01-01/030000.tar.zst", records[1].Key)
+
+	assert.Equal(t, audit.ResultFailure, records[2].Result)
+	assert.Equal(t, audit.ActorScheduler, records[2].Actor)
+	assert.Equal(t, "released", records[2].Error)
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer func() {
+		_ = file.Close()
+	}()
+	n, err := audit.Verify(file)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+}
@@ -8,10 +8,97 @@ import (
 )
 
 // BackupType defines the interface for different backup implementations.
+// opts carries the type-specific options of the backup config being run.
+// FileExtension depends on them since the compression option changes it.
 type BackupType interface {
 	Name() string
-	FileExtension() string
-	Backup(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, w io.Writer) error
-	Restore(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, r io.Reader) error
-	Validate(container *docker.ContainerInfo) error
+	FileExtension(opts Options) string
+	Backup(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts Options, w io.Writer) error
+	Restore(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts Options, r io.Reader) error
+	Validate(container *docker.ContainerInfo, opts Options) error
+}
+
+// ArchiveEntry is one file inside a backup archive
+type ArchiveEntry struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// Lister is implemented by backup types whose archives can be listed without
+// restoring them. fn is called once per entry in archive order.
+type Lister interface {
+	ListArchive(ctx context.Context, r io.Reader, fn func(ArchiveEntry) error) error
+}
+
+// Extractor is implemented by backup types whose archives can be written to a
+// directory on the daemon's filesystem instead of being restored into a
+// container. dest must exist; entries never leave it.
+type Extractor interface {
+	Extract(ctx context.Context, r io.Reader, dest string) error
+}
+
+// TreeArchiver is implemented by backup types whose archives can be stored
+// unpacked, e.g. by local pools with format=tree
+type TreeArchiver interface {
+	// TreeArchive reports whether the archive written with opts is a
+	// zstd-compressed tar of the backed up files
+	TreeArchive(opts Options) bool
+}
+
+// Splitter is implemented by backup types that can store each part of a
+// backup, e.g. each database, as a backup of its own. With the split option
+// the manager stores every part below <container>/<config>/<part>/, so a part
+// can be restored on its own and retention applies to each part separately.
+type Splitter interface {
+	// Parts returns the names of the parts to back up
+	Parts(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts Options) ([]string, error)
+	// BackupPart writes an archive holding only the named part, which Restore reads like any other
+	BackupPart(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts Options, part string, w io.Writer) error
+}
+
+// Querier is implemented by backup types of database servers. Restore tests
+// use it to wait for the server in a fresh container and to check the data
+// restored into it.
+type Querier interface {
+	// Ping returns nil once the server accepts connections from outside its
+	// container, i.e. it finished any initialization it runs on first start
+	Ping(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts Options) error
+	// Query runs query with the client of the server and returns its output
+	Query(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts Options, query string) (string, error)
+}
+
+// Deduplicator is implemented by backup types that can store files once as
+// content-addressed blobs shared by all backups of a config instead of in
+// every archive. With the dedup option the manager passes Backup a BlobStore
+// through the context, see BlobStoreFromContext; restores always get one.
+type Deduplicator interface {
+	// BlobRefs calls fn with the digest of every blob the archive references,
+	// so blobs no backup references anymore can be pruned
+	BlobRefs(ctx context.Context, r io.Reader, fn func(digest string) error) error
+}
+
+// BlobStore holds the blobs of a deduplicated backup config, keyed by the
+// lowercase hex SHA-256 of their content
+type BlobStore interface {
+	// Has reports whether the blob was stored by an earlier backup or this one
+	Has(digest string) bool
+	// Put stores the content of a blob read from r
+	Put(ctx context.Context, digest string, r io.Reader) error
+	// Get returns the content of a blob
+	Get(ctx context.Context, digest string) (io.ReadCloser, error)
+}
+
+type tempDirKey struct{}
+
+// WithTempDir returns a context carrying the directory backup types spool
+// temporary files to, i.e. the daemon's --temp-dir
+func WithTempDir(ctx context.Context, dir string) context.Context {
+	return context.WithValue(ctx, tempDirKey{}, dir)
+}
+
+// TempDirFromContext returns the temp dir carried by ctx, or "" for the
+// system temp dir, so it can be passed to os.CreateTemp as is
+func TempDirFromContext(ctx context.Context) string {
+	dir, _ := ctx.Value(tempDirKey{}).(string)
+	return dir
 }
@@ -0,0 +1,288 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/shyim/docker-backup/internal/compression"
+	"github.com/shyim/docker-backup/internal/config"
+	"github.com/shyim/docker-backup/internal/storage"
+)
+
+// blobsDir is the top-level directory of deduplicated blobs in a pool. Docker
+// container names start with a letter or digit, so it never clashes with one.
+const blobsDir = ".blobs"
+
+type blobStoreKey struct{}
+
+// WithBlobStore returns a context carrying bs
+func WithBlobStore(ctx context.Context, bs BlobStore) context.Context {
+	return context.WithValue(ctx, blobStoreKey{}, bs)
+}
+
+// BlobStoreFromContext returns the blob store carried by ctx, or nil
+func BlobStoreFromContext(ctx context.Context) BlobStore {
+	bs, _ := ctx.Value(blobStoreKey{}).(BlobStore)
+	return bs
+}
+
+// configDeduplicator returns the config's backup type as a Deduplicator when
+// the dedup option is set, or nil when it isn't. Blobs are shared between the
+// backups of one pool, so configs with fallback or mirror pools fail.
+func configDeduplicator(b config.BackupConfig, backupType BackupType) (Deduplicator, error) {
+	dedup, err := Options(b.Options).Deduplicator(backupType)
+	if err != nil || dedup == nil {
+		return nil, err
+	}
+	if len(b.Fallback) > 0 || len(b.Mirrors) > 0 {
+		return nil, fmt.Errorf("the %s option doesn't support fallback or mirror pools", OptionDedup)
+	}
+	return dedup, nil
+}
+
+// blobPrefix returns the key prefix the blobs of a backup config are stored below
+func blobPrefix(cfg *config.ContainerConfig, backup config.BackupConfig) string {
+	return blobsDir + "/" + configKeyPrefix(cfg.ContainerName, backup)
+}
+
+// poolBlobStore stores blobs below a prefix of a storage pool, compressed
+// with the config's codec and encrypted like backups
+type poolBlobStore struct {
+	m      *Manager
+	store  storage.Storage
+	prefix string
+	codec  compression.Codec
+
+	mu    sync.Mutex
+	known map[string]bool
+}
+
+func (m *Manager) newBlobStore(store storage.Storage, prefix string, codec compression.Codec) *poolBlobStore {
+	return &poolBlobStore{m: m, store: store, prefix: prefix, codec: codec, known: make(map[string]bool)}
+}
+
+// load lists the blobs already stored, so Has reports them
+func (b *poolBlobStore) load(ctx context.Context) error {
+	files, err := b.store.List(ctx, b.prefix)
+	if err != nil {
+		return fmt.Errorf("failed to list blobs: %w", err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, f := range files {
+		b.known[path.Base(f.Key)] = true
+	}
+	return nil
+}
+
+// key returns the key of a blob, fanned out by the first byte of its digest
+func (b *poolBlobStore) key(digest string) string {
+	return b.prefix + digest[:2] + "/" + digest
+}
+
+func (b *poolBlobStore) Has(digest string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.known[digest]
+}
+
+func (b *poolBlobStore) Put(ctx context.Context, digest string, r io.Reader) error {
+	if !validDigest(digest) {
+		return fmt.Errorf("invalid blob digest %q", digest)
+	}
+
+	// The blob is written to a file first so its size is known and storage
+	// retries can read it again
+	tmp, err := os.CreateTemp(b.m.config.TempDir, "docker-backup-blob-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	err = b.m.writeBackup(tmp, func(w io.Writer) error {
+		compressor, err := b.codec.NewWriter(w)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(compressor, r); err != nil {
+			_ = compressor.Close()
+			return err
+		}
+		return compressor.Close()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write blob: %w", err)
+	}
+
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("failed to read temp file: %w", err)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read temp file: %w", err)
+	}
+
+	if err := storage.Store(ctx, b.store, b.key(digest), b.m.uploadLimit.Reader(ctx, tmp), storage.StoreOptions{Size: size}); err != nil {
+		return fmt.Errorf("failed to store blob: %w", err)
+	}
+
+	b.mu.Lock()
+	b.known[digest] = true
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *poolBlobStore) Get(ctx context.Context, digest string) (io.ReadCloser, error) {
+	if !validDigest(digest) {
+		return nil, fmt.Errorf("invalid blob digest %q", digest)
+	}
+
+	stored, err := b.store.Get(ctx, b.key(digest))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blob %s: %w", digest, err)
+	}
+	plain, err := b.m.openArchive(stored)
+	if err != nil {
+		_ = stored.Close()
+		return nil, fmt.Errorf("failed to open blob %s: %w", digest, err)
+	}
+	decompressor, err := compression.NewReader(plain)
+	if err != nil {
+		_ = stored.Close()
+		return nil, fmt.Errorf("failed to open blob %s: %w", digest, err)
+	}
+	return &blobReader{Reader: decompressor, closers: []io.Closer{decompressor, stored}}, nil
+}
+
+// blobReader closes the decompressor and the stored object together
+type blobReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *blobReader) Close() error {
+	var firstErr error
+	for _, c := range r.closers {
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// validDigest reports whether digest is a lowercase hex SHA-256
+func validDigest(digest string) bool {
+	if len(digest) != 64 {
+		return false
+	}
+	for _, c := range digest {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// backupBlobStore returns the blob store a deduplicated backup of the config
+// writes to, knowing the blobs stored by earlier backups
+func (m *Manager) backupBlobStore(ctx context.Context, cfg *config.ContainerConfig, backup config.BackupConfig) (*poolBlobStore, error) {
+	store, err := m.poolManager.GetForContainer(backup.Storage)
+	if err != nil {
+		return nil, err
+	}
+	codec, err := Options(backup.Options).Compression()
+	if err != nil {
+		return nil, err
+	}
+	blobs := m.newBlobStore(store, blobPrefix(cfg, backup), codec)
+	if err := blobs.load(ctx); err != nil {
+		return nil, err
+	}
+	return blobs, nil
+}
+
+// restoreBlobStore returns ctx carrying a blob store for reading a backup of
+// backupCfg. Backup types that don't deduplicate get ctx unchanged. The store
+// is provided even without the dedup option, which may have been turned off
+// after the backup was taken; archives without blob references never use it.
+func (m *Manager) restoreBlobStore(ctx context.Context, cfg *config.ContainerConfig, backupCfg config.BackupConfig, backupType BackupType) context.Context {
+	if _, ok := backupType.(Deduplicator); !ok {
+		return ctx
+	}
+	store, err := m.poolManager.GetForContainer(backupCfg.Storage)
+	if err != nil {
+		return ctx
+	}
+	return WithBlobStore(ctx, m.newBlobStore(store, blobPrefix(cfg, backupCfg), compression.Default))
+}
+
+// pruneBlobs deletes the blobs of a deduplicated backup config that none of
+// its remaining backups reference. Every backup is read for its references,
+// so the prune is skipped when one of them can't be read.
+func (m *Manager) pruneBlobs(ctx context.Context, cfg *config.ContainerConfig, backup config.BackupConfig, dedup Deduplicator) (int, error) {
+	store, err := m.poolManager.GetForContainer(backup.Storage)
+	if err != nil {
+		return 0, err
+	}
+
+	files, err := store.List(ctx, configKeyPrefix(cfg.ContainerName, backup))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	referenced := make(map[string]bool)
+	for _, f := range storage.WithoutChecksums(files) {
+		if err := m.blobRefs(ctx, store, dedup, f.Key, referenced); err != nil {
+			return 0, fmt.Errorf("failed to read blob references of %s: %w", f.Key, err)
+		}
+	}
+
+	blobs, err := store.List(ctx, blobPrefix(cfg, backup))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list blobs: %w", err)
+	}
+
+	deleted := 0
+	for _, blob := range blobs {
+		if referenced[path.Base(blob.Key)] {
+			continue
+		}
+		if m.config.RetentionDryRun {
+			slog.Info("retention dry run: would delete unreferenced blob", "key", blob.Key)
+			continue
+		}
+		if err := store.Delete(ctx, blob.Key); err != nil {
+			return deleted, fmt.Errorf("failed to delete blob %s: %w", blob.Key, err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// blobRefs adds the blobs referenced by the backup stored at key to refs
+func (m *Manager) blobRefs(ctx context.Context, store storage.Storage, dedup Deduplicator, key string, refs map[string]bool) error {
+	reader, err := store.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	archive, err := m.openArchive(reader)
+	if err != nil {
+		return err
+	}
+	return dedup.BlobRefs(ctx, archive, func(digest string) error {
+		refs[digest] = true
+		return nil
+	})
+}
@@ -0,0 +1,120 @@
+package backup
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/shyim/docker-backup/internal/compression"
+	"github.com/shyim/docker-backup/internal/config"
+	"github.com/shyim/docker-backup/internal/encryption"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dedupBackupType writes archives listing one blob digest per line
+type dedupBackupType struct {
+	checkBackupType
+}
+
+func (dedupBackupType) BlobRefs(_ context.Context, r io.Reader, fn func(digest string) error) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if err := fn(scanner.Text()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func digestOf(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestPoolBlobStore(t *testing.T) {
+	k, err := encryption.ParseKeyring("a="+newTestKey(t), "")
+	require.NoError(t, err)
+	store := &memStorage{objects: map[string][]byte{}}
+	m := &Manager{keyring: k, config: &config.Config{TempDir: t.TempDir()}}
+	ctx := context.Background()
+
+	blobs := m.newBlobStore(store, ".blobs/app/data/", compression.Zstd)
+	digest := digestOf("large file")
+	assert.False(t, blobs.Has(digest))
+
+	require.NoError(t, blobs.Put(ctx, digest, strings.NewReader("large file")))
+	assert.True(t, blobs.Has(digest))
+
+	stored, ok := store.objects[".blobs/app/data/"+digest[:2]+"/"+digest]
+	require.True(t, ok)
+	assert.NotContains(t, string(stored), "large file", "blobs are encrypted like backups")
+
+	r, err := blobs.Get(ctx, digest)
+	require.NoError(t, err)
+	got, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, "large file", string(got))
+
+	// A new run knows the blobs stored by earlier ones
+	next := m.newBlobStore(store, ".blobs/app/data/", compression.Zstd)
+	require.NoError(t, next.load(ctx))
+	assert.True(t, next.Has(digest))
+
+	assert.ErrorContains(t, blobs.Put(ctx, "../../app/data/x", strings.NewReader("")), "invalid blob digest")
+}
+
+func TestPruneBlobs(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.ContainerConfig{ContainerName: "app"}
+	backup := config.BackupConfig{Name: "data", Storage: "s3"}
+	kept, expired := digestOf("kept"), digestOf("expired")
+
+	for _, dryRun := range []bool{true, false} {
+		m := newFailoverManager(t)
+		m.config = &config.Config{RetentionDryRun: dryRun}
+		store := memPools["s3"]
+		store.objects["app/data/2026-01-02/000000.tar.zst"] = []byte(kept + "\n")
+		store.objects["app/data/2026-01-02/000000.tar.zst.sha256"] = []byte("checksum")
+		store.objects[".blobs/app/data/"+kept[:2]+"/"+kept] = []byte("kept")
+		store.objects[".blobs/app/data/"+expired[:2]+"/"+expired] = []byte("expired")
+		store.objects[".blobs/app/other/"+expired[:2]+"/"+expired] = []byte("other config")
+
+		deleted, err := m.pruneBlobs(ctx, cfg, backup, dedupBackupType{})
+		require.NoError(t, err)
+
+		assert.Contains(t, store.objects, ".blobs/app/data/"+kept[:2]+"/"+kept)
+		assert.Contains(t, store.objects, ".blobs/app/other/"+expired[:2]+"/"+expired)
+		if dryRun {
+			assert.Equal(t, 0, deleted)
+			assert.Contains(t, store.objects, ".blobs/app/data/"+expired[:2]+"/"+expired)
+		} else {
+			assert.Equal(t, 1, deleted)
+			assert.NotContains(t, store.objects, ".blobs/app/data/"+expired[:2]+"/"+expired)
+		}
+	}
+}
+
+func TestConfigDeduplicator(t *testing.T) {
+	b := config.BackupConfig{Name: "data", Options: map[string]string{OptionDedup: "true"}}
+
+	dedup, err := configDeduplicator(b, dedupBackupType{})
+	require.NoError(t, err)
+	assert.NotNil(t, dedup)
+
+	_, err = configDeduplicator(b, checkBackupType{})
+	assert.ErrorContains(t, err, "doesn't support the dedup option")
+
+	b.Mirrors = []string{"offsite"}
+	_, err = configDeduplicator(b, dedupBackupType{})
+	assert.ErrorContains(t, err, "doesn't support fallback or mirror pools")
+
+	dedup, err = configDeduplicator(config.BackupConfig{Name: "data"}, dedupBackupType{})
+	require.NoError(t, err)
+	assert.Nil(t, dedup)
+}
@@ -0,0 +1,88 @@
+package backup
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/config"
+)
+
+// missedBackup is a backup config whose scheduled run was missed
+type missedBackup struct {
+	containerID string
+	cfg         *config.ContainerConfig
+	backup      config.BackupConfig
+}
+
+// runMissedBackups backs up, one after another, every tracked config whose
+// schedule was due since its newest backup, e.g. because the daemon was down
+// during the scheduled window. Configs without any backup count as missed.
+func (m *Manager) runMissedBackups(ctx context.Context) {
+	missed := m.missedBackups(ctx, time.Now())
+	if len(missed) == 0 {
+		slog.Info("no missed backups to catch up on")
+		return
+	}
+
+	slog.Info("catching up on missed backups", "configs", len(missed))
+	for _, b := range missed {
+		if ctx.Err() != nil {
+			return
+		}
+		backupType, ok := Get(b.backup.BackupType)
+		if !ok {
+			continue
+		}
+		m.runBackup(ctx, b.containerID, b.cfg, b.backup, backupType)
+	}
+}
+
+// missedBackups returns the tracked configs whose schedule was due between
+// their newest backup and now, in container and config order
+func (m *Manager) missedBackups(ctx context.Context, now time.Time) []missedBackup {
+	m.mu.RLock()
+	containers := make(map[string]*config.ContainerConfig, len(m.containers))
+	for id, cfg := range m.containers {
+		containers[id] = cfg
+	}
+	m.mu.RUnlock()
+
+	var missed []missedBackup
+	for containerID, cfg := range containers {
+		for _, backup := range cfg.Backups {
+			newest, ok, err := m.newestBackupOf(ctx, cfg.ContainerName, backup)
+			if err != nil {
+				slog.Warn("failed to check for a missed backup",
+					"container", cfg.ContainerName,
+					"config", backup.Name,
+					"error", err,
+				)
+				continue
+			}
+
+			if ok {
+				due, err := m.scheduler.MissedRun(backup.CronSchedule(), newest.LastModified, now)
+				if err != nil || !due {
+					continue
+				}
+				slog.Info("scheduled backup was missed",
+					"container", cfg.ContainerName,
+					"config", backup.Name,
+					"last_backup", newest.LastModified,
+				)
+			}
+
+			missed = append(missed, missedBackup{containerID: containerID, cfg: cfg, backup: backup})
+		}
+	}
+
+	sort.Slice(missed, func(i, j int) bool {
+		if missed[i].cfg.ContainerName != missed[j].cfg.ContainerName {
+			return missed[i].cfg.ContainerName < missed[j].cfg.ContainerName
+		}
+		return missed[i].backup.Name < missed[j].backup.Name
+	})
+	return missed
+}
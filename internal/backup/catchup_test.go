@@ -0,0 +1,41 @@
+package backup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/config"
+	"github.com/shyim/docker-backup/internal/scheduler"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMissedBackups(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	m := newFailoverManager(t)
+	m.scheduler = scheduler.NewInLocation(context.Background(), time.UTC)
+	memPools["s3"].objects["app/db/2026-03-10/030000.sql.zst"] = []byte("fresh")
+	memPools["s3"].objects["app/files/2026-03-08/040000.tar.zst"] = []byte("stale")
+	memPools["s3"].objects["app/files/2026-03-08/040000.tar.zst.sha256"] = []byte("sum")
+	memPools["s3"].modified = map[string]time.Time{
+		"app/db/2026-03-10/030000.sql.zst":           time.Date(2026, 3, 10, 3, 0, 20, 0, time.UTC),
+		"app/files/2026-03-08/040000.tar.zst":        time.Date(2026, 3, 8, 4, 1, 0, 0, time.UTC),
+		"app/files/2026-03-08/040000.tar.zst.sha256": time.Date(2026, 3, 11, 0, 0, 0, 0, time.UTC),
+	}
+
+	m.containers = map[string]*config.ContainerConfig{
+		"abc": {ContainerName: "app", Backups: []config.BackupConfig{
+			{Name: "db", BackupType: "postgres", Schedule: "0 3 * * *", Storage: "s3"},
+			{Name: "files", BackupType: "volume", Schedule: "0 4 * * *", Storage: "s3"},
+			{Name: "logs", BackupType: "logs", Schedule: "0 5 * * *", Storage: "s3"},
+		}},
+	}
+
+	var names []string
+	for _, b := range m.missedBackups(context.Background(), now) {
+		assert.Equal(t, "abc", b.containerID)
+		names = append(names, b.backup.Name)
+	}
+	assert.Equal(t, []string{"files", "logs"}, names, "stale and never backed up configs are missed, checksum sidecars don't count")
+}
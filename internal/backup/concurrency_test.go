@@ -0,0 +1,129 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/config"
+	"github.com/shyim/docker-backup/internal/docker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDocker is the state of the containers a fake Docker API serves. They
+// run between a start and a stop request.
+type fakeDocker struct {
+	mu      sync.Mutex
+	running bool
+	calls   []string
+	labels  map[string]map[string]string // Labels by container ID
+}
+
+// newFakeDocker serves the parts of the Docker API runBackup needs. Every
+// container is reported as running, unless state is passed to track it.
+func newFakeDocker(t *testing.T, state ...*fakeDocker) *docker.MultiClient {
+	t.Helper()
+	fake := &fakeDocker{running: true}
+	if len(state) > 0 {
+		fake = state[0]
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Api-Version", "1.45")
+		parts := strings.Split(r.URL.Path, "/")
+		action := parts[len(parts)-1]
+
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		switch action {
+		case "start", "stop":
+			fake.running = action == "start"
+			fake.calls = append(fake.calls, action)
+			w.WriteHeader(http.StatusNoContent)
+		case "json":
+			id := parts[len(parts)-2]
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"Id":     id,
+				"Name":   "/" + id,
+				"Config": map[string]any{"Labels": fake.labels[id]},
+				"State":  map[string]any{"Running": fake.running},
+			})
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := docker.NewClient("tcp://" + server.Listener.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	return docker.SingleClient(client)
+}
+
+// blockingBackup counts running backups until release is closed, then fails
+// so the run ends without storing anything
+type blockingBackup struct {
+	running atomic.Int32
+	peak    atomic.Int32
+	release chan struct{}
+}
+
+func (b *blockingBackup) Name() string                                  { return "blocking" }
+func (b *blockingBackup) FileExtension(opts Options) string             { return ".bin" }
+func (b *blockingBackup) Validate(*docker.ContainerInfo, Options) error { return nil }
+func (b *blockingBackup) Restore(context.Context, *docker.ContainerInfo, *docker.Client, Options, io.Reader) error {
+	return nil
+}
+
+func (b *blockingBackup) Backup(ctx context.Context, _ *docker.ContainerInfo, _ *docker.Client, _ Options, _ io.Writer) error {
+	running := b.running.Add(1)
+	defer b.running.Add(-1)
+	for {
+		peak := b.peak.Load()
+		if running <= peak || b.peak.CompareAndSwap(peak, running) {
+			break
+		}
+	}
+	<-b.release
+	return errors.New("released")
+}
+
+func TestRunBackup_MaxConcurrentBackups(t *testing.T) {
+	cfg := config.New()
+	cfg.MaxConcurrentBackups = 2
+
+	m := NewManager(newFakeDocker(t), newFailoverManager(t).poolManager, nil, nil, nil, nil, nil, cfg)
+	backupType := &blockingBackup{release: make(chan struct{})}
+
+	var wg sync.WaitGroup
+	for _, name := range []string{"app1", "app2", "app3", "app4"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			containerCfg := &config.ContainerConfig{ContainerName: name}
+			m.runBackup(context.Background(), name, containerCfg, config.BackupConfig{Name: "data", BackupType: "blocking"}, backupType)
+		}()
+	}
+
+	require.Eventually(t, func() bool { return backupType.running.Load() == 2 }, 5*time.Second, time.Millisecond)
+	// The other two backups stay queued while both slots are taken
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(2), backupType.running.Load())
+
+	close(backupType.release)
+	wg.Wait()
+
+	assert.Equal(t, int32(2), backupType.peak.Load(), "no more than two backups ran at once")
+	for _, name := range []string{"app1", "app2", "app3", "app4"} {
+		failures := m.jobs.failureHistory(m.makeJobKey(name, "data"))
+		require.Len(t, failures, 1, "%s ran once a slot was free", name)
+		assert.Equal(t, FailureBackup, failures[0].Category)
+	}
+}
@@ -0,0 +1,167 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+)
+
+const (
+	// maxDiffEntries bounds the entries of the older backup held in memory while diffing
+	maxDiffEntries = 500000
+	// maxDiffResults bounds the added, removed and changed entries returned by a diff
+	maxDiffResults = 10000
+)
+
+// BackupDiff is the difference between two backups of the same config
+type BackupDiff struct {
+	From    string         `json:"from"`
+	To      string         `json:"to"`
+	Added   []ArchiveEntry `json:"added"`
+	Removed []ArchiveEntry `json:"removed"`
+	Changed []ChangedEntry `json:"changed"`
+	// Unchanged counts entries present in both backups with the same size
+	Unchanged int `json:"unchanged"`
+	// Truncated is set when more than maxDiffResults differences were found
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// ChangedEntry is an entry present in both backups with a different size
+type ChangedEntry struct {
+	Name     string `json:"name"`
+	FromSize int64  `json:"from_size"`
+	ToSize   int64  `json:"to_size"`
+}
+
+// DiffBackups compares two backups of the same backup config by their archive
+// listings: files for volume backups, databases and dump sizes for database
+// backups. Archives are streamed and never extracted.
+func (m *Manager) DiffBackups(ctx context.Context, containerName, fromKey, toKey string) (*BackupDiff, error) {
+	cfg, _, err := m.findContainerConfig(ctx, containerName)
+	if err != nil {
+		return nil, err
+	}
+
+	fromCfg := backupConfigForKey(cfg, fromKey)
+	if fromCfg == nil {
+		return nil, fmt.Errorf("no backup configuration found for %s", fromKey)
+	}
+	if toCfg := backupConfigForKey(cfg, toKey); toCfg != fromCfg {
+		return nil, fmt.Errorf("backups %s and %s do not belong to the same backup configuration", fromKey, toKey)
+	}
+
+	backupType, ok := Get(fromCfg.BackupType)
+	if !ok {
+		return nil, fmt.Errorf("unknown backup type %q", fromCfg.BackupType)
+	}
+	lister, ok := backupType.(Lister)
+	if !ok {
+		return nil, fmt.Errorf("backup type %q does not support diffing", fromCfg.BackupType)
+	}
+
+	open := func(key string) (io.ReadCloser, io.Reader, error) {
+		store, err := m.getStorageForBackupKey(ctx, cfg, key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get storage: %w", err)
+		}
+		reader, err := store.Get(ctx, key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get backup %s: %w", key, err)
+		}
+		archive, err := m.openArchive(reader)
+		if err != nil {
+			_ = reader.Close()
+			return nil, nil, fmt.Errorf("failed to open backup %s: %w", key, err)
+		}
+		return reader, archive, nil
+	}
+
+	fromReader, fromArchive, err := open(fromKey)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = fromReader.Close()
+	}()
+
+	toReader, toArchive, err := open(toKey)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = toReader.Close()
+	}()
+
+	diff, err := diffArchives(ctx, lister, fromArchive, toArchive)
+	if err != nil {
+		return nil, err
+	}
+	diff.From = fromKey
+	diff.To = toKey
+	return diff, nil
+}
+
+// diffArchives lists from into memory and then streams to against it, so only
+// the older listing is held at once
+func diffArchives(ctx context.Context, lister Lister, from, to io.Reader) (*BackupDiff, error) {
+	sizes := make(map[string]int64)
+	err := lister.ListArchive(ctx, from, func(e ArchiveEntry) error {
+		if len(sizes) >= maxDiffEntries {
+			return fmt.Errorf("backup has more than %d entries, too many to diff", maxDiffEntries)
+		}
+		sizes[e.Name] = e.Size
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backup: %w", err)
+	}
+
+	diff := &BackupDiff{Added: []ArchiveEntry{}, Removed: []ArchiveEntry{}, Changed: []ChangedEntry{}}
+	results := 0
+	record := func() bool {
+		if results >= maxDiffResults {
+			diff.Truncated = true
+			return false
+		}
+		results++
+		return true
+	}
+
+	seen := make(map[string]bool, len(sizes))
+	err = lister.ListArchive(ctx, to, func(e ArchiveEntry) error {
+		fromSize, ok := sizes[e.Name]
+		switch {
+		case !ok:
+			if record() {
+				diff.Added = append(diff.Added, e)
+			}
+		case seen[e.Name]:
+			// Duplicate names (e.g. a file re-added to a tar) count once
+		case fromSize != e.Size:
+			if record() {
+				diff.Changed = append(diff.Changed, ChangedEntry{Name: e.Name, FromSize: fromSize, ToSize: e.Size})
+			}
+		default:
+			diff.Unchanged++
+		}
+		if ok {
+			seen[e.Name] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backup: %w", err)
+	}
+
+	for name, size := range sizes {
+		if !seen[name] && record() {
+			diff.Removed = append(diff.Removed, ArchiveEntry{Name: name, Size: size})
+		}
+	}
+
+	sort.Slice(diff.Added, func(i, j int) bool { return diff.Added[i].Name < diff.Added[j].Name })
+	sort.Slice(diff.Removed, func(i, j int) bool { return diff.Removed[i].Name < diff.Removed[j].Name })
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].Name < diff.Changed[j].Name })
+	return diff, nil
+}
@@ -0,0 +1,59 @@
+package backup
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lineLister lists archives made of "name size" lines
+type lineLister struct{}
+
+func (lineLister) ListArchive(ctx context.Context, r io.Reader, fn func(ArchiveEntry) error) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		name, size, _ := strings.Cut(scanner.Text(), " ")
+		n, err := strconv.ParseInt(size, 10, 64)
+		if err != nil {
+			return err
+		}
+		if err := fn(ArchiveEntry{Name: name, Size: n}); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func TestDiffArchives(t *testing.T) {
+	from := "data/a.txt 10\ndata/b.txt 20\ndata/c.txt 30\n"
+	to := "data/a.txt 10\ndata/c.txt 35\ndata/d.txt 40\n"
+
+	diff, err := diffArchives(context.Background(), lineLister{}, strings.NewReader(from), strings.NewReader(to))
+	require.NoError(t, err)
+
+	assert.Equal(t, []ArchiveEntry{{Name: "data/d.txt", Size: 40}}, diff.Added)
+	assert.Equal(t, []ArchiveEntry{{Name: "data/b.txt", Size: 20}}, diff.Removed)
+	assert.Equal(t, []ChangedEntry{{Name: "data/c.txt", FromSize: 30, ToSize: 35}}, diff.Changed)
+	assert.Equal(t, 1, diff.Unchanged)
+	assert.False(t, diff.Truncated)
+}
+
+func TestDiffArchives_Truncates(t *testing.T) {
+	var to strings.Builder
+	for i := 0; i < maxDiffResults+5; i++ {
+		fmt.Fprintf(&to, "file-%d 1\n", i)
+	}
+
+	diff, err := diffArchives(context.Background(), lineLister{}, strings.NewReader(""), strings.NewReader(to.String()))
+	require.NoError(t, err)
+
+	assert.Len(t, diff.Added, maxDiffResults)
+	assert.True(t, diff.Truncated)
+}
@@ -0,0 +1,69 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// ExtractBackup writes the contents of a backup to the directory dest on the
+// daemon's filesystem instead of restoring it into the container. The
+// container is neither stopped nor touched, so this takes no operation lock.
+// dest must be absolute and is created if missing.
+func (m *Manager) ExtractBackup(ctx context.Context, containerName, backupKey, dest string) error {
+	if !filepath.IsAbs(dest) {
+		return fmt.Errorf("destination %q must be an absolute path", dest)
+	}
+
+	cfg, _, err := m.findContainerConfig(ctx, containerName)
+	if err != nil {
+		return err
+	}
+
+	backupCfg := backupConfigForKey(cfg, backupKey)
+	if backupCfg == nil {
+		return fmt.Errorf("no backup configuration found for %s", backupKey)
+	}
+
+	backupType, ok := Get(backupCfg.BackupType)
+	if !ok {
+		return fmt.Errorf("unknown backup type %q", backupCfg.BackupType)
+	}
+	extractor, ok := backupType.(Extractor)
+	if !ok {
+		return fmt.Errorf("backup type %q does not support extracting", backupCfg.BackupType)
+	}
+
+	store, err := m.getStorageForBackupKey(ctx, cfg, backupKey)
+	if err != nil {
+		return fmt.Errorf("failed to get storage: %w", err)
+	}
+
+	reader, err := store.Get(ctx, backupKey)
+	if err != nil {
+		return fmt.Errorf("failed to get backup: %w", err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	archive, err := m.openArchive(reader)
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return fmt.Errorf("failed to create destination: %w", err)
+	}
+
+	slog.Info("extracting backup", "container", containerName, "key", backupKey, "dest", dest)
+
+	if err := extractor.Extract(m.restoreBlobStore(ctx, cfg, *backupCfg, backupType), archive, dest); err != nil {
+		return fmt.Errorf("failed to extract backup: %w", err)
+	}
+
+	slog.Info("backup extracted", "container", containerName, "key", backupKey, "dest", dest)
+	return nil
+}
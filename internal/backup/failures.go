@@ -0,0 +1,83 @@
+package backup
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FailureCategory names the stage of a backup run that failed
+type FailureCategory string
+
+const (
+	FailureBusy       FailureCategory = "busy"       // Timed out waiting for the container's operation lock
+	FailureContainer  FailureCategory = "container"  // Container could not be inspected
+	FailureValidation FailureCategory = "validation" // Backup type rejected the container
+	FailureOptions    FailureCategory = "options"    // Invalid backup config options
+	FailureHook       FailureCategory = "hook"       // Pre-hook command failed
+	FailureStorage    FailureCategory = "storage"    // Storage pool unavailable or upload failed
+	FailureBackup     FailureCategory = "backup"     // Backup type failed while producing the archive
+	FailureVerify     FailureCategory = "verify"     // Stored backup could not be read back or didn't match
+)
+
+// retryable reports whether a run failing at this stage may succeed when
+// tried again. Validation and option errors need a config change first.
+func (c FailureCategory) retryable() bool {
+	return c != FailureValidation && c != FailureOptions
+}
+
+// FailureRecord describes a single failed backup run
+type FailureRecord struct {
+	Time      time.Time       `json:"time"`
+	Category  FailureCategory `json:"category"`
+	Error     string          `json:"error"` // Secrets masked
+	BackupKey string          `json:"key,omitempty"`
+}
+
+// secretEnvPattern matches environment variable names whose values are masked in failure records
+var secretEnvPattern = regexp.MustCompile(`(?i)(PASSWORD|PASSWD|PWD|SECRET|TOKEN|KEY)`)
+
+// secretArgPattern matches key=value arguments that commonly carry secrets
+var secretArgPattern = regexp.MustCompile(`(?i)(password|passwd|pwd|secret|token)=\S+`)
+
+// urlCredentialPattern matches the password part of credentials embedded in URLs
+var urlCredentialPattern = regexp.MustCompile(`(://[^:/@\s]+):[^@\s]+@`)
+
+// secretValues returns the values of env vars that look like they hold secrets
+func secretValues(env map[string]string) []string {
+	var secrets []string
+	for name, value := range env {
+		// Very short values would mask unrelated parts of the message
+		if len(value) >= 4 && secretEnvPattern.MatchString(name) {
+			secrets = append(secrets, value)
+		}
+	}
+	// Longest first, so a secret containing another one is masked whole
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	return secrets
+}
+
+// maskSecrets replaces known secret values and credential-looking arguments in msg
+func maskSecrets(msg string, secrets []string) string {
+	for _, secret := range secrets {
+		msg = strings.ReplaceAll(msg, secret, "***")
+	}
+	msg = secretArgPattern.ReplaceAllString(msg, "${1}=***")
+	return urlCredentialPattern.ReplaceAllString(msg, "${1}:***@")
+}
+
+// Failures returns the recent failures of a container's backup configs, newest first, keyed by config name
+func (m *Manager) Failures(ctx context.Context, containerName string) (map[string][]FailureRecord, error) {
+	cfg, containerID, err := m.findContainerConfig(ctx, containerName)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]FailureRecord, len(cfg.Backups))
+	for _, backup := range cfg.Backups {
+		result[backup.Name] = m.jobs.failureHistory(m.makeJobKey(containerID, backup.Name))
+	}
+	return result, nil
+}
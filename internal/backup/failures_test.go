@@ -0,0 +1,98 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/notification"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobTracker_FailureHistoryIsBounded(t *testing.T) {
+	tracker := newJobTracker(3)
+	start := time.Now()
+
+	for i := 0; i < 5; i++ {
+		tracker.record("c1:db", notification.Event{
+			Type:      notification.EventBackupFailed,
+			Error:     fmt.Errorf("failure %d", i),
+			Timestamp: start.Add(time.Duration(i) * time.Second),
+		}, FailureBackup, nil)
+	}
+	tracker.record("c1:db", notification.Event{
+		Type:      notification.EventBackupCompleted,
+		Timestamp: start.Add(10 * time.Second),
+	}, "", nil)
+
+	history := tracker.failureHistory("c1:db")
+	require.Len(t, history, 3)
+	assert.Equal(t, "failure 4", history[0].Error)
+	assert.Equal(t, "failure 2", history[2].Error)
+	assert.Equal(t, FailureBackup, history[0].Category)
+
+	// Callers get a copy
+	history[0].Error = "changed"
+	assert.Equal(t, "failure 4", tracker.failureHistory("c1:db")[0].Error)
+}
+
+func TestJobTracker_FailureHistoryDisabled(t *testing.T) {
+	tracker := newJobTracker(0)
+	tracker.record("c1:db", notification.Event{
+		Type:  notification.EventBackupFailed,
+		Error: errors.New("boom"),
+	}, FailureBackup, nil)
+
+	assert.Empty(t, tracker.failureHistory("c1:db"))
+	_, last := tracker.status("c1:db")
+	require.NotNil(t, last)
+	assert.Equal(t, "boom", last.Error)
+}
+
+func TestJobTracker_MasksSecrets(t *testing.T) {
+	tracker := newJobTracker(10)
+	secrets := secretValues(map[string]string{
+		"POSTGRES_PASSWORD": "hunter2-secret",
+		"POSTGRES_USER":     "postgres",
+	})
+
+	tracker.record("c1:db", notification.Event{
+		Type:  notification.EventBackupFailed,
+		Error: errors.New("pg_dump failed with exit code 1: auth failed for postgres using hunter2-secret"),
+	}, FailureBackup, secrets)
+
+	history := tracker.failureHistory("c1:db")
+	require.Len(t, history, 1)
+	assert.Equal(t, "pg_dump failed with exit code 1: auth failed for postgres using ***", history[0].Error)
+}
+
+func TestMaskSecrets(t *testing.T) {
+	tests := []struct {
+		msg      string
+		expected string
+	}{
+		{"connect failed: password=abc123 host=db", "connect failed: password=*** host=db"},
+		{"PGPASSWORD=abc123 pg_dump", "PGPASSWORD=*** pg_dump"},
+		{"dial postgres://app:s3cret@db:5432/app failed", "dial postgres://app:***@db:5432/app failed"},
+		{"nothing to hide", "nothing to hide"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			assert.Equal(t, tt.expected, maskSecrets(tt.msg, nil))
+		})
+	}
+}
+
+func TestSecretValues(t *testing.T) {
+	secrets := secretValues(map[string]string{
+		"MYSQL_ROOT_PASSWORD": "rootpass",
+		"API_TOKEN":           "tok-longer-value",
+		"APP_KEY":             "abc", // too short to mask safely
+		"MYSQL_DATABASE":      "app",
+	})
+
+	assert.Equal(t, []string{"tok-longer-value", "rootpass"}, secrets)
+}
@@ -0,0 +1,31 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/shyim/docker-backup/internal/docker"
+)
+
+// hookShell runs hook commands, so they may use pipes and variables
+const hookShell = "/bin/sh"
+
+// runHook runs a pre- or post-hook command in the container. An empty command
+// is a no-op; a non-zero exit code is an error.
+func runHook(ctx context.Context, dockerClient *docker.Client, container *docker.ContainerInfo, hook, cmd string) error {
+	if cmd == "" {
+		return nil
+	}
+
+	slog.Debug("running hook", "container", container.Name, "hook", hook, "command", cmd)
+
+	result, err := dockerClient.Exec(ctx, container.ID, []string{hookShell, "-c", cmd}, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to execute %s: %w", hook, err)
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("%s failed with exit code %d: %s", hook, result.ExitCode, result.ErrorOutput())
+	}
+	return nil
+}
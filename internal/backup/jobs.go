@@ -0,0 +1,328 @@
+package backup
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/notification"
+	"github.com/shyim/docker-backup/internal/progress"
+)
+
+// JobState describes what a backup config is doing right now
+type JobState string
+
+const (
+	JobIdle      JobState = "idle"      // Waiting for its next scheduled run
+	JobQueued    JobState = "queued"    // Triggered, waiting for the container's operation lock or a backup slot
+	JobRunning   JobState = "running"   // Backup in progress
+	JobSucceeded JobState = "succeeded" // Finished successfully within jobRecentWindow
+	JobFailed    JobState = "failed"    // Failed within jobRecentWindow
+)
+
+// jobRecentWindow is how long a finished run is reported instead of idle
+const jobRecentWindow = 10 * time.Minute
+
+// JobResult is the outcome of the last finished run of a backup config
+type JobResult struct {
+	Success    bool          `json:"success"`
+	Error      string        `json:"error,omitempty"`
+	BackupKey  string        `json:"key,omitempty"`
+	Storage    string        `json:"storage,omitempty"` // Pool the backup was written to
+	Size       int64         `json:"size,omitempty"`
+	Duration   time.Duration `json:"duration,omitempty"`
+	FinishedAt time.Time     `json:"finished_at"`
+}
+
+// JobStatus combines schedule, queue, progress and last result of a backup config
+type JobStatus struct {
+	ContainerName       string             `json:"container"`
+	ConfigName          string             `json:"config"`
+	BackupType          string             `json:"type"`
+	Schedule            string             `json:"schedule"`
+	State               JobState           `json:"state"`
+	NextRun             *time.Time         `json:"next_run,omitempty"`
+	QueuedSince         *time.Time         `json:"queued_since,omitempty"`
+	Progress            *progress.Snapshot `json:"progress,omitempty"`
+	LastResult          *JobResult         `json:"last_result,omitempty"`
+	ConsecutiveFailures int                `json:"consecutive_failures,omitempty"` // Failed runs since the last success
+}
+
+// jobTracker remembers queued runs, last results and recent failures per job key
+type jobTracker struct {
+	mu           sync.Mutex
+	queued       map[string]time.Time
+	last         map[string]JobResult
+	failures     map[string][]FailureRecord // Newest first
+	streaks      map[string]int             // Consecutive failures
+	failureLimit int
+}
+
+func newJobTracker(failureLimit int) *jobTracker {
+	return &jobTracker{
+		queued:       make(map[string]time.Time),
+		last:         make(map[string]JobResult),
+		failures:     make(map[string][]FailureRecord),
+		streaks:      make(map[string]int),
+		failureLimit: failureLimit,
+	}
+}
+
+// queue marks a job as waiting to run
+func (t *jobTracker) queue(jobKey string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.queued[jobKey] = time.Now()
+}
+
+// dequeue marks a job as no longer waiting
+func (t *jobTracker) dequeue(jobKey string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.queued, jobKey)
+}
+
+// record stores the outcome of a run from its completion or failure event.
+// Failures are also added to the job's failure history with secrets masked.
+// It returns the job's failure streak: for a failure the number of consecutive
+// failures including this one, for a success the number of failures it ended.
+func (t *jobTracker) record(jobKey string, event notification.Event, category FailureCategory, secrets []string) int {
+	return t.store(jobKey, event, category, secrets, true)
+}
+
+// recordRetried stores a failed run that is tried again like record, without
+// counting it towards the failure streak
+func (t *jobTracker) recordRetried(jobKey string, event notification.Event, category FailureCategory, secrets []string) {
+	t.store(jobKey, event, category, secrets, false)
+}
+
+// countRetried counts the last failure stored with recordRetried towards the
+// failure streak once it is not tried again, returning the streak
+func (t *jobTracker) countRetried(jobKey string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.streaks[jobKey]++
+	return t.streaks[jobKey]
+}
+
+// store records a run, counting a failure towards the streak if countStreak is set
+func (t *jobTracker) store(jobKey string, event notification.Event, category FailureCategory, secrets []string, countStreak bool) int {
+	result := JobResult{
+		Success:    event.Type == notification.EventBackupCompleted,
+		BackupKey:  event.BackupKey,
+		Storage:    event.Storage,
+		Size:       event.Size,
+		Duration:   event.Duration,
+		FinishedAt: event.Timestamp,
+	}
+	if event.Error != nil {
+		result.Error = maskSecrets(event.Error.Error(), secrets)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.last[jobKey] = result
+
+	streak := t.streaks[jobKey]
+	if result.Success {
+		delete(t.streaks, jobKey)
+		return streak
+	}
+	if countStreak {
+		streak++
+		t.streaks[jobKey] = streak
+	}
+
+	if t.failureLimit <= 0 {
+		return streak
+	}
+
+	history := append([]FailureRecord{{
+		Time:      event.Timestamp,
+		Category:  category,
+		Error:     result.Error,
+		BackupKey: event.BackupKey,
+	}}, t.failures[jobKey]...)
+	if len(history) > t.failureLimit {
+		history = history[:t.failureLimit]
+	}
+	t.failures[jobKey] = history
+	return streak
+}
+
+// streak returns the number of consecutive failures of a job
+func (t *jobTracker) streak(jobKey string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.streaks[jobKey]
+}
+
+// failureHistory returns a copy of the job's recent failures, newest first
+func (t *jobTracker) failureHistory(jobKey string) []FailureRecord {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]FailureRecord{}, t.failures[jobKey]...)
+}
+
+// forget drops all state kept for a job
+func (t *jobTracker) forget(jobKey string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.queued, jobKey)
+	delete(t.last, jobKey)
+	delete(t.failures, jobKey)
+	delete(t.streaks, jobKey)
+}
+
+// status returns the queue time and last result of a job
+func (t *jobTracker) status(jobKey string) (*time.Time, *JobResult) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var queuedSince *time.Time
+	if since, ok := t.queued[jobKey]; ok {
+		queuedSince = &since
+	}
+	var last *JobResult
+	if result, ok := t.last[jobKey]; ok {
+		last = &result
+	}
+	return queuedSince, last
+}
+
+// jobState derives the state shown for a job, preferring the most active one
+func jobState(queuedSince *time.Time, running *progress.Snapshot, last *JobResult, now time.Time) JobState {
+	switch {
+	case running != nil:
+		return JobRunning
+	case queuedSince != nil:
+		return JobQueued
+	case last != nil && now.Sub(last.FinishedAt) < jobRecentWindow:
+		if last.Success {
+			return JobSucceeded
+		}
+		return JobFailed
+	default:
+		return JobIdle
+	}
+}
+
+// Jobs returns the status of every scheduled backup config, sorted by container and config name
+func (m *Manager) Jobs() []JobStatus {
+	scheduled := m.scheduler.ListJobs()
+
+	running := make(map[string]progress.Snapshot)
+	for _, snap := range m.progress.List() {
+		if snap.Operation == progress.OperationBackup {
+			running[snap.ContainerName+"/"+snap.ConfigName] = snap
+		}
+	}
+
+	now := time.Now()
+
+	m.mu.RLock()
+	var jobs []JobStatus
+	for containerID, cfg := range m.containers {
+		for _, backup := range cfg.Backups {
+			jobKey := m.makeJobKey(containerID, backup.Name)
+			status := JobStatus{
+				ContainerName: cfg.ContainerName,
+				ConfigName:    backup.Name,
+				BackupType:    backup.BackupType,
+				Schedule:      backup.Schedule,
+			}
+
+			if job, ok := scheduled[jobKey]; ok && !job.NextRun.IsZero() {
+				next := job.NextRun
+				status.NextRun = &next
+			}
+			if snap, ok := running[cfg.ContainerName+"/"+backup.Name]; ok {
+				status.Progress = &snap
+			}
+			status.QueuedSince, status.LastResult = m.jobs.status(jobKey)
+			status.State = jobState(status.QueuedSince, status.Progress, status.LastResult, now)
+			status.ConsecutiveFailures = m.jobs.streak(jobKey)
+
+			jobs = append(jobs, status)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(jobs, func(i, j int) bool {
+		if jobs[i].ContainerName != jobs[j].ContainerName {
+			return jobs[i].ContainerName < jobs[j].ContainerName
+		}
+		return jobs[i].ConfigName < jobs[j].ConfigName
+	})
+
+	return jobs
+}
+
+// ContainerStatus is a tracked container with the status of its backup configs
+type ContainerStatus struct {
+	ContainerID   string         `json:"id"`
+	ContainerName string         `json:"name"`
+	Notify        []string       `json:"notify,omitempty"`
+	Configs       []ConfigStatus `json:"configs"`
+}
+
+// ConfigStatus is a backup config as parsed from the labels, with its job status
+type ConfigStatus struct {
+	Name                string     `json:"name"`
+	BackupType          string     `json:"type"`
+	Schedule            string     `json:"schedule"`
+	Retention           string     `json:"retention"`
+	Storage             string     `json:"storage,omitempty"`
+	Fallback            []string   `json:"fallback,omitempty"`
+	Mirrors             []string   `json:"mirrors,omitempty"`
+	State               JobState   `json:"state"`
+	NextRun             *time.Time `json:"next_run,omitempty"`
+	LastResult          *JobResult `json:"last_result,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures,omitempty"`
+}
+
+// Containers returns every tracked container with its backup configs and when
+// they run next, sorted by container and config name
+func (m *Manager) Containers() []ContainerStatus {
+	jobs := make(map[string]JobStatus)
+	for _, job := range m.Jobs() {
+		jobs[job.ContainerName+"/"+job.ConfigName] = job
+	}
+
+	containers := m.GetContainers()
+	result := make([]ContainerStatus, 0, len(containers))
+	for _, c := range containers {
+		status := ContainerStatus{
+			ContainerID:   c.ContainerID,
+			ContainerName: c.ContainerName,
+			Notify:        c.Notify,
+			Configs:       make([]ConfigStatus, 0, len(c.Backups)),
+		}
+		for _, b := range c.Backups {
+			job := jobs[c.ContainerName+"/"+b.Name]
+			status.Configs = append(status.Configs, ConfigStatus{
+				Name:                b.Name,
+				BackupType:          b.BackupType,
+				Schedule:            b.Schedule,
+				Retention:           b.Retention,
+				Storage:             b.Storage,
+				Fallback:            b.Fallback,
+				Mirrors:             b.Mirrors,
+				State:               job.State,
+				NextRun:             job.NextRun,
+				LastResult:          job.LastResult,
+				ConsecutiveFailures: job.ConsecutiveFailures,
+			})
+		}
+		sort.Slice(status.Configs, func(i, j int) bool {
+			return status.Configs[i].Name < status.Configs[j].Name
+		})
+		result = append(result, status)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ContainerName < result[j].ContainerName
+	})
+
+	return result
+}
@@ -0,0 +1,169 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/config"
+	"github.com/shyim/docker-backup/internal/notification"
+	"github.com/shyim/docker-backup/internal/progress"
+	"github.com/shyim/docker-backup/internal/scheduler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobTracker_QueueAndRecord(t *testing.T) {
+	tracker := newJobTracker(10)
+
+	queued, last := tracker.status("c1:db")
+	assert.Nil(t, queued)
+	assert.Nil(t, last)
+
+	tracker.queue("c1:db")
+	queued, _ = tracker.status("c1:db")
+	require.NotNil(t, queued)
+
+	tracker.dequeue("c1:db")
+	finished := time.Now()
+	tracker.record("c1:db", notification.Event{
+		Type:      notification.EventBackupFailed,
+		BackupKey: "db/db/1.sql.zst",
+		Error:     errors.New("dump failed"),
+		Timestamp: finished,
+	}, FailureBackup, nil)
+
+	queued, last = tracker.status("c1:db")
+	assert.Nil(t, queued)
+	require.NotNil(t, last)
+	assert.False(t, last.Success)
+	assert.Equal(t, "dump failed", last.Error)
+	assert.Equal(t, "db/db/1.sql.zst", last.BackupKey)
+	assert.Equal(t, finished, last.FinishedAt)
+
+	tracker.record("c1:db", notification.Event{
+		Type:      notification.EventBackupCompleted,
+		Size:      42,
+		Timestamp: finished,
+	}, "", nil)
+	_, last = tracker.status("c1:db")
+	require.NotNil(t, last)
+	assert.True(t, last.Success)
+	assert.Empty(t, last.Error)
+	assert.Equal(t, int64(42), last.Size)
+
+	tracker.forget("c1:db")
+	_, last = tracker.status("c1:db")
+	assert.Nil(t, last)
+}
+
+func TestJobState(t *testing.T) {
+	now := time.Now()
+	queued := now.Add(-time.Second)
+	running := &progress.Snapshot{ContainerName: "db", ConfigName: "db"}
+	recentOK := &JobResult{Success: true, FinishedAt: now.Add(-time.Minute)}
+	recentFailed := &JobResult{Success: false, FinishedAt: now.Add(-time.Minute)}
+	old := &JobResult{Success: false, FinishedAt: now.Add(-jobRecentWindow - time.Minute)}
+
+	assert.Equal(t, JobIdle, jobState(nil, nil, nil, now))
+	assert.Equal(t, JobIdle, jobState(nil, nil, old, now))
+	assert.Equal(t, JobSucceeded, jobState(nil, nil, recentOK, now))
+	assert.Equal(t, JobFailed, jobState(nil, nil, recentFailed, now))
+	assert.Equal(t, JobQueued, jobState(&queued, nil, recentOK, now))
+	assert.Equal(t, JobRunning, jobState(&queued, running, recentOK, now))
+}
+
+func TestJobTracker_FailureStreak(t *testing.T) {
+	tracker := newJobTracker(0)
+	failed := notification.Event{Type: notification.EventBackupFailed, Error: errors.New("unreachable")}
+	completed := notification.Event{Type: notification.EventBackupCompleted}
+
+	assert.Equal(t, 1, tracker.record("c1:db", failed, FailureStorage, nil))
+	assert.Equal(t, 2, tracker.record("c1:db", failed, FailureStorage, nil))
+	assert.Equal(t, 2, tracker.streak("c1:db"))
+
+	// A success returns the streak it ended and resets it
+	assert.Equal(t, 2, tracker.record("c1:db", completed, "", nil))
+	assert.Equal(t, 0, tracker.streak("c1:db"))
+	assert.Equal(t, 0, tracker.record("c1:db", completed, "", nil))
+
+	assert.Equal(t, 1, tracker.record("c1:db", failed, FailureStorage, nil))
+	tracker.forget("c1:db")
+	assert.Equal(t, 0, tracker.streak("c1:db"))
+}
+
+func TestJobTracker_RecordRetried(t *testing.T) {
+	tracker := newJobTracker(10)
+	failed := notification.Event{Type: notification.EventBackupFailed, Error: errors.New("unreachable")}
+
+	// A failure that is tried again shows up without counting towards the streak
+	tracker.recordRetried("c1:db", failed, FailureStorage, nil)
+	assert.Equal(t, 0, tracker.streak("c1:db"))
+	assert.Len(t, tracker.failureHistory("c1:db"), 1)
+	_, last := tracker.status("c1:db")
+	require.NotNil(t, last)
+	assert.Equal(t, "unreachable", last.Error)
+
+	assert.Equal(t, 1, tracker.record("c1:db", failed, FailureStorage, nil))
+	assert.Len(t, tracker.failureHistory("c1:db"), 2)
+
+	// A failure whose retry didn't run counts once it is given up
+	tracker.recordRetried("c1:db", failed, FailureStorage, nil)
+	assert.Equal(t, 2, tracker.countRetried("c1:db"))
+	assert.Len(t, tracker.failureHistory("c1:db"), 3)
+
+	assert.True(t, FailureStorage.retryable())
+	assert.False(t, FailureValidation.retryable())
+	assert.False(t, FailureOptions.retryable())
+}
+
+func TestManager_Containers(t *testing.T) {
+	sched := scheduler.New(context.Background())
+	sched.Start()
+	defer sched.Stop()
+
+	m := &Manager{
+		scheduler: sched,
+		progress:  progress.NewRegistry(),
+		jobs:      newJobTracker(10),
+		containers: map[string]*config.ContainerConfig{
+			"b2": {ContainerName: "wiki", Backups: []config.BackupConfig{
+				{Name: "files", BackupType: "volume", Schedule: "0 4 * * *", Retention: 7},
+			}},
+			"a1": {ContainerName: "postgres", Notify: []string{"team"}, Backups: []config.BackupConfig{
+				{Name: "hourly", BackupType: "postgres", Schedule: "0 * * * *", Retention: 24, Storage: "s3"},
+				{Name: "daily", BackupType: "postgres", Schedule: "0 3 * * *", RetentionMaxAge: 90 * 24 * time.Hour},
+			}},
+		},
+	}
+	require.NoError(t, sched.AddJob(m.makeJobKey("a1", "daily"), "0 3 * * *", func(ctx context.Context) {}))
+	m.jobs.record(m.makeJobKey("a1", "hourly"), notification.Event{
+		Type:      notification.EventBackupFailed,
+		Error:     errors.New("dump failed"),
+		Timestamp: time.Now(),
+	}, FailureBackup, nil)
+
+	containers := m.Containers()
+	require.Len(t, containers, 2)
+	assert.Equal(t, "postgres", containers[0].ContainerName)
+	assert.Equal(t, "a1", containers[0].ContainerID)
+	assert.Equal(t, []string{"team"}, containers[0].Notify)
+	assert.Equal(t, "wiki", containers[1].ContainerName)
+
+	configs := containers[0].Configs
+	require.Len(t, configs, 2)
+	assert.Equal(t, "daily", configs[0].Name, "configs are sorted by name")
+	assert.Equal(t, "90d", configs[0].Retention)
+	require.NotNil(t, configs[0].NextRun)
+	assert.Equal(t, 3, configs[0].NextRun.Hour())
+	assert.Equal(t, JobIdle, configs[0].State)
+
+	assert.Equal(t, "hourly", configs[1].Name)
+	assert.Equal(t, "s3", configs[1].Storage)
+	assert.Nil(t, configs[1].NextRun, "not scheduled")
+	assert.Equal(t, JobFailed, configs[1].State)
+	require.NotNil(t, configs[1].LastResult)
+	assert.Equal(t, "dump failed", configs[1].LastResult.Error)
+	assert.Equal(t, 1, configs[1].ConsecutiveFailures)
+}
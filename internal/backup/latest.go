@@ -0,0 +1,109 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shyim/docker-backup/internal/config"
+	"github.com/shyim/docker-backup/internal/storage"
+)
+
+// LatestBackup returns the newest backup of one of a container's backup configs.
+// configName is the config's key path: its name, or the backup type for unnamed configs.
+func (m *Manager) LatestBackup(ctx context.Context, containerName, configName string) (storage.BackupFile, error) {
+	cfg, _, err := m.findContainerConfig(ctx, containerName)
+	if err != nil {
+		return storage.BackupFile{}, err
+	}
+
+	for _, backup := range cfg.Backups {
+		if configKeyPath(backup) != configName {
+			continue
+		}
+
+		latest, ok, err := m.newestBackupOf(ctx, containerName, backup)
+		if err != nil {
+			return storage.BackupFile{}, err
+		}
+		if !ok {
+			return storage.BackupFile{}, fmt.Errorf("no backups found for config %q of container %q", configName, containerName)
+		}
+		return latest, nil
+	}
+
+	return storage.BackupFile{}, fmt.Errorf("backup config %q not found in container %q", configName, containerName)
+}
+
+// LatestBackupKey returns the key of the newest backup of configName, which
+// may be empty if the container has a single backup config
+func (m *Manager) LatestBackupKey(ctx context.Context, containerName, configName string) (string, error) {
+	cfg, _, err := m.findContainerConfig(ctx, containerName)
+	if err != nil {
+		return "", err
+	}
+	return m.latestKey(ctx, cfg, configName)
+}
+
+// latestKey is LatestBackupKey for a container whose config was already looked up
+func (m *Manager) latestKey(ctx context.Context, cfg *config.ContainerConfig, configName string) (string, error) {
+	if configName == "" {
+		if len(cfg.Backups) != 1 {
+			return "", fmt.Errorf("container %q has %d backup configs, select one to use its latest backup", cfg.ContainerName, len(cfg.Backups))
+		}
+		configName = configKeyPath(cfg.Backups[0])
+	}
+
+	latest, err := m.LatestBackup(ctx, cfg.ContainerName, configName)
+	if err != nil {
+		return "", err
+	}
+	return latest.Key, nil
+}
+
+// configKeyPath returns the key path segment of a backup config: its name, or
+// the backup type for unnamed configs
+func configKeyPath(backup config.BackupConfig) string {
+	if backup.Name != "" {
+		return backup.Name
+	}
+	return backup.BackupType
+}
+
+// newestBackupOf returns the newest backup of a config across its storage
+// chain. ok is false when the config has no backups yet.
+func (m *Manager) newestBackupOf(ctx context.Context, containerName string, backup config.BackupConfig) (storage.BackupFile, bool, error) {
+	// A failover chain spreads backups over several pools
+	var files []storage.BackupFile
+	for _, pool := range backup.StorageChain() {
+		store, err := m.poolManager.GetForContainer(pool)
+		if err != nil {
+			return storage.BackupFile{}, false, fmt.Errorf("failed to get storage: %w", err)
+		}
+
+		listed, err := store.List(ctx, configKeyPrefix(containerName, backup))
+		if err != nil {
+			return storage.BackupFile{}, false, fmt.Errorf("failed to list backups: %w", err)
+		}
+		files = append(files, storage.WithoutChecksums(listed)...)
+	}
+
+	latest, ok := NewestBackup(files)
+	return latest, ok, nil
+}
+
+// NewestBackup returns the most recently modified file. Keys break ties, as
+// they end in the backup time.
+func NewestBackup(files []storage.BackupFile) (storage.BackupFile, bool) {
+	if len(files) == 0 {
+		return storage.BackupFile{}, false
+	}
+
+	newest := files[0]
+	for _, f := range files[1:] {
+		if f.LastModified.After(newest.LastModified) ||
+			(f.LastModified.Equal(newest.LastModified) && f.Key > newest.Key) {
+			newest = f
+		}
+	}
+	return newest, true
+}
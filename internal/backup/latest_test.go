@@ -0,0 +1,69 @@
+package backup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/config"
+	"github.com/shyim/docker-backup/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewestBackup(t *testing.T) {
+	_, ok := NewestBackup(nil)
+	assert.False(t, ok)
+
+	base := time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC)
+	files := []storage.BackupFile{
+		{Key: "db/db/2026-01-02/030000.sql.zst", LastModified: base},
+		{Key: "db/db/2026-01-03/030000.sql.zst", LastModified: base.Add(24 * time.Hour)},
+		{Key: "db/db/2026-01-01/030000.sql.zst", LastModified: base.Add(-24 * time.Hour)},
+	}
+	newest, ok := NewestBackup(files)
+	assert.True(t, ok)
+	assert.Equal(t, "db/db/2026-01-03/030000.sql.zst", newest.Key)
+
+	// Storage backends with coarse timestamps report the same time for several files
+	tied := []storage.BackupFile{
+		{Key: "db/db/2026-01-02/030001.sql.zst", LastModified: base},
+		{Key: "db/db/2026-01-02/030000.sql.zst", LastModified: base},
+	}
+	newest, _ = NewestBackup(tied)
+	assert.Equal(t, "db/db/2026-01-02/030001.sql.zst", newest.Key)
+}
+
+func TestLatestBackupKey(t *testing.T) {
+	ctx := context.Background()
+	m := newFailoverManager(t)
+	m.containers = map[string]*config.ContainerConfig{"app": {
+		ContainerName: "app",
+		Backups:       []config.BackupConfig{{Name: "db", Storage: "s3"}},
+	}}
+
+	_, err := m.LatestBackupKey(ctx, "app", "")
+	assert.Error(t, err, "no backups yet")
+
+	base := time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC)
+	s3 := memPools["s3"]
+	s3.objects["app/db/2026-01-02/030000.sql.zst"] = []byte("new")
+	s3.objects["app/db/2026-01-01/030000.sql.zst"] = []byte("old")
+	s3.modified = map[string]time.Time{
+		"app/db/2026-01-02/030000.sql.zst": base,
+		"app/db/2026-01-01/030000.sql.zst": base.Add(-24 * time.Hour),
+	}
+
+	// A single config is used without naming it
+	key, err := m.LatestBackupKey(ctx, "app", "")
+	require.NoError(t, err)
+	assert.Equal(t, "app/db/2026-01-02/030000.sql.zst", key)
+
+	m.containers["app"].Backups = append(m.containers["app"].Backups, config.BackupConfig{Name: "files", Storage: "s3"})
+	_, err = m.LatestBackupKey(ctx, "app", "")
+	assert.ErrorContains(t, err, "2 backup configs")
+
+	key, err = m.LatestBackupKey(ctx, "app", "db")
+	require.NoError(t, err)
+	assert.Equal(t, "app/db/2026-01-02/030000.sql.zst", key)
+}
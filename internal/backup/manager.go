@@ -1,54 +1,89 @@
 package backup
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"log/slog"
+	"maps"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/docker/docker/api/types/events"
+	"github.com/shyim/docker-backup/internal/audit"
+	"github.com/shyim/docker-backup/internal/compression"
 	"github.com/shyim/docker-backup/internal/config"
 	"github.com/shyim/docker-backup/internal/docker"
+	"github.com/shyim/docker-backup/internal/encryption"
 	"github.com/shyim/docker-backup/internal/notification"
+	"github.com/shyim/docker-backup/internal/progress"
 	"github.com/shyim/docker-backup/internal/retention"
 	"github.com/shyim/docker-backup/internal/scheduler"
 	"github.com/shyim/docker-backup/internal/storage"
+	"golang.org/x/sync/semaphore"
 )
 
 // Manager orchestrates the backup process
 type Manager struct {
-	dockerClient *docker.Client
-	poolManager  *storage.PoolManager
-	scheduler    *scheduler.Scheduler
-	retention    *retention.Manager
-	notifyMgr    *notification.Manager
-	config       *config.Config
-	watcher      *docker.Watcher
-	containers   map[string]*config.ContainerConfig
-	mu           sync.RWMutex
+	docker      *docker.MultiClient
+	poolManager *storage.PoolManager
+	scheduler   *scheduler.Scheduler
+	retention   *retention.Manager
+	notifyMgr   *notification.Manager
+	keyring     *encryption.Keyring // nil when encryption is disabled
+	age         *encryption.Age     // nil when age encryption is disabled
+	config      *config.Config
+	watcher     *docker.Watcher
+	containers  map[string]*config.ContainerConfig
+	mu          sync.RWMutex
+	ready       chan struct{}
+	readyOnce   sync.Once
+	opLocks     *opLocks
+	backupSlots *semaphore.Weighted // nil without --max-concurrent-backups
+	progress    *progress.Registry
+	jobs        *jobTracker
+	notifying   sync.WaitGroup       // Notifications still being sent
+	audit       *audit.Log           // nil without --audit-log
+	uploadLimit *storage.RateLimiter // nil without --upload-rate-limit
 }
 
 // NewManager creates a new backup manager
 func NewManager(
-	dockerClient *docker.Client,
+	dockerClient *docker.MultiClient,
 	poolManager *storage.PoolManager,
 	sched *scheduler.Scheduler,
 	retention *retention.Manager,
 	notifyMgr *notification.Manager,
+	keyring *encryption.Keyring,
+	ageEncryption *encryption.Age,
 	cfg *config.Config,
 ) *Manager {
 	m := &Manager{
-		dockerClient: dockerClient,
-		poolManager:  poolManager,
-		scheduler:    sched,
-		retention:    retention,
-		notifyMgr:    notifyMgr,
-		config:       cfg,
-		containers:   make(map[string]*config.ContainerConfig),
+		docker:      dockerClient,
+		poolManager: poolManager,
+		scheduler:   sched,
+		retention:   retention,
+		notifyMgr:   notifyMgr,
+		keyring:     keyring,
+		age:         ageEncryption,
+		config:      cfg,
+		containers:  make(map[string]*config.ContainerConfig),
+		ready:       make(chan struct{}),
+		opLocks:     newOpLocks(opLockWarnAfter, opLockTimeout),
+		progress:    progress.NewRegistry(),
+		jobs:        newJobTracker(cfg.FailureHistory),
+	}
+
+	if cfg.MaxConcurrentBackups > 0 {
+		m.backupSlots = semaphore.NewWeighted(int64(cfg.MaxConcurrentBackups))
 	}
 
 	m.watcher = docker.NewWatcher(dockerClient, m.handleEvent, cfg.PollInterval)
@@ -62,11 +97,39 @@ func (m *Manager) Start(ctx context.Context) error {
 		return fmt.Errorf("initial container sync failed: %w", err)
 	}
 
+	m.readyOnce.Do(func() { close(m.ready) })
+	slog.Info("backup manager ready")
+
+	if m.config.RunMissedOnStartup {
+		go m.runMissedBackups(ctx)
+	}
+
 	m.watcher.Start(ctx)
 
 	return nil
 }
 
+// Ready returns a channel that is closed once the initial container sync has completed.
+// Until then the manager's view of containers is incomplete.
+func (m *Manager) Ready() <-chan struct{} {
+	return m.ready
+}
+
+// Progress returns the progress of all running backups and restores
+func (m *Manager) Progress() []progress.Snapshot {
+	return m.progress.List()
+}
+
+// IsReady reports whether the initial container sync has completed
+func (m *Manager) IsReady() bool {
+	select {
+	case <-m.ready:
+		return true
+	default:
+		return false
+	}
+}
+
 func (m *Manager) handleEvent(ctx context.Context, event events.Message) {
 	switch event.Action {
 	case "start":
@@ -77,7 +140,15 @@ func (m *Manager) handleEvent(ctx context.Context, event events.Message) {
 	case "stop", "die":
 		containerID := event.Actor.ID
 		slog.Debug("container stopped", "container_id", containerID)
-		m.removeContainer(containerID)
+		if m.config.IncludeStoppedContainers {
+			// Keeps the configs that run while the container is stopped
+			m.addContainer(ctx, containerID)
+		} else {
+			m.removeContainer(containerID)
+		}
+
+	case "destroy":
+		m.removeContainer(event.Actor.ID)
 
 	case "sync":
 		if err := m.syncContainers(ctx); err != nil {
@@ -88,17 +159,20 @@ func (m *Manager) handleEvent(ctx context.Context, event events.Message) {
 
 // syncContainers scans for containers and updates scheduled jobs
 func (m *Manager) syncContainers(ctx context.Context) error {
-	containers, err := m.dockerClient.ListContainers(ctx)
+	containers, nodeErrs, err := m.docker.ListContainers(ctx, m.config.IncludeStoppedContainers)
 	if err != nil {
 		return fmt.Errorf("failed to list containers: %w", err)
 	}
+	for node, err := range nodeErrs {
+		slog.Warn("failed to list containers of docker node, keeping its schedules", "node", node, "error", err)
+	}
 
 	seen := make(map[string]bool)
 
 	for _, container := range containers {
-		seen[container.ID] = true
+		seen[container.Key()] = true
 
-		cfg, err := config.ParseLabels("docker-backup", container.ID, container.Name, container.Labels)
+		cfg, err := m.containerConfig(&container)
 		if err != nil {
 			slog.Warn("failed to parse container labels",
 				"container", container.Name,
@@ -110,9 +184,14 @@ func (m *Manager) syncContainers(ctx context.Context) error {
 		if !cfg.Enabled {
 			continue
 		}
+		if len(cfg.Backups) == 0 {
+			// A stopped container without configs that run while it's stopped
+			delete(seen, container.Key())
+			continue
+		}
 
 		m.mu.RLock()
-		existingCfg, exists := m.containers[container.ID]
+		existingCfg, exists := m.containers[container.Key()]
 		m.mu.RUnlock()
 
 		if exists {
@@ -121,16 +200,21 @@ func (m *Manager) syncContainers(ctx context.Context) error {
 			}
 		}
 
-		m.scheduleContainer(ctx, container.ID, cfg)
+		m.scheduleContainer(ctx, container.Key(), cfg)
 	}
 
 	m.mu.Lock()
 	for containerID := range m.containers {
+		// Containers of unreachable nodes may still be running
+		if node, _ := docker.SplitID(containerID); nodeErrs[node] != nil {
+			continue
+		}
 		if !seen[containerID] {
 			cfg := m.containers[containerID]
 			for _, backup := range cfg.Backups {
 				jobKey := m.makeJobKey(containerID, backup.Name)
 				m.scheduler.RemoveJob(jobKey)
+				m.jobs.forget(jobKey)
 			}
 			delete(m.containers, containerID)
 			slog.Info("removed backup schedule for stopped container", "container_id", containerID)
@@ -154,6 +238,22 @@ func (m *Manager) syncContainers(ctx context.Context) error {
 	return nil
 }
 
+// parseLabels parses a container's labels using the configured label prefix and
+// backup defaults, merged with the config file's rules matching the container
+func (m *Manager) parseLabels(container *docker.ContainerInfo) (*config.ContainerConfig, error) {
+	return config.ParseConfigForContainer(m.config.LabelPrefix, m.config.BackupDefaults(), m.config.Containers, container.Key(), container.Name, container.Labels)
+}
+
+// containerConfig parses a container's labels like parseLabels, keeping only
+// the configs that run while it is stopped if it isn't running
+func (m *Manager) containerConfig(container *docker.ContainerInfo) (*config.ContainerConfig, error) {
+	cfg, err := m.parseLabels(container)
+	if err != nil || container.Running {
+		return cfg, err
+	}
+	return cfg.Stopped(), nil
+}
+
 // configsEqual compares two slices of BackupConfig for equality
 func configsEqual(a, b []config.BackupConfig) bool {
 	if len(a) != len(b) {
@@ -163,8 +263,22 @@ func configsEqual(a, b []config.BackupConfig) bool {
 		if a[i].Name != b[i].Name ||
 			a[i].BackupType != b[i].BackupType ||
 			a[i].Schedule != b[i].Schedule ||
+			a[i].Timezone != b[i].Timezone ||
+			a[i].PreHook != b[i].PreHook ||
+			a[i].PostHook != b[i].PostHook ||
 			a[i].Retention != b[i].Retention ||
-			a[i].Storage != b[i].Storage {
+			a[i].RetentionMaxAge != b[i].RetentionMaxAge ||
+			a[i].MaxTotalSize != b[i].MaxTotalSize ||
+			a[i].MinKeep != b[i].MinKeep ||
+			a[i].Storage != b[i].Storage ||
+			a[i].NotifyAfterFailures != b[i].NotifyAfterFailures ||
+			!slices.Equal(a[i].NotifyOn, b[i].NotifyOn) ||
+			!slices.Equal(a[i].Fallback, b[i].Fallback) ||
+			!slices.Equal(a[i].Mirrors, b[i].Mirrors) ||
+			a[i].MirrorMode != b[i].MirrorMode ||
+			a[i].WhenStopped != b[i].WhenStopped ||
+			a[i].PathPrefix != b[i].PathPrefix ||
+			!maps.Equal(a[i].Options, b[i].Options) {
 			return false
 		}
 	}
@@ -173,13 +287,13 @@ func configsEqual(a, b []config.BackupConfig) bool {
 
 // addContainer adds a single container to the backup schedule
 func (m *Manager) addContainer(ctx context.Context, containerID string) {
-	container, err := m.dockerClient.GetContainer(ctx, containerID)
+	_, container, err := m.docker.GetContainer(ctx, containerID)
 	if err != nil {
 		slog.Warn("failed to get container info", "container_id", containerID, "error", err)
 		return
 	}
 
-	cfg, err := config.ParseLabels("docker-backup", container.ID, container.Name, container.Labels)
+	cfg, err := m.containerConfig(container)
 	if err != nil {
 		slog.Debug("container not configured for backup", "container", container.Name, "error", err)
 		return
@@ -188,6 +302,10 @@ func (m *Manager) addContainer(ctx context.Context, containerID string) {
 	if !cfg.Enabled {
 		return
 	}
+	if len(cfg.Backups) == 0 {
+		m.removeContainer(containerID)
+		return
+	}
 
 	m.scheduleContainer(ctx, containerID, cfg)
 }
@@ -202,6 +320,7 @@ func (m *Manager) removeContainer(containerID string) {
 		for _, backup := range cfg.Backups {
 			jobKey := m.makeJobKey(containerID, backup.Name)
 			m.scheduler.RemoveJob(jobKey)
+			m.jobs.forget(jobKey)
 		}
 		delete(m.containers, containerID)
 		slog.Info("removed backup schedule", "container_id", containerID)
@@ -244,16 +363,16 @@ func (m *Manager) scheduleBackupConfig(ctx context.Context, containerID string,
 		return
 	}
 
-	storagePool := backup.Storage
-	_, err := m.poolManager.GetForContainer(storagePool)
-	if err != nil {
-		slog.Error("storage pool not found",
-			"container", cfg.ContainerName,
-			"config", backup.Name,
-			"storage", storagePool,
-			"error", err,
-		)
-		return
+	for _, storagePool := range backup.Pools() {
+		if _, err := m.poolManager.GetForContainer(storagePool); err != nil {
+			slog.Error("storage pool not found",
+				"container", cfg.ContainerName,
+				"config", backup.Name,
+				"storage", storagePool,
+				"error", err,
+			)
+			return
+		}
 	}
 
 	jobKey := m.makeJobKey(containerID, backup.Name)
@@ -261,10 +380,10 @@ func (m *Manager) scheduleBackupConfig(ctx context.Context, containerID string,
 	backupCfg := backup
 
 	job := func(jobCtx context.Context) {
-		m.runBackup(jobCtx, containerID, cfg, backupCfg, backupType)
+		m.runScheduled(jobCtx, jobKey, containerID, cfg, backupCfg, backupType, 0)
 	}
 
-	if err := m.scheduler.AddJob(jobKey, backup.Schedule, job); err != nil {
+	if err := m.scheduler.AddJob(jobKey, backup.CronSchedule(), job); err != nil {
 		slog.Error("failed to schedule backup",
 			"container", cfg.ContainerName,
 			"config", backup.Name,
@@ -279,8 +398,11 @@ func (m *Manager) scheduleBackupConfig(ctx context.Context, containerID string,
 		"config", backup.Name,
 		"type", backup.BackupType,
 		"schedule", backup.Schedule,
-		"retention", backup.Retention,
+		"tz", backup.Timezone,
+		"retention", backup.RetentionString(),
 		"storage", backup.Storage,
+		"fallback", backup.Fallback,
+		"mirrors", backup.Mirrors,
 	)
 }
 
@@ -293,10 +415,164 @@ func (m *Manager) getNotifyProviders(cfg *config.ContainerConfig, backup config.
 	return cfg.Notify
 }
 
+// getNotifyOn returns the event groups to notify for a backup, preferring the
+// per-config notify-on over the container-level one
+func (m *Manager) getNotifyOn(cfg *config.ContainerConfig, backup config.BackupConfig) []string {
+	if backup.NotifyOn != nil {
+		return backup.NotifyOn
+	}
+	return cfg.NotifyOn
+}
+
+// acquireBackup takes the container's operation lock and, with
+// --max-concurrent-backups, one of the backup slots. The slot is taken second,
+// so a backup waiting for its container doesn't keep other containers waiting.
+func (m *Manager) acquireBackup(ctx context.Context, containerName string) (func(), error) {
+	release, err := m.opLocks.acquire(ctx, containerName, "backup")
+	if err != nil || m.backupSlots == nil {
+		return release, err
+	}
+
+	if !m.backupSlots.TryAcquire(1) {
+		slog.Info("waiting for a free backup slot", "container", containerName, "max_concurrent_backups", m.config.MaxConcurrentBackups)
+		if err := m.backupSlots.Acquire(ctx, 1); err != nil {
+			release()
+			return nil, err
+		}
+	}
+
+	return func() {
+		m.backupSlots.Release(1)
+		release()
+	}, nil
+}
+
+// failedAttempt is a failed run of a scheduled backup that is tried again.
+// It is reported as the final failure if the retry doesn't run.
+type failedAttempt struct {
+	event           notification.Event
+	backup          config.BackupConfig
+	notifyOn        []string
+	notifyProviders []string
+}
+
+// runScheduled runs a scheduled backup. A failed run is tried again up to
+// BackupRetries times, waiting BackupRetryDelay doubled with every attempt
+// but no longer than BackupRetryMaxDelay. A retry that doesn't run, because
+// the previous run is still going, the job was removed or the daemon shuts
+// down, ends the retries with the last failure.
+func (m *Manager) runScheduled(ctx context.Context, jobKey, containerID string, cfg *config.ContainerConfig, backup config.BackupConfig, backupType BackupType, attempt int) {
+	failed := m.runAttempt(ctx, containerID, cfg, backup, backupType, attempt, m.config.BackupRetries-attempt)
+	if failed == nil {
+		return
+	}
+
+	delay := retryDelay(m.config.BackupRetryDelay, m.config.BackupRetryMaxDelay, attempt)
+	slog.Warn("backup failed, retrying",
+		"container", cfg.ContainerName,
+		"config", backup.Name,
+		"attempt", attempt+1,
+		"retries", m.config.BackupRetries,
+		"delay", delay,
+	)
+	m.scheduler.RunAfter(jobKey, delay, func(retryCtx context.Context) {
+		// The container may have been removed or relabeled in the meantime
+		if !m.scheduler.HasJob(jobKey) {
+			m.giveUpRetry(retryCtx, jobKey, failed, "backup config no longer scheduled")
+			return
+		}
+		m.runScheduled(retryCtx, jobKey, containerID, cfg, backup, backupType, attempt+1)
+	}, func() {
+		m.giveUpRetry(ctx, jobKey, failed, "retry skipped or dropped")
+	})
+}
+
+// giveUpRetry reports a failed attempt whose retry doesn't run as the final
+// failure, counting it towards the failure streak and notifying it
+func (m *Manager) giveUpRetry(ctx context.Context, jobKey string, failed *failedAttempt, reason string) {
+	slog.Warn("not retrying failed backup",
+		"container", failed.event.ContainerName,
+		"config", failed.backup.Name,
+		"reason", reason,
+	)
+	streak := m.jobs.countRetried(jobKey)
+	m.notifyFinished(ctx, failed.event, streak, failed.backup, failed.notifyOn, failed.notifyProviders)
+}
+
+// notifyFinished sends the event of a finished run, holding back failures
+// below the config's notify-after-failures threshold
+func (m *Manager) notifyFinished(ctx context.Context, event notification.Event, streak int, backup config.BackupConfig, notifyOn, notifyProviders []string) {
+	if event, send := applyFailureThreshold(event, streak, backup.NotifyAfterFailures); send && notifiesEvent(notifyOn, event.Type) {
+		m.notify(ctx, event, notifyProviders)
+	}
+}
+
+// retryDelay returns the wait before retry number attempt+1
+func retryDelay(base, maxDelay time.Duration, attempt int) time.Duration {
+	delay := base
+	for range attempt {
+		if maxDelay > 0 && delay >= maxDelay {
+			break
+		}
+		delay *= 2
+	}
+	if maxDelay > 0 && delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay
+}
+
 // runBackup executes a backup for a specific container and backup config
 func (m *Manager) runBackup(ctx context.Context, containerID string, cfg *config.ContainerConfig, backup config.BackupConfig, backupType BackupType) {
-	startTime := time.Now()
+	m.runAttempt(ctx, containerID, cfg, backup, backupType, 0, 0)
+}
+
+// runAttempt executes a backup like runBackup, attempt counting the retries
+// before it. While retriesLeft is positive, a failure that may pass when tried
+// again is recorded without notifying or counting towards the failure streak,
+// and returned as retry.
+func (m *Manager) runAttempt(ctx context.Context, containerID string, cfg *config.ContainerConfig, backup config.BackupConfig, backupType BackupType, attempt, retriesLeft int) (retry *failedAttempt) {
 	notifyProviders := m.getNotifyProviders(cfg, backup)
+	notifyOn := m.getNotifyOn(cfg, backup)
+	jobKey := m.makeJobKey(containerID, backup.Name)
+
+	// Every finished run is recorded so the dashboard can show its last result and
+	// recent failures. Secret env values are masked once the container is known.
+	var secrets []string
+	finish := func(event notification.Event, category FailureCategory) {
+		if event.Type == notification.EventBackupFailed && retriesLeft > 0 && category.retryable() {
+			m.jobs.recordRetried(jobKey, event, category, secrets)
+			m.auditBackup(ctx, backup.Name, event, secrets)
+			retry = &failedAttempt{event: event, backup: backup, notifyOn: notifyOn, notifyProviders: notifyProviders}
+			return
+		}
+		streak := m.jobs.record(jobKey, event, category, secrets)
+		m.auditBackup(ctx, backup.Name, event, secrets)
+		m.notifyFinished(ctx, event, streak, backup, notifyOn, notifyProviders)
+	}
+
+	// Hold the container's operation lock through retention so neither can overlap a restore
+	m.jobs.queue(jobKey)
+	release, err := m.acquireBackup(ctx, cfg.ContainerName)
+	m.jobs.dequeue(jobKey)
+	if err != nil {
+		slog.Error("skipping backup, container is busy",
+			"container", cfg.ContainerName,
+			"config", backup.Name,
+			"error", err,
+		)
+		finish(notification.Event{
+			Type:          notification.EventBackupFailed,
+			ContainerName: cfg.ContainerName,
+			BackupType:    backup.BackupType,
+			Error:         err,
+			Timestamp:     time.Now(),
+		}, FailureBusy)
+		return
+	}
+	defer release()
+
+	startTime := time.Now()
 
 	slog.Info("starting backup",
 		"container", cfg.ContainerName,
@@ -304,146 +580,716 @@ func (m *Manager) runBackup(ctx context.Context, containerID string, cfg *config
 		"type", backup.BackupType,
 	)
 
-	container, err := m.dockerClient.GetContainer(ctx, containerID)
+	dockerClient, container, err := m.docker.GetContainer(ctx, containerID)
 	if err != nil {
 		slog.Error("failed to get container info for backup",
 			"container", cfg.ContainerName,
 			"error", err,
 		)
-		m.notify(ctx, notification.Event{
+		finish(notification.Event{
 			Type:          notification.EventBackupFailed,
 			ContainerName: cfg.ContainerName,
 			BackupType:    backup.BackupType,
 			Error:         err,
 			Timestamp:     time.Now(),
-		}, notifyProviders)
+		}, FailureContainer)
 		return
 	}
 
+	secrets = secretValues(container.Env)
+	opts := Options(backup.Options)
+
 	if !container.Running {
-		slog.Warn("container not running, skipping backup",
+		switch backup.WhenStopped {
+		case config.StoppedBackup:
+			slog.Info("container not running, backing it up while stopped",
+				"container", cfg.ContainerName,
+				"config", backup.Name,
+			)
+		case config.StoppedStart:
+			started, stop, err := m.startForBackup(ctx, dockerClient, container, backupType, opts)
+			if err != nil {
+				slog.Error("failed to start stopped container for backup",
+					"container", cfg.ContainerName,
+					"config", backup.Name,
+					"error", err,
+				)
+				finish(notification.Event{
+					Type:          notification.EventBackupFailed,
+					ContainerName: cfg.ContainerName,
+					BackupType:    backup.BackupType,
+					Error:         err,
+					Timestamp:     time.Now(),
+				}, FailureContainer)
+				return
+			}
+			defer stop()
+			container = started
+		default:
+			slog.Warn("container not running, skipping backup",
+				"container", cfg.ContainerName,
+			)
+			return
+		}
+	}
+
+	if err := backupType.Validate(container, opts); err != nil {
+		slog.Error("container validation failed",
+			"container", cfg.ContainerName,
+			"error", err,
+		)
+		finish(notification.Event{
+			Type:          notification.EventBackupFailed,
+			ContainerName: cfg.ContainerName,
+			BackupType:    backup.BackupType,
+			Error:         err,
+			Timestamp:     time.Now(),
+		}, FailureValidation)
+		return
+	}
+
+	for _, storagePool := range backup.Pools() {
+		if _, err := m.poolManager.GetForContainer(storagePool); err != nil {
+			slog.Error("failed to get storage",
+				"container", cfg.ContainerName,
+				"error", err,
+			)
+			finish(notification.Event{
+				Type:          notification.EventBackupFailed,
+				ContainerName: cfg.ContainerName,
+				BackupType:    backup.BackupType,
+				Error:         err,
+				Timestamp:     time.Now(),
+			}, FailureStorage)
+			return
+		}
+	}
+
+	tags, err := storage.ParseTags(opts.String(OptionS3Tags, ""))
+	if err != nil {
+		err = fmt.Errorf("invalid %s option: %w", OptionS3Tags, err)
+		slog.Error("invalid backup options",
 			"container", cfg.ContainerName,
+			"error", err,
 		)
+		finish(notification.Event{
+			Type:          notification.EventBackupFailed,
+			ContainerName: cfg.ContainerName,
+			BackupType:    backup.BackupType,
+			Error:         err,
+			Timestamp:     time.Now(),
+		}, FailureOptions)
 		return
 	}
 
-	if err := backupType.Validate(container); err != nil {
-		slog.Error("container validation failed",
+	if _, err := opts.Compression(); err != nil {
+		slog.Error("invalid backup options",
 			"container", cfg.ContainerName,
 			"error", err,
 		)
-		m.notify(ctx, notification.Event{
+		finish(notification.Event{
 			Type:          notification.EventBackupFailed,
 			ContainerName: cfg.ContainerName,
 			BackupType:    backup.BackupType,
 			Error:         err,
 			Timestamp:     time.Now(),
-		}, notifyProviders)
+		}, FailureOptions)
 		return
 	}
 
-	store, err := m.poolManager.GetForContainer(backup.Storage)
+	splitter, err := opts.Splitter(backupType)
 	if err != nil {
-		slog.Error("failed to get storage",
+		slog.Error("invalid backup options",
 			"container", cfg.ContainerName,
 			"error", err,
 		)
-		m.notify(ctx, notification.Event{
+		finish(notification.Event{
 			Type:          notification.EventBackupFailed,
 			ContainerName: cfg.ContainerName,
 			BackupType:    backup.BackupType,
 			Error:         err,
 			Timestamp:     time.Now(),
-		}, notifyProviders)
+		}, FailureOptions)
 		return
 	}
 
-	key := m.generateBackupKey(cfg.ContainerName, backup.Name, backupType.FileExtension(), time.Now())
+	dedup, err := configDeduplicator(backup, backupType)
+	if err != nil {
+		slog.Error("invalid backup options",
+			"container", cfg.ContainerName,
+			"error", err,
+		)
+		finish(notification.Event{
+			Type:          notification.EventBackupFailed,
+			ContainerName: cfg.ContainerName,
+			BackupType:    backup.BackupType,
+			Error:         err,
+			Timestamp:     time.Now(),
+		}, FailureOptions)
+		return
+	}
 
-	var buf bytes.Buffer
+	var blobs *poolBlobStore
+	if dedup != nil {
+		blobs, err = m.backupBlobStore(ctx, cfg, backup)
+		if err != nil {
+			slog.Error("failed to load blobs",
+				"container", cfg.ContainerName,
+				"error", err,
+			)
+			finish(notification.Event{
+				Type:          notification.EventBackupFailed,
+				ContainerName: cfg.ContainerName,
+				BackupType:    backup.BackupType,
+				Error:         err,
+				Timestamp:     time.Now(),
+			}, FailureStorage)
+			return
+		}
+	}
 
-	if err := backupType.Backup(ctx, container, m.dockerClient, &buf); err != nil {
-		slog.Error("backup failed",
+	// Only a run that got past skipping and validation is announced, every
+	// started event is followed by a completed or failed one. A retry
+	// continues the run whose start was already notified.
+	if attempt == 0 && notifiesEvent(notifyOn, notification.EventBackupStarted) {
+		m.notify(ctx, notification.Event{
+			Type:          notification.EventBackupStarted,
+			ContainerName: cfg.ContainerName,
+			BackupType:    backup.BackupType,
+			Timestamp:     startTime,
+		}, notifyProviders)
+	}
+
+	extension := backupType.FileExtension(opts)
+	switch {
+	case m.age.CanEncrypt():
+		extension += encryption.AgeExtension
+	case m.keyring != nil:
+		extension += encryption.Extension
+	}
+	now := time.Now()
+	key := m.generateBackupKey(backup.KeyPrefix(), cfg.ContainerName, backup.Name, extension, now)
+	if splitter != nil {
+		key = configKeyPrefix(cfg.ContainerName, backup)
+	}
+
+	if err := runHook(ctx, dockerClient, container, config.LabelPreHook, backup.PreHook); err != nil {
+		slog.Error("pre-hook failed, skipping backup",
 			"container", cfg.ContainerName,
+			"config", backup.Name,
 			"error", err,
 		)
-		m.notify(ctx, notification.Event{
+		finish(notification.Event{
 			Type:          notification.EventBackupFailed,
 			ContainerName: cfg.ContainerName,
 			BackupType:    backup.BackupType,
-			BackupKey:     key,
 			Error:         err,
 			Timestamp:     time.Now(),
-		}, notifyProviders)
+		}, FailureHook)
 		return
 	}
 
-	if err := store.Store(ctx, key, &buf); err != nil {
-		slog.Error("failed to store backup",
+	tracker := m.progress.Start(progress.OperationBackup, cfg.ContainerName, backup.Name, key)
+	defer tracker.Done()
+	trackerCtx := WithTempDir(progress.WithTracker(ctx, tracker), m.config.TempDir)
+	if blobs != nil {
+		trackerCtx = WithBlobStore(trackerCtx, blobs)
+	}
+
+	var archives []*archive
+	if splitter != nil {
+		archives, err = m.writeParts(trackerCtx, splitter, dockerClient, container, opts, tracker, func(part string) string {
+			return m.generateBackupKey(backup.KeyPrefix(), cfg.ContainerName, backup.Name+"/"+part, extension, now)
+		})
+	} else {
+		a := newArchive(key)
+		archives = append(archives, a)
+		err = m.writeBackup(tracker.Writer(a.writer()), func(w io.Writer) error {
+			return backupType.Backup(trackerCtx, container, dockerClient, opts, w)
+		})
+	}
+
+	// The post-hook runs after failed backups too, so it can undo the pre-hook
+	if hookErr := runHook(ctx, dockerClient, container, config.LabelPostHook, backup.PostHook); hookErr != nil {
+		slog.Warn("post-hook failed",
+			"container", cfg.ContainerName,
+			"config", backup.Name,
+			"error", hookErr,
+		)
+	}
+
+	if err != nil {
+		slog.Error("backup failed",
 			"container", cfg.ContainerName,
-			"key", key,
 			"error", err,
 		)
-		m.notify(ctx, notification.Event{
+		finish(notification.Event{
 			Type:          notification.EventBackupFailed,
 			ContainerName: cfg.ContainerName,
 			BackupType:    backup.BackupType,
 			BackupKey:     key,
 			Error:         err,
 			Timestamp:     time.Now(),
-		}, notifyProviders)
+		}, FailureBackup)
 		return
 	}
 
+	var keys []string
+	var size int64
+	var storagePool string
+	for _, a := range archives {
+		var category FailureCategory
+		storagePool, category, err = m.storeArchive(ctx, cfg, backup, a, storage.StoreOptions{Tags: tags, Tree: m.treeArchive(backupType, opts)})
+		if err != nil {
+			finish(notification.Event{
+				Type:          notification.EventBackupFailed,
+				ContainerName: cfg.ContainerName,
+				BackupType:    backup.BackupType,
+				BackupKey:     a.key,
+				Error:         err,
+				Timestamp:     time.Now(),
+			}, category)
+			return
+		}
+		keys = append(keys, a.key)
+		size += int64(a.data.Len())
+	}
+
 	duration := time.Since(startTime)
 	slog.Info("backup completed",
 		"container", cfg.ContainerName,
 		"config", backup.Name,
-		"key", key,
-		"size", buf.Len(),
+		"key", strings.Join(keys, ","),
+		"storage", storagePool,
+		"size", size,
 		"duration", duration,
 	)
 
-	m.notify(ctx, notification.Event{
+	finish(notification.Event{
 		Type:          notification.EventBackupCompleted,
 		ContainerName: cfg.ContainerName,
 		BackupType:    backup.BackupType,
-		BackupKey:     key,
-		Size:          int64(buf.Len()),
+		BackupKey:     strings.Join(keys, ", "),
+		Storage:       storagePool,
+		Size:          size,
 		Duration:      duration,
 		Timestamp:     time.Now(),
-	}, notifyProviders)
+	}, "")
+
+	// Retention applies to each pool of the chain and every mirror on its own,
+	// and to each part of a split backup
+	for _, pool := range backup.Pools() {
+		prefixes, err := m.retentionPrefixes(ctx, cfg, backup, pool)
+		if err != nil {
+			slog.Warn("retention enforcement failed",
+				"container", cfg.ContainerName,
+				"storage", m.poolManager.PoolName(pool),
+				"error", err,
+			)
+			continue
+		}
+		for _, prefix := range prefixes {
+			deleted, err := m.retention.Enforce(ctx, pool, prefix, retentionPolicy(backup))
+			if err != nil {
+				slog.Warn("retention enforcement failed",
+					"container", cfg.ContainerName,
+					"storage", m.poolManager.PoolName(pool),
+					"prefix", prefix,
+					"error", err,
+				)
+			} else if deleted > 0 {
+				slog.Info("retention policy applied",
+					"container", cfg.ContainerName,
+					"config", backup.Name,
+					"storage", m.poolManager.PoolName(pool),
+					"prefix", prefix,
+					"deleted", deleted,
+				)
+			}
+		}
+	}
+
+	// Blobs are pruned after retention so those of expired backups go with them
+	if dedup != nil {
+		deleted, err := m.pruneBlobs(ctx, cfg, backup, dedup)
+		if err != nil {
+			slog.Warn("blob pruning failed",
+				"container", cfg.ContainerName,
+				"config", backup.Name,
+				"error", err,
+			)
+		} else if deleted > 0 {
+			slog.Info("unreferenced blobs pruned",
+				"container", cfg.ContainerName,
+				"config", backup.Name,
+				"deleted", deleted,
+			)
+		}
+	}
+	return nil
+}
+
+// archive is a backup written to memory before it is stored
+type archive struct {
+	key  string
+	data bytes.Buffer
+	hash hash.Hash
+}
+
+func newArchive(key string) *archive {
+	return &archive{key: key, hash: sha256.New()}
+}
+
+func (a *archive) writer() io.Writer {
+	return io.MultiWriter(&a.data, a.hash)
+}
+
+func (a *archive) checksum() string {
+	return hex.EncodeToString(a.hash.Sum(nil))
+}
+
+// writeParts writes each part of a split backup into an archive of its own,
+// stored under the key keyFor returns
+func (m *Manager) writeParts(ctx context.Context, splitter Splitter, dockerClient *docker.Client, container *docker.ContainerInfo, opts Options, tracker *progress.Tracker, keyFor func(part string) string) ([]*archive, error) {
+	parts, err := splitter.Parts(ctx, container, dockerClient, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	archives := make([]*archive, 0, len(parts))
+	for _, part := range parts {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, "/\\") {
+			return nil, fmt.Errorf("part %q can't be stored under a key of its own", part)
+		}
+		a := newArchive(keyFor(part))
+		err := m.writeBackup(tracker.Writer(a.writer()), func(w io.Writer) error {
+			return splitter.BackupPart(ctx, container, dockerClient, opts, part, w)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("part %s: %w", part, err)
+		}
+		archives = append(archives, a)
+	}
+	return archives, nil
+}
 
-	prefix := fmt.Sprintf("%s/%s/", cfg.ContainerName, backup.Name)
-	deleted, err := m.retention.Enforce(ctx, backup.Storage, prefix, backup.Retention)
+// storeArchive stores a written backup in the config's storage chain,
+// verifies it, writes its checksum sidecar and mirrors it. It returns the
+// pool holding it, or the failure category of the step that failed.
+func (m *Manager) storeArchive(ctx context.Context, cfg *config.ContainerConfig, backup config.BackupConfig, a *archive, opts storage.StoreOptions) (string, FailureCategory, error) {
+	storagePool, err := m.storeBackup(ctx, backup, a.key, a.data.Bytes(), opts)
 	if err != nil {
-		slog.Warn("retention enforcement failed",
+		slog.Error("failed to store backup",
 			"container", cfg.ContainerName,
+			"key", a.key,
 			"error", err,
 		)
-	} else if deleted > 0 {
-		slog.Info("retention policy applied",
+		return "", FailureStorage, err
+	}
+
+	if m.config.VerifyBackups {
+		if err := m.verifyStoredBackup(ctx, storagePool, a.key, a.checksum()); err != nil {
+			slog.Error("backup verification failed",
+				"container", cfg.ContainerName,
+				"key", a.key,
+				"storage", storagePool,
+				"error", err,
+			)
+			return "", FailureVerify, err
+		}
+	}
+
+	// The backup is stored either way, a missing sidecar only means it can't be verified later
+	if err := m.storeChecksum(ctx, storagePool, a.key, a.checksum()); err != nil {
+		slog.Warn("failed to store backup checksum",
 			"container", cfg.ContainerName,
-			"config", backup.Name,
-			"deleted", deleted,
+			"key", a.key,
+			"storage", storagePool,
+			"error", err,
+		)
+	}
+
+	if err := m.mirrorBackup(ctx, backup, storagePool, a.key, a.data.Bytes(), a.checksum(), opts); err != nil {
+		if backup.MirrorMode != config.MirrorBestEffort {
+			slog.Error("failed to mirror backup",
+				"container", cfg.ContainerName,
+				"key", a.key,
+				"error", err,
+			)
+			return "", FailureStorage, err
+		}
+		slog.Warn("failed to mirror backup",
+			"container", cfg.ContainerName,
+			"key", a.key,
+			"error", err,
 		)
 	}
+
+	return storagePool, "", nil
+}
+
+// WaitNotifications waits for the notifications still being sent, each of
+// which gives up after 30 seconds
+func (m *Manager) WaitNotifications() {
+	m.notifying.Wait()
 }
 
 func (m *Manager) notify(_ context.Context, event notification.Event, providers []string) {
 	if len(providers) > 0 {
 		notifyCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		m.notifying.Add(1)
 		go func() {
+			defer m.notifying.Done()
 			defer cancel()
 			m.notifyMgr.Notify(notifyCtx, event, providers)
 		}()
 	}
 }
 
+// applyFailureThreshold decides whether a finished run is notified when the
+// config sets notify-after-failures. Failures are held back until the streak
+// reaches the threshold, and the success ending a notified streak is sent as a
+// recovery. Without a threshold every event is sent unchanged.
+func applyFailureThreshold(event notification.Event, streak, threshold int) (notification.Event, bool) {
+	if threshold <= 0 {
+		return event, true
+	}
+
+	switch event.Type {
+	case notification.EventBackupFailed:
+		event.Failures = streak
+		return event, streak >= threshold
+	case notification.EventBackupCompleted:
+		if streak >= threshold {
+			event.Type = notification.EventBackupRecovered
+			event.Failures = streak
+		}
+	}
+	return event, true
+}
+
+// notifiesEvent reports whether an event type is in the notify-on groups. A
+// recovery closes a failure alert, so it is sent with either completed or
+// failed.
+func notifiesEvent(groups []string, eventType notification.EventType) bool {
+	if groups == nil {
+		groups = config.DefaultNotifyOn
+	}
+
+	var wanted []string
+	switch eventType {
+	case notification.EventBackupStarted, notification.EventRestoreStarted:
+		wanted = []string{config.NotifyOnStarted}
+	case notification.EventBackupCompleted, notification.EventRestoreCompleted:
+		wanted = []string{config.NotifyOnCompleted}
+	case notification.EventBackupFailed, notification.EventRestoreFailed:
+		wanted = []string{config.NotifyOnFailed}
+	case notification.EventBackupRecovered:
+		wanted = []string{config.NotifyOnCompleted, config.NotifyOnFailed}
+	default:
+		return true
+	}
+
+	for _, group := range wanted {
+		if slices.Contains(groups, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeBackup runs backup, which writes an archive, into w, encrypting it to
+// the age recipients or with the current key when a keyring is configured.
+// The tracker counts the plaintext, not the stored bytes.
+func (m *Manager) writeBackup(w io.Writer, backup func(w io.Writer) error) error {
+	var enc io.WriteCloser
+	var err error
+	switch {
+	case m.age.CanEncrypt():
+		enc, err = m.age.Encrypt(w)
+	case m.keyring != nil:
+		enc, err = m.keyring.Encrypt(w)
+	default:
+		return backup(w)
+	}
+	if err != nil {
+		return err
+	}
+	if err := backup(enc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// treeArchive reports whether archives of backupType written with opts may be
+// stored unpacked, which encryption rules out
+func (m *Manager) treeArchive(backupType BackupType, opts Options) bool {
+	if m.age.CanEncrypt() || m.keyring != nil {
+		return false
+	}
+	t, ok := backupType.(TreeArchiver)
+	return ok && t.TreeArchive(opts)
+}
+
+// SetUploadRateLimit caps the upload speed of backups to bytesPerSecond,
+// shared by all uploads. It must be called before the manager is started.
+func (m *Manager) SetUploadRateLimit(bytesPerSecond int64) {
+	if bytesPerSecond > 0 {
+		m.uploadLimit = storage.NewRateLimiter(bytesPerSecond)
+	}
+}
+
+// storeBackup writes data to the first pool of the config's storage chain that
+// accepts it and returns that pool's name. Later pools are only tried when the
+// ones before them fail.
+func (m *Manager) storeBackup(ctx context.Context, backup config.BackupConfig, key string, data []byte, opts storage.StoreOptions) (string, error) {
+	opts.Size = int64(len(data))
+	chain := backup.StorageChain()
+	var errs []error
+	for i, name := range chain {
+		pool := m.poolManager.PoolName(name)
+		store, err := m.poolManager.GetForContainer(name)
+		if err == nil {
+			err = storage.Store(ctx, store, key, m.uploadLimit.Reader(ctx, bytes.NewReader(data)), opts)
+		}
+		if err == nil {
+			if i > 0 {
+				slog.Warn("backup stored in fallback storage pool", "key", key, "storage", pool, "primary", m.poolManager.PoolName(chain[0]))
+			}
+			return pool, nil
+		}
+
+		if len(chain) == 1 {
+			return "", err
+		}
+		errs = append(errs, fmt.Errorf("storage pool %q: %w", pool, err))
+		if ctx.Err() != nil {
+			break
+		}
+		if i < len(chain)-1 {
+			slog.Warn("failed to store backup, trying next storage pool", "key", key, "storage", pool, "error", err)
+		}
+	}
+	return "", errors.Join(errs...)
+}
+
+// mirrorBackup copies a stored backup and its checksum sidecar to the config's
+// mirror pools. In fail-fast mode it stops at the first mirror that fails,
+// otherwise every mirror is tried and the failures are returned together.
+func (m *Manager) mirrorBackup(ctx context.Context, backup config.BackupConfig, stored, key string, data []byte, checksum string, opts storage.StoreOptions) error {
+	opts.Size = int64(len(data))
+	var errs []error
+	for _, name := range backup.Mirrors {
+		pool := m.poolManager.PoolName(name)
+		if pool == stored {
+			continue
+		}
+
+		store, err := m.poolManager.GetForContainer(name)
+		if err == nil {
+			err = storage.Store(ctx, store, key, m.uploadLimit.Reader(ctx, bytes.NewReader(data)), opts)
+		}
+		if err == nil && m.config.VerifyBackups {
+			err = m.verifyStoredBackup(ctx, pool, key, checksum)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("storage pool %q: %w", pool, err))
+			if backup.MirrorMode != config.MirrorBestEffort || ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+
+		if err := writeChecksum(ctx, store, key, checksum); err != nil {
+			slog.Warn("failed to store backup checksum", "key", key, "storage", pool, "error", err)
+		}
+		slog.Debug("backup mirrored", "key", key, "storage", pool)
+	}
+	return errors.Join(errs...)
+}
+
+// verifyStoredBackup reads a backup back from the pool it was stored in and
+// compares its SHA-256 with checksum. A backup that doesn't match is deleted,
+// one that can't be read is left for retention. Backups the pool stored as a
+// tree are re-packed when read, so for them only reading succeeds is checked.
+func (m *Manager) verifyStoredBackup(ctx context.Context, pool, key, checksum string) error {
+	store, err := m.poolManager.GetForContainer(pool)
+	if err != nil {
+		return err
+	}
+
+	reader, err := store.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to read back stored backup: %w", err)
+	}
+	hash := sha256.New()
+	_, err = io.Copy(hash, reader)
+	_ = reader.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read back stored backup: %w", err)
+	}
+
+	if storage.IsTree(store, key) {
+		slog.Debug("tree-format backup read back", "key", key, "storage", pool)
+		return nil
+	}
+
+	if got := hex.EncodeToString(hash.Sum(nil)); got != checksum {
+		if err := store.Delete(ctx, key); err != nil {
+			slog.Warn("failed to delete corrupt backup", "key", key, "storage", pool, "error", err)
+		}
+		return fmt.Errorf("stored backup is corrupt: sha256 %s, expected %s", got, checksum)
+	}
+
+	slog.Debug("backup verified", "key", key, "storage", pool, "sha256", checksum)
+	return nil
+}
+
+// storeChecksum writes the checksum sidecar of a backup to the pool holding it
+func (m *Manager) storeChecksum(ctx context.Context, pool, key, checksum string) error {
+	store, err := m.poolManager.GetForContainer(pool)
+	if err != nil {
+		return err
+	}
+	return writeChecksum(ctx, store, key, checksum)
+}
+
+// writeChecksum stores checksum as the sidecar of key in store. Backups stored
+// as a tree get none, as they don't read back as the archive that was hashed.
+func writeChecksum(ctx context.Context, store storage.Storage, key, checksum string) error {
+	if storage.IsTree(store, key) {
+		return nil
+	}
+	content := storage.FormatChecksum(key, checksum)
+	return storage.Store(ctx, store, storage.ChecksumKey(key), bytes.NewReader(content), storage.StoreOptions{Size: int64(len(content))})
+}
+
+// openArchive returns the plaintext of a stored backup, decrypting it when it
+// starts with an age or encryption header. Plain backups pass through unchanged.
+func (m *Manager) openArchive(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	if encryption.IsAge(br) {
+		return m.age.Decrypt(br)
+	}
+
+	id, encrypted, err := encryption.PeekKeyID(br)
+	if err != nil {
+		return nil, err
+	}
+	if !encrypted {
+		return br, nil
+	}
+	if m.keyring == nil {
+		return nil, fmt.Errorf("backup is encrypted with key %q but no encryption keys are configured", id)
+	}
+	return m.keyring.Decrypt(br)
+}
+
 // generateBackupKey creates a unique key for the backup file
-// Format: container-name/config-name/YYYY-MM-DD/HHMMSS<extension>
-func (m *Manager) generateBackupKey(containerName, path string, extension string, t time.Time) string {
-	return fmt.Sprintf("%s/%s/%s/%s%s",
+// Format: [path-prefix/]container-name/config-name/YYYY-MM-DD/HHMMSS<extension>
+func (m *Manager) generateBackupKey(keyPrefix, containerName, path string, extension string, t time.Time) string {
+	return fmt.Sprintf("%s%s/%s/%s/%s%s",
+		keyPrefix,
 		containerName,
 		path,
 		t.Format("2006-01-02"),
@@ -452,6 +1298,12 @@ func (m *Manager) generateBackupKey(containerName, path string, extension string
 	)
 }
 
+// configKeyPrefix returns the key prefix, including the trailing slash, that
+// generateBackupKey stores the backups of a config below
+func configKeyPrefix(containerName string, backup config.BackupConfig) string {
+	return fmt.Sprintf("%s%s/%s/", backup.KeyPrefix(), containerName, backup.Name)
+}
+
 // findContainerConfig looks up a container config by container name
 func (m *Manager) findContainerConfig(ctx context.Context, containerName string) (*config.ContainerConfig, string, error) {
 	// First check tracked containers
@@ -466,18 +1318,18 @@ func (m *Manager) findContainerConfig(ctx context.Context, containerName string)
 	m.mu.RUnlock()
 
 	// If not found in tracked containers, try to find it in Docker
-	containers, err := m.dockerClient.ListContainers(ctx)
+	containers, _, err := m.docker.ListContainers(ctx, m.config.IncludeStoppedContainers)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to list containers: %w", err)
 	}
 
 	for _, container := range containers {
 		if container.Name == containerName {
-			cfg, err := config.ParseLabels("docker-backup", container.ID, container.Name, container.Labels)
+			cfg, err := m.parseLabels(&container)
 			if err != nil {
 				return nil, "", fmt.Errorf("failed to parse container labels: %w", err)
 			}
-			return cfg, container.ID, nil
+			return cfg, container.Key(), nil
 		}
 	}
 
@@ -494,37 +1346,69 @@ func (m *Manager) findBackupConfig(cfg *config.ContainerConfig, configName strin
 	return nil, fmt.Errorf("backup config %q not found in container %q", configName, cfg.ContainerName)
 }
 
-// getStorageFromBackupKey extracts config name from backup key and returns storage pool
-func (m *Manager) getStorageForBackupKey(cfg *config.ContainerConfig, backupKey string) (storage.Storage, error) {
+// getStorageForBackupKey extracts config name from backup key and returns the
+// storage pool holding it. For configs with a failover chain or mirrors the
+// pools are searched in order.
+func (m *Manager) getStorageForBackupKey(ctx context.Context, cfg *config.ContainerConfig, backupKey string) (storage.Storage, error) {
 	// Extract config name from key: container-name/config-name/date/time.ext
-	parts := strings.Split(backupKey, "/")
-	if len(parts) < 2 {
+	backup := backupConfigForKey(cfg, backupKey)
+
+	// A checksum sidecar is stored next to its backup
+	lookupKey := strings.TrimSuffix(backupKey, storage.ChecksumSuffix)
+	if backup == nil {
 		// Fall back to first backup config's storage
 		if len(cfg.Backups) > 0 {
 			return m.poolManager.GetForContainer(cfg.Backups[0].Storage)
 		}
-		return nil, fmt.Errorf("invalid backup key format")
+		return nil, fmt.Errorf("no backup config found for key %q", backupKey)
 	}
 
-	configPath := parts[1] // This is either config name or backup type
+	pools := backup.Pools()
+	if len(pools) == 1 {
+		return m.poolManager.GetForContainer(backup.Storage)
+	}
 
-	// Find matching backup config
-	for _, backup := range cfg.Backups {
-		keyPath := backup.BackupType
-		if backup.Name != "" {
-			keyPath = backup.Name
+	var firstErr error
+	for _, pool := range pools {
+		store, err := m.poolManager.GetForContainer(pool)
+		if err == nil {
+			var files []storage.BackupFile
+			files, err = store.List(ctx, lookupKey)
+			for _, f := range files {
+				if f.Key == lookupKey {
+					return store, nil
+				}
+			}
 		}
-		if keyPath == configPath {
-			return m.poolManager.GetForContainer(backup.Storage)
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("storage pool %q: %w", m.poolManager.PoolName(pool), err)
 		}
 	}
-
-	// Fall back to first backup config's storage
-	if len(cfg.Backups) > 0 {
-		return m.poolManager.GetForContainer(cfg.Backups[0].Storage)
+	if firstErr != nil {
+		return nil, firstErr
 	}
+	return nil, fmt.Errorf("backup %q not found in storage pools %v", backupKey, pools)
+}
 
-	return nil, fmt.Errorf("no backup config found for key %q", backupKey)
+// backupConfigForKey returns the backup config whose key path matches the
+// segment after the container name in key, or nil. Keys start with the
+// config's path prefix; the longest matching prefix wins.
+func backupConfigForKey(cfg *config.ContainerConfig, key string) *config.BackupConfig {
+	var match *config.BackupConfig
+	for i := range cfg.Backups {
+		rest, ok := strings.CutPrefix(key, cfg.Backups[i].KeyPrefix())
+		if !ok {
+			continue
+		}
+		parts := strings.Split(rest, "/")
+		if len(parts) < 2 || parts[1] != configKeyPath(cfg.Backups[i]) {
+			continue
+		}
+		if match == nil || len(cfg.Backups[i].PathPrefix) > len(match.PathPrefix) {
+			match = &cfg.Backups[i]
+		}
+	}
+	return match
 }
 
 // ListBackups lists all backups for a container by name.
@@ -534,31 +1418,43 @@ func (m *Manager) ListBackups(ctx context.Context, containerName string) ([]stor
 		return nil, err
 	}
 
-	// Collect backups from all storage pools used by this container
+	// Collect backups from all storage pools used by this container, below
+	// each path prefix its configs use. Mirrored copies share their key and
+	// are listed once.
+	type listing struct{ pool, prefix string }
 	var allBackups []storage.BackupFile
-	seenPools := make(map[string]bool)
+	seenListings := make(map[listing]bool)
+	seenKeys := make(map[string]bool)
 
 	for _, backup := range cfg.Backups {
-		storagePool := backup.Storage
-		if seenPools[storagePool] {
-			continue
-		}
-		seenPools[storagePool] = true
+		prefix := backup.KeyPrefix() + containerName + "/"
+		for _, storagePool := range backup.Pools() {
+			storagePool = m.poolManager.PoolName(storagePool)
+			if seenListings[listing{storagePool, prefix}] {
+				continue
+			}
+			seenListings[listing{storagePool, prefix}] = true
 
-		store, err := m.poolManager.GetForContainer(storagePool)
-		if err != nil {
-			slog.Warn("failed to get storage pool", "pool", storagePool, "error", err)
-			continue
-		}
+			store, err := m.poolManager.GetForContainer(storagePool)
+			if err != nil {
+				slog.Warn("failed to get storage pool", "pool", storagePool, "error", err)
+				continue
+			}
 
-		prefix := fmt.Sprintf("%s/", containerName)
-		backups, err := store.List(ctx, prefix)
-		if err != nil {
-			slog.Warn("failed to list backups", "pool", storagePool, "error", err)
-			continue
-		}
+			backups, err := store.List(ctx, prefix)
+			if err != nil {
+				slog.Warn("failed to list backups", "pool", storagePool, "error", err)
+				continue
+			}
 
-		allBackups = append(allBackups, backups...)
+			for _, b := range storage.WithoutChecksums(backups) {
+				if seenKeys[b.Key] {
+					continue
+				}
+				seenKeys[b.Key] = true
+				allBackups = append(allBackups, b)
+			}
+		}
 	}
 
 	return allBackups, nil
@@ -571,7 +1467,7 @@ func (m *Manager) GetBackup(ctx context.Context, containerName, backupKey string
 		return nil, err
 	}
 
-	store, err := m.getStorageForBackupKey(cfg, backupKey)
+	store, err := m.getStorageForBackupKey(ctx, cfg, backupKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get storage: %w", err)
 	}
@@ -579,32 +1475,20 @@ func (m *Manager) GetBackup(ctx context.Context, containerName, backupKey string
 	return store.Get(ctx, backupKey)
 }
 
-// RestoreBackup restores a specific backup to a container.
-func (m *Manager) RestoreBackup(ctx context.Context, containerName, backupKey string) error {
+// RestoreBackup restores a specific backup to a container. Overrides take
+// precedence over the backup config's options for this restore only.
+func (m *Manager) RestoreBackup(ctx context.Context, containerName, backupKey string, overrides map[string]string) error {
 	cfg, containerID, err := m.findContainerConfig(ctx, containerName)
 	if err != nil {
 		return err
 	}
 
-	// Extract config name from key to find backup type
-	parts := strings.Split(backupKey, "/")
-	if len(parts) < 2 {
+	if !strings.Contains(backupKey, "/") {
 		return fmt.Errorf("invalid backup key format")
 	}
-	configPath := parts[1]
-
-	var backupCfg *config.BackupConfig
-	for i := range cfg.Backups {
-		keyPath := cfg.Backups[i].BackupType
-		if cfg.Backups[i].Name != "" {
-			keyPath = cfg.Backups[i].Name
-		}
-		if keyPath == configPath {
-			backupCfg = &cfg.Backups[i]
-			break
-		}
-	}
 
+	// Find the backup type from the config the key belongs to
+	backupCfg := backupConfigForKey(cfg, backupKey)
 	if backupCfg == nil {
 		if len(cfg.Backups) > 0 {
 			backupCfg = &cfg.Backups[0]
@@ -618,12 +1502,71 @@ func (m *Manager) RestoreBackup(ctx context.Context, containerName, backupKey st
 		return fmt.Errorf("unknown backup type %q", backupCfg.BackupType)
 	}
 
-	store, err := m.getStorageForBackupKey(cfg, backupKey)
+	store, err := m.getStorageForBackupKey(ctx, cfg, backupKey)
 	if err != nil {
 		return fmt.Errorf("failed to get storage: %w", err)
 	}
 
-	container, err := m.dockerClient.GetContainer(ctx, containerID)
+	open := func(ctx context.Context) (io.ReadCloser, int64, error) {
+		reader, err := store.Get(ctx, backupKey)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to get backup: %w", err)
+		}
+		return reader, storedSize(ctx, store, backupKey), nil
+	}
+
+	return m.restoreStream(ctx, cfg, containerID, *backupCfg, backupType, overrides, backupKey, open)
+}
+
+// storedSize returns the size of key in store, or 0 if it can't be listed
+func storedSize(ctx context.Context, store storage.Storage, key string) int64 {
+	files, err := store.List(ctx, key)
+	if err != nil {
+		return 0
+	}
+	for _, f := range files {
+		if f.Key == key {
+			return f.Size
+		}
+	}
+	return 0
+}
+
+// restoreStream restores the archive returned by open into a container while
+// holding its operation lock. open also returns the archive's size in bytes,
+// 0 or less if unknown, which is the total of the restore's progress.
+// source identifies the archive in logs and notifications.
+func (m *Manager) restoreStream(ctx context.Context, cfg *config.ContainerConfig, containerID string, backupCfg config.BackupConfig, backupType BackupType, overrides map[string]string, source string, open func(ctx context.Context) (io.ReadCloser, int64, error)) (err error) {
+	containerName := cfg.ContainerName
+
+	if err := ValidateRestoreOverrides(overrides); err != nil {
+		return err
+	}
+
+	auditStart := time.Now()
+	var restored int64
+	defer func() {
+		result, message := auditResult(err)
+		m.auditRecord(audit.Record{
+			Operation: audit.OperationRestore,
+			Result:    result,
+			Actor:     audit.ActorFrom(ctx, ""),
+			Container: containerName,
+			Config:    backupCfg.Name,
+			Key:       source,
+			Size:      restored,
+			Duration:  time.Since(auditStart).Seconds(),
+			Error:     message,
+		})
+	}()
+
+	release, err := m.opLocks.acquire(ctx, cfg.ContainerName, "restore")
+	if err != nil {
+		return fmt.Errorf("failed to start restore: %w", err)
+	}
+	defer release()
+
+	dockerClient, container, err := m.docker.GetContainer(ctx, containerID)
 	if err != nil {
 		return fmt.Errorf("failed to get container info: %w", err)
 	}
@@ -632,59 +1575,147 @@ func (m *Manager) RestoreBackup(ctx context.Context, containerName, backupKey st
 		return fmt.Errorf("container %q is not running", containerName)
 	}
 
-	if err := backupType.Validate(container); err != nil {
+	opts := Options(backupCfg.Options)
+	if len(overrides) > 0 {
+		opts = Options(maps.Clone(backupCfg.Options))
+		if opts == nil {
+			opts = make(Options, len(overrides))
+		}
+		maps.Copy(opts, overrides)
+	}
+
+	if err := backupType.Validate(container, opts); err != nil {
 		return fmt.Errorf("container validation failed: %w", err)
 	}
 
-	reader, err := store.Get(ctx, backupKey)
+	tracker := m.progress.Start(progress.OperationRestore, cfg.ContainerName, backupCfg.Name, source)
+	defer tracker.Done()
+
+	stored, size, err := open(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get backup: %w", err)
+		return err
 	}
 	defer func() {
-		_ = reader.Close()
+		_ = stored.Close()
 	}()
+	tracker.SetTotal(size)
+
+	// Progress counts the bytes read of the stored archive, so it matches its size
+	reader, err := m.openArchive(tracker.Reader(stored))
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
 
 	startTime := time.Now()
-	slog.Info("starting restore", "container", containerName, "key", backupKey)
+	slog.Info("starting restore", "container", containerName, "key", source)
 
-	notifyProviders := m.getNotifyProviders(cfg, *backupCfg)
+	notifyProviders := m.getNotifyProviders(cfg, backupCfg)
+	notifyOn := m.getNotifyOn(cfg, backupCfg)
+	notify := func(event notification.Event) {
+		if notifiesEvent(notifyOn, event.Type) {
+			m.notify(ctx, event, notifyProviders)
+		}
+	}
 
-	if err := backupType.Restore(ctx, container, m.dockerClient, reader); err != nil {
-		m.notify(ctx, notification.Event{
+	notify(notification.Event{
+		Type:          notification.EventRestoreStarted,
+		ContainerName: containerName,
+		BackupType:    backupCfg.BackupType,
+		BackupKey:     source,
+		Timestamp:     startTime,
+	})
+
+	restoreCtx := m.restoreBlobStore(WithTempDir(progress.WithTracker(ctx, tracker), m.config.TempDir), cfg, backupCfg, backupType)
+	counted, counter := countRestored(reader)
+	err = backupType.Restore(restoreCtx, container, dockerClient, opts, counted)
+	restored = counter.Bytes()
+	if err != nil {
+		notify(notification.Event{
 			Type:          notification.EventRestoreFailed,
 			ContainerName: containerName,
 			BackupType:    backupCfg.BackupType,
-			BackupKey:     backupKey,
+			BackupKey:     source,
 			Error:         err,
 			Timestamp:     time.Now(),
-		}, notifyProviders)
+		})
 		return fmt.Errorf("restore failed: %w", err)
 	}
 
 	duration := time.Since(startTime)
-	slog.Info("restore completed", "container", containerName, "key", backupKey, "duration", duration)
+	slog.Info("restore completed", "container", containerName, "key", source, "bytes", restored, "duration", duration)
 
-	m.notify(ctx, notification.Event{
+	notify(notification.Event{
 		Type:          notification.EventRestoreCompleted,
 		ContainerName: containerName,
 		BackupType:    backupCfg.BackupType,
-		BackupKey:     backupKey,
+		BackupKey:     source,
+		Size:          restored,
 		Duration:      duration,
 		Timestamp:     time.Now(),
-	}, notifyProviders)
+	})
 
 	return nil
 }
 
+// restoreCounter counts the bytes a restore gets out of an archive by
+// decompressing a copy of the stream the backup type reads. Content a volume
+// restore takes from deduplicated blobs is not part of the archive.
+type restoreCounter struct {
+	pw   *io.PipeWriter
+	done chan struct{}
+	n    int64
+}
+
+// countRestored returns a reader passing r through to a restoreCounter
+func countRestored(r io.Reader) (io.Reader, *restoreCounter) {
+	pr, pw := io.Pipe()
+	c := &restoreCounter{pw: pw, done: make(chan struct{})}
+	go func() {
+		defer close(c.done)
+		if decompressor, err := compression.NewReader(pr); err == nil {
+			c.n, _ = io.Copy(io.Discard, decompressor)
+			_ = decompressor.Close()
+		}
+		// Keep reading whatever the decompressor left, so the restore never blocks
+		_, _ = io.Copy(io.Discard, pr)
+	}()
+	return io.TeeReader(r, pw), c
+}
+
+// Bytes stops counting and returns the decompressed size of what was read
+func (c *restoreCounter) Bytes() int64 {
+	_ = c.pw.Close()
+	<-c.done
+	return c.n
+}
+
 // DeleteBackup deletes a specific backup for a container.
-func (m *Manager) DeleteBackup(ctx context.Context, containerName, backupKey string) error {
+func (m *Manager) DeleteBackup(ctx context.Context, containerName, backupKey string) (err error) {
+	configName, _, _ := strings.Cut(strings.TrimPrefix(backupKey, containerName+"/"), "/")
+	defer func() {
+		result, message := auditResult(err)
+		m.auditRecord(audit.Record{
+			Operation: audit.OperationDelete,
+			Result:    result,
+			Actor:     audit.ActorFrom(ctx, ""),
+			Container: containerName,
+			Config:    configName,
+			Key:       backupKey,
+			Error:     message,
+		})
+	}()
+
 	cfg, _, err := m.findContainerConfig(ctx, containerName)
 	if err != nil {
 		return err
 	}
+	backup := backupConfigForKey(cfg, backupKey)
+	if backup != nil {
+		configName = backup.Name
+	}
 
 	// Get storage for this backup key
-	store, err := m.getStorageForBackupKey(cfg, backupKey)
+	store, err := m.getStorageForBackupKey(ctx, cfg, backupKey)
 	if err != nil {
 		return fmt.Errorf("failed to get storage: %w", err)
 	}
@@ -693,11 +1724,43 @@ func (m *Manager) DeleteBackup(ctx context.Context, containerName, backupKey str
 	if err := store.Delete(ctx, backupKey); err != nil {
 		return fmt.Errorf("failed to delete backup: %w", err)
 	}
+	if err := store.Delete(ctx, storage.ChecksumKey(backupKey)); err != nil {
+		slog.Warn("failed to delete backup checksum", "container", containerName, "key", backupKey, "error", err)
+	}
+
+	// Mirrored copies go as well, otherwise the backup would still be listed
+	if backup != nil {
+		for _, name := range backup.Mirrors {
+			if err := m.deleteCopy(ctx, name, backupKey); err != nil {
+				slog.Warn("failed to delete mirrored backup", "container", containerName, "key", backupKey, "storage", m.poolManager.PoolName(name), "error", err)
+			}
+		}
+	}
 
 	slog.Info("backup deleted", "container", containerName, "key", backupKey)
 	return nil
 }
 
+// deleteCopy deletes key and its checksum sidecar from a pool if it holds them
+func (m *Manager) deleteCopy(ctx context.Context, pool, key string) error {
+	store, err := m.poolManager.GetForContainer(pool)
+	if err != nil {
+		return err
+	}
+	files, err := store.List(ctx, key)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if f.Key == key || f.Key == storage.ChecksumKey(key) {
+			if err := store.Delete(ctx, f.Key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // TriggerBackup triggers an immediate backup for a container by name.
 // If configName is empty and there's only one backup config, it uses that.
 // If configName is empty and there are multiple configs, it runs all of them.
@@ -741,8 +1804,11 @@ type BackupConfigInfo struct {
 	Name       string
 	BackupType string
 	Schedule   string
-	Retention  int
+	Retention  string // Count or age, e.g. "7" or "90d"
 	Storage    string
+	Fallback   []string
+	Mirrors    []string
+	PathPrefix string // Path prepended to the config's backup keys, if any
 }
 
 // ContainerInfo contains information about a container for the dashboard
@@ -772,8 +1838,11 @@ func (m *Manager) GetContainers() []ContainerInfo {
 				Name:       backup.Name,
 				BackupType: backup.BackupType,
 				Schedule:   backup.Schedule,
-				Retention:  backup.Retention,
+				Retention:  backup.RetentionString(),
 				Storage:    backup.Storage,
+				Fallback:   backup.Fallback,
+				Mirrors:    backup.Mirrors,
+				PathPrefix: backup.PathPrefix,
 			})
 		}
 
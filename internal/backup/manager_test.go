@@ -0,0 +1,629 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/shyim/docker-backup/internal/config"
+	"github.com/shyim/docker-backup/internal/docker"
+	"github.com/shyim/docker-backup/internal/encryption"
+	"github.com/shyim/docker-backup/internal/notification"
+	"github.com/shyim/docker-backup/internal/progress"
+	"github.com/shyim/docker-backup/internal/retention"
+	"github.com/shyim/docker-backup/internal/scheduler"
+	"github.com/shyim/docker-backup/internal/storage"
+	"github.com/shyim/docker-backup/internal/storages/local"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memStorageType creates memStorage pools and keeps them by pool name so tests can inspect them
+type memStorageType struct{}
+
+var memPools = map[string]*memStorage{}
+
+func (memStorageType) Name() string { return "mem-test" }
+func (memStorageType) Create(poolName string, options map[string]string) (storage.Storage, error) {
+	s := &memStorage{objects: map[string][]byte{}, fail: options["fail"] == "true"}
+	memPools[poolName] = s
+	return s, nil
+}
+
+func init() {
+	storage.Register(memStorageType{})
+}
+
+func newFailoverManager(t *testing.T, failing ...string) *Manager {
+	t.Helper()
+	pools := map[string]*config.StoragePool{
+		"s3":    {Name: "s3", Type: "mem-test", Options: map[string]string{}},
+		"local": {Name: "local", Type: "mem-test", Options: map[string]string{}},
+		"nfs":   {Name: "nfs", Type: "mem-test", Options: map[string]string{}},
+	}
+	for _, name := range failing {
+		pools[name].Options["fail"] = "true"
+	}
+	pm, err := storage.NewPoolManager(pools, "s3")
+	require.NoError(t, err)
+	return &Manager{poolManager: pm}
+}
+
+func TestStoreBackup_Failover(t *testing.T) {
+	ctx := context.Background()
+	backup := config.BackupConfig{Name: "db", Storage: "s3", Fallback: []string{"local", "nfs"}}
+
+	m := newFailoverManager(t)
+	pool, err := m.storeBackup(ctx, backup, "app/db/1.sql.zst", []byte("dump"), storage.StoreOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "s3", pool)
+	assert.Len(t, memPools["s3"].objects, 1)
+	assert.Empty(t, memPools["local"].objects, "fallback pools are not written when the primary works")
+
+	m = newFailoverManager(t, "s3")
+	pool, err = m.storeBackup(ctx, backup, "app/db/1.sql.zst", []byte("dump"), storage.StoreOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "local", pool)
+	assert.Equal(t, []byte("dump"), memPools["local"].objects["app/db/1.sql.zst"])
+	assert.Empty(t, memPools["nfs"].objects)
+
+	m = newFailoverManager(t, "s3", "local", "nfs")
+	_, err = m.storeBackup(ctx, backup, "app/db/1.sql.zst", []byte("dump"), storage.StoreOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `storage pool "s3"`)
+	assert.Contains(t, err.Error(), `storage pool "nfs"`)
+
+	// Without a chain the error is passed through unchanged
+	m = newFailoverManager(t, "s3")
+	_, err = m.storeBackup(ctx, config.BackupConfig{}, "app/db/1.sql.zst", []byte("dump"), storage.StoreOptions{})
+	assert.EqualError(t, err, "storage unreachable")
+}
+
+func TestMirrorBackup(t *testing.T) {
+	ctx := context.Background()
+	checksum := strings.Repeat("ab", 32)
+	backup := config.BackupConfig{Name: "db", Storage: "s3", Mirrors: []string{"local", "nfs"}}
+
+	m := newFailoverManager(t)
+	m.config = config.New()
+	require.NoError(t, m.mirrorBackup(ctx, backup, "s3", "app/db/1.sql.zst", []byte("dump"), checksum, storage.StoreOptions{}))
+	assert.Empty(t, memPools["s3"].objects, "the pool that stored the backup is skipped")
+	for _, pool := range []string{"local", "nfs"} {
+		assert.Equal(t, []byte("dump"), memPools[pool].objects["app/db/1.sql.zst"], pool)
+		assert.Contains(t, memPools[pool].objects, "app/db/1.sql.zst.sha256", pool)
+	}
+
+	require.NoError(t, m.deleteCopy(ctx, "nfs", "app/db/1.sql.zst"))
+	assert.Empty(t, memPools["nfs"].objects)
+	require.NoError(t, m.deleteCopy(ctx, "nfs", "app/db/1.sql.zst"), "a missing copy is no error")
+
+	// Fail-fast stops at the first failing mirror
+	m = newFailoverManager(t, "local")
+	m.config = config.New()
+	err := m.mirrorBackup(ctx, backup, "s3", "app/db/1.sql.zst", []byte("dump"), checksum, storage.StoreOptions{})
+	assert.ErrorContains(t, err, `storage pool "local"`)
+	assert.Empty(t, memPools["nfs"].objects)
+
+	// Best-effort tries every mirror
+	backup.MirrorMode = config.MirrorBestEffort
+	m = newFailoverManager(t, "local")
+	m.config = config.New()
+	err = m.mirrorBackup(ctx, backup, "s3", "app/db/1.sql.zst", []byte("dump"), checksum, storage.StoreOptions{})
+	assert.ErrorContains(t, err, `storage pool "local"`)
+	assert.Equal(t, []byte("dump"), memPools["nfs"].objects["app/db/1.sql.zst"])
+}
+
+func TestGetStorageForBackupKey_SearchesMirrors(t *testing.T) {
+	ctx := context.Background()
+	m := newFailoverManager(t)
+	memPools["nfs"].objects["app/db/2026-01-01/000000.sql.zst"] = []byte("mirror")
+
+	cfg := &config.ContainerConfig{ContainerName: "app", Backups: []config.BackupConfig{
+		{Name: "db", BackupType: "postgres", Storage: "s3", Mirrors: []string{"nfs"}},
+	}}
+
+	store, err := m.getStorageForBackupKey(ctx, cfg, "app/db/2026-01-01/000000.sql.zst")
+	require.NoError(t, err)
+	reader, err := store.Get(ctx, "app/db/2026-01-01/000000.sql.zst")
+	require.NoError(t, err)
+	got, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "mirror", string(got))
+}
+
+func TestGetStorageForBackupKey_SearchesChain(t *testing.T) {
+	ctx := context.Background()
+	m := newFailoverManager(t)
+	memPools["s3"].objects["app/db/2026-01-02/000000.sql.zst"] = []byte("primary")
+	memPools["local"].objects["app/db/2026-01-01/000000.sql.zst"] = []byte("fallback")
+
+	cfg := &config.ContainerConfig{ContainerName: "app", Backups: []config.BackupConfig{
+		{Name: "db", BackupType: "postgres", Storage: "s3", Fallback: []string{"local"}},
+	}}
+
+	for key, want := range map[string]string{
+		"app/db/2026-01-01/000000.sql.zst": "fallback",
+		"app/db/2026-01-02/000000.sql.zst": "primary",
+	} {
+		store, err := m.getStorageForBackupKey(ctx, cfg, key)
+		require.NoError(t, err)
+		reader, err := store.Get(ctx, key)
+		require.NoError(t, err)
+		got, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, want, string(got))
+	}
+
+	// A checksum sidecar is found in the pool of its backup
+	checksum := strings.Repeat("ab", 32)
+	require.NoError(t, m.storeChecksum(ctx, "local", "app/db/2026-01-01/000000.sql.zst", checksum))
+	store, err := m.getStorageForBackupKey(ctx, cfg, "app/db/2026-01-01/000000.sql.zst.sha256")
+	require.NoError(t, err)
+	reader, err := store.Get(ctx, "app/db/2026-01-01/000000.sql.zst.sha256")
+	require.NoError(t, err)
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, checksum+"  000000.sql.zst\n", string(content))
+
+	_, err = m.getStorageForBackupKey(ctx, cfg, "app/db/2026-01-03/000000.sql.zst")
+	assert.ErrorContains(t, err, "not found")
+}
+
+func TestBackupConfigForKey(t *testing.T) {
+	cfg := &config.ContainerConfig{Backups: []config.BackupConfig{
+		{BackupType: "postgres"},
+		{Name: "files", BackupType: "volume"},
+	}}
+
+	assert.Equal(t, "postgres", backupConfigForKey(cfg, "db/postgres/2026-01-01/000000.sql.zst").BackupType)
+	assert.Equal(t, "files", backupConfigForKey(cfg, "db/files/2026-01-01/000000.tar.zst").Name)
+	assert.Nil(t, backupConfigForKey(cfg, "db/other/2026-01-01/000000.tar.zst"))
+	assert.Nil(t, backupConfigForKey(cfg, "invalid"))
+}
+
+func TestBackupConfigForKey_PathPrefix(t *testing.T) {
+	cfg := &config.ContainerConfig{ContainerName: "db", Backups: []config.BackupConfig{
+		{Name: "dump", BackupType: "postgres", PathPrefix: "prod/myapp"},
+		{Name: "files", BackupType: "volume"},
+	}}
+
+	assert.Equal(t, "dump", backupConfigForKey(cfg, "prod/myapp/db/dump/2026-01-01/000000.sql.zst").Name)
+	assert.Equal(t, "files", backupConfigForKey(cfg, "db/files/2026-01-01/000000.tar.zst").Name)
+	assert.Nil(t, backupConfigForKey(cfg, "db/dump/2026-01-01/000000.sql.zst"), "keys of prefixed configs start with the prefix")
+	assert.Nil(t, backupConfigForKey(cfg, "staging/myapp/db/dump/2026-01-01/000000.sql.zst"))
+
+	m := &Manager{}
+	key := m.generateBackupKey(cfg.Backups[0].KeyPrefix(), "db", "dump", ".sql.zst", time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	assert.Equal(t, "prod/myapp/db/dump/2026-01-02/030405.sql.zst", key)
+	assert.True(t, strings.HasPrefix(key, configKeyPrefix(cfg.ContainerName, cfg.Backups[0])))
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, time.Minute, retryDelay(time.Minute, 30*time.Minute, 0))
+	assert.Equal(t, 2*time.Minute, retryDelay(time.Minute, 30*time.Minute, 1))
+	assert.Equal(t, 16*time.Minute, retryDelay(time.Minute, 30*time.Minute, 4))
+	assert.Equal(t, 30*time.Minute, retryDelay(time.Minute, 30*time.Minute, 5))
+	assert.Equal(t, 30*time.Minute, retryDelay(time.Minute, 30*time.Minute, 100))
+	assert.Equal(t, 8*time.Minute, retryDelay(time.Minute, 0, 3))
+}
+
+// treeBackupType writes archives that may be stored unpacked
+type treeBackupType struct {
+	checkBackupType
+}
+
+func (treeBackupType) TreeArchive(Options) bool { return true }
+
+// treeArchiveBackup writes a zstd-compressed tar holding one file
+type treeArchiveBackup struct {
+	treeBackupType
+}
+
+func init() {
+	Register(treeArchiveBackup{})
+}
+
+func (treeArchiveBackup) Name() string                 { return "tree-archive-test" }
+func (treeArchiveBackup) FileExtension(Options) string { return ".tar.zst" }
+
+func (treeArchiveBackup) Backup(_ context.Context, _ *docker.ContainerInfo, _ *docker.Client, _ Options, w io.Writer) error {
+	zw, err := zstd.NewWriter(w)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(zw)
+	if err := tw.WriteHeader(&tar.Header{Name: "data/hello.txt", Mode: 0644, Size: 5, Typeflag: tar.TypeReg}); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(tw, "hello"); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func TestRunBackup_VerifyTreePool(t *testing.T) {
+	dir := t.TempDir()
+	pm, err := storage.NewPoolManager(map[string]*config.StoragePool{
+		"debug": {Name: "debug", Type: "local", Options: map[string]string{"path": dir, "format": local.FormatTree}},
+	}, "debug")
+	require.NoError(t, err)
+	cfg := config.New()
+	cfg.VerifyBackups = true
+	m := NewManager(newFakeDocker(t), pm, nil, retention.New(pm), nil, nil, nil, cfg)
+	backup := config.BackupConfig{Name: "files", BackupType: "tree-archive-test", Storage: "debug", Retention: 7}
+
+	m.runBackup(context.Background(), "app", &config.ContainerConfig{ContainerName: "app"}, backup, treeArchiveBackup{})
+	require.Empty(t, m.jobs.failureHistory(m.makeJobKey("app", "files")), "re-packed trees don't fail verification")
+
+	store, err := pm.GetForContainer("debug")
+	require.NoError(t, err)
+	files, err := store.List(context.Background(), "app/files/")
+	require.NoError(t, err)
+	require.Len(t, files, 1, "the tree is kept and has no checksum file")
+	assert.True(t, storage.IsTree(store, files[0].Key))
+	assert.DirExists(t, filepath.Join(dir, files[0].Key))
+	assert.NoFileExists(t, filepath.Join(dir, storage.ChecksumKey(files[0].Key)))
+}
+
+func TestManager_TreeArchive(t *testing.T) {
+	m := &Manager{}
+	assert.True(t, m.treeArchive(treeBackupType{}, nil))
+	assert.False(t, m.treeArchive(checkBackupType{}, nil), "backup types opt in")
+
+	k, err := encryption.ParseKeyring("a="+newTestKey(t), "")
+	require.NoError(t, err)
+	m.keyring = k
+	assert.False(t, m.treeArchive(treeBackupType{}, nil), "encrypted archives can't be unpacked")
+}
+
+// recordingNotifier remembers the events sent to it
+type recordingNotifier struct {
+	mu     sync.Mutex
+	events []notification.Event
+}
+
+func (n *recordingNotifier) Name() string { return "recording" }
+
+func (n *recordingNotifier) Send(_ context.Context, event notification.Event) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events = append(n.events, event)
+	return nil
+}
+
+func (n *recordingNotifier) sent() []notification.EventType {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var types []notification.EventType
+	for _, event := range n.events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+// retryTest runs a scheduled backup of app that always fails, with two retries
+type retryTest struct {
+	m        *Manager
+	sched    *scheduler.Scheduler
+	notifier *recordingNotifier
+	jobKey   string
+}
+
+func newRetryTest(t *testing.T, delay time.Duration) *retryTest {
+	pm := newFailoverManager(t).poolManager
+	sched := scheduler.New(context.Background())
+	cfg := config.New()
+	cfg.BackupRetries = 2
+	cfg.BackupRetryDelay = delay
+	notifier := &recordingNotifier{}
+	notifyMgr := notification.NewManager()
+	notifyMgr.AddNotifier("rec", notifier)
+	m := NewManager(newFakeDocker(t), pm, sched, retention.New(pm), notifyMgr, nil, nil, cfg)
+
+	jobKey := m.makeJobKey("app", "files")
+	require.NoError(t, sched.AddJob(jobKey, "0 3 * * *", func(ctx context.Context) {}))
+	return &retryTest{m: m, sched: sched, notifier: notifier, jobKey: jobKey}
+}
+
+func (rt *retryTest) run() {
+	containerCfg := &config.ContainerConfig{ContainerName: "app", Notify: []string{"rec"}, NotifyOn: []string{config.NotifyOnStarted, config.NotifyOnFailed}}
+	backup := config.BackupConfig{Name: "files", BackupType: "blocking", Storage: "s3"}
+	failing := &blockingBackup{release: make(chan struct{})}
+	close(failing.release)
+	rt.m.runScheduled(context.Background(), rt.jobKey, "app", containerCfg, backup, failing, 0)
+}
+
+func TestRunScheduled_Retries(t *testing.T) {
+	rt := newRetryTest(t, time.Millisecond)
+	rt.run()
+
+	// Only the last attempt counts towards the failure streak and is notified
+	assert.Eventually(t, func() bool { return rt.m.jobs.streak(rt.jobKey) == 1 }, 5*time.Second, time.Millisecond)
+	require.NoError(t, rt.sched.Shutdown(time.Second))
+	rt.m.notifying.Wait()
+	assert.Len(t, rt.m.jobs.failureHistory(rt.jobKey), 3)
+	assert.Equal(t, 1, rt.m.jobs.streak(rt.jobKey))
+	assert.Equal(t, []notification.EventType{notification.EventBackupStarted, notification.EventBackupFailed}, rt.notifier.sent())
+}
+
+func TestRunScheduled_RetryDroppedOnShutdown(t *testing.T) {
+	rt := newRetryTest(t, time.Hour)
+	rt.run()
+	assert.Equal(t, 0, rt.m.jobs.streak(rt.jobKey))
+
+	require.NoError(t, rt.sched.Shutdown(time.Second))
+	rt.m.notifying.Wait()
+	assert.Len(t, rt.m.jobs.failureHistory(rt.jobKey), 1)
+	assert.Equal(t, 1, rt.m.jobs.streak(rt.jobKey), "the dropped retry ends the retries")
+	assert.Equal(t, []notification.EventType{notification.EventBackupStarted, notification.EventBackupFailed}, rt.notifier.sent())
+}
+
+func TestRunScheduled_RetryOfRemovedJob(t *testing.T) {
+	rt := newRetryTest(t, 20*time.Millisecond)
+	rt.run()
+	rt.sched.RemoveJob(rt.jobKey)
+
+	assert.Eventually(t, func() bool { return rt.m.jobs.streak(rt.jobKey) == 1 }, 5*time.Second, time.Millisecond)
+	require.NoError(t, rt.sched.Shutdown(time.Second))
+	rt.m.notifying.Wait()
+	assert.Len(t, rt.m.jobs.failureHistory(rt.jobKey), 1, "the retry didn't run")
+	assert.Equal(t, []notification.EventType{notification.EventBackupStarted, notification.EventBackupFailed}, rt.notifier.sent())
+}
+
+func TestRunScheduled_RetrySkippedWhileRunning(t *testing.T) {
+	rt := newRetryTest(t, time.Millisecond)
+
+	// Another run of the config holds its overlap lock when the retry is due
+	started, release := make(chan struct{}), make(chan struct{})
+	rt.sched.RunAfter(rt.jobKey, 0, func(ctx context.Context) {
+		close(started)
+		<-release
+	}, nil)
+	<-started
+	rt.run()
+
+	assert.Eventually(t, func() bool { return rt.m.jobs.streak(rt.jobKey) == 1 }, 5*time.Second, time.Millisecond)
+	close(release)
+	require.NoError(t, rt.sched.Shutdown(time.Second))
+	rt.m.notifying.Wait()
+	assert.Len(t, rt.m.jobs.failureHistory(rt.jobKey), 1, "the retry didn't run")
+	assert.Equal(t, []notification.EventType{notification.EventBackupStarted, notification.EventBackupFailed}, rt.notifier.sent())
+}
+
+func TestApplyFailureThreshold(t *testing.T) {
+	failed := notification.Event{Type: notification.EventBackupFailed}
+	completed := notification.Event{Type: notification.EventBackupCompleted}
+
+	// Without a threshold every event is sent unchanged
+	event, send := applyFailureThreshold(failed, 1, 0)
+	assert.True(t, send)
+	assert.Equal(t, failed, event)
+	event, send = applyFailureThreshold(completed, 4, 0)
+	assert.True(t, send)
+	assert.Equal(t, notification.EventBackupCompleted, event.Type)
+
+	for streak, wantSend := range map[int]bool{1: false, 2: false, 3: true, 4: true} {
+		event, send := applyFailureThreshold(failed, streak, 3)
+		assert.Equal(t, wantSend, send, "streak %d", streak)
+		assert.Equal(t, streak, event.Failures)
+	}
+
+	// Ending a streak below the threshold closes no alert
+	event, send = applyFailureThreshold(completed, 2, 3)
+	assert.True(t, send)
+	assert.Equal(t, notification.EventBackupCompleted, event.Type)
+
+	event, send = applyFailureThreshold(completed, 3, 3)
+	assert.True(t, send)
+	assert.Equal(t, notification.EventBackupRecovered, event.Type)
+	assert.Equal(t, 3, event.Failures)
+}
+
+func TestGetNotifyOn(t *testing.T) {
+	m := &Manager{}
+	cfg := &config.ContainerConfig{NotifyOn: []string{config.NotifyOnFailed}}
+
+	assert.Equal(t, []string{config.NotifyOnFailed}, m.getNotifyOn(cfg, config.BackupConfig{}))
+	assert.Equal(t, []string{config.NotifyOnStarted}, m.getNotifyOn(cfg, config.BackupConfig{NotifyOn: []string{config.NotifyOnStarted}}))
+	assert.Nil(t, m.getNotifyOn(&config.ContainerConfig{}, config.BackupConfig{}))
+}
+
+func TestNotifiesEvent(t *testing.T) {
+	// The default keeps the events sent before notify-on existed
+	assert.False(t, notifiesEvent(nil, notification.EventBackupStarted))
+	assert.False(t, notifiesEvent(nil, notification.EventRestoreStarted))
+	assert.True(t, notifiesEvent(nil, notification.EventBackupCompleted))
+	assert.True(t, notifiesEvent(nil, notification.EventBackupFailed))
+	assert.True(t, notifiesEvent(nil, notification.EventRestoreCompleted))
+	assert.True(t, notifiesEvent(nil, notification.EventRestoreFailed))
+
+	failedOnly := []string{config.NotifyOnFailed}
+	assert.True(t, notifiesEvent(failedOnly, notification.EventBackupFailed))
+	assert.True(t, notifiesEvent(failedOnly, notification.EventRestoreFailed))
+	assert.False(t, notifiesEvent(failedOnly, notification.EventBackupCompleted))
+	assert.True(t, notifiesEvent(failedOnly, notification.EventBackupRecovered), "a recovery closes the failure alert")
+
+	started := []string{config.NotifyOnStarted}
+	assert.True(t, notifiesEvent(started, notification.EventBackupStarted))
+	assert.True(t, notifiesEvent(started, notification.EventRestoreStarted))
+	assert.False(t, notifiesEvent(started, notification.EventBackupFailed))
+	assert.False(t, notifiesEvent(started, notification.EventBackupRecovered))
+}
+
+func TestVerifyStoredBackup(t *testing.T) {
+	ctx := context.Background()
+	m := newFailoverManager(t)
+	sum := sha256.Sum256([]byte("dump"))
+	checksum := hex.EncodeToString(sum[:])
+
+	memPools["local"].objects["app/db/1.sql.zst"] = []byte("dump")
+	require.NoError(t, m.verifyStoredBackup(ctx, "local", "app/db/1.sql.zst", checksum))
+	assert.Contains(t, memPools["local"].objects, "app/db/1.sql.zst")
+
+	// A corrupt copy is deleted
+	memPools["local"].objects["app/db/1.sql.zst"] = []byte("dumq")
+	err := m.verifyStoredBackup(ctx, "local", "app/db/1.sql.zst", checksum)
+	assert.ErrorContains(t, err, "stored backup is corrupt")
+	assert.NotContains(t, memPools["local"].objects, "app/db/1.sql.zst")
+
+	// A backup that can't be read back is kept
+	memPools["s3"].objects["app/db/1.sql.zst"] = []byte("dump")
+	memPools["s3"].fail = true
+	err = m.verifyStoredBackup(ctx, "s3", "app/db/1.sql.zst", checksum)
+	assert.ErrorContains(t, err, "failed to read back")
+	assert.Contains(t, memPools["s3"].objects, "app/db/1.sql.zst")
+}
+
+// splitBackup writes the part name as its archive
+type splitBackup struct {
+	blockingBackup
+	parts []string
+}
+
+func (b *splitBackup) Parts(context.Context, *docker.ContainerInfo, *docker.Client, Options) ([]string, error) {
+	return b.parts, nil
+}
+
+func (b *splitBackup) BackupPart(_ context.Context, _ *docker.ContainerInfo, _ *docker.Client, _ Options, part string, w io.Writer) error {
+	_, err := io.WriteString(w, part)
+	return err
+}
+
+func TestRunBackup_Split(t *testing.T) {
+	pm := newFailoverManager(t).poolManager
+	m := NewManager(newFakeDocker(t), pm, nil, retention.New(pm), nil, nil, nil, config.New())
+	cfg := &config.ContainerConfig{ContainerName: "app"}
+	backup := config.BackupConfig{Name: "db", BackupType: "split", Storage: "s3", Retention: 1, Options: map[string]string{OptionSplit: "true"}}
+
+	// An older backup of each database, one of a database that is gone, and a bundled one
+	old := time.Now().Add(-time.Hour)
+	s3 := memPools["s3"]
+	s3.modified = map[string]time.Time{}
+	for _, key := range []string{"app/db/shop/2026-01-01/030000.tar.zst", "app/db/blog/2026-01-01/030000.tar.zst", "app/db/gone/2026-01-01/030000.tar.zst", "app/db/2026-01-01/030000.tar.zst"} {
+		s3.objects[key] = []byte("old")
+		s3.modified[key] = old
+	}
+
+	m.runBackup(context.Background(), "app", cfg, backup, &splitBackup{parts: []string{"shop", "blog"}})
+	require.Empty(t, m.jobs.failureHistory(m.makeJobKey("app", "db")))
+
+	var keys []string
+	for key := range s3.objects {
+		if strings.HasSuffix(key, storage.ChecksumSuffix) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	require.Len(t, keys, 4, "retention keeps the newest backup of each database")
+	assert.Equal(t, "app/db/2026-01-01/030000.tar.zst", keys[0], "bundled backups are left alone")
+	assert.Regexp(t, `^app/db/blog/\d{4}-\d{2}-\d{2}/\d{6}\.bin$`, keys[1])
+	assert.Equal(t, "app/db/gone/2026-01-01/030000.tar.zst", keys[2])
+	assert.Regexp(t, `^app/db/shop/\d{4}-\d{2}-\d{2}/\d{6}\.bin$`, keys[3])
+	assert.Equal(t, "shop", string(s3.objects[keys[3]]))
+}
+
+// halfwayRestore reads half of the archive, records the restore's progress and
+// reads the rest
+type halfwayRestore struct {
+	checkBackupType
+	snapshot progress.Snapshot
+	tempDir  string
+}
+
+var halfway = &halfwayRestore{}
+
+func init() {
+	Register(halfway)
+}
+
+func (b *halfwayRestore) Name() string { return "halfway-test" }
+
+func (b *halfwayRestore) Restore(ctx context.Context, _ *docker.ContainerInfo, _ *docker.Client, _ Options, r io.Reader) error {
+	if _, err := io.CopyN(io.Discard, r, 32<<10); err != nil {
+		return err
+	}
+	b.snapshot = progress.FromContext(ctx).Snapshot()
+	b.tempDir = TempDirFromContext(ctx)
+	_, err := io.Copy(io.Discard, r)
+	return err
+}
+
+func TestRestoreBackup_Progress(t *testing.T) {
+	pm := newFailoverManager(t).poolManager
+	notifier := &recordingNotifier{}
+	notifyMgr := notification.NewManager()
+	notifyMgr.AddNotifier("rec", notifier)
+	cfg := config.New()
+	cfg.TempDir = t.TempDir()
+	m := NewManager(newFakeDocker(t), pm, nil, nil, notifyMgr, nil, nil, cfg)
+	m.containers["app"] = &config.ContainerConfig{
+		ContainerName: "app",
+		Notify:        []string{"rec"},
+		NotifyOn:      []string{config.NotifyOnCompleted},
+		Backups:       []config.BackupConfig{{Name: "files", BackupType: "halfway-test", Storage: "s3"}},
+	}
+	key := "app/files/2026-01-01/030000.bin"
+	memPools["s3"].objects[key] = []byte(strings.Repeat("x", 64<<10))
+
+	require.NoError(t, m.RestoreBackup(context.Background(), "app", key, nil))
+	m.WaitNotifications()
+
+	assert.Equal(t, cfg.TempDir, halfway.tempDir, "restores spool to --temp-dir")
+	assert.Equal(t, int64(64<<10), halfway.snapshot.TotalBytes)
+	assert.GreaterOrEqual(t, halfway.snapshot.Percent(), float64(50))
+	assert.Less(t, halfway.snapshot.Percent(), float64(100))
+
+	require.Len(t, notifier.events, 1)
+	assert.Equal(t, notification.EventRestoreCompleted, notifier.events[0].Type)
+	assert.Equal(t, int64(64<<10), notifier.events[0].Size)
+}
+
+func TestRestoreBackup_SizeCountsDecompressedBytes(t *testing.T) {
+	pm := newFailoverManager(t).poolManager
+	notifier := &recordingNotifier{}
+	notifyMgr := notification.NewManager()
+	notifyMgr.AddNotifier("rec", notifier)
+	m := NewManager(newFakeDocker(t), pm, nil, nil, notifyMgr, nil, nil, config.New())
+	m.containers["app"] = &config.ContainerConfig{
+		ContainerName: "app",
+		Notify:        []string{"rec"},
+		NotifyOn:      []string{config.NotifyOnCompleted},
+		Backups:       []config.BackupConfig{{Name: "files", BackupType: "halfway-test", Storage: "s3"}},
+	}
+
+	random := make([]byte, 64<<10)
+	_, _ = rand.New(rand.NewSource(1)).Read(random)
+	plain := []byte(hex.EncodeToString(random))
+	var compressed bytes.Buffer
+	enc, err := zstd.NewWriter(&compressed)
+	require.NoError(t, err)
+	_, err = enc.Write(plain)
+	require.NoError(t, err)
+	require.NoError(t, enc.Close())
+	require.Greater(t, compressed.Len(), 32<<10)
+
+	key := "app/files/2026-01-01/030000.tar.zst"
+	memPools["s3"].objects[key] = compressed.Bytes()
+
+	require.NoError(t, m.RestoreBackup(context.Background(), "app", key, nil))
+	m.WaitNotifications()
+
+	assert.Equal(t, int64(compressed.Len()), halfway.snapshot.TotalBytes, "progress follows the stored archive")
+	require.Len(t, notifier.events, 1)
+	assert.Equal(t, int64(len(plain)), notifier.events[0].Size, "size counts what was restored")
+}
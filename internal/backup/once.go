@@ -0,0 +1,108 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/audit"
+)
+
+// RunStatus is the outcome of one backup config in a RunOnce batch
+type RunStatus string
+
+const (
+	RunSucceeded RunStatus = "succeeded"
+	RunFailed    RunStatus = "failed"
+	RunSkipped   RunStatus = "skipped" // The container stopped before its backup started
+)
+
+// RunResult is the result of one backup config in a RunOnce batch
+type RunResult struct {
+	Container string        `json:"container"`
+	Config    string        `json:"config,omitempty"`
+	Type      string        `json:"type,omitempty"`
+	Status    RunStatus     `json:"status"`
+	BackupKey string        `json:"key,omitempty"`
+	Storage   string        `json:"storage,omitempty"`
+	Size      int64         `json:"size,omitempty"`
+	Duration  time.Duration `json:"duration,omitempty"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// RunOnce backs up every enabled backup config of all running containers one
+// after another, ignoring their schedules, and returns a result per config in
+// container order. Containers whose labels don't parse and Docker nodes that
+// can't be listed are reported as failed results. Retention and notifications
+// work as for scheduled runs; RunOnce returns after all notifications are sent.
+// The error is only set when no container could be listed at all.
+func (m *Manager) RunOnce(ctx context.Context) ([]RunResult, error) {
+	defer m.notifying.Wait()
+
+	ctx = audit.WithActor(ctx, audit.ActorOnce)
+
+	containers, nodeErrs, err := m.docker.ListContainers(ctx, m.config.IncludeStoppedContainers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	var results []RunResult
+	for node, err := range nodeErrs {
+		results = append(results, RunResult{
+			Container: "*@" + node, // Like a qualified container name, for every container of the node
+			Status:    RunFailed,
+			Error:     fmt.Sprintf("failed to list containers: %v", err),
+		})
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Container < results[j].Container })
+
+	sort.Slice(containers, func(i, j int) bool { return containers[i].Name < containers[j].Name })
+
+	for i := range containers {
+		container := &containers[i]
+		cfg, err := m.containerConfig(container)
+		if err != nil {
+			results = append(results, RunResult{Container: container.Name, Status: RunFailed, Error: err.Error()})
+			continue
+		}
+		if !cfg.Enabled {
+			continue
+		}
+
+		for _, b := range cfg.Backups {
+			result := RunResult{Container: cfg.ContainerName, Config: b.Name, Type: b.BackupType}
+
+			backupType, ok := Get(b.BackupType)
+			if !ok {
+				result.Status = RunFailed
+				result.Error = fmt.Sprintf("unknown backup type %q", b.BackupType)
+				results = append(results, result)
+				continue
+			}
+
+			started := time.Now()
+			m.runBackup(ctx, container.Key(), cfg, b, backupType)
+
+			_, last := m.jobs.status(m.makeJobKey(container.Key(), b.Name))
+			switch {
+			case last == nil || last.FinishedAt.Before(started):
+				result.Status = RunSkipped
+			case last.Success:
+				result.Status = RunSucceeded
+			default:
+				result.Status = RunFailed
+			}
+			if last != nil && result.Status != RunSkipped {
+				result.BackupKey = last.BackupKey
+				result.Storage = last.Storage
+				result.Size = last.Size
+				result.Duration = last.Duration
+				result.Error = last.Error
+			}
+			results = append(results, result)
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,102 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// opLockWarnAfter is how long an operation waits before logging that it is blocked
+	opLockWarnAfter = time.Minute
+	// opLockTimeout bounds how long a backup or restore waits for the container to become free
+	opLockTimeout = 30 * time.Minute
+)
+
+// opLocks serializes backup, retention and restore operations per container,
+// so a scheduled backup can't capture a half-restored container and retention
+// can't delete the key that is currently being restored
+type opLocks struct {
+	mu        sync.Mutex
+	locks     map[string]*containerLock
+	warnAfter time.Duration
+	timeout   time.Duration
+}
+
+// containerLock is a single-slot semaphore that remembers which operation holds it
+type containerLock struct {
+	sem    chan struct{}
+	mu     sync.Mutex
+	holder string
+}
+
+func newOpLocks(warnAfter, timeout time.Duration) *opLocks {
+	return &opLocks{
+		locks:     make(map[string]*containerLock),
+		warnAfter: warnAfter,
+		timeout:   timeout,
+	}
+}
+
+func (l *opLocks) get(containerName string) *containerLock {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lock, ok := l.locks[containerName]
+	if !ok {
+		lock = &containerLock{sem: make(chan struct{}, 1)}
+		l.locks[containerName] = lock
+	}
+	return lock
+}
+
+func (c *containerLock) setHolder(op string) {
+	c.mu.Lock()
+	c.holder = op
+	c.mu.Unlock()
+}
+
+func (c *containerLock) currentHolder() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.holder
+}
+
+// acquire blocks until no other operation runs on the container. It logs a
+// warning each time warnAfter elapses while waiting and gives up once the
+// timeout is reached. The returned function releases the lock.
+func (l *opLocks) acquire(ctx context.Context, containerName, op string) (func(), error) {
+	lock := l.get(containerName)
+
+	warn := time.NewTicker(l.warnAfter)
+	defer warn.Stop()
+
+	timeout := time.NewTimer(l.timeout)
+	defer timeout.Stop()
+
+	start := time.Now()
+
+	for {
+		select {
+		case lock.sem <- struct{}{}:
+			lock.setHolder(op)
+			return func() {
+				lock.setHolder("")
+				<-lock.sem
+			}, nil
+		case <-warn.C:
+			slog.Warn("waiting for running operation to finish",
+				"container", containerName,
+				"operation", op,
+				"running", lock.currentHolder(),
+				"waited", time.Since(start).Round(time.Second),
+			)
+		case <-timeout.C:
+			return nil, fmt.Errorf("timed out after %s waiting for %s to finish on container %s", l.timeout, lock.currentHolder(), containerName)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package backup
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpLocks_SerializesOperations(t *testing.T) {
+	locks := newOpLocks(time.Second, 5*time.Second)
+
+	var active, maxActive int32
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		op := "backup"
+		if i%2 == 0 {
+			op = "restore"
+		}
+
+		wg.Add(1)
+		go func(op string) {
+			defer wg.Done()
+
+			release, err := locks.acquire(context.Background(), "db", op)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer release()
+
+			n := atomic.AddInt32(&active, 1)
+			for {
+				current := atomic.LoadInt32(&maxActive)
+				if n <= current || atomic.CompareAndSwapInt32(&maxActive, current, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&active, -1)
+		}(op)
+	}
+
+	wg.Wait()
+	assert.Equal(t, int32(1), maxActive)
+}
+
+func TestOpLocks_ContainersAreIndependent(t *testing.T) {
+	locks := newOpLocks(time.Second, time.Second)
+
+	release, err := locks.acquire(context.Background(), "db", "restore")
+	require.NoError(t, err)
+	defer release()
+
+	other, err := locks.acquire(context.Background(), "cache", "backup")
+	require.NoError(t, err)
+	other()
+}
+
+func TestOpLocks_TimesOut(t *testing.T) {
+	locks := newOpLocks(10*time.Millisecond, 50*time.Millisecond)
+
+	release, err := locks.acquire(context.Background(), "db", "restore")
+	require.NoError(t, err)
+	defer release()
+
+	_, err = locks.acquire(context.Background(), "db", "backup")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "waiting for restore to finish on container db")
+}
+
+func TestOpLocks_ContextCancelled(t *testing.T) {
+	locks := newOpLocks(time.Second, time.Minute)
+
+	release, err := locks.acquire(context.Background(), "db", "restore")
+	require.NoError(t, err)
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err = locks.acquire(ctx, "db", "backup")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestOpLocks_ReleaseAllowsNextOperation(t *testing.T) {
+	locks := newOpLocks(time.Second, time.Second)
+
+	release, err := locks.acquire(context.Background(), "db", "backup")
+	require.NoError(t, err)
+	release()
+
+	release, err = locks.acquire(context.Background(), "db", "restore")
+	require.NoError(t, err)
+	release()
+}
@@ -0,0 +1,153 @@
+package backup
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/compression"
+)
+
+// Options holds type-specific settings of a backup config. They come from labels
+// that aren't one of the common properties, e.g. docker-backup.db.stream=true.
+type Options map[string]string
+
+// OptionS3Tags sets object tags on the stored backup, e.g. docker-backup.db.s3-tags=retention=30d,tier=cold.
+// It is handled by the manager rather than the backup type and ignored by pools without tagging support.
+const OptionS3Tags = "s3-tags"
+
+// OptionCompression selects the codec archives are compressed with, e.g. docker-backup.db.compression=gzip.
+// Backup types read it with Compression; the manager rejects invalid values before a run.
+const OptionCompression = "compression"
+
+// OptionSplit stores each part of a backup, e.g. each database, under a key of its own, e.g.
+// docker-backup.db.split=true. It is handled by the manager for backup types implementing Splitter.
+const OptionSplit = "split"
+
+// OptionDedup stores large files once as blobs shared by all backups of the config, e.g.
+// docker-backup.data.dedup=true. It is handled by the manager for backup types implementing Deduplicator.
+const OptionDedup = "dedup"
+
+// OptionRestoreMode selects how a restore treats existing data, e.g. docker-backup.data.restore-mode=clear.
+// It is the only option a single restore may override, see ValidateRestoreOverrides.
+const OptionRestoreMode = "restore-mode"
+
+// ValidateRestoreOverrides rejects overrides of options other than OptionRestoreMode. The
+// others, e.g. helper images, credentials or paths, are only set by the container's labels.
+func ValidateRestoreOverrides(overrides map[string]string) error {
+	for _, name := range slices.Sorted(maps.Keys(overrides)) {
+		if name != OptionRestoreMode {
+			return fmt.Errorf("option %q can't be overridden for a restore, only %q", name, OptionRestoreMode)
+		}
+	}
+	return nil
+}
+
+// String returns the option value or def if it is not set
+func (o Options) String(key, def string) string {
+	if val, ok := o[key]; ok && strings.TrimSpace(val) != "" {
+		return strings.TrimSpace(val)
+	}
+	return def
+}
+
+// Bool returns the option parsed as a boolean or def if it is not set
+func (o Options) Bool(key string, def bool) (bool, error) {
+	val := o.String(key, "")
+	if val == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		return def, fmt.Errorf("invalid value for option %q: %w", key, err)
+	}
+	return b, nil
+}
+
+// Int returns the option parsed as an integer or def if it is not set
+func (o Options) Int(key string, def int) (int, error) {
+	val := o.String(key, "")
+	if val == "" {
+		return def, nil
+	}
+	i, err := strconv.Atoi(val)
+	if err != nil {
+		return def, fmt.Errorf("invalid value for option %q: %w", key, err)
+	}
+	return i, nil
+}
+
+// Duration returns the option parsed as a duration (e.g. 24h, 90m) or def if it is not set
+func (o Options) Duration(key string, def time.Duration) (time.Duration, error) {
+	val := o.String(key, "")
+	if val == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		return def, fmt.Errorf("invalid value for option %q: %w", key, err)
+	}
+	return d, nil
+}
+
+// List returns the option split on commas with empty items removed
+func (o Options) List(key string) []string {
+	var items []string
+	for _, item := range strings.Split(o.String(key, ""), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Compression returns the codec selected with the compression option
+func (o Options) Compression() (compression.Codec, error) {
+	codec, err := compression.Parse(o.String(OptionCompression, ""))
+	if err != nil {
+		return "", fmt.Errorf("invalid %s option: %w", OptionCompression, err)
+	}
+	return codec, nil
+}
+
+// Extension returns base followed by the extension of the selected codec, e.g.
+// ".tar.gz" for base ".tar" and compression=gzip. An invalid compression option
+// fails the run before the extension matters, so it yields the default one.
+func (o Options) Extension(base string) string {
+	codec, err := o.Compression()
+	if err != nil {
+		codec = compression.Default
+	}
+	return base + codec.Extension()
+}
+
+// Splitter returns the backup type as a Splitter when the split option is set,
+// or nil when it isn't. Backup types that can't split their backups fail.
+func (o Options) Splitter(backupType BackupType) (Splitter, error) {
+	split, err := o.Bool(OptionSplit, false)
+	if err != nil || !split {
+		return nil, err
+	}
+	splitter, ok := backupType.(Splitter)
+	if !ok {
+		return nil, fmt.Errorf("backup type %q doesn't support the %s option", backupType.Name(), OptionSplit)
+	}
+	return splitter, nil
+}
+
+// Deduplicator returns the backup type as a Deduplicator when the dedup option
+// is set, or nil when it isn't. Backup types that can't deduplicate fail.
+func (o Options) Deduplicator(backupType BackupType) (Deduplicator, error) {
+	dedup, err := o.Bool(OptionDedup, false)
+	if err != nil || !dedup {
+		return nil, err
+	}
+	deduplicator, ok := backupType.(Deduplicator)
+	if !ok {
+		return nil, fmt.Errorf("backup type %q doesn't support the %s option", backupType.Name(), OptionDedup)
+	}
+	return deduplicator, nil
+}
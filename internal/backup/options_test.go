@@ -0,0 +1,99 @@
+package backup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/compression"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptions_String(t *testing.T) {
+	opts := Options{"path": " /data ", "empty": " "}
+
+	assert.Equal(t, "/data", opts.String("path", "x"))
+	assert.Equal(t, "x", opts.String("empty", "x"))
+	assert.Equal(t, "x", opts.String("missing", "x"))
+}
+
+func TestOptions_Bool(t *testing.T) {
+	opts := Options{"stream": "true", "bad": "maybe"}
+
+	v, err := opts.Bool("stream", false)
+	require.NoError(t, err)
+	assert.True(t, v)
+
+	v, err = opts.Bool("missing", true)
+	require.NoError(t, err)
+	assert.True(t, v)
+
+	_, err = opts.Bool("bad", false)
+	assert.Error(t, err)
+}
+
+func TestOptions_Int(t *testing.T) {
+	opts := Options{"jobs": "4", "bad": "four"}
+
+	v, err := opts.Int("jobs", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 4, v)
+
+	v, err = opts.Int("missing", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, v)
+
+	_, err = opts.Int("bad", 1)
+	assert.Error(t, err)
+}
+
+func TestOptions_Duration(t *testing.T) {
+	opts := Options{"since": "24h", "bad": "a day"}
+
+	v, err := opts.Duration("since", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 24*time.Hour, v)
+
+	v, err = opts.Duration("missing", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, v)
+
+	_, err = opts.Duration("bad", 0)
+	assert.Error(t, err)
+}
+
+func TestOptions_List(t *testing.T) {
+	opts := Options{"volumes": "data, uploads,,"}
+
+	assert.Equal(t, []string{"data", "uploads"}, opts.List("volumes"))
+	assert.Nil(t, opts.List("missing"))
+	assert.Nil(t, Options(nil).List("missing"))
+}
+
+func TestOptions_Compression(t *testing.T) {
+	codec, err := Options(nil).Compression()
+	require.NoError(t, err)
+	assert.Equal(t, compression.Zstd, codec)
+
+	codec, err = Options{OptionCompression: "gzip"}.Compression()
+	require.NoError(t, err)
+	assert.Equal(t, compression.Gzip, codec)
+
+	_, err = Options{OptionCompression: "lz4"}.Compression()
+	assert.Error(t, err)
+}
+
+func TestOptions_Extension(t *testing.T) {
+	assert.Equal(t, ".tar.zst", Options(nil).Extension(".tar"))
+	assert.Equal(t, ".tar.gz", Options{OptionCompression: "gzip"}.Extension(".tar"))
+	assert.Equal(t, ".rdb", Options{OptionCompression: "none"}.Extension(".rdb"))
+}
+
+func TestValidateRestoreOverrides(t *testing.T) {
+	assert.NoError(t, ValidateRestoreOverrides(nil))
+	assert.NoError(t, ValidateRestoreOverrides(map[string]string{OptionRestoreMode: "clear"}))
+
+	err := ValidateRestoreOverrides(map[string]string{OptionRestoreMode: "clear", "helper-image": "evil:latest"})
+	assert.ErrorContains(t, err, `option "helper-image" can't be overridden`)
+}
@@ -0,0 +1,51 @@
+package backup
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shyim/docker-backup/internal/config"
+	"github.com/shyim/docker-backup/internal/scheduler"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddContainer_InstancesKeepToTheirLabelPrefix(t *testing.T) {
+	// Two daemons on one host see the same containers, each labelled for one of them
+	fake := &fakeDocker{running: true, labels: map[string]map[string]string{
+		"web": {
+			"docker-backup.enable":         "true",
+			"docker-backup.files.type":     "check-test",
+			"docker-backup.files.storage":  "s3",
+			"docker-backup.files.schedule": "0 3 * * *",
+		},
+		"db": {
+			"docker-backup-staging.enable":      "true",
+			"docker-backup-staging.db.type":     "check-test",
+			"docker-backup-staging.db.storage":  "s3",
+			"docker-backup-staging.db.schedule": "0 3 * * *",
+		},
+	}}
+	pm := newFailoverManager(t).poolManager
+
+	newInstance := func(prefix string) (*Manager, *scheduler.Scheduler) {
+		sched := scheduler.New(context.Background())
+		cfg := config.New()
+		cfg.LabelPrefix = prefix
+		m := NewManager(newFakeDocker(t, fake), pm, sched, nil, nil, nil, nil, cfg)
+		for _, id := range []string{"web", "db"} {
+			m.addContainer(context.Background(), id)
+		}
+		return m, sched
+	}
+
+	prod, prodSched := newInstance("docker-backup")
+	staging, stagingSched := newInstance("docker-backup-staging")
+
+	assert.Equal(t, 1, prodSched.JobCount())
+	assert.True(t, prodSched.HasJob(prod.makeJobKey("web", "files")))
+	assert.NotContains(t, prod.containers, "db")
+
+	assert.Equal(t, 1, stagingSched.JobCount())
+	assert.True(t, stagingSched.HasJob(staging.makeJobKey("db", "db")))
+	assert.NotContains(t, staging.containers, "web")
+}
@@ -0,0 +1,168 @@
+package backup
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/shyim/docker-backup/internal/encryption"
+	"github.com/shyim/docker-backup/internal/storage"
+)
+
+// ReencryptResult summarises a reencrypt run for one container
+type ReencryptResult struct {
+	CurrentKey  string             `json:"current_key"`
+	Reencrypted []string           `json:"reencrypted"`
+	Current     int                `json:"current"` // already encrypted with the current key
+	Plain       int                `json:"plain"`   // not encrypted, left unchanged
+	Failed      []ReencryptFailure `json:"failed,omitempty"`
+}
+
+// ReencryptFailure is a backup that could not be re-encrypted
+type ReencryptFailure struct {
+	Key   string `json:"key"`
+	Error string `json:"error"`
+}
+
+// Reencrypt rewrites the container's backups that were encrypted with an older
+// key so they use the current key. Each backup is fully decrypted and
+// re-encrypted into a temporary file before the stored object is replaced, so
+// a failure leaves the original in place. Unencrypted backups are skipped.
+func (m *Manager) Reencrypt(ctx context.Context, containerName string) (*ReencryptResult, error) {
+	if m.keyring == nil {
+		return nil, fmt.Errorf("encryption is not configured")
+	}
+
+	cfg, _, err := m.findContainerConfig(ctx, containerName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Backups and restores of the container must not read an object while it is replaced
+	release, err := m.opLocks.acquire(ctx, cfg.ContainerName, "reencrypt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to start reencrypt: %w", err)
+	}
+	defer release()
+
+	backups, err := m.ListBackups(ctx, containerName)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReencryptResult{CurrentKey: m.keyring.Current(), Reencrypted: []string{}}
+	for _, file := range backups {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		store, err := m.getStorageForBackupKey(ctx, cfg, file.Key)
+		if err != nil {
+			result.Failed = append(result.Failed, ReencryptFailure{Key: file.Key, Error: err.Error()})
+			continue
+		}
+
+		var tags map[string]string
+		if backupCfg := backupConfigForKey(cfg, file.Key); backupCfg != nil {
+			// Replacing the object drops its tags, so they are set again
+			tags, _ = storage.ParseTags(Options(backupCfg.Options).String(OptionS3Tags, ""))
+		}
+
+		state, err := m.reencryptObject(ctx, store, file.Key, tags)
+		switch {
+		case err != nil:
+			slog.Error("failed to re-encrypt backup", "container", containerName, "key", file.Key, "error", err)
+			result.Failed = append(result.Failed, ReencryptFailure{Key: file.Key, Error: err.Error()})
+		case state == reencryptPlain:
+			result.Plain++
+		case state == reencryptCurrent:
+			result.Current++
+		default:
+			slog.Info("backup re-encrypted", "container", containerName, "key", file.Key, "current_key", result.CurrentKey)
+			result.Reencrypted = append(result.Reencrypted, file.Key)
+		}
+	}
+
+	return result, nil
+}
+
+type reencryptState int
+
+const (
+	reencryptDone reencryptState = iota
+	reencryptCurrent
+	reencryptPlain
+)
+
+// reencryptObject re-encrypts one stored backup with the current key
+func (m *Manager) reencryptObject(ctx context.Context, store storage.Storage, key string, tags map[string]string) (reencryptState, error) {
+	reader, err := store.Get(ctx, key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get backup: %w", err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	br := bufio.NewReader(reader)
+	id, encrypted, err := encryption.PeekKeyID(br)
+	if err != nil {
+		return 0, err
+	}
+	if !encrypted {
+		return reencryptPlain, nil
+	}
+	if id == m.keyring.Current() {
+		return reencryptCurrent, nil
+	}
+
+	plain, err := m.keyring.Decrypt(br)
+	if err != nil {
+		return 0, err
+	}
+
+	tmp, err := os.CreateTemp(m.config.TempDir, "docker-backup-reencrypt-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	hash := sha256.New()
+	enc, err := m.keyring.Encrypt(io.MultiWriter(tmp, hash))
+	if err != nil {
+		return 0, err
+	}
+	if _, err := io.Copy(enc, plain); err != nil {
+		return 0, fmt.Errorf("failed to re-encrypt backup: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return 0, err
+	}
+	// The original is no longer read; close it before the backend replaces it
+	_ = reader.Close()
+
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read temp file: %w", err)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to read temp file: %w", err)
+	}
+
+	if err := storage.Store(ctx, store, key, m.uploadLimit.Reader(ctx, tmp), storage.StoreOptions{Size: size, Tags: tags}); err != nil {
+		return 0, fmt.Errorf("failed to store backup: %w", err)
+	}
+	// The old checksum no longer matches the rewritten object
+	if err := writeChecksum(ctx, store, key, hex.EncodeToString(hash.Sum(nil))); err != nil {
+		slog.Warn("failed to store backup checksum", "key", key, "error", err)
+	}
+	return reencryptDone, nil
+}
@@ -0,0 +1,203 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	"github.com/shyim/docker-backup/internal/config"
+	"github.com/shyim/docker-backup/internal/encryption"
+	"github.com/shyim/docker-backup/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memStorage keeps objects in memory. With fail set, every Store fails.
+// List reports the times in modified, if any.
+type memStorage struct {
+	objects  map[string][]byte
+	modified map[string]time.Time
+	stores   int
+	fail     bool
+}
+
+func (s *memStorage) Store(_ context.Context, key string, r io.Reader) error {
+	if s.fail {
+		return errors.New("storage unreachable")
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.objects[key] = data
+	if s.modified != nil {
+		s.modified[key] = time.Now()
+	}
+	s.stores++
+	return nil
+}
+
+func (s *memStorage) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	if s.fail {
+		return nil, errors.New("storage unreachable")
+	}
+	return io.NopCloser(bytes.NewReader(s.objects[key])), nil
+}
+
+func (s *memStorage) List(_ context.Context, prefix string) ([]storage.BackupFile, error) {
+	var files []storage.BackupFile
+	for key, data := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			files = append(files, storage.BackupFile{Key: key, Size: int64(len(data)), LastModified: s.modified[key]})
+		}
+	}
+	return files, nil
+}
+
+func (s *memStorage) Delete(_ context.Context, key string) error {
+	delete(s.objects, key)
+	return nil
+}
+
+func newTestKey(t *testing.T) string {
+	t.Helper()
+	key := make([]byte, encryption.KeySize)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func encryptWith(t *testing.T, k *encryption.Keyring, plain string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := k.Encrypt(&buf)
+	require.NoError(t, err)
+	_, err = io.WriteString(w, plain)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestReencryptObject(t *testing.T) {
+	oldKey, newKey := newTestKey(t), newTestKey(t)
+	old, err := encryption.ParseKeyring("old="+oldKey, "")
+	require.NoError(t, err)
+	rotated, err := encryption.ParseKeyring("old="+oldKey+",new="+newKey, "")
+	require.NoError(t, err)
+
+	store := &memStorage{objects: map[string][]byte{
+		"db/db/2026-01-01/000000.sql.zst.enc": encryptWith(t, old, "old dump"),
+		"db/db/2026-01-02/000000.sql.zst.enc": encryptWith(t, rotated, "new dump"),
+		"db/db/2025-12-31/000000.sql.zst":     []byte("plain dump"),
+	}}
+	m := &Manager{keyring: rotated, config: &config.Config{TempDir: t.TempDir()}}
+	ctx := context.Background()
+
+	state, err := m.reencryptObject(ctx, store, "db/db/2026-01-01/000000.sql.zst.enc", nil)
+	require.NoError(t, err)
+	assert.Equal(t, reencryptDone, state)
+
+	state, err = m.reencryptObject(ctx, store, "db/db/2026-01-02/000000.sql.zst.enc", nil)
+	require.NoError(t, err)
+	assert.Equal(t, reencryptCurrent, state)
+
+	state, err = m.reencryptObject(ctx, store, "db/db/2025-12-31/000000.sql.zst", nil)
+	require.NoError(t, err)
+	assert.Equal(t, reencryptPlain, state)
+
+	assert.Equal(t, 2, store.stores, "only the rewritten backup and its checksum are stored")
+
+	// The checksum is written again for the rewritten object
+	rewritten := store.objects["db/db/2026-01-01/000000.sql.zst.enc"]
+	sum := sha256.Sum256(rewritten)
+	checksum, err := storage.ParseChecksum(store.objects[storage.ChecksumKey("db/db/2026-01-01/000000.sql.zst.enc")])
+	require.NoError(t, err)
+	assert.Equal(t, hex.EncodeToString(sum[:]), checksum)
+
+	// The rewritten object now only needs the new key
+	newOnly, err := encryption.ParseKeyring("new="+newKey, "")
+	require.NoError(t, err)
+	r, err := newOnly.Decrypt(bytes.NewReader(store.objects["db/db/2026-01-01/000000.sql.zst.enc"]))
+	require.NoError(t, err)
+	got, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "old dump", string(got))
+}
+
+func TestReencryptObject_UnknownKeyKeepsOriginal(t *testing.T) {
+	other, err := encryption.ParseKeyring("other="+newTestKey(t), "")
+	require.NoError(t, err)
+	k, err := encryption.ParseKeyring("new="+newTestKey(t), "")
+	require.NoError(t, err)
+
+	original := encryptWith(t, other, "dump")
+	store := &memStorage{objects: map[string][]byte{"db/db/1.sql.zst.enc": original}}
+	m := &Manager{keyring: k, config: &config.Config{TempDir: t.TempDir()}}
+
+	_, err = m.reencryptObject(context.Background(), store, "db/db/1.sql.zst.enc", nil)
+	assert.ErrorIs(t, err, encryption.ErrUnknownKey)
+	assert.Equal(t, 0, store.stores)
+	assert.Equal(t, original, store.objects["db/db/1.sql.zst.enc"])
+}
+
+func TestOpenArchive(t *testing.T) {
+	k, err := encryption.ParseKeyring("a="+newTestKey(t), "")
+	require.NoError(t, err)
+
+	m := &Manager{keyring: k}
+	r, err := m.openArchive(bytes.NewReader(encryptWith(t, k, "archive")))
+	require.NoError(t, err)
+	got, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "archive", string(got))
+
+	// Backups written before encryption was enabled still restore
+	r, err = m.openArchive(bytes.NewReader([]byte("plain archive")))
+	require.NoError(t, err)
+	got, err = io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "plain archive", string(got))
+
+	unconfigured := &Manager{}
+	_, err = unconfigured.openArchive(bytes.NewReader(encryptWith(t, k, "archive")))
+	assert.ErrorContains(t, err, "no encryption keys are configured")
+}
+
+func TestOpenArchive_Age(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	var encrypted bytes.Buffer
+	w, err := age.Encrypt(&encrypted, identity.Recipient())
+	require.NoError(t, err)
+	_, err = w.Write([]byte("archive"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	keyFile := filepath.Join(t.TempDir(), "key.txt")
+	require.NoError(t, os.WriteFile(keyFile, []byte(identity.String()+"\n"), 0600))
+	a, err := encryption.ParseAge(nil, keyFile)
+	require.NoError(t, err)
+
+	m := &Manager{age: a}
+	r, err := m.openArchive(bytes.NewReader(encrypted.Bytes()))
+	require.NoError(t, err)
+	got, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "archive", string(got))
+
+	unconfigured := &Manager{}
+	_, err = unconfigured.openArchive(bytes.NewReader(encrypted.Bytes()))
+	assert.ErrorContains(t, err, "no age identity is configured")
+}
@@ -0,0 +1,119 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/config"
+	"github.com/shyim/docker-backup/internal/httpclient"
+)
+
+// RemoteSource describes a backup archive fetched over HTTP(S) instead of from a storage pool
+type RemoteSource struct {
+	URL        string            `json:"url"`
+	BackupType string            `json:"type"`
+	Headers    map[string]string `json:"headers,omitempty"` // e.g. Authorization
+}
+
+// remoteClient fetches remote archives. There is no overall timeout because the
+// body is streamed into the restore, which can take much longer than the request.
+// Failed attempts to get a response are retried; a broken body stream is not.
+var remoteClient = &http.Client{
+	Transport: httpclient.NewRetryTransport(&http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		ResponseHeaderTimeout: 30 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+	}, httpclient.DefaultRetryPolicy()),
+}
+
+// RestoreFromURL restores a container from an archive fetched over HTTP(S). The
+// response body is streamed straight into the backup type's restore.
+// Options and notifications come from the container's first backup config of the
+// same type, if there is one.
+func (m *Manager) RestoreFromURL(ctx context.Context, containerName string, src RemoteSource, overrides map[string]string) error {
+	target, err := parseRemoteURL(src.URL)
+	if err != nil {
+		return err
+	}
+
+	backupType, ok := Get(src.BackupType)
+	if !ok {
+		return fmt.Errorf("unknown backup type %q (available: %v)", src.BackupType, List())
+	}
+
+	cfg, containerID, err := m.findContainerConfig(ctx, containerName)
+	if err != nil {
+		return err
+	}
+
+	backupCfg := config.BackupConfig{BackupType: src.BackupType}
+	for _, b := range cfg.Backups {
+		if b.BackupType == src.BackupType {
+			backupCfg = b
+			break
+		}
+	}
+
+	open := func(ctx context.Context) (io.ReadCloser, int64, error) {
+		return openRemote(ctx, target, src.Headers)
+	}
+
+	return m.restoreStream(ctx, cfg, containerID, backupCfg, backupType, overrides, redactURL(target), open)
+}
+
+// parseRemoteURL checks that rawURL is an absolute http or https URL
+func parseRemoteURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid URL %q: scheme must be http or https", redactURL(u))
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid URL %q: missing host", redactURL(u))
+	}
+	return u, nil
+}
+
+// openRemote starts the download and returns the response body and its
+// Content-Length, which is -1 when the server doesn't send one
+func openRemote(ctx context.Context, target *url.URL, headers map[string]string) (io.ReadCloser, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := remoteClient.Do(req)
+	if err != nil {
+		// url.Error repeats the full URL, signature included
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, 0, fmt.Errorf("failed to fetch backup from %s: %w", redactURL(target), err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		_ = resp.Body.Close()
+		return nil, 0, fmt.Errorf("failed to fetch backup from %s: unexpected status %s", redactURL(target), resp.Status)
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// redactURL drops credentials from a URL for logs and notifications, including
+// the query string, which carries the signature of presigned URLs
+func redactURL(u *url.URL) string {
+	redacted := *u
+	redacted.User = nil
+	redacted.RawQuery = ""
+	redacted.Fragment = ""
+	return redacted.String()
+}
@@ -0,0 +1,228 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/audit"
+	"github.com/shyim/docker-backup/internal/config"
+	"github.com/shyim/docker-backup/internal/docker"
+)
+
+// LatestKey stands for the newest backup of a config in a restore or restore test
+const LatestKey = "latest"
+
+const (
+	// DefaultRestoreTestStartTimeout bounds how long a restore test waits for
+	// the database in the sandbox to accept connections
+	DefaultRestoreTestStartTimeout = 2 * time.Minute
+
+	// restoreTestPollInterval is how often the sandbox's database is pinged
+	restoreTestPollInterval = 2 * time.Second
+)
+
+// RestoreTestOptions configures a restore test
+type RestoreTestOptions struct {
+	// Config selects the backup config whose newest backup is tested when the
+	// key is LatestKey. It may be omitted for containers with a single config.
+	Config string `json:"config,omitempty"`
+	// Image runs the sandbox from another image than the container's
+	Image string `json:"image,omitempty"`
+	// Query is run against the restored database and must succeed, only for
+	// backup types implementing Querier
+	Query string `json:"query,omitempty"`
+	// Exec is a shell command run in the sandbox after the restore that must exit with 0
+	Exec string `json:"exec,omitempty"`
+	// StartTimeout bounds the wait for the sandbox's database, 0 means DefaultRestoreTestStartTimeout
+	StartTimeout time.Duration `json:"start_timeout,omitempty"`
+}
+
+// RestoreTestResult is the outcome of a restore test
+type RestoreTestResult struct {
+	Container string        `json:"container"`
+	Key       string        `json:"key"`
+	Image     string        `json:"image"`
+	Passed    bool          `json:"passed"`
+	Output    string        `json:"output,omitempty"` // Output of the query and exec checks
+	Error     string        `json:"error,omitempty"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// RestoreTest restores a backup into a throwaway copy of the container and
+// checks the result, see docker.Client.CreateSandbox. The container itself
+// is neither stopped nor touched, so this takes no operation lock. A failed
+// test is reported in the result; the error is only set if the test couldn't
+// be started, e.g. because the backup doesn't exist.
+func (m *Manager) RestoreTest(ctx context.Context, containerName, backupKey string, opts RestoreTestOptions) (*RestoreTestResult, error) {
+	cfg, containerID, err := m.findContainerConfig(ctx, containerName)
+	if err != nil {
+		return nil, err
+	}
+
+	if backupKey == LatestKey {
+		backupKey, err = m.latestKey(ctx, cfg, opts.Config)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	backupCfg := backupConfigForKey(cfg, backupKey)
+	if backupCfg == nil {
+		return nil, fmt.Errorf("no backup configuration found for %s", backupKey)
+	}
+
+	backupType, ok := Get(backupCfg.BackupType)
+	if !ok {
+		return nil, fmt.Errorf("unknown backup type %q", backupCfg.BackupType)
+	}
+	querier, _ := backupType.(Querier)
+	if opts.Query != "" && querier == nil {
+		return nil, fmt.Errorf("backup type %q does not support queries, check the restore with an exec command instead", backupCfg.BackupType)
+	}
+
+	dockerClient, container, err := m.docker.GetContainer(ctx, containerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get container info: %w", err)
+	}
+
+	result := &RestoreTestResult{
+		Container: containerName,
+		Key:       backupKey,
+		Image:     opts.Image,
+	}
+	if result.Image == "" {
+		result.Image = container.Image
+	}
+
+	slog.Info("starting restore test", "container", containerName, "key", backupKey, "image", result.Image)
+	start := time.Now()
+
+	output, err := m.runRestoreTest(ctx, cfg, *backupCfg, backupType, querier, dockerClient, container, backupKey, opts)
+	result.Duration = time.Since(start)
+	result.Output = output
+	result.Passed = err == nil
+	if err != nil {
+		result.Error = err.Error()
+		slog.Warn("restore test failed", "container", containerName, "key", backupKey, "duration", result.Duration, "error", err)
+	} else {
+		slog.Info("restore test passed", "container", containerName, "key", backupKey, "duration", result.Duration)
+	}
+
+	auditResult, message := auditResult(err)
+	m.auditRecord(audit.Record{
+		Operation: audit.OperationRestoreTest,
+		Result:    auditResult,
+		Actor:     audit.ActorFrom(ctx, ""),
+		Container: containerName,
+		Config:    backupCfg.Name,
+		Key:       backupKey,
+		Duration:  result.Duration.Seconds(),
+		Error:     message,
+	})
+
+	return result, nil
+}
+
+// runRestoreTest creates the sandbox, restores the backup into it and runs
+// the checks, returning their output
+func (m *Manager) runRestoreTest(ctx context.Context, cfg *config.ContainerConfig, backupCfg config.BackupConfig, backupType BackupType, querier Querier, dockerClient *docker.Client, container *docker.ContainerInfo, backupKey string, opts RestoreTestOptions) (string, error) {
+	store, err := m.getStorageForBackupKey(ctx, cfg, backupKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to get storage: %w", err)
+	}
+
+	sandbox, err := dockerClient.CreateSandbox(ctx, container.ID, opts.Image)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := dockerClient.RemoveSandbox(sandbox.ID); err != nil {
+			slog.Warn("failed to remove sandbox container", "container", sandbox.Name, "error", err)
+		}
+	}()
+
+	backupOpts := Options(backupCfg.Options)
+	if err := backupType.Validate(sandbox, backupOpts); err != nil {
+		return "", fmt.Errorf("sandbox validation failed: %w", err)
+	}
+
+	startTimeout := opts.StartTimeout
+	if startTimeout <= 0 {
+		startTimeout = DefaultRestoreTestStartTimeout
+	}
+	if querier != nil {
+		if err := waitForPing(ctx, querier, dockerClient, sandbox, backupOpts, startTimeout); err != nil {
+			return "", err
+		}
+	}
+
+	stored, err := store.Get(ctx, backupKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to get backup: %w", err)
+	}
+	defer func() {
+		_ = stored.Close()
+	}()
+
+	reader, err := m.openArchive(stored)
+	if err != nil {
+		return "", fmt.Errorf("failed to open backup: %w", err)
+	}
+
+	if err := backupType.Restore(m.restoreBlobStore(WithTempDir(ctx, m.config.TempDir), cfg, backupCfg, backupType), sandbox, dockerClient, backupOpts, reader); err != nil {
+		return "", fmt.Errorf("restore failed: %w", err)
+	}
+
+	var output strings.Builder
+	if querier != nil {
+		// Restores of mounted data restart the sandbox
+		if err := waitForPing(ctx, querier, dockerClient, sandbox, backupOpts, startTimeout); err != nil {
+			return "", err
+		}
+		if opts.Query != "" {
+			out, err := querier.Query(ctx, sandbox, dockerClient, backupOpts, opts.Query)
+			output.WriteString(out)
+			if err != nil {
+				return output.String(), fmt.Errorf("query failed: %w", err)
+			}
+		}
+	}
+
+	if opts.Exec != "" {
+		result, err := dockerClient.Exec(ctx, sandbox.ID, []string{"sh", "-c", opts.Exec}, nil, nil)
+		if err != nil {
+			return output.String(), fmt.Errorf("failed to execute check: %w", err)
+		}
+		output.WriteString(result.Output)
+		if result.ExitCode != 0 {
+			return output.String(), fmt.Errorf("check exited with code %d", result.ExitCode)
+		}
+	}
+
+	return output.String(), nil
+}
+
+// waitForPing pings the database in container until it answers or timeout passes
+func waitForPing(ctx context.Context, querier Querier, dockerClient *docker.Client, container *docker.ContainerInfo, opts Options, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(restoreTestPollInterval)
+	defer ticker.Stop()
+
+	for {
+		err := querier.Ping(ctx, container, dockerClient, opts)
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("database not ready after %s: %w", timeout, err)
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/config"
+	"github.com/shyim/docker-backup/internal/docker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pingQuerier answers pings once ready is set
+type pingQuerier struct {
+	ready bool
+	pings int
+}
+
+func (q *pingQuerier) Ping(context.Context, *docker.ContainerInfo, *docker.Client, Options) error {
+	q.pings++
+	if !q.ready {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (q *pingQuerier) Query(context.Context, *docker.ContainerInfo, *docker.Client, Options, string) (string, error) {
+	return "", nil
+}
+
+func TestWaitForPing(t *testing.T) {
+	ctx := context.Background()
+
+	ready := &pingQuerier{ready: true}
+	require.NoError(t, waitForPing(ctx, ready, nil, &docker.ContainerInfo{}, nil, time.Second))
+	assert.Equal(t, 1, ready.pings)
+
+	starting := &pingQuerier{}
+	err := waitForPing(ctx, starting, nil, &docker.ContainerInfo{}, nil, 10*time.Millisecond)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not ready after 10ms")
+	assert.Contains(t, err.Error(), "connection refused")
+}
+
+func TestRestoreTest_RejectsUnsupportedRequests(t *testing.T) {
+	m := NewManager(newFakeDocker(t), newFailoverManager(t).poolManager, nil, nil, nil, nil, nil, config.New())
+	m.containers["app"] = &config.ContainerConfig{
+		ContainerName: "app",
+		Backups: []config.BackupConfig{
+			{Name: "files", BackupType: "check-test", Storage: "s3"},
+			{Name: "more", BackupType: "check-test", Storage: "s3"},
+		},
+	}
+	ctx := context.Background()
+
+	// Only database types can run a query
+	_, err := m.RestoreTest(ctx, "app", "app/files/2026-01-01/030000.bin", RestoreTestOptions{Query: "SELECT 1"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not support queries")
+
+	_, err = m.RestoreTest(ctx, "app", LatestKey, RestoreTestOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "has 2 backup configs")
+
+	_, err = m.RestoreTest(ctx, "app", "app/other/2026-01-01/030000.bin", RestoreTestOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no backup configuration found")
+}
@@ -0,0 +1,105 @@
+package backup
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/shyim/docker-backup/internal/config"
+	"github.com/shyim/docker-backup/internal/retention"
+	"github.com/shyim/docker-backup/internal/storage"
+)
+
+// RetentionPreview lists the backups of one backup config that retention
+// would delete from one storage pool
+type RetentionPreview struct {
+	Config  string               `json:"config"`
+	Part    string               `json:"part,omitempty"` // Part of a split backup
+	Storage string               `json:"storage"`
+	Backups []storage.BackupFile `json:"backups,omitempty"` // Newest first
+	Error   string               `json:"error,omitempty"`
+}
+
+// retentionPolicy returns the retention policy of a backup config
+func retentionPolicy(backup config.BackupConfig) retention.Policy {
+	return retention.Policy{
+		KeepCount:    backup.Retention,
+		MaxAge:       backup.RetentionMaxAge,
+		MaxTotalSize: backup.MaxTotalSize,
+		MinKeep:      backup.MinKeep,
+	}
+}
+
+// retentionPrefixes returns the key prefixes retention is enforced below in
+// pool. A split backup config has one per part found in the pool, so each
+// database keeps its own history, including those that no longer exist.
+func (m *Manager) retentionPrefixes(ctx context.Context, cfg *config.ContainerConfig, backup config.BackupConfig, pool string) ([]string, error) {
+	prefix := configKeyPrefix(cfg.ContainerName, backup)
+	if split, _ := Options(backup.Options).Bool(OptionSplit, false); !split {
+		return []string{prefix}, nil
+	}
+
+	store, err := m.poolManager.GetForContainer(pool)
+	if err != nil {
+		return nil, err
+	}
+	files, err := store.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parts are stored as <prefix><part>/<date>/<file>
+	var prefixes []string
+	for _, f := range files {
+		segments := strings.Split(strings.TrimPrefix(f.Key, prefix), "/")
+		if len(segments) != 3 {
+			continue
+		}
+		if partPrefix := prefix + segments[0] + "/"; !slices.Contains(prefixes, partPrefix) {
+			prefixes = append(prefixes, partPrefix)
+		}
+	}
+	slices.Sort(prefixes)
+	return prefixes, nil
+}
+
+// PreviewRetention returns the backups retention would delete for each backup
+// config and pool of a container, without deleting anything. A pool that
+// can't be listed is reported in its preview's Error.
+func (m *Manager) PreviewRetention(ctx context.Context, containerName string) ([]RetentionPreview, error) {
+	cfg, _, err := m.findContainerConfig(ctx, containerName)
+	if err != nil {
+		return nil, err
+	}
+
+	var previews []RetentionPreview
+	for _, backup := range cfg.Backups {
+		for _, pool := range backup.Pools() {
+			prefixes, err := m.retentionPrefixes(ctx, cfg, backup, pool)
+			if err != nil {
+				previews = append(previews, RetentionPreview{
+					Config:  backup.Name,
+					Storage: m.poolManager.PoolName(pool),
+					Error:   err.Error(),
+				})
+				continue
+			}
+
+			configPrefix := configKeyPrefix(cfg.ContainerName, backup)
+			for _, prefix := range prefixes {
+				expired, err := m.retention.Preview(ctx, pool, prefix, retentionPolicy(backup))
+				preview := RetentionPreview{
+					Config:  backup.Name,
+					Part:    strings.TrimSuffix(strings.TrimPrefix(prefix, configPrefix), "/"),
+					Storage: m.poolManager.PoolName(pool),
+					Backups: expired,
+				}
+				if err != nil {
+					preview.Error = err.Error()
+				}
+				previews = append(previews, preview)
+			}
+		}
+	}
+	return previews, nil
+}
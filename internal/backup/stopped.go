@@ -0,0 +1,52 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/docker"
+)
+
+// stoppedStartTimeout bounds how long the database in a container started for
+// a backup may take to accept connections
+const stoppedStartTimeout = 2 * time.Minute
+
+// startForBackup starts a stopped container for a config with when-stopped
+// set to start and, for backup types implementing Querier, waits until its
+// database answers. It returns the info of the running container and a
+// function stopping it again.
+func (m *Manager) startForBackup(ctx context.Context, dockerClient *docker.Client, container *docker.ContainerInfo, backupType BackupType, opts Options) (*docker.ContainerInfo, func(), error) {
+	slog.Info("starting stopped container for backup", "container", container.Name)
+	if err := dockerClient.StartContainer(ctx, container.ID); err != nil {
+		return nil, nil, fmt.Errorf("failed to start container: %w", err)
+	}
+
+	stop := func() {
+		// Also after a cancelled backup, the container is left stopped as it was found
+		if err := dockerClient.StopContainer(context.WithoutCancel(ctx), container.ID, 30*time.Second); err != nil {
+			slog.Warn("failed to stop container after backup", "container", container.Name, "error", err)
+			return
+		}
+		slog.Info("stopped container again after backup", "container", container.Name)
+	}
+
+	started, err := dockerClient.GetContainer(ctx, container.ID)
+	if err != nil {
+		stop()
+		return nil, nil, fmt.Errorf("failed to get container info: %w", err)
+	}
+	// The node client doesn't know the node the container was qualified with
+	started.Node = container.Node
+	started.Name = container.Name
+
+	if querier, ok := backupType.(Querier); ok {
+		if err := waitForPing(ctx, querier, dockerClient, started, opts, stoppedStartTimeout); err != nil {
+			stop()
+			return nil, nil, err
+		}
+	}
+
+	return started, stop, nil
+}
@@ -0,0 +1,73 @@
+package backup
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+
+	"github.com/shyim/docker-backup/internal/config"
+	"github.com/shyim/docker-backup/internal/docker"
+	"github.com/shyim/docker-backup/internal/notification"
+	"github.com/shyim/docker-backup/internal/retention"
+	"github.com/stretchr/testify/assert"
+)
+
+// stateBackup records whether the container ran while it was backed up
+type stateBackup struct {
+	runs    atomic.Int32
+	running atomic.Bool
+}
+
+func (b *stateBackup) Name() string                                  { return "state" }
+func (b *stateBackup) FileExtension(Options) string                  { return ".bin" }
+func (b *stateBackup) Validate(*docker.ContainerInfo, Options) error { return nil }
+func (b *stateBackup) Restore(context.Context, *docker.ContainerInfo, *docker.Client, Options, io.Reader) error {
+	return nil
+}
+
+func (b *stateBackup) Backup(_ context.Context, container *docker.ContainerInfo, _ *docker.Client, _ Options, w io.Writer) error {
+	b.runs.Add(1)
+	b.running.Store(container.Running)
+	_, err := w.Write([]byte("data"))
+	return err
+}
+
+func TestRunBackup_StoppedContainer(t *testing.T) {
+	for _, tt := range []struct {
+		whenStopped string
+		runs        int32
+		running     bool
+		calls       []string
+		events      []notification.EventType
+	}{
+		// A skipped run isn't announced, as nothing would close its started event
+		{whenStopped: "", runs: 0},
+		{whenStopped: config.StoppedSkip, runs: 0},
+		{whenStopped: config.StoppedBackup, runs: 1, running: false, events: []notification.EventType{notification.EventBackupStarted, notification.EventBackupCompleted}},
+		{whenStopped: config.StoppedStart, runs: 1, running: true, calls: []string{"start", "stop"}, events: []notification.EventType{notification.EventBackupStarted, notification.EventBackupCompleted}},
+	} {
+		t.Run(tt.whenStopped, func(t *testing.T) {
+			fake := &fakeDocker{}
+			dockerClient := newFakeDocker(t, fake)
+			pm := newFailoverManager(t).poolManager
+			notifier := &recordingNotifier{}
+			notifyMgr := notification.NewManager()
+			notifyMgr.AddNotifier("rec", notifier)
+			m := NewManager(dockerClient, pm, nil, retention.New(pm), notifyMgr, nil, nil, config.New())
+			cfg := &config.ContainerConfig{ContainerName: "batch", Notify: []string{"rec"}, NotifyOn: []string{config.NotifyOnStarted, config.NotifyOnCompleted}}
+			backup := config.BackupConfig{Name: "data", BackupType: "state", Storage: "s3", Retention: 1, WhenStopped: tt.whenStopped}
+
+			backupType := &stateBackup{}
+			m.runBackup(context.Background(), "batch", cfg, backup, backupType)
+			m.WaitNotifications()
+
+			assert.Equal(t, tt.runs, backupType.runs.Load())
+			assert.Equal(t, tt.running, backupType.running.Load())
+			assert.Equal(t, tt.calls, fake.calls)
+			assert.False(t, fake.running, "the container is left stopped")
+			assert.Empty(t, m.jobs.failureHistory(m.makeJobKey("batch", "data")))
+			assert.ElementsMatch(t, tt.events, notifier.sent(), "notifications are sent concurrently")
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+)
+
+// RestoreFromReader restores a container from an archive read from r, e.g. a
+// file uploaded to the dashboard. The backup type, options and notifications
+// come from the container's backup config configName. fileName only names the
+// archive in logs and notifications.
+func (m *Manager) RestoreFromReader(ctx context.Context, containerName, configName, fileName string, r io.Reader) error {
+	cfg, containerID, err := m.findContainerConfig(ctx, containerName)
+	if err != nil {
+		return err
+	}
+
+	backupCfg, err := m.findBackupConfig(cfg, configName)
+	if err != nil {
+		return err
+	}
+
+	backupType, ok := Get(backupCfg.BackupType)
+	if !ok {
+		return fmt.Errorf("unknown backup type %q", backupCfg.BackupType)
+	}
+
+	open := func(context.Context) (io.ReadCloser, int64, error) {
+		return io.NopCloser(r), 0, nil
+	}
+
+	return m.restoreStream(ctx, cfg, containerID, *backupCfg, backupType, nil, "upload:"+path.Base(fileName), open)
+}
@@ -0,0 +1,89 @@
+package backup
+
+import (
+	"fmt"
+
+	"github.com/shyim/docker-backup/internal/config"
+	"github.com/shyim/docker-backup/internal/scheduler"
+	"github.com/shyim/docker-backup/internal/storage"
+)
+
+// ConfigCheck is the result of validating one backup config without a container
+type ConfigCheck struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Schedule string   `json:"schedule"`
+	Storage  string   `json:"storage,omitempty"`  // Pool the config resolves to
+	Fallback []string `json:"fallback,omitempty"` // Pools tried when storing to Storage fails
+	Mirrors  []string `json:"mirrors,omitempty"`  // Pools every backup is copied to
+	Valid    bool     `json:"valid"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// CheckBackupConfig validates a parsed backup config against the cron parser,
+// the registered backup types and the configured storage pools. A nil pools map
+// skips the storage check. Checks that need the container, such as required
+// environment variables, are left to the backup type at run time.
+func CheckBackupConfig(b config.BackupConfig, pools map[string]*config.StoragePool, defaultStorage string) ConfigCheck {
+	check := ConfigCheck{
+		Name:     b.Name,
+		Type:     b.BackupType,
+		Schedule: b.Schedule,
+	}
+
+	if err := scheduler.ValidateSchedule(b.Schedule); err != nil {
+		check.Errors = append(check.Errors, fmt.Sprintf("invalid schedule %q: %v", b.Schedule, err))
+	}
+
+	if backupType, ok := Get(b.BackupType); !ok {
+		check.Errors = append(check.Errors, fmt.Sprintf("unknown backup type %q (available: %v)", b.BackupType, List()))
+	} else {
+		if _, err := Options(b.Options).Splitter(backupType); err != nil {
+			check.Errors = append(check.Errors, err.Error())
+		}
+		if _, err := configDeduplicator(b, backupType); err != nil {
+			check.Errors = append(check.Errors, err.Error())
+		}
+	}
+
+	if _, err := Options(b.Options).Compression(); err != nil {
+		check.Errors = append(check.Errors, err.Error())
+	}
+
+	if pools != nil {
+		poolName := b.Storage
+		if poolName == "" {
+			poolName = defaultStorage
+		}
+		check.Storage = poolName
+		check.Fallback = b.Fallback
+		check.Mirrors = b.Mirrors
+
+		if poolName == "" {
+			check.Errors = append(check.Errors, "no storage pool set and no default storage pool configured")
+		} else {
+			check.Errors = append(check.Errors, checkPool(poolName, pools)...)
+		}
+		for _, fallback := range b.Fallback {
+			check.Errors = append(check.Errors, checkPool(fallback, pools)...)
+		}
+		for _, mirror := range b.Mirrors {
+			check.Errors = append(check.Errors, checkPool(mirror, pools)...)
+		}
+	}
+
+	check.Valid = len(check.Errors) == 0
+	return check
+}
+
+// checkPool reports problems with a configured storage pool
+func checkPool(name string, pools map[string]*config.StoragePool) []string {
+	pool, ok := pools[name]
+	if !ok {
+		return []string{fmt.Sprintf("storage pool %q not found", name)}
+	}
+	if _, ok := storage.Get(pool.Type); !ok {
+		return []string{fmt.Sprintf("storage pool %q has unknown type %q (available: %v)", name, pool.Type, storage.List())}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/shyim/docker-backup/internal/config"
+	"github.com/shyim/docker-backup/internal/docker"
+	"github.com/shyim/docker-backup/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+type checkBackupType struct{}
+
+func (checkBackupType) Name() string                 { return "check-test" }
+func (checkBackupType) FileExtension(Options) string { return ".bin" }
+func (checkBackupType) Backup(context.Context, *docker.ContainerInfo, *docker.Client, Options, io.Writer) error {
+	return nil
+}
+func (checkBackupType) Restore(context.Context, *docker.ContainerInfo, *docker.Client, Options, io.Reader) error {
+	return nil
+}
+func (checkBackupType) Validate(*docker.ContainerInfo, Options) error { return nil }
+
+type checkStorageType struct{}
+
+func (checkStorageType) Name() string { return "check-test" }
+func (checkStorageType) Create(string, map[string]string) (storage.Storage, error) {
+	return nil, errors.New("not used")
+}
+
+func init() {
+	Register(checkBackupType{})
+	storage.Register(checkStorageType{})
+}
+
+func TestCheckBackupConfig(t *testing.T) {
+	pools := map[string]*config.StoragePool{
+		"main":   {Name: "main", Type: "check-test"},
+		"broken": {Name: "broken", Type: "ftp"},
+	}
+	valid := config.BackupConfig{Name: "db", BackupType: "check-test", Schedule: "0 3 * * *"}
+
+	check := CheckBackupConfig(valid, pools, "main")
+	assert.True(t, check.Valid)
+	assert.Empty(t, check.Errors)
+	assert.Equal(t, "main", check.Storage)
+
+	tests := []struct {
+		name           string
+		modify         func(b *config.BackupConfig)
+		defaultStorage string
+		errorContains  string
+	}{
+		{name: "bad schedule", modify: func(b *config.BackupConfig) { b.Schedule = "every day" }, defaultStorage: "main", errorContains: "invalid schedule"},
+		{name: "unknown type", modify: func(b *config.BackupConfig) { b.BackupType = "oracle" }, defaultStorage: "main", errorContains: "unknown backup type"},
+		{name: "missing pool", modify: func(b *config.BackupConfig) { b.Storage = "offsite" }, defaultStorage: "main", errorContains: `storage pool "offsite" not found`},
+		{name: "no default pool", modify: func(b *config.BackupConfig) {}, errorContains: "no default storage pool"},
+		{name: "unknown storage type", modify: func(b *config.BackupConfig) { b.Storage = "broken" }, errorContains: `unknown type "ftp"`},
+		{name: "split unsupported", modify: func(b *config.BackupConfig) { b.Options = map[string]string{OptionSplit: "true"} }, defaultStorage: "main", errorContains: "doesn't support the split option"},
+		{name: "dedup unsupported", modify: func(b *config.BackupConfig) { b.Options = map[string]string{OptionDedup: "true"} }, defaultStorage: "main", errorContains: "doesn't support the dedup option"},
+		{name: "missing fallback pool", modify: func(b *config.BackupConfig) { b.Fallback = []string{"offsite"} }, defaultStorage: "main", errorContains: `storage pool "offsite" not found`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := valid
+			tt.modify(&b)
+			check := CheckBackupConfig(b, pools, tt.defaultStorage)
+			assert.False(t, check.Valid)
+			if assert.Len(t, check.Errors, 1) {
+				assert.Contains(t, check.Errors[0], tt.errorContains)
+			}
+		})
+	}
+
+	// Without pools the storage check is skipped
+	check = CheckBackupConfig(valid, nil, "")
+	assert.True(t, check.Valid)
+	assert.Empty(t, check.Storage)
+}
@@ -8,8 +8,8 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
-	"github.com/klauspost/compress/zstd"
 	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/shyim/docker-backup/internal/compression"
 	"github.com/shyim/docker-backup/internal/docker"
 )
 
@@ -45,18 +45,18 @@ func (c *ClickHouseBackup) Name() string {
 	return "clickhouse"
 }
 
-func (c *ClickHouseBackup) FileExtension() string {
-	return ".tar.zst"
+func (c *ClickHouseBackup) FileExtension(opts backup.Options) string {
+	return opts.Extension(".tar")
 }
 
-func (c *ClickHouseBackup) Validate(container *docker.ContainerInfo) error {
+func (c *ClickHouseBackup) Validate(container *docker.ContainerInfo, opts backup.Options) error {
 	// No env vars required — ClickHouse works with defaults (user=default, no password).
 	// Version and clickhouse-client checks run at the start of Backup/Restore
 	// where the docker client is available.
 	return nil
 }
 
-func (c *ClickHouseBackup) Backup(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, w io.Writer) error {
+func (c *ClickHouseBackup) Backup(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options, w io.Writer) error {
 	if err := c.checkVersion(ctx, container, dockerClient); err != nil {
 		return err
 	}
@@ -69,10 +69,10 @@ func (c *ClickHouseBackup) Backup(ctx context.Context, container *docker.Contain
 		return fmt.Errorf("failed to configure backup path: %w", err)
 	}
 
-	_, _ = dockerClient.Exec(ctx, container.ID, []string{"rm", "-rf", backupTmpDir}, nil)
+	_, _ = dockerClient.Exec(ctx, container.ID, []string{"rm", "-rf", backupTmpDir}, nil, nil)
 
 	defer func() {
-		_, _ = dockerClient.Exec(ctx, container.ID, []string{"rm", "-rf", backupPath}, nil)
+		_, _ = dockerClient.Exec(ctx, container.ID, []string{"rm", "-rf", backupPath}, nil, nil)
 	}()
 
 	databases, err := c.getDatabases(ctx, container, dockerClient, user, password)
@@ -94,30 +94,35 @@ func (c *ClickHouseBackup) Backup(ctx context.Context, container *docker.Contain
 		return fmt.Errorf("backup failed: %w", err)
 	}
 
-	zstdWriter, err := zstd.NewWriter(w)
+	codec, err := opts.Compression()
 	if err != nil {
-		return fmt.Errorf("failed to create zstd writer: %w", err)
+		return err
+	}
+	compressor, err := codec.NewWriter(w)
+	if err != nil {
+		return err
 	}
 	defer func() {
-		_ = zstdWriter.Close()
+		_ = compressor.Close()
 	}()
 
-	exitCode, err := dockerClient.ExecWithOutput(ctx, container.ID,
+	result, err := dockerClient.ExecWithOutput(ctx, container.ID,
 		[]string{"tar", "-c", "-C", backupTmpDir, backupID},
-		zstdWriter,
+		nil,
+		compressor,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to stream backup: %w", err)
 	}
 
-	if exitCode != 0 {
-		return fmt.Errorf("tar failed with exit code %d", exitCode)
+	if result.ExitCode != 0 {
+		return fmt.Errorf("tar failed with exit code %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
 	}
 
 	return nil
 }
 
-func (c *ClickHouseBackup) Restore(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, r io.Reader) error {
+func (c *ClickHouseBackup) Restore(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options, r io.Reader) error {
 	if err := c.checkVersion(ctx, container, dockerClient); err != nil {
 		return err
 	}
@@ -127,23 +132,26 @@ func (c *ClickHouseBackup) Restore(ctx context.Context, container *docker.Contai
 	restorePath := backupTmpDir + "/" + restoreID
 
 	defer func() {
-		_, _ = dockerClient.Exec(ctx, container.ID, []string{"rm", "-rf", restorePath}, nil)
+		_, _ = dockerClient.Exec(ctx, container.ID, []string{"rm", "-rf", restorePath}, nil, nil)
 	}()
 
-	result, err := dockerClient.Exec(ctx, container.ID, []string{"mkdir", "-p", backupTmpDir}, nil)
+	result, err := dockerClient.Exec(ctx, container.ID, []string{"mkdir", "-p", backupTmpDir}, nil, nil)
 	if err != nil || result.ExitCode != 0 {
 		return fmt.Errorf("failed to create temp directory: %w", err)
 	}
 
-	zstdReader, err := zstd.NewReader(r)
+	decompressor, err := compression.NewReader(r)
 	if err != nil {
-		return fmt.Errorf("failed to create zstd reader: %w", err)
+		return err
 	}
-	defer zstdReader.Close()
+	defer func() {
+		_ = decompressor.Close()
+	}()
 
 	result, err = dockerClient.Exec(ctx, container.ID,
 		[]string{"tar", "-x", "-C", backupTmpDir},
-		zstdReader,
+		nil,
+		decompressor,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to extract backup: %w", err)
@@ -153,7 +161,7 @@ func (c *ClickHouseBackup) Restore(ctx context.Context, container *docker.Contai
 		return fmt.Errorf("tar extract failed with exit code %d: %s", result.ExitCode, result.Output)
 	}
 
-	result, err = dockerClient.Exec(ctx, container.ID, []string{"ls", backupTmpDir}, nil)
+	result, err = dockerClient.Exec(ctx, container.ID, []string{"ls", backupTmpDir}, nil, nil)
 	if err != nil {
 		return fmt.Errorf("failed to list backup directory: %w", err)
 	}
@@ -176,6 +184,18 @@ func (c *ClickHouseBackup) Restore(ctx context.Context, container *docker.Contai
 	return nil
 }
 
+// Ping checks that the server answers a query
+func (c *ClickHouseBackup) Ping(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options) error {
+	_, err := c.Query(ctx, container, dockerClient, opts, "SELECT 1")
+	return err
+}
+
+// Query runs query with clickhouse-client
+func (c *ClickHouseBackup) Query(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options, query string) (string, error) {
+	user, password := c.getCredentials(container.Env)
+	return c.execQueryWithOutput(ctx, container, dockerClient, user, password, query)
+}
+
 func (c *ClickHouseBackup) getCredentials(env map[string]string) (user, password string) {
 	user = env[EnvClickHouseUser]
 	if user == "" {
@@ -211,7 +231,7 @@ func (c *ClickHouseBackup) getDatabases(ctx context.Context, container *docker.C
 }
 
 func (c *ClickHouseBackup) checkVersion(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client) error {
-	result, err := dockerClient.Exec(ctx, container.ID, []string{"clickhouse-client", "--version"}, nil)
+	result, err := dockerClient.Exec(ctx, container.ID, []string{"clickhouse-client", "--version"}, nil, nil)
 	if err != nil {
 		return fmt.Errorf("clickhouse-client not found in container %s: %w", container.Name, err)
 	}
@@ -267,7 +287,7 @@ func parseVersion(output string) (major, minor int, err error) {
 func (c *ClickHouseBackup) execQuery(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, user, password, query string) error {
 	cmd := c.buildClientCmd(user, password, query)
 
-	result, err := dockerClient.Exec(ctx, container.ID, cmd, nil)
+	result, err := dockerClient.Exec(ctx, container.ID, cmd, nil, nil)
 	if err != nil {
 		return fmt.Errorf("failed to execute query: %w", err)
 	}
@@ -282,7 +302,7 @@ func (c *ClickHouseBackup) execQuery(ctx context.Context, container *docker.Cont
 func (c *ClickHouseBackup) execQueryWithOutput(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, user, password, query string) (string, error) {
 	cmd := c.buildClientCmd(user, password, query)
 
-	result, err := dockerClient.Exec(ctx, container.ID, cmd, nil)
+	result, err := dockerClient.Exec(ctx, container.ID, cmd, nil, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to execute query: %w", err)
 	}
@@ -303,6 +323,7 @@ func (c *ClickHouseBackup) ensureBackupPathAllowed(ctx context.Context, containe
 	result, err := dockerClient.Exec(ctx, container.ID,
 		[]string{"sh", "-c", fmt.Sprintf("echo '%s' > %s", configXML, configPath)},
 		nil,
+		nil,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to write backup config: %w", err)
@@ -314,6 +335,7 @@ func (c *ClickHouseBackup) ensureBackupPathAllowed(ctx context.Context, containe
 	_, _ = dockerClient.Exec(ctx, container.ID,
 		[]string{"clickhouse-client", "--query", "SYSTEM RELOAD CONFIG"},
 		nil,
+		nil,
 	)
 
 	return nil
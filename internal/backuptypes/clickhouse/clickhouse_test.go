@@ -22,7 +22,7 @@ func TestClickHouseBackup_Name(t *testing.T) {
 
 func TestClickHouseBackup_FileExtension(t *testing.T) {
 	c := &ClickHouseBackup{}
-	assert.Equal(t, ".tar.zst", c.FileExtension())
+	assert.Equal(t, ".tar.zst", c.FileExtension(nil))
 }
 
 func TestClickHouseBackup_Validate(t *testing.T) {
@@ -54,7 +54,7 @@ func TestClickHouseBackup_Validate(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := c.Validate(tt.container)
+			err := c.Validate(tt.container, nil)
 			assert.NoError(t, err)
 		})
 	}
@@ -208,7 +208,7 @@ func TestClickHouseBackup_Integration(t *testing.T) {
 
 	c := &ClickHouseBackup{}
 	var backupBuffer bytes.Buffer
-	err = c.Backup(ctx, containerInfo, dockerClient, &backupBuffer)
+	err = c.Backup(ctx, containerInfo, dockerClient, nil, &backupBuffer)
 	require.NoError(t, err)
 	assert.Greater(t, backupBuffer.Len(), 0, "backup should not be empty")
 	t.Logf("Backup size: %d bytes", backupBuffer.Len())
@@ -221,7 +221,7 @@ func TestClickHouseBackup_Integration(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, 0, count, "users table should be dropped")
 
-	err = c.Restore(ctx, containerInfo, dockerClient, &backupBuffer)
+	err = c.Restore(ctx, containerInfo, dockerClient, nil, &backupBuffer)
 	require.NoError(t, err)
 
 	err = db.QueryRow(`SELECT count() FROM testdb.users`).Scan(&count)
@@ -288,7 +288,7 @@ func TestClickHouseBackup_SpecificDatabase(t *testing.T) {
 
 	c := &ClickHouseBackup{}
 	var backupBuffer bytes.Buffer
-	err = c.Backup(ctx, containerInfo, dockerClient, &backupBuffer)
+	err = c.Backup(ctx, containerInfo, dockerClient, nil, &backupBuffer)
 	require.NoError(t, err)
 	assert.Greater(t, backupBuffer.Len(), 0)
 	t.Logf("Backup size: %d bytes", backupBuffer.Len())
@@ -298,7 +298,7 @@ func TestClickHouseBackup_SpecificDatabase(t *testing.T) {
 	_, err = db.Exec(`DROP TABLE myapp.products`)
 	require.NoError(t, err)
 
-	err = c.Restore(ctx, containerInfo, dockerClient, &backupBuffer)
+	err = c.Restore(ctx, containerInfo, dockerClient, nil, &backupBuffer)
 	require.NoError(t, err)
 
 	var count int
@@ -378,7 +378,7 @@ func TestClickHouseBackup_LargeData(t *testing.T) {
 
 	c := &ClickHouseBackup{}
 	var backupBuffer bytes.Buffer
-	err = c.Backup(ctx, containerInfo, dockerClient, &backupBuffer)
+	err = c.Backup(ctx, containerInfo, dockerClient, nil, &backupBuffer)
 	require.NoError(t, err)
 
 	t.Logf("Large data backup size: %d bytes", backupBuffer.Len())
@@ -386,7 +386,7 @@ func TestClickHouseBackup_LargeData(t *testing.T) {
 	_, err = db.Exec(`DROP TABLE testdb.large_data`)
 	require.NoError(t, err)
 
-	err = c.Restore(ctx, containerInfo, dockerClient, &backupBuffer)
+	err = c.Restore(ctx, containerInfo, dockerClient, nil, &backupBuffer)
 	require.NoError(t, err)
 
 	var count int
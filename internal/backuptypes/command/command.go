@@ -0,0 +1,161 @@
+package command
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/shyim/docker-backup/internal/backuptypes/dbdump"
+	"github.com/shyim/docker-backup/internal/compression"
+	"github.com/shyim/docker-backup/internal/docker"
+)
+
+func init() {
+	backup.Register(&CommandBackup{})
+}
+
+// Options understood by the command backup type
+const (
+	// OptionBackupCmd is run in the container, its stdout is the backup
+	OptionBackupCmd = "backup-cmd"
+	// OptionRestoreCmd is run in the container with the backup on stdin
+	OptionRestoreCmd = "restore-cmd"
+	// OptionShell runs the commands, so they may use pipes and variables
+	OptionShell = "shell"
+)
+
+const (
+	defaultShell = "/bin/sh"
+
+	// dumpEntry is the archive entry holding the output of the backup command
+	dumpEntry = "dump"
+)
+
+type CommandBackup struct{}
+
+func (c *CommandBackup) Name() string {
+	return "command"
+}
+
+func (c *CommandBackup) FileExtension(opts backup.Options) string {
+	return opts.Extension(".tar")
+}
+
+func (c *CommandBackup) Validate(container *docker.ContainerInfo, opts backup.Options) error {
+	if strings.TrimSpace(opts.String(OptionBackupCmd, "")) == "" {
+		return fmt.Errorf("container %s is missing the %s option", container.Name, OptionBackupCmd)
+	}
+	return nil
+}
+
+// Backup runs the backup command in the container and streams its stdout into
+// the archive as it is produced
+func (c *CommandBackup) Backup(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options, w io.Writer) error {
+	cmd := opts.String(OptionBackupCmd, "")
+	if strings.TrimSpace(cmd) == "" {
+		return fmt.Errorf("container %s is missing the %s option", container.Name, OptionBackupCmd)
+	}
+
+	codec, err := opts.Compression()
+	if err != nil {
+		return err
+	}
+	compressor, err := codec.NewWriter(w)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = compressor.Close()
+	}()
+
+	tarWriter := tar.NewWriter(compressor)
+	defer func() {
+		_ = tarWriter.Close()
+	}()
+
+	chunkWriter := dbdump.NewChunkWriter(tarWriter, dumpEntry, dbdump.DefaultChunkSize)
+
+	result, err := dockerClient.ExecWithOutput(ctx, container.ID, shellCommand(opts, cmd), nil, chunkWriter)
+	if err != nil {
+		return fmt.Errorf("failed to execute backup command: %w", err)
+	}
+
+	if result.ExitCode != 0 {
+		return fmt.Errorf("backup command failed with exit code %d: %s", result.ExitCode, result.ErrorOutput())
+	}
+
+	if err := chunkWriter.Close(); err != nil {
+		return err
+	}
+	if err := tarWriter.Close(); err != nil {
+		return fmt.Errorf("failed to close tar writer: %w", err)
+	}
+	return compressor.Close()
+}
+
+// Restore pipes the output stored by Backup into the restore command
+func (c *CommandBackup) Restore(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options, r io.Reader) error {
+	cmd := opts.String(OptionRestoreCmd, "")
+	if strings.TrimSpace(cmd) == "" {
+		return fmt.Errorf("container %s has no %s option, backups of it can't be restored", container.Name, OptionRestoreCmd)
+	}
+
+	decompressor, err := compression.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = decompressor.Close()
+	}()
+
+	return dbdump.Restore(ctx, dbdump.NewReader(tar.NewReader(decompressor)), 1, func(ctx context.Context, entry *dbdump.Entry, data io.Reader) error {
+		result, err := dockerClient.Exec(ctx, container.ID, shellCommand(opts, cmd), nil, data)
+		if err != nil {
+			return fmt.Errorf("failed to execute restore command: %w", err)
+		}
+
+		if result.ExitCode != 0 {
+			return fmt.Errorf("restore command failed with exit code %d: %s", result.ExitCode, result.ErrorOutput())
+		}
+		return nil
+	})
+}
+
+// ListArchive reports the stored command output with its size
+func (c *CommandBackup) ListArchive(ctx context.Context, r io.Reader, fn func(backup.ArchiveEntry) error) error {
+	decompressor, err := compression.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = decompressor.Close()
+	}()
+
+	return dbdump.List(dbdump.NewReader(tar.NewReader(decompressor)), func(entry *dbdump.Entry, size int64) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fn(backup.ArchiveEntry{Name: entry.Name, Size: size})
+	})
+}
+
+// Extract writes the stored command output to dest/dump
+func (c *CommandBackup) Extract(ctx context.Context, r io.Reader, dest string) error {
+	decompressor, err := compression.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = decompressor.Close()
+	}()
+
+	return dbdump.Extract(ctx, dbdump.NewReader(tar.NewReader(decompressor)), dest)
+}
+
+// shellCommand wraps cmd in the configured shell
+func shellCommand(opts backup.Options, cmd string) []string {
+	return []string{opts.String(OptionShell, defaultShell), "-c", cmd}
+}
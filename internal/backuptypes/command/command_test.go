@@ -0,0 +1,115 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/shyim/docker-backup/internal/docker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+func TestCommandBackup_Name(t *testing.T) {
+	c := &CommandBackup{}
+	assert.Equal(t, "command", c.Name())
+}
+
+func TestCommandBackup_FileExtension(t *testing.T) {
+	c := &CommandBackup{}
+	assert.Equal(t, ".tar.zst", c.FileExtension(nil))
+}
+
+func TestCommandBackup_Validate(t *testing.T) {
+	c := &CommandBackup{}
+	container := &docker.ContainerInfo{Name: "test"}
+
+	assert.NoError(t, c.Validate(container, backup.Options{OptionBackupCmd: "influx backup -"}))
+	assert.NoError(t, c.Validate(container, backup.Options{OptionBackupCmd: "cat /data/db", OptionRestoreCmd: "cat > /data/db"}))
+	assert.Error(t, c.Validate(container, nil))
+	assert.Error(t, c.Validate(container, backup.Options{OptionBackupCmd: "  ", OptionRestoreCmd: "cat > /data/db"}))
+}
+
+func TestShellCommand(t *testing.T) {
+	assert.Equal(t, []string{"/bin/sh", "-c", "pg_dumpall | gzip"}, shellCommand(nil, "pg_dumpall | gzip"))
+	assert.Equal(t, []string{"bash", "-c", "echo"}, shellCommand(backup.Options{OptionShell: "bash"}, "echo"))
+}
+
+// TestCommandBackup_Integration backs up and restores a file through shell
+// commands in a real container via testcontainers
+func TestCommandBackup_Integration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+
+	req := testcontainers.ContainerRequest{
+		Image:      "alpine:latest",
+		Cmd:        []string{"sh", "-c", "mkdir -p /data && echo 'hello from the app' > /data/state.txt && sleep 3600"},
+		WaitingFor: wait.ForExec([]string{"test", "-f", "/data/state.txt"}).WithStartupTimeout(30 * time.Second),
+	}
+
+	ctr, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	require.NoError(t, err)
+	defer func() {
+		if err := ctr.Terminate(ctx); err != nil {
+			t.Logf("failed to terminate container: %v", err)
+		}
+	}()
+
+	dockerClient, err := docker.NewClient("")
+	require.NoError(t, err)
+	defer func() {
+		_ = dockerClient.Close()
+	}()
+
+	containerInfo, err := dockerClient.GetContainer(ctx, ctr.GetContainerID())
+	require.NoError(t, err)
+
+	opts := backup.Options{
+		OptionBackupCmd:  "cat /data/state.txt",
+		OptionRestoreCmd: "cat > /data/state.txt",
+	}
+
+	c := &CommandBackup{}
+	require.NoError(t, c.Validate(containerInfo, opts))
+
+	var backupBuffer bytes.Buffer
+	require.NoError(t, c.Backup(ctx, containerInfo, dockerClient, opts, &backupBuffer))
+
+	dest := t.TempDir()
+	require.NoError(t, c.Extract(ctx, bytes.NewReader(backupBuffer.Bytes()), dest))
+	extracted, err := os.ReadFile(filepath.Join(dest, dumpEntry))
+	require.NoError(t, err)
+	assert.Equal(t, "hello from the app\n", string(extracted))
+
+	// Simulate data loss
+	_, _, err = ctr.Exec(ctx, []string{"sh", "-c", "echo broken > /data/state.txt"})
+	require.NoError(t, err)
+
+	require.NoError(t, c.Restore(ctx, containerInfo, dockerClient, opts, bytes.NewReader(backupBuffer.Bytes())))
+
+	exitCode, reader, err := ctr.Exec(ctx, []string{"cat", "/data/state.txt"})
+	require.NoError(t, err)
+	require.Equal(t, 0, exitCode)
+	output, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Contains(t, string(output), "hello from the app")
+
+	// A failing backup command fails the backup with its stderr
+	err = c.Backup(ctx, containerInfo, dockerClient, backup.Options{OptionBackupCmd: "echo nope >&2; exit 3"}, io.Discard)
+	require.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "exit code 3") && strings.Contains(err.Error(), "nope"), err.Error())
+}
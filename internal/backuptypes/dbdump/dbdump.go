@@ -0,0 +1,244 @@
+// Package dbdump implements the archive layout shared by the database backup types.
+//
+// A database archive is a compressed tar with one entry per dumped database.
+// Buffered dumps are written as a single regular entry ("app.sql"), which needs the
+// dump size up front and therefore a temp file. Streamed dumps write the dump
+// straight from the exec stream into consecutive chunk entries of bounded size:
+//
+//	app.sql.part-000000
+//	app.sql.part-000001
+//	...
+//
+// Readers join consecutive chunks with the same base name back into one stream, so
+// both layouts restore through the same code path and old archives stay readable.
+package dbdump
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// DefaultChunkSize is the amount of dump data buffered in memory per chunk entry
+const DefaultChunkSize = 16 << 20
+
+var chunkPattern = regexp.MustCompile(`^(.+)\.part-(\d{6})$`)
+
+// ChunkName returns the tar entry name of chunk index for a streamed entry
+func ChunkName(name string, index int) string {
+	return fmt.Sprintf("%s.part-%06d", name, index)
+}
+
+// parseChunkName splits a chunk entry name into its base name and index
+func parseChunkName(name string) (string, int, bool) {
+	m := chunkPattern.FindStringSubmatch(name)
+	if m == nil {
+		return "", 0, false
+	}
+	index, err := strconv.Atoi(m[2])
+	if err != nil {
+		return "", 0, false
+	}
+	return m[1], index, true
+}
+
+// ChunkWriter writes a stream of unknown length into a tar as chunk entries
+type ChunkWriter struct {
+	tw    *tar.Writer
+	name  string
+	buf   []byte
+	index int
+}
+
+// NewChunkWriter creates a writer that stores data as chunks of name in tw.
+// At most chunkSize bytes are held in memory.
+func NewChunkWriter(tw *tar.Writer, name string, chunkSize int) *ChunkWriter {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	return &ChunkWriter{
+		tw:   tw,
+		name: name,
+		buf:  make([]byte, 0, chunkSize),
+	}
+}
+
+// Write buffers p and emits full chunks as they fill up
+func (c *ChunkWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(cap(c.buf)-len(c.buf), len(p))
+		c.buf = append(c.buf, p[:n]...)
+		p = p[n:]
+		written += n
+
+		if len(c.buf) == cap(c.buf) {
+			if err := c.flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Close writes the remaining data. An empty stream still produces one empty chunk
+// so the entry shows up on restore.
+func (c *ChunkWriter) Close() error {
+	if len(c.buf) > 0 || c.index == 0 {
+		return c.flush()
+	}
+	return nil
+}
+
+func (c *ChunkWriter) flush() error {
+	header := &tar.Header{
+		Name:    ChunkName(c.name, c.index),
+		Mode:    0644,
+		Size:    int64(len(c.buf)),
+		ModTime: time.Now(),
+	}
+
+	if err := c.tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write tar header: %w", err)
+	}
+	if _, err := c.tw.Write(c.buf); err != nil {
+		return fmt.Errorf("failed to write to tar: %w", err)
+	}
+
+	c.buf = c.buf[:0]
+	c.index++
+	return nil
+}
+
+// Entry is one logical dump inside an archive
+type Entry struct {
+	// Name is the entry name with any chunk suffix removed (e.g. "app.sql")
+	Name string
+	// Size is the dump size, or -1 for streamed entries whose size isn't known up front
+	Size int64
+	// Chunked tells whether the entry was written as chunks
+	Chunked bool
+}
+
+// Reader iterates over the logical entries of a database archive
+type Reader struct {
+	tr      *tar.Reader
+	pending *tar.Header
+	current io.Reader
+}
+
+// NewReader creates a Reader on top of tr
+func NewReader(tr *tar.Reader) *Reader {
+	return &Reader{tr: tr}
+}
+
+// Next advances to the next logical entry. It returns io.EOF at the end of the archive.
+// The returned reader is only valid until the next call to Next.
+func (r *Reader) Next() (*Entry, io.Reader, error) {
+	// Skip whatever the caller didn't consume so chunk boundaries stay intact
+	if r.current != nil {
+		if _, err := io.Copy(io.Discard, r.current); err != nil {
+			return nil, nil, err
+		}
+		r.current = nil
+	}
+
+	header := r.pending
+	r.pending = nil
+
+	for header == nil || header.Typeflag != tar.TypeReg {
+		var err error
+		header, err = r.tr.Next()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	base, index, ok := parseChunkName(header.Name)
+	if !ok {
+		r.current = r.tr
+		return &Entry{Name: header.Name, Size: header.Size}, r.tr, nil
+	}
+
+	if index != 0 {
+		return nil, nil, fmt.Errorf("archive entry %s: expected first chunk, got index %d", base, index)
+	}
+
+	cr := &chunkReader{reader: r, base: base, next: 1}
+	r.current = cr
+	return &Entry{Name: base, Size: -1, Chunked: true}, cr, nil
+}
+
+// chunkReader reads consecutive chunk entries as one stream
+type chunkReader struct {
+	reader *Reader
+	base   string
+	next   int
+	done   bool
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if c.done {
+			return 0, io.EOF
+		}
+
+		n, err := c.reader.tr.Read(p)
+		if err != io.EOF {
+			return n, err
+		}
+		if n > 0 {
+			return n, nil
+		}
+
+		header, err := c.reader.tr.Next()
+		if err == io.EOF {
+			c.done = true
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		base, index, ok := parseChunkName(header.Name)
+		if !ok || base != c.base || header.Typeflag != tar.TypeReg {
+			// Start of the next logical entry
+			c.reader.pending = header
+			c.done = true
+			continue
+		}
+
+		if index != c.next {
+			return 0, fmt.Errorf("archive entry %s: expected chunk %d, got %d", c.base, c.next, index)
+		}
+		c.next++
+	}
+}
+
+// List calls fn for every logical entry of the archive with its size. Chunked
+// entries are read to the end to measure them; nothing is buffered.
+func List(r *Reader, fn func(entry *Entry, size int64) error) error {
+	for {
+		entry, data, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		size := entry.Size
+		if entry.Chunked {
+			if size, err = io.Copy(io.Discard, data); err != nil {
+				return fmt.Errorf("failed to read archive entry %s: %w", entry.Name, err)
+			}
+		}
+
+		if err := fn(entry, size); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package dbdump
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type readEntry struct {
+	name    string
+	size    int64
+	chunked bool
+	data    string
+}
+
+func readAll(t *testing.T, archive []byte) []readEntry {
+	t.Helper()
+
+	r := NewReader(tar.NewReader(bytes.NewReader(archive)))
+
+	var entries []readEntry
+	for {
+		entry, data, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		content, err := io.ReadAll(data)
+		require.NoError(t, err)
+
+		entries = append(entries, readEntry{
+			name:    entry.Name,
+			size:    entry.Size,
+			chunked: entry.Chunked,
+			data:    string(content),
+		})
+	}
+	return entries
+}
+
+func writeRegular(t *testing.T, tw *tar.Writer, name, data string) {
+	t.Helper()
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data))}))
+	_, err := tw.Write([]byte(data))
+	require.NoError(t, err)
+}
+
+func TestChunkWriter_RoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+
+	dump := strings.Repeat("INSERT INTO t VALUES (1);\n", 100)
+
+	cw := NewChunkWriter(tw, "app.sql", 64)
+	_, err := io.Copy(cw, strings.NewReader(dump))
+	require.NoError(t, err)
+	require.NoError(t, cw.Close())
+	require.NoError(t, tw.Close())
+
+	// The dump is split into several bounded entries
+	tr := tar.NewReader(bytes.NewReader(buf.Bytes()))
+	parts := 0
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		assert.LessOrEqual(t, header.Size, int64(64))
+		assert.Equal(t, ChunkName("app.sql", parts), header.Name)
+		parts++
+	}
+	assert.Equal(t, (len(dump)+63)/64, parts)
+
+	entries := readAll(t, buf.Bytes())
+	require.Len(t, entries, 1)
+	assert.Equal(t, "app.sql", entries[0].name)
+	assert.Equal(t, int64(-1), entries[0].size)
+	assert.True(t, entries[0].chunked)
+	assert.Equal(t, dump, entries[0].data)
+}
+
+func TestChunkWriter_EmptyDump(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+
+	cw := NewChunkWriter(tw, "empty.sql", 64)
+	require.NoError(t, cw.Close())
+	require.NoError(t, tw.Close())
+
+	entries := readAll(t, buf.Bytes())
+	require.Len(t, entries, 1)
+	assert.Equal(t, "empty.sql", entries[0].name)
+	assert.Empty(t, entries[0].data)
+}
+
+func TestReader_MixedLayouts(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+
+	writeRegular(t, tw, "legacy.sql", "legacy dump")
+
+	cw := NewChunkWriter(tw, "first.sql", 4)
+	_, err := cw.Write([]byte("streamed first"))
+	require.NoError(t, err)
+	require.NoError(t, cw.Close())
+
+	cw = NewChunkWriter(tw, "second.sql", 4)
+	_, err = cw.Write([]byte("streamed second"))
+	require.NoError(t, err)
+	require.NoError(t, cw.Close())
+
+	writeRegular(t, tw, "last.sql", "trailing")
+	require.NoError(t, tw.Close())
+
+	entries := readAll(t, buf.Bytes())
+	require.Len(t, entries, 4)
+
+	assert.Equal(t, readEntry{name: "legacy.sql", size: 11, data: "legacy dump"}, entries[0])
+	assert.Equal(t, readEntry{name: "first.sql", size: -1, chunked: true, data: "streamed first"}, entries[1])
+	assert.Equal(t, readEntry{name: "second.sql", size: -1, chunked: true, data: "streamed second"}, entries[2])
+	assert.Equal(t, readEntry{name: "last.sql", size: 8, data: "trailing"}, entries[3])
+}
+
+func TestReader_SkipsUnreadEntries(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+
+	cw := NewChunkWriter(tw, "skipped.sql", 4)
+	_, err := cw.Write([]byte("not read by the caller"))
+	require.NoError(t, err)
+	require.NoError(t, cw.Close())
+	writeRegular(t, tw, "next.sql", "next")
+	require.NoError(t, tw.Close())
+
+	r := NewReader(tar.NewReader(bytes.NewReader(buf.Bytes())))
+
+	entry, _, err := r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "skipped.sql", entry.Name)
+
+	entry, data, err := r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "next.sql", entry.Name)
+	content, err := io.ReadAll(data)
+	require.NoError(t, err)
+	assert.Equal(t, "next", string(content))
+
+	_, _, err = r.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReader_MissingChunk(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	writeRegular(t, tw, ChunkName("app.sql", 0), "aaaa")
+	writeRegular(t, tw, ChunkName("app.sql", 2), "cccc")
+	require.NoError(t, tw.Close())
+
+	r := NewReader(tar.NewReader(bytes.NewReader(buf.Bytes())))
+	_, data, err := r.Next()
+	require.NoError(t, err)
+
+	_, err = io.ReadAll(data)
+	assert.ErrorContains(t, err, "expected chunk 1, got 2")
+}
+
+func TestList_MeasuresChunkedEntries(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+
+	writeRegular(t, tw, "plain.sql", "SELECT 1;\n")
+
+	dump := strings.Repeat("INSERT INTO t VALUES (1);\n", 100)
+	cw := NewChunkWriter(tw, "streamed.sql", 64)
+	_, err := cw.Write([]byte(dump))
+	require.NoError(t, err)
+	require.NoError(t, cw.Close())
+	require.NoError(t, tw.Close())
+
+	sizes := map[string]int64{}
+	err = List(NewReader(tar.NewReader(&buf)), func(entry *Entry, size int64) error {
+		sizes[entry.Name] = size
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]int64{
+		"plain.sql":    10,
+		"streamed.sql": int64(len(dump)),
+	}, sizes)
+}
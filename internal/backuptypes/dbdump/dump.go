@@ -0,0 +1,169 @@
+package dbdump
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/shyim/docker-backup/internal/backup"
+)
+
+// OptionBackupParallel is the number of databases dumped at the same time,
+// e.g. docker-backup.db.backup-parallel=4
+const OptionBackupParallel = "backup-parallel"
+
+// BackupParallel returns the number of dumps to run at the same time. Streamed
+// dumps are written straight into the archive, so they can't run in parallel.
+func BackupParallel(opts backup.Options, stream bool) (int, error) {
+	parallel, err := opts.Int(OptionBackupParallel, 1)
+	if err != nil {
+		return 0, err
+	}
+	if parallel < 1 {
+		return 0, fmt.Errorf("invalid %s %d: must be at least 1", OptionBackupParallel, parallel)
+	}
+	if parallel > 1 && stream {
+		return 0, fmt.Errorf("invalid %s %d: streamed dumps are written one at a time", OptionBackupParallel, parallel)
+	}
+	return parallel, nil
+}
+
+// DumpJob produces one entry of an archive
+type DumpJob struct {
+	// Entry is the name of the archive entry, e.g. "app.sql"
+	Entry string
+	// Dump writes the dump to w
+	Dump func(ctx context.Context, w io.Writer) error
+}
+
+// StreamDumps runs the jobs one after another, writing each dump straight into
+// tw as chunk entries
+func StreamDumps(ctx context.Context, tw *tar.Writer, jobs []DumpJob) error {
+	for _, job := range jobs {
+		chunkWriter := NewChunkWriter(tw, job.Entry, DefaultChunkSize)
+		if err := job.Dump(ctx, chunkWriter); err != nil {
+			return err
+		}
+		if err := chunkWriter.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteDumps runs up to parallel jobs at a time, each dumping into a temp file
+// in the temp dir carried by ctx, see backup.WithTempDir, so its size is known
+// for the tar header. The entries are written to tw in the order of jobs
+// whatever order the dumps finish in, so the archive doesn't depend on
+// parallel; at most parallel temp files exist at a time. The first error
+// cancels the dumps still running and is returned.
+func WriteDumps(ctx context.Context, tw *tar.Writer, jobs []DumpJob, parallel int) error {
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type dumped struct {
+		file    *os.File
+		err     error
+		started bool // The dump took a slot
+	}
+	results := make([]chan dumped, len(jobs))
+	for i := range results {
+		results[i] = make(chan dumped, 1)
+	}
+
+	// A slot is taken before a dump starts and given back once its entry is
+	// written, which happens in job order, so job i always gets a slot
+	slots := make(chan struct{}, parallel)
+	go func() {
+		for i, job := range jobs {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				results[i] <- dumped{err: ctx.Err()}
+				continue
+			}
+			go func() {
+				file, err := dumpToFile(ctx, job)
+				results[i] <- dumped{file: file, err: err, started: true}
+			}()
+		}
+	}()
+
+	var firstErr error
+	for i, job := range jobs {
+		result := <-results[i]
+		if result.file != nil {
+			if firstErr == nil {
+				result.err = writeEntry(tw, job.Entry, result.file)
+			}
+			_ = result.file.Close()
+			_ = os.Remove(result.file.Name())
+		}
+		if firstErr == nil && result.err != nil {
+			firstErr = result.err
+			cancel()
+		}
+		// Keep draining after a failure so every temp file is removed
+		if result.started {
+			<-slots
+		}
+	}
+
+	if firstErr == nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return firstErr
+}
+
+// dumpToFile runs job into a temp file positioned at its start
+func dumpToFile(ctx context.Context, job DumpJob) (*os.File, error) {
+	tmpFile, err := os.CreateTemp(backup.TempDirFromContext(ctx), "dbdump-*"+path.Ext(job.Entry))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+
+	if err := job.Dump(ctx, tmpFile); err != nil {
+		_ = tmpFile.Close()
+		_ = os.Remove(tmpFile.Name())
+		return nil, err
+	}
+
+	if _, err := tmpFile.Seek(0, io.SeekStart); err != nil {
+		_ = tmpFile.Close()
+		_ = os.Remove(tmpFile.Name())
+		return nil, fmt.Errorf("failed to seek temp file: %w", err)
+	}
+
+	return tmpFile, nil
+}
+
+// writeEntry copies a dumped temp file into tw as a regular entry
+func writeEntry(tw *tar.Writer, name string, file *os.File) error {
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat temp file: %w", err)
+	}
+
+	header := &tar.Header{
+		Name: name,
+		Mode: 0644,
+		Size: fileInfo.Size(),
+	}
+
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write tar header: %w", err)
+	}
+
+	if _, err := io.Copy(tw, file); err != nil {
+		return fmt.Errorf("failed to write to tar: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package dbdump
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testJobs returns jobs whose dumps finish in reverse order when run in parallel
+func testJobs(count int, running, maxRunning *atomic.Int32) []DumpJob {
+	var jobs []DumpJob
+	for i := range count {
+		jobs = append(jobs, DumpJob{
+			Entry: fmt.Sprintf("db%d.sql", i),
+			Dump: func(ctx context.Context, w io.Writer) error {
+				if running != nil {
+					n := running.Add(1)
+					defer running.Add(-1)
+					for {
+						current := maxRunning.Load()
+						if n <= current || maxRunning.CompareAndSwap(current, n) {
+							break
+						}
+					}
+				}
+				time.Sleep(time.Duration(count-i) * 5 * time.Millisecond)
+				_, err := io.WriteString(w, strings.Repeat(fmt.Sprintf("INSERT INTO t%d VALUES (1);\n", i), i+1))
+				return err
+			},
+		})
+	}
+	return jobs
+}
+
+func writeDumps(t *testing.T, jobs []DumpJob, parallel int) ([]byte, error) {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	err := WriteDumps(context.Background(), tw, jobs, parallel)
+	require.NoError(t, tw.Close())
+	return buf.Bytes(), err
+}
+
+func TestWriteDumps_SameOutputForAnyParallelism(t *testing.T) {
+	sequential, err := writeDumps(t, testJobs(6, nil, nil), 1)
+	require.NoError(t, err)
+
+	entries := readAll(t, sequential)
+	require.Len(t, entries, 6)
+	for i, entry := range entries {
+		assert.Equal(t, fmt.Sprintf("db%d.sql", i), entry.name)
+		assert.Equal(t, int64(len(entry.data)), entry.size)
+	}
+
+	for _, parallel := range []int{2, 4, 8} {
+		t.Run(fmt.Sprintf("parallel=%d", parallel), func(t *testing.T) {
+			var running, maxRunning atomic.Int32
+			archive, err := writeDumps(t, testJobs(6, &running, &maxRunning), parallel)
+			require.NoError(t, err)
+			assert.Equal(t, sequential, archive)
+			assert.LessOrEqual(t, maxRunning.Load(), int32(parallel))
+			assert.Greater(t, maxRunning.Load(), int32(1))
+		})
+	}
+}
+
+func TestWriteDumps_FirstErrorCancelsTheRest(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+
+	jobs := testJobs(4, nil, nil)
+	jobs[1].Dump = func(ctx context.Context, w io.Writer) error {
+		return errors.New("failed to backup database db1: mysqldump failed")
+	}
+	jobs[3].Dump = func(ctx context.Context, w io.Writer) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	archive, err := writeDumps(t, jobs, 4)
+	assert.EqualError(t, err, "failed to backup database db1: mysqldump failed")
+
+	// Entries before the failing one are written, none after it
+	entries := readAll(t, archive)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "db0.sql", entries[0].name)
+
+	tmpFiles, err := os.ReadDir(os.TempDir())
+	require.NoError(t, err)
+	assert.Empty(t, tmpFiles, "temp files are removed")
+}
+
+func TestWriteDumps_SpoolsToTempDir(t *testing.T) {
+	dir := t.TempDir()
+	var spooled string
+	jobs := []DumpJob{{
+		Entry: "db.sql",
+		Dump: func(ctx context.Context, w io.Writer) error {
+			spooled = w.(*os.File).Name()
+			_, err := io.WriteString(w, "SELECT 1;\n")
+			return err
+		},
+	}}
+
+	tw := tar.NewWriter(io.Discard)
+	require.NoError(t, WriteDumps(backup.WithTempDir(context.Background(), dir), tw, jobs, 1))
+	require.NoError(t, tw.Close())
+
+	assert.Equal(t, dir, filepath.Dir(spooled))
+	assert.NoFileExists(t, spooled)
+}
+
+func TestStreamDumps(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	require.NoError(t, StreamDumps(context.Background(), tw, testJobs(2, nil, nil)))
+	require.NoError(t, tw.Close())
+
+	entries := readAll(t, buf.Bytes())
+	require.Len(t, entries, 2)
+	assert.Equal(t, readEntry{name: "db0.sql", size: -1, chunked: true, data: "INSERT INTO t0 VALUES (1);\n"}, entries[0])
+	assert.Equal(t, "db1.sql", entries[1].name)
+}
+
+func TestBackupParallel(t *testing.T) {
+	parallel, err := BackupParallel(backup.Options{}, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, parallel)
+
+	parallel, err = BackupParallel(backup.Options{OptionBackupParallel: "4"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, 4, parallel)
+
+	_, err = BackupParallel(backup.Options{OptionBackupParallel: "0"}, false)
+	assert.ErrorContains(t, err, "must be at least 1")
+
+	_, err = BackupParallel(backup.Options{OptionBackupParallel: "4"}, true)
+	assert.ErrorContains(t, err, "streamed dumps")
+
+	parallel, err = BackupParallel(backup.Options{OptionBackupParallel: "1"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, 1, parallel)
+}
@@ -0,0 +1,58 @@
+package dbdump
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Extract writes every entry of an archive to a file of the same name in dest,
+// joining chunked entries back into one dump. Dumps are created with mode 0600
+// since they hold the database contents. Entry names that are not plain file
+// names are rejected.
+func Extract(ctx context.Context, dumps *Reader, dest string) error {
+	root, err := os.OpenRoot(dest)
+	if err != nil {
+		return fmt.Errorf("failed to open destination: %w", err)
+	}
+	defer func() {
+		_ = root.Close()
+	}()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		entry, data, err := dumps.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar header: %w", err)
+		}
+
+		if entry.Name == "" || entry.Name == "." || entry.Name == ".." || strings.ContainsAny(entry.Name, `/\`) {
+			return fmt.Errorf("archive entry %q is not a plain file name", entry.Name)
+		}
+
+		if err := extractEntry(root, entry.Name, data); err != nil {
+			return fmt.Errorf("failed to extract %s: %w", entry.Name, err)
+		}
+	}
+}
+
+func extractEntry(root *os.Root, name string, data io.Reader) error {
+	f, err := root.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
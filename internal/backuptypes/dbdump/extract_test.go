@@ -0,0 +1,44 @@
+package dbdump
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtract_WritesDumps(t *testing.T) {
+	archive := buildArchive(t, "a.sql", "chunked.sql", GlobalsEntry)
+	dest := t.TempDir()
+
+	require.NoError(t, Extract(context.Background(), archiveReader(archive), dest))
+
+	for _, name := range []string{"a.sql", "chunked.sql", GlobalsEntry} {
+		data, err := os.ReadFile(filepath.Join(dest, name))
+		require.NoError(t, err)
+		assert.Equal(t, "dump of "+name, string(data))
+
+		info, err := os.Stat(filepath.Join(dest, name))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+}
+
+func TestExtract_RejectsPathNames(t *testing.T) {
+	for _, name := range []string{"../escape.sql", "sub/app.sql", ".."} {
+		t.Run(name, func(t *testing.T) {
+			parent := t.TempDir()
+			dest := filepath.Join(parent, "dest")
+			require.NoError(t, os.Mkdir(dest, 0755))
+
+			err := Extract(context.Background(), archiveReader(buildArchive(t, name)), dest)
+			require.Error(t, err)
+
+			_, statErr := os.Stat(filepath.Join(parent, "escape.sql"))
+			assert.True(t, os.IsNotExist(statErr))
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package dbdump
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/shyim/docker-backup/internal/backup"
+)
+
+// GlobalsEntry holds cluster-wide objects such as roles that the databases
+// depend on. On a parallel restore it is restored on its own, after everything
+// before it and before anything after it.
+const GlobalsEntry = "globals.sql"
+
+// RestoreFunc restores one logical entry of an archive from r
+type RestoreFunc func(ctx context.Context, entry *Entry, r io.Reader) error
+
+// Restore restores every entry of an archive with restore. With parallel > 1,
+// up to parallel entries are restored concurrently. The archive is still read
+// sequentially, so each entry is first copied to a temp file in the temp dir
+// carried by ctx; at most parallel temp files exist at a time. The first error cancels the restores still
+// running and is returned.
+func Restore(ctx context.Context, dumps *Reader, parallel int, restore RestoreFunc) error {
+	if parallel <= 1 {
+		for {
+			entry, data, err := dumps.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read tar header: %w", err)
+			}
+
+			if err := restore(ctx, entry, data); err != nil {
+				return err
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+		slots    = make(chan struct{}, parallel)
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	for ctx.Err() == nil {
+		entry, data, err := dumps.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fail(fmt.Errorf("failed to read tar header: %w", err))
+			break
+		}
+
+		if entry.Name == GlobalsEntry {
+			wg.Wait()
+			if ctx.Err() != nil {
+				break
+			}
+			if err := restore(ctx, entry, data); err != nil {
+				fail(err)
+			}
+			continue
+		}
+
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		spooled, err := spool(backup.TempDirFromContext(ctx), data)
+		if err != nil {
+			<-slots
+			fail(fmt.Errorf("failed to buffer %s: %w", entry.Name, err))
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				_ = spooled.Close()
+				_ = os.Remove(spooled.Name())
+				<-slots
+			}()
+
+			if err := restore(ctx, entry, spooled); err != nil {
+				fail(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if firstErr == nil && ctx.Err() != nil {
+		// The parent context was cancelled
+		return ctx.Err()
+	}
+	return firstErr
+}
+
+// spool copies r into a temp file in dir positioned at its start
+func spool(dir string, r io.Reader) (*os.File, error) {
+	tmpFile, err := os.CreateTemp(dir, "dbdump-restore-*.sql")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+
+	if _, err := io.Copy(tmpFile, r); err != nil {
+		_ = tmpFile.Close()
+		_ = os.Remove(tmpFile.Name())
+		return nil, fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	if _, err := tmpFile.Seek(0, io.SeekStart); err != nil {
+		_ = tmpFile.Close()
+		_ = os.Remove(tmpFile.Name())
+		return nil, fmt.Errorf("failed to seek temp file: %w", err)
+	}
+
+	return tmpFile, nil
+}
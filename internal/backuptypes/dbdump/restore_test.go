@@ -0,0 +1,177 @@
+package dbdump
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func buildArchive(t *testing.T, names ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range names {
+		if name == "chunked.sql" {
+			cw := NewChunkWriter(tw, name, 4)
+			_, err := io.WriteString(cw, "dump of "+name)
+			require.NoError(t, err)
+			require.NoError(t, cw.Close())
+			continue
+		}
+		writeRegular(t, tw, name, "dump of "+name)
+	}
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+func archiveReader(archive []byte) *Reader {
+	return NewReader(tar.NewReader(bytes.NewReader(archive)))
+}
+
+func TestRestore_Sequential(t *testing.T) {
+	archive := buildArchive(t, "a.sql", "chunked.sql", "b.sql")
+
+	var got []string
+	err := Restore(context.Background(), archiveReader(archive), 1, func(_ context.Context, entry *Entry, r io.Reader) error {
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, "dump of "+entry.Name, string(data))
+		got = append(got, entry.Name)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.sql", "chunked.sql", "b.sql"}, got)
+}
+
+func TestRestore_ParallelBounded(t *testing.T) {
+	archive := buildArchive(t, "a.sql", "b.sql", "chunked.sql", "c.sql", "d.sql", "e.sql")
+
+	var (
+		running, peak atomic.Int32
+		mu            sync.Mutex
+		got           = map[string]string{}
+	)
+	err := Restore(context.Background(), archiveReader(archive), 2, func(_ context.Context, entry *Entry, r io.Reader) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		got[entry.Name] = string(data)
+		mu.Unlock()
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Len(t, got, 6)
+	for name, data := range got {
+		assert.Equal(t, "dump of "+name, data)
+	}
+	assert.Equal(t, int32(2), peak.Load())
+}
+
+func TestRestore_ParallelSpoolsToTempDir(t *testing.T) {
+	dir := t.TempDir()
+	archive := buildArchive(t, "a.sql", "b.sql")
+
+	var mu sync.Mutex
+	var spooled []string
+	ctx := backup.WithTempDir(context.Background(), dir)
+	err := Restore(ctx, archiveReader(archive), 2, func(_ context.Context, _ *Entry, r io.Reader) error {
+		mu.Lock()
+		defer mu.Unlock()
+		spooled = append(spooled, r.(*os.File).Name())
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Len(t, spooled, 2)
+	for _, name := range spooled {
+		assert.Equal(t, dir, filepath.Dir(name))
+		assert.NoFileExists(t, name)
+	}
+}
+
+func TestRestore_GlobalsRestoredAlone(t *testing.T) {
+	archive := buildArchive(t, GlobalsEntry, "a.sql", "b.sql", "c.sql")
+
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	record := func(event string) {
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	}
+
+	err := Restore(context.Background(), archiveReader(archive), 4, func(_ context.Context, entry *Entry, r io.Reader) error {
+		record("start " + entry.Name)
+		if entry.Name == GlobalsEntry {
+			time.Sleep(20 * time.Millisecond)
+		}
+		_, err := io.Copy(io.Discard, r)
+		record("end " + entry.Name)
+		return err
+	})
+	require.NoError(t, err)
+
+	require.Len(t, events, 8)
+	assert.Equal(t, []string{"start " + GlobalsEntry, "end " + GlobalsEntry}, events[:2])
+}
+
+func TestRestore_FirstErrorCancelsRest(t *testing.T) {
+	archive := buildArchive(t, "a.sql", "b.sql", "c.sql", "d.sql", "e.sql", "f.sql")
+	errBroken := errors.New("broken dump")
+
+	var started atomic.Int32
+	err := Restore(context.Background(), archiveReader(archive), 2, func(ctx context.Context, entry *Entry, r io.Reader) error {
+		started.Add(1)
+		if entry.Name == "a.sql" {
+			return errBroken
+		}
+
+		// Other restores run until they are cancelled
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			return nil
+		}
+	})
+	assert.ErrorIs(t, err, errBroken)
+	assert.Less(t, started.Load(), int32(6), "restores after the failure should not start")
+}
+
+func TestRestore_ParentCancelled(t *testing.T) {
+	archive := buildArchive(t, "a.sql", "b.sql")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := Restore(ctx, archiveReader(archive), 2, func(ctx context.Context, entry *Entry, r io.Reader) error {
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
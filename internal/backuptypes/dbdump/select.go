@@ -0,0 +1,48 @@
+package dbdump
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/shyim/docker-backup/internal/backup"
+)
+
+// Options selecting the databases a dump covers, shared by the database backup types
+const (
+	// OptionDatabases limits the backup to these databases, e.g. docker-backup.db.databases=app,sessions
+	OptionDatabases = "databases"
+	// OptionExcludeDatabases leaves these databases out, e.g. docker-backup.db.exclude-databases=logs
+	OptionExcludeDatabases = "exclude-databases"
+)
+
+// SelectDatabases narrows the databases found on the server down to the ones
+// selected with the databases and exclude-databases options, keeping their
+// order. Named databases that don't exist are logged; it fails when databases
+// are named but none of them exist, rather than backing up nothing.
+func SelectDatabases(containerName string, databases []string, opts backup.Options) ([]string, error) {
+	include := opts.List(OptionDatabases)
+	exclude := opts.List(OptionExcludeDatabases)
+
+	for _, name := range slices.Concat(include, exclude) {
+		if !slices.Contains(databases, name) {
+			slog.Warn("selected database does not exist", "container", containerName, "database", name)
+		}
+	}
+
+	var selected []string
+	for _, name := range databases {
+		if len(include) > 0 && !slices.Contains(include, name) {
+			continue
+		}
+		if slices.Contains(exclude, name) {
+			continue
+		}
+		selected = append(selected, name)
+	}
+
+	if len(include) > 0 && len(selected) == 0 {
+		return nil, fmt.Errorf("none of the databases %v exist or all are excluded", include)
+	}
+	return selected, nil
+}
@@ -0,0 +1,32 @@
+package dbdump
+
+import (
+	"testing"
+
+	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectDatabases(t *testing.T) {
+	databases := []string{"app", "sessions", "logs", "tenant_b"}
+
+	selected, err := SelectDatabases("db", databases, backup.Options{})
+	require.NoError(t, err)
+	assert.Equal(t, databases, selected, "everything without a selection")
+
+	selected, err = SelectDatabases("db", databases, backup.Options{OptionDatabases: "sessions, app,missing"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"app", "sessions"}, selected, "server order, missing names skipped")
+
+	selected, err = SelectDatabases("db", databases, backup.Options{OptionExcludeDatabases: "logs"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"app", "sessions", "tenant_b"}, selected)
+
+	selected, err = SelectDatabases("db", databases, backup.Options{OptionDatabases: "app,logs", OptionExcludeDatabases: "logs"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"app"}, selected)
+
+	_, err = SelectDatabases("db", databases, backup.Options{OptionDatabases: "missing"})
+	assert.ErrorContains(t, err, "none of the databases")
+}
@@ -0,0 +1,492 @@
+package filesystem
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/shyim/docker-backup/internal/compression"
+	"github.com/shyim/docker-backup/internal/docker"
+	"github.com/shyim/docker-backup/internal/pathfilter"
+	"github.com/shyim/docker-backup/internal/progress"
+)
+
+func init() {
+	backup.Register(&FilesystemBackup{})
+}
+
+// Options understood by the filesystem backup type
+const (
+	// OptionInclude lists glob patterns of the paths to back up, all paths if empty
+	OptionInclude = "include"
+	// OptionExclude lists glob patterns of the paths to leave out
+	OptionExclude = "exclude"
+	// OptionIncludeVolumes also backs up named volumes, not only bind mounts
+	OptionIncludeVolumes = "include-volumes"
+)
+
+// FilesystemBackup archives the bind mounts of a container, and optionally its
+// named volumes. Entries are stored below the mount destination inside the
+// container ("etc/app-config/app.yml"), so a restore maps every path back to
+// the mount it came from.
+type FilesystemBackup struct{}
+
+func (f *FilesystemBackup) Name() string {
+	return "filesystem"
+}
+
+func (f *FilesystemBackup) FileExtension(opts backup.Options) string {
+	return opts.Extension(".tar")
+}
+
+func (f *FilesystemBackup) Validate(container *docker.ContainerInfo, opts backup.Options) error {
+	if _, err := selectMounts(container, opts); err != nil {
+		return err
+	}
+	_, err := newFilter(opts)
+	return err
+}
+
+// selectMounts returns the bind mounts of the container, plus its named
+// volumes if include-volumes is set
+func selectMounts(container *docker.ContainerInfo, opts backup.Options) ([]docker.MountInfo, error) {
+	includeVolumes, err := opts.Bool(OptionIncludeVolumes, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var mounts []docker.MountInfo
+	for _, mount := range container.Mounts {
+		if mount.Type == "bind" || (includeVolumes && mount.Type == "volume") {
+			mounts = append(mounts, mount)
+		}
+	}
+
+	if len(mounts) == 0 {
+		if includeVolumes {
+			return nil, fmt.Errorf("container %s has no bind mounts or volumes", container.Name)
+		}
+		return nil, fmt.Errorf("container %s has no bind mounts (set %s=true to include named volumes)", container.Name, OptionIncludeVolumes)
+	}
+	return mounts, nil
+}
+
+func newFilter(opts backup.Options) (*pathfilter.Filter, error) {
+	filter, err := pathfilter.New(opts.List(OptionInclude), opts.List(OptionExclude))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s or %s: %w", OptionInclude, OptionExclude, err)
+	}
+	return filter, nil
+}
+
+// archiveRoot returns the archive path a mount is stored under
+func archiveRoot(mount docker.MountInfo) string {
+	return strings.TrimPrefix(path.Clean(mount.Destination), "/")
+}
+
+func (f *FilesystemBackup) Backup(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options, w io.Writer) error {
+	mounts, err := selectMounts(container, opts)
+	if err != nil {
+		return err
+	}
+	filter, err := newFilter(opts)
+	if err != nil {
+		return err
+	}
+
+	codec, err := opts.Compression()
+	if err != nil {
+		return err
+	}
+	compressor, err := codec.NewWriter(w)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = compressor.Close()
+	}()
+
+	tarWriter := tar.NewWriter(compressor)
+	defer func() {
+		_ = tarWriter.Close()
+	}()
+
+	for _, mount := range mounts {
+		slog.Debug("backing up mount",
+			"container", container.Name,
+			"type", mount.Type,
+			"path", mount.Destination,
+		)
+
+		if err := f.addMount(ctx, dockerClient, tarWriter, container, mount, filter); err != nil {
+			return fmt.Errorf("failed to backup %s: %w", mount.Destination, err)
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+	return compressor.Close()
+}
+
+func (f *FilesystemBackup) addMount(ctx context.Context, dockerClient *docker.Client, tarWriter *tar.Writer, container *docker.ContainerInfo, mount docker.MountInfo, filter *pathfilter.Filter) error {
+	reader, err := dockerClient.CopyFromContainer(ctx, container.ID, mount.Destination)
+	if err != nil {
+		return fmt.Errorf("failed to copy from container: %w", err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	// Docker prefixes archive entries with the basename of the copied path
+	srcPrefix := path.Base(path.Clean(mount.Destination))
+	root := archiveRoot(mount)
+	nested := nestedMounts(container, mount)
+	tracker := progress.FromContext(ctx)
+
+	// Regular files written so far, hardlinks to anything else are dropped
+	written := make(map[string]bool)
+
+	tarReader := tar.NewReader(reader)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		relPath := strings.TrimPrefix(strings.TrimPrefix(header.Name, srcPrefix), "/")
+		relPath = strings.TrimSuffix(relPath, "/")
+
+		// Mounts below this one are their own entry, or deliberately left out
+		if underAny(relPath, nested) {
+			continue
+		}
+		if !filter.Keep(relPath, header.Typeflag == tar.TypeDir) {
+			continue
+		}
+
+		name := root
+		if relPath != "" {
+			name += "/" + relPath
+		}
+
+		if header.Typeflag == tar.TypeLink {
+			relTarget, ok := strings.CutPrefix(header.Linkname, srcPrefix+"/")
+			if !ok || !written[relTarget] {
+				slog.Warn("skipping hardlink to a file that is not backed up",
+					"container", container.Name,
+					"link", name,
+					"target", header.Linkname,
+				)
+				continue
+			}
+			header.Linkname = root + "/" + relTarget
+		}
+
+		header.Name = name
+		if header.Typeflag == tar.TypeDir {
+			header.Name += "/"
+		}
+
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to write tar header: %w", err)
+		}
+		tracker.AddEntry()
+
+		if header.Typeflag == tar.TypeReg {
+			if _, err := io.Copy(tarWriter, tarReader); err != nil {
+				return fmt.Errorf("failed to write file to tar: %w", err)
+			}
+			written[relPath] = true
+		}
+	}
+}
+
+// nestedMounts returns the paths of the container's other mounts below mount,
+// relative to it
+func nestedMounts(container *docker.ContainerInfo, mount docker.MountInfo) []string {
+	dest := path.Clean(mount.Destination)
+
+	var nested []string
+	for _, other := range container.Mounts {
+		otherDest := path.Clean(other.Destination)
+		if rel, ok := strings.CutPrefix(otherDest, strings.TrimSuffix(dest, "/")+"/"); ok && otherDest != dest {
+			nested = append(nested, rel)
+		}
+	}
+	return nested
+}
+
+// underAny reports whether relPath is one of prefixes or below one of them
+func underAny(relPath string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if relPath == prefix || strings.HasPrefix(relPath, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Restore writes the archived paths back into the mounts they came from while
+// the containers using them are stopped. Paths whose mount is gone or not
+// selected are skipped. Files not in the backup are kept.
+func (f *FilesystemBackup) Restore(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options, r io.Reader) error {
+	mounts, err := selectMounts(container, opts)
+	if err != nil {
+		return err
+	}
+
+	roots := make(map[string]docker.MountInfo, len(mounts))
+	for _, mount := range mounts {
+		roots[archiveRoot(mount)] = mount
+	}
+
+	restart, err := stopMountUsers(ctx, container, dockerClient, mounts)
+	if err != nil {
+		return err
+	}
+	defer restart(ctx)
+
+	decompressor, err := compression.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = decompressor.Close()
+	}()
+
+	tarReader := tar.NewReader(decompressor)
+	tracker := progress.FromContext(ctx)
+	skipped := make(map[string]bool)
+
+	var current *restoreStream
+	finishCurrent := func() error {
+		if current == nil {
+			return nil
+		}
+		err := current.close()
+		current = nil
+		return err
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			_ = finishCurrent()
+			return err
+		}
+
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			_ = finishCurrent()
+			return fmt.Errorf("failed to read tar header: %w", err)
+		}
+
+		name := strings.TrimSuffix(header.Name, "/")
+		root, relPath, ok := findRoot(roots, name)
+		if !ok {
+			top, _, _ := strings.Cut(name, "/")
+			if !skipped[top] {
+				slog.Warn("backup contains a path that is not mounted or not selected, skipping",
+					"path", "/"+name,
+					"container", container.Name,
+				)
+				skipped[top] = true
+			}
+			continue
+		}
+		mount := roots[root]
+
+		if hasDotDot(relPath) {
+			_ = finishCurrent()
+			return fmt.Errorf("refusing to restore archive entry %q: path escapes the mount", header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeSymlink:
+			if !symlinkInside(mount.Destination, relPath, header.Linkname) {
+				slog.Warn("skipping symlink pointing outside the mount",
+					"container", container.Name,
+					"link", "/"+name,
+					"target", header.Linkname,
+				)
+				continue
+			}
+		case tar.TypeLink:
+			relTarget, ok := strings.CutPrefix(header.Linkname, root+"/")
+			if !ok || hasDotDot(relTarget) {
+				slog.Warn("skipping hardlink pointing outside the mount",
+					"container", container.Name,
+					"link", "/"+name,
+					"target", header.Linkname,
+				)
+				continue
+			}
+			header.Linkname = path.Base(path.Clean(mount.Destination)) + "/" + relTarget
+		}
+
+		if current == nil || current.root != root {
+			if err := finishCurrent(); err != nil {
+				return fmt.Errorf("failed to restore %s: %w", mount.Destination, err)
+			}
+			current = newRestoreStream(ctx, dockerClient, container.ID, root, mount.Destination)
+		}
+
+		// CopyToContainer extracts into the parent of the mount destination
+		header.Name = path.Base(path.Clean(mount.Destination))
+		if relPath != "" {
+			header.Name += "/" + relPath
+		}
+		if header.Typeflag == tar.TypeDir {
+			header.Name += "/"
+		}
+
+		if err := current.writer.WriteHeader(header); err != nil {
+			_ = finishCurrent()
+			return fmt.Errorf("failed to write tar header: %w", err)
+		}
+		tracker.AddEntry()
+
+		if header.Typeflag == tar.TypeReg {
+			if _, err := io.Copy(current.writer, tarReader); err != nil {
+				_ = finishCurrent()
+				return fmt.Errorf("failed to write file: %w", err)
+			}
+		}
+	}
+
+	if err := finishCurrent(); err != nil {
+		return fmt.Errorf("failed to restore: %w", err)
+	}
+	return nil
+}
+
+// stopMountUsers stops the container itself if a bind mount is restored, and
+// every container using one of the restored volumes
+func stopMountUsers(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, mounts []docker.MountInfo) (func(ctx context.Context), error) {
+	var restarts []func(ctx context.Context)
+	restartAll := func(ctx context.Context) {
+		for i := len(restarts) - 1; i >= 0; i-- {
+			restarts[i](ctx)
+		}
+	}
+
+	var volumeNames []string
+	for _, mount := range mounts {
+		if mount.Type == "volume" {
+			volumeNames = append(volumeNames, mount.Name)
+			continue
+		}
+		if len(restarts) == 0 {
+			restart, err := dockerClient.StopPathUsers(ctx, container, mount.Destination, 30*time.Second)
+			if err != nil {
+				return nil, err
+			}
+			restarts = append(restarts, restart)
+		}
+	}
+
+	if len(volumeNames) > 0 {
+		restart, err := dockerClient.StopVolumeUsers(ctx, volumeNames, 30*time.Second)
+		if err != nil {
+			restartAll(ctx)
+			return nil, err
+		}
+		restarts = append(restarts, restart)
+	}
+
+	return restartAll, nil
+}
+
+// findRoot returns the deepest mount root containing the archive path name
+func findRoot(roots map[string]docker.MountInfo, name string) (root, relPath string, ok bool) {
+	for candidate := range roots {
+		if len(candidate) <= len(root) && ok {
+			continue
+		}
+		if name == candidate {
+			root, relPath, ok = candidate, "", true
+		} else if rel, found := strings.CutPrefix(name, candidate+"/"); found {
+			root, relPath, ok = candidate, rel, true
+		}
+	}
+	return root, relPath, ok
+}
+
+func hasDotDot(relPath string) bool {
+	for _, segment := range strings.Split(relPath, "/") {
+		if segment == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// symlinkInside reports whether a symlink at relPath below the mount at dest
+// points inside the mount. Only the target itself is checked, not symlinks
+// it may lead through.
+func symlinkInside(dest, relPath, target string) bool {
+	dest = path.Clean(dest)
+	if path.IsAbs(target) {
+		target = path.Clean(target)
+		return target == dest || strings.HasPrefix(target, strings.TrimSuffix(dest, "/")+"/")
+	}
+	resolved := path.Join(path.Dir(relPath), target)
+	return resolved != ".." && !strings.HasPrefix(resolved, "../")
+}
+
+// restoreStream pipes a tar archive of a single mount into the container via
+// CopyToContainer
+type restoreStream struct {
+	root   string
+	pw     *io.PipeWriter
+	writer *tar.Writer
+	done   chan error
+}
+
+func newRestoreStream(ctx context.Context, dockerClient *docker.Client, containerID, root, dest string) *restoreStream {
+	pr, pw := io.Pipe()
+	s := &restoreStream{
+		root:   root,
+		pw:     pw,
+		writer: tar.NewWriter(pw),
+		done:   make(chan error, 1),
+	}
+
+	target := path.Dir(path.Clean(dest))
+	go func() {
+		err := dockerClient.CopyToContainer(ctx, containerID, target, pr)
+		_ = pr.CloseWithError(err)
+		s.done <- err
+	}()
+
+	return s
+}
+
+func (s *restoreStream) close() error {
+	if err := s.writer.Close(); err != nil {
+		_ = s.pw.CloseWithError(err)
+		<-s.done
+		return err
+	}
+	if err := s.pw.Close(); err != nil {
+		<-s.done
+		return err
+	}
+	return <-s.done
+}
@@ -0,0 +1,223 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/shyim/docker-backup/internal/docker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+func TestFilesystemBackup_Name(t *testing.T) {
+	f := &FilesystemBackup{}
+	assert.Equal(t, "filesystem", f.Name())
+}
+
+func TestFilesystemBackup_FileExtension(t *testing.T) {
+	f := &FilesystemBackup{}
+	assert.Equal(t, ".tar.zst", f.FileExtension(nil))
+}
+
+func TestFilesystemBackup_Validate(t *testing.T) {
+	f := &FilesystemBackup{}
+
+	bind := docker.MountInfo{Type: "bind", Source: "/srv/config", Destination: "/etc/app-config"}
+	vol := docker.MountInfo{Type: "volume", Name: "data", Destination: "/data"}
+
+	tests := []struct {
+		name        string
+		mounts      []docker.MountInfo
+		opts        backup.Options
+		expectError bool
+	}{
+		{name: "bind mount", mounts: []docker.MountInfo{bind}},
+		{name: "only volumes", mounts: []docker.MountInfo{vol}, expectError: true},
+		{name: "only volumes with include-volumes", mounts: []docker.MountInfo{vol}, opts: backup.Options{OptionIncludeVolumes: "true"}},
+		{name: "no mounts", expectError: true},
+		{name: "patterns", mounts: []docker.MountInfo{bind}, opts: backup.Options{OptionInclude: "*.yml", OptionExclude: "secrets/, *.bak"}},
+		{name: "invalid pattern", mounts: []docker.MountInfo{bind}, opts: backup.Options{OptionExclude: "[a-"}, expectError: true},
+		{name: "invalid include-volumes", mounts: []docker.MountInfo{bind}, opts: backup.Options{OptionIncludeVolumes: "maybe"}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := f.Validate(&docker.ContainerInfo{Name: "test", Mounts: tt.mounts}, tt.opts)
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSelectMounts(t *testing.T) {
+	ctr := &docker.ContainerInfo{
+		Name: "test",
+		Mounts: []docker.MountInfo{
+			{Type: "volume", Name: "data", Destination: "/data"},
+			{Type: "bind", Source: "/srv/config", Destination: "/etc/app-config"},
+			{Type: "tmpfs", Destination: "/tmp"},
+		},
+	}
+
+	mounts, err := selectMounts(ctr, nil)
+	require.NoError(t, err)
+	require.Len(t, mounts, 1)
+	assert.Equal(t, "/etc/app-config", mounts[0].Destination)
+
+	mounts, err = selectMounts(ctr, backup.Options{OptionIncludeVolumes: "true"})
+	require.NoError(t, err)
+	require.Len(t, mounts, 2)
+	assert.Equal(t, "/data", mounts[0].Destination)
+}
+
+func TestNestedMounts(t *testing.T) {
+	outer := docker.MountInfo{Type: "bind", Destination: "/app"}
+	ctr := &docker.ContainerInfo{
+		Mounts: []docker.MountInfo{
+			outer,
+			{Type: "volume", Name: "uploads", Destination: "/app/public/uploads"},
+			{Type: "bind", Destination: "/application"},
+		},
+	}
+
+	nested := nestedMounts(ctr, outer)
+	assert.Equal(t, []string{"public/uploads"}, nested)
+
+	assert.True(t, underAny("public/uploads", nested))
+	assert.True(t, underAny("public/uploads/a.png", nested))
+	assert.False(t, underAny("public/index.php", nested))
+}
+
+func TestFindRoot(t *testing.T) {
+	roots := map[string]docker.MountInfo{
+		"app":                {Destination: "/app"},
+		"app/public/uploads": {Destination: "/app/public/uploads"},
+		"etc/app-config":     {Destination: "/etc/app-config"},
+	}
+
+	root, rel, ok := findRoot(roots, "app/public/uploads/a.png")
+	require.True(t, ok)
+	assert.Equal(t, "app/public/uploads", root)
+	assert.Equal(t, "a.png", rel)
+
+	root, rel, ok = findRoot(roots, "app/index.php")
+	require.True(t, ok)
+	assert.Equal(t, "app", root)
+	assert.Equal(t, "index.php", rel)
+
+	root, rel, ok = findRoot(roots, "etc/app-config")
+	require.True(t, ok)
+	assert.Equal(t, "etc/app-config", root)
+	assert.Equal(t, "", rel)
+
+	_, _, ok = findRoot(roots, "application/index.php")
+	assert.False(t, ok)
+}
+
+func TestSymlinkInside(t *testing.T) {
+	assert.True(t, symlinkInside("/etc/app-config", "current", "v2/app.yml"))
+	assert.True(t, symlinkInside("/etc/app-config", "a/b", "../c"))
+	assert.True(t, symlinkInside("/etc/app-config", "link", "/etc/app-config/app.yml"))
+	assert.False(t, symlinkInside("/etc/app-config", "a/b", "../../c"))
+	assert.False(t, symlinkInside("/etc/app-config", "passwd", "/etc/passwd"))
+	assert.False(t, symlinkInside("/etc/app-config", "link", "/etc/app-config-old/app.yml"))
+}
+
+// TestFilesystemBackup_Integration backs up and restores a bind mount of a
+// real container via testcontainers
+func TestFilesystemBackup_Integration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+
+	hostDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(hostDir, "app.yml"), []byte("debug: false\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(hostDir, "app.yml.bak"), []byte("old"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(hostDir, "conf.d"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(hostDir, "conf.d", "extra.yml"), []byte("extra: true\n"), 0644))
+
+	req := testcontainers.ContainerRequest{
+		Image: "alpine:latest",
+		Cmd:   []string{"sleep", "3600"},
+		HostConfigModifier: func(hc *container.HostConfig) {
+			hc.Binds = append(hc.Binds, hostDir+":/etc/app-config")
+		},
+		WaitingFor: wait.ForExec([]string{"true"}).WithStartupTimeout(30 * time.Second),
+	}
+
+	ctr, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	require.NoError(t, err)
+	defer func() {
+		if err := ctr.Terminate(ctx); err != nil {
+			t.Logf("failed to terminate container: %v", err)
+		}
+	}()
+
+	containerID := ctr.GetContainerID()
+
+	dockerClient, err := docker.NewClient("")
+	require.NoError(t, err)
+	defer func() {
+		_ = dockerClient.Close()
+	}()
+
+	containerInfo, err := dockerClient.GetContainer(ctx, containerID)
+	require.NoError(t, err)
+
+	opts := backup.Options{OptionExclude: "*.bak"}
+
+	f := &FilesystemBackup{}
+	require.NoError(t, f.Validate(containerInfo, opts))
+
+	var backupBuffer bytes.Buffer
+	err = f.Backup(ctx, containerInfo, dockerClient, opts, &backupBuffer)
+	require.NoError(t, err)
+	assert.Greater(t, backupBuffer.Len(), 0, "backup should not be empty")
+
+	// Simulate data loss
+	_, _, err = ctr.Exec(ctx, []string{"sh", "-c", "rm -rf /etc/app-config/*"})
+	require.NoError(t, err)
+
+	err = f.Restore(ctx, containerInfo, dockerClient, opts, bytes.NewReader(backupBuffer.Bytes()))
+	require.NoError(t, err)
+
+	containerInfo, err = dockerClient.GetContainer(ctx, containerID)
+	require.NoError(t, err)
+	assert.True(t, containerInfo.Running, "container should be running after restore")
+
+	cat := func(p string) (int, string) {
+		exitCode, reader, err := ctr.Exec(ctx, []string{"cat", p})
+		require.NoError(t, err)
+		output, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		return exitCode, string(output)
+	}
+
+	exitCode, output := cat("/etc/app-config/app.yml")
+	require.Equal(t, 0, exitCode)
+	assert.Contains(t, output, "debug: false")
+
+	exitCode, output = cat("/etc/app-config/conf.d/extra.yml")
+	require.Equal(t, 0, exitCode)
+	assert.Contains(t, output, "extra: true")
+
+	exitCode, _ = cat("/etc/app-config/app.yml.bak")
+	assert.NotEqual(t, 0, exitCode, "excluded file should not be restored")
+}
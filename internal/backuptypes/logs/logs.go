@@ -0,0 +1,280 @@
+package logs
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/shyim/docker-backup/internal/compression"
+	"github.com/shyim/docker-backup/internal/docker"
+)
+
+func init() {
+	backup.Register(&LogsBackup{})
+}
+
+// Options understood by the logs backup type
+const (
+	// OptionTail limits the capture to the last N lines of each stream
+	OptionTail = "tail"
+	// OptionSince limits the capture to lines written within the duration before the backup
+	OptionSince = "since"
+	// OptionMaxSize bounds the captured stdout and stderr together, in MiB
+	OptionMaxSize = "max-size"
+	// OptionTimestamps prefixes each line with the time Docker received it
+	OptionTimestamps = "timestamps"
+
+	defaultMaxSizeMiB = 100
+)
+
+// Archive entry names
+const (
+	infoEntry   = "info.json"
+	stdoutEntry = "stdout.log"
+	stderrEntry = "stderr.log"
+)
+
+// errLimitReached stops reading the logs once max-size is captured
+var errLimitReached = errors.New("log size limit reached")
+
+// LogsBackup captures a container's logs. It is capture-only: the archive is
+// for inspection and cannot be restored into a container.
+type LogsBackup struct{}
+
+func (l *LogsBackup) Name() string {
+	return "logs"
+}
+
+func (l *LogsBackup) FileExtension(opts backup.Options) string {
+	return opts.Extension(".tar")
+}
+
+// window is the part of the logs a backup captures
+type window struct {
+	tail       int
+	since      time.Duration
+	maxBytes   int64
+	timestamps bool
+}
+
+func parseWindow(opts backup.Options) (window, error) {
+	tail, err := opts.Int(OptionTail, 0)
+	if err != nil {
+		return window{}, err
+	}
+	if tail < 0 {
+		return window{}, fmt.Errorf("invalid %s %d: must not be negative", OptionTail, tail)
+	}
+
+	since, err := opts.Duration(OptionSince, 0)
+	if err != nil {
+		return window{}, err
+	}
+	if since < 0 {
+		return window{}, fmt.Errorf("invalid %s %s: must not be negative", OptionSince, since)
+	}
+
+	maxSize, err := opts.Int(OptionMaxSize, defaultMaxSizeMiB)
+	if err != nil {
+		return window{}, err
+	}
+	if maxSize <= 0 {
+		return window{}, fmt.Errorf("invalid %s %d: must be positive", OptionMaxSize, maxSize)
+	}
+
+	timestamps, err := opts.Bool(OptionTimestamps, true)
+	if err != nil {
+		return window{}, err
+	}
+
+	return window{
+		tail:       tail,
+		since:      since,
+		maxBytes:   int64(maxSize) << 20,
+		timestamps: timestamps,
+	}, nil
+}
+
+func (l *LogsBackup) Validate(container *docker.ContainerInfo, opts backup.Options) error {
+	_, err := parseWindow(opts)
+	return err
+}
+
+// captureInfo is stored as info.json next to the logs
+type captureInfo struct {
+	Container   string    `json:"container"`
+	ContainerID string    `json:"container_id"`
+	CapturedAt  time.Time `json:"captured_at"`
+	Since       string    `json:"since,omitempty"`
+	Tail        int       `json:"tail,omitempty"`
+	Timestamps  bool      `json:"timestamps"`
+	Truncated   bool      `json:"truncated"` // max-size was reached and later lines are missing
+}
+
+func (l *LogsBackup) Backup(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options, w io.Writer) error {
+	win, err := parseWindow(opts)
+	if err != nil {
+		return err
+	}
+
+	// Tar headers need the entry size, so the streams are spooled to disk first
+	stdout, err := os.CreateTemp(backup.TempDirFromContext(ctx), "docker-backup-logs-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer func() {
+		_ = stdout.Close()
+		_ = os.Remove(stdout.Name())
+	}()
+
+	stderr, err := os.CreateTemp(backup.TempDirFromContext(ctx), "docker-backup-logs-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer func() {
+		_ = stderr.Close()
+		_ = os.Remove(stderr.Name())
+	}()
+
+	info := captureInfo{
+		Container:   container.Name,
+		ContainerID: container.ID,
+		CapturedAt:  time.Now().UTC(),
+		Tail:        win.tail,
+		Timestamps:  win.timestamps,
+	}
+	logOpts := docker.LogOptions{Tail: win.tail, Timestamps: win.timestamps}
+	if win.since > 0 {
+		logOpts.Since = info.CapturedAt.Add(-win.since)
+		info.Since = logOpts.Since.Format(time.RFC3339)
+	}
+
+	budget := &sizeBudget{remaining: win.maxBytes}
+	err = dockerClient.GetLogs(ctx, container.ID, logOpts, budget.writer(stdout), budget.writer(stderr))
+	if err != nil && !errors.Is(err, errLimitReached) {
+		return fmt.Errorf("failed to read container logs: %w", err)
+	}
+	info.Truncated = budget.exhausted
+
+	codec, err := opts.Compression()
+	if err != nil {
+		return err
+	}
+	return writeArchive(w, codec, info, stdout, stderr)
+}
+
+// writeArchive writes info.json and the spooled streams as a tar compressed
+// with codec
+func writeArchive(w io.Writer, codec compression.Codec, info captureInfo, stdout, stderr *os.File) error {
+	compressor, err := codec.NewWriter(w)
+	if err != nil {
+		return err
+	}
+	tarWriter := tar.NewWriter(compressor)
+
+	infoJSON, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode capture info: %w", err)
+	}
+	header := &tar.Header{
+		Name:    infoEntry,
+		Mode:    0644,
+		Size:    int64(len(infoJSON)),
+		ModTime: info.CapturedAt,
+	}
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write tar header: %w", err)
+	}
+	if _, err := tarWriter.Write(infoJSON); err != nil {
+		return fmt.Errorf("failed to write %s: %w", infoEntry, err)
+	}
+
+	for _, entry := range []struct {
+		name string
+		file *os.File
+	}{
+		{stdoutEntry, stdout},
+		{stderrEntry, stderr},
+	} {
+		if err := addFile(tarWriter, entry.name, entry.file, info.CapturedAt); err != nil {
+			return err
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return fmt.Errorf("failed to close tar writer: %w", err)
+	}
+	if err := compressor.Close(); err != nil {
+		return fmt.Errorf("failed to close compressor: %w", err)
+	}
+	return nil
+}
+
+func addFile(tarWriter *tar.Writer, name string, file *os.File, modTime time.Time) error {
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    size,
+		ModTime: modTime,
+	}
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write tar header: %w", err)
+	}
+	if _, err := io.CopyN(tarWriter, file, size); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// Restore always fails: logs are captured for inspection, not put back into the container
+func (l *LogsBackup) Restore(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options, r io.Reader) error {
+	return fmt.Errorf("logs backups are capture-only and cannot be restored; download the backup to inspect it")
+}
+
+// sizeBudget shares max-size between the stdout and stderr writers
+type sizeBudget struct {
+	remaining int64
+	exhausted bool
+}
+
+func (b *sizeBudget) writer(w io.Writer) io.Writer {
+	return &budgetWriter{budget: b, w: w}
+}
+
+type budgetWriter struct {
+	budget *sizeBudget
+	w      io.Writer
+}
+
+// Write writes what fits in the remaining budget and returns errLimitReached
+// for the rest, which stops the log stream
+func (bw *budgetWriter) Write(p []byte) (int, error) {
+	b := bw.budget
+	if int64(len(p)) <= b.remaining {
+		n, err := bw.w.Write(p)
+		b.remaining -= int64(n)
+		return n, err
+	}
+
+	n, err := bw.w.Write(p[:b.remaining])
+	b.remaining -= int64(n)
+	if err != nil {
+		return n, err
+	}
+	b.exhausted = true
+	return n, errLimitReached
+}
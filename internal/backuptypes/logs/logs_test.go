@@ -0,0 +1,193 @@
+package logs
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/shyim/docker-backup/internal/compression"
+	"github.com/shyim/docker-backup/internal/docker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+func TestLogsBackup_Name(t *testing.T) {
+	l := &LogsBackup{}
+	assert.Equal(t, "logs", l.Name())
+}
+
+func TestLogsBackup_FileExtension(t *testing.T) {
+	l := &LogsBackup{}
+	assert.Equal(t, ".tar.zst", l.FileExtension(nil))
+}
+
+func TestLogsBackup_Validate(t *testing.T) {
+	l := &LogsBackup{}
+	container := &docker.ContainerInfo{Name: "app"}
+
+	tests := []struct {
+		name        string
+		opts        backup.Options
+		expectError bool
+	}{
+		{name: "defaults", opts: nil},
+		{name: "tail and since", opts: backup.Options{OptionTail: "1000", OptionSince: "24h"}},
+		{name: "invalid tail", opts: backup.Options{OptionTail: "many"}, expectError: true},
+		{name: "negative tail", opts: backup.Options{OptionTail: "-1"}, expectError: true},
+		{name: "invalid since", opts: backup.Options{OptionSince: "1 day"}, expectError: true},
+		{name: "zero max-size", opts: backup.Options{OptionMaxSize: "0"}, expectError: true},
+		{name: "invalid timestamps", opts: backup.Options{OptionTimestamps: "sometimes"}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := l.Validate(container, tt.opts)
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestParseWindow_Defaults(t *testing.T) {
+	win, err := parseWindow(nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, win.tail)
+	assert.Equal(t, time.Duration(0), win.since)
+	assert.Equal(t, int64(defaultMaxSizeMiB)<<20, win.maxBytes)
+	assert.True(t, win.timestamps)
+}
+
+func TestSizeBudget_SharedBetweenStreams(t *testing.T) {
+	budget := &sizeBudget{remaining: 10}
+	var stdout, stderr bytes.Buffer
+	out, errOut := budget.writer(&stdout), budget.writer(&stderr)
+
+	n, err := out.Write([]byte("123456"))
+	require.NoError(t, err)
+	assert.Equal(t, 6, n)
+
+	n, err = errOut.Write([]byte("abcdef"))
+	assert.ErrorIs(t, err, errLimitReached)
+	assert.Equal(t, 4, n)
+	assert.True(t, budget.exhausted)
+
+	assert.Equal(t, "123456", stdout.String())
+	assert.Equal(t, "abcd", stderr.String())
+}
+
+func TestLogsBackup_RestoreFails(t *testing.T) {
+	l := &LogsBackup{}
+	err := l.Restore(context.Background(), &docker.ContainerInfo{Name: "app"}, nil, nil, bytes.NewReader(nil))
+	assert.ErrorContains(t, err, "capture-only")
+}
+
+// readArchive returns the entries of a logs backup by name
+func readArchive(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+
+	zr, err := zstd.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	defer zr.Close()
+
+	entries := make(map[string][]byte)
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		entries[header.Name] = content
+	}
+	return entries
+}
+
+func TestWriteArchive(t *testing.T) {
+	stdout, err := os.CreateTemp(t.TempDir(), "stdout")
+	require.NoError(t, err)
+	defer func() { _ = stdout.Close() }()
+	stderr, err := os.CreateTemp(t.TempDir(), "stderr")
+	require.NoError(t, err)
+	defer func() { _ = stderr.Close() }()
+
+	_, err = stdout.WriteString("started\nlistening on :80\n")
+	require.NoError(t, err)
+	_, err = stderr.WriteString("warning: low memory\n")
+	require.NoError(t, err)
+
+	info := captureInfo{Container: "app", ContainerID: "abc", CapturedAt: time.Now().UTC(), Truncated: true}
+	var buf bytes.Buffer
+	require.NoError(t, writeArchive(&buf, compression.Zstd, info, stdout, stderr))
+
+	entries := readArchive(t, buf.Bytes())
+	assert.Equal(t, "started\nlistening on :80\n", string(entries[stdoutEntry]))
+	assert.Equal(t, "warning: low memory\n", string(entries[stderrEntry]))
+
+	var decoded captureInfo
+	require.NoError(t, json.Unmarshal(entries[infoEntry], &decoded))
+	assert.Equal(t, "app", decoded.Container)
+	assert.True(t, decoded.Truncated)
+}
+
+func TestLogsBackup_Integration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+
+	req := testcontainers.ContainerRequest{
+		Image:      "alpine:latest",
+		Cmd:        []string{"sh", "-c", "for i in 1 2 3 4 5; do echo out-$i; echo err-$i >&2; done; sleep 3600"},
+		WaitingFor: wait.ForLog("err-5").WithStartupTimeout(30 * time.Second),
+	}
+
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	require.NoError(t, err)
+	defer func() {
+		if err := container.Terminate(ctx); err != nil {
+			t.Logf("failed to terminate container: %v", err)
+		}
+	}()
+
+	dockerClient, err := docker.NewClient("")
+	require.NoError(t, err)
+	defer func() {
+		_ = dockerClient.Close()
+	}()
+
+	containerInfo, err := dockerClient.GetContainer(ctx, container.GetContainerID())
+	require.NoError(t, err)
+
+	l := &LogsBackup{}
+
+	var buf bytes.Buffer
+	err = l.Backup(ctx, containerInfo, dockerClient, backup.Options{OptionTail: "2", OptionTimestamps: "false"}, &buf)
+	require.NoError(t, err)
+
+	entries := readArchive(t, buf.Bytes())
+	assert.Equal(t, "out-4\nout-5\n", string(entries[stdoutEntry]))
+	assert.Equal(t, "err-4\nerr-5\n", string(entries[stderrEntry]))
+
+	var info captureInfo
+	require.NoError(t, json.Unmarshal(entries[infoEntry], &info))
+	assert.Equal(t, containerInfo.Name, info.Container)
+	assert.False(t, info.Truncated)
+}
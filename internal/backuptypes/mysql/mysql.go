@@ -5,11 +5,11 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"os"
 	"strings"
 
-	"github.com/klauspost/compress/zstd"
 	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/shyim/docker-backup/internal/backuptypes/dbdump"
+	"github.com/shyim/docker-backup/internal/compression"
 	"github.com/shyim/docker-backup/internal/docker"
 )
 
@@ -23,6 +23,17 @@ const (
 	EnvMySQLPassword     = "MYSQL_PASSWORD"
 	EnvMySQLRootPassword = "MYSQL_ROOT_PASSWORD"
 	EnvMySQLDatabase     = "MYSQL_DATABASE"
+
+	// EnvMySQLPwd passes the password to the client tools
+	EnvMySQLPwd = "MYSQL_PWD"
+)
+
+// Options understood by the MySQL backup type
+const (
+	// OptionStream writes dumps straight into the archive instead of buffering them in a temp file
+	OptionStream = "stream"
+	// OptionRestoreParallel is the number of databases restored at the same time
+	OptionRestoreParallel = "restore-parallel"
 )
 
 type MySQLBackup struct{}
@@ -31,11 +42,11 @@ func (m *MySQLBackup) Name() string {
 	return "mysql"
 }
 
-func (m *MySQLBackup) FileExtension() string {
-	return ".tar.zst"
+func (m *MySQLBackup) FileExtension(opts backup.Options) string {
+	return opts.Extension(".tar")
 }
 
-func (m *MySQLBackup) Validate(container *docker.ContainerInfo) error {
+func (m *MySQLBackup) Validate(container *docker.ContainerInfo, opts backup.Options) error {
 	// Check for password - either root password or user password
 	if _, ok := container.Env[EnvMySQLRootPassword]; !ok {
 		if _, ok := container.Env[EnvMySQLPassword]; !ok {
@@ -47,7 +58,15 @@ func (m *MySQLBackup) Validate(container *docker.ContainerInfo) error {
 		}
 	}
 
-	return nil
+	if _, err := restoreParallel(opts); err != nil {
+		return err
+	}
+	stream, err := opts.Bool(OptionStream, false)
+	if err != nil {
+		return err
+	}
+	_, err = dbdump.BackupParallel(opts, stream)
+	return err
 }
 
 func (m *MySQLBackup) getCredentials(env map[string]string) (user, password string) {
@@ -60,41 +79,130 @@ func (m *MySQLBackup) getCredentials(env map[string]string) (user, password stri
 	return env[EnvMySQLUser], env[EnvMySQLPassword]
 }
 
-func (m *MySQLBackup) Backup(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, w io.Writer) error {
+func (m *MySQLBackup) Backup(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options, w io.Writer) error {
+	databases, err := m.Parts(ctx, container, dockerClient, opts)
+	if err != nil {
+		return err
+	}
+	return m.writeArchive(ctx, container, dockerClient, opts, databases, w)
+}
+
+// Parts returns the selected user databases of the container, each stored as
+// a backup of its own with the split option
+func (m *MySQLBackup) Parts(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options) ([]string, error) {
+	user, password := m.getCredentials(container.Env)
+	databases, err := m.listDatabases(ctx, container, dockerClient, user, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list databases: %w", err)
+	}
+	return dbdump.SelectDatabases(container.Name, databases, opts)
+}
+
+// BackupPart writes an archive holding only the dump of database dbname
+func (m *MySQLBackup) BackupPart(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options, dbname string, w io.Writer) error {
+	return m.writeArchive(ctx, container, dockerClient, opts, []string{dbname}, w)
+}
+
+// writeArchive dumps the given databases into a compressed tar archive
+func (m *MySQLBackup) writeArchive(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options, databases []string, w io.Writer) error {
 	user, password := m.getCredentials(container.Env)
 
-	zstdWriter, err := zstd.NewWriter(w)
+	stream, err := opts.Bool(OptionStream, false)
+	if err != nil {
+		return err
+	}
+
+	parallel, err := dbdump.BackupParallel(opts, stream)
+	if err != nil {
+		return err
+	}
+
+	codec, err := opts.Compression()
+	if err != nil {
+		return err
+	}
+	compressor, err := codec.NewWriter(w)
 	if err != nil {
-		return fmt.Errorf("failed to create zstd writer: %w", err)
+		return err
 	}
 	defer func() {
-		_ = zstdWriter.Close()
+		_ = compressor.Close()
 	}()
 
-	tarWriter := tar.NewWriter(zstdWriter)
+	tarWriter := tar.NewWriter(compressor)
 	defer func() {
 		_ = tarWriter.Close()
 	}()
 
-	databases, err := m.listDatabases(ctx, container, dockerClient, user, password)
-	if err != nil {
-		return fmt.Errorf("failed to list databases: %w", err)
+	mysqldumpCmd := m.getMySQLDumpCommand(ctx, container, dockerClient)
+	var jobs []dbdump.DumpJob
+	for _, dbname := range databases {
+		jobs = append(jobs, dbdump.DumpJob{
+			Entry: dbname + ".sql",
+			Dump: func(ctx context.Context, w io.Writer) error {
+				if err := m.dumpDatabase(ctx, container, dockerClient, mysqldumpCmd, user, password, dbname, w); err != nil {
+					return fmt.Errorf("failed to backup database %s: %w", dbname, err)
+				}
+				return nil
+			},
+		})
 	}
 
-	for _, dbname := range databases {
-		if err := m.backupDatabase(ctx, container, dockerClient, tarWriter, user, password, dbname); err != nil {
-			return fmt.Errorf("failed to backup database %s: %w", dbname, err)
-		}
+	if stream {
+		return dbdump.StreamDumps(ctx, tarWriter, jobs)
+	}
+	return dbdump.WriteDumps(ctx, tarWriter, jobs, parallel)
+}
+
+// Ping checks that the server accepts TCP connections. The official images
+// initialize a new database with a server that doesn't listen on TCP.
+func (m *MySQLBackup) Ping(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options) error {
+	user, password := m.getCredentials(container.Env)
+	cmd := []string{
+		m.getMySQLCommand(ctx, container, dockerClient),
+		"--protocol=TCP", "-h", "127.0.0.1",
+		"-u", user,
+		"-e", "SELECT 1",
 	}
 
+	result, err := dockerClient.Exec(ctx, container.ID, cmd, passwordEnv(password), nil)
+	if err != nil {
+		return fmt.Errorf("failed to execute mysql: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("mysql failed with exit code %d: %s", result.ExitCode, result.ErrorOutput())
+	}
 	return nil
 }
 
+// Query runs query with the mysql client, in the database named by
+// MYSQL_DATABASE if it is set
+func (m *MySQLBackup) Query(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options, query string) (string, error) {
+	user, password := m.getCredentials(container.Env)
+	cmd := []string{
+		m.getMySQLCommand(ctx, container, dockerClient),
+		"-u", user,
+		"-N", "-e", query,
+	}
+	if dbname := container.Env[EnvMySQLDatabase]; dbname != "" {
+		cmd = append(cmd, dbname)
+	}
+
+	result, err := dockerClient.Exec(ctx, container.ID, cmd, passwordEnv(password), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute query: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return "", fmt.Errorf("mysql failed with exit code %d: %s", result.ExitCode, result.ErrorOutput())
+	}
+	return result.Output, nil
+}
+
 // getMySQLCommand returns the appropriate mysql command for the container
 // MariaDB 11+ uses 'mariadb' instead of 'mysql'
 func (m *MySQLBackup) getMySQLCommand(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client) string {
 	// Try mariadb first (MariaDB 11+)
-	result, err := dockerClient.Exec(ctx, container.ID, []string{"which", "mariadb"}, nil)
+	result, err := dockerClient.Exec(ctx, container.ID, []string{"which", "mariadb"}, nil, nil)
 	if err == nil && result.ExitCode == 0 {
 		return "mariadb"
 	}
@@ -103,7 +211,7 @@ func (m *MySQLBackup) getMySQLCommand(ctx context.Context, container *docker.Con
 
 func (m *MySQLBackup) getMySQLDumpCommand(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client) string {
 	// Try mariadb-dump first (MariaDB 11+)
-	result, err := dockerClient.Exec(ctx, container.ID, []string{"which", "mariadb-dump"}, nil)
+	result, err := dockerClient.Exec(ctx, container.ID, []string{"which", "mariadb-dump"}, nil, nil)
 	if err == nil && result.ExitCode == 0 {
 		return "mariadb-dump"
 	}
@@ -122,18 +230,17 @@ func (m *MySQLBackup) listDatabases(ctx context.Context, container *docker.Conta
 	cmd := []string{
 		mysqlCmd,
 		"-u", user,
-		"-p" + password,
 		"-N", "-e",
 		"SELECT schema_name FROM information_schema.schemata",
 	}
 
-	result, err := dockerClient.Exec(ctx, container.ID, cmd, nil)
+	result, err := dockerClient.Exec(ctx, container.ID, cmd, passwordEnv(password), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list databases: %w", err)
 	}
 
 	if result.ExitCode != 0 {
-		return nil, fmt.Errorf("mysql failed with exit code %d: %s", result.ExitCode, result.Output)
+		return nil, fmt.Errorf("mysql failed with exit code %d: %s", result.ExitCode, result.ErrorOutput())
 	}
 
 	var databases []string
@@ -149,12 +256,11 @@ func (m *MySQLBackup) listDatabases(ctx context.Context, container *docker.Conta
 	return databases, nil
 }
 
-func (m *MySQLBackup) backupDatabase(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, tarWriter *tar.Writer, user, password, dbname string) error {
-	mysqldumpCmd := m.getMySQLDumpCommand(ctx, container, dockerClient)
+// dumpDatabase runs mysqldump for database dbname and writes its output to w
+func (m *MySQLBackup) dumpDatabase(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, mysqldumpCmd, user, password, dbname string, w io.Writer) error {
 	cmd := []string{
 		mysqldumpCmd,
 		"-u", user,
-		"-p" + password,
 		"--single-transaction",
 		"--routines",
 		"--triggers",
@@ -163,102 +269,109 @@ func (m *MySQLBackup) backupDatabase(ctx context.Context, container *docker.Cont
 		"--databases", dbname,
 	}
 
-	tmpFile, err := os.CreateTemp("", "mysqldump-*.sql")
-	if err != nil {
-		return fmt.Errorf("failed to create temp file: %w", err)
-	}
-	defer func() {
-		_ = os.Remove(tmpFile.Name())
-	}()
-	defer func() {
-		_ = tmpFile.Close()
-	}()
-
-	exitCode, err := dockerClient.ExecWithOutput(ctx, container.ID, cmd, tmpFile)
+	result, err := dockerClient.ExecWithOutput(ctx, container.ID, cmd, passwordEnv(password), w)
 	if err != nil {
 		return fmt.Errorf("failed to execute mysqldump: %w", err)
 	}
 
-	if exitCode != 0 {
-		return fmt.Errorf("mysqldump failed with exit code %d", exitCode)
+	if result.ExitCode != 0 {
+		return fmt.Errorf("mysqldump failed with exit code %d: %s", result.ExitCode, result.ErrorOutput())
 	}
 
-	fileInfo, err := tmpFile.Stat()
+	return nil
+}
+
+func (m *MySQLBackup) Restore(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options, r io.Reader) error {
+	decompressor, err := compression.NewReader(r)
 	if err != nil {
-		return fmt.Errorf("failed to stat temp file: %w", err)
+		return err
 	}
+	defer func() {
+		_ = decompressor.Close()
+	}()
 
-	if _, err := tmpFile.Seek(0, 0); err != nil {
-		return fmt.Errorf("failed to seek temp file: %w", err)
-	}
+	tarReader := tar.NewReader(decompressor)
 
-	header := &tar.Header{
-		Name: dbname + ".sql",
-		Mode: 0644,
-		Size: fileInfo.Size(),
-	}
+	user, password := m.getCredentials(container.Env)
 
-	if err := tarWriter.WriteHeader(header); err != nil {
-		return fmt.Errorf("failed to write tar header: %w", err)
+	parallel, err := restoreParallel(opts)
+	if err != nil {
+		return err
 	}
 
-	if _, err := io.Copy(tarWriter, tmpFile); err != nil {
-		return fmt.Errorf("failed to write to tar: %w", err)
-	}
+	return dbdump.Restore(ctx, dbdump.NewReader(tarReader), parallel, func(ctx context.Context, entry *dbdump.Entry, data io.Reader) error {
+		dbname := strings.TrimSuffix(entry.Name, ".sql")
 
-	return nil
+		if err := m.restoreDatabase(ctx, container, dockerClient, data, user, password); err != nil {
+			return fmt.Errorf("failed to restore database %s: %w", dbname, err)
+		}
+		return nil
+	})
 }
 
-func (m *MySQLBackup) Restore(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, r io.Reader) error {
-	zstdReader, err := zstd.NewReader(r)
+// ListArchive reports each database in the archive with the size of its dump
+func (m *MySQLBackup) ListArchive(ctx context.Context, r io.Reader, fn func(backup.ArchiveEntry) error) error {
+	decompressor, err := compression.NewReader(r)
 	if err != nil {
-		return fmt.Errorf("failed to create zstd reader: %w", err)
+		return err
 	}
-	defer zstdReader.Close()
-
-	tarReader := tar.NewReader(zstdReader)
-
-	user, password := m.getCredentials(container.Env)
-
-	for {
-		header, err := tarReader.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return fmt.Errorf("failed to read tar header: %w", err)
-		}
+	defer func() {
+		_ = decompressor.Close()
+	}()
 
-		if header.Typeflag != tar.TypeReg {
-			continue
+	return dbdump.List(dbdump.NewReader(tar.NewReader(decompressor)), func(entry *dbdump.Entry, size int64) error {
+		if err := ctx.Err(); err != nil {
+			return err
 		}
+		return fn(backup.ArchiveEntry{Name: strings.TrimSuffix(entry.Name, ".sql"), Size: size})
+	})
+}
 
-		dbname := strings.TrimSuffix(header.Name, ".sql")
-
-		if err := m.restoreDatabase(ctx, container, dockerClient, tarReader, user, password, header.Size); err != nil {
-			return fmt.Errorf("failed to restore database %s: %w", dbname, err)
-		}
+// Extract writes the dump of each database in the archive to dest as <name>.sql
+func (m *MySQLBackup) Extract(ctx context.Context, r io.Reader, dest string) error {
+	decompressor, err := compression.NewReader(r)
+	if err != nil {
+		return err
 	}
+	defer func() {
+		_ = decompressor.Close()
+	}()
 
-	return nil
+	return dbdump.Extract(ctx, dbdump.NewReader(tar.NewReader(decompressor)), dest)
 }
 
-func (m *MySQLBackup) restoreDatabase(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, r io.Reader, user, password string, size int64) error {
+func (m *MySQLBackup) restoreDatabase(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, r io.Reader, user, password string) error {
 	mysqlCmd := m.getMySQLCommand(ctx, container, dockerClient)
 	cmd := []string{
 		mysqlCmd,
 		"-u", user,
-		"-p" + password,
 	}
 
-	result, err := dockerClient.Exec(ctx, container.ID, cmd, io.LimitReader(r, size))
+	result, err := dockerClient.Exec(ctx, container.ID, cmd, passwordEnv(password), r)
 	if err != nil {
 		return fmt.Errorf("failed to execute restore command: %w", err)
 	}
 
 	if result.ExitCode != 0 {
-		return fmt.Errorf("restore failed with exit code %d: %s", result.ExitCode, result.Output)
+		return fmt.Errorf("restore failed with exit code %d: %s", result.ExitCode, result.ErrorOutput())
 	}
 
 	return nil
 }
+
+// passwordEnv hands the password to mysql and mysqldump through the exec
+// environment, as an argument it would show up in ps and docker top
+func passwordEnv(password string) []string {
+	return []string{EnvMySQLPwd + "=" + password}
+}
+
+func restoreParallel(opts backup.Options) (int, error) {
+	parallel, err := opts.Int(OptionRestoreParallel, 1)
+	if err != nil {
+		return 0, err
+	}
+	if parallel < 1 {
+		return 0, fmt.Errorf("invalid %s %d: must be at least 1", OptionRestoreParallel, parallel)
+	}
+	return parallel, nil
+}
@@ -24,7 +24,7 @@ func TestMySQLBackup_Name(t *testing.T) {
 
 func TestMySQLBackup_FileExtension(t *testing.T) {
 	m := &MySQLBackup{}
-	assert.Equal(t, ".tar.zst", m.FileExtension())
+	assert.Equal(t, ".tar.zst", m.FileExtension(nil))
 }
 
 func TestMySQLBackup_Validate(t *testing.T) {
@@ -78,7 +78,7 @@ func TestMySQLBackup_Validate(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := m.Validate(tt.container)
+			err := m.Validate(tt.container, nil)
 			if tt.expectError {
 				assert.Error(t, err)
 			} else {
@@ -127,6 +127,10 @@ func TestMySQLBackup_GetCredentials(t *testing.T) {
 	}
 }
 
+func TestPasswordEnv(t *testing.T) {
+	assert.Equal(t, []string{"MYSQL_PWD=s3cr=t;"}, passwordEnv("s3cr=t;"))
+}
+
 // TestMySQLBackup_Integration tests the full backup and restore cycle
 // using a real MySQL container via testcontainers.
 func TestMySQLBackup_Integration(t *testing.T) {
@@ -235,7 +239,7 @@ func TestMySQLBackup_Integration(t *testing.T) {
 	// Perform backup
 	m := &MySQLBackup{}
 	var backupBuffer bytes.Buffer
-	err = m.Backup(ctx, containerInfo, dockerClient, &backupBuffer)
+	err = m.Backup(ctx, containerInfo, dockerClient, nil, &backupBuffer)
 	require.NoError(t, err)
 	assert.Greater(t, backupBuffer.Len(), 0, "backup should not be empty")
 
@@ -255,7 +259,7 @@ func TestMySQLBackup_Integration(t *testing.T) {
 	assert.Equal(t, 0, count, "users table should be dropped")
 
 	// Perform restore
-	err = m.Restore(ctx, containerInfo, dockerClient, &backupBuffer)
+	err = m.Restore(ctx, containerInfo, dockerClient, nil, &backupBuffer)
 	require.NoError(t, err)
 
 	// Verify data is restored in first database
@@ -359,7 +363,7 @@ func TestMySQLBackup_LargeData(t *testing.T) {
 	// Perform backup
 	m := &MySQLBackup{}
 	var backupBuffer bytes.Buffer
-	err = m.Backup(ctx, containerInfo, dockerClient, &backupBuffer)
+	err = m.Backup(ctx, containerInfo, dockerClient, nil, &backupBuffer)
 	require.NoError(t, err)
 
 	t.Logf("Large data backup size: %d bytes", backupBuffer.Len())
@@ -369,7 +373,7 @@ func TestMySQLBackup_LargeData(t *testing.T) {
 	require.NoError(t, err)
 
 	// Restore
-	err = m.Restore(ctx, containerInfo, dockerClient, &backupBuffer)
+	err = m.Restore(ctx, containerInfo, dockerClient, nil, &backupBuffer)
 	require.NoError(t, err)
 
 	// Verify all rows are restored
@@ -465,7 +469,7 @@ func TestMySQLBackup_SpecialCharacters(t *testing.T) {
 	// Perform backup
 	m := &MySQLBackup{}
 	var backupBuffer bytes.Buffer
-	err = m.Backup(ctx, containerInfo, dockerClient, &backupBuffer)
+	err = m.Backup(ctx, containerInfo, dockerClient, nil, &backupBuffer)
 	require.NoError(t, err)
 
 	// Drop table
@@ -473,7 +477,7 @@ func TestMySQLBackup_SpecialCharacters(t *testing.T) {
 	require.NoError(t, err)
 
 	// Restore
-	err = m.Restore(ctx, containerInfo, dockerClient, &backupBuffer)
+	err = m.Restore(ctx, containerInfo, dockerClient, nil, &backupBuffer)
 	require.NoError(t, err)
 
 	// Verify all special strings are restored correctly
@@ -569,7 +573,7 @@ func TestMySQLBackup_MariaDB(t *testing.T) {
 	// Perform backup
 	m := &MySQLBackup{}
 	var backupBuffer bytes.Buffer
-	err = m.Backup(ctx, containerInfo, dockerClient, &backupBuffer)
+	err = m.Backup(ctx, containerInfo, dockerClient, nil, &backupBuffer)
 	require.NoError(t, err)
 
 	t.Logf("MariaDB backup size: %d bytes", backupBuffer.Len())
@@ -579,7 +583,7 @@ func TestMySQLBackup_MariaDB(t *testing.T) {
 	require.NoError(t, err)
 
 	// Restore
-	err = m.Restore(ctx, containerInfo, dockerClient, &backupBuffer)
+	err = m.Restore(ctx, containerInfo, dockerClient, nil, &backupBuffer)
 	require.NoError(t, err)
 
 	// Verify data is restored
@@ -0,0 +1,8 @@
+//go:build noop
+
+package backuptypes
+
+import (
+	// The noop backup type is for development and tests only
+	_ "github.com/shyim/docker-backup/internal/backuptypes/noop"
+)
@@ -0,0 +1,129 @@
+// Package noop provides a backup type that writes generated data instead of
+// reading from a container. It exists to exercise storage, retention and
+// notifications without a database or volume, and is only registered in
+// binaries built with the noop build tag.
+package noop
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+
+	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/shyim/docker-backup/internal/config"
+	"github.com/shyim/docker-backup/internal/docker"
+)
+
+func init() {
+	backup.Register(&NoopBackup{})
+}
+
+// Options understood by the noop backup type
+const (
+	// OptionSize is the number of bytes each backup writes, e.g. "10MB"
+	OptionSize = "size"
+	// OptionSeed selects the generated data; backups with the same seed and size are identical
+	OptionSeed = "seed"
+	// OptionFail makes every backup fail after writing its data
+	OptionFail = "fail"
+
+	defaultSize = "1MiB"
+)
+
+// NoopBackup writes a deterministic stream of pseudo-random bytes and
+// discards the data on restore. It never touches the container.
+type NoopBackup struct{}
+
+func (n *NoopBackup) Name() string {
+	return "noop"
+}
+
+func (n *NoopBackup) FileExtension(opts backup.Options) string {
+	return ".bin"
+}
+
+type settings struct {
+	size int64
+	seed uint64
+	fail bool
+}
+
+func parseSettings(opts backup.Options) (settings, error) {
+	size, err := config.ParseSize(opts.String(OptionSize, defaultSize))
+	if err != nil {
+		return settings{}, fmt.Errorf("invalid %s option: %w", OptionSize, err)
+	}
+	if size < 0 {
+		return settings{}, fmt.Errorf("invalid %s option: must not be negative", OptionSize)
+	}
+
+	seed, err := opts.Int(OptionSeed, 0)
+	if err != nil {
+		return settings{}, err
+	}
+
+	fail, err := opts.Bool(OptionFail, false)
+	if err != nil {
+		return settings{}, err
+	}
+
+	return settings{size: size, seed: uint64(seed), fail: fail}, nil
+}
+
+// Validate only checks the options, the container needs nothing
+func (n *NoopBackup) Validate(container *docker.ContainerInfo, opts backup.Options) error {
+	_, err := parseSettings(opts)
+	return err
+}
+
+func (n *NoopBackup) Backup(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options, w io.Writer) error {
+	s, err := parseSettings(opts)
+	if err != nil {
+		return err
+	}
+
+	written, err := io.Copy(w, io.LimitReader(newDataReader(ctx, s.seed), s.size))
+	if err != nil {
+		return fmt.Errorf("failed to write data: %w", err)
+	}
+
+	slog.Debug("noop backup written", "container", container.Name, "size", written, "seed", s.seed)
+
+	if s.fail {
+		return fmt.Errorf("noop backup failed as requested by the %s option", OptionFail)
+	}
+	return nil
+}
+
+func (n *NoopBackup) Restore(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options, r io.Reader) error {
+	read, err := io.Copy(io.Discard, r)
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+
+	slog.Debug("noop backup restored", "container", container.Name, "size", read)
+	return nil
+}
+
+// dataReader generates an endless pseudo-random stream for a seed
+type dataReader struct {
+	ctx context.Context
+	rng *rand.ChaCha8
+}
+
+func newDataReader(ctx context.Context, seed uint64) *dataReader {
+	var key [32]byte
+	for i := range 8 {
+		key[i] = byte(seed >> (8 * i))
+	}
+	return &dataReader{ctx: ctx, rng: rand.NewChaCha8(key)}
+}
+
+func (d *dataReader) Read(p []byte) (int, error) {
+	if err := d.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return d.rng.Read(p)
+}
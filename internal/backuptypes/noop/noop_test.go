@@ -0,0 +1,170 @@
+package noop
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/shyim/docker-backup/internal/config"
+	"github.com/shyim/docker-backup/internal/docker"
+	"github.com/shyim/docker-backup/internal/notification"
+	"github.com/shyim/docker-backup/internal/retention"
+	"github.com/shyim/docker-backup/internal/scheduler"
+	"github.com/shyim/docker-backup/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	_ "github.com/shyim/docker-backup/internal/storages/local"
+)
+
+func TestNoopBackup_Name(t *testing.T) {
+	n := &NoopBackup{}
+	assert.Equal(t, "noop", n.Name())
+	assert.Equal(t, ".bin", n.FileExtension(nil))
+}
+
+func TestNoopBackup_Validate(t *testing.T) {
+	n := &NoopBackup{}
+	container := &docker.ContainerInfo{Name: "app"}
+
+	assert.NoError(t, n.Validate(container, nil))
+	assert.NoError(t, n.Validate(container, backup.Options{OptionSize: "10MB", OptionSeed: "42"}))
+	assert.Error(t, n.Validate(container, backup.Options{OptionSize: "lots"}))
+	assert.Error(t, n.Validate(container, backup.Options{OptionSeed: "x"}))
+	assert.Error(t, n.Validate(container, backup.Options{OptionFail: "maybe"}))
+}
+
+func TestNoopBackup_Backup(t *testing.T) {
+	n := &NoopBackup{}
+	container := &docker.ContainerInfo{Name: "app"}
+	ctx := context.Background()
+
+	run := func(opts backup.Options) []byte {
+		var buf bytes.Buffer
+		require.NoError(t, n.Backup(ctx, container, nil, opts, &buf))
+		return buf.Bytes()
+	}
+
+	first := run(backup.Options{OptionSize: "100KB", OptionSeed: "1"})
+	assert.Len(t, first, 100<<10)
+	assert.Equal(t, first, run(backup.Options{OptionSize: "100KB", OptionSeed: "1"}))
+	assert.NotEqual(t, first, run(backup.Options{OptionSize: "100KB", OptionSeed: "2"}))
+	assert.Len(t, run(nil), 1<<20)
+	assert.Empty(t, run(backup.Options{OptionSize: "0"}))
+
+	var buf bytes.Buffer
+	err := n.Backup(ctx, container, nil, backup.Options{OptionSize: "1KB", OptionFail: "true"}, &buf)
+	assert.Error(t, err)
+	assert.Equal(t, 1<<10, buf.Len())
+}
+
+func TestNoopBackup_BackupCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var buf bytes.Buffer
+	err := (&NoopBackup{}).Backup(ctx, &docker.ContainerInfo{Name: "app"}, nil, nil, &buf)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestNoopBackup_Restore(t *testing.T) {
+	err := (&NoopBackup{}).Restore(context.Background(), &docker.ContainerInfo{Name: "app"}, nil, nil, bytes.NewReader(make([]byte, 4096)))
+	assert.NoError(t, err)
+}
+
+// recordingNotifier collects events sent to it
+type recordingNotifier struct {
+	events chan notification.Event
+}
+
+func (r *recordingNotifier) Name() string {
+	return "recorder"
+}
+
+func (r *recordingNotifier) Send(_ context.Context, event notification.Event) error {
+	r.events <- event
+	return nil
+}
+
+// TestNoopBackup_Integration runs backups through the manager against a plain
+// container, exercising storage, retention, notifications and restore.
+func TestNoopBackup_Integration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+	name := fmt.Sprintf("noop-test-%d", time.Now().UnixNano())
+
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image: "alpine:latest",
+			Name:  name,
+			Cmd:   []string{"sleep", "3600"},
+			Labels: map[string]string{
+				"docker-backup.enable":         "true",
+				"docker-backup.notify":         "recorder",
+				"docker-backup.data.type":      "noop",
+				"docker-backup.data.schedule":  "0 3 * * *",
+				"docker-backup.data.retention": "2",
+				"docker-backup.data.size":      "256KB",
+			},
+			WaitingFor: wait.ForExec([]string{"true"}).WithStartupTimeout(30 * time.Second),
+		},
+		Started: true,
+	})
+	require.NoError(t, err)
+	defer func() {
+		if err := container.Terminate(ctx); err != nil {
+			t.Logf("failed to terminate container: %v", err)
+		}
+	}()
+
+	cfg := config.New()
+	cfg.TempDir = t.TempDir()
+	cfg.StorageArgs = []string{"local.type=local", "local.path=" + t.TempDir()}
+	require.NoError(t, cfg.ParseStoragePools())
+
+	dockerClient, err := docker.NewClient(cfg.DockerHost)
+	require.NoError(t, err)
+	defer func() {
+		_ = dockerClient.Close()
+	}()
+
+	poolManager, err := storage.NewPoolManager(cfg.StoragePools, cfg.DefaultStorage)
+	require.NoError(t, err)
+
+	notifier := &recordingNotifier{events: make(chan notification.Event, 10)}
+	notifyMgr := notification.NewManager()
+	notifyMgr.AddNotifier(notifier.Name(), notifier)
+
+	mgr := backup.NewManager(docker.SingleClient(dockerClient), poolManager, scheduler.New(context.Background()), retention.New(poolManager), notifyMgr, nil, nil, cfg)
+	require.NoError(t, mgr.Start(ctx))
+
+	// Keys have a resolution of one second
+	for i := range 3 {
+		if i > 0 {
+			time.Sleep(time.Second)
+		}
+		require.NoError(t, mgr.TriggerBackup(ctx, name, "data"))
+
+		select {
+		case event := <-notifier.events:
+			assert.Equal(t, notification.EventBackupCompleted, event.Type)
+			assert.Equal(t, int64(256<<10), event.Size)
+		case <-time.After(10 * time.Second):
+			t.Fatal("no notification received")
+		}
+	}
+
+	backups, err := mgr.ListBackups(ctx, name)
+	require.NoError(t, err)
+	require.Len(t, backups, 2, "retention should keep the two newest backups")
+
+	require.NoError(t, mgr.RestoreBackup(ctx, name, backups[0].Key, nil))
+}
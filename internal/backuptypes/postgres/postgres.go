@@ -5,11 +5,13 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"os"
+	"strconv"
 	"strings"
 
-	"github.com/klauspost/compress/zstd"
+	"github.com/google/uuid"
 	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/shyim/docker-backup/internal/backuptypes/dbdump"
+	"github.com/shyim/docker-backup/internal/compression"
 	"github.com/shyim/docker-backup/internal/docker"
 )
 
@@ -29,17 +31,50 @@ const (
 	EnvPGPassword       = "PGPASSWORD"
 )
 
+// Options understood by the PostgreSQL backup type
+const (
+	// OptionStream writes dumps straight into the archive instead of buffering them in a temp file
+	OptionStream = "stream"
+	// OptionRestoreParallel is the number of databases restored at the same time
+	OptionRestoreParallel = "restore-parallel"
+	// OptionFormat selects the pg_dump format, FormatPlain or FormatCustom
+	OptionFormat = "format"
+	// OptionRestoreJobs is the number of pg_restore jobs restoring one custom-format dump
+	OptionRestoreJobs = "restore-jobs"
+	// OptionGlobals adds roles and tablespaces dumped with pg_dumpall, on by default
+	OptionGlobals = "globals"
+)
+
+// Dump formats selectable with OptionFormat
+const (
+	// FormatPlain dumps SQL scripts restored with psql
+	FormatPlain = "plain"
+	// FormatCustom dumps pg_dump's custom format, restored with pg_restore
+	FormatCustom = "custom"
+)
+
+// Archive entry extensions of the dump formats. Restore picks the tool by
+// extension, so archives of either format restore regardless of the option.
+const (
+	plainExtension  = ".sql"
+	customExtension = ".dump"
+)
+
+// restoreDir holds custom-format dumps inside the container while pg_restore
+// reads them, parallel jobs can't read from stdin
+const restoreDir = "/tmp"
+
 type PostgresBackup struct{}
 
 func (p *PostgresBackup) Name() string {
 	return "postgres"
 }
 
-func (p *PostgresBackup) FileExtension() string {
-	return ".tar.zst"
+func (p *PostgresBackup) FileExtension(opts backup.Options) string {
+	return opts.Extension(".tar")
 }
 
-func (p *PostgresBackup) Validate(container *docker.ContainerInfo) error {
+func (p *PostgresBackup) Validate(container *docker.ContainerInfo, opts backup.Options) error {
 	// Check for user
 	if _, ok := container.Env[EnvPostgresUser]; !ok {
 		if _, ok := container.Env[EnvPGUser]; !ok {
@@ -47,44 +82,176 @@ func (p *PostgresBackup) Validate(container *docker.ContainerInfo) error {
 		}
 	}
 
-	return nil
+	if _, err := restoreParallel(opts); err != nil {
+		return err
+	}
+	stream, err := opts.Bool(OptionStream, false)
+	if err != nil {
+		return err
+	}
+	if _, err := dbdump.BackupParallel(opts, stream); err != nil {
+		return err
+	}
+	if _, err := dumpFormat(opts); err != nil {
+		return err
+	}
+	if _, err := opts.Bool(OptionGlobals, true); err != nil {
+		return err
+	}
+	_, err = restoreJobs(opts)
+	return err
+}
+
+func (p *PostgresBackup) Backup(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options, w io.Writer) error {
+	databases, err := p.Parts(ctx, container, dockerClient, opts)
+	if err != nil {
+		return err
+	}
+	return p.writeArchive(ctx, container, dockerClient, opts, databases, w)
+}
+
+// Parts returns the selected databases of the container, each stored as a
+// backup of its own with the split option
+func (p *PostgresBackup) Parts(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options) ([]string, error) {
+	databases, err := p.listDatabases(ctx, container, dockerClient, postgresUser(container.Env))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list databases: %w", err)
+	}
+	return dbdump.SelectDatabases(container.Name, databases, opts)
 }
 
-func (p *PostgresBackup) Backup(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, w io.Writer) error {
-	env := container.Env
+// BackupPart writes an archive holding only the dump of database dbname
+func (p *PostgresBackup) BackupPart(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options, dbname string, w io.Writer) error {
+	return p.writeArchive(ctx, container, dockerClient, opts, []string{dbname}, w)
+}
 
-	user := env[EnvPostgresUser]
-	if user == "" {
-		user = env[EnvPGUser]
+// writeArchive dumps the given databases into a compressed tar archive
+func (p *PostgresBackup) writeArchive(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options, databases []string, w io.Writer) error {
+	user := postgresUser(container.Env)
+
+	stream, err := opts.Bool(OptionStream, false)
+	if err != nil {
+		return err
+	}
+
+	parallel, err := dbdump.BackupParallel(opts, stream)
+	if err != nil {
+		return err
+	}
+
+	format, err := dumpFormat(opts)
+	if err != nil {
+		return err
+	}
+
+	globals, err := opts.Bool(OptionGlobals, true)
+	if err != nil {
+		return err
 	}
 
-	zstdWriter, err := zstd.NewWriter(w)
+	codec, err := opts.Compression()
 	if err != nil {
-		return fmt.Errorf("failed to create zstd writer: %w", err)
+		return err
+	}
+	compressor, err := codec.NewWriter(w)
+	if err != nil {
+		return err
 	}
 	defer func() {
-		_ = zstdWriter.Close()
+		_ = compressor.Close()
 	}()
 
-	tarWriter := tar.NewWriter(zstdWriter)
+	tarWriter := tar.NewWriter(compressor)
 	defer func() {
 		_ = tarWriter.Close()
 	}()
 
-	databases, err := p.listDatabases(ctx, container, dockerClient, user)
-	if err != nil {
-		return fmt.Errorf("failed to list databases: %w", err)
+	// The globals come first, so restore creates the roles the databases
+	// reference before their dumps are restored
+	var jobs []dbdump.DumpJob
+	if globals {
+		cmd := []string{"pg_dumpall", "-U", user, "--globals-only"}
+		jobs = append(jobs, dbdump.DumpJob{
+			Entry: dbdump.GlobalsEntry,
+			Dump: func(ctx context.Context, w io.Writer) error {
+				if err := p.runDump(ctx, container, dockerClient, cmd, w); err != nil {
+					return fmt.Errorf("failed to backup global objects: %w", err)
+				}
+				return nil
+			},
+		})
 	}
 
 	for _, dbname := range databases {
-		if err := p.backupDatabase(ctx, container, dockerClient, tarWriter, user, dbname); err != nil {
-			return fmt.Errorf("failed to backup database %s: %w", dbname, err)
-		}
+		cmd, entryName := dumpCommand(user, dbname, format)
+		jobs = append(jobs, dbdump.DumpJob{
+			Entry: entryName,
+			Dump: func(ctx context.Context, w io.Writer) error {
+				if err := p.runDump(ctx, container, dockerClient, cmd, w); err != nil {
+					return fmt.Errorf("failed to backup database %s: %w", dbname, err)
+				}
+				return nil
+			},
+		})
+	}
+
+	if stream {
+		return dbdump.StreamDumps(ctx, tarWriter, jobs)
 	}
+	return dbdump.WriteDumps(ctx, tarWriter, jobs, parallel)
+}
+
+// Ping checks that the server accepts TCP connections. The official image
+// initializes a new database with a server listening only on its socket.
+func (p *PostgresBackup) Ping(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options) error {
+	cmd := []string{"pg_isready", "-h", "127.0.0.1", "-U", postgresUser(container.Env)}
 
+	result, err := dockerClient.Exec(ctx, container.ID, cmd, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to execute pg_isready: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("pg_isready failed with exit code %d: %s", result.ExitCode, strings.TrimSpace(result.Output))
+	}
 	return nil
 }
 
+// Query runs query with psql in the database named by POSTGRES_DB, or the
+// user's database if it isn't set
+func (p *PostgresBackup) Query(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options, query string) (string, error) {
+	user := postgresUser(container.Env)
+	dbname := container.Env[EnvPostgresDB]
+	if dbname == "" {
+		dbname = user
+	}
+
+	cmd := []string{
+		"psql",
+		"-U", user,
+		"-d", dbname,
+		"-v", "ON_ERROR_STOP=1",
+		"-t", "-A",
+		"-c", query,
+	}
+
+	result, err := dockerClient.Exec(ctx, container.ID, cmd, nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute query: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return "", fmt.Errorf("psql failed with exit code %d: %s", result.ExitCode, result.ErrorOutput())
+	}
+	return result.Output, nil
+}
+
+// postgresUser returns the user the client tools connect as
+func postgresUser(env map[string]string) string {
+	if user := env[EnvPostgresUser]; user != "" {
+		return user
+	}
+	return env[EnvPGUser]
+}
+
 func (p *PostgresBackup) listDatabases(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, user string) ([]string, error) {
 	cmd := []string{
 		"psql",
@@ -94,13 +261,13 @@ func (p *PostgresBackup) listDatabases(ctx context.Context, container *docker.Co
 		"-c", "SELECT datname FROM pg_database WHERE datistemplate = false AND datname != 'postgres'",
 	}
 
-	result, err := dockerClient.Exec(ctx, container.ID, cmd, nil)
+	result, err := dockerClient.Exec(ctx, container.ID, cmd, nil, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list databases: %w", err)
 	}
 
 	if result.ExitCode != 0 {
-		return nil, fmt.Errorf("psql failed with exit code %d: %s", result.ExitCode, result.Output)
+		return nil, fmt.Errorf("psql failed with exit code %d: %s", result.ExitCode, result.ErrorOutput())
 	}
 
 	var databases []string
@@ -114,116 +281,206 @@ func (p *PostgresBackup) listDatabases(ctx context.Context, container *docker.Co
 	return databases, nil
 }
 
-func (p *PostgresBackup) backupDatabase(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, tarWriter *tar.Writer, user, dbname string) error {
+// dumpCommand returns the pg_dump command for database dbname in format and
+// the name of its archive entry
+func dumpCommand(user, dbname, format string) ([]string, string) {
 	cmd := []string{
 		"pg_dump",
 		"-U", user,
 		"-d", dbname,
-		"--clean",
-		"--if-exists",
-		"--create",
 	}
-
-	tmpFile, err := os.CreateTemp("", "pgdump-*.sql")
-	if err != nil {
-		return fmt.Errorf("failed to create temp file: %w", err)
+	if format == FormatCustom {
+		// The archive is compressed as a whole, so pg_dump doesn't compress on its own.
+		// --clean and --create are options of pg_restore for this format.
+		return append(cmd, "--format=custom", "--compress=0"), dbname + customExtension
 	}
-	defer func() {
-		_ = os.Remove(tmpFile.Name())
-	}()
-	defer func() {
-		_ = tmpFile.Close()
-	}()
+	return append(cmd, "--clean", "--if-exists", "--create"), dbname + plainExtension
+}
 
-	exitCode, err := dockerClient.ExecWithOutput(ctx, container.ID, cmd, tmpFile)
+// runDump runs the dump command cmd in the container and writes its output to w
+func (p *PostgresBackup) runDump(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, cmd []string, w io.Writer) error {
+	result, err := dockerClient.ExecWithOutput(ctx, container.ID, cmd, nil, w)
 	if err != nil {
-		return fmt.Errorf("failed to execute pg_dump: %w", err)
+		return fmt.Errorf("failed to execute %s: %w", cmd[0], err)
 	}
 
-	if exitCode != 0 {
-		return fmt.Errorf("pg_dump failed with exit code %d", exitCode)
+	if result.ExitCode != 0 {
+		return fmt.Errorf("%s failed with exit code %d: %s", cmd[0], result.ExitCode, result.ErrorOutput())
 	}
 
-	fileInfo, err := tmpFile.Stat()
+	return nil
+}
+
+func (p *PostgresBackup) Restore(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options, r io.Reader) error {
+	decompressor, err := compression.NewReader(r)
 	if err != nil {
-		return fmt.Errorf("failed to stat temp file: %w", err)
+		return err
 	}
+	defer func() {
+		_ = decompressor.Close()
+	}()
 
-	if _, err := tmpFile.Seek(0, 0); err != nil {
-		return fmt.Errorf("failed to seek temp file: %w", err)
-	}
+	tarReader := tar.NewReader(decompressor)
 
-	header := &tar.Header{
-		Name: dbname + ".sql",
-		Mode: 0644,
-		Size: fileInfo.Size(),
-	}
+	user := postgresUser(container.Env)
 
-	if err := tarWriter.WriteHeader(header); err != nil {
-		return fmt.Errorf("failed to write tar header: %w", err)
+	parallel, err := restoreParallel(opts)
+	if err != nil {
+		return err
 	}
 
-	if _, err := io.Copy(tarWriter, tmpFile); err != nil {
-		return fmt.Errorf("failed to write to tar: %w", err)
+	jobs, err := restoreJobs(opts)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return dbdump.Restore(ctx, dbdump.NewReader(tarReader), parallel, func(ctx context.Context, entry *dbdump.Entry, data io.Reader) error {
+		dbname := databaseName(entry.Name)
+
+		var err error
+		if strings.HasSuffix(entry.Name, customExtension) {
+			err = p.restoreCustomDatabase(ctx, container, dockerClient, data, user, jobs)
+		} else {
+			err = p.restoreDatabase(ctx, container, dockerClient, data, user)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to restore database %s: %w", dbname, err)
+		}
+		return nil
+	})
 }
 
-func (p *PostgresBackup) Restore(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, r io.Reader) error {
-	zstdReader, err := zstd.NewReader(r)
+// ListArchive reports each database in the archive with the size of its dump
+func (p *PostgresBackup) ListArchive(ctx context.Context, r io.Reader, fn func(backup.ArchiveEntry) error) error {
+	decompressor, err := compression.NewReader(r)
 	if err != nil {
-		return fmt.Errorf("failed to create zstd reader: %w", err)
+		return err
 	}
-	defer zstdReader.Close()
-
-	tarReader := tar.NewReader(zstdReader)
+	defer func() {
+		_ = decompressor.Close()
+	}()
 
-	env := container.Env
+	return dbdump.List(dbdump.NewReader(tar.NewReader(decompressor)), func(entry *dbdump.Entry, size int64) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fn(backup.ArchiveEntry{Name: databaseName(entry.Name), Size: size})
+	})
+}
 
-	user := env[EnvPostgresUser]
-	if user == "" {
-		user = env[EnvPGUser]
+// Extract writes the dump of each database in the archive to dest as
+// <name>.sql, or <name>.dump for custom-format dumps
+func (p *PostgresBackup) Extract(ctx context.Context, r io.Reader, dest string) error {
+	decompressor, err := compression.NewReader(r)
+	if err != nil {
+		return err
 	}
+	defer func() {
+		_ = decompressor.Close()
+	}()
 
-	for {
-		header, err := tarReader.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return fmt.Errorf("failed to read tar header: %w", err)
-		}
+	return dbdump.Extract(ctx, dbdump.NewReader(tar.NewReader(decompressor)), dest)
+}
 
-		if header.Typeflag != tar.TypeReg {
-			continue
-		}
+func (p *PostgresBackup) restoreDatabase(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, r io.Reader, user string) error {
+	cmd := []string{
+		"psql",
+		"-U", user,
+		"-d", "postgres",
+	}
 
-		dbname := strings.TrimSuffix(header.Name, ".sql")
+	result, err := dockerClient.Exec(ctx, container.ID, cmd, nil, r)
+	if err != nil {
+		return fmt.Errorf("failed to execute restore command: %w", err)
+	}
 
-		if err := p.restoreDatabase(ctx, container, dockerClient, tarReader, user, header.Size); err != nil {
-			return fmt.Errorf("failed to restore database %s: %w", dbname, err)
-		}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("restore failed with exit code %d: %s", result.ExitCode, result.ErrorOutput())
 	}
 
 	return nil
 }
 
-func (p *PostgresBackup) restoreDatabase(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, r io.Reader, user string, size int64) error {
+// restoreCustomDatabase restores a custom-format dump with pg_restore. With
+// more than one job the dump is copied into the container first, since
+// pg_restore only reads files in parallel.
+func (p *PostgresBackup) restoreCustomDatabase(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, r io.Reader, user string, jobs int) error {
 	cmd := []string{
-		"psql",
+		"pg_restore",
 		"-U", user,
 		"-d", "postgres",
+		"--clean",
+		"--if-exists",
+		"--create",
 	}
 
-	result, err := dockerClient.Exec(ctx, container.ID, cmd, io.LimitReader(r, size))
-	if err != nil {
-		return fmt.Errorf("failed to execute restore command: %w", err)
+	if jobs == 1 {
+		result, err := dockerClient.Exec(ctx, container.ID, cmd, nil, r)
+		if err != nil {
+			return fmt.Errorf("failed to execute pg_restore: %w", err)
+		}
+		if result.ExitCode != 0 {
+			return fmt.Errorf("pg_restore failed with exit code %d: %s", result.ExitCode, result.ErrorOutput())
+		}
+		return nil
 	}
 
+	dumpPath := restoreDir + "/docker-backup-" + uuid.New().String() + customExtension
+	defer func() {
+		_, _ = dockerClient.Exec(context.WithoutCancel(ctx), container.ID, []string{"rm", "-f", dumpPath}, nil, nil)
+	}()
+
+	result, err := dockerClient.Exec(ctx, container.ID, []string{"sh", "-c", `cat > "$1"`, "sh", dumpPath}, nil, r)
+	if err != nil {
+		return fmt.Errorf("failed to copy dump into container: %w", err)
+	}
 	if result.ExitCode != 0 {
-		return fmt.Errorf("restore failed with exit code %d: %s", result.ExitCode, result.Output)
+		return fmt.Errorf("failed to copy dump into container: exit code %d: %s", result.ExitCode, result.ErrorOutput())
 	}
 
+	cmd = append(cmd, "--jobs", strconv.Itoa(jobs), dumpPath)
+	result, err = dockerClient.Exec(ctx, container.ID, cmd, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to execute pg_restore: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("pg_restore failed with exit code %d: %s", result.ExitCode, result.ErrorOutput())
+	}
 	return nil
 }
+
+// databaseName returns the database an archive entry holds the dump of
+func databaseName(entryName string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(entryName, plainExtension), customExtension)
+}
+
+func dumpFormat(opts backup.Options) (string, error) {
+	switch format := opts.String(OptionFormat, FormatPlain); format {
+	case FormatPlain, FormatCustom:
+		return format, nil
+	default:
+		return "", fmt.Errorf("invalid %s %q: must be %s or %s", OptionFormat, format, FormatPlain, FormatCustom)
+	}
+}
+
+func restoreJobs(opts backup.Options) (int, error) {
+	jobs, err := opts.Int(OptionRestoreJobs, 1)
+	if err != nil {
+		return 0, err
+	}
+	if jobs < 1 {
+		return 0, fmt.Errorf("invalid %s %d: must be at least 1", OptionRestoreJobs, jobs)
+	}
+	return jobs, nil
+}
+
+func restoreParallel(opts backup.Options) (int, error) {
+	parallel, err := opts.Int(OptionRestoreParallel, 1)
+	if err != nil {
+		return 0, err
+	}
+	if parallel < 1 {
+		return 0, fmt.Errorf("invalid %s %d: must be at least 1", OptionRestoreParallel, parallel)
+	}
+	return parallel, nil
+}
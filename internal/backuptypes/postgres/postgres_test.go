@@ -9,6 +9,8 @@ import (
 	"time"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/shyim/docker-backup/internal/backuptypes/dbdump"
 	"github.com/shyim/docker-backup/internal/docker"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -24,7 +26,7 @@ func TestPostgresBackup_Name(t *testing.T) {
 
 func TestPostgresBackup_FileExtension(t *testing.T) {
 	p := &PostgresBackup{}
-	assert.Equal(t, ".tar.zst", p.FileExtension())
+	assert.Equal(t, ".tar.zst", p.FileExtension(nil))
 }
 
 func TestPostgresBackup_Validate(t *testing.T) {
@@ -67,7 +69,7 @@ func TestPostgresBackup_Validate(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := p.Validate(tt.container)
+			err := p.Validate(tt.container, nil)
 			if tt.expectError {
 				assert.Error(t, err)
 			} else {
@@ -77,6 +79,35 @@ func TestPostgresBackup_Validate(t *testing.T) {
 	}
 }
 
+func TestPostgresBackup_ValidateRestoreParallel(t *testing.T) {
+	p := &PostgresBackup{}
+	container := &docker.ContainerInfo{Name: "test", Env: map[string]string{"POSTGRES_USER": "testuser"}}
+
+	assert.NoError(t, p.Validate(container, backup.Options{OptionRestoreParallel: "4"}))
+	assert.Error(t, p.Validate(container, backup.Options{OptionRestoreParallel: "0"}))
+	assert.Error(t, p.Validate(container, backup.Options{OptionRestoreParallel: "many"}))
+	assert.NoError(t, p.Validate(container, backup.Options{dbdump.OptionBackupParallel: "4"}))
+	assert.Error(t, p.Validate(container, backup.Options{dbdump.OptionBackupParallel: "4", OptionStream: "true"}))
+}
+
+func TestPostgresBackup_ValidateFormat(t *testing.T) {
+	p := &PostgresBackup{}
+	container := &docker.ContainerInfo{Name: "test", Env: map[string]string{"POSTGRES_USER": "testuser"}}
+
+	assert.NoError(t, p.Validate(container, backup.Options{OptionFormat: FormatCustom, OptionRestoreJobs: "4"}))
+	assert.NoError(t, p.Validate(container, backup.Options{OptionFormat: FormatPlain}))
+	assert.Error(t, p.Validate(container, backup.Options{OptionFormat: "directory"}))
+	assert.Error(t, p.Validate(container, backup.Options{OptionRestoreJobs: "0"}))
+	assert.NoError(t, p.Validate(container, backup.Options{OptionGlobals: "false"}))
+	assert.Error(t, p.Validate(container, backup.Options{OptionGlobals: "sometimes"}))
+}
+
+func TestDatabaseName(t *testing.T) {
+	assert.Equal(t, "shop", databaseName("shop.sql"))
+	assert.Equal(t, "shop", databaseName("shop.dump"))
+	assert.Equal(t, "globals", databaseName(dbdump.GlobalsEntry))
+}
+
 // TestPostgresBackup_Integration tests the full backup and restore cycle
 // using a real PostgreSQL container via testcontainers.
 func TestPostgresBackup_Integration(t *testing.T) {
@@ -191,7 +222,7 @@ func TestPostgresBackup_Integration(t *testing.T) {
 	// Perform backup
 	p := &PostgresBackup{}
 	var backupBuffer bytes.Buffer
-	err = p.Backup(ctx, containerInfo, dockerClient, &backupBuffer)
+	err = p.Backup(ctx, containerInfo, dockerClient, nil, &backupBuffer)
 	require.NoError(t, err)
 	assert.Greater(t, backupBuffer.Len(), 0, "backup should not be empty")
 
@@ -212,7 +243,7 @@ func TestPostgresBackup_Integration(t *testing.T) {
 	assert.Equal(t, 0, count, "users table should be dropped")
 
 	// Perform restore
-	err = p.Restore(ctx, containerInfo, dockerClient, &backupBuffer)
+	err = p.Restore(ctx, containerInfo, dockerClient, nil, &backupBuffer)
 	require.NoError(t, err)
 
 	// Verify data is restored in first database
@@ -322,7 +353,7 @@ func TestPostgresBackup_LargeData(t *testing.T) {
 	// Perform backup
 	p := &PostgresBackup{}
 	var backupBuffer bytes.Buffer
-	err = p.Backup(ctx, containerInfo, dockerClient, &backupBuffer)
+	err = p.Backup(ctx, containerInfo, dockerClient, nil, &backupBuffer)
 	require.NoError(t, err)
 
 	t.Logf("Large data backup size: %d bytes", backupBuffer.Len())
@@ -332,7 +363,7 @@ func TestPostgresBackup_LargeData(t *testing.T) {
 	require.NoError(t, err)
 
 	// Restore
-	err = p.Restore(ctx, containerInfo, dockerClient, &backupBuffer)
+	err = p.Restore(ctx, containerInfo, dockerClient, nil, &backupBuffer)
 	require.NoError(t, err)
 
 	// Verify all rows are restored
@@ -427,7 +458,7 @@ func TestPostgresBackup_SpecialCharacters(t *testing.T) {
 	// Perform backup
 	p := &PostgresBackup{}
 	var backupBuffer bytes.Buffer
-	err = p.Backup(ctx, containerInfo, dockerClient, &backupBuffer)
+	err = p.Backup(ctx, containerInfo, dockerClient, nil, &backupBuffer)
 	require.NoError(t, err)
 
 	// Drop table
@@ -435,7 +466,7 @@ func TestPostgresBackup_SpecialCharacters(t *testing.T) {
 	require.NoError(t, err)
 
 	// Restore
-	err = p.Restore(ctx, containerInfo, dockerClient, &backupBuffer)
+	err = p.Restore(ctx, containerInfo, dockerClient, nil, &backupBuffer)
 	require.NoError(t, err)
 
 	// Verify all special strings are restored correctly
@@ -458,3 +489,161 @@ func TestPostgresBackup_SpecialCharacters(t *testing.T) {
 		assert.Equal(t, expected, restored[i], "special string %d should match", i)
 	}
 }
+
+// TestPostgresBackup_StreamedIntegration tests a backup written with the stream option,
+// which pipes pg_dump output straight into the archive without a temp file.
+func TestPostgresBackup_StreamedIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+
+	pgContainer, err := postgres.Run(ctx,
+		"postgres:16-alpine",
+		postgres.WithDatabase("testdb"),
+		postgres.WithUsername("testuser"),
+		postgres.WithPassword("testpass"),
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(30*time.Second),
+		),
+	)
+	require.NoError(t, err)
+	defer func() {
+		if err := pgContainer.Terminate(ctx); err != nil {
+			t.Logf("failed to terminate container: %v", err)
+		}
+	}()
+
+	dockerClient, err := docker.NewClient("")
+	require.NoError(t, err)
+	defer func() {
+		_ = dockerClient.Close()
+	}()
+
+	containerInfo, err := dockerClient.GetContainer(ctx, pgContainer.GetContainerID())
+	require.NoError(t, err)
+
+	connStr, err := pgContainer.ConnectionString(ctx, "sslmode=disable")
+	require.NoError(t, err)
+
+	db, err := sql.Open("pgx", connStr)
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+
+	require.Eventually(t, func() bool {
+		return db.Ping() == nil
+	}, 10*time.Second, 100*time.Millisecond)
+
+	_, err = db.Exec(`CREATE TABLE events (id SERIAL PRIMARY KEY, payload TEXT NOT NULL)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO events (payload) SELECT md5(i::text) FROM generate_series(1, 1000) AS i`)
+	require.NoError(t, err)
+
+	p := &PostgresBackup{}
+	opts := backup.Options{OptionStream: "true"}
+
+	var backupBuffer bytes.Buffer
+	err = p.Backup(ctx, containerInfo, dockerClient, opts, &backupBuffer)
+	require.NoError(t, err)
+
+	_, err = db.Exec(`DROP TABLE events`)
+	require.NoError(t, err)
+
+	err = p.Restore(ctx, containerInfo, dockerClient, opts, &backupBuffer)
+	require.NoError(t, err)
+
+	var count int
+	err = db.QueryRow(`SELECT COUNT(*) FROM events`).Scan(&count)
+	require.NoError(t, err)
+	assert.Equal(t, 1000, count)
+}
+
+func TestPostgresBackup_CustomFormatIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+
+	pgContainer, err := postgres.Run(ctx,
+		"postgres:16-alpine",
+		postgres.WithDatabase("testdb"),
+		postgres.WithUsername("testuser"),
+		postgres.WithPassword("testpass"),
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(30*time.Second),
+		),
+	)
+	require.NoError(t, err)
+	defer func() {
+		if err := pgContainer.Terminate(ctx); err != nil {
+			t.Logf("failed to terminate container: %v", err)
+		}
+	}()
+
+	dockerClient, err := docker.NewClient("")
+	require.NoError(t, err)
+	defer func() {
+		_ = dockerClient.Close()
+	}()
+
+	containerInfo, err := dockerClient.GetContainer(ctx, pgContainer.GetContainerID())
+	require.NoError(t, err)
+
+	connStr, err := pgContainer.ConnectionString(ctx, "sslmode=disable")
+	require.NoError(t, err)
+
+	db, err := sql.Open("pgx", connStr)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return db.Ping() == nil
+	}, 10*time.Second, 100*time.Millisecond)
+
+	_, err = db.Exec(`CREATE TABLE events (id SERIAL PRIMARY KEY, payload TEXT NOT NULL)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO events (payload) SELECT md5(i::text) FROM generate_series(1, 1000) AS i`)
+	require.NoError(t, err)
+
+	p := &PostgresBackup{}
+	opts := backup.Options{OptionFormat: FormatCustom, OptionRestoreJobs: "2"}
+
+	var backupBuffer bytes.Buffer
+	err = p.Backup(ctx, containerInfo, dockerClient, opts, &backupBuffer)
+	require.NoError(t, err)
+
+	var names []string
+	require.NoError(t, p.ListArchive(ctx, bytes.NewReader(backupBuffer.Bytes()), func(entry backup.ArchiveEntry) error {
+		names = append(names, entry.Name)
+		return nil
+	}))
+	// The global objects are dumped before the databases
+	assert.Equal(t, []string{"globals", "testdb"}, names)
+
+	_, err = db.Exec(`DELETE FROM events WHERE id > 10`)
+	require.NoError(t, err)
+
+	// pg_restore drops the database, which fails while it has connections
+	require.NoError(t, db.Close())
+
+	err = p.Restore(ctx, containerInfo, dockerClient, opts, &backupBuffer)
+	require.NoError(t, err)
+
+	db, err = sql.Open("pgx", connStr)
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+
+	var count int
+	err = db.QueryRow(`SELECT COUNT(*) FROM events`).Scan(&count)
+	require.NoError(t, err)
+	assert.Equal(t, 1000, count)
+}
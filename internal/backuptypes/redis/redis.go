@@ -0,0 +1,439 @@
+package redis
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/shyim/docker-backup/internal/compression"
+	"github.com/shyim/docker-backup/internal/docker"
+)
+
+func init() {
+	backup.Register(&RedisBackup{})
+}
+
+// EnvRedisPassword is the environment variable holding the password used for AUTH
+const EnvRedisPassword = "REDIS_PASSWORD"
+
+// Options understood by the Redis backup type
+const (
+	// OptionDataDir is the path of the mounted data directory inside the container
+	OptionDataDir = "data-dir"
+	// OptionSaveMode selects the command that writes the snapshot
+	OptionSaveMode = "save-mode"
+)
+
+// Save modes
+const (
+	// SaveModeBGSave forks a child process to write the snapshot, Redis keeps serving clients
+	SaveModeBGSave = "bgsave"
+	// SaveModeSave writes the snapshot in the main process, blocking all clients until done
+	SaveModeSave = "save"
+)
+
+const (
+	defaultDataDir    = "/data"
+	defaultDBFilename = "dump.rdb"
+
+	// bgsavePollInterval is how often the status of a running BGSAVE is checked
+	bgsavePollInterval = 500 * time.Millisecond
+)
+
+type RedisBackup struct{}
+
+func (r *RedisBackup) Name() string {
+	return "redis"
+}
+
+func (r *RedisBackup) FileExtension(opts backup.Options) string {
+	return opts.Extension(".rdb")
+}
+
+func (r *RedisBackup) Validate(container *docker.ContainerInfo, opts backup.Options) error {
+	if _, err := saveMode(opts); err != nil {
+		return err
+	}
+
+	_, err := dataMount(container, opts)
+	return err
+}
+
+// Backup writes a fresh RDB snapshot and streams it out compressed
+func (r *RedisBackup) Backup(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options, w io.Writer) error {
+	mode, err := saveMode(opts)
+	if err != nil {
+		return err
+	}
+	if _, err := dataMount(container, opts); err != nil {
+		return err
+	}
+
+	cli := newCLI(container, dockerClient)
+
+	dir, dbfilename := r.rdbLocation(ctx, cli, opts)
+
+	if mode == SaveModeSave {
+		if err := cli.expect(ctx, "OK", "SAVE"); err != nil {
+			return fmt.Errorf("failed to save snapshot: %w", err)
+		}
+	} else if err := r.bgsave(ctx, cli); err != nil {
+		return fmt.Errorf("failed to save snapshot: %w", err)
+	}
+
+	rdbPath := path.Join(dir, dbfilename)
+	reader, err := dockerClient.CopyFromContainer(ctx, container.ID, rdbPath)
+	if err != nil {
+		return fmt.Errorf("failed to copy %s from container: %w", rdbPath, err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	tarReader := tar.NewReader(reader)
+	header, err := tarReader.Next()
+	if err != nil {
+		return fmt.Errorf("failed to read %s from container: %w", rdbPath, err)
+	}
+	if header.Typeflag != tar.TypeReg {
+		return fmt.Errorf("%s is not a regular file", rdbPath)
+	}
+
+	codec, err := opts.Compression()
+	if err != nil {
+		return err
+	}
+	compressor, err := codec.NewWriter(w)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = compressor.Close()
+	}()
+
+	if _, err := io.Copy(compressor, tarReader); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	return compressor.Close()
+}
+
+// bgsave starts a BGSAVE and waits for it to finish. A snapshot already in
+// progress may have started before the backup was requested, so it is waited
+// for and a new one started afterwards.
+func (r *RedisBackup) bgsave(ctx context.Context, cli *redisCLI) error {
+	if err := r.waitForBGSave(ctx, cli); err != nil {
+		return err
+	}
+
+	if err := cli.expect(ctx, "Background saving started", "BGSAVE"); err != nil {
+		return err
+	}
+
+	if err := r.waitForBGSave(ctx, cli); err != nil {
+		return err
+	}
+
+	info, err := cli.run(ctx, "INFO", "persistence")
+	if err != nil {
+		return err
+	}
+	if status := infoField(info, "rdb_last_bgsave_status"); status != "ok" {
+		return fmt.Errorf("BGSAVE failed with status %q, see the Redis logs", status)
+	}
+
+	return nil
+}
+
+// waitForBGSave returns once no BGSAVE is running
+func (r *RedisBackup) waitForBGSave(ctx context.Context, cli *redisCLI) error {
+	for {
+		info, err := cli.run(ctx, "INFO", "persistence")
+		if err != nil {
+			return err
+		}
+
+		inProgress := infoField(info, "rdb_bgsave_in_progress")
+		if inProgress == "" {
+			return fmt.Errorf("unexpected INFO persistence output: %s", strings.TrimSpace(info))
+		}
+		if inProgress == "0" {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(bgsavePollInterval):
+		}
+	}
+}
+
+// Restore replaces the RDB file with the one from the backup. Redis only reads
+// it on startup, so the containers using the data directory are stopped while
+// the file is replaced and started again afterwards.
+func (r *RedisBackup) Restore(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options, rd io.Reader) error {
+	if _, err := dataMount(container, opts); err != nil {
+		return err
+	}
+
+	cli := newCLI(container, dockerClient)
+
+	appendOnly, err := cli.configGet(ctx, "appendonly")
+	if err == nil && appendOnly == "yes" {
+		return fmt.Errorf("container %s has appendonly enabled, Redis would load the AOF instead of the restored RDB file", container.Name)
+	}
+
+	dir, dbfilename := r.rdbLocation(ctx, cli, opts)
+
+	tmpFile, err := os.CreateTemp(backup.TempDirFromContext(ctx), "redis-restore-*.rdb")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmpFile.Name())
+	}()
+	defer func() {
+		_ = tmpFile.Close()
+	}()
+
+	decompressor, err := compression.NewReader(rd)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = decompressor.Close()
+	}()
+
+	size, err := io.Copy(tmpFile, decompressor)
+	if err != nil {
+		return fmt.Errorf("failed to decompress snapshot: %w", err)
+	}
+	if _, err := tmpFile.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek temp file: %w", err)
+	}
+
+	// The restored file gets the owner of the data directory, so Redis can
+	// still write snapshots when it doesn't run as root
+	uid, gid, err := dockerClient.PathOwner(ctx, container.ID, dir)
+	if err != nil {
+		return fmt.Errorf("failed to read owner of %s: %w", dir, err)
+	}
+
+	restart, err := dockerClient.StopPathUsers(ctx, container, dir, 30*time.Second)
+	if err != nil {
+		return err
+	}
+	defer restart(ctx)
+
+	pr, pw := io.Pipe()
+	go func() {
+		tarWriter := tar.NewWriter(pw)
+		err := tarWriter.WriteHeader(&tar.Header{
+			Name:    dbfilename,
+			Mode:    0644,
+			Size:    size,
+			Uid:     uid,
+			Gid:     gid,
+			ModTime: time.Now(),
+		})
+		if err == nil {
+			_, err = io.Copy(tarWriter, tmpFile)
+		}
+		if err == nil {
+			err = tarWriter.Close()
+		}
+		_ = pw.CloseWithError(err)
+	}()
+
+	if err := dockerClient.CopyToContainer(ctx, container.ID, dir, pr); err != nil {
+		_ = pr.CloseWithError(err)
+		return fmt.Errorf("failed to copy snapshot into container: %w", err)
+	}
+
+	slog.Debug("replaced redis snapshot",
+		"container", container.Name,
+		"path", path.Join(dir, dbfilename),
+		"size", size,
+	)
+
+	return nil
+}
+
+// Extract writes the snapshot in the archive to dest as dump.rdb
+func (r *RedisBackup) Extract(ctx context.Context, rd io.Reader, dest string) error {
+	decompressor, err := compression.NewReader(rd)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = decompressor.Close()
+	}()
+
+	f, err := os.OpenFile(filepath.Join(dest, defaultDBFilename), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", defaultDBFilename, err)
+	}
+
+	if _, err := io.Copy(f, decompressor); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write %s: %w", defaultDBFilename, err)
+	}
+	return f.Close()
+}
+
+// rdbLocation returns the directory and file name Redis writes its snapshot
+// to. CONFIG can be renamed or disabled, so the data-dir option and the
+// default file name are used when it can't be queried.
+func (r *RedisBackup) rdbLocation(ctx context.Context, cli *redisCLI, opts backup.Options) (dir, dbfilename string) {
+	dir, err := cli.configGet(ctx, "dir")
+	if err != nil || dir == "" {
+		dir = opts.String(OptionDataDir, defaultDataDir)
+	}
+
+	dbfilename, err = cli.configGet(ctx, "dbfilename")
+	if err != nil || dbfilename == "" || strings.Contains(dbfilename, "/") {
+		dbfilename = defaultDBFilename
+	}
+
+	return dir, dbfilename
+}
+
+// dataMount returns the mount holding the Redis data directory
+func dataMount(container *docker.ContainerInfo, opts backup.Options) (docker.MountInfo, error) {
+	dataDir := path.Clean(opts.String(OptionDataDir, defaultDataDir))
+
+	for _, mount := range container.Mounts {
+		if path.Clean(mount.Destination) == dataDir {
+			if mount.Type != "volume" && mount.Type != "bind" {
+				return docker.MountInfo{}, fmt.Errorf("container %s mounts %s as %s, the data directory must be a volume or bind mount", container.Name, dataDir, mount.Type)
+			}
+			return mount, nil
+		}
+	}
+
+	return docker.MountInfo{}, fmt.Errorf("container %s has no volume mounted at %s (set %s to the mounted data directory)", container.Name, dataDir, OptionDataDir)
+}
+
+func saveMode(opts backup.Options) (string, error) {
+	mode := opts.String(OptionSaveMode, SaveModeBGSave)
+	switch mode {
+	case SaveModeBGSave, SaveModeSave:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid %s %q: must be %q or %q", OptionSaveMode, mode, SaveModeBGSave, SaveModeSave)
+	}
+}
+
+// Ping checks that Redis answers PING, which it does once the dataset is loaded
+func (r *RedisBackup) Ping(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options) error {
+	return newCLI(container, dockerClient).expect(ctx, "PONG", "PING")
+}
+
+// Query runs a Redis command given as space-separated words, e.g. "DBSIZE"
+func (r *RedisBackup) Query(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options, query string) (string, error) {
+	args := strings.Fields(query)
+	if len(args) == 0 {
+		return "", fmt.Errorf("empty redis command")
+	}
+	return newCLI(container, dockerClient).run(ctx, args...)
+}
+
+// redisCLI runs redis-cli inside the Redis container
+type redisCLI struct {
+	container    *docker.ContainerInfo
+	dockerClient *docker.Client
+	password     string
+}
+
+func newCLI(container *docker.ContainerInfo, dockerClient *docker.Client) *redisCLI {
+	return &redisCLI{
+		container:    container,
+		dockerClient: dockerClient,
+		password:     container.Env[EnvRedisPassword],
+	}
+}
+
+// run executes a command and returns its reply. Error replies are returned as errors.
+func (c *redisCLI) run(ctx context.Context, args ...string) (string, error) {
+	cmd := []string{"redis-cli"}
+	if c.password != "" {
+		cmd = append(cmd, "-a", c.password, "--no-auth-warning")
+	}
+	cmd = append(cmd, args...)
+
+	result, err := c.dockerClient.Exec(ctx, c.container.ID, cmd, nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute redis-cli: %w", err)
+	}
+
+	output := strings.TrimSpace(result.Output)
+	if result.ExitCode != 0 {
+		return "", fmt.Errorf("redis-cli %s failed with exit code %d: %s", args[0], result.ExitCode, output)
+	}
+	// Older redis-cli versions exit with 0 on error replies
+	if isErrorReply(output) {
+		return "", fmt.Errorf("redis-cli %s failed: %s", args[0], output)
+	}
+
+	return result.Output, nil
+}
+
+// expect executes a command and checks that its reply is want
+func (c *redisCLI) expect(ctx context.Context, want string, args ...string) error {
+	output, err := c.run(ctx, args...)
+	if err != nil {
+		return err
+	}
+	if reply := strings.TrimSpace(output); reply != want {
+		return fmt.Errorf("unexpected reply to %s: %s", args[0], reply)
+	}
+	return nil
+}
+
+// configGet returns the value of a config parameter
+func (c *redisCLI) configGet(ctx context.Context, parameter string) (string, error) {
+	output, err := c.run(ctx, "CONFIG", "GET", parameter)
+	if err != nil {
+		return "", err
+	}
+	return configValue(output, parameter)
+}
+
+// configValue parses the reply to CONFIG GET, one line with the parameter
+// name followed by one with its value
+func configValue(output, parameter string) (string, error) {
+	lines := strings.Split(strings.ReplaceAll(strings.TrimSpace(output), "\r\n", "\n"), "\n")
+	if len(lines) < 2 || lines[0] != parameter {
+		return "", fmt.Errorf("unknown config parameter %s", parameter)
+	}
+	return lines[1], nil
+}
+
+// infoField returns the value of a "field:value" line of an INFO reply
+func infoField(info, field string) string {
+	for _, line := range strings.Split(info, "\n") {
+		name, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if ok && name == field {
+			return value
+		}
+	}
+	return ""
+}
+
+func isErrorReply(output string) bool {
+	for _, prefix := range []string{"ERR ", "NOAUTH ", "WRONGPASS ", "NOPERM ", "BUSY ", "LOADING ", "MISCONF "} {
+		if strings.HasPrefix(output, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,201 @@
+package redis
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/shyim/docker-backup/internal/docker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+func TestRedisBackup_Name(t *testing.T) {
+	r := &RedisBackup{}
+	assert.Equal(t, "redis", r.Name())
+}
+
+func TestRedisBackup_FileExtension(t *testing.T) {
+	r := &RedisBackup{}
+	assert.Equal(t, ".rdb.zst", r.FileExtension(nil))
+}
+
+func TestRedisBackup_Validate(t *testing.T) {
+	r := &RedisBackup{}
+
+	tests := []struct {
+		name        string
+		mounts      []docker.MountInfo
+		opts        backup.Options
+		expectError bool
+	}{
+		{
+			name:   "volume at default data dir",
+			mounts: []docker.MountInfo{{Type: "volume", Name: "redis-data", Destination: "/data"}},
+		},
+		{
+			name:   "bind mount at custom data dir",
+			mounts: []docker.MountInfo{{Type: "bind", Source: "/srv/redis", Destination: "/var/lib/redis/"}},
+			opts:   backup.Options{OptionDataDir: "/var/lib/redis"},
+		},
+		{
+			name:        "no mounts",
+			expectError: true,
+		},
+		{
+			name:        "data dir not mounted",
+			mounts:      []docker.MountInfo{{Type: "volume", Name: "other", Destination: "/other"}},
+			expectError: true,
+		},
+		{
+			name:        "tmpfs data dir",
+			mounts:      []docker.MountInfo{{Type: "tmpfs", Destination: "/data"}},
+			expectError: true,
+		},
+		{
+			name:   "save mode",
+			mounts: []docker.MountInfo{{Type: "volume", Name: "redis-data", Destination: "/data"}},
+			opts:   backup.Options{OptionSaveMode: SaveModeSave},
+		},
+		{
+			name:        "invalid save mode",
+			mounts:      []docker.MountInfo{{Type: "volume", Name: "redis-data", Destination: "/data"}},
+			opts:        backup.Options{OptionSaveMode: "fork"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			container := &docker.ContainerInfo{Name: "test", Mounts: tt.mounts}
+			err := r.Validate(container, tt.opts)
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestConfigValue(t *testing.T) {
+	value, err := configValue("dir\n/data\n", "dir")
+	require.NoError(t, err)
+	assert.Equal(t, "/data", value)
+
+	value, err = configValue("appendonly\r\nno\r\n", "appendonly")
+	require.NoError(t, err)
+	assert.Equal(t, "no", value)
+
+	_, err = configValue("", "dir")
+	assert.Error(t, err)
+}
+
+func TestInfoField(t *testing.T) {
+	info := "# Persistence\r\nloading:0\r\nrdb_bgsave_in_progress:1\r\nrdb_last_bgsave_status:ok\r\n"
+
+	assert.Equal(t, "1", infoField(info, "rdb_bgsave_in_progress"))
+	assert.Equal(t, "ok", infoField(info, "rdb_last_bgsave_status"))
+	assert.Equal(t, "", infoField(info, "aof_enabled"))
+}
+
+// TestRedisBackup_Integration tests the full backup and restore cycle
+// using a real Redis container via testcontainers.
+func TestRedisBackup_Integration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+
+	volumeName := fmt.Sprintf("test-redis-%d", time.Now().UnixNano())
+
+	req := testcontainers.ContainerRequest{
+		Image: "redis:7-alpine",
+		Cmd:   []string{"redis-server", "--requirepass", "testpass"},
+		Env:   map[string]string{EnvRedisPassword: "testpass"},
+		Mounts: testcontainers.ContainerMounts{
+			testcontainers.VolumeMount(volumeName, "/data"),
+		},
+		WaitingFor: wait.ForLog("Ready to accept connections").WithStartupTimeout(30 * time.Second),
+	}
+
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	require.NoError(t, err)
+	defer func() {
+		if err := container.Terminate(ctx); err != nil {
+			t.Logf("failed to terminate container: %v", err)
+		}
+	}()
+
+	containerID := container.GetContainerID()
+
+	dockerClient, err := docker.NewClient("")
+	require.NoError(t, err)
+	defer func() {
+		_ = dockerClient.Close()
+	}()
+
+	containerInfo, err := dockerClient.GetContainer(ctx, containerID)
+	require.NoError(t, err)
+
+	redisCmd := func(args ...string) string {
+		cmd := append([]string{"redis-cli", "-a", "testpass", "--no-auth-warning"}, args...)
+		exitCode, reader, err := container.Exec(ctx, cmd)
+		require.NoError(t, err)
+		require.Equal(t, 0, exitCode)
+		output, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		return string(output)
+	}
+
+	redisCmd("SET", "greeting", "hello")
+	redisCmd("RPUSH", "queue", "a", "b", "c")
+	redisCmd("HSET", "user:1", "name", "Alice")
+
+	r := &RedisBackup{}
+	require.NoError(t, r.Validate(containerInfo, nil))
+
+	var backupBuffer bytes.Buffer
+	err = r.Backup(ctx, containerInfo, dockerClient, nil, &backupBuffer)
+	require.NoError(t, err)
+	assert.Greater(t, backupBuffer.Len(), 0, "backup should not be empty")
+
+	t.Logf("Backup size: %d bytes", backupBuffer.Len())
+
+	// Simulate data loss and persist it, so a plain restart would not bring the keys back
+	redisCmd("FLUSHALL")
+	redisCmd("SAVE")
+	assert.Contains(t, redisCmd("DBSIZE"), "0")
+
+	err = r.Restore(ctx, containerInfo, dockerClient, nil, bytes.NewReader(backupBuffer.Bytes()))
+	require.NoError(t, err)
+
+	containerInfo, err = dockerClient.GetContainer(ctx, containerID)
+	require.NoError(t, err)
+	assert.True(t, containerInfo.Running, "container should be running after restore")
+
+	// Redis loads the snapshot on startup
+	require.Eventually(t, func() bool {
+		exitCode, reader, err := container.Exec(ctx, []string{"redis-cli", "-a", "testpass", "--no-auth-warning", "PING"})
+		if err != nil || exitCode != 0 {
+			return false
+		}
+		output, _ := io.ReadAll(reader)
+		return strings.Contains(string(output), "PONG")
+	}, 30*time.Second, 500*time.Millisecond)
+
+	assert.Contains(t, redisCmd("GET", "greeting"), "hello")
+	assert.Contains(t, redisCmd("LRANGE", "queue", "0", "-1"), "c")
+	assert.Contains(t, redisCmd("HGET", "user:1", "name"), "Alice")
+}
@@ -5,7 +5,12 @@ package backuptypes
 import (
 	// Import all backup types for self-registration
 	_ "github.com/shyim/docker-backup/internal/backuptypes/clickhouse"
+	_ "github.com/shyim/docker-backup/internal/backuptypes/command"
+	_ "github.com/shyim/docker-backup/internal/backuptypes/filesystem"
+	_ "github.com/shyim/docker-backup/internal/backuptypes/logs"
 	_ "github.com/shyim/docker-backup/internal/backuptypes/mysql"
 	_ "github.com/shyim/docker-backup/internal/backuptypes/postgres"
+	_ "github.com/shyim/docker-backup/internal/backuptypes/redis"
+	_ "github.com/shyim/docker-backup/internal/backuptypes/sqlite"
 	_ "github.com/shyim/docker-backup/internal/backuptypes/volume"
 )
@@ -0,0 +1,294 @@
+package sqlite
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/shyim/docker-backup/internal/compression"
+	"github.com/shyim/docker-backup/internal/docker"
+)
+
+func init() {
+	backup.Register(&SQLiteBackup{})
+}
+
+// Options understood by the SQLite backup type
+const (
+	// OptionDBPath is the path of the database file inside the container
+	OptionDBPath = "db-path"
+)
+
+const (
+	// detectMaxDepth limits how deep below each mount databases are searched for
+	detectMaxDepth = 3
+
+	// fileHeader starts every SQLite database file
+	fileHeader = "SQLite format 3\x00"
+)
+
+// detectPatterns are the file names considered databases when db-path is not set
+var detectPatterns = []string{"*.sqlite", "*.sqlite3", "*.db"}
+
+// journalSuffixes are the files SQLite keeps next to the database. Stale ones
+// would be applied to the restored database, so they are emptied on restore.
+var journalSuffixes = []string{"-wal", "-shm", "-journal"}
+
+type SQLiteBackup struct{}
+
+func (s *SQLiteBackup) Name() string {
+	return "sqlite"
+}
+
+func (s *SQLiteBackup) FileExtension(opts backup.Options) string {
+	return opts.Extension(".db")
+}
+
+func (s *SQLiteBackup) Validate(container *docker.ContainerInfo, opts backup.Options) error {
+	if dbPath := opts.String(OptionDBPath, ""); dbPath != "" {
+		if !path.IsAbs(dbPath) {
+			return fmt.Errorf("invalid %s %q: must be an absolute path", OptionDBPath, dbPath)
+		}
+		return nil
+	}
+
+	if len(searchDirs(container)) == 0 {
+		return fmt.Errorf("container %s has no %s set and no mounts to detect a database on", container.Name, OptionDBPath)
+	}
+	return nil
+}
+
+// Backup snapshots the database with the sqlite3 ".backup" command, which
+// uses the online backup API and so is consistent even while the application
+// writes to it, and streams the snapshot out compressed
+func (s *SQLiteBackup) Backup(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options, w io.Writer) error {
+	dbPath, err := s.dbPath(ctx, container, dockerClient, opts)
+	if err != nil {
+		return err
+	}
+
+	snapshotPath := "/tmp/docker-backup-" + uuid.New().String() + ".db"
+	cmd := []string{"sqlite3", dbPath, ".backup '" + snapshotPath + "'"}
+
+	result, err := dockerClient.Exec(ctx, container.ID, cmd, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to execute sqlite3: %w", err)
+	}
+	defer func() {
+		if _, err := dockerClient.Exec(ctx, container.ID, []string{"rm", "-f", snapshotPath}, nil, nil); err != nil {
+			slog.Warn("failed to remove sqlite snapshot from container",
+				"container", container.Name,
+				"path", snapshotPath,
+				"error", err,
+			)
+		}
+	}()
+
+	if result.ExitCode != 0 {
+		return fmt.Errorf("sqlite3 backup of %s failed with exit code %d: %s", dbPath, result.ExitCode, strings.TrimSpace(result.Output))
+	}
+
+	reader, err := dockerClient.CopyFromContainer(ctx, container.ID, snapshotPath)
+	if err != nil {
+		return fmt.Errorf("failed to copy snapshot from container: %w", err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	tarReader := tar.NewReader(reader)
+	if _, err := tarReader.Next(); err != nil {
+		return fmt.Errorf("failed to read snapshot from container: %w", err)
+	}
+
+	codec, err := opts.Compression()
+	if err != nil {
+		return err
+	}
+	compressor, err := codec.NewWriter(w)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = compressor.Close()
+	}()
+
+	if _, err := io.Copy(compressor, tarReader); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	return compressor.Close()
+}
+
+// Restore replaces the database file with the one from the backup while the
+// containers using it are stopped
+func (s *SQLiteBackup) Restore(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options, r io.Reader) error {
+	dbPath, err := s.dbPath(ctx, container, dockerClient, opts)
+	if err != nil {
+		return err
+	}
+	dir, name := path.Split(dbPath)
+
+	tmpFile, err := os.CreateTemp(backup.TempDirFromContext(ctx), "sqlite-restore-*.db")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmpFile.Name())
+	}()
+	defer func() {
+		_ = tmpFile.Close()
+	}()
+
+	decompressor, err := compression.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = decompressor.Close()
+	}()
+
+	size, err := io.Copy(tmpFile, decompressor)
+	if err != nil {
+		return fmt.Errorf("failed to decompress database: %w", err)
+	}
+
+	header := make([]byte, len(fileHeader))
+	if _, err := tmpFile.ReadAt(header, 0); err != nil || string(header) != fileHeader {
+		return fmt.Errorf("backup does not contain a SQLite database")
+	}
+
+	// Keep the owner of the existing database, or of its directory for a new one
+	uid, gid, err := dockerClient.PathOwner(ctx, container.ID, dbPath)
+	if err != nil {
+		uid, gid, err = dockerClient.PathOwner(ctx, container.ID, dir)
+		if err != nil {
+			return fmt.Errorf("failed to read owner of %s: %w", dir, err)
+		}
+	}
+
+	restart, err := dockerClient.StopPathUsers(ctx, container, dbPath, 30*time.Second)
+	if err != nil {
+		return err
+	}
+	defer restart(ctx)
+
+	// Checked only now, the application may have created them until it stopped
+	var journals []string
+	for _, suffix := range journalSuffixes {
+		if _, _, err := dockerClient.PathOwner(ctx, container.ID, dbPath+suffix); err == nil {
+			journals = append(journals, name+suffix)
+		}
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		_ = pw.CloseWithError(writeRestoreArchive(pw, tmpFile, name, size, journals, uid, gid))
+	}()
+
+	if err := dockerClient.CopyToContainer(ctx, container.ID, dir, pr); err != nil {
+		_ = pr.CloseWithError(err)
+		return fmt.Errorf("failed to copy database into container: %w", err)
+	}
+
+	slog.Debug("replaced sqlite database",
+		"container", container.Name,
+		"path", dbPath,
+		"size", size,
+		"emptied", journals,
+	)
+
+	return nil
+}
+
+// writeRestoreArchive writes a tar with the database and an empty file for
+// each of its journals
+func writeRestoreArchive(w io.Writer, db io.ReaderAt, name string, size int64, journals []string, uid, gid int) error {
+	tarWriter := tar.NewWriter(w)
+	now := time.Now()
+
+	if err := tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: size, Uid: uid, Gid: gid, ModTime: now}); err != nil {
+		return err
+	}
+	if _, err := io.Copy(tarWriter, io.NewSectionReader(db, 0, size)); err != nil {
+		return err
+	}
+
+	for _, journal := range journals {
+		if err := tarWriter.WriteHeader(&tar.Header{Name: journal, Mode: 0644, Uid: uid, Gid: gid, ModTime: now}); err != nil {
+			return err
+		}
+	}
+
+	return tarWriter.Close()
+}
+
+// dbPath returns the db-path option, or the single database file found on
+// the container's mounts
+func (s *SQLiteBackup) dbPath(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options) (string, error) {
+	if dbPath := opts.String(OptionDBPath, ""); dbPath != "" {
+		return dbPath, nil
+	}
+
+	dirs := searchDirs(container)
+	if len(dirs) == 0 {
+		return "", fmt.Errorf("container %s has no %s set and no mounts to detect a database on", container.Name, OptionDBPath)
+	}
+
+	cmd := append([]string{"find"}, dirs...)
+	cmd = append(cmd, "-maxdepth", fmt.Sprint(detectMaxDepth), "-type", "f", "(")
+	for i, pattern := range detectPatterns {
+		if i > 0 {
+			cmd = append(cmd, "-o")
+		}
+		cmd = append(cmd, "-name", pattern)
+	}
+	cmd = append(cmd, ")")
+
+	var stdout bytes.Buffer
+	result, err := dockerClient.ExecWithOutput(ctx, container.ID, cmd, nil, &stdout)
+	if err != nil {
+		return "", fmt.Errorf("failed to search for a database: %w", err)
+	}
+
+	var found []string
+	scanner := bufio.NewScanner(&stdout)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			found = append(found, line)
+		}
+	}
+
+	switch {
+	case len(found) == 1:
+		slog.Debug("detected sqlite database", "container", container.Name, "path", found[0])
+		return found[0], nil
+	case len(found) > 1:
+		return "", fmt.Errorf("container %s has several database files (%s), set %s to pick one", container.Name, strings.Join(found, ", "), OptionDBPath)
+	case result.ExitCode != 0:
+		return "", fmt.Errorf("failed to search for a database, find failed with exit code %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+	default:
+		return "", fmt.Errorf("container %s has no %s set and no database file on its mounts", container.Name, OptionDBPath)
+	}
+}
+
+// searchDirs returns the destinations of the volume and bind mounts
+func searchDirs(container *docker.ContainerInfo) []string {
+	var dirs []string
+	for _, mount := range container.Mounts {
+		if mount.Type == "volume" || mount.Type == "bind" {
+			dirs = append(dirs, mount.Destination)
+		}
+	}
+	return dirs
+}
@@ -0,0 +1,184 @@
+package sqlite
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/shyim/docker-backup/internal/docker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+func TestSQLiteBackup_Name(t *testing.T) {
+	s := &SQLiteBackup{}
+	assert.Equal(t, "sqlite", s.Name())
+}
+
+func TestSQLiteBackup_FileExtension(t *testing.T) {
+	s := &SQLiteBackup{}
+	assert.Equal(t, ".db.zst", s.FileExtension(nil))
+}
+
+func TestSQLiteBackup_Validate(t *testing.T) {
+	s := &SQLiteBackup{}
+
+	tests := []struct {
+		name        string
+		mounts      []docker.MountInfo
+		opts        backup.Options
+		expectError bool
+	}{
+		{
+			name: "db-path set",
+			opts: backup.Options{OptionDBPath: "/data/app.db"},
+		},
+		{
+			name:        "relative db-path",
+			opts:        backup.Options{OptionDBPath: "data/app.db"},
+			expectError: true,
+		},
+		{
+			name:   "detect on volume",
+			mounts: []docker.MountInfo{{Type: "volume", Name: "app-data", Destination: "/data"}},
+		},
+		{
+			name:   "detect on bind mount",
+			mounts: []docker.MountInfo{{Type: "bind", Source: "/srv/app", Destination: "/data"}},
+		},
+		{
+			name:        "no db-path and no mounts",
+			expectError: true,
+		},
+		{
+			name:        "no db-path and only tmpfs",
+			mounts:      []docker.MountInfo{{Type: "tmpfs", Destination: "/tmp"}},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			container := &docker.ContainerInfo{Name: "test", Mounts: tt.mounts}
+			err := s.Validate(container, tt.opts)
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestWriteRestoreArchive(t *testing.T) {
+	db := fileHeader + "rest of the database"
+
+	var buf bytes.Buffer
+	require.NoError(t, writeRestoreArchive(&buf, strings.NewReader(db), "app.db", int64(len(db)), []string{"app.db-wal"}, 1000, 1000))
+
+	tr := tar.NewReader(&buf)
+
+	header, err := tr.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "app.db", header.Name)
+	assert.Equal(t, 1000, header.Uid)
+	data, err := io.ReadAll(tr)
+	require.NoError(t, err)
+	assert.Equal(t, db, string(data))
+
+	header, err = tr.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "app.db-wal", header.Name)
+	assert.Equal(t, int64(0), header.Size)
+
+	_, err = tr.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+// TestSQLiteBackup_Integration tests the full backup and restore cycle
+// using a container with the sqlite3 CLI via testcontainers.
+func TestSQLiteBackup_Integration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+
+	volumeName := fmt.Sprintf("test-sqlite-%d", time.Now().UnixNano())
+
+	req := testcontainers.ContainerRequest{
+		Image: "alpine:latest",
+		Cmd:   []string{"sh", "-c", "apk add --no-cache sqlite && sleep 3600"},
+		Mounts: testcontainers.ContainerMounts{
+			testcontainers.VolumeMount(volumeName, "/data"),
+		},
+		WaitingFor: wait.ForExec([]string{"which", "sqlite3"}).WithStartupTimeout(60 * time.Second),
+	}
+
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	require.NoError(t, err)
+	defer func() {
+		if err := container.Terminate(ctx); err != nil {
+			t.Logf("failed to terminate container: %v", err)
+		}
+	}()
+
+	containerID := container.GetContainerID()
+
+	dockerClient, err := docker.NewClient("")
+	require.NoError(t, err)
+	defer func() {
+		_ = dockerClient.Close()
+	}()
+
+	containerInfo, err := dockerClient.GetContainer(ctx, containerID)
+	require.NoError(t, err)
+
+	query := func(sql string) string {
+		exitCode, reader, err := container.Exec(ctx, []string{"sqlite3", "/data/app.db", sql})
+		require.NoError(t, err)
+		require.Equal(t, 0, exitCode)
+		output, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		return string(output)
+	}
+
+	query("PRAGMA journal_mode=WAL; CREATE TABLE users (name TEXT); INSERT INTO users VALUES ('Alice'), ('Bob');")
+
+	// The database is detected on the volume without db-path
+	s := &SQLiteBackup{}
+	require.NoError(t, s.Validate(containerInfo, nil))
+
+	var backupBuffer bytes.Buffer
+	err = s.Backup(ctx, containerInfo, dockerClient, nil, &backupBuffer)
+	require.NoError(t, err)
+	assert.Greater(t, backupBuffer.Len(), 0, "backup should not be empty")
+
+	t.Logf("Backup size: %d bytes", backupBuffer.Len())
+
+	// Simulate data loss
+	query("DELETE FROM users; INSERT INTO users VALUES ('Mallory');")
+
+	err = s.Restore(ctx, containerInfo, dockerClient, backup.Options{OptionDBPath: "/data/app.db"}, bytes.NewReader(backupBuffer.Bytes()))
+	require.NoError(t, err)
+
+	containerInfo, err = dockerClient.GetContainer(ctx, containerID)
+	require.NoError(t, err)
+	assert.True(t, containerInfo.Running, "container should be running after restore")
+
+	output := query("SELECT name FROM users ORDER BY name;")
+	assert.Contains(t, output, "Alice")
+	assert.Contains(t, output, "Bob")
+	assert.NotContains(t, output, "Mallory")
+}
@@ -0,0 +1,197 @@
+package volume
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/shyim/docker-backup/internal/backup"
+)
+
+// With the dedup option, regular files of at least dedupMinSize bytes are
+// stored as blobs instead of in the archive, which then serves as the manifest
+// of the backup. Every file keeps its tar entry and metadata, but the entry of
+// a deduplicated file has no content and carries two PAX records:
+//
+//	DOCKERBACKUP.blob  lowercase hex SHA-256 of the file content, naming the blob
+//	DOCKERBACKUP.size  size of the file in bytes
+//
+// Restore, Extract and ListArchive put the size back and read the content
+// from the blob store. Tools like tar extract such files as empty files.
+const (
+	dedupMinSize = 64 << 10
+	paxBlob      = "DOCKERBACKUP.blob"
+	paxSize      = "DOCKERBACKUP.size"
+)
+
+// dedupFile stores the file content read from r as a blob unless the store
+// already has it, and turns header into a reference to that blob
+func dedupFile(ctx context.Context, blobs backup.BlobStore, header *tar.Header, r io.Reader) error {
+	// The digest is only known once the file was read, so it is kept in a
+	// temporary file until it is clear whether the blob is new
+	tmp, err := os.CreateTemp(backup.TempDirFromContext(ctx), "docker-backup-dedup-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), r); err != nil {
+		return fmt.Errorf("failed to read file from volume archive: %w", err)
+	}
+	digest := hex.EncodeToString(h.Sum(nil))
+
+	if !blobs.Has(digest) {
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to rewind temporary file: %w", err)
+		}
+		if err := blobs.Put(ctx, digest, tmp); err != nil {
+			return err
+		}
+	}
+
+	if header.PAXRecords == nil {
+		header.PAXRecords = make(map[string]string)
+	}
+	header.PAXRecords[paxBlob] = digest
+	header.PAXRecords[paxSize] = strconv.FormatInt(header.Size, 10)
+	header.Size = 0
+	header.Format = tar.FormatPAX
+	return nil
+}
+
+// takeBlobRef turns a deduplicated entry back into a regular one by removing
+// its blob records and restoring its size. It returns the digest of the blob
+// holding the content, or "" for entries whose content is in the archive.
+func takeBlobRef(header *tar.Header) (string, error) {
+	digest, ok := header.PAXRecords[paxBlob]
+	if !ok {
+		return "", nil
+	}
+	if header.Typeflag != tar.TypeReg || !validDigest(digest) {
+		return "", fmt.Errorf("archive entry %q has an invalid blob reference", header.Name)
+	}
+	size, err := strconv.ParseInt(header.PAXRecords[paxSize], 10, 64)
+	if err != nil || size < 0 {
+		return "", fmt.Errorf("archive entry %q has an invalid blob size", header.Name)
+	}
+
+	delete(header.PAXRecords, paxBlob)
+	delete(header.PAXRecords, paxSize)
+	header.Size = size
+	return digest, nil
+}
+
+// openEntry returns the content of an archive entry: r itself, or the blob
+// named by digest from the blob store in ctx. Blob content is checked against
+// digest and size while it is read.
+func openEntry(ctx context.Context, r io.Reader, digest string, size int64) (io.ReadCloser, error) {
+	if digest == "" {
+		return io.NopCloser(r), nil
+	}
+
+	blobs := backup.BlobStoreFromContext(ctx)
+	if blobs == nil {
+		return nil, errors.New("archive references blobs but no blob store is available")
+	}
+	content, err := blobs.Get(ctx, digest)
+	if err != nil {
+		return nil, err
+	}
+	return &verifiedBlob{ReadCloser: content, digest: digest, size: size, hash: sha256.New()}, nil
+}
+
+// copyEntry copies the content of an archive entry to w, see openEntry
+func copyEntry(ctx context.Context, w io.Writer, r io.Reader, digest string, size int64) error {
+	content, err := openEntry(ctx, r, digest, size)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = content.Close()
+	}()
+
+	_, err = io.Copy(w, content)
+	return err
+}
+
+// verifiedBlob fails at the end of a blob whose content doesn't match its reference
+type verifiedBlob struct {
+	io.ReadCloser
+	digest string
+	size   int64
+	read   int64
+	hash   hash.Hash
+}
+
+func (b *verifiedBlob) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	b.hash.Write(p[:n])
+	if err == io.EOF {
+		if b.read != b.size {
+			return n, fmt.Errorf("blob %s has %d bytes, expected %d", b.digest, b.read, b.size)
+		}
+		if got := hex.EncodeToString(b.hash.Sum(nil)); got != b.digest {
+			return n, fmt.Errorf("blob %s is corrupt: sha256 %s", b.digest, got)
+		}
+	}
+	return n, err
+}
+
+// BlobRefs reports the blobs referenced by the deduplicated files of the archive
+func (v *VolumeBackup) BlobRefs(ctx context.Context, r io.Reader, fn func(digest string) error) error {
+	tarReader, closeArchive, err := openArchive(r)
+	if err != nil {
+		return err
+	}
+	defer closeArchive()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar header: %w", err)
+		}
+
+		digest, err := takeBlobRef(header)
+		if err != nil {
+			return err
+		}
+		if digest == "" {
+			continue
+		}
+		if err := fn(digest); err != nil {
+			return err
+		}
+	}
+}
+
+// validDigest reports whether digest is a lowercase hex SHA-256
+func validDigest(digest string) bool {
+	if len(digest) != sha256.Size*2 {
+		return false
+	}
+	for _, c := range digest {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,161 @@
+package volume
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memBlobs struct {
+	blobs map[string][]byte
+	puts  int
+}
+
+func newMemBlobs() *memBlobs {
+	return &memBlobs{blobs: make(map[string][]byte)}
+}
+
+func (m *memBlobs) Has(digest string) bool {
+	_, ok := m.blobs[digest]
+	return ok
+}
+
+func (m *memBlobs) Put(_ context.Context, digest string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	m.blobs[digest] = data
+	m.puts++
+	return nil
+}
+
+func (m *memBlobs) Get(_ context.Context, digest string) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(m.blobs[digest])), nil
+}
+
+// dedupEntry returns an archive entry referencing data stored in blobs
+func dedupEntry(t *testing.T, blobs backup.BlobStore, name, data string) extractEntry {
+	t.Helper()
+	header := &tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(data))}
+	require.NoError(t, dedupFile(context.Background(), blobs, header, strings.NewReader(data)))
+	return extractEntry{header: header}
+}
+
+func TestDedupFile(t *testing.T) {
+	blobs := newMemBlobs()
+	content := strings.Repeat("a", dedupMinSize)
+
+	first := dedupEntry(t, blobs, "data/one.bin", content)
+	second := dedupEntry(t, blobs, "data/two.bin", content)
+
+	assert.Equal(t, 1, blobs.puts)
+	assert.Equal(t, int64(0), first.header.Size)
+	assert.Equal(t, tar.FormatPAX, first.header.Format)
+	assert.Len(t, first.header.PAXRecords[paxBlob], 64)
+	assert.Equal(t, first.header.PAXRecords[paxBlob], second.header.PAXRecords[paxBlob])
+	assert.Equal(t, "65536", first.header.PAXRecords[paxSize])
+	assert.Equal(t, content, string(blobs.blobs[first.header.PAXRecords[paxBlob]]))
+}
+
+// spoolingBlobs records the name of the temp file each blob is put from
+type spoolingBlobs struct {
+	*memBlobs
+	spooled []string
+}
+
+func (s *spoolingBlobs) Put(ctx context.Context, digest string, r io.Reader) error {
+	s.spooled = append(s.spooled, r.(*os.File).Name())
+	return s.memBlobs.Put(ctx, digest, r)
+}
+
+func TestDedupFile_SpoolsToTempDir(t *testing.T) {
+	dir := t.TempDir()
+	blobs := &spoolingBlobs{memBlobs: newMemBlobs()}
+	header := &tar.Header{Name: "data/large.bin", Typeflag: tar.TypeReg, Mode: 0644, Size: dedupMinSize}
+
+	ctx := backup.WithTempDir(context.Background(), dir)
+	require.NoError(t, dedupFile(ctx, blobs, header, strings.NewReader(strings.Repeat("a", dedupMinSize))))
+
+	require.Len(t, blobs.spooled, 1)
+	assert.Equal(t, dir, filepath.Dir(blobs.spooled[0]))
+	assert.NoFileExists(t, blobs.spooled[0])
+}
+
+func TestVolumeBackup_Deduplicated(t *testing.T) {
+	blobs := newMemBlobs()
+	content := strings.Repeat("large file ", dedupMinSize)
+	archive := buildVolumeArchive(t,
+		extractEntry{header: &tar.Header{Name: "data/small.txt", Typeflag: tar.TypeReg, Mode: 0644}, data: "small"},
+		dedupEntry(t, blobs, "data/large.bin", content),
+	).Bytes()
+
+	var entries []backup.ArchiveEntry
+	err := (&VolumeBackup{}).ListArchive(context.Background(), bytes.NewReader(archive), func(e backup.ArchiveEntry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []backup.ArchiveEntry{
+		{Name: "data/small.txt", Size: 5},
+		{Name: "data/large.bin", Size: int64(len(content))},
+	}, entries)
+
+	var refs []string
+	err = (&VolumeBackup{}).BlobRefs(context.Background(), bytes.NewReader(archive), func(digest string) error {
+		refs = append(refs, digest)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, refs, 1)
+	assert.True(t, blobs.Has(refs[0]))
+
+	dest := t.TempDir()
+	ctx := backup.WithBlobStore(context.Background(), blobs)
+	require.NoError(t, (&VolumeBackup{}).Extract(ctx, bytes.NewReader(archive), dest))
+
+	data, err := os.ReadFile(filepath.Join(dest, "data", "large.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, content, string(data))
+	data, err = os.ReadFile(filepath.Join(dest, "data", "small.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "small", string(data))
+}
+
+func TestVolumeBackup_DeduplicatedWithoutBlobStore(t *testing.T) {
+	archive := buildVolumeArchive(t, dedupEntry(t, newMemBlobs(), "data/large.bin", strings.Repeat("a", dedupMinSize)))
+
+	err := (&VolumeBackup{}).Extract(context.Background(), archive, t.TempDir())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no blob store")
+}
+
+func TestVolumeBackup_DeduplicatedCorruptBlob(t *testing.T) {
+	blobs := newMemBlobs()
+	entry := dedupEntry(t, blobs, "data/large.bin", strings.Repeat("a", dedupMinSize))
+	blobs.blobs[entry.header.PAXRecords[paxBlob]] = []byte(strings.Repeat("b", dedupMinSize))
+	archive := buildVolumeArchive(t, entry)
+
+	err := (&VolumeBackup{}).Extract(backup.WithBlobStore(context.Background(), blobs), archive, t.TempDir())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is corrupt")
+}
+
+func TestTakeBlobRef_Invalid(t *testing.T) {
+	header := &tar.Header{Name: "data/x", Typeflag: tar.TypeReg, PAXRecords: map[string]string{paxBlob: "../../etc", paxSize: "1"}}
+	_, err := takeBlobRef(header)
+	assert.Error(t, err)
+
+	header = &tar.Header{Name: "data/x", Typeflag: tar.TypeReg, PAXRecords: map[string]string{paxBlob: strings.Repeat("a", 64), paxSize: "-1"}}
+	_, err = takeBlobRef(header)
+	assert.Error(t, err)
+}
@@ -0,0 +1,65 @@
+package volume
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/shyim/docker-backup/internal/pathfilter"
+)
+
+// excludeFilter parses the exclude option
+func excludeFilter(opts backup.Options) (*pathfilter.Filter, error) {
+	filter, err := pathfilter.New(nil, opts.List(OptionExclude))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", OptionExclude, err)
+	}
+	return filter, nil
+}
+
+// excluder drops the entries of one volume's archive that match the exclude
+// patterns. Docker lists a directory before its contents, so once a directory
+// is excluded, the entries below it are dropped by their prefix without
+// matching them against the patterns.
+type excluder struct {
+	filter  *pathfilter.Filter
+	skipDir string // Volume-relative path of the excluded directory being skipped
+	checks  int    // Entries matched against the patterns
+}
+
+func newExcluder(filter *pathfilter.Filter) *excluder {
+	return &excluder{filter: filter}
+}
+
+// skip reports whether the entry at the volume-relative path relPath is excluded
+func (e *excluder) skip(relPath string, isDir bool) bool {
+	if e.filter.Empty() {
+		return false
+	}
+
+	relPath = strings.TrimSuffix(relPath, "/")
+	if relPath == "" {
+		return false
+	}
+	if e.skipDir != "" && strings.HasPrefix(relPath, e.skipDir+"/") {
+		return true
+	}
+
+	e.checks++
+	if !e.filter.Excluded(relPath, isDir) {
+		return false
+	}
+	if isDir {
+		e.skipDir = relPath
+	}
+	return true
+}
+
+// excludedTarget reports whether the target of a hardlink, a volume-relative
+// regular file, is excluded
+func (e *excluder) excludedTarget(relTarget string) bool {
+	if e.filter.Empty() {
+		return false
+	}
+	return (e.skipDir != "" && strings.HasPrefix(relTarget, e.skipDir+"/")) || e.filter.Excluded(relTarget, false)
+}
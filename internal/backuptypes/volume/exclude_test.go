@@ -0,0 +1,85 @@
+package volume
+
+import (
+	"testing"
+
+	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/shyim/docker-backup/internal/docker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExcluder_NestedMatches(t *testing.T) {
+	filter, err := excludeFilter(backup.Options{OptionExclude: "*.log, cache/**, node_modules"})
+	require.NoError(t, err)
+	e := newExcluder(filter)
+
+	entries := []struct {
+		relPath string
+		isDir   bool
+		skip    bool
+	}{
+		{"", true, false},
+		{"app.php", false, false},
+		{"debug.log", false, true},
+		{"var/", true, false},
+		{"var/log/", true, false},
+		{"var/log/error.log", false, true},
+		{"var/log/error.txt", false, false},
+		{"web/", true, false},
+		{"web/node_modules/", true, true},
+		{"web/node_modules/left-pad/index.js", false, true},
+		{"web/index.js", false, false},
+		{"config/cache/", true, false}, // cache/** is anchored at the volume root
+	}
+
+	for _, entry := range entries {
+		assert.Equal(t, entry.skip, e.skip(entry.relPath, entry.isDir), entry.relPath)
+	}
+}
+
+func TestExcluder_SkipsExcludedDirectoriesWithoutMatching(t *testing.T) {
+	filter, err := excludeFilter(backup.Options{OptionExclude: "cache/"})
+	require.NoError(t, err)
+	e := newExcluder(filter)
+
+	require.True(t, e.skip("cache/", true))
+	checks := e.checks
+
+	for _, relPath := range []string{"cache/a", "cache/b/", "cache/b/c", "cache/b/d/e"} {
+		assert.True(t, e.skip(relPath, false), relPath)
+	}
+	assert.Equal(t, checks, e.checks, "entries below an excluded directory should not be matched")
+
+	assert.False(t, e.skip("cached.txt", false))
+	assert.Equal(t, checks+1, e.checks)
+}
+
+func TestExcluder_HardlinkTargets(t *testing.T) {
+	filter, err := excludeFilter(backup.Options{OptionExclude: "tmp/, *.bak"})
+	require.NoError(t, err)
+	e := newExcluder(filter)
+
+	assert.True(t, e.excludedTarget("tmp/file"))
+	assert.True(t, e.excludedTarget("data/old.bak"))
+	assert.False(t, e.excludedTarget("data/file"))
+}
+
+func TestExcluder_NoPatterns(t *testing.T) {
+	filter, err := excludeFilter(nil)
+	require.NoError(t, err)
+	e := newExcluder(filter)
+
+	assert.False(t, e.skip("anything.log", false))
+	assert.Equal(t, 0, e.checks)
+}
+
+func TestVolumeBackup_ValidateExclude(t *testing.T) {
+	container := &docker.ContainerInfo{
+		Name:   "test",
+		Mounts: []docker.MountInfo{{Type: "volume", Name: "vol", Destination: "/data"}},
+	}
+
+	assert.NoError(t, (&VolumeBackup{}).Validate(container, backup.Options{OptionExclude: "*.log,cache/**"}))
+	assert.Error(t, (&VolumeBackup{}).Validate(container, backup.Options{OptionExclude: "[a-"}))
+}
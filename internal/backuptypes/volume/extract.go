@@ -0,0 +1,242 @@
+package volume
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/shyim/docker-backup/internal/progress"
+)
+
+// extractModeMask keeps the permission bits of a tar entry, including setuid,
+// setgid and sticky
+const extractModeMask = fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+
+// Extract writes the volumes in the archive below dest, one directory per
+// volume ("<dest>/<volume>/..."), without touching any container.
+//
+// Entries go through the same symlink checks as Restore. dest is not the mount
+// path the backup was taken from, so absolute symlinks cannot be resolved and
+// are skipped like symlinks leading out of the volume. Modes and modification
+// times are kept; ownership only when running as root. Device nodes and FIFOs
+// are skipped.
+func (v *VolumeBackup) Extract(ctx context.Context, r io.Reader, dest string) error {
+	tarReader, closeArchive, err := openArchive(r)
+	if err != nil {
+		return err
+	}
+	defer closeArchive()
+
+	root, err := os.OpenRoot(dest)
+	if err != nil {
+		return fmt.Errorf("failed to open destination: %w", err)
+	}
+	defer func() {
+		_ = root.Close()
+	}()
+
+	tracker := progress.FromContext(ctx)
+	chown := os.Geteuid() == 0
+
+	guards := make(map[string]*symlinkGuard)
+	// Directory modes are applied last so read-only directories can still be filled
+	var dirs []*tar.Header
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar header: %w", err)
+		}
+
+		volumeName, relPath := splitArchivePath(header.Name)
+		if volumeName == "" || volumeName == "." || volumeName == ".." || strings.ContainsRune(volumeName, '\\') {
+			return fmt.Errorf("archive entry %q has an invalid volume name", header.Name)
+		}
+
+		guard, ok := guards[volumeName]
+		if !ok {
+			// No mount path, so every absolute symlink target counts as outside
+			guard = newSymlinkGuard("")
+			guards[volumeName] = guard
+		}
+
+		name := volumeName
+		if relPath != "" {
+			name += "/" + strings.TrimSuffix(relPath, "/")
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := guard.checkEntry(relPath); err != nil {
+				return fmt.Errorf("refusing to extract volume %s: %w", volumeName, err)
+			}
+			if err := root.MkdirAll(name, 0755); err != nil {
+				return fmt.Errorf("failed to create directory %s: %w", name, err)
+			}
+			dirs = append(dirs, header)
+			tracker.AddEntry()
+			continue
+		case tar.TypeReg:
+			if err := guard.checkEntry(relPath); err != nil {
+				return fmt.Errorf("refusing to extract volume %s: %w", volumeName, err)
+			}
+			digest, err := takeBlobRef(header)
+			if err != nil {
+				return err
+			}
+			if err := prepareEntry(root, name); err != nil {
+				return err
+			}
+			content, err := openEntry(ctx, tarReader, digest, header.Size)
+			if err != nil {
+				return fmt.Errorf("failed to extract %s: %w", name, err)
+			}
+			err = extractFile(root, name, tracker, content)
+			_ = content.Close()
+			if err != nil {
+				return fmt.Errorf("failed to extract %s: %w", name, err)
+			}
+		case tar.TypeSymlink:
+			if err := guard.addSymlink(relPath, header.Linkname); err != nil {
+				slog.Warn("skipping symlink pointing outside the volume",
+					"volume", volumeName,
+					"error", err,
+				)
+				continue
+			}
+			if err := prepareEntry(root, name); err != nil {
+				return err
+			}
+			if err := root.Symlink(header.Linkname, name); err != nil {
+				return fmt.Errorf("failed to create symlink %s: %w", name, err)
+			}
+		case tar.TypeLink:
+			// Hardlink targets are archive paths below the basename of the mount path
+			_, relTarget, inVolume := strings.Cut(header.Linkname, "/")
+			if !inVolume {
+				slog.Warn("skipping hardlink pointing outside the volume",
+					"volume", volumeName,
+					"link", relPath,
+					"target", header.Linkname,
+				)
+				continue
+			}
+			if err := guard.checkEntry(relPath); err != nil {
+				return fmt.Errorf("refusing to extract volume %s: %w", volumeName, err)
+			}
+			if err := guard.checkHardlink(relTarget); err != nil {
+				slog.Warn("skipping hardlink pointing outside the volume",
+					"volume", volumeName,
+					"error", err,
+				)
+				continue
+			}
+			if err := prepareEntry(root, name); err != nil {
+				return err
+			}
+			if err := root.Link(volumeName+"/"+relTarget, name); err != nil {
+				return fmt.Errorf("failed to create hardlink %s: %w", name, err)
+			}
+			// A hardlink shares the metadata of its target
+			tracker.AddEntry()
+			continue
+		default:
+			slog.Warn("skipping unsupported archive entry",
+				"volume", volumeName,
+				"entry", relPath,
+				"type", string(header.Typeflag),
+			)
+			continue
+		}
+
+		if err := applyMetadata(root, name, header, chown); err != nil {
+			return err
+		}
+		tracker.AddEntry()
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		volumeName, relPath := splitArchivePath(dirs[i].Name)
+		name := volumeName
+		if relPath != "" {
+			name += "/" + strings.TrimSuffix(relPath, "/")
+		}
+		if err := applyMetadata(root, name, dirs[i], chown); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// prepareEntry creates the parent directories of name and removes whatever
+// non-directory is in its place, so a file never writes through an old symlink
+func prepareEntry(root *os.Root, name string) error {
+	if err := root.MkdirAll(path.Dir(name), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", name, err)
+	}
+
+	info, err := root.Lstat(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to stat %s: %w", name, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("cannot replace directory %s", name)
+	}
+	if err := root.Remove(name); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", name, err)
+	}
+	return nil
+}
+
+// extractFile writes a regular file, reporting the bytes written to tracker
+func extractFile(root *os.Root, name string, tracker *progress.Tracker, r io.Reader) error {
+	f, err := root.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(tracker.Writer(f), r); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// applyMetadata sets the mode, modification time and, when chown is set, owner
+// recorded in header. Symlinks only get their owner.
+func applyMetadata(root *os.Root, name string, header *tar.Header, chown bool) error {
+	if chown {
+		if err := root.Lchown(name, header.Uid, header.Gid); err != nil {
+			return fmt.Errorf("failed to set owner of %s: %w", name, err)
+		}
+	}
+	if header.Typeflag == tar.TypeSymlink {
+		return nil
+	}
+
+	// Chmod after chown, which clears setuid and setgid
+	if err := root.Chmod(name, header.FileInfo().Mode()&extractModeMask); err != nil {
+		return fmt.Errorf("failed to set mode of %s: %w", name, err)
+	}
+	if err := root.Chtimes(name, header.AccessTime, header.ModTime); err != nil {
+		return fmt.Errorf("failed to set modification time of %s: %w", name, err)
+	}
+	return nil
+}
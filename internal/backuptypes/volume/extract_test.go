@@ -0,0 +1,127 @@
+package volume
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type extractEntry struct {
+	header *tar.Header
+	data   string
+}
+
+func buildVolumeArchive(t *testing.T, entries ...extractEntry) *bytes.Buffer {
+	t.Helper()
+	var archive bytes.Buffer
+	enc, err := zstd.NewWriter(&archive)
+	require.NoError(t, err)
+	tw := tar.NewWriter(enc)
+	for _, e := range entries {
+		e.header.Size = int64(len(e.data))
+		require.NoError(t, tw.WriteHeader(e.header))
+		_, err := tw.Write([]byte(e.data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, enc.Close())
+	return &archive
+}
+
+func TestVolumeBackup_Extract(t *testing.T) {
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	archive := buildVolumeArchive(t,
+		extractEntry{header: &tar.Header{Name: "data/", Typeflag: tar.TypeDir, Mode: 0750, ModTime: modTime}},
+		extractEntry{header: &tar.Header{Name: "data/app.log", Typeflag: tar.TypeReg, Mode: 0640, ModTime: modTime}, data: "hello"},
+		extractEntry{header: &tar.Header{Name: "data/sub/nested.txt", Typeflag: tar.TypeReg, Mode: 0644}, data: "nested"},
+		extractEntry{header: &tar.Header{Name: "data/current", Typeflag: tar.TypeSymlink, Linkname: "app.log"}},
+		extractEntry{header: &tar.Header{Name: "data/same.log", Typeflag: tar.TypeLink, Linkname: "app/app.log"}},
+		extractEntry{header: &tar.Header{Name: "cache/blob", Typeflag: tar.TypeReg, Mode: 0600}, data: "blob"},
+	)
+
+	dest := t.TempDir()
+	require.NoError(t, (&VolumeBackup{}).Extract(context.Background(), archive, dest))
+
+	data, err := os.ReadFile(filepath.Join(dest, "data", "app.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	info, err := os.Stat(filepath.Join(dest, "data", "app.log"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+	assert.True(t, info.ModTime().Equal(modTime))
+
+	info, err = os.Stat(filepath.Join(dest, "data"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0750), info.Mode().Perm())
+
+	data, err = os.ReadFile(filepath.Join(dest, "data", "sub", "nested.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "nested", string(data))
+
+	target, err := os.Readlink(filepath.Join(dest, "data", "current"))
+	require.NoError(t, err)
+	assert.Equal(t, "app.log", target)
+
+	data, err = os.ReadFile(filepath.Join(dest, "data", "same.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	data, err = os.ReadFile(filepath.Join(dest, "cache", "blob"))
+	require.NoError(t, err)
+	assert.Equal(t, "blob", string(data))
+}
+
+func TestVolumeBackup_ExtractSkipsEscapingLinks(t *testing.T) {
+	archive := buildVolumeArchive(t,
+		extractEntry{header: &tar.Header{Name: "data/up", Typeflag: tar.TypeSymlink, Linkname: "../.."}},
+		extractEntry{header: &tar.Header{Name: "data/abs", Typeflag: tar.TypeSymlink, Linkname: "/etc"}},
+		extractEntry{header: &tar.Header{Name: "data/passwd", Typeflag: tar.TypeLink, Linkname: "app/../../passwd"}},
+		extractEntry{header: &tar.Header{Name: "data/up/escaped.txt", Typeflag: tar.TypeReg, Mode: 0644}, data: "x"},
+	)
+
+	parent := t.TempDir()
+	dest := filepath.Join(parent, "dest")
+	require.NoError(t, os.Mkdir(dest, 0755))
+	require.NoError(t, (&VolumeBackup{}).Extract(context.Background(), archive, dest))
+
+	for _, name := range []string{"abs", "passwd"} {
+		_, err := os.Lstat(filepath.Join(dest, "data", name))
+		assert.True(t, os.IsNotExist(err), name)
+	}
+
+	// The skipped symlink is not followed; the file lands in a plain directory
+	info, err := os.Lstat(filepath.Join(dest, "data", "up"))
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+
+	data, err := os.ReadFile(filepath.Join(dest, "data", "up", "escaped.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "x", string(data))
+	_, err = os.Stat(filepath.Join(parent, "escaped.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestVolumeBackup_ExtractRejectsTraversal(t *testing.T) {
+	archive := buildVolumeArchive(t,
+		extractEntry{header: &tar.Header{Name: "data/../../escaped.txt", Typeflag: tar.TypeReg, Mode: 0644}, data: "x"},
+	)
+
+	parent := t.TempDir()
+	dest := filepath.Join(parent, "dest")
+	require.NoError(t, os.Mkdir(dest, 0755))
+
+	err := (&VolumeBackup{}).Extract(context.Background(), archive, dest)
+	require.Error(t, err)
+
+	_, err = os.Stat(filepath.Join(parent, "escaped.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,172 @@
+package volume
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// maxSymlinkDepth bounds how many symlinks are followed while resolving a
+// path, matching the limit Linux uses for path lookups (MAXSYMLINKS).
+const maxSymlinkDepth = 40
+
+var (
+	errEscapesVolume = errors.New("path escapes the volume")
+	errSymlinkDepth  = errors.New("too many levels of symbolic links")
+)
+
+// symlinkGuard keeps a restore inside the volume it targets.
+//
+// Backups record symlinks verbatim. On restore every archive entry is checked
+// against the symlinks restored so far: an entry is only written if its parent
+// directory resolves inside the volume, and a symlink is only created if its
+// final target does too. Absolute targets count as inside when they point below
+// the volume's mount path. Symlinks that already exist in the volume but are
+// not part of the archive are not known to the guard.
+type symlinkGuard struct {
+	mountPath string            // Mount destination of the volume inside the container
+	links     map[string]string // Volume-relative symlink path -> link target
+}
+
+func newSymlinkGuard(mountPath string) *symlinkGuard {
+	return &symlinkGuard{
+		mountPath: path.Clean(mountPath),
+		links:     make(map[string]string),
+	}
+}
+
+// checkEntry validates a regular entry (file, directory, ...) at the
+// volume-relative path relPath. Writing it must not go through a symlink that
+// leads out of the volume.
+func (g *symlinkGuard) checkEntry(relPath string) error {
+	if _, err := g.entryPath(relPath); err != nil {
+		return fmt.Errorf("entry %q: %w", relPath, err)
+	}
+	return nil
+}
+
+// addSymlink validates a symlink entry at relPath pointing to target and
+// remembers it for later entries. Symlinks whose target resolves outside the
+// volume, or that loop, are rejected and not remembered.
+func (g *symlinkGuard) addSymlink(relPath, target string) error {
+	key, err := g.entryPath(relPath)
+	if err != nil {
+		return fmt.Errorf("symlink %q: %w", relPath, err)
+	}
+
+	g.links[key] = target
+	if _, err := g.resolve(key, 0); err != nil {
+		delete(g.links, key)
+		return fmt.Errorf("symlink %q -> %q: %w", relPath, target, err)
+	}
+
+	return nil
+}
+
+// checkHardlink validates a hardlink target given as a volume-relative path
+func (g *symlinkGuard) checkHardlink(relTarget string) error {
+	if _, err := g.resolve(relTarget, 0); err != nil {
+		return fmt.Errorf("hardlink target %q: %w", relTarget, err)
+	}
+	return nil
+}
+
+// entryPath returns the volume-relative location an entry is written to:
+// its resolved parent directory joined with its own name
+func (g *symlinkGuard) entryPath(relPath string) (string, error) {
+	relPath = strings.TrimSuffix(relPath, "/")
+
+	parent, err := g.resolve(parentDir(relPath), 0)
+	if err != nil {
+		return "", err
+	}
+
+	name := relPath[strings.LastIndexByte(relPath, '/')+1:]
+	switch name {
+	case "..":
+		return "", errEscapesVolume
+	case "", ".":
+		return parent, nil
+	}
+
+	if parent == "" {
+		return name, nil
+	}
+	return parent + "/" + name, nil
+}
+
+// resolve walks the volume-relative path p one component at a time, following
+// known symlinks, and returns the resolved volume-relative path
+func (g *symlinkGuard) resolve(p string, depth int) (string, error) {
+	if depth > maxSymlinkDepth {
+		return "", errSymlinkDepth
+	}
+
+	parts := strings.Split(p, "/")
+	resolved := make([]string, 0, len(parts))
+
+	for i, part := range parts {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if len(resolved) == 0 {
+				return "", errEscapesVolume
+			}
+			resolved = resolved[:len(resolved)-1]
+			continue
+		}
+
+		resolved = append(resolved, part)
+		target, ok := g.links[strings.Join(resolved, "/")]
+		if !ok {
+			continue
+		}
+
+		// Continue from the link target, keeping the unresolved remainder as-is
+		// so ".." components apply to the target rather than the link
+		var next string
+		if path.IsAbs(target) {
+			rel, ok := g.relativeToMount(target)
+			if !ok {
+				return "", errEscapesVolume
+			}
+			next = rel
+		} else {
+			next = strings.Join(resolved[:len(resolved)-1], "/") + "/" + target
+		}
+		if rest := parts[i+1:]; len(rest) > 0 {
+			next += "/" + strings.Join(rest, "/")
+		}
+
+		return g.resolve(next, depth+1)
+	}
+
+	return strings.Join(resolved, "/"), nil
+}
+
+// relativeToMount converts an absolute path inside the container to a
+// volume-relative one, reporting false if it lies outside the mount
+func (g *symlinkGuard) relativeToMount(target string) (string, bool) {
+	target = path.Clean(target)
+	if target == g.mountPath {
+		return "", true
+	}
+	prefix := g.mountPath + "/"
+	if g.mountPath == "/" {
+		prefix = "/"
+	}
+	if !strings.HasPrefix(target, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(target, prefix), true
+}
+
+func parentDir(relPath string) string {
+	idx := strings.LastIndexByte(relPath, '/')
+	if idx < 0 {
+		return ""
+	}
+	return relPath[:idx]
+}
@@ -0,0 +1,98 @@
+package volume
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSymlinkGuard_AllowsLinksInsideVolume(t *testing.T) {
+	g := newSymlinkGuard("/data")
+
+	require.NoError(t, g.addSymlink("link.txt", "original.txt"))
+	require.NoError(t, g.addSymlink("sub/up", "../original.txt"))
+	require.NoError(t, g.addSymlink("abs", "/data/sub/file"))
+	require.NoError(t, g.addSymlink("root", "."))
+	require.NoError(t, g.addSymlink("dirlink", "sub"))
+
+	// Writing below a symlinked directory that stays in the volume is fine
+	assert.NoError(t, g.checkEntry("dirlink/file.txt"))
+}
+
+func TestSymlinkGuard_RejectsEscapingTargets(t *testing.T) {
+	tests := map[string]struct {
+		link   string
+		target string
+	}{
+		"absolute outside mount": {"passwd", "/etc/passwd"},
+		"relative escape":        {"up", "../secret"},
+		"nested relative escape": {"a/b/up", "../../../secret"},
+		"mount path prefix only": {"sibling", "/database/file"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			g := newSymlinkGuard("/data")
+			err := g.addSymlink(tt.link, tt.target)
+			require.Error(t, err)
+			assert.ErrorIs(t, err, errEscapesVolume)
+		})
+	}
+}
+
+func TestSymlinkGuard_RejectsEscapeThroughEarlierLink(t *testing.T) {
+	g := newSymlinkGuard("/data")
+
+	// "root" points at the volume root, so "root/.." is the mount's parent directory
+	require.NoError(t, g.addSymlink("root", "."))
+	assert.ErrorIs(t, g.addSymlink("parent", "root/.."), errEscapesVolume)
+}
+
+func TestSymlinkGuard_RejectsEntriesThroughEscapingLink(t *testing.T) {
+	g := newSymlinkGuard("/data")
+
+	// Bypass addSymlink to simulate a link that it would have rejected
+	g.links["etc"] = "/etc"
+
+	err := g.checkEntry("etc/passwd")
+	assert.ErrorIs(t, err, errEscapesVolume)
+}
+
+func TestSymlinkGuard_RejectsEscapingEntryNames(t *testing.T) {
+	g := newSymlinkGuard("/data")
+
+	assert.ErrorIs(t, g.checkEntry("../outside.txt"), errEscapesVolume)
+	assert.NoError(t, g.checkEntry("a/../inside.txt"))
+	assert.ErrorIs(t, g.checkEntry("a/../.."), errEscapesVolume)
+}
+
+func TestSymlinkGuard_ReplacingLinkDoesNotFollowIt(t *testing.T) {
+	g := newSymlinkGuard("/data")
+
+	require.NoError(t, g.addSymlink("current", "releases/1"))
+	require.NoError(t, g.addSymlink("current", "releases/2"))
+	assert.Equal(t, "releases/2", g.links["current"])
+}
+
+func TestSymlinkGuard_Hardlinks(t *testing.T) {
+	g := newSymlinkGuard("/data")
+	require.NoError(t, g.addSymlink("root", "."))
+
+	assert.NoError(t, g.checkHardlink("sub/original.txt"))
+	assert.ErrorIs(t, g.checkHardlink("root/../etc/passwd"), errEscapesVolume)
+}
+
+func TestSymlinkGuard_RejectsLoops(t *testing.T) {
+	g := newSymlinkGuard("/data")
+
+	require.NoError(t, g.addSymlink("a", "b"))
+	assert.ErrorIs(t, g.addSymlink("b", "a"), errSymlinkDepth)
+	assert.ErrorIs(t, g.addSymlink("self", "self"), errSymlinkDepth)
+}
+
+func TestSymlinkGuard_RootMount(t *testing.T) {
+	g := newSymlinkGuard("/")
+
+	assert.NoError(t, g.addSymlink("link", "/anything"))
+}
@@ -10,104 +10,221 @@ import (
 	"strings"
 	"time"
 
-	"github.com/klauspost/compress/zstd"
 	"github.com/shyim/docker-backup/internal/backup"
+	"github.com/shyim/docker-backup/internal/compression"
 	"github.com/shyim/docker-backup/internal/docker"
+	"github.com/shyim/docker-backup/internal/pathfilter"
+	"github.com/shyim/docker-backup/internal/progress"
 )
 
 func init() {
 	backup.Register(&VolumeBackup{})
 }
 
+// Options understood by the volume backup type
+const (
+	// OptionRestoreMode selects how a restore treats existing volume contents
+	OptionRestoreMode = backup.OptionRestoreMode
+	// OptionHelperImage is the image of the helper container used to clear volumes
+	OptionHelperImage = "helper-image"
+	// OptionVolumes limits backup and restore to the listed volumes, by name or mount destination
+	OptionVolumes = "volumes"
+	// OptionExclude lists glob patterns of paths inside the volumes to leave out of the backup
+	OptionExclude = "exclude"
+	// OptionStopContainer stops the containers using the volumes during the backup, false copies them live
+	OptionStopContainer = "stop-container"
+)
+
+// Restore modes
+const (
+	// RestoreModeMerge extracts the archive over the existing contents, keeping files that aren't in it
+	RestoreModeMerge = "merge"
+	// RestoreModeClear deletes the volume contents before extracting the archive
+	RestoreModeClear = "clear"
+)
+
 type VolumeBackup struct{}
 
 func (v *VolumeBackup) Name() string {
 	return "volume"
 }
 
-func (v *VolumeBackup) FileExtension() string {
-	return ".tar.zst"
+func (v *VolumeBackup) FileExtension(opts backup.Options) string {
+	return opts.Extension(".tar")
 }
 
-func (v *VolumeBackup) Validate(container *docker.ContainerInfo) error {
-	// Volume backups work with any container that has mounted volumes
-	if len(container.Mounts) == 0 {
-		return fmt.Errorf("container %s has no mounted volumes", container.Name)
+// TreeArchive reports whether the archive is a plain zstd-compressed tar, which
+// deduplicated archives are not as they leave out the content of large files
+func (v *VolumeBackup) TreeArchive(opts backup.Options) bool {
+	codec, err := opts.Compression()
+	if err != nil || codec != compression.Zstd {
+		return false
 	}
-	return nil
+	dedup, err := opts.Bool(backup.OptionDedup, false)
+	return err == nil && !dedup
 }
 
-func (v *VolumeBackup) Backup(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, w io.Writer) error {
+func (v *VolumeBackup) Validate(container *docker.ContainerInfo, opts backup.Options) error {
+	// Volume backups work with any container that has mounted volumes
 	if len(container.Mounts) == 0 {
 		return fmt.Errorf("container %s has no mounted volumes", container.Name)
 	}
+	if _, err := restoreMode(opts); err != nil {
+		return err
+	}
+	if _, err := opts.Compression(); err != nil {
+		return err
+	}
+	if _, err := selectVolumes(container, opts); err != nil {
+		return err
+	}
+	if _, err := excludeFilter(opts); err != nil {
+		return err
+	}
+	if _, err := opts.Bool(OptionStopContainer, true); err != nil {
+		return err
+	}
+	return nil
+}
 
-	var volumeNames []string
+// selectVolumes returns the named volume mounts to back up or restore: all of
+// them, or those listed in the volumes option by name or mount destination
+func selectVolumes(container *docker.ContainerInfo, opts backup.Options) ([]docker.MountInfo, error) {
+	var volumes []docker.MountInfo
 	for _, mount := range container.Mounts {
 		if mount.Type == "volume" {
-			volumeNames = append(volumeNames, mount.Name)
+			volumes = append(volumes, mount)
 		}
 	}
 
-	stoppedContainers := make(map[string]bool)
-	for _, volumeName := range volumeNames {
-		containers, err := dockerClient.GetContainersUsingVolume(ctx, volumeName)
-		if err != nil {
-			slog.Warn("failed to get containers using volume",
-				"volume", volumeName,
-				"error", err,
-			)
+	selectors := opts.List(OptionVolumes)
+	if len(selectors) == 0 {
+		return volumes, nil
+	}
+
+	selected := make(map[string]bool)
+	for _, sel := range selectors {
+		found := false
+		for _, mount := range volumes {
+			if mount.SourceName() == sel || path.Clean(mount.Destination) == path.Clean(sel) {
+				selected[mount.Name] = true
+				found = true
+			}
+		}
+		if found {
 			continue
 		}
 
-		for _, c := range containers {
-			if _, alreadyProcessed := stoppedContainers[c.ID]; alreadyProcessed {
-				continue
+		for _, mount := range container.Mounts {
+			if path.Clean(mount.Destination) == path.Clean(sel) {
+				return nil, fmt.Errorf("invalid %s: %s on container %s is a %s mount, not a named volume", OptionVolumes, sel, container.Name, mount.Type)
 			}
+		}
+		return nil, fmt.Errorf("invalid %s: no volume named or mounted at %q on container %s", OptionVolumes, sel, container.Name)
+	}
 
-			if c.Running {
-				slog.Debug("stopping container for volume backup",
-					"container", c.Name,
-					"volume", volumeName,
-				)
-				if err := dockerClient.StopContainer(ctx, c.ID, 30*time.Second); err != nil {
-					v.restartContainers(ctx, dockerClient, stoppedContainers)
-					return fmt.Errorf("failed to stop container %s: %w", c.Name, err)
-				}
-				stoppedContainers[c.ID] = true
-			} else {
-				stoppedContainers[c.ID] = false
-			}
+	// Keep the mount order so archives stay grouped per volume
+	var filtered []docker.MountInfo
+	for _, mount := range volumes {
+		if selected[mount.Name] {
+			filtered = append(filtered, mount)
+		}
+	}
+	return filtered, nil
+}
+
+func restoreMode(opts backup.Options) (string, error) {
+	mode := opts.String(OptionRestoreMode, RestoreModeMerge)
+	switch mode {
+	case RestoreModeMerge, RestoreModeClear:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid %s %q: must be %q or %q", OptionRestoreMode, mode, RestoreModeMerge, RestoreModeClear)
+	}
+}
+
+func (v *VolumeBackup) Backup(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options, w io.Writer) error {
+	if len(container.Mounts) == 0 {
+		return fmt.Errorf("container %s has no mounted volumes", container.Name)
+	}
+
+	codec, err := opts.Compression()
+	if err != nil {
+		return err
+	}
+
+	exclude, err := excludeFilter(opts)
+	if err != nil {
+		return err
+	}
+
+	mounts, err := selectVolumes(container, opts)
+	if err != nil {
+		return err
+	}
+
+	stop, err := opts.Bool(OptionStopContainer, true)
+	if err != nil {
+		return err
+	}
+
+	for _, mount := range container.Mounts {
+		if mount.Type == "bind" {
+			slog.Warn("volume backup skips bind mounts, use the filesystem backup type for them",
+				"container", container.Name,
+				"path", mount.Destination,
+			)
 		}
 	}
+	if len(mounts) == 0 {
+		return fmt.Errorf("container %s has no named volumes to back up", container.Name)
+	}
 
-	defer v.restartContainers(ctx, dockerClient, stoppedContainers)
+	var volumeNames []string
+	for _, mount := range mounts {
+		volumeNames = append(volumeNames, mount.Name)
+	}
 
-	zstdWriter, err := zstd.NewWriter(w)
+	if stop {
+		restart, err := dockerClient.StopVolumeUsers(ctx, volumeNames, 30*time.Second)
+		if err != nil {
+			return err
+		}
+		defer restart(ctx)
+	} else {
+		slog.Debug("backing up volumes without stopping their containers", "container", container.Name, "volumes", volumeNames)
+	}
+
+	// The manager passes a blob store when the dedup option is set
+	blobs := backup.BlobStoreFromContext(ctx)
+
+	compressor, err := codec.NewWriter(w)
 	if err != nil {
-		return fmt.Errorf("failed to create zstd writer: %w", err)
+		return err
 	}
 	defer func() {
-		_ = zstdWriter.Close()
+		_ = compressor.Close()
 	}()
 
-	tarWriter := tar.NewWriter(zstdWriter)
+	tarWriter := tar.NewWriter(compressor)
 	defer func() {
 		_ = tarWriter.Close()
 	}()
 
-	for _, mount := range container.Mounts {
-		if mount.Type != "volume" {
-			continue
-		}
+	return v.writeVolumes(ctx, container, dockerClient, mounts, exclude, blobs, tarWriter)
+}
 
+// writeVolumes adds the given volume mounts of the container to the archive,
+// leaving out excluded paths and storing large files in blobs when it is not nil
+func (v *VolumeBackup) writeVolumes(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, mounts []docker.MountInfo, exclude *pathfilter.Filter, blobs backup.BlobStore, tarWriter *tar.Writer) error {
+	for _, mount := range mounts {
 		slog.Debug("backing up volume",
 			"container", container.Name,
 			"volume", mount.Name,
 			"path", mount.Destination,
 		)
 
-		if err := v.addVolumeToTar(ctx, dockerClient, tarWriter, container.ID, mount.Name, mount.Destination); err != nil {
+		if err := v.addVolumeToTar(ctx, dockerClient, tarWriter, container.ID, mount.Name, mount.Destination, newExcluder(exclude), blobs); err != nil {
 			return fmt.Errorf("failed to backup volume %s: %w", mount.Name, err)
 		}
 	}
@@ -115,7 +232,7 @@ func (v *VolumeBackup) Backup(ctx context.Context, container *docker.ContainerIn
 	return nil
 }
 
-func (v *VolumeBackup) addVolumeToTar(ctx context.Context, dockerClient *docker.Client, tarWriter *tar.Writer, containerID, volumeName, mountPath string) error {
+func (v *VolumeBackup) addVolumeToTar(ctx context.Context, dockerClient *docker.Client, tarWriter *tar.Writer, containerID, volumeName, mountPath string, excluded *excluder, blobs backup.BlobStore) error {
 	reader, err := dockerClient.CopyFromContainer(ctx, containerID, mountPath)
 	if err != nil {
 		return fmt.Errorf("failed to copy volume from container: %w", err)
@@ -127,6 +244,7 @@ func (v *VolumeBackup) addVolumeToTar(ctx context.Context, dockerClient *docker.
 	// Docker prefixes archive entries with the basename of the copied path; strip
 	// it and re-root everything under the volume name for the restore to map back.
 	srcPrefix := path.Base(mountPath)
+	tracker := progress.FromContext(ctx)
 
 	tarReader := tar.NewReader(reader)
 	for {
@@ -147,6 +265,20 @@ func (v *VolumeBackup) addVolumeToTar(ctx context.Context, dockerClient *docker.
 		relPath := strings.TrimPrefix(header.Name, srcPrefix)
 		relPath = strings.TrimPrefix(relPath, "/")
 
+		if excluded.skip(relPath, header.Typeflag == tar.TypeDir) {
+			continue
+		}
+		if header.Typeflag == tar.TypeLink {
+			if relTarget, ok := strings.CutPrefix(header.Linkname, srcPrefix+"/"); ok && excluded.excludedTarget(relTarget) {
+				slog.Warn("skipping hardlink to an excluded file",
+					"volume", volumeName,
+					"link", relPath,
+					"target", relTarget,
+				)
+				continue
+			}
+		}
+
 		newName := volumeName
 		if relPath != "" {
 			newName = volumeName + "/" + relPath
@@ -156,11 +288,21 @@ func (v *VolumeBackup) addVolumeToTar(ctx context.Context, dockerClient *docker.
 		}
 		header.Name = newName
 
+		// A deduplicated file's entry is written without its content
+		deduped := false
+		if blobs != nil && header.Typeflag == tar.TypeReg && header.Size >= dedupMinSize {
+			if err := dedupFile(ctx, blobs, header, tarReader); err != nil {
+				return fmt.Errorf("failed to deduplicate %s: %w", newName, err)
+			}
+			deduped = true
+		}
+
 		if err := tarWriter.WriteHeader(header); err != nil {
 			return fmt.Errorf("failed to write tar header: %w", err)
 		}
+		tracker.AddEntry()
 
-		if header.Typeflag == tar.TypeReg {
+		if header.Typeflag == tar.TypeReg && !deduped {
 			if _, err := io.Copy(tarWriter, tarReader); err != nil {
 				return fmt.Errorf("failed to write file to tar: %w", err)
 			}
@@ -170,70 +312,70 @@ func (v *VolumeBackup) addVolumeToTar(ctx context.Context, dockerClient *docker.
 	return nil
 }
 
-func (v *VolumeBackup) Restore(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, r io.Reader) error {
+func (v *VolumeBackup) Restore(ctx context.Context, container *docker.ContainerInfo, dockerClient *docker.Client, opts backup.Options, r io.Reader) error {
 	if len(container.Mounts) == 0 {
 		return fmt.Errorf("container %s has no mounted volumes", container.Name)
 	}
 
+	mounts, err := selectVolumes(container, opts)
+	if err != nil {
+		return err
+	}
+
+	// Archive entries are grouped by the name of the volume they were backed up
+	// from, which differs from the mounted volume in a restore test sandbox
 	volumeDests := make(map[string]string)
+	mounted := make(map[string]string)
 	var volumeNames []string
-	for _, mount := range container.Mounts {
-		if mount.Type == "volume" {
-			volumeDests[mount.Name] = mount.Destination
-			volumeNames = append(volumeNames, mount.Name)
-		}
+	for _, mount := range mounts {
+		volumeDests[mount.SourceName()] = mount.Destination
+		mounted[mount.SourceName()] = mount.Name
+		volumeNames = append(volumeNames, mount.Name)
 	}
 
 	if len(volumeDests) == 0 {
 		return fmt.Errorf("container %s has no named volumes to restore", container.Name)
 	}
 
-	stoppedContainers := make(map[string]bool)
-	for _, volumeName := range volumeNames {
-		containers, err := dockerClient.GetContainersUsingVolume(ctx, volumeName)
-		if err != nil {
-			slog.Warn("failed to get containers using volume",
-				"volume", volumeName,
-				"error", err,
-			)
-			continue
-		}
-
-		for _, c := range containers {
-			if _, alreadyProcessed := stoppedContainers[c.ID]; alreadyProcessed {
-				continue
-			}
-
-			if c.Running {
-				slog.Debug("stopping container for volume restore",
-					"container", c.Name,
-					"volume", volumeName,
-				)
-				if err := dockerClient.StopContainer(ctx, c.ID, 30*time.Second); err != nil {
-					v.restartContainers(ctx, dockerClient, stoppedContainers)
-					return fmt.Errorf("failed to stop container %s: %w", c.Name, err)
-				}
-				stoppedContainers[c.ID] = true
-			} else {
-				stoppedContainers[c.ID] = false
-			}
-		}
+	mode, err := restoreMode(opts)
+	if err != nil {
+		return err
+	}
+	if mode == RestoreModeClear {
+		slog.Warn("restoring in clear mode, existing contents of restored volumes will be deleted",
+			"container", container.Name,
+			"volumes", volumeNames,
+		)
+	} else {
+		slog.Info("restoring in merge mode, files not contained in the backup are kept",
+			"container", container.Name,
+			"volumes", volumeNames,
+		)
 	}
 
-	defer v.restartContainers(ctx, dockerClient, stoppedContainers)
+	restart, err := dockerClient.StopVolumeUsers(ctx, volumeNames, 30*time.Second)
+	if err != nil {
+		return err
+	}
+	defer restart(ctx)
 
-	zstdReader, err := zstd.NewReader(r)
+	tarReader, closeArchive, err := openArchive(r)
 	if err != nil {
-		return fmt.Errorf("failed to create zstd reader: %w", err)
+		return err
 	}
-	defer zstdReader.Close()
+	defer closeArchive()
 
-	tarReader := tar.NewReader(zstdReader)
+	// The manager counts the bytes read of the archive, restores only count entries
+	tracker := progress.FromContext(ctx)
 
 	// Entries are grouped per volume, so stream each volume into the container
 	// through CopyToContainer, switching streams when the volume name changes.
 	var current *volumeRestoreStream
 
+	// Track restored symlinks per volume so no entry is written outside of it
+	guards := make(map[string]*symlinkGuard)
+	cleared := make(map[string]bool)
+
 	finishCurrent := func() error {
 		if current == nil {
 			return nil
@@ -260,21 +402,75 @@ func (v *VolumeBackup) Restore(ctx context.Context, container *docker.ContainerI
 			return fmt.Errorf("failed to read tar header: %w", err)
 		}
 
-		volumeName, relPath := splitVolumePath(header.Name)
+		volumeName, relPath := splitArchivePath(header.Name)
 
 		dest, ok := volumeDests[volumeName]
 		if !ok {
-			slog.Warn("backup contains unknown volume, skipping",
+			slog.Warn("backup contains a volume that is not mounted or not selected, skipping",
 				"volume", volumeName,
 				"container", container.Name,
 			)
 			continue
 		}
 
+		guard, ok := guards[volumeName]
+		if !ok {
+			guard = newSymlinkGuard(dest)
+			guards[volumeName] = guard
+		}
+
+		switch header.Typeflag {
+		case tar.TypeSymlink:
+			if err := guard.addSymlink(relPath, header.Linkname); err != nil {
+				slog.Warn("skipping symlink pointing outside the volume",
+					"volume", volumeName,
+					"container", container.Name,
+					"error", err,
+				)
+				continue
+			}
+		case tar.TypeLink:
+			// Hardlink targets are archive paths relative to the extraction directory
+			relTarget, inVolume := strings.CutPrefix(header.Linkname, path.Base(dest)+"/")
+			if !inVolume {
+				slog.Warn("skipping hardlink pointing outside the volume",
+					"volume", volumeName,
+					"container", container.Name,
+					"link", relPath,
+					"target", header.Linkname,
+				)
+				continue
+			}
+			if err := guard.checkEntry(relPath); err != nil {
+				_ = finishCurrent()
+				return fmt.Errorf("refusing to restore volume %s: %w", volumeName, err)
+			}
+			if err := guard.checkHardlink(relTarget); err != nil {
+				slog.Warn("skipping hardlink pointing outside the volume",
+					"volume", volumeName,
+					"container", container.Name,
+					"error", err,
+				)
+				continue
+			}
+		default:
+			if err := guard.checkEntry(relPath); err != nil {
+				_ = finishCurrent()
+				return fmt.Errorf("refusing to restore volume %s: %w", volumeName, err)
+			}
+		}
+
 		if current == nil || current.volumeName != volumeName {
 			if err := finishCurrent(); err != nil {
 				return fmt.Errorf("failed to restore volume: %w", err)
 			}
+			// Clear lazily so volumes missing from the archive are left untouched
+			if mode == RestoreModeClear && !cleared[volumeName] {
+				if err := clearVolume(ctx, dockerClient, mounted[volumeName], opts.String(OptionHelperImage, docker.DefaultHelperImage)); err != nil {
+					return fmt.Errorf("failed to clear volume %s: %w", volumeName, err)
+				}
+				cleared[volumeName] = true
+			}
 			current, err = newVolumeRestoreStream(ctx, dockerClient, container.ID, volumeName, dest)
 			if err != nil {
 				return fmt.Errorf("failed to start restore for volume %s: %w", volumeName, err)
@@ -290,13 +486,20 @@ func (v *VolumeBackup) Restore(ctx context.Context, container *docker.ContainerI
 		}
 		header.Name = newName
 
+		digest, err := takeBlobRef(header)
+		if err != nil {
+			_ = finishCurrent()
+			return err
+		}
+
 		if err := current.writer.WriteHeader(header); err != nil {
 			_ = finishCurrent()
 			return fmt.Errorf("failed to write tar header: %w", err)
 		}
+		tracker.AddEntry()
 
 		if header.Typeflag == tar.TypeReg {
-			if _, err := io.Copy(current.writer, tarReader); err != nil {
+			if err := copyEntry(ctx, current.writer, tarReader, digest, header.Size); err != nil {
 				_ = finishCurrent()
 				return fmt.Errorf("failed to write file: %w", err)
 			}
@@ -351,24 +554,80 @@ func (s *volumeRestoreStream) close() error {
 	return <-s.done
 }
 
-func splitVolumePath(name string) (volumeName, relPath string) {
-	idx := strings.IndexByte(name, '/')
-	if idx < 0 {
-		return name, ""
+// clearVolume deletes everything inside a volume using a helper container,
+// since the containers using it are stopped during the restore
+func clearVolume(ctx context.Context, dockerClient *docker.Client, volumeName, helperImage string) error {
+	slog.Info("clearing volume before restore", "volume", volumeName, "helper_image", helperImage)
+
+	result, err := dockerClient.RunHelper(ctx, docker.HelperContainer{
+		Image:   helperImage,
+		Cmd:     []string{"find", "/volume", "-mindepth", "1", "-maxdepth", "1", "-exec", "rm", "-rf", "{}", "+"},
+		Volumes: map[string]string{volumeName: "/volume"},
+	})
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("helper exited with code %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
 	}
-	return name[:idx], strings.TrimPrefix(name[idx+1:], "/")
+
+	return nil
 }
 
-func (v *VolumeBackup) restartContainers(ctx context.Context, dockerClient *docker.Client, stoppedContainers map[string]bool) {
-	for containerID, wasRunning := range stoppedContainers {
-		if wasRunning {
-			if err := dockerClient.StartContainer(ctx, containerID); err != nil {
-				slog.Warn("failed to restart container after backup/restore",
-					"container", containerID,
-					"error", err,
-				)
-			}
+// ListArchive reports the files in the archive from their tar headers, without
+// extracting them or reading blobs. Directories are left out; symlinks are listed with size 0.
+func (v *VolumeBackup) ListArchive(ctx context.Context, r io.Reader, fn func(backup.ArchiveEntry) error) error {
+	tarReader, closeArchive, err := openArchive(r)
+	if err != nil {
+		return err
+	}
+	defer closeArchive()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar header: %w", err)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeReg, tar.TypeSymlink, tar.TypeLink:
+		default:
+			continue
+		}
+		// Deduplicated files are listed with their real size
+		if _, err := takeBlobRef(header); err != nil {
+			return err
+		}
+
+		if err := fn(backup.ArchiveEntry{Name: header.Name, Size: header.Size}); err != nil {
+			return err
 		}
 	}
 }
 
+// openArchive returns a tar reader over a volume archive, decompressed with
+// the codec detected from its first bytes
+func openArchive(r io.Reader) (*tar.Reader, func(), error) {
+	decompressor, err := compression.NewReader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tar.NewReader(decompressor), func() { _ = decompressor.Close() }, nil
+}
+
+// splitArchivePath splits an archive entry name into the volume it belongs to
+// and the path inside that volume. Tar entry names always use "/" whatever
+// system wrote the archive, so a backslash is part of a file name and never a
+// separator. A leading "./" or "/" as written by some tar tools is ignored.
+func splitArchivePath(name string) (volumeName, relPath string) {
+	name = strings.TrimLeft(strings.TrimPrefix(name, "./"), "/")
+	volumeName, relPath, _ = strings.Cut(name, "/")
+	return volumeName, strings.TrimLeft(relPath, "/")
+}
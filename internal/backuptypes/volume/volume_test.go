@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/klauspost/compress/zstd"
+	"github.com/shyim/docker-backup/internal/backup"
 	"github.com/shyim/docker-backup/internal/docker"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -25,7 +26,16 @@ func TestVolumeBackup_Name(t *testing.T) {
 
 func TestVolumeBackup_FileExtension(t *testing.T) {
 	v := &VolumeBackup{}
-	assert.Equal(t, ".tar.zst", v.FileExtension())
+	assert.Equal(t, ".tar.zst", v.FileExtension(nil))
+	assert.Equal(t, ".tar.gz", v.FileExtension(backup.Options{backup.OptionCompression: "gzip"}))
+	assert.Equal(t, ".tar", v.FileExtension(backup.Options{backup.OptionCompression: "none"}))
+}
+
+func TestVolumeBackup_TreeArchive(t *testing.T) {
+	v := &VolumeBackup{}
+	assert.True(t, v.TreeArchive(nil))
+	assert.False(t, v.TreeArchive(backup.Options{backup.OptionCompression: "gzip"}))
+	assert.False(t, v.TreeArchive(backup.Options{backup.OptionDedup: "true"}))
 }
 
 func TestVolumeBackup_Validate(t *testing.T) {
@@ -91,7 +101,7 @@ func TestVolumeBackup_Validate(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := v.Validate(tt.container)
+			err := v.Validate(tt.container, nil)
 			if tt.expectError {
 				assert.Error(t, err)
 			} else {
@@ -101,8 +111,162 @@ func TestVolumeBackup_Validate(t *testing.T) {
 	}
 }
 
+func TestVolumeBackup_Validate_RestoreMode(t *testing.T) {
+	v := &VolumeBackup{}
+	container := &docker.ContainerInfo{
+		Name:   "test",
+		Mounts: []docker.MountInfo{{Type: "volume", Name: "vol", Destination: "/data"}},
+	}
+
+	assert.NoError(t, v.Validate(container, backup.Options{OptionRestoreMode: RestoreModeMerge}))
+	assert.NoError(t, v.Validate(container, backup.Options{OptionRestoreMode: RestoreModeClear}))
+
+	err := v.Validate(container, backup.Options{OptionRestoreMode: "wipe"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "restore-mode")
+}
+
+func TestVolumeBackup_Validate_Compression(t *testing.T) {
+	v := &VolumeBackup{}
+	container := &docker.ContainerInfo{
+		Name:   "test",
+		Mounts: []docker.MountInfo{{Type: "volume", Name: "vol", Destination: "/data"}},
+	}
+
+	assert.NoError(t, v.Validate(container, backup.Options{backup.OptionCompression: "gzip"}))
+	assert.Error(t, v.Validate(container, backup.Options{backup.OptionCompression: "lz4"}))
+}
+
+func TestVolumeBackup_Validate_StopContainer(t *testing.T) {
+	v := &VolumeBackup{}
+	container := &docker.ContainerInfo{
+		Name:   "test",
+		Mounts: []docker.MountInfo{{Type: "volume", Name: "vol", Destination: "/data"}},
+	}
+
+	assert.NoError(t, v.Validate(container, backup.Options{OptionStopContainer: "false"}))
+	assert.NoError(t, v.Validate(container, backup.Options{OptionStopContainer: "true"}))
+
+	err := v.Validate(container, backup.Options{OptionStopContainer: "sometimes"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), OptionStopContainer)
+}
+
+func TestSelectVolumes(t *testing.T) {
+	container := &docker.ContainerInfo{
+		Name: "app",
+		Mounts: []docker.MountInfo{
+			{Type: "volume", Name: "app-data", Destination: "/data"},
+			{Type: "volume", Name: "app-cache", Destination: "/var/cache/app"},
+			{Type: "bind", Source: "/host/config", Destination: "/config"},
+			{Type: "volume", Name: "app-uploads", Destination: "/uploads"},
+		},
+	}
+
+	names := func(mounts []docker.MountInfo) []string {
+		var names []string
+		for _, m := range mounts {
+			names = append(names, m.Name)
+		}
+		return names
+	}
+
+	all, err := selectVolumes(container, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"app-data", "app-cache", "app-uploads"}, names(all))
+
+	// Names and destinations can be mixed; the mount order is kept
+	selected, err := selectVolumes(container, backup.Options{OptionVolumes: "/uploads/, app-data"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"app-data", "app-uploads"}, names(selected))
+
+	_, err = selectVolumes(container, backup.Options{OptionVolumes: "app-data,missing"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"missing"`)
+
+	_, err = selectVolumes(container, backup.Options{OptionVolumes: "/config"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not a named volume")
+
+	assert.Error(t, (&VolumeBackup{}).Validate(container, backup.Options{OptionVolumes: "missing"}))
+
+	// In a restore test sandbox volumes are selected by the name they stand in for
+	sandbox := &docker.ContainerInfo{
+		Name: "app-restore-test",
+		Mounts: []docker.MountInfo{
+			{Type: "volume", Name: "3f2a9c", Destination: "/data", Origin: "app-data"},
+			{Type: "volume", Name: "8b1d4e", Destination: "/uploads", Origin: "app-uploads"},
+		},
+	}
+	selected, err = selectVolumes(sandbox, backup.Options{OptionVolumes: "app-uploads"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"8b1d4e"}, names(selected))
+}
+
 // TestVolumeBackup_Integration tests the full backup and restore cycle
 // using a real container with a named volume via testcontainers.
+func TestSplitArchivePath(t *testing.T) {
+	for name, want := range map[string][2]string{
+		"data":                {"data", ""},
+		"data/":               {"data", ""},
+		"data/file.txt":       {"data", "file.txt"},
+		"data/sub/dir/":       {"data", "sub/dir/"},
+		"data//file.txt":      {"data", "file.txt"},
+		"./data/file.txt":     {"data", "file.txt"},
+		"/data/file.txt":      {"data", "file.txt"},
+		`data/back\slash.txt`: {"data", `back\slash.txt`},
+		`data\file.txt`:       {`data\file.txt`, ""},
+		"":                    {"", ""},
+	} {
+		volumeName, relPath := splitArchivePath(name)
+		assert.Equal(t, want[0], volumeName, name)
+		assert.Equal(t, want[1], relPath, name)
+	}
+}
+
+func TestVolumeBackup_ListArchive(t *testing.T) {
+	var archive bytes.Buffer
+	enc, err := zstd.NewWriter(&archive)
+	require.NoError(t, err)
+	tw := tar.NewWriter(enc)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "data/", Typeflag: tar.TypeDir, Mode: 0755}))
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "data/app.log", Typeflag: tar.TypeReg, Mode: 0644, Size: 5}))
+	_, err = tw.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "data/current", Typeflag: tar.TypeSymlink, Linkname: "app.log"}))
+	require.NoError(t, tw.Close())
+	require.NoError(t, enc.Close())
+
+	var entries []backup.ArchiveEntry
+	err = (&VolumeBackup{}).ListArchive(context.Background(), &archive, func(e backup.ArchiveEntry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []backup.ArchiveEntry{
+		{Name: "data/app.log", Size: 5},
+		{Name: "data/current", Size: 0},
+	}, entries)
+}
+
+func TestVolumeBackup_ListArchive_Uncompressed(t *testing.T) {
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "data/app.log", Typeflag: tar.TypeReg, Mode: 0644, Size: 5}))
+	_, err := tw.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+
+	var entries []backup.ArchiveEntry
+	err = (&VolumeBackup{}).ListArchive(context.Background(), &archive, func(e backup.ArchiveEntry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []backup.ArchiveEntry{{Name: "data/app.log", Size: 5}}, entries)
+}
+
 func TestVolumeBackup_Integration(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping integration test in short mode")
@@ -172,7 +336,7 @@ func TestVolumeBackup_Integration(t *testing.T) {
 	// Perform backup
 	v := &VolumeBackup{}
 	var backupBuffer bytes.Buffer
-	err = v.Backup(ctx, containerInfo, dockerClient, &backupBuffer)
+	err = v.Backup(ctx, containerInfo, dockerClient, nil, &backupBuffer)
 	require.NoError(t, err)
 	assert.Greater(t, backupBuffer.Len(), 0, "backup should not be empty")
 
@@ -193,7 +357,7 @@ func TestVolumeBackup_Integration(t *testing.T) {
 	assert.NotEqual(t, 0, exitCode, "file should not exist after deletion")
 
 	// Perform restore
-	err = v.Restore(ctx, containerInfo, dockerClient, bytes.NewReader(backupBuffer.Bytes()))
+	err = v.Restore(ctx, containerInfo, dockerClient, nil, bytes.NewReader(backupBuffer.Bytes()))
 	require.NoError(t, err)
 
 	// Container should be running again after restore
@@ -293,7 +457,7 @@ func TestVolumeBackup_MultipleVolumes(t *testing.T) {
 	// Perform backup
 	v := &VolumeBackup{}
 	var backupBuffer bytes.Buffer
-	err = v.Backup(ctx, containerInfo, dockerClient, &backupBuffer)
+	err = v.Backup(ctx, containerInfo, dockerClient, nil, &backupBuffer)
 	require.NoError(t, err)
 	assert.Greater(t, backupBuffer.Len(), 0)
 
@@ -309,7 +473,7 @@ func TestVolumeBackup_MultipleVolumes(t *testing.T) {
 	assert.NotEqual(t, 0, exitCode)
 
 	// Perform restore
-	err = v.Restore(ctx, containerInfo, dockerClient, bytes.NewReader(backupBuffer.Bytes()))
+	err = v.Restore(ctx, containerInfo, dockerClient, nil, bytes.NewReader(backupBuffer.Bytes()))
 	require.NoError(t, err)
 
 	// Verify files are restored in both volumes
@@ -387,7 +551,7 @@ func TestVolumeBackup_LargeFiles(t *testing.T) {
 	// Perform backup
 	v := &VolumeBackup{}
 	var backupBuffer bytes.Buffer
-	err = v.Backup(ctx, containerInfo, dockerClient, &backupBuffer)
+	err = v.Backup(ctx, containerInfo, dockerClient, nil, &backupBuffer)
 	require.NoError(t, err)
 
 	t.Logf("Large file backup size: %d bytes (original: 1MB)", backupBuffer.Len())
@@ -397,7 +561,7 @@ func TestVolumeBackup_LargeFiles(t *testing.T) {
 	require.NoError(t, err)
 
 	// Restore
-	err = v.Restore(ctx, containerInfo, dockerClient, bytes.NewReader(backupBuffer.Bytes()))
+	err = v.Restore(ctx, containerInfo, dockerClient, nil, bytes.NewReader(backupBuffer.Bytes()))
 	require.NoError(t, err)
 
 	// Verify checksum matches
@@ -472,7 +636,7 @@ func TestVolumeBackup_SpecialFilenames(t *testing.T) {
 	// Perform backup
 	v := &VolumeBackup{}
 	var backupBuffer bytes.Buffer
-	err = v.Backup(ctx, containerInfo, dockerClient, &backupBuffer)
+	err = v.Backup(ctx, containerInfo, dockerClient, nil, &backupBuffer)
 	require.NoError(t, err)
 
 	// Delete all files
@@ -480,7 +644,7 @@ func TestVolumeBackup_SpecialFilenames(t *testing.T) {
 	require.NoError(t, err)
 
 	// Restore
-	err = v.Restore(ctx, containerInfo, dockerClient, bytes.NewReader(backupBuffer.Bytes()))
+	err = v.Restore(ctx, containerInfo, dockerClient, nil, bytes.NewReader(backupBuffer.Bytes()))
 	require.NoError(t, err)
 
 	// Verify all files are restored
@@ -555,7 +719,7 @@ func TestVolumeBackup_Symlinks(t *testing.T) {
 	// Perform backup
 	v := &VolumeBackup{}
 	var backupBuffer bytes.Buffer
-	err = v.Backup(ctx, containerInfo, dockerClient, &backupBuffer)
+	err = v.Backup(ctx, containerInfo, dockerClient, nil, &backupBuffer)
 	require.NoError(t, err)
 
 	// Delete files
@@ -563,7 +727,7 @@ func TestVolumeBackup_Symlinks(t *testing.T) {
 	require.NoError(t, err)
 
 	// Restore
-	err = v.Restore(ctx, containerInfo, dockerClient, bytes.NewReader(backupBuffer.Bytes()))
+	err = v.Restore(ctx, containerInfo, dockerClient, nil, bytes.NewReader(backupBuffer.Bytes()))
 	require.NoError(t, err)
 
 	// Verify symlink is restored and works
@@ -585,6 +749,150 @@ func TestVolumeBackup_Symlinks(t *testing.T) {
 	assert.Contains(t, output, "original.txt")
 }
 
+// TestVolumeBackup_RestoreSkipsEscapingSymlinks restores a crafted archive whose
+// symlinks point outside the volume and checks that none of them are created
+func TestVolumeBackup_RestoreSkipsEscapingSymlinks(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+
+	volumeName := fmt.Sprintf("test-volume-evil-symlink-%d", time.Now().UnixNano())
+
+	req := testcontainers.ContainerRequest{
+		Image: "alpine:latest",
+		Cmd:   []string{"sleep", "3600"},
+		Mounts: testcontainers.ContainerMounts{
+			testcontainers.VolumeMount(volumeName, "/data"),
+		},
+		WaitingFor: wait.ForExec([]string{"true"}).WithStartupTimeout(30 * time.Second),
+	}
+
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	require.NoError(t, err)
+	defer func() {
+		if err := container.Terminate(ctx); err != nil {
+			t.Logf("failed to terminate container: %v", err)
+		}
+	}()
+
+	dockerClient, err := docker.NewClient("")
+	require.NoError(t, err)
+	defer func() {
+		_ = dockerClient.Close()
+	}()
+
+	containerInfo, err := dockerClient.GetContainer(ctx, container.GetContainerID())
+	require.NoError(t, err)
+
+	var archive bytes.Buffer
+	zw, err := zstd.NewWriter(&archive)
+	require.NoError(t, err)
+	tw := tar.NewWriter(zw)
+
+	content := []byte("safe content")
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: volumeName + "/", Typeflag: tar.TypeDir, Mode: 0755}))
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: volumeName + "/etc", Typeflag: tar.TypeSymlink, Linkname: "/etc"}))
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: volumeName + "/up", Typeflag: tar.TypeSymlink, Linkname: "../.."}))
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: volumeName + "/inside", Typeflag: tar.TypeSymlink, Linkname: "safe.txt"}))
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: volumeName + "/safe.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}))
+	_, err = tw.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, zw.Close())
+
+	v := &VolumeBackup{}
+	err = v.Restore(ctx, containerInfo, dockerClient, nil, &archive)
+	require.NoError(t, err)
+
+	for _, link := range []string{"/data/etc", "/data/up"} {
+		exitCode, _, err := container.Exec(ctx, []string{"test", "-L", link})
+		require.NoError(t, err)
+		assert.NotEqual(t, 0, exitCode, "escaping symlink %s must not be restored", link)
+	}
+
+	exitCode, reader, err := container.Exec(ctx, []string{"cat", "/data/inside"})
+	require.NoError(t, err)
+	require.Equal(t, 0, exitCode)
+
+	output, err := readExecOutput(reader)
+	require.NoError(t, err)
+	assert.Contains(t, output, "safe content")
+}
+
+// TestVolumeBackup_RestoreModes checks that merge keeps files created after the
+// backup while clear removes them
+func TestVolumeBackup_RestoreModes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+
+	volumeName := fmt.Sprintf("test-volume-restore-mode-%d", time.Now().UnixNano())
+
+	req := testcontainers.ContainerRequest{
+		Image: "alpine:latest",
+		Cmd:   []string{"sleep", "3600"},
+		Mounts: testcontainers.ContainerMounts{
+			testcontainers.VolumeMount(volumeName, "/data"),
+		},
+		WaitingFor: wait.ForExec([]string{"true"}).WithStartupTimeout(30 * time.Second),
+	}
+
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	require.NoError(t, err)
+	defer func() {
+		if err := container.Terminate(ctx); err != nil {
+			t.Logf("failed to terminate container: %v", err)
+		}
+	}()
+
+	dockerClient, err := docker.NewClient("")
+	require.NoError(t, err)
+	defer func() {
+		_ = dockerClient.Close()
+	}()
+
+	containerInfo, err := dockerClient.GetContainer(ctx, container.GetContainerID())
+	require.NoError(t, err)
+
+	_, _, err = container.Exec(ctx, []string{"sh", "-c", "echo 'backed up' > /data/kept.txt"})
+	require.NoError(t, err)
+
+	v := &VolumeBackup{}
+	var backupBuffer bytes.Buffer
+	require.NoError(t, v.Backup(ctx, containerInfo, dockerClient, nil, &backupBuffer))
+
+	fileExists := func(p string) bool {
+		exitCode, _, err := container.Exec(ctx, []string{"test", "-e", p})
+		require.NoError(t, err)
+		return exitCode == 0
+	}
+
+	_, _, err = container.Exec(ctx, []string{"sh", "-c", "echo 'new' > /data/new.txt"})
+	require.NoError(t, err)
+
+	// Merge keeps files that aren't part of the backup
+	err = v.Restore(ctx, containerInfo, dockerClient, backup.Options{OptionRestoreMode: RestoreModeMerge}, bytes.NewReader(backupBuffer.Bytes()))
+	require.NoError(t, err)
+	assert.True(t, fileExists("/data/kept.txt"))
+	assert.True(t, fileExists("/data/new.txt"))
+
+	// Clear removes them
+	err = v.Restore(ctx, containerInfo, dockerClient, backup.Options{OptionRestoreMode: RestoreModeClear}, bytes.NewReader(backupBuffer.Bytes()))
+	require.NoError(t, err)
+	assert.True(t, fileExists("/data/kept.txt"))
+	assert.False(t, fileExists("/data/new.txt"))
+}
+
 // TestVolumeBackup_EmptyVolume tests backup/restore with an empty volume
 func TestVolumeBackup_EmptyVolume(t *testing.T) {
 	if testing.Short() {
@@ -629,13 +937,13 @@ func TestVolumeBackup_EmptyVolume(t *testing.T) {
 	// Backup empty volume (should still work)
 	v := &VolumeBackup{}
 	var backupBuffer bytes.Buffer
-	err = v.Backup(ctx, containerInfo, dockerClient, &backupBuffer)
+	err = v.Backup(ctx, containerInfo, dockerClient, nil, &backupBuffer)
 	require.NoError(t, err)
 
 	t.Logf("Empty volume backup size: %d bytes", backupBuffer.Len())
 
 	// Restore should also work without errors
-	err = v.Restore(ctx, containerInfo, dockerClient, bytes.NewReader(backupBuffer.Bytes()))
+	err = v.Restore(ctx, containerInfo, dockerClient, nil, bytes.NewReader(backupBuffer.Bytes()))
 	require.NoError(t, err)
 }
 
@@ -691,7 +999,7 @@ func TestVolumeBackup_DeepDirectoryStructure(t *testing.T) {
 	// Perform backup
 	v := &VolumeBackup{}
 	var backupBuffer bytes.Buffer
-	err = v.Backup(ctx, containerInfo, dockerClient, &backupBuffer)
+	err = v.Backup(ctx, containerInfo, dockerClient, nil, &backupBuffer)
 	require.NoError(t, err)
 
 	// Delete all
@@ -699,7 +1007,7 @@ func TestVolumeBackup_DeepDirectoryStructure(t *testing.T) {
 	require.NoError(t, err)
 
 	// Restore
-	err = v.Restore(ctx, containerInfo, dockerClient, bytes.NewReader(backupBuffer.Bytes()))
+	err = v.Restore(ctx, containerInfo, dockerClient, nil, bytes.NewReader(backupBuffer.Bytes()))
 	require.NoError(t, err)
 
 	// Verify deep file is restored
@@ -763,7 +1071,7 @@ func TestVolumeBackup_ArchiveContainsFiles(t *testing.T) {
 
 	v := &VolumeBackup{}
 	var backupBuffer bytes.Buffer
-	err = v.Backup(ctx, containerInfo, dockerClient, &backupBuffer)
+	err = v.Backup(ctx, containerInfo, dockerClient, nil, &backupBuffer)
 	require.NoError(t, err)
 
 	require.Greater(t, backupBuffer.Len(), 16, "backup must not be an empty archive (issue #16)")
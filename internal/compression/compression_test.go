@@ -0,0 +1,72 @@
+package compression
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	codec, err := Parse("")
+	require.NoError(t, err)
+	assert.Equal(t, Zstd, codec)
+
+	for _, name := range []string{"none", "gzip", "zstd"} {
+		codec, err := Parse(name)
+		require.NoError(t, err)
+		assert.Equal(t, Codec(name), codec)
+	}
+
+	_, err = Parse("brotli")
+	assert.Error(t, err)
+}
+
+func TestCodec_Extension(t *testing.T) {
+	assert.Equal(t, "", None.Extension())
+	assert.Equal(t, ".gz", Gzip.Extension())
+	assert.Equal(t, ".zst", Zstd.Extension())
+}
+
+func TestRoundTrip(t *testing.T) {
+	data := strings.Repeat("docker-backup ", 1000)
+
+	for _, codec := range []Codec{None, Gzip, Zstd} {
+		t.Run(string(codec), func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := codec.NewWriter(&buf)
+			require.NoError(t, err)
+			_, err = io.WriteString(w, data)
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+
+			if codec != None {
+				assert.Less(t, buf.Len(), len(data))
+			}
+
+			r, err := NewReader(&buf)
+			require.NoError(t, err)
+			out, err := io.ReadAll(r)
+			require.NoError(t, err)
+			require.NoError(t, r.Close())
+			assert.Equal(t, data, string(out))
+		})
+	}
+}
+
+func TestNewReader_ShortInput(t *testing.T) {
+	r, err := NewReader(strings.NewReader("x"))
+	require.NoError(t, err)
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "x", string(out))
+
+	r, err = NewReader(strings.NewReader(""))
+	require.NoError(t, err)
+	out, err = io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Empty(t, out)
+}
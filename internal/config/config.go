@@ -1,8 +1,11 @@
 package config
 
 import (
+	"crypto/tls"
 	"fmt"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -16,11 +19,42 @@ const (
 	EnvNotifyPrefix = EnvPrefix + "NOTIFY_"
 )
 
+const (
+	// DefaultFailureHistory is the number of failure records kept per backup config
+	DefaultFailureHistory = 10
+	// MaxFailureHistory bounds the failure records kept per backup config
+	MaxFailureHistory = 100
+)
+
 // Config holds the global application configuration
 type Config struct {
 	// Docker settings
 	DockerHost   string
 	PollInterval time.Duration
+	LabelPrefix  string // Prefix of container labels this instance reacts to
+
+	// Name of this daemon in notifications, defaults to the hostname, and
+	// whether backup keys start with it to keep daemons sharing a bucket apart
+	InstanceName      string
+	InstanceKeyPrefix bool
+
+	// TCP address of the API next to the Unix socket, and the bearer token it
+	// requires (--api-token or DOCKER_BACKUP_API_TOKEN)
+	APIAddr  string
+	APIToken string
+
+	// TCP address of the /healthz and /readyz probes, empty disables them
+	HealthAddr string
+
+	// Named Docker endpoints watched instead of DockerHost (format: name=host)
+	DockerNodeArgs []string
+	DockerNodes    []string          // Node names in flag order
+	DockerNodeHost map[string]string // Node name to Docker host
+
+	// TLS files for tcp:// Docker hosts
+	DockerTLSCA   string
+	DockerTLSCert string
+	DockerTLSKey  string
 
 	// Storage settings
 	DefaultStorage string
@@ -31,16 +65,74 @@ type Config struct {
 	NotifyArgs []string
 	NotifyDSNs map[string]string // map of notifier name to DSN
 
+	// Backups declared in the config file for containers without labels
+	Containers []ContainerRule
+
 	// Backup settings
-	TempDir string
+	TempDir          string
+	DefaultRetention int    // Retention for configs without a retention label
+	DefaultSchedule  string // Schedule for configs without a schedule label, empty means required
+	RetentionMinKeep int    // Backups of a config retention never deletes, 0 disables the guarantee
+	RetentionDryRun  bool   // Only log the backups retention would delete
+	FailureHistory   int    // Failure records kept per backup config, 0 disables the history
+	VerifyBackups    bool   // Read every stored backup back and compare its checksum
+	AuditLog         string // JSON Lines file recording every backup, restore and delete, empty disables it
+	StateDir         string // Directory of the journal of containers stopped for backups, empty means next to the socket
+
+	// Retries of storage operations after timeouts, network errors and 5xx responses
+	StorageRetries       int
+	StorageRetryDelay    time.Duration // Wait before the first retry, doubled for each further one
+	StorageRetryMaxDelay time.Duration // Cap of the jittered exponential backoff between retries
+
+	// Retries of failed scheduled backups, 0 disables them
+	BackupRetries       int
+	BackupRetryDelay    time.Duration // Wait before the first retry, doubled for each further one
+	BackupRetryMaxDelay time.Duration // Cap of the wait between retries
+
+	// Bytes per second all uploads share, as a size such as "10MB", empty means no limit
+	UploadRateLimit string
+
+	// IANA time zone schedules are evaluated in, empty means the local zone
+	Timezone string
+
+	// Back up configs whose scheduled run was missed while the daemon was down
+	RunMissedOnStartup bool
+
+	// Also watch stopped containers, whose configs with a when-stopped label are scheduled
+	IncludeStoppedContainers bool
+
+	// Upper bound of the random delay before each scheduled run, 0 disables it
+	ScheduleJitter time.Duration
+
+	// Backups running at the same time across all containers, 0 means no limit
+	MaxConcurrentBackups int
+
+	// How long shutdown waits for running backups before cancelling them
+	ShutdownTimeout time.Duration
+
+	// Encryption keyring (read from DOCKER_BACKUP_ENCRYPTION_KEYS and
+	// DOCKER_BACKUP_ENCRYPTION_CURRENT_KEY), empty disables encryption
+	EncryptionKeys       string
+	EncryptionCurrentKey string
+
+	// age encryption: recipients new backups are encrypted to and the file with
+	// the identities restores decrypt with (flags or DOCKER_BACKUP_ENCRYPTION_AGE_*)
+	EncryptionAgeRecipients   []string
+	EncryptionAgeIdentityFile string
 
 	// Dashboard settings
 	DashboardAddr      string
 	DashboardBasicAuth string // htpasswd-style credentials (user:hash or file path)
 
-	// Dashboard session secret (read from DOCKER_BACKUP_SESSION_SECRET env var, random if unset)
+	// Dashboard session secret (--dashboard.session-secret or DOCKER_BACKUP_SESSION_SECRET, random if unset)
 	DashboardSessionSecret string
 
+	// Dashboard HTTPS: certificate and key files (PEM), and an optional plain
+	// HTTP address that redirects to the dashboard
+	DashboardTLSCert      string
+	DashboardTLSKey       string
+	DashboardHTTPRedirect string
+
 	// Dashboard OIDC settings
 	DashboardOIDCProvider       string
 	DashboardOIDCIssuerURL      string
@@ -50,9 +142,20 @@ type Config struct {
 	DashboardOIDCAllowedUsers   []string
 	DashboardOIDCAllowedDomains []string
 
+	// Extra CA certificates (PEM file or directory) trusted for OIDC and notifier HTTPS calls
+	TLSCAFile string
+
+	// Outgoing HTTP settings for OIDC and notifier calls
+	HTTPTimeout       time.Duration // Limit for one request including retries, 0 disables it
+	HTTPRetries       int           // Retries after network errors and 429/5xx responses
+	HTTPRetryMaxDelay time.Duration // Cap of the jittered exponential backoff between retries
+
 	// Logging
 	LogLevel  string
 	LogFormat string
+
+	// sources records where settings came from, see SetSource
+	sources map[string]string
 }
 
 // StoragePool represents a named storage pool configuration
@@ -65,22 +168,278 @@ type StoragePool struct {
 // New creates a new Config with default values
 func New() *Config {
 	return &Config{
-		DockerHost:   "unix:///var/run/docker.sock",
-		PollInterval: 30 * time.Second,
-		LogLevel:     "info",
-		LogFormat:    "text",
-		StoragePools: make(map[string]*StoragePool),
-		NotifyDSNs:   make(map[string]string),
+		DockerHost:           "unix:///var/run/docker.sock",
+		PollInterval:         30 * time.Second,
+		ShutdownTimeout:      5 * time.Minute,
+		LabelPrefix:          LabelPrefix,
+		InstanceName:         hostname(),
+		DefaultRetention:     DefaultRetention,
+		FailureHistory:       DefaultFailureHistory,
+		StorageRetries:       3,
+		StorageRetryDelay:    time.Second,
+		StorageRetryMaxDelay: 30 * time.Second,
+		BackupRetryDelay:     time.Minute,
+		BackupRetryMaxDelay:  30 * time.Minute,
+		HTTPTimeout:          60 * time.Second,
+		HTTPRetries:          3,
+		HTTPRetryMaxDelay:    10 * time.Second,
+		LogLevel:             "info",
+		LogFormat:            "text",
+		StoragePools:         make(map[string]*StoragePool),
+		NotifyDSNs:           make(map[string]string),
 	}
 }
 
-// LoadSessionSecret loads the session secret from the environment variable
-// DOCKER_BACKUP_SESSION_SECRET. If not set, a random 32-byte key is generated.
-// A random key means sessions won't survive restarts.
-func (c *Config) LoadSessionSecret() {
+// LoadSessionSecret loads the session secret from DOCKER_BACKUP_SESSION_SECRET
+// unless it was set via --dashboard.session-secret. Without either, the
+// dashboard generates a random key at startup.
+func (c *Config) LoadSessionSecret(flagSet bool) {
+	if flagSet {
+		return
+	}
 	if secret := os.Getenv(EnvPrefix + "SESSION_SECRET"); secret != "" {
 		c.DashboardSessionSecret = secret
+		c.SetSource("dashboard.session-secret", SourceEnv)
+	}
+}
+
+// LoadAPIToken loads the API token from DOCKER_BACKUP_API_TOKEN unless it was
+// set via --api-token
+func (c *Config) LoadAPIToken(flagSet bool) {
+	if flagSet {
+		return
+	}
+	if token := os.Getenv(EnvPrefix + "API_TOKEN"); token != "" {
+		c.APIToken = token
+		c.SetSource("api-token", SourceEnv)
+	}
+}
+
+// ValidateDashboardTLS checks that the dashboard certificate and key are set
+// together and can be loaded, and that the HTTP redirect is only set with them
+func (c *Config) ValidateDashboardTLS() error {
+	if (c.DashboardTLSCert == "") != (c.DashboardTLSKey == "") {
+		return fmt.Errorf("--dashboard.tls-cert and --dashboard.tls-key must be set together")
+	}
+	if c.DashboardTLSCert == "" {
+		if c.DashboardHTTPRedirect != "" {
+			return fmt.Errorf("--dashboard.http-redirect requires --dashboard.tls-cert and --dashboard.tls-key")
+		}
+		return nil
+	}
+	if _, err := tls.LoadX509KeyPair(c.DashboardTLSCert, c.DashboardTLSKey); err != nil {
+		return fmt.Errorf("failed to load dashboard certificate: %w", err)
+	}
+	return nil
+}
+
+// ValidateDockerTLS checks the TLS files for tcp:// Docker hosts: the client
+// certificate and key are set together and can be loaded
+func (c *Config) ValidateDockerTLS() error {
+	if (c.DockerTLSCert == "") != (c.DockerTLSKey == "") {
+		return fmt.Errorf("--docker-tls-cert and --docker-tls-key must be set together")
 	}
+	if c.DockerTLSCert != "" {
+		if _, err := tls.LoadX509KeyPair(c.DockerTLSCert, c.DockerTLSKey); err != nil {
+			return fmt.Errorf("failed to load docker client certificate: %w", err)
+		}
+	}
+	if c.DockerTLSCA != "" {
+		if _, err := os.Stat(c.DockerTLSCA); err != nil {
+			return fmt.Errorf("failed to read docker CA certificate: %w", err)
+		}
+	}
+	return nil
+}
+
+// LoadEncryptionKeys loads the encryption keyring from DOCKER_BACKUP_ENCRYPTION_KEYS
+// (comma-separated id=base64key pairs) and the ID of the key new backups use from
+// DOCKER_BACKUP_ENCRYPTION_CURRENT_KEY
+func (c *Config) LoadEncryptionKeys() {
+	c.EncryptionKeys = os.Getenv(EnvPrefix + "ENCRYPTION_KEYS")
+	c.EncryptionCurrentKey = os.Getenv(EnvPrefix + "ENCRYPTION_CURRENT_KEY")
+	if c.EncryptionKeys != "" {
+		c.SetSource("encryption-keys", SourceEnv)
+	}
+	if c.EncryptionCurrentKey != "" {
+		c.SetSource("encryption-current-key", SourceEnv)
+	}
+}
+
+// LoadAgeEncryption reads DOCKER_BACKUP_ENCRYPTION_AGE_RECIPIENTS (comma-separated
+// public keys) and DOCKER_BACKUP_ENCRYPTION_AGE_IDENTITY_FILE for the values that
+// weren't set explicitly via flags
+func (c *Config) LoadAgeEncryption(recipientsSet, identitySet bool) {
+	if !recipientsSet {
+		if val := os.Getenv(EnvPrefix + "ENCRYPTION_AGE_RECIPIENTS"); val != "" {
+			c.EncryptionAgeRecipients = []string{val}
+			c.SetSource("encryption-age-recipient", SourceEnv)
+		}
+	}
+
+	if !identitySet {
+		if val := os.Getenv(EnvPrefix + "ENCRYPTION_AGE_IDENTITY_FILE"); val != "" {
+			c.EncryptionAgeIdentityFile = val
+			c.SetSource("encryption-age-identity", SourceEnv)
+		}
+	}
+}
+
+// LoadBackupDefaults reads DOCKER_BACKUP_DEFAULT_RETENTION and DOCKER_BACKUP_DEFAULT_SCHEDULE
+// for the values that weren't set explicitly via flags, then validates the retention
+func (c *Config) LoadBackupDefaults(retentionSet, scheduleSet bool) error {
+	if !retentionSet {
+		if val := os.Getenv(EnvPrefix + "DEFAULT_RETENTION"); val != "" {
+			retention, err := strconv.Atoi(val)
+			if err != nil {
+				return fmt.Errorf("invalid %sDEFAULT_RETENTION: %w", EnvPrefix, err)
+			}
+			c.DefaultRetention = retention
+			c.SetSource("default-retention", SourceEnv)
+		}
+	}
+
+	if !scheduleSet {
+		if val := os.Getenv(EnvPrefix + "DEFAULT_SCHEDULE"); val != "" {
+			c.DefaultSchedule = val
+			c.SetSource("default-schedule", SourceEnv)
+		}
+	}
+
+	if c.DefaultRetention < 1 {
+		return fmt.Errorf("default retention must be at least 1, got %d", c.DefaultRetention)
+	}
+	c.DefaultSchedule = strings.TrimSpace(c.DefaultSchedule)
+
+	return nil
+}
+
+// LoadRetentionMinKeep reads DOCKER_BACKUP_RETENTION_MIN_KEEP unless it was set
+// via --retention-min-keep, then validates it
+func (c *Config) LoadRetentionMinKeep(flagSet bool) error {
+	if !flagSet {
+		if val := os.Getenv(EnvPrefix + "RETENTION_MIN_KEEP"); val != "" {
+			minKeep, err := strconv.Atoi(val)
+			if err != nil {
+				return fmt.Errorf("invalid %sRETENTION_MIN_KEEP: %w", EnvPrefix, err)
+			}
+			c.RetentionMinKeep = minKeep
+			c.SetSource("retention-min-keep", SourceEnv)
+		}
+	}
+
+	if c.RetentionMinKeep < 0 {
+		return fmt.Errorf("retention min-keep must not be negative, got %d", c.RetentionMinKeep)
+	}
+
+	return nil
+}
+
+// LoadTimezone reads DOCKER_BACKUP_TIMEZONE unless the --timezone flag was
+// set, and returns the location schedules are evaluated in
+func (c *Config) LoadTimezone(flagSet bool) (*time.Location, error) {
+	if !flagSet {
+		if val := os.Getenv(EnvPrefix + "TIMEZONE"); val != "" {
+			c.Timezone = val
+			c.SetSource("timezone", SourceEnv)
+		}
+	}
+
+	if c.Timezone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", c.Timezone, err)
+	}
+	return loc, nil
+}
+
+// LoadRetentionDryRun reads DOCKER_BACKUP_RETENTION_DRY_RUN unless the
+// --retention-dry-run flag was set
+func (c *Config) LoadRetentionDryRun(flagSet bool) error {
+	if flagSet {
+		return nil
+	}
+	if val := os.Getenv(EnvPrefix + "RETENTION_DRY_RUN"); val != "" {
+		dryRun, err := strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("invalid %sRETENTION_DRY_RUN: %w", EnvPrefix, err)
+		}
+		c.RetentionDryRun = dryRun
+		c.SetSource("retention-dry-run", SourceEnv)
+	}
+	return nil
+}
+
+// LoadVerifyBackups reads DOCKER_BACKUP_VERIFY_BACKUPS unless the
+// --verify-backups flag was set
+func (c *Config) LoadVerifyBackups(flagSet bool) error {
+	if flagSet {
+		return nil
+	}
+	if val := os.Getenv(EnvPrefix + "VERIFY_BACKUPS"); val != "" {
+		verify, err := strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("invalid %sVERIFY_BACKUPS: %w", EnvPrefix, err)
+		}
+		c.VerifyBackups = verify
+		c.SetSource("verify-backups", SourceEnv)
+	}
+	return nil
+}
+
+// BackupDefaults returns the daemon-level defaults applied when parsing container labels
+func (c *Config) BackupDefaults() Defaults {
+	defaults := Defaults{
+		Retention: c.DefaultRetention,
+		Schedule:  c.DefaultSchedule,
+		MinKeep:   c.RetentionMinKeep,
+	}
+	if c.InstanceKeyPrefix {
+		defaults.PathPrefix = c.InstanceName
+	}
+	return defaults
+}
+
+// hostname returns the name of the host, or "" when it can't be determined
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+// ValidateInstanceName checks that the instance name is set and, when backup
+// keys start with it, that it is a single path segment
+func (c *Config) ValidateInstanceName() error {
+	if strings.TrimSpace(c.InstanceName) == "" {
+		return fmt.Errorf("instance name must not be empty, set --instance-name")
+	}
+	if !c.InstanceKeyPrefix {
+		return nil
+	}
+	if strings.Contains(c.InstanceName, "/") {
+		return fmt.Errorf("invalid instance name %q: must not contain a slash when used as key prefix", c.InstanceName)
+	}
+	if _, err := parsePathPrefix(c.InstanceName); err != nil {
+		return fmt.Errorf("invalid instance name %q: %w", c.InstanceName, err)
+	}
+	return nil
+}
+
+// UploadRateLimitBytes returns the upload rate limit in bytes per second, 0
+// when uploads aren't limited
+func (c *Config) UploadRateLimitBytes() (int64, error) {
+	if c.UploadRateLimit == "" {
+		return 0, nil
+	}
+	limit, err := ParseSize(c.UploadRateLimit)
+	if err != nil {
+		return 0, fmt.Errorf("invalid upload rate limit: %w", err)
+	}
+	return limit, nil
 }
 
 func (c *Config) ParseStoragePools() error {
@@ -106,20 +465,22 @@ func (c *Config) ParseStoragePools() error {
 		poolName := keyParts[0]
 		option := keyParts[1]
 
-		c.setStoragePoolOption(poolName, option, value)
+		c.setStoragePoolOption(poolName, option, value, SourceFlag)
 	}
 
 	// Set default storage if not specified and only one pool exists
 	if c.DefaultStorage == "" && len(c.StoragePools) == 1 {
 		for name := range c.StoragePools {
 			c.DefaultStorage = name
+			c.SetSource("default-storage", SourceAuto)
 		}
 	}
 
-	// Check for default storage from environment
-	if c.DefaultStorage == "" {
+	// Check for default storage from environment, which overrides the config file
+	if c.DefaultStorage == "" || c.Source("default-storage") == SourceFile {
 		if envDefault := os.Getenv(EnvPrefix + "DEFAULT_STORAGE"); envDefault != "" {
 			c.DefaultStorage = envDefault
+			c.SetSource("default-storage", SourceEnv)
 		}
 	}
 
@@ -162,11 +523,11 @@ func (c *Config) parseStorageEnvVars() {
 		// Convert underscores to hyphens in option name (ACCESS_KEY -> access-key)
 		option = strings.ReplaceAll(option, "_", "-")
 
-		c.setStoragePoolOption(poolName, option, value)
+		c.setStoragePoolOption(poolName, option, value, SourceEnv)
 	}
 }
 
-func (c *Config) setStoragePoolOption(poolName, option, value string) {
+func (c *Config) setStoragePoolOption(poolName, option, value, source string) {
 	pool, exists := c.StoragePools[poolName]
 	if !exists {
 		pool = &StoragePool{
@@ -176,6 +537,8 @@ func (c *Config) setStoragePoolOption(poolName, option, value string) {
 		c.StoragePools[poolName] = pool
 	}
 
+	c.SetSource(storageSourceKey(poolName, option), source)
+
 	// Handle type specially
 	if option == "type" {
 		pool.Type = value
@@ -184,6 +547,34 @@ func (c *Config) setStoragePoolOption(poolName, option, value string) {
 	}
 }
 
+// dockerNodePattern restricts node names to characters that are safe in
+// container names and storage keys
+var dockerNodePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9_-]*[a-z0-9])?$`)
+
+// ParseDockerNodes parses the --docker-node arguments into DockerNodes and DockerNodeHost
+func (c *Config) ParseDockerNodes() error {
+	c.DockerNodes = nil
+	c.DockerNodeHost = make(map[string]string)
+
+	for _, arg := range c.DockerNodeArgs {
+		name, host, ok := strings.Cut(arg, "=")
+		if !ok || host == "" {
+			return fmt.Errorf("invalid docker node argument format: %s (expected name=host)", arg)
+		}
+		if !dockerNodePattern.MatchString(name) {
+			return fmt.Errorf("invalid docker node name %q: must consist of lowercase letters, digits, dashes and underscores", name)
+		}
+		if _, exists := c.DockerNodeHost[name]; exists {
+			return fmt.Errorf("docker node %q is configured twice", name)
+		}
+
+		c.DockerNodes = append(c.DockerNodes, name)
+		c.DockerNodeHost[name] = host
+	}
+
+	return nil
+}
+
 func (c *Config) ParseNotifyDSNs() error {
 	// First, parse environment variables
 	c.parseNotifyEnvVars()
@@ -199,6 +590,7 @@ func (c *Config) ParseNotifyDSNs() error {
 		dsn := parts[1]
 
 		c.NotifyDSNs[name] = dsn
+		c.SetSource(notifySourceKey(name), SourceFlag)
 	}
 
 	return nil
@@ -225,5 +617,6 @@ func (c *Config) parseNotifyEnvVars() {
 		name := strings.ToLower(remainder)
 
 		c.NotifyDSNs[name] = value
+		c.SetSource(notifySourceKey(name), SourceEnv)
 	}
 }
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDockerNodes(t *testing.T) {
+	cfg := New()
+	cfg.DockerNodeArgs = []string{"node1=tcp://10.0.0.1:2376", "node-2=ssh://admin@10.0.0.2"}
+	require.NoError(t, cfg.ParseDockerNodes())
+
+	assert.Equal(t, []string{"node1", "node-2"}, cfg.DockerNodes)
+	assert.Equal(t, "ssh://admin@10.0.0.2", cfg.DockerNodeHost["node-2"])
+}
+
+func TestParseDockerNodes_Invalid(t *testing.T) {
+	for _, args := range [][]string{
+		{"node1"},
+		{"node1="},
+		{"Node1=tcp://10.0.0.1:2376"},
+		{"node/1=tcp://10.0.0.1:2376"},
+		{"node@1=tcp://10.0.0.1:2376"},
+		{"node1=tcp://10.0.0.1:2376", "node1=tcp://10.0.0.2:2376"},
+	} {
+		cfg := New()
+		cfg.DockerNodeArgs = args
+		assert.Error(t, cfg.ParseDockerNodes(), args)
+	}
+}
+
+func TestLoadVerifyBackups(t *testing.T) {
+	t.Setenv("DOCKER_BACKUP_VERIFY_BACKUPS", "true")
+
+	c := New()
+	require.NoError(t, c.LoadVerifyBackups(false))
+	assert.True(t, c.VerifyBackups)
+	assert.Equal(t, SourceEnv, c.Source("verify-backups"))
+
+	// An explicit flag wins over the environment
+	c = New()
+	require.NoError(t, c.LoadVerifyBackups(true))
+	assert.False(t, c.VerifyBackups)
+
+	t.Setenv("DOCKER_BACKUP_VERIFY_BACKUPS", "sometimes")
+	assert.Error(t, New().LoadVerifyBackups(false))
+}
+
+func TestLoadTimezone(t *testing.T) {
+	loc, err := New().LoadTimezone(false)
+	require.NoError(t, err)
+	assert.Equal(t, time.Local, loc)
+
+	t.Setenv("DOCKER_BACKUP_TIMEZONE", "Europe/Berlin")
+	c := New()
+	loc, err = c.LoadTimezone(false)
+	require.NoError(t, err)
+	assert.Equal(t, "Europe/Berlin", loc.String())
+	assert.Equal(t, SourceEnv, c.Source("timezone"))
+
+	// An explicit flag wins over the environment
+	c = New()
+	c.Timezone = "UTC"
+	loc, err = c.LoadTimezone(true)
+	require.NoError(t, err)
+	assert.Equal(t, "UTC", loc.String())
+
+	t.Setenv("DOCKER_BACKUP_TIMEZONE", "Mars/Olympus")
+	_, err = New().LoadTimezone(false)
+	assert.Error(t, err)
+}
+
+func TestValidateDashboardTLS(t *testing.T) {
+	require.NoError(t, New().ValidateDashboardTLS())
+
+	for name, cfg := range map[string]*Config{
+		"cert without key":     {DashboardTLSCert: "cert.pem"},
+		"key without cert":     {DashboardTLSKey: "key.pem"},
+		"redirect without TLS": {DashboardHTTPRedirect: ":80"},
+		"missing files":        {DashboardTLSCert: "missing.pem", DashboardTLSKey: "missing-key.pem"},
+	} {
+		assert.Error(t, cfg.ValidateDashboardTLS(), name)
+	}
+}
+
+func TestValidateDockerTLS(t *testing.T) {
+	require.NoError(t, New().ValidateDockerTLS())
+
+	for name, cfg := range map[string]*Config{
+		"cert without key": {DockerTLSCert: "cert.pem"},
+		"key without cert": {DockerTLSKey: "key.pem"},
+		"missing files":    {DockerTLSCert: "missing.pem", DockerTLSKey: "missing-key.pem"},
+		"missing CA":       {DockerTLSCA: "missing-ca.pem"},
+	} {
+		assert.Error(t, cfg.ValidateDockerTLS(), name)
+	}
+}
+
+func TestValidateInstanceName(t *testing.T) {
+	require.NoError(t, (&Config{InstanceName: "host-a"}).ValidateInstanceName())
+	require.NoError(t, (&Config{InstanceName: "host-a", InstanceKeyPrefix: true}).ValidateInstanceName())
+	require.NoError(t, (&Config{InstanceName: "eu/host-a"}).ValidateInstanceName(), "only key prefixes are single segments")
+
+	assert.ErrorContains(t, (&Config{}).ValidateInstanceName(), "must not be empty")
+	assert.ErrorContains(t, (&Config{InstanceName: "eu/host-a", InstanceKeyPrefix: true}).ValidateInstanceName(), "slash")
+	assert.ErrorContains(t, (&Config{InstanceName: ".host", InstanceKeyPrefix: true}).ValidateInstanceName(), "dot")
+}
+
+func TestBackupDefaults_InstanceKeyPrefix(t *testing.T) {
+	c := New()
+	c.InstanceName = "host-a"
+	assert.Empty(t, c.BackupDefaults().PathPrefix)
+
+	c.InstanceKeyPrefix = true
+	labels := map[string]string{
+		"docker-backup.enable":         "true",
+		"docker-backup.db.type":        "postgres",
+		"docker-backup.db.schedule":    "0 3 * * *",
+		"docker-backup.db.path-prefix": "prod/myapp",
+		"docker-backup.files.type":     "volume",
+		"docker-backup.files.schedule": "0 4 * * *",
+	}
+	cfg, err := ParseLabelsWithDefaults("docker-backup", c.BackupDefaults(), "abc123", "mycontainer", labels)
+	require.NoError(t, err)
+	require.Len(t, cfg.Backups, 2)
+	assert.Equal(t, "host-a/prod/myapp", cfg.Backups[0].PathPrefix)
+	assert.Equal(t, "host-a", cfg.Backups[1].PathPrefix)
+}
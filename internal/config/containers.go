@@ -0,0 +1,92 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ContainerRule declares backups in the config file for containers that
+// can't carry labels, e.g. third-party images whose compose file is out of
+// reach. It matches containers by name, by labels, or both.
+type ContainerRule struct {
+	Name     string            `yaml:"name"`      // Container name
+	Selector map[string]string `yaml:"selector"`  // Labels the container must have, with these values
+	Notify   string            `yaml:"notify"`    // Like the notify label
+	NotifyOn string            `yaml:"notify-on"` // Like the notify-on label
+	// Backups maps config names to their properties, named like the labels
+	// below <prefix>.<config>., e.g. type, schedule or backup type options
+	Backups map[string]map[string]string `yaml:"backups"`
+}
+
+// Matches reports whether the rule applies to the container
+func (r ContainerRule) Matches(containerName string, labels map[string]string) bool {
+	if r.Name != "" && r.Name != containerName {
+		return false
+	}
+	for key, value := range r.Selector {
+		if actual, ok := labels[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (r ContainerRule) validate() error {
+	if r.Name == "" && len(r.Selector) == 0 {
+		return fmt.Errorf("needs a name or a selector")
+	}
+	if len(r.Backups) == 0 {
+		return fmt.Errorf("declares no backups")
+	}
+	for name, props := range r.Backups {
+		if name == "" || strings.Contains(name, ".") || reservedProperties[name] {
+			return fmt.Errorf("invalid backup config name %q", name)
+		}
+		if strings.TrimSpace(props[LabelType]) == "" {
+			return fmt.Errorf("backup config %q has no type", name)
+		}
+	}
+	return nil
+}
+
+// labels returns the rule as the container labels it stands in for
+func (r ContainerRule) labels(prefix string) map[string]string {
+	labels := map[string]string{prefix + "." + LabelEnable: "true"}
+	if r.Notify != "" {
+		labels[prefix+"."+LabelNotify] = r.Notify
+	}
+	if r.NotifyOn != "" {
+		labels[prefix+"."+LabelNotifyOn] = r.NotifyOn
+	}
+	for name, props := range r.Backups {
+		for property, value := range props {
+			labels[prefix+"."+name+"."+property] = value
+		}
+	}
+	return labels
+}
+
+// ParseConfigForContainer parses the backup configuration of a container from
+// its labels and the config file rules matching it. Rules are merged as if
+// they were labels, label by label: a later rule overrides an earlier one and
+// the container's own labels override every rule. A container is enabled by a
+// matching rule unless it has an enable label of its own.
+func ParseConfigForContainer(prefix string, defaults Defaults, rules []ContainerRule, containerID, containerName string, labels map[string]string) (*ContainerConfig, error) {
+	merged := make(map[string]string)
+	for _, rule := range rules {
+		if !rule.Matches(containerName, labels) {
+			continue
+		}
+		for key, value := range rule.labels(prefix) {
+			merged[key] = value
+		}
+	}
+	if len(merged) == 0 {
+		return ParseLabelsWithDefaults(prefix, defaults, containerID, containerName, labels)
+	}
+
+	for key, value := range labels {
+		merged[key] = value
+	}
+	return ParseLabelsWithDefaults(prefix, defaults, containerID, containerName, merged)
+}
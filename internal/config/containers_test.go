@@ -0,0 +1,110 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var vendorDBRule = ContainerRule{
+	Name:   "vendor-db",
+	Notify: "telegram",
+	Backups: map[string]map[string]string{
+		"db": {"type": "postgres", "schedule": "0 3 * * *", "retention": "14", "dump-format": "custom"},
+	},
+}
+
+func TestParseConfigForContainer_RuleByName(t *testing.T) {
+	cfg, err := ParseConfigForContainer(LabelPrefix, Defaults{}, []ContainerRule{vendorDBRule}, "abc", "vendor-db", nil)
+	require.NoError(t, err)
+
+	assert.True(t, cfg.Enabled)
+	assert.Equal(t, []string{"telegram"}, cfg.Notify)
+	require.Len(t, cfg.Backups, 1)
+	assert.Equal(t, "postgres", cfg.Backups[0].BackupType)
+	assert.Equal(t, 14, cfg.Backups[0].Retention)
+	assert.Equal(t, "custom", cfg.Backups[0].Options["dump-format"])
+
+	cfg, err = ParseConfigForContainer(LabelPrefix, Defaults{}, []ContainerRule{vendorDBRule}, "def", "other-db", nil)
+	require.NoError(t, err)
+	assert.False(t, cfg.Enabled)
+}
+
+func TestParseConfigForContainer_RuleBySelector(t *testing.T) {
+	rule := ContainerRule{
+		Selector: map[string]string{"com.docker.compose.service": "db"},
+		Backups:  map[string]map[string]string{"db": {"type": "mysql", "schedule": "@daily"}},
+	}
+
+	cfg, err := ParseConfigForContainer(LabelPrefix, Defaults{}, []ContainerRule{rule}, "abc", "shop-db-1", map[string]string{
+		"com.docker.compose.service": "db",
+	})
+	require.NoError(t, err)
+	assert.True(t, cfg.Enabled)
+
+	cfg, err = ParseConfigForContainer(LabelPrefix, Defaults{}, []ContainerRule{rule}, "abc", "shop-web-1", map[string]string{
+		"com.docker.compose.service": "web",
+	})
+	require.NoError(t, err)
+	assert.False(t, cfg.Enabled)
+}
+
+func TestParseConfigForContainer_Precedence(t *testing.T) {
+	override := ContainerRule{
+		Selector: map[string]string{"tier": "critical"},
+		Backups:  map[string]map[string]string{"db": {"type": "postgres", "retention": "30"}},
+	}
+	labels := map[string]string{
+		"tier":                      "critical",
+		"docker-backup.db.schedule": "0 * * * *",
+		"docker-backup.files.type":  "volume",
+	}
+
+	cfg, err := ParseConfigForContainer(LabelPrefix, Defaults{Schedule: "@daily"}, []ContainerRule{vendorDBRule, override}, "abc", "vendor-db", labels)
+	require.NoError(t, err)
+	require.Len(t, cfg.Backups, 2)
+
+	// The later rule overrides the retention, the label the schedule
+	db := cfg.Backups[0]
+	assert.Equal(t, 30, db.Retention)
+	assert.Equal(t, "0 * * * *", db.Schedule)
+	assert.Equal(t, "custom", db.Options["dump-format"])
+	assert.Equal(t, "volume", cfg.Backups[1].BackupType)
+
+	// An enable label of the container itself wins over the rule
+	labels["docker-backup.enable"] = "false"
+	cfg, err = ParseConfigForContainer(LabelPrefix, Defaults{}, []ContainerRule{vendorDBRule}, "abc", "vendor-db", labels)
+	require.NoError(t, err)
+	assert.False(t, cfg.Enabled)
+}
+
+func TestLoadFile_Containers(t *testing.T) {
+	f, err := LoadFile(writeConfigFile(t, `
+containers:
+  - name: vendor-db
+    backups:
+      db:
+        type: postgres
+        schedule: "0 3 * * *"
+        retention: 14
+`))
+	require.NoError(t, err)
+
+	c := New()
+	c.ApplyFile(f, noFlags)
+	require.Len(t, c.Containers, 1)
+	assert.Equal(t, "14", c.Containers[0].Backups["db"]["retention"])
+
+	for name, content := range map[string]string{
+		"no match":    "containers:\n  - backups:\n      db:\n        type: postgres",
+		"no backups":  "containers:\n  - name: vendor-db",
+		"no type":     "containers:\n  - name: vendor-db\n    backups:\n      db:\n        schedule: '@daily'",
+		"dotted name": "containers:\n  - name: vendor-db\n    backups:\n      db.main:\n        type: postgres",
+		"reserved":    "containers:\n  - name: vendor-db\n    backups:\n      notify:\n        type: postgres",
+		"unknown key": "containers:\n  - name: vendor-db\n    labels:\n      a: b",
+	} {
+		_, err := LoadFile(writeConfigFile(t, content))
+		assert.Error(t, err, name)
+	}
+}
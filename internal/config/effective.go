@@ -0,0 +1,236 @@
+package config
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Sources reported for effective configuration values
+const (
+	SourceDefault = "default" // Built-in default
+	SourceFlag    = "flag"    // Command line flag
+	SourceEnv     = "env"     // DOCKER_BACKUP_* environment variable
+	SourceFile    = "file"    // --config file
+	SourceAuto    = "auto"    // Derived from other settings
+)
+
+// Redacted replaces secret values in the effective configuration
+const Redacted = "[redacted]"
+
+// secretPatterns mark option names whose values are never shown
+var secretPatterns = []string{"secret", "password", "passwd", "token", "key", "credential", "auth"}
+
+// EffectiveConfig is the configuration the daemon runs with, secrets redacted
+type EffectiveConfig struct {
+	Settings     []Setting           `json:"settings"`
+	StoragePools []EffectivePool     `json:"storage_pools"`
+	Notifiers    []EffectiveNotifier `json:"notifiers"`
+}
+
+// Setting is a single daemon setting and where its value came from
+type Setting struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// EffectivePool is a configured storage pool
+type EffectivePool struct {
+	Name       string    `json:"name"`
+	Type       string    `json:"type"`
+	TypeSource string    `json:"type_source"`
+	Default    bool      `json:"default"`
+	Options    []Setting `json:"options"`
+}
+
+// EffectiveNotifier is a configured notification provider. Only the scheme of
+// the DSN is shown, the rest usually carries credentials.
+type EffectiveNotifier struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Source string `json:"source"`
+}
+
+// SetSource records where the value of a setting came from. Settings with a
+// flag use the flag name.
+func (c *Config) SetSource(name, source string) {
+	if c.sources == nil {
+		c.sources = make(map[string]string)
+	}
+	c.sources[name] = source
+}
+
+// Source returns where the value of a setting came from
+func (c *Config) Source(name string) string {
+	if source, ok := c.sources[name]; ok {
+		return source
+	}
+	return SourceDefault
+}
+
+// Effective returns the configuration with secrets redacted
+func (c *Config) Effective() EffectiveConfig {
+	eff := EffectiveConfig{
+		StoragePools: []EffectivePool{},
+		Notifiers:    []EffectiveNotifier{},
+	}
+
+	add := func(name, value string) {
+		eff.Settings = append(eff.Settings, Setting{Name: name, Value: value, Source: c.Source(name)})
+	}
+	addSecret := func(name, value string) {
+		if value != "" {
+			value = Redacted
+		}
+		add(name, value)
+	}
+
+	add("docker-host", redactURL(c.DockerHost))
+	nodes := make([]string, 0, len(c.DockerNodes))
+	for _, name := range c.DockerNodes {
+		nodes = append(nodes, name+"="+redactURL(c.DockerNodeHost[name]))
+	}
+	add("docker-node", strings.Join(nodes, ","))
+	add("docker-tls-ca", c.DockerTLSCA)
+	add("docker-tls-cert", c.DockerTLSCert)
+	add("docker-tls-key", c.DockerTLSKey)
+	add("poll-interval", c.PollInterval.String())
+	add("label-prefix", c.LabelPrefix)
+	add("instance-name", c.InstanceName)
+	add("instance-key-prefix", strconv.FormatBool(c.InstanceKeyPrefix))
+	add("default-storage", c.DefaultStorage)
+	add("default-retention", strconv.Itoa(c.DefaultRetention))
+	add("default-schedule", c.DefaultSchedule)
+	add("timezone", c.Timezone)
+	add("schedule-jitter", c.ScheduleJitter.String())
+	add("run-missed-on-startup", strconv.FormatBool(c.RunMissedOnStartup))
+	add("include-stopped-containers", strconv.FormatBool(c.IncludeStoppedContainers))
+	add("retention-min-keep", strconv.Itoa(c.RetentionMinKeep))
+	add("retention-dry-run", strconv.FormatBool(c.RetentionDryRun))
+	add("failure-history", strconv.Itoa(c.FailureHistory))
+	add("verify-backups", strconv.FormatBool(c.VerifyBackups))
+	add("max-concurrent-backups", strconv.Itoa(c.MaxConcurrentBackups))
+	add("shutdown-timeout", c.ShutdownTimeout.String())
+	add("temp-dir", c.TempDir)
+	add("audit-log", c.AuditLog)
+	add("state-dir", c.StateDir)
+	add("backup-retries", strconv.Itoa(c.BackupRetries))
+	add("backup-retry-delay", c.BackupRetryDelay.String())
+	add("backup-retry-max-delay", c.BackupRetryMaxDelay.String())
+	add("storage-retries", strconv.Itoa(c.StorageRetries))
+	add("storage-retry-delay", c.StorageRetryDelay.String())
+	add("storage-retry-max-delay", c.StorageRetryMaxDelay.String())
+	add("upload-rate-limit", c.UploadRateLimit)
+	addSecret("encryption-keys", c.EncryptionKeys)
+	add("encryption-current-key", c.EncryptionCurrentKey)
+	add("encryption-age-recipient", strings.Join(c.EncryptionAgeRecipients, ","))
+	add("encryption-age-identity", c.EncryptionAgeIdentityFile)
+	add("dashboard", c.DashboardAddr)
+	addSecret("dashboard.auth.basic", c.DashboardBasicAuth)
+	addSecret("dashboard.session-secret", c.DashboardSessionSecret)
+	add("dashboard.tls-cert", c.DashboardTLSCert)
+	add("dashboard.tls-key", c.DashboardTLSKey)
+	add("dashboard.http-redirect", c.DashboardHTTPRedirect)
+	add("dashboard.auth.oidc.provider", c.DashboardOIDCProvider)
+	add("dashboard.auth.oidc.issuer-url", redactURL(c.DashboardOIDCIssuerURL))
+	add("dashboard.auth.oidc.client-id", c.DashboardOIDCClientID)
+	addSecret("dashboard.auth.oidc.client-secret", c.DashboardOIDCClientSecret)
+	add("dashboard.auth.oidc.redirect-url", redactURL(c.DashboardOIDCRedirectURL))
+	add("dashboard.auth.oidc.allowed-users", strings.Join(c.DashboardOIDCAllowedUsers, ","))
+	add("dashboard.auth.oidc.allowed-domains", strings.Join(c.DashboardOIDCAllowedDomains, ","))
+	add("api-addr", c.APIAddr)
+	addSecret("api-token", c.APIToken)
+	add("health-addr", c.HealthAddr)
+	add("tls-ca-file", c.TLSCAFile)
+	add("http-timeout", c.HTTPTimeout.String())
+	add("http-retries", strconv.Itoa(c.HTTPRetries))
+	add("http-retry-max-delay", c.HTTPRetryMaxDelay.String())
+	add("log-level", c.LogLevel)
+	add("log-format", c.LogFormat)
+
+	poolNames := make([]string, 0, len(c.StoragePools))
+	for name := range c.StoragePools {
+		poolNames = append(poolNames, name)
+	}
+	sort.Strings(poolNames)
+	for _, name := range poolNames {
+		pool := c.StoragePools[name]
+		p := EffectivePool{
+			Name:       name,
+			Type:       pool.Type,
+			TypeSource: c.Source(storageSourceKey(name, "type")),
+			Default:    name == c.DefaultStorage,
+			Options:    []Setting{},
+		}
+
+		options := make([]string, 0, len(pool.Options))
+		for option := range pool.Options {
+			options = append(options, option)
+		}
+		sort.Strings(options)
+		for _, option := range options {
+			value := pool.Options[option]
+			if IsSecretOption(option) {
+				value = Redacted
+			} else {
+				value = redactURL(value)
+			}
+			p.Options = append(p.Options, Setting{
+				Name:   option,
+				Value:  value,
+				Source: c.Source(storageSourceKey(name, option)),
+			})
+		}
+		eff.StoragePools = append(eff.StoragePools, p)
+	}
+
+	notifyNames := make([]string, 0, len(c.NotifyDSNs))
+	for name := range c.NotifyDSNs {
+		notifyNames = append(notifyNames, name)
+	}
+	sort.Strings(notifyNames)
+	for _, name := range notifyNames {
+		notifierType, _, _ := strings.Cut(c.NotifyDSNs[name], "://")
+		eff.Notifiers = append(eff.Notifiers, EffectiveNotifier{
+			Name:   name,
+			Type:   notifierType,
+			Source: c.Source(notifySourceKey(name)),
+		})
+	}
+
+	return eff
+}
+
+// IsSecretOption reports whether an option name looks like it holds a secret
+func IsSecretOption(name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range secretPatterns {
+		if strings.Contains(name, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactURL hides the password of URLs with credentials and leaves other values unchanged
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return value
+	}
+	if _, ok := u.User.Password(); !ok {
+		return value
+	}
+	u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	return u.String()
+}
+
+func storageSourceKey(pool, option string) string {
+	return "storage." + pool + "." + option
+}
+
+func notifySourceKey(name string) string {
+	return "notify." + name
+}
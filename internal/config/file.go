@@ -0,0 +1,271 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File is the YAML config file passed with --config. Keys are named like the
+// daemon flags; every setting is optional and flags and environment variables
+// override it. Backups themselves are still configured with container labels.
+type File struct {
+	DockerHost    *string        `yaml:"docker-host"`
+	DockerNodes   []string       `yaml:"docker-nodes"` // name=host, like --docker-node
+	DockerTLSCA   *string        `yaml:"docker-tls-ca"`
+	DockerTLSCert *string        `yaml:"docker-tls-cert"`
+	DockerTLSKey  *string        `yaml:"docker-tls-key"`
+	PollInterval  *time.Duration `yaml:"poll-interval"`
+	LabelPrefix   *string        `yaml:"label-prefix"`
+
+	InstanceName      *string `yaml:"instance-name"`
+	InstanceKeyPrefix *bool   `yaml:"instance-key-prefix"`
+
+	DefaultStorage       *string        `yaml:"default-storage"`
+	DefaultRetention     *int           `yaml:"default-retention"`
+	DefaultSchedule      *string        `yaml:"default-schedule"`
+	Timezone             *string        `yaml:"timezone"`
+	ScheduleJitter       *time.Duration `yaml:"schedule-jitter"`
+	RunMissedOnStartup   *bool          `yaml:"run-missed-on-startup"`
+	IncludeStopped       *bool          `yaml:"include-stopped-containers"`
+	RetentionMinKeep     *int           `yaml:"retention-min-keep"`
+	RetentionDryRun      *bool          `yaml:"retention-dry-run"`
+	FailureHistory       *int           `yaml:"failure-history"`
+	VerifyBackups        *bool          `yaml:"verify-backups"`
+	MaxConcurrentBackups *int           `yaml:"max-concurrent-backups"`
+	ShutdownTimeout      *time.Duration `yaml:"shutdown-timeout"`
+	TempDir              *string        `yaml:"temp-dir"`
+	AuditLog             *string        `yaml:"audit-log"`
+	StateDir             *string        `yaml:"state-dir"`
+	BackupRetries        *int           `yaml:"backup-retries"`
+	BackupRetryDelay     *time.Duration `yaml:"backup-retry-delay"`
+	BackupRetryMaxDelay  *time.Duration `yaml:"backup-retry-max-delay"`
+	StorageRetries       *int           `yaml:"storage-retries"`
+	StorageRetryDelay    *time.Duration `yaml:"storage-retry-delay"`
+	StorageRetryMaxDelay *time.Duration `yaml:"storage-retry-max-delay"`
+	UploadRateLimit      *string        `yaml:"upload-rate-limit"`
+
+	Encryption struct {
+		AgeRecipients []string `yaml:"age-recipients"`
+		AgeIdentity   *string  `yaml:"age-identity"`
+	} `yaml:"encryption"`
+
+	// Storage maps pool names to their options, including type
+	Storage map[string]map[string]string `yaml:"storage"`
+	// Notify maps notification provider names to their DSN
+	Notify map[string]string `yaml:"notify"`
+	// Containers declares backups for containers that can't be labeled
+	Containers []ContainerRule `yaml:"containers"`
+
+	Dashboard struct {
+		Address       *string `yaml:"address"`
+		SessionSecret *string `yaml:"session-secret"`
+		TLSCert       *string `yaml:"tls-cert"`
+		TLSKey        *string `yaml:"tls-key"`
+		HTTPRedirect  *string `yaml:"http-redirect"`
+		Auth          struct {
+			Basic *string `yaml:"basic"`
+			OIDC  struct {
+				Provider       *string  `yaml:"provider"`
+				IssuerURL      *string  `yaml:"issuer-url"`
+				ClientID       *string  `yaml:"client-id"`
+				ClientSecret   *string  `yaml:"client-secret"`
+				RedirectURL    *string  `yaml:"redirect-url"`
+				AllowedUsers   []string `yaml:"allowed-users"`
+				AllowedDomains []string `yaml:"allowed-domains"`
+			} `yaml:"oidc"`
+		} `yaml:"auth"`
+	} `yaml:"dashboard"`
+
+	APIAddr    *string `yaml:"api-addr"`
+	APIToken   *string `yaml:"api-token"`
+	HealthAddr *string `yaml:"health-addr"`
+
+	TLSCAFile         *string        `yaml:"tls-ca-file"`
+	HTTPTimeout       *time.Duration `yaml:"http-timeout"`
+	HTTPRetries       *int           `yaml:"http-retries"`
+	HTTPRetryMaxDelay *time.Duration `yaml:"http-retry-max-delay"`
+
+	LogLevel  *string `yaml:"log-level"`
+	LogFormat *string `yaml:"log-format"`
+}
+
+// LoadFile reads and validates the config file at path. Unknown keys are
+// rejected, so typos don't go unnoticed.
+func LoadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	f, err := parseFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return f, nil
+}
+
+func parseFile(data []byte) (*File, error) {
+	f := &File{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	if err := f.validate(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *File) validate() error {
+	for name, options := range f.Storage {
+		if name == "" {
+			return fmt.Errorf("storage pool without a name")
+		}
+		if options["type"] == "" {
+			return fmt.Errorf("storage pool %q has no type", name)
+		}
+	}
+	for name, dsn := range f.Notify {
+		if dsn == "" {
+			return fmt.Errorf("notification provider %q has no DSN", name)
+		}
+	}
+
+	for i, rule := range f.Containers {
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("containers[%d]: %w", i, err)
+		}
+	}
+
+	for name, d := range map[string]*time.Duration{
+		"poll-interval":           f.PollInterval,
+		"schedule-jitter":         f.ScheduleJitter,
+		"shutdown-timeout":        f.ShutdownTimeout,
+		"backup-retry-delay":      f.BackupRetryDelay,
+		"backup-retry-max-delay":  f.BackupRetryMaxDelay,
+		"storage-retry-delay":     f.StorageRetryDelay,
+		"storage-retry-max-delay": f.StorageRetryMaxDelay,
+		"http-timeout":            f.HTTPTimeout,
+		"http-retry-max-delay":    f.HTTPRetryMaxDelay,
+	} {
+		if d != nil && *d < 0 {
+			return fmt.Errorf("%s must not be negative, got %s", name, *d)
+		}
+	}
+	if f.PollInterval != nil && *f.PollInterval == 0 {
+		return fmt.Errorf("poll-interval must be greater than 0")
+	}
+	if f.UploadRateLimit != nil && *f.UploadRateLimit != "" {
+		if _, err := ParseSize(*f.UploadRateLimit); err != nil {
+			return fmt.Errorf("upload-rate-limit: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ApplyFile copies the settings of f into c, skipping those whose flag was
+// set. It must run before the environment is read, which then overrides the
+// file. flagSet reports whether the flag of that name was set.
+func (c *Config) ApplyFile(f *File, flagSet func(name string) bool) {
+	applyFileValue(c, flagSet, "docker-host", &c.DockerHost, f.DockerHost)
+	applyFileValue(c, flagSet, "docker-tls-ca", &c.DockerTLSCA, f.DockerTLSCA)
+	applyFileValue(c, flagSet, "docker-tls-cert", &c.DockerTLSCert, f.DockerTLSCert)
+	applyFileValue(c, flagSet, "docker-tls-key", &c.DockerTLSKey, f.DockerTLSKey)
+	applyFileValue(c, flagSet, "poll-interval", &c.PollInterval, f.PollInterval)
+	applyFileValue(c, flagSet, "label-prefix", &c.LabelPrefix, f.LabelPrefix)
+	applyFileValue(c, flagSet, "instance-name", &c.InstanceName, f.InstanceName)
+	applyFileValue(c, flagSet, "instance-key-prefix", &c.InstanceKeyPrefix, f.InstanceKeyPrefix)
+	applyFileValue(c, flagSet, "default-storage", &c.DefaultStorage, f.DefaultStorage)
+	applyFileValue(c, flagSet, "default-retention", &c.DefaultRetention, f.DefaultRetention)
+	applyFileValue(c, flagSet, "default-schedule", &c.DefaultSchedule, f.DefaultSchedule)
+	applyFileValue(c, flagSet, "timezone", &c.Timezone, f.Timezone)
+	applyFileValue(c, flagSet, "schedule-jitter", &c.ScheduleJitter, f.ScheduleJitter)
+	applyFileValue(c, flagSet, "run-missed-on-startup", &c.RunMissedOnStartup, f.RunMissedOnStartup)
+	applyFileValue(c, flagSet, "include-stopped-containers", &c.IncludeStoppedContainers, f.IncludeStopped)
+	applyFileValue(c, flagSet, "retention-min-keep", &c.RetentionMinKeep, f.RetentionMinKeep)
+	applyFileValue(c, flagSet, "retention-dry-run", &c.RetentionDryRun, f.RetentionDryRun)
+	applyFileValue(c, flagSet, "failure-history", &c.FailureHistory, f.FailureHistory)
+	applyFileValue(c, flagSet, "verify-backups", &c.VerifyBackups, f.VerifyBackups)
+	applyFileValue(c, flagSet, "max-concurrent-backups", &c.MaxConcurrentBackups, f.MaxConcurrentBackups)
+	applyFileValue(c, flagSet, "shutdown-timeout", &c.ShutdownTimeout, f.ShutdownTimeout)
+	applyFileValue(c, flagSet, "temp-dir", &c.TempDir, f.TempDir)
+	applyFileValue(c, flagSet, "audit-log", &c.AuditLog, f.AuditLog)
+	applyFileValue(c, flagSet, "state-dir", &c.StateDir, f.StateDir)
+	applyFileValue(c, flagSet, "backup-retries", &c.BackupRetries, f.BackupRetries)
+	applyFileValue(c, flagSet, "backup-retry-delay", &c.BackupRetryDelay, f.BackupRetryDelay)
+	applyFileValue(c, flagSet, "backup-retry-max-delay", &c.BackupRetryMaxDelay, f.BackupRetryMaxDelay)
+	applyFileValue(c, flagSet, "storage-retries", &c.StorageRetries, f.StorageRetries)
+	applyFileValue(c, flagSet, "storage-retry-delay", &c.StorageRetryDelay, f.StorageRetryDelay)
+	applyFileValue(c, flagSet, "storage-retry-max-delay", &c.StorageRetryMaxDelay, f.StorageRetryMaxDelay)
+	applyFileValue(c, flagSet, "upload-rate-limit", &c.UploadRateLimit, f.UploadRateLimit)
+	applyFileList(c, flagSet, "docker-node", &c.DockerNodeArgs, f.DockerNodes)
+	applyFileList(c, flagSet, "encryption-age-recipient", &c.EncryptionAgeRecipients, f.Encryption.AgeRecipients)
+	applyFileValue(c, flagSet, "encryption-age-identity", &c.EncryptionAgeIdentityFile, f.Encryption.AgeIdentity)
+
+	dashboard := &f.Dashboard
+	applyFileValue(c, flagSet, "dashboard", &c.DashboardAddr, dashboard.Address)
+	applyFileValue(c, flagSet, "dashboard.session-secret", &c.DashboardSessionSecret, dashboard.SessionSecret)
+	applyFileValue(c, flagSet, "dashboard.tls-cert", &c.DashboardTLSCert, dashboard.TLSCert)
+	applyFileValue(c, flagSet, "dashboard.tls-key", &c.DashboardTLSKey, dashboard.TLSKey)
+	applyFileValue(c, flagSet, "dashboard.http-redirect", &c.DashboardHTTPRedirect, dashboard.HTTPRedirect)
+	applyFileValue(c, flagSet, "dashboard.auth.basic", &c.DashboardBasicAuth, dashboard.Auth.Basic)
+	oidc := &dashboard.Auth.OIDC
+	applyFileValue(c, flagSet, "dashboard.auth.oidc.provider", &c.DashboardOIDCProvider, oidc.Provider)
+	applyFileValue(c, flagSet, "dashboard.auth.oidc.issuer-url", &c.DashboardOIDCIssuerURL, oidc.IssuerURL)
+	applyFileValue(c, flagSet, "dashboard.auth.oidc.client-id", &c.DashboardOIDCClientID, oidc.ClientID)
+	applyFileValue(c, flagSet, "dashboard.auth.oidc.client-secret", &c.DashboardOIDCClientSecret, oidc.ClientSecret)
+	applyFileValue(c, flagSet, "dashboard.auth.oidc.redirect-url", &c.DashboardOIDCRedirectURL, oidc.RedirectURL)
+	applyFileList(c, flagSet, "dashboard.auth.oidc.allowed-users", &c.DashboardOIDCAllowedUsers, oidc.AllowedUsers)
+	applyFileList(c, flagSet, "dashboard.auth.oidc.allowed-domains", &c.DashboardOIDCAllowedDomains, oidc.AllowedDomains)
+
+	applyFileValue(c, flagSet, "api-addr", &c.APIAddr, f.APIAddr)
+	applyFileValue(c, flagSet, "api-token", &c.APIToken, f.APIToken)
+	applyFileValue(c, flagSet, "health-addr", &c.HealthAddr, f.HealthAddr)
+	applyFileValue(c, flagSet, "tls-ca-file", &c.TLSCAFile, f.TLSCAFile)
+	applyFileValue(c, flagSet, "http-timeout", &c.HTTPTimeout, f.HTTPTimeout)
+	applyFileValue(c, flagSet, "http-retries", &c.HTTPRetries, f.HTTPRetries)
+	applyFileValue(c, flagSet, "http-retry-max-delay", &c.HTTPRetryMaxDelay, f.HTTPRetryMaxDelay)
+	applyFileValue(c, flagSet, "log-level", &c.LogLevel, f.LogLevel)
+	applyFileValue(c, flagSet, "log-format", &c.LogFormat, f.LogFormat)
+
+	// Pools and providers are merged option by option, ParseStoragePools and
+	// ParseNotifyDSNs apply the environment and flags on top
+	for pool, options := range f.Storage {
+		for option, value := range options {
+			c.setStoragePoolOption(pool, option, value, SourceFile)
+		}
+	}
+	for name, dsn := range f.Notify {
+		c.NotifyDSNs[name] = dsn
+		c.SetSource(notifySourceKey(name), SourceFile)
+	}
+
+	c.Containers = f.Containers
+}
+
+// applyFileValue sets *dst to the file value unless it is missing or the flag was set
+func applyFileValue[T any](c *Config, flagSet func(string) bool, name string, dst *T, val *T) {
+	if val == nil || flagSet(name) {
+		return
+	}
+	*dst = *val
+	c.SetSource(name, SourceFile)
+}
+
+// applyFileList sets *dst to the file list unless it is empty or the flag was set
+func applyFileList(c *Config, flagSet func(string) bool, name string, dst *[]string, val []string) {
+	if len(val) == 0 || flagSet(name) {
+		return
+	}
+	*dst = val
+	c.SetSource(name, SourceFile)
+}
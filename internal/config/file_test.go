@@ -0,0 +1,127 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testConfigFile = `
+poll-interval: 1m
+default-retention: 14
+default-schedule: "0 3 * * *"
+timezone: Europe/Berlin
+verify-backups: true
+
+storage:
+  local:
+    type: local
+    path: /backups
+  offsite:
+    type: s3
+    bucket: backups
+    region: eu-central-1
+
+notify:
+  telegram: telegram://token@telegram?chats=123
+
+dashboard:
+  address: ":8080"
+  auth:
+    oidc:
+      provider: google
+      client-id: my-client
+      allowed-domains: [example.com]
+`
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func noFlags(string) bool { return false }
+
+func TestLoadFile(t *testing.T) {
+	f, err := LoadFile(writeConfigFile(t, testConfigFile))
+	require.NoError(t, err)
+
+	c := New()
+	c.ApplyFile(f, noFlags)
+	require.NoError(t, c.ParseStoragePools())
+	require.NoError(t, c.ParseNotifyDSNs())
+
+	assert.Equal(t, time.Minute, c.PollInterval)
+	assert.Equal(t, 14, c.DefaultRetention)
+	assert.Equal(t, "0 3 * * *", c.DefaultSchedule)
+	assert.Equal(t, "Europe/Berlin", c.Timezone)
+	assert.True(t, c.VerifyBackups)
+	assert.Equal(t, SourceFile, c.Source("poll-interval"))
+
+	require.Len(t, c.StoragePools, 2)
+	assert.Equal(t, "s3", c.StoragePools["offsite"].Type)
+	assert.Equal(t, "backups", c.StoragePools["offsite"].Options["bucket"])
+	assert.Equal(t, SourceFile, c.Source(storageSourceKey("offsite", "bucket")))
+	assert.Equal(t, "telegram://token@telegram?chats=123", c.NotifyDSNs["telegram"])
+
+	assert.Equal(t, ":8080", c.DashboardAddr)
+	assert.Equal(t, "google", c.DashboardOIDCProvider)
+	assert.Equal(t, "my-client", c.DashboardOIDCClientID)
+	assert.Equal(t, []string{"example.com"}, c.DashboardOIDCAllowedDomains)
+}
+
+func TestLoadFile_Empty(t *testing.T) {
+	f, err := LoadFile(writeConfigFile(t, ""))
+	require.NoError(t, err)
+
+	c := New()
+	c.ApplyFile(f, noFlags)
+	assert.Equal(t, New().PollInterval, c.PollInterval)
+	assert.Equal(t, SourceDefault, c.Source("poll-interval"))
+}
+
+func TestLoadFile_Invalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"unknown key":       "poll-intervall: 1m",
+		"wrong type":        "default-retention: many",
+		"pool without type": "storage:\n  local:\n    path: /backups",
+		"empty dsn":         "notify:\n  telegram: \"\"",
+		"negative duration": "schedule-jitter: -5m",
+		"zero poll":         "poll-interval: 0s",
+		"not a mapping":     "- poll-interval",
+	} {
+		_, err := LoadFile(writeConfigFile(t, content))
+		assert.Error(t, err, name)
+	}
+
+	_, err := LoadFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestApplyFile_Overrides(t *testing.T) {
+	f, err := LoadFile(writeConfigFile(t, testConfigFile))
+	require.NoError(t, err)
+
+	t.Setenv("DOCKER_BACKUP_STORAGE_LOCAL_PATH", "/mnt/backups")
+	t.Setenv("DOCKER_BACKUP_DEFAULT_RETENTION", "30")
+	t.Setenv("DOCKER_BACKUP_DEFAULT_STORAGE", "offsite")
+
+	c := New()
+	c.PollInterval = 10 * time.Second
+	c.ApplyFile(f, func(name string) bool { return name == "poll-interval" })
+	require.NoError(t, c.LoadBackupDefaults(false, false))
+	require.NoError(t, c.ParseStoragePools())
+
+	// The flag keeps its value, the environment replaces the file's
+	assert.Equal(t, 10*time.Second, c.PollInterval)
+	assert.Equal(t, 30, c.DefaultRetention)
+	assert.Equal(t, SourceEnv, c.Source("default-retention"))
+	assert.Equal(t, "/mnt/backups", c.StoragePools["local"].Options["path"])
+	assert.Equal(t, "offsite", c.DefaultStorage)
+	assert.Equal(t, "0 3 * * *", c.DefaultSchedule)
+}
@@ -2,19 +2,47 @@ package config
 
 import (
 	"fmt"
+	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // BackupConfig represents a single named backup configuration
 type BackupConfig struct {
-	Name       string   // Config name (e.g., "db", "files")
-	BackupType string   // Required: backup type (e.g., "postgres")
-	Schedule   string   // Required: cron expression
-	Retention  int      // Optional: defaults to 7
-	Storage    string   // Optional: storage pool name
-	Notify     []string // Optional: per-config notification override
+	Name                string            // Config name (e.g., "db", "files")
+	BackupType          string            // Required: backup type (e.g., "postgres")
+	Schedule            string            // Required: cron expression
+	Timezone            string            // Optional: IANA time zone the schedule is evaluated in, empty uses the daemon's
+	Retention           int               // Optional: defaults to Defaults.Retention, 0 when RetentionMaxAge is set
+	RetentionMaxAge     time.Duration     // Optional: set by a duration retention label, deletes backups older than this
+	MaxTotalSize        int64             // Optional: deletes the oldest backups once all of them together are larger
+	MinKeep             int               // Optional: defaults to Defaults.MinKeep, backups retention never deletes
+	Storage             string            // Optional: storage pool name
+	Fallback            []string          // Optional: pools tried in order when storing to Storage fails
+	Mirrors             []string          // Optional: pools every backup is copied to in addition to the one storing it
+	MirrorMode          string            // Optional: MirrorFailFast or MirrorBestEffort, empty means MirrorFailFast
+	WhenStopped         string            // Optional: StoppedSkip, StoppedBackup or StoppedStart, empty means StoppedSkip
+	Notify              []string          // Optional: per-config notification override
+	NotifyAfterFailures int               // Optional: consecutive failures before failures are notified, 0 notifies every failure
+	NotifyOn            []string          // Optional: per-config override of the notified NotifyOn* event groups
+	PreHook             string            // Optional: shell command run in the container before the backup, failing aborts it
+	PostHook            string            // Optional: shell command run in the container after the backup, failing only warns
+	PathPrefix          string            // Optional: path prepended to the storage keys of the backups, without surrounding slashes
+	Options             map[string]string // Optional: backup type specific options
+}
+
+// DefaultRetention is the number of backups kept when neither a label nor a daemon default sets one
+const DefaultRetention = 7
+
+// Defaults are daemon-level values applied to backup configs that omit them
+type Defaults struct {
+	Retention  int    // Used when a config has no retention label, 0 means DefaultRetention
+	Schedule   string // Used when a config has no schedule label, empty makes the label required
+	MinKeep    int    // Used when a config has no min-keep label
+	PathPrefix string // Put in front of the path-prefix label of every config, e.g. the instance name
 }
 
 // ContainerConfig represents parsed labels from a container
@@ -23,12 +51,17 @@ type ContainerConfig struct {
 	ContainerName string
 	Enabled       bool
 	Notify        []string       // Shared notification providers (container-level)
+	NotifyOn      []string       // Shared notified event groups, nil means DefaultNotifyOn
 	Backups       []BackupConfig // One or more backup configurations
 }
 
-// LabelPrefix is the fixed prefix for all docker-backup labels
+// LabelPrefix is the default prefix for all docker-backup labels
 const LabelPrefix = "docker-backup"
 
+// labelPrefixPattern follows Docker's recommendation for label keys:
+// lowercase alphanumeric segments separated by dots or dashes
+var labelPrefixPattern = regexp.MustCompile(`^[a-z0-9]+([.-][a-z0-9]+)*$`)
+
 // Label suffixes (appended to LabelPrefix)
 const (
 	LabelEnable    = "enable"
@@ -37,8 +70,47 @@ const (
 	LabelRetention = "retention"
 	LabelStorage   = "storage"
 	LabelNotify    = "notify"
+
+	LabelNotifyAfterFailures = "notify-after-failures"
+	LabelMaxTotalSize        = "max-total-size"
+	LabelMinKeep             = "min-keep"
+	LabelNotifyOn            = "notify-on"
+	LabelTimezone            = "tz"
+	LabelPreHook             = "pre-hook"
+	LabelPostHook            = "post-hook"
+	LabelMirror              = "mirror"
+	LabelMirrorMode          = "mirror-mode"
+	LabelWhenStopped         = "when-stopped"
+	LabelPathPrefix          = "path-prefix"
+)
+
+// Values of the mirror-mode label
+const (
+	MirrorFailFast   = "fail-fast"   // The run fails at the first mirror that can't be written
+	MirrorBestEffort = "best-effort" // Every mirror is tried, failing ones only warn
 )
 
+// Values of the when-stopped label, see --include-stopped-containers
+const (
+	StoppedSkip   = "skip"   // Stopped containers aren't backed up
+	StoppedBackup = "backup" // Stopped containers are backed up as they are, e.g. their volumes
+	StoppedStart  = "start"  // Stopped containers are started for the backup and stopped again
+)
+
+// Event groups selected by the notify-on label
+const (
+	NotifyOnStarted   = "started"   // A backup or restore began
+	NotifyOnCompleted = "completed" // A backup or restore succeeded
+	NotifyOnFailed    = "failed"    // A backup or restore failed
+)
+
+// DefaultNotifyOn are the event groups notified without a notify-on label
+var DefaultNotifyOn = []string{NotifyOnCompleted, NotifyOnFailed}
+
+// optionsPrefix may be used to namespace backup type options explicitly,
+// e.g. docker-backup.db.options.stream=true
+const optionsPrefix = "options."
+
 // reservedProperties are property names that cannot be used as config names
 var reservedProperties = map[string]bool{
 	LabelEnable:    true,
@@ -47,10 +119,39 @@ var reservedProperties = map[string]bool{
 	LabelRetention: true,
 	LabelStorage:   true,
 	LabelNotify:    true,
+
+	LabelNotifyAfterFailures: true,
+	LabelMaxTotalSize:        true,
+	LabelMinKeep:             true,
+	LabelNotifyOn:            true,
+	LabelTimezone:            true,
+	LabelPreHook:             true,
+	LabelPostHook:            true,
+	LabelMirror:              true,
+	LabelMirrorMode:          true,
+	LabelWhenStopped:         true,
+	LabelPathPrefix:          true,
+}
+
+// ValidateLabelPrefix checks that prefix can be used as a label key prefix
+func ValidateLabelPrefix(prefix string) error {
+	if prefix == "" {
+		return fmt.Errorf("label prefix must not be empty")
+	}
+	if !labelPrefixPattern.MatchString(prefix) {
+		return fmt.Errorf("invalid label prefix %q: must consist of lowercase letters, digits, dots and dashes, and start and end with a letter or digit", prefix)
+	}
+	return nil
 }
 
 // ParseLabels extracts ContainerConfig from Docker container labels
 func ParseLabels(prefix, containerID, containerName string, labels map[string]string) (*ContainerConfig, error) {
+	return ParseLabelsWithDefaults(prefix, Defaults{}, containerID, containerName, labels)
+}
+
+// ParseLabelsWithDefaults extracts ContainerConfig from Docker container labels,
+// filling in retention and schedule from defaults where labels omit them
+func ParseLabelsWithDefaults(prefix string, defaults Defaults, containerID, containerName string, labels map[string]string) (*ContainerConfig, error) {
 	cfg := &ContainerConfig{
 		ContainerID:   containerID,
 		ContainerName: containerName,
@@ -72,21 +173,30 @@ func ParseLabels(prefix, containerID, containerName string, labels map[string]st
 
 	cfg.Notify = parseNotifyValue(labels[prefix+"."+LabelNotify])
 
-	backups, err := parseNamedConfigs(prefix, containerName, labels)
+	notifyOnKey := prefix + "." + LabelNotifyOn
+	if val, ok := labels[notifyOnKey]; ok {
+		groups, err := parseNotifyOn(val)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %w", notifyOnKey, err)
+		}
+		cfg.NotifyOn = groups
+	}
+
+	backups, err := parseNamedConfigs(prefix, containerName, labels, defaults)
 	if err != nil {
 		return nil, err
 	}
 	cfg.Backups = backups
 
 	if len(cfg.Backups) == 0 {
-		return nil, fmt.Errorf("container %s has backup enabled but no backup configurations found (use docker-backup.<name>.type=... format)", containerName)
+		return nil, fmt.Errorf("container %s has backup enabled but no backup configurations found (use %s.<name>.type=... format)", containerName, prefix)
 	}
 
 	return cfg, nil
 }
 
 // parseNamedConfigs parses named backup configurations from labels
-func parseNamedConfigs(prefix, containerName string, labels map[string]string) ([]BackupConfig, error) {
+func parseNamedConfigs(prefix, containerName string, labels map[string]string, defaults Defaults) ([]BackupConfig, error) {
 	// Group labels by config name
 	configGroups := make(map[string]map[string]string)
 
@@ -123,7 +233,7 @@ func parseNamedConfigs(prefix, containerName string, labels map[string]string) (
 	// Parse each config group
 	var backups []BackupConfig
 	for name, props := range configGroups {
-		backup, err := parseConfigGroup(name, containerName, props)
+		backup, err := parseConfigGroup(name, containerName, props, defaults)
 		if err != nil {
 			return nil, err
 		}
@@ -139,10 +249,16 @@ func parseNamedConfigs(prefix, containerName string, labels map[string]string) (
 }
 
 // parseConfigGroup parses a single named config from its properties
-func parseConfigGroup(name, containerName string, props map[string]string) (BackupConfig, error) {
+func parseConfigGroup(name, containerName string, props map[string]string, defaults Defaults) (BackupConfig, error) {
 	backup := BackupConfig{
-		Name:      name,
-		Retention: 7, // Default retention
+		Name:       name,
+		Schedule:   defaults.Schedule,
+		Retention:  defaults.Retention,
+		MinKeep:    defaults.MinKeep,
+		PathPrefix: defaults.PathPrefix,
+	}
+	if backup.Retention == 0 {
+		backup.Retention = DefaultRetention
 	}
 
 	// Parse backup type (required)
@@ -153,29 +269,121 @@ func parseConfigGroup(name, containerName string, props map[string]string) (Back
 		return backup, fmt.Errorf("container %s config %q has no backup type specified", containerName, name)
 	}
 
-	// Parse schedule (required)
-	if val, ok := props[LabelSchedule]; ok {
+	// Parse schedule (required unless a default schedule is configured)
+	if val, ok := props[LabelSchedule]; ok && strings.TrimSpace(val) != "" {
 		backup.Schedule = strings.TrimSpace(val)
 	}
 	if backup.Schedule == "" {
-		return backup, fmt.Errorf("container %s config %q has no schedule specified", containerName, name)
+		return backup, fmt.Errorf("container %s config %q has no schedule specified and no default schedule is configured", containerName, name)
+	}
+
+	// Parse the schedule's time zone (optional)
+	if val, ok := props[LabelTimezone]; ok && strings.TrimSpace(val) != "" {
+		tz := strings.TrimSpace(val)
+		if _, err := time.LoadLocation(tz); err != nil {
+			return backup, fmt.Errorf("container %s config %q has invalid %s: %w", containerName, name, LabelTimezone, err)
+		}
+		backup.Timezone = tz
 	}
 
-	// Parse retention (optional)
+	// Parse retention (optional). A count keeps the newest backups, a duration
+	// keeps the backups younger than it.
 	if val, ok := props[LabelRetention]; ok {
-		retention, err := strconv.Atoi(val)
+		val = strings.TrimSpace(val)
+		if maxAge, ok, err := parseRetentionAge(val); ok {
+			if err != nil {
+				return backup, fmt.Errorf("container %s config %q has invalid retention: %w", containerName, name, err)
+			}
+			backup.Retention = 0
+			backup.RetentionMaxAge = maxAge
+		} else {
+			retention, err := strconv.Atoi(val)
+			if err != nil {
+				return backup, fmt.Errorf("container %s config %q has invalid retention: %w", containerName, name, err)
+			}
+			if retention < 1 {
+				return backup, fmt.Errorf("container %s config %q retention must be at least 1, got %d", containerName, name, retention)
+			}
+			backup.Retention = retention
+		}
+	}
+
+	// Parse size cap (optional), it applies on top of the retention
+	if val, ok := props[LabelMaxTotalSize]; ok {
+		size, err := ParseSize(val)
+		if err != nil {
+			return backup, fmt.Errorf("container %s config %q has invalid %s: %w", containerName, name, LabelMaxTotalSize, err)
+		}
+		if size < 1 {
+			return backup, fmt.Errorf("container %s config %q %s must be greater than 0", containerName, name, LabelMaxTotalSize)
+		}
+		backup.MaxTotalSize = size
+	}
+
+	// Parse minimum number of kept backups (optional), it overrides every
+	// retention limit
+	if val, ok := props[LabelMinKeep]; ok {
+		minKeep, err := strconv.Atoi(strings.TrimSpace(val))
 		if err != nil {
-			return backup, fmt.Errorf("container %s config %q has invalid retention: %w", containerName, name, err)
+			return backup, fmt.Errorf("container %s config %q has invalid %s: %w", containerName, name, LabelMinKeep, err)
 		}
-		if retention < 1 {
-			return backup, fmt.Errorf("container %s config %q retention must be at least 1, got %d", containerName, name, retention)
+		if minKeep < 0 {
+			return backup, fmt.Errorf("container %s config %q %s must not be negative, got %d", containerName, name, LabelMinKeep, minKeep)
 		}
-		backup.Retention = retention
+		backup.MinKeep = minKeep
 	}
 
-	// Parse storage pool (optional)
+	// Parse storage pool (optional). A list is a failover chain: each backup is
+	// written to the first pool that accepts it, not to all of them.
 	if val, ok := props[LabelStorage]; ok {
-		backup.Storage = strings.TrimSpace(val)
+		pools, err := parseStorageChain(val)
+		if err != nil {
+			return backup, fmt.Errorf("container %s config %q has invalid storage: %w", containerName, name, err)
+		}
+		if len(pools) > 0 {
+			backup.Storage = pools[0]
+			backup.Fallback = pools[1:]
+		}
+	}
+
+	// Parse mirror pools (optional). Unlike the storage chain, every mirror
+	// receives a copy of each backup.
+	if val, ok := props[LabelMirror]; ok {
+		mirrors, err := parseStorageChain(val)
+		if err != nil {
+			return backup, fmt.Errorf("container %s config %q has invalid %s: %w", containerName, name, LabelMirror, err)
+		}
+		for _, mirror := range mirrors {
+			if slices.Contains(backup.StorageChain(), mirror) {
+				return backup, fmt.Errorf("container %s config %q has invalid %s: storage pool %q is already used by storage", containerName, name, LabelMirror, mirror)
+			}
+		}
+		backup.Mirrors = mirrors
+	}
+	if val, ok := props[LabelMirrorMode]; ok {
+		mode := strings.TrimSpace(val)
+		if mode != MirrorFailFast && mode != MirrorBestEffort {
+			return backup, fmt.Errorf("container %s config %q has invalid %s %q: must be %q or %q", containerName, name, LabelMirrorMode, mode, MirrorFailFast, MirrorBestEffort)
+		}
+		backup.MirrorMode = mode
+	}
+
+	if val, ok := props[LabelWhenStopped]; ok {
+		mode := strings.TrimSpace(val)
+		if mode != StoppedSkip && mode != StoppedBackup && mode != StoppedStart {
+			return backup, fmt.Errorf("container %s config %q has invalid %s %q: must be %q, %q or %q", containerName, name, LabelWhenStopped, mode, StoppedSkip, StoppedBackup, StoppedStart)
+		}
+		backup.WhenStopped = mode
+	}
+
+	if val, ok := props[LabelPathPrefix]; ok {
+		pathPrefix, err := parsePathPrefix(val)
+		if err != nil {
+			return backup, fmt.Errorf("container %s config %q has invalid %s: %w", containerName, name, LabelPathPrefix, err)
+		}
+		if pathPrefix != "" {
+			backup.PathPrefix = strings.TrimPrefix(defaults.PathPrefix+"/"+pathPrefix, "/")
+		}
 	}
 
 	// Parse per-config notify override (optional)
@@ -183,9 +391,186 @@ func parseConfigGroup(name, containerName string, props map[string]string) (Back
 		backup.Notify = parseNotifyValue(val)
 	}
 
+	// Parse failure notification threshold (optional)
+	if val, ok := props[LabelNotifyAfterFailures]; ok {
+		threshold, err := strconv.Atoi(strings.TrimSpace(val))
+		if err != nil {
+			return backup, fmt.Errorf("container %s config %q has invalid %s: %w", containerName, name, LabelNotifyAfterFailures, err)
+		}
+		if threshold < 1 {
+			return backup, fmt.Errorf("container %s config %q %s must be at least 1, got %d", containerName, name, LabelNotifyAfterFailures, threshold)
+		}
+		backup.NotifyAfterFailures = threshold
+	}
+
+	// Parse the notified event groups (optional)
+	if val, ok := props[LabelNotifyOn]; ok {
+		groups, err := parseNotifyOn(val)
+		if err != nil {
+			return backup, fmt.Errorf("container %s config %q has invalid %s: %w", containerName, name, LabelNotifyOn, err)
+		}
+		backup.NotifyOn = groups
+	}
+
+	// Parse hook commands (optional)
+	backup.PreHook = strings.TrimSpace(props[LabelPreHook])
+	backup.PostHook = strings.TrimSpace(props[LabelPostHook])
+
+	// Everything else is passed through to the backup type
+	for property, val := range props {
+		if reservedProperties[property] {
+			continue
+		}
+		if backup.Options == nil {
+			backup.Options = make(map[string]string)
+		}
+		backup.Options[strings.TrimPrefix(property, optionsPrefix)] = strings.TrimSpace(val)
+	}
+
 	return backup, nil
 }
 
+// parseStorageChain parses a comma-separated list of storage pools
+func parseStorageChain(val string) ([]string, error) {
+	if strings.TrimSpace(val) == "" {
+		return nil, nil
+	}
+
+	var pools []string
+	seen := make(map[string]bool)
+	for _, pool := range strings.Split(val, ",") {
+		pool = strings.TrimSpace(pool)
+		if pool == "" {
+			return nil, fmt.Errorf("empty storage pool name in %q", val)
+		}
+		if seen[pool] {
+			return nil, fmt.Errorf("storage pool %q listed twice", pool)
+		}
+		seen[pool] = true
+		pools = append(pools, pool)
+	}
+	return pools, nil
+}
+
+// parsePathPrefix parses a path-prefix label such as prod/myapp. Surrounding
+// slashes are dropped; segments starting with a dot are reserved for the
+// daemon's own keys like .blobs.
+func parsePathPrefix(val string) (string, error) {
+	prefix := strings.Trim(strings.TrimSpace(val), "/")
+	if prefix == "" {
+		return "", nil
+	}
+	for _, segment := range strings.Split(prefix, "/") {
+		if segment == "" {
+			return "", fmt.Errorf("empty path segment in %q", val)
+		}
+		if strings.HasPrefix(segment, ".") {
+			return "", fmt.Errorf("path segment %q in %q must not start with a dot", segment, val)
+		}
+		if strings.ContainsAny(segment, "\\*?") {
+			return "", fmt.Errorf("path segment %q in %q must not contain \\, * or ?", segment, val)
+		}
+	}
+	return prefix, nil
+}
+
+// KeyPrefix returns the path prefix of the config's storage keys including
+// the trailing slash, or "" without a path-prefix label
+func (b BackupConfig) KeyPrefix() string {
+	if b.PathPrefix == "" {
+		return ""
+	}
+	return b.PathPrefix + "/"
+}
+
+// StorageChain returns the pools a backup is written to, in the order they are
+// tried. An empty name is the default pool.
+func (b BackupConfig) StorageChain() []string {
+	return append([]string{b.Storage}, b.Fallback...)
+}
+
+// Pools returns every pool holding backups of the config: the storage chain
+// followed by the mirrors
+func (b BackupConfig) Pools() []string {
+	return append(b.StorageChain(), b.Mirrors...)
+}
+
+// CronSchedule returns the schedule as passed to the scheduler, prefixed with
+// CRON_TZ when the config sets its own time zone
+func (b BackupConfig) CronSchedule() string {
+	if b.Timezone == "" {
+		return b.Schedule
+	}
+	return "CRON_TZ=" + b.Timezone + " " + b.Schedule
+}
+
+// RunsWhenStopped reports whether the config is backed up while its container is stopped
+func (b BackupConfig) RunsWhenStopped() bool {
+	return b.WhenStopped == StoppedBackup || b.WhenStopped == StoppedStart
+}
+
+// Stopped returns a copy of c with only the backup configs that run while the
+// container is stopped
+func (c *ContainerConfig) Stopped() *ContainerConfig {
+	stopped := *c
+	stopped.Backups = nil
+	for _, b := range c.Backups {
+		if b.RunsWhenStopped() {
+			stopped.Backups = append(stopped.Backups, b)
+		}
+	}
+	return &stopped
+}
+
+// RetentionString formats the retention as a count or an age such as 90d
+func (b BackupConfig) RetentionString() string {
+	if b.RetentionMaxAge > 0 {
+		return formatRetentionAge(b.RetentionMaxAge)
+	}
+	return strconv.Itoa(b.Retention)
+}
+
+// retentionUnits are the suffixes of a duration retention, longest first so
+// "mo" isn't read as minutes
+var retentionUnits = []struct {
+	suffix string
+	unit   time.Duration
+}{
+	{"mo", 30 * 24 * time.Hour},
+	{"y", 365 * 24 * time.Hour},
+	{"w", 7 * 24 * time.Hour},
+	{"d", 24 * time.Hour},
+	{"h", time.Hour},
+}
+
+// parseRetentionAge parses a duration retention such as 90d, 12w or 6mo.
+// ok is false when val has no unit suffix and is meant as a count.
+func parseRetentionAge(val string) (maxAge time.Duration, ok bool, err error) {
+	for _, u := range retentionUnits {
+		number, found := strings.CutSuffix(val, u.suffix)
+		if !found {
+			continue
+		}
+		n, err := strconv.Atoi(number)
+		if err != nil {
+			return 0, true, fmt.Errorf("%q is not a number of %s", number, u.suffix)
+		}
+		if n < 1 {
+			return 0, true, fmt.Errorf("retention must be at least 1%s, got %s", u.suffix, val)
+		}
+		return time.Duration(n) * u.unit, true, nil
+	}
+	return 0, false, nil
+}
+
+// formatRetentionAge formats maxAge in days, or hours if it isn't whole days
+func formatRetentionAge(maxAge time.Duration) string {
+	if maxAge%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", maxAge/(24*time.Hour))
+	}
+	return fmt.Sprintf("%dh", maxAge/time.Hour)
+}
+
 // parseNotifyValue parses a comma-separated notification provider list
 func parseNotifyValue(val string) []string {
 	val = strings.TrimSpace(val)
@@ -202,3 +587,21 @@ func parseNotifyValue(val string) []string {
 	}
 	return providers
 }
+
+// parseNotifyOn parses a comma-separated list of NotifyOn* event groups
+func parseNotifyOn(val string) ([]string, error) {
+	groups := parseNotifyValue(val)
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("expected one or more of %s, %s and %s", NotifyOnStarted, NotifyOnCompleted, NotifyOnFailed)
+	}
+	for i, group := range groups {
+		group = strings.ToLower(group)
+		switch group {
+		case NotifyOnStarted, NotifyOnCompleted, NotifyOnFailed:
+			groups[i] = group
+		default:
+			return nil, fmt.Errorf("unknown event %q, expected %s, %s or %s", group, NotifyOnStarted, NotifyOnCompleted, NotifyOnFailed)
+		}
+	}
+	return groups, nil
+}
@@ -1,7 +1,9 @@
 package config
 
 import (
+	"maps"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -142,6 +144,102 @@ func TestParseLabels_MissingSchedule(t *testing.T) {
 	assert.Contains(t, err.Error(), "no schedule")
 }
 
+func TestParseLabelsWithDefaults_AppliesDefaults(t *testing.T) {
+	labels := map[string]string{
+		"docker-backup.enable":  "true",
+		"docker-backup.db.type": "postgres",
+	}
+
+	defaults := Defaults{Retention: 14, Schedule: "0 2 * * *"}
+	cfg, err := ParseLabelsWithDefaults("docker-backup", defaults, "abc123", "mycontainer", labels)
+	require.NoError(t, err)
+	require.Len(t, cfg.Backups, 1)
+	assert.Equal(t, "0 2 * * *", cfg.Backups[0].Schedule)
+	assert.Equal(t, 14, cfg.Backups[0].Retention)
+}
+
+func TestParseLabelsWithDefaults_LabelsOverrideDefaults(t *testing.T) {
+	labels := map[string]string{
+		"docker-backup.enable":       "true",
+		"docker-backup.db.type":      "postgres",
+		"docker-backup.db.schedule":  "0 3 * * *",
+		"docker-backup.db.retention": "3",
+	}
+
+	defaults := Defaults{Retention: 14, Schedule: "0 2 * * *"}
+	cfg, err := ParseLabelsWithDefaults("docker-backup", defaults, "abc123", "mycontainer", labels)
+	require.NoError(t, err)
+	assert.Equal(t, "0 3 * * *", cfg.Backups[0].Schedule)
+	assert.Equal(t, 3, cfg.Backups[0].Retention)
+}
+
+func TestParseLabelsWithDefaults_MissingScheduleWithoutDefault(t *testing.T) {
+	labels := map[string]string{
+		"docker-backup.enable":  "true",
+		"docker-backup.db.type": "postgres",
+	}
+
+	_, err := ParseLabelsWithDefaults("docker-backup", Defaults{Retention: 14}, "abc123", "mycontainer", labels)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no default schedule")
+}
+
+func TestLoadBackupDefaults(t *testing.T) {
+	t.Setenv("DOCKER_BACKUP_DEFAULT_RETENTION", "30")
+	t.Setenv("DOCKER_BACKUP_DEFAULT_SCHEDULE", "0 4 * * *")
+
+	c := New()
+	require.NoError(t, c.LoadBackupDefaults(false, false))
+	assert.Equal(t, Defaults{Retention: 30, Schedule: "0 4 * * *"}, c.BackupDefaults())
+
+	// Explicit flags win over the environment
+	c = New()
+	c.DefaultRetention = 5
+	c.DefaultSchedule = "0 1 * * *"
+	require.NoError(t, c.LoadBackupDefaults(true, true))
+	assert.Equal(t, Defaults{Retention: 5, Schedule: "0 1 * * *"}, c.BackupDefaults())
+}
+
+func TestLoadAgeEncryption(t *testing.T) {
+	t.Setenv("DOCKER_BACKUP_ENCRYPTION_AGE_RECIPIENTS", "age1a,age1b")
+	t.Setenv("DOCKER_BACKUP_ENCRYPTION_AGE_IDENTITY_FILE", "/run/secrets/age.txt")
+
+	c := New()
+	c.LoadAgeEncryption(false, false)
+	assert.Equal(t, []string{"age1a,age1b"}, c.EncryptionAgeRecipients)
+	assert.Equal(t, "/run/secrets/age.txt", c.EncryptionAgeIdentityFile)
+	assert.Equal(t, SourceEnv, c.Source("encryption-age-recipient"))
+
+	// Explicit flags win over the environment
+	c = New()
+	c.EncryptionAgeRecipients = []string{"age1c"}
+	c.LoadAgeEncryption(true, true)
+	assert.Equal(t, []string{"age1c"}, c.EncryptionAgeRecipients)
+	assert.Empty(t, c.EncryptionAgeIdentityFile)
+}
+
+func TestLoadSessionSecret(t *testing.T) {
+	t.Setenv("DOCKER_BACKUP_SESSION_SECRET", "from-env")
+
+	c := New()
+	c.LoadSessionSecret(false)
+	assert.Equal(t, "from-env", c.DashboardSessionSecret)
+	assert.Equal(t, SourceEnv, c.Source("dashboard.session-secret"))
+
+	// An explicit flag wins over the environment
+	c = New()
+	c.DashboardSessionSecret = "from-flag"
+	c.LoadSessionSecret(true)
+	assert.Equal(t, "from-flag", c.DashboardSessionSecret)
+}
+
+func TestLoadBackupDefaults_InvalidRetention(t *testing.T) {
+	t.Setenv("DOCKER_BACKUP_DEFAULT_RETENTION", "0")
+
+	err := New().LoadBackupDefaults(false, false)
+	assert.Error(t, err)
+}
+
 func TestParseLabels_InvalidRetention(t *testing.T) {
 	labels := map[string]string{
 		"docker-backup.enable":       "true",
@@ -179,6 +277,114 @@ func TestParseLabels_NegativeRetention(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestParseLabels_RetentionDuration(t *testing.T) {
+	for val, want := range map[string]time.Duration{
+		"90d": 90 * 24 * time.Hour,
+		"12w": 12 * 7 * 24 * time.Hour,
+		"6mo": 6 * 30 * 24 * time.Hour,
+		"1y":  365 * 24 * time.Hour,
+		"36h": 36 * time.Hour,
+	} {
+		labels := map[string]string{
+			"docker-backup.enable":       "true",
+			"docker-backup.db.type":      "postgres",
+			"docker-backup.db.schedule":  "0 3 * * *",
+			"docker-backup.db.retention": val,
+		}
+
+		cfg, err := ParseLabels("docker-backup", "abc123", "mycontainer", labels)
+		require.NoError(t, err, val)
+		assert.Equal(t, want, cfg.Backups[0].RetentionMaxAge, val)
+		assert.Equal(t, 0, cfg.Backups[0].Retention, "a duration replaces the default count")
+	}
+
+	for _, val := range []string{"0d", "-3w", "d", "1.5mo", "90m"} {
+		labels := map[string]string{
+			"docker-backup.enable":       "true",
+			"docker-backup.db.type":      "postgres",
+			"docker-backup.db.schedule":  "0 3 * * *",
+			"docker-backup.db.retention": val,
+		}
+
+		_, err := ParseLabels("docker-backup", "abc123", "mycontainer", labels)
+		assert.Error(t, err, val)
+	}
+}
+
+func TestParseLabels_MaxTotalSize(t *testing.T) {
+	labels := map[string]string{
+		"docker-backup.enable":            "true",
+		"docker-backup.db.type":           "postgres",
+		"docker-backup.db.schedule":       "0 3 * * *",
+		"docker-backup.db.max-total-size": "50GB",
+	}
+
+	cfg, err := ParseLabels("docker-backup", "abc123", "mycontainer", labels)
+	require.NoError(t, err)
+	assert.Equal(t, int64(50<<30), cfg.Backups[0].MaxTotalSize)
+	assert.Equal(t, DefaultRetention, cfg.Backups[0].Retention, "the size cap applies on top of the count")
+	assert.NotContains(t, cfg.Backups[0].Options, "max-total-size")
+
+	for _, val := range []string{"", "0", "lots", "50XB"} {
+		labels["docker-backup.db.max-total-size"] = val
+		_, err := ParseLabels("docker-backup", "abc123", "mycontainer", labels)
+		assert.Error(t, err, val)
+	}
+}
+
+func TestParseLabels_MinKeep(t *testing.T) {
+	labels := map[string]string{
+		"docker-backup.enable":       "true",
+		"docker-backup.db.type":      "postgres",
+		"docker-backup.db.schedule":  "0 3 * * *",
+		"docker-backup.db.retention": "30d",
+	}
+
+	cfg, err := ParseLabelsWithDefaults("docker-backup", Defaults{MinKeep: 2}, "abc123", "mycontainer", labels)
+	require.NoError(t, err)
+	assert.Equal(t, 2, cfg.Backups[0].MinKeep, "the daemon default applies without a label")
+
+	labels["docker-backup.db.min-keep"] = "5"
+	cfg, err = ParseLabelsWithDefaults("docker-backup", Defaults{MinKeep: 2}, "abc123", "mycontainer", labels)
+	require.NoError(t, err)
+	assert.Equal(t, 5, cfg.Backups[0].MinKeep)
+
+	labels["docker-backup.db.min-keep"] = "0"
+	cfg, err = ParseLabelsWithDefaults("docker-backup", Defaults{MinKeep: 2}, "abc123", "mycontainer", labels)
+	require.NoError(t, err)
+	assert.Equal(t, 0, cfg.Backups[0].MinKeep, "a label can turn the guarantee off")
+
+	for _, val := range []string{"-1", "few"} {
+		labels["docker-backup.db.min-keep"] = val
+		_, err := ParseLabels("docker-backup", "abc123", "mycontainer", labels)
+		assert.Error(t, err, val)
+	}
+}
+
+func TestLoadRetentionMinKeep(t *testing.T) {
+	t.Setenv("DOCKER_BACKUP_RETENTION_MIN_KEEP", "3")
+
+	c := New()
+	require.NoError(t, c.LoadRetentionMinKeep(false))
+	assert.Equal(t, 3, c.BackupDefaults().MinKeep)
+	assert.Equal(t, SourceEnv, c.Source("retention-min-keep"))
+
+	// An explicit flag wins over the environment
+	c = New()
+	c.RetentionMinKeep = 1
+	require.NoError(t, c.LoadRetentionMinKeep(true))
+	assert.Equal(t, 1, c.RetentionMinKeep)
+
+	t.Setenv("DOCKER_BACKUP_RETENTION_MIN_KEEP", "-1")
+	assert.Error(t, New().LoadRetentionMinKeep(false))
+}
+
+func TestBackupConfig_RetentionString(t *testing.T) {
+	assert.Equal(t, "7", BackupConfig{Retention: 7}.RetentionString())
+	assert.Equal(t, "90d", BackupConfig{RetentionMaxAge: 90 * 24 * time.Hour}.RetentionString())
+	assert.Equal(t, "36h", BackupConfig{RetentionMaxAge: 36 * time.Hour}.RetentionString())
+}
+
 func TestParseLabels_EnabledButNoConfigs(t *testing.T) {
 	labels := map[string]string{
 		"docker-backup.enable": "true",
@@ -235,6 +441,263 @@ func TestParseLabels_WhitespaceHandling(t *testing.T) {
 	assert.Equal(t, []string{"telegram", "discord"}, cfg.Notify)
 }
 
+func TestParseLabels_StorageFailoverChain(t *testing.T) {
+	labels := map[string]string{
+		"docker-backup.enable":      "true",
+		"docker-backup.db.type":     "postgres",
+		"docker-backup.db.schedule": "0 3 * * *",
+		"docker-backup.db.storage":  "s3, local",
+	}
+
+	cfg, err := ParseLabels("docker-backup", "abc123", "mycontainer", labels)
+	require.NoError(t, err)
+
+	backup := cfg.Backups[0]
+	assert.Equal(t, "s3", backup.Storage)
+	assert.Equal(t, []string{"local"}, backup.Fallback)
+	assert.Equal(t, []string{"s3", "local"}, backup.StorageChain())
+
+	for _, val := range []string{"s3,,local", "s3,s3", "s3,"} {
+		labels["docker-backup.db.storage"] = val
+		_, err := ParseLabels("docker-backup", "abc123", "mycontainer", labels)
+		assert.Error(t, err, val)
+	}
+}
+
+func TestParseLabels_Mirror(t *testing.T) {
+	labels := map[string]string{
+		"docker-backup.enable":      "true",
+		"docker-backup.db.type":     "postgres",
+		"docker-backup.db.schedule": "0 3 * * *",
+		"docker-backup.db.storage":  "local",
+		"docker-backup.db.mirror":   "s3, offsite",
+	}
+
+	cfg, err := ParseLabels("docker-backup", "abc123", "mycontainer", labels)
+	require.NoError(t, err)
+
+	backup := cfg.Backups[0]
+	assert.Equal(t, []string{"s3", "offsite"}, backup.Mirrors)
+	assert.Empty(t, backup.MirrorMode)
+	assert.Equal(t, []string{"local", "s3", "offsite"}, backup.Pools())
+	assert.Empty(t, backup.Options)
+
+	labels["docker-backup.db.mirror-mode"] = "best-effort"
+	cfg, err = ParseLabels("docker-backup", "abc123", "mycontainer", labels)
+	require.NoError(t, err)
+	assert.Equal(t, MirrorBestEffort, cfg.Backups[0].MirrorMode)
+
+	for label, val := range map[string]string{
+		"docker-backup.db.mirror":      "local",
+		"docker-backup.db.mirror-mode": "sometimes",
+	} {
+		invalid := maps.Clone(labels)
+		invalid[label] = val
+		_, err := ParseLabels("docker-backup", "abc123", "mycontainer", invalid)
+		assert.Error(t, err, val)
+	}
+}
+
+func TestParseLabels_WhenStopped(t *testing.T) {
+	labels := map[string]string{
+		"docker-backup.enable":               "true",
+		"docker-backup.db.type":              "postgres",
+		"docker-backup.db.schedule":          "0 3 * * *",
+		"docker-backup.db.when-stopped":      "start",
+		"docker-backup.files.type":           "volume",
+		"docker-backup.files.schedule":       "0 4 * * *",
+		"docker-backup.files.when-stopped":   "backup",
+		"docker-backup.logs.type":            "volume",
+		"docker-backup.logs.schedule":        "0 5 * * *",
+		"docker-backup.archive.type":         "volume",
+		"docker-backup.archive.schedule":     "0 6 * * *",
+		"docker-backup.archive.when-stopped": "skip",
+	}
+
+	cfg, err := ParseLabels("docker-backup", "abc123", "mycontainer", labels)
+	require.NoError(t, err)
+	for _, b := range cfg.Backups {
+		assert.NotContains(t, b.Options, "when-stopped", b.Name)
+		switch b.Name {
+		case "db":
+			assert.Equal(t, StoppedStart, b.WhenStopped)
+			assert.True(t, b.RunsWhenStopped())
+		case "files":
+			assert.Equal(t, StoppedBackup, b.WhenStopped)
+			assert.True(t, b.RunsWhenStopped())
+		case "logs":
+			assert.Empty(t, b.WhenStopped)
+			assert.False(t, b.RunsWhenStopped())
+		case "archive":
+			assert.False(t, b.RunsWhenStopped())
+		}
+	}
+
+	stopped := cfg.Stopped()
+	require.Len(t, stopped.Backups, 2)
+	assert.Equal(t, "db", stopped.Backups[0].Name)
+	assert.Equal(t, "files", stopped.Backups[1].Name)
+	assert.Len(t, cfg.Backups, 4)
+
+	labels["docker-backup.db.when-stopped"] = "sometimes"
+	_, err = ParseLabels("docker-backup", "abc123", "mycontainer", labels)
+	assert.ErrorContains(t, err, "when-stopped")
+}
+
+func TestParseLabels_PathPrefix(t *testing.T) {
+	labels := map[string]string{
+		"docker-backup.enable":         "true",
+		"docker-backup.db.type":        "postgres",
+		"docker-backup.db.schedule":    "0 3 * * *",
+		"docker-backup.db.path-prefix": "/prod/myapp/",
+		"docker-backup.files.type":     "volume",
+		"docker-backup.files.schedule": "0 4 * * *",
+	}
+
+	cfg, err := ParseLabels("docker-backup", "abc123", "mycontainer", labels)
+	require.NoError(t, err)
+	require.Len(t, cfg.Backups, 2)
+	assert.Equal(t, "prod/myapp", cfg.Backups[0].PathPrefix)
+	assert.Equal(t, "prod/myapp/", cfg.Backups[0].KeyPrefix())
+	assert.NotContains(t, cfg.Backups[0].Options, "path-prefix")
+	assert.Empty(t, cfg.Backups[1].PathPrefix)
+	assert.Empty(t, cfg.Backups[1].KeyPrefix())
+
+	for _, invalid := range []string{"prod//myapp", "prod/../myapp", ".blobs", "prod/*"} {
+		labels["docker-backup.db.path-prefix"] = invalid
+		_, err = ParseLabels("docker-backup", "abc123", "mycontainer", labels)
+		assert.ErrorContains(t, err, "path-prefix", invalid)
+	}
+}
+
+func TestParseLabels_NotifyAfterFailures(t *testing.T) {
+	labels := map[string]string{
+		"docker-backup.enable":                   "true",
+		"docker-backup.db.type":                  "postgres",
+		"docker-backup.db.schedule":              "0 3 * * *",
+		"docker-backup.db.notify-after-failures": "3",
+		"docker-backup.files.type":               "volume",
+		"docker-backup.files.schedule":           "0 4 * * *",
+	}
+
+	cfg, err := ParseLabels("docker-backup", "abc123", "mycontainer", labels)
+	require.NoError(t, err)
+	for _, b := range cfg.Backups {
+		switch b.Name {
+		case "db":
+			assert.Equal(t, 3, b.NotifyAfterFailures)
+			assert.NotContains(t, b.Options, "notify-after-failures")
+		case "files":
+			assert.Equal(t, 0, b.NotifyAfterFailures)
+		}
+	}
+
+	for _, val := range []string{"0", "-1", "three"} {
+		labels["docker-backup.db.notify-after-failures"] = val
+		_, err := ParseLabels("docker-backup", "abc123", "mycontainer", labels)
+		assert.Error(t, err, val)
+	}
+}
+
+func TestParseLabels_NotifyOn(t *testing.T) {
+	labels := map[string]string{
+		"docker-backup.enable":         "true",
+		"docker-backup.db.type":        "postgres",
+		"docker-backup.db.schedule":    "0 3 * * *",
+		"docker-backup.db.notify-on":   "Started, failed",
+		"docker-backup.files.type":     "volume",
+		"docker-backup.files.schedule": "0 4 * * *",
+	}
+
+	cfg, err := ParseLabels("docker-backup", "abc123", "mycontainer", labels)
+	require.NoError(t, err)
+	for _, b := range cfg.Backups {
+		switch b.Name {
+		case "db":
+			assert.Equal(t, []string{NotifyOnStarted, NotifyOnFailed}, b.NotifyOn)
+			assert.NotContains(t, b.Options, "notify-on")
+		case "files":
+			assert.Nil(t, b.NotifyOn)
+		}
+	}
+
+	for _, val := range []string{"", " , ", "failure", "completed,never"} {
+		labels["docker-backup.db.notify-on"] = val
+		_, err := ParseLabels("docker-backup", "abc123", "mycontainer", labels)
+		assert.Error(t, err, val)
+	}
+}
+
+func TestParseLabels_ContainerLevelNotifyOn(t *testing.T) {
+	labels := map[string]string{
+		"docker-backup.enable":         "true",
+		"docker-backup.notify-on":      "failed",
+		"docker-backup.db.type":        "postgres",
+		"docker-backup.db.schedule":    "0 3 * * *",
+		"docker-backup.db.notify-on":   "completed,failed",
+		"docker-backup.files.type":     "volume",
+		"docker-backup.files.schedule": "0 4 * * *",
+	}
+
+	cfg, err := ParseLabels("docker-backup", "abc123", "mycontainer", labels)
+	require.NoError(t, err)
+	assert.Equal(t, []string{NotifyOnFailed}, cfg.NotifyOn)
+	require.Len(t, cfg.Backups, 2, "notify-on is not a config name")
+	assert.Equal(t, []string{NotifyOnCompleted, NotifyOnFailed}, cfg.Backups[0].NotifyOn)
+	assert.Nil(t, cfg.Backups[1].NotifyOn)
+
+	labels["docker-backup.notify-on"] = "sometimes"
+	_, err = ParseLabels("docker-backup", "abc123", "mycontainer", labels)
+	assert.ErrorContains(t, err, "docker-backup.notify-on")
+}
+
+func TestParseLabels_Timezone(t *testing.T) {
+	labels := map[string]string{
+		"docker-backup.enable":      "true",
+		"docker-backup.db.type":     "postgres",
+		"docker-backup.db.schedule": "0 3 * * *",
+		"docker-backup.db.tz":       "Europe/Berlin",
+	}
+
+	cfg, err := ParseLabels("docker-backup", "abc123", "mycontainer", labels)
+	require.NoError(t, err)
+	require.Len(t, cfg.Backups, 1)
+	assert.Equal(t, "Europe/Berlin", cfg.Backups[0].Timezone)
+	assert.Equal(t, "CRON_TZ=Europe/Berlin 0 3 * * *", cfg.Backups[0].CronSchedule())
+	assert.Empty(t, cfg.Backups[0].Options, "tz is not passed to the backup type")
+
+	labels["docker-backup.db.tz"] = "Mars/Olympus"
+	_, err = ParseLabels("docker-backup", "abc123", "mycontainer", labels)
+	assert.ErrorContains(t, err, "invalid tz")
+}
+
+func TestBackupConfig_CronScheduleWithoutTimezone(t *testing.T) {
+	assert.Equal(t, "0 3 * * *", BackupConfig{Schedule: "0 3 * * *"}.CronSchedule())
+}
+
+func TestParseLabels_Hooks(t *testing.T) {
+	labels := map[string]string{
+		"docker-backup.enable":          "true",
+		"docker-backup.files.type":      "volume",
+		"docker-backup.files.schedule":  "0 3 * * *",
+		"docker-backup.files.pre-hook":  " php bin/console cache:clear ",
+		"docker-backup.files.post-hook": "php bin/console cache:warmup",
+	}
+
+	cfg, err := ParseLabels("docker-backup", "abc123", "mycontainer", labels)
+	require.NoError(t, err)
+	require.Len(t, cfg.Backups, 1)
+
+	backup := cfg.Backups[0]
+	assert.Equal(t, "php bin/console cache:clear", backup.PreHook)
+	assert.Equal(t, "php bin/console cache:warmup", backup.PostHook)
+	assert.Empty(t, backup.Options, "hooks are not passed to the backup type")
+}
+
+func TestBackupConfig_StorageChainDefault(t *testing.T) {
+	assert.Equal(t, []string{""}, BackupConfig{}.StorageChain())
+}
+
 func TestParseNotifyValue(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -271,3 +734,87 @@ func TestParseLabels_ContainerInfo(t *testing.T) {
 	assert.Equal(t, "abc123def456", cfg.ContainerID)
 	assert.Equal(t, "my-postgres-container", cfg.ContainerName)
 }
+
+func TestValidateLabelPrefix(t *testing.T) {
+	valid := []string{
+		"docker-backup",
+		"docker-backup-staging",
+		"com.example.backup",
+		"backup2",
+	}
+	for _, prefix := range valid {
+		assert.NoError(t, ValidateLabelPrefix(prefix), prefix)
+	}
+
+	invalid := []string{
+		"",
+		"Docker-Backup",
+		"docker-backup.",
+		".docker-backup",
+		"-backup",
+		"docker--backup",
+		"docker backup",
+		"docker_backup",
+	}
+	for _, prefix := range invalid {
+		assert.Error(t, ValidateLabelPrefix(prefix), prefix)
+	}
+}
+
+func TestParseLabels_CustomPrefixIgnoresDefaultLabels(t *testing.T) {
+	labels := map[string]string{
+		"docker-backup.enable":      "true",
+		"docker-backup.db.type":     "postgres",
+		"docker-backup.db.schedule": "0 3 * * *",
+	}
+
+	cfg, err := ParseLabels("docker-backup-staging", "abc123", "mycontainer", labels)
+	require.NoError(t, err)
+	assert.False(t, cfg.Enabled)
+	assert.Empty(t, cfg.Backups)
+}
+
+func TestParseLabels_TwoPrefixesDoNotCrossSchedule(t *testing.T) {
+	labels := map[string]string{
+		"docker-backup.enable":                 "true",
+		"docker-backup.db.type":                "postgres",
+		"docker-backup.db.schedule":            "0 3 * * *",
+		"docker-backup-staging.enable":         "true",
+		"docker-backup-staging.files.type":     "volume",
+		"docker-backup-staging.files.schedule": "0 4 * * *",
+	}
+
+	prod, err := ParseLabels("docker-backup", "abc123", "mycontainer", labels)
+	require.NoError(t, err)
+	require.Len(t, prod.Backups, 1)
+	assert.Equal(t, "db", prod.Backups[0].Name)
+	assert.Equal(t, "postgres", prod.Backups[0].BackupType)
+
+	staging, err := ParseLabels("docker-backup-staging", "abc123", "mycontainer", labels)
+	require.NoError(t, err)
+	require.Len(t, staging.Backups, 1)
+	assert.Equal(t, "files", staging.Backups[0].Name)
+	assert.Equal(t, "volume", staging.Backups[0].BackupType)
+}
+
+func TestParseLabels_Options(t *testing.T) {
+	labels := map[string]string{
+		"docker-backup.enable":               "true",
+		"docker-backup.db.type":              "postgres",
+		"docker-backup.db.schedule":          "0 3 * * *",
+		"docker-backup.db.stream":            "true",
+		"docker-backup.db.options.databases": "app, billing",
+		"docker-backup.files.type":           "volume",
+		"docker-backup.files.schedule":       "0 4 * * *",
+	}
+
+	cfg, err := ParseLabels("docker-backup", "abc123", "mycontainer", labels)
+	require.NoError(t, err)
+	require.Len(t, cfg.Backups, 2)
+
+	assert.Equal(t, map[string]string{
+		"stream":    "true",
+		"databases": "app, billing",
+	}, cfg.Backups[0].Options)
+	assert.Nil(t, cfg.Backups[1].Options)
+}
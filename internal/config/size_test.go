@@ -0,0 +1,48 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"1024", 1024},
+		{"100B", 100},
+		{"1KB", 1024},
+		{"1k", 1024},
+		{"512MB", 512 << 20},
+		{"10 GiB", 10 << 30},
+		{"1.5GB", 3 << 29},
+		{"2TB", 2 << 40},
+		{" 5 mb ", 5 << 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			size, err := ParseSize(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, size)
+		})
+	}
+}
+
+func TestParseSize_Invalid(t *testing.T) {
+	for _, input := range []string{"", "GB", "10XB", "-1GB", "1..5MB"} {
+		t.Run(input, func(t *testing.T) {
+			_, err := ParseSize(input)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	assert.Equal(t, "512 B", FormatSize(512))
+	assert.Equal(t, "1.0 KB", FormatSize(1024))
+	assert.Equal(t, "1.5 GB", FormatSize(3<<29))
+}
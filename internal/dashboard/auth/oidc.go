@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
+	"net/http"
 	"strings"
 
 	"github.com/coreos/go-oidc/v3/oidc"
@@ -30,6 +31,10 @@ type OIDCConfig struct {
 	Scopes         []string
 	AllowedUsers   []string
 	AllowedDomains []string
+	// SecureCookies marks cookies Secure even if RedirectURL isn't https, e.g. when the dashboard serves TLS itself
+	SecureCookies bool
+	// HTTPClient is used for discovery, token exchange and user lookups; http.DefaultClient if nil
+	HTTPClient *http.Client
 }
 
 // OIDCAuth handles OIDC authentication
@@ -41,6 +46,7 @@ type OIDCAuth struct {
 	allowedDomains []string
 	providerType   string // "google", "github", "oidc"
 	secureCookies  bool
+	httpClient     *http.Client
 }
 
 // NewOIDCAuth creates a new OIDC authenticator
@@ -49,7 +55,11 @@ func NewOIDCAuth(ctx context.Context, cfg OIDCConfig) (*OIDCAuth, error) {
 		providerType:   cfg.Provider,
 		allowedDomains: cfg.AllowedDomains,
 		allowedUsers:   make(map[string]bool),
-		secureCookies:  strings.HasPrefix(cfg.RedirectURL, "https://"),
+		secureCookies:  cfg.SecureCookies || strings.HasPrefix(cfg.RedirectURL, "https://"),
+		httpClient:     cfg.HTTPClient,
+	}
+	if auth.httpClient == nil {
+		auth.httpClient = http.DefaultClient
 	}
 
 	// Build allowed users map
@@ -90,8 +100,9 @@ func NewOIDCAuth(ctx context.Context, cfg OIDCConfig) (*OIDCAuth, error) {
 		return nil, fmt.Errorf("unknown OIDC provider: %s (supported: google, github, oidc)", cfg.Provider)
 	}
 
-	// Initialize OIDC provider (for google and generic)
-	provider, err := oidc.NewProvider(ctx, issuerURL)
+	// Initialize OIDC provider (for google and generic). The provider keeps the
+	// client for fetching signing keys later.
+	provider, err := oidc.NewProvider(auth.clientContext(ctx), issuerURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create OIDC provider: %w", err)
 	}
@@ -112,6 +123,11 @@ func NewOIDCAuth(ctx context.Context, cfg OIDCConfig) (*OIDCAuth, error) {
 	return auth, nil
 }
 
+// clientContext makes the oidc and oauth2 packages use the configured HTTP client
+func (a *OIDCAuth) clientContext(ctx context.Context) context.Context {
+	return oidc.ClientContext(ctx, a.httpClient)
+}
+
 // ProviderType returns the provider type
 func (a *OIDCAuth) ProviderType() string {
 	return a.providerType
@@ -66,7 +66,7 @@ func (a *OIDCAuth) handleLogin(c *gin.Context) {
 // handleCallback processes the OIDC callback
 func (a *OIDCAuth) handleCallback(c *gin.Context) {
 	session := sessions.Default(c)
-	ctx := c.Request.Context()
+	ctx := a.clientContext(c.Request.Context())
 
 	// Verify state
 	expectedState := session.Get(SessionKeyOIDCState)
@@ -196,7 +196,7 @@ func (a *OIDCAuth) fetchGitHubEmail(ctx context.Context, accessToken string) (st
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 	req.Header.Set("Accept", "application/vnd.github+json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := a.httpClient.Do(req)
 	if err != nil {
 		return "", err
 	}
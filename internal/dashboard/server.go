@@ -6,19 +6,31 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"mime/multipart"
+	"net"
 	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/gzip"
+	"github.com/shyim/docker-backup/internal/audit"
 	"github.com/shyim/docker-backup/internal/backup"
 	"github.com/shyim/docker-backup/internal/config"
 	"github.com/shyim/docker-backup/internal/dashboard/auth"
 	"github.com/shyim/docker-backup/internal/dashboard/static"
 	"github.com/shyim/docker-backup/internal/dashboard/templates"
+	"github.com/shyim/docker-backup/internal/encryption"
+	"github.com/shyim/docker-backup/internal/metrics"
 	"github.com/shyim/docker-backup/internal/notification"
+	"github.com/shyim/docker-backup/internal/progress"
 	"github.com/shyim/docker-backup/internal/scheduler"
 	"github.com/shyim/docker-backup/internal/storage"
 )
@@ -26,6 +38,7 @@ import (
 // Server represents the dashboard HTTP server
 type Server struct {
 	server      *http.Server
+	redirect    *http.Server // plain HTTP server redirecting to HTTPS, if configured
 	addr        string
 	backupMgr   *backup.Manager
 	poolManager *storage.PoolManager
@@ -34,6 +47,9 @@ type Server struct {
 	config      *config.Config
 }
 
+// minSessionSecretLen is the session secret length below which a warning is logged
+const minSessionSecretLen = 32
+
 var flashMessages = map[string]string{
 	"backup_success":  "Backup completed successfully for {0}",
 	"backup_failed":   "Backup failed for {0}",
@@ -41,10 +57,12 @@ var flashMessages = map[string]string{
 	"delete_failed":   "Failed to delete backup",
 	"restore_success": "Backup restored successfully for {0}",
 	"restore_failed":  "Failed to restore backup for {0}",
+	"latest_changed":  "A newer backup of {0} was created since the page was loaded. Check the key and try again.",
+	"upload_invalid":  "Choose a backup config and a file, and confirm the restore",
 }
 
 // NewServer creates a new dashboard server
-func NewServer(addr string, backupMgr *backup.Manager, poolManager *storage.PoolManager, sched *scheduler.Scheduler, notifyMgr *notification.Manager, cfg *config.Config) *Server {
+func NewServer(addr string, backupMgr *backup.Manager, poolManager *storage.PoolManager, sched *scheduler.Scheduler, notifyMgr *notification.Manager, httpClient *http.Client, cfg *config.Config) *Server {
 	gin.SetMode(gin.ReleaseMode)
 
 	s := &Server{
@@ -63,17 +81,25 @@ func NewServer(addr string, backupMgr *backup.Manager, poolManager *storage.Pool
 	var sessionKey []byte
 	if cfg.DashboardSessionSecret != "" {
 		sessionKey = []byte(cfg.DashboardSessionSecret)
+		if len(sessionKey) < minSessionSecretLen {
+			slog.Warn("dashboard session secret is short, use at least 32 random characters", "length", len(sessionKey))
+		}
 	} else {
 		sessionKey = make([]byte, 32)
 		if _, err := rand.Read(sessionKey); err != nil {
 			return nil
 		}
-		slog.Warn("no session secret configured, using random key (sessions won't survive restarts). Set DOCKER_BACKUP_SESSION_SECRET to fix this.")
+		slog.Warn("no dashboard session secret configured, using a random key: sessions won't survive restarts. Set --dashboard.session-secret or DOCKER_BACKUP_SESSION_SECRET to keep them.")
 	}
+	// Cookies are only sent over HTTPS when the dashboard serves TLS itself or
+	// OIDC redirects to an https URL, i.e. a TLS-terminating proxy is in front
+	tlsEnabled := cfg.DashboardTLSCert != ""
+	secureCookies := tlsEnabled || (cfg.DashboardOIDCProvider != "" && strings.HasPrefix(cfg.DashboardOIDCRedirectURL, "https://"))
 	store := cookie.NewStore(sessionKey)
 	store.Options(sessions.Options{
 		Path:     "/",
 		HttpOnly: true,
+		Secure:   secureCookies,
 		SameSite: http.SameSiteLaxMode,
 	})
 	router.Use(sessions.Sessions("docker_backup", store))
@@ -88,6 +114,8 @@ func NewServer(addr string, backupMgr *backup.Manager, poolManager *storage.Pool
 			RedirectURL:    cfg.DashboardOIDCRedirectURL,
 			AllowedUsers:   cfg.DashboardOIDCAllowedUsers,
 			AllowedDomains: cfg.DashboardOIDCAllowedDomains,
+			SecureCookies:  tlsEnabled,
+			HTTPClient:     httpClient,
 		})
 		if err != nil {
 			slog.Error("failed to initialize OIDC auth", "error", err)
@@ -128,11 +156,20 @@ func NewServer(addr string, backupMgr *backup.Manager, poolManager *storage.Pool
 
 	// Routes
 	router.GET("/", s.handleIndex)
-	router.GET("/backups", s.handleBackups)
-	router.POST("/api/backup/trigger", s.handleTriggerBackup)
-	router.GET("/api/backup/download", s.handleDownloadBackup)
-	router.POST("/api/backup/delete", s.handleDeleteBackup)
-	router.POST("/api/backup/restore", s.handleRestoreBackup)
+	router.GET("/metrics", gin.WrapH(metrics.Default.Handler()))
+
+	// Container-scoped routes need the initial container sync to have completed
+	scoped := router.Group("/", s.requireReady, auditActor)
+	scoped.GET("/backups", s.handleBackups)
+	scoped.POST("/api/backup/trigger", s.handleTriggerBackup)
+	scoped.GET("/api/backup/download", s.handleDownloadBackup)
+	scoped.POST("/api/backup/delete", s.handleDeleteBackup)
+	scoped.POST("/api/backup/restore", s.handleRestoreBackup)
+	scoped.POST("/api/backup/restore-latest", s.handleRestoreLatest)
+	scoped.POST("/api/backup/restore-upload", s.handleRestoreUpload)
+	scoped.GET("/api/progress", s.handleProgress)
+	scoped.GET("/api/jobs", s.handleJobs)
+	scoped.GET("/api/backup/diff", s.handleDiffBackups)
 
 	s.server = &http.Server{
 		Addr:         addr,
@@ -141,13 +178,39 @@ func NewServer(addr string, backupMgr *backup.Manager, poolManager *storage.Pool
 		WriteTimeout: 120 * time.Second,
 	}
 
+	if tlsEnabled && cfg.DashboardHTTPRedirect != "" {
+		s.redirect = &http.Server{
+			Addr:         cfg.DashboardHTTPRedirect,
+			Handler:      httpsRedirect(addr),
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+		}
+	}
+
 	return s
 }
 
-// Start starts the dashboard server
+// Start starts the dashboard server, over HTTPS if a certificate is configured
 func (s *Server) Start() error {
-	slog.Info("starting dashboard server", "addr", s.addr)
-	if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	if s.config.DashboardTLSCert == "" {
+		slog.Info("starting dashboard server", "addr", s.addr)
+		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			return fmt.Errorf("dashboard server error: %w", err)
+		}
+		return nil
+	}
+
+	if s.redirect != nil {
+		go func() {
+			slog.Info("redirecting HTTP to the dashboard", "addr", s.redirect.Addr)
+			if err := s.redirect.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Error("dashboard redirect server error", "error", err)
+			}
+		}()
+	}
+
+	slog.Info("starting dashboard server", "addr", s.addr, "tls", true)
+	if err := s.server.ListenAndServeTLS(s.config.DashboardTLSCert, s.config.DashboardTLSKey); err != nil && err != http.ErrServerClosed {
 		return fmt.Errorf("dashboard server error: %w", err)
 	}
 	return nil
@@ -155,9 +218,53 @@ func (s *Server) Start() error {
 
 // Shutdown gracefully shuts down the dashboard server
 func (s *Server) Shutdown(ctx context.Context) error {
+	if s.redirect != nil {
+		if err := s.redirect.Shutdown(ctx); err != nil {
+			slog.Warn("dashboard redirect server shutdown error", "error", err)
+		}
+	}
 	return s.server.Shutdown(ctx)
 }
 
+// httpsRedirect redirects requests to the same host and path over HTTPS on
+// the port of the dashboard address
+func httpsRedirect(dashboardAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(dashboardAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		target := url.URL{Scheme: "https", Host: host, Path: r.URL.Path, RawQuery: r.URL.RawQuery}
+		http.Redirect(w, r, target.String(), http.StatusMovedPermanently)
+	})
+}
+
+// requireReady responds with 503 until the backup manager has finished its initial sync
+func (s *Server) requireReady(c *gin.Context) {
+	if !s.backupMgr.IsReady() {
+		c.Header("Retry-After", "1")
+		c.String(http.StatusServiceUnavailable, "docker-backup is starting up, please try again shortly")
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// auditActor records the operations of a request in the audit log as done by
+// the signed-in dashboard user
+func auditActor(c *gin.Context) {
+	actor := "dashboard"
+	if user := c.GetString("user"); user != "" {
+		actor += ":" + user
+	}
+	c.Request = c.Request.WithContext(audit.WithActor(c.Request.Context(), actor))
+	c.Next()
+}
+
 func setFlash(c *gin.Context, flashType, msgKey string, params ...string) {
 	session := sessions.Default(c)
 	session.AddFlash(flashType, "flash_type")
@@ -212,6 +319,12 @@ func (s *Server) handleIndex(c *gin.Context) {
 	containers := s.backupMgr.GetContainers()
 	jobs := s.scheduler.ListJobs()
 
+	statuses := make(map[string]backup.JobStatus)
+	for _, status := range s.backupMgr.Jobs() {
+		statuses[status.ContainerName+"/"+status.ConfigName] = status
+	}
+	idleState := string(backup.JobIdle)
+
 	data := templates.IndexData{
 		ContainerCount: len(containers),
 		JobCount:       len(jobs),
@@ -247,14 +360,23 @@ func (s *Server) handleIndex(c *gin.Context) {
 				nextRun = job.NextRun.Format("2006-01-02 15:04:05")
 			}
 
-			containerInfo.Backups = append(containerInfo.Backups, templates.BackupConfigInfo{
+			info := templates.BackupConfigInfo{
 				Name:       backup.Name,
 				BackupType: backup.BackupType,
 				Schedule:   backup.Schedule,
 				Retention:  backup.Retention,
-				Storage:    backup.Storage,
+				Storage:    s.poolManager.PoolName(backup.Storage),
+				Fallback:   backup.Fallback,
+				Mirrors:    backup.Mirrors,
 				NextRun:    nextRun,
-			})
+				State:      idleState,
+			}
+			if status, ok := statuses[cont.ContainerName+"/"+backup.Name]; ok {
+				info.State = string(status.State)
+				info.Detail = jobDetail(status)
+			}
+
+			containerInfo.Backups = append(containerInfo.Backups, info)
 		}
 
 		data.Containers = append(data.Containers, containerInfo)
@@ -282,28 +404,92 @@ func (s *Server) handleBackups(c *gin.Context) {
 		return
 	}
 
+	configFilter := c.Query("config")
+	page, perPage := backupsPage(c)
+
 	data := templates.BackupsData{
 		ContainerName: containerName,
 		ConfigNames:   make([]string, 0),
 		BackupGroups:  make(map[string][]templates.BackupInfo),
+		ConfigFilter:  configFilter,
+		Pagination:    templates.Pagination{Page: page, PerPage: perPage},
+		LatestKeys:    make(map[string]string),
 		Flash:         getFlash(c),
 	}
 
-	// Group backups by config name (extracted from key: container/config/date/time.ext)
-	configOrder := make(map[string]int)
+	var pathPrefixes []string
+	for _, cont := range s.backupMgr.GetContainers() {
+		if cont.ContainerName == containerName {
+			for _, b := range cont.Backups {
+				data.RestoreConfigs = append(data.RestoreConfigs, b.Name)
+				pathPrefixes = append(pathPrefixes, b.PathPrefix)
+			}
+		}
+	}
+
+	// Group backups by config name (extracted from key: [prefix/]container/config/date/time.ext)
+	groupFiles := make(map[string][]storage.BackupFile)
 	for _, b := range backups {
-		configName := extractConfigName(b.Key)
+		configName := extractConfigName(b.Key, pathPrefixes)
+		if _, exists := groupFiles[configName]; !exists {
+			data.AllConfigs = append(data.AllConfigs, configName)
+		}
+		groupFiles[configName] = append(groupFiles[configName], b)
+	}
 
-		if _, exists := configOrder[configName]; !exists {
-			configOrder[configName] = len(data.ConfigNames)
-			data.ConfigNames = append(data.ConfigNames, configName)
+	// Every config is paginated on its own, so its header and actions stay
+	// on every page
+	hasMore := false
+	for _, configName := range data.AllConfigs {
+		files := groupFiles[configName]
+		if latest, ok := backup.NewestBackup(files); ok {
+			data.LatestKeys[configName] = latest.Key
+		}
+		if configFilter != "" && configName != configFilter {
+			continue
 		}
 
-		data.BackupGroups[configName] = append(data.BackupGroups[configName], templates.BackupInfo{
-			Key:          b.Key,
-			ConfigName:   configName,
-			Size:         formatSize(b.Size),
-			LastModified: b.LastModified.Format("2006-01-02 15:04:05"),
+		pageFiles, more := pageBackups(files, page, perPage)
+		hasMore = hasMore || more
+		data.ConfigNames = append(data.ConfigNames, configName)
+		data.BackupGroups[configName] = make([]templates.BackupInfo, 0, len(pageFiles))
+		for _, b := range pageFiles {
+			data.BackupGroups[configName] = append(data.BackupGroups[configName], templates.BackupInfo{
+				Key:          b.Key,
+				ConfigName:   configName,
+				Size:         config.FormatSize(b.Size),
+				LastModified: b.LastModified.Format("2006-01-02 15:04:05"),
+			})
+		}
+	}
+	if page > 1 {
+		data.Pagination.PrevURL = backupsPageURL(containerName, configFilter, page-1, perPage)
+	}
+	if hasMore {
+		data.Pagination.NextURL = backupsPageURL(containerName, configFilter, page+1, perPage)
+	}
+
+	failures, err := s.backupMgr.Failures(c.Request.Context(), containerName)
+	if err != nil {
+		slog.Warn("failed to load failure history", "container", containerName, "error", err)
+	}
+	type failure struct {
+		configName string
+		record     backup.FailureRecord
+	}
+	var recent []failure
+	for configName, history := range failures {
+		for _, record := range history {
+			recent = append(recent, failure{configName: configName, record: record})
+		}
+	}
+	sort.Slice(recent, func(i, j int) bool { return recent[i].record.Time.After(recent[j].record.Time) })
+	for _, f := range recent {
+		data.Failures = append(data.Failures, templates.FailureInfo{
+			ConfigName: f.configName,
+			Time:       f.record.Time.Format("2006-01-02 15:04:05"),
+			Category:   string(f.record.Category),
+			Error:      f.record.Error,
 		})
 	}
 
@@ -314,9 +500,74 @@ func (s *Server) handleBackups(c *gin.Context) {
 	}
 }
 
+const (
+	// defaultBackupsPerPage is the number of backups shown per config and page
+	defaultBackupsPerPage = 50
+	// maxBackupsPerPage caps the per_page query parameter
+	maxBackupsPerPage = 500
+)
+
+// backupsPage returns the page and per_page query parameters, falling back to
+// the first page and the default size for missing or invalid values
+func backupsPage(c *gin.Context) (page, perPage int) {
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	perPage, err = strconv.Atoi(c.Query("per_page"))
+	if err != nil || perPage < 1 {
+		perPage = defaultBackupsPerPage
+	}
+	return page, min(perPage, maxBackupsPerPage)
+}
+
+// pageBackups returns the given page of a config's backups, newest first, and
+// whether more backups follow. The page is cut from the full listing: paged
+// storage listings come oldest key first, so they can't serve the newest
+// backups without reading everything, and retention keeps the listing short.
+func pageBackups(files []storage.BackupFile, page, perPage int) ([]storage.BackupFile, bool) {
+	sorted := slices.Clone(files)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].LastModified.Equal(sorted[j].LastModified) {
+			return sorted[i].LastModified.After(sorted[j].LastModified)
+		}
+		return sorted[i].Key > sorted[j].Key
+	})
+
+	start := (page - 1) * perPage
+	if start >= len(sorted) {
+		return nil, false
+	}
+	end := min(start+perPage, len(sorted))
+	return sorted[start:end], end < len(sorted)
+}
+
+// backupsPageURL returns the URL of a page of the backups page
+func backupsPageURL(containerName, configFilter string, page, perPage int) string {
+	query := url.Values{"container": {containerName}, "page": {strconv.Itoa(page)}}
+	if configFilter != "" {
+		query.Set("config", configFilter)
+	}
+	if perPage != defaultBackupsPerPage {
+		query.Set("per_page", strconv.Itoa(perPage))
+	}
+	return "/backups?" + query.Encode()
+}
+
 // extractConfigName extracts the config name from a backup key
-// Key format: container-name/config-name/YYYY-MM-DD/HHMMSS.ext
-func extractConfigName(key string) string {
+// Key format: [path-prefix/]container-name/config-name/YYYY-MM-DD/HHMMSS.ext
+// The longest of the container's path prefixes that key starts with is skipped.
+func extractConfigName(key string, pathPrefixes []string) string {
+	longest := ""
+	for _, prefix := range pathPrefixes {
+		if prefix != "" && len(prefix) > len(longest) && strings.HasPrefix(key, prefix+"/") {
+			longest = prefix
+		}
+	}
+	if longest != "" {
+		key = strings.TrimPrefix(key, longest+"/")
+	}
+
 	parts := strings.Split(key, "/")
 	if len(parts) >= 2 {
 		return parts[1]
@@ -390,7 +641,7 @@ func (s *Server) handleRestoreBackup(c *gin.Context) {
 	}
 
 	// Restore the backup
-	err := s.backupMgr.RestoreBackup(c.Request.Context(), containerName, backupKey)
+	err := s.backupMgr.RestoreBackup(c.Request.Context(), containerName, backupKey, nil)
 
 	// Redirect back to backups page with flash message
 	redirectURL := fmt.Sprintf("/backups?container=%s", containerName)
@@ -404,7 +655,213 @@ func (s *Server) handleRestoreBackup(c *gin.Context) {
 	c.Redirect(http.StatusSeeOther, redirectURL)
 }
 
+// handleRestoreLatest restores the newest backup of a config. The key shown in the
+// confirmation is sent along, so a backup created after the page was loaded
+// is not restored without the user seeing it first.
+func (s *Server) handleRestoreLatest(c *gin.Context) {
+	containerName := c.Query("container")
+	configName := c.Query("config")
+	expectedKey := c.Query("key")
+
+	if containerName == "" || configName == "" || expectedKey == "" {
+		c.String(http.StatusBadRequest, "container, config and key parameters required")
+		return
+	}
+
+	redirectURL := fmt.Sprintf("/backups?container=%s", containerName)
+
+	latest, err := s.backupMgr.LatestBackup(c.Request.Context(), containerName, configName)
+	if err != nil {
+		slog.Error("failed to resolve latest backup", "container", containerName, "config", configName, "error", err)
+		setFlash(c, "error", "restore_failed", containerName)
+		c.Redirect(http.StatusSeeOther, redirectURL)
+		return
+	}
+	if latest.Key != expectedKey {
+		slog.Warn("latest backup changed before restore", "container", containerName, "confirmed", expectedKey, "latest", latest.Key)
+		setFlash(c, "error", "latest_changed", configName)
+		c.Redirect(http.StatusSeeOther, redirectURL)
+		return
+	}
+
+	err = s.backupMgr.RestoreBackup(c.Request.Context(), containerName, latest.Key, nil)
+	if err != nil {
+		slog.Error("failed to restore backup", "container", containerName, "key", latest.Key, "error", err)
+		setFlash(c, "error", "restore_failed", containerName)
+	} else {
+		setFlash(c, "success", "restore_success", containerName)
+	}
+
+	c.Redirect(http.StatusSeeOther, redirectURL)
+}
+
+// maxUploadField limits the size of the form fields sent before the uploaded file
+const maxUploadField = 1024
+
+// handleRestoreUpload restores a container from an uploaded backup file. The
+// multipart body is streamed into the restore, so the form fields have to come
+// before the file.
+func (s *Server) handleRestoreUpload(c *gin.Context) {
+	containerName := c.Query("container")
+	if containerName == "" {
+		c.String(http.StatusBadRequest, "container parameter required")
+		return
+	}
+
+	redirectURL := fmt.Sprintf("/backups?container=%s", containerName)
+
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		c.String(http.StatusBadRequest, "multipart form required")
+		return
+	}
+	fields, file, err := readUpload(reader)
+	if err != nil || fields["confirm"] != "yes" || fields["config"] == "" {
+		slog.Warn("invalid restore upload", "container", containerName, "error", err)
+		setFlash(c, "error", "upload_invalid")
+		c.Redirect(http.StatusSeeOther, redirectURL)
+		return
+	}
+
+	// Uploading and restoring a large backup takes longer than the server's timeouts
+	rc := http.NewResponseController(c.Writer)
+	_ = rc.SetReadDeadline(time.Time{})
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	err = s.backupMgr.RestoreFromReader(c.Request.Context(), containerName, fields["config"], file.FileName(), file)
+	if err != nil {
+		slog.Error("failed to restore uploaded backup", "container", containerName, "file", file.FileName(), "error", err)
+		setFlash(c, "error", "restore_failed", containerName)
+	} else {
+		setFlash(c, "success", "restore_success", containerName)
+	}
+
+	c.Redirect(http.StatusSeeOther, redirectURL)
+}
+
+// readUpload reads the form fields of an upload up to the file part, which is
+// returned unread
+func readUpload(reader *multipart.Reader) (map[string]string, *multipart.Part, error) {
+	fields := make(map[string]string)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, nil, fmt.Errorf("no file uploaded")
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if part.FormName() == "file" {
+			if part.FileName() == "" {
+				return nil, nil, fmt.Errorf("no file uploaded")
+			}
+			return fields, part, nil
+		}
+
+		value, err := io.ReadAll(io.LimitReader(part, maxUploadField+1))
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(value) > maxUploadField {
+			return nil, nil, fmt.Errorf("form field %q is too large", part.FormName())
+		}
+		fields[part.FormName()] = string(value)
+	}
+}
+
+// progressInfo is a running operation as reported to the dashboard
+type progressInfo struct {
+	progress.Snapshot
+	Size    string  `json:"size"`
+	Percent float64 `json:"percent"` // -1 when the total size is unknown
+}
+
+// handleProgress returns the progress of running backups and restores,
+// optionally filtered by container
+func (s *Server) handleProgress(c *gin.Context) {
+	containerName := c.Query("container")
+
+	operations := make([]progressInfo, 0)
+	for _, snap := range s.backupMgr.Progress() {
+		if containerName != "" && snap.ContainerName != containerName {
+			continue
+		}
+		operations = append(operations, progressInfo{
+			Snapshot: snap,
+			Size:     config.FormatSize(snap.Bytes),
+			Percent:  snap.Percent(),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"operations": operations})
+}
+
+// jobInfo is a job status enriched with a display-ready description
+type jobInfo struct {
+	backup.JobStatus
+	Detail string `json:"detail"`
+	Next   string `json:"next"` // Next run formatted like the index page
+}
+
+// handleJobs returns the status of every scheduled backup config
+func (s *Server) handleJobs(c *gin.Context) {
+	jobs := make([]jobInfo, 0)
+	for _, status := range s.backupMgr.Jobs() {
+		info := jobInfo{
+			JobStatus: status,
+			Detail:    jobDetail(status),
+		}
+		if status.NextRun != nil {
+			info.Next = status.NextRun.Format("2006-01-02 15:04:05")
+		}
+		jobs = append(jobs, info)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs})
+}
+
+// jobDetail describes a job's state in a few words for the dashboard
+func jobDetail(status backup.JobStatus) string {
+	switch status.State {
+	case backup.JobQueued:
+		return "waiting since " + status.QueuedSince.Format("15:04:05")
+	case backup.JobRunning:
+		detail := config.FormatSize(status.Progress.Bytes)
+		if percent := status.Progress.Percent(); percent >= 0 {
+			detail += fmt.Sprintf(" (%.0f%%)", percent)
+		}
+		return detail
+	case backup.JobSucceeded:
+		return fmt.Sprintf("%s in %s", config.FormatSize(status.LastResult.Size), status.LastResult.Duration.Round(time.Second))
+	case backup.JobFailed:
+		return status.LastResult.Error
+	default:
+		return ""
+	}
+}
+
 // handleDownloadBackup downloads a backup file
+func (s *Server) handleDiffBackups(c *gin.Context) {
+	containerName := c.Query("container")
+	fromKey := c.Query("from")
+	toKey := c.Query("to")
+
+	if containerName == "" || fromKey == "" || toKey == "" {
+		c.String(http.StatusBadRequest, "container, from and to parameters required")
+		return
+	}
+
+	diff, err := s.backupMgr.DiffBackups(c.Request.Context(), containerName, fromKey, toKey)
+	if err != nil {
+		slog.Error("failed to diff backups", "container", containerName, "from", fromKey, "to", toKey, "error", err)
+		c.String(http.StatusInternalServerError, "Failed to diff backups: %s", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"diff": diff})
+}
+
 func (s *Server) handleDownloadBackup(c *gin.Context) {
 	containerName := c.Query("container")
 	backupKey := c.Query("key")
@@ -433,8 +890,29 @@ func (s *Server) handleDownloadBackup(c *gin.Context) {
 	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
 	c.Header("Content-Type", "application/octet-stream")
 
+	// Uncompressed payloads are gzipped in transit; clients decompress them and
+	// still save the original file
+	compress := !isCompressedKey(backupKey)
+	if compress {
+		c.Header("Vary", "Accept-Encoding")
+		compress = acceptsGzip(c.GetHeader("Accept-Encoding"))
+	}
+	if compress {
+		c.Header("Content-Encoding", "gzip")
+	}
+
 	// Stream the backup to the response
 	c.Stream(func(w io.Writer) bool {
+		if compress {
+			gz := gzip.NewWriter(w)
+			defer func() {
+				if err := gz.Close(); err != nil {
+					slog.Error("failed to finish compressed download", "error", err)
+				}
+			}()
+			w = gz
+		}
+
 		_, err := io.Copy(w, reader)
 		if err != nil {
 			slog.Error("failed to stream backup", "error", err)
@@ -443,16 +921,36 @@ func (s *Server) handleDownloadBackup(c *gin.Context) {
 	})
 }
 
-// formatSize formats bytes into human-readable size
-func formatSize(bytes int64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
-	}
-	div, exp := int64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
+// compressedExtensions are backup file extensions whose content does not
+// shrink further. Encrypted data is indistinguishable from random bytes.
+var compressedExtensions = []string{".zst", ".gz", ".tgz", ".bz2", ".xz", ".lz4", ".zip", ".br", encryption.Extension, encryption.AgeExtension}
+
+// isCompressedKey reports whether a backup key names already compressed content
+func isCompressedKey(key string) bool {
+	ext := strings.ToLower(path.Ext(key))
+	return slices.Contains(compressedExtensions, ext)
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip. An
+// explicit gzip entry wins over a * wildcard.
+func acceptsGzip(header string) bool {
+	wildcard := false
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+
+		accepted := true
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			accepted = err == nil && weight > 0
+		}
+
+		switch coding {
+		case "gzip", "x-gzip":
+			return accepted
+		case "*":
+			wildcard = accepted
+		}
 	}
-	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+	return wildcard
 }
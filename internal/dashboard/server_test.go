@@ -0,0 +1,153 @@
+package dashboard
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	for header, want := range map[string]bool{
+		"":                        false,
+		"gzip":                    true,
+		"gzip, deflate, br, zstd": true,
+		"deflate, GZIP;q=0.5":     true,
+		"br":                      false,
+		"gzip;q=0":                false,
+		"*":                       true,
+		"*;q=0":                   false,
+		"gzip;q=0, *":             false,
+		"identity, *;q=0.1":       true,
+	} {
+		assert.Equal(t, want, acceptsGzip(header), header)
+	}
+}
+
+func TestIsCompressedKey(t *testing.T) {
+	for key, want := range map[string]bool{
+		"db/db/2026-01-01/030000.tar.zst":     true,
+		"db/db/2026-01-01/030000.sql.gz":      true,
+		"db/db/2026-01-01/030000.tar.zst.enc": true,
+		"app/logs/2026-01-01/030000.log":      false,
+		"app/data/2026-01-01/030000.tar":      false,
+		"app/data/2026-01-01/030000.JSON":     false,
+	} {
+		assert.Equal(t, want, isCompressedKey(key), key)
+	}
+}
+
+func TestExtractConfigName(t *testing.T) {
+	assert.Equal(t, "db", extractConfigName("app/db/2026-01-01/030000.sql.zst", nil))
+	assert.Equal(t, "db", extractConfigName("prod/myapp/app/db/2026-01-01/030000.sql.zst", []string{"", "prod", "prod/myapp"}))
+	assert.Equal(t, "files", extractConfigName("app/files/2026-01-01/030000.tar.zst", []string{"prod/myapp"}))
+	assert.Equal(t, "default", extractConfigName("invalid", nil))
+}
+
+func multipartReader(t *testing.T, build func(w *multipart.Writer)) *multipart.Reader {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	build(w)
+	require.NoError(t, w.Close())
+	return multipart.NewReader(&body, w.Boundary())
+}
+
+func TestReadUpload(t *testing.T) {
+	reader := multipartReader(t, func(w *multipart.Writer) {
+		require.NoError(t, w.WriteField("confirm", "yes"))
+		require.NoError(t, w.WriteField("config", "db"))
+		fw, err := w.CreateFormFile("file", "dump.sql.gz")
+		require.NoError(t, err)
+		_, err = fw.Write([]byte("archive"))
+		require.NoError(t, err)
+	})
+
+	fields, file, err := readUpload(reader)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"confirm": "yes", "config": "db"}, fields)
+	assert.Equal(t, "dump.sql.gz", file.FileName())
+
+	content, err := io.ReadAll(file)
+	require.NoError(t, err)
+	assert.Equal(t, "archive", string(content))
+}
+
+func TestReadUpload_Invalid(t *testing.T) {
+	for name, build := range map[string]func(w *multipart.Writer){
+		"no file": func(w *multipart.Writer) {
+			_ = w.WriteField("config", "db")
+		},
+		"no file name": func(w *multipart.Writer) {
+			_ = w.WriteField("config", "db")
+			_, _ = w.CreateFormField("file")
+		},
+		"field too large": func(w *multipart.Writer) {
+			_ = w.WriteField("config", strings.Repeat("a", maxUploadField+1))
+			_, _ = w.CreateFormFile("file", "dump.sql.gz")
+		},
+	} {
+		_, _, err := readUpload(multipartReader(t, build))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestHTTPSRedirect(t *testing.T) {
+	for _, tt := range []struct {
+		addr, host, want string
+	}{
+		{":8443", "backup.example.com", "https://backup.example.com:8443/backups?container=db"},
+		{":443", "backup.example.com:80", "https://backup.example.com/backups?container=db"},
+		{"0.0.0.0:8443", "10.0.0.5:8080", "https://10.0.0.5:8443/backups?container=db"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "http://"+tt.host+"/backups?container=db", nil)
+		rec := httptest.NewRecorder()
+		httpsRedirect(tt.addr).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+		assert.Equal(t, tt.want, rec.Header().Get("Location"))
+	}
+}
+
+func TestPageBackups(t *testing.T) {
+	start := time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC)
+	var files []storage.BackupFile
+	for i := range 5 {
+		files = append(files, storage.BackupFile{
+			Key:          fmt.Sprintf("app/db/2026-01-0%d/030000.sql.gz", i+1),
+			LastModified: start.AddDate(0, 0, i),
+		})
+	}
+
+	page, more := pageBackups(files, 1, 2)
+	assert.True(t, more)
+	require.Len(t, page, 2)
+	assert.Equal(t, "app/db/2026-01-05/030000.sql.gz", page[0].Key)
+	assert.Equal(t, "app/db/2026-01-04/030000.sql.gz", page[1].Key)
+
+	page, more = pageBackups(files, 3, 2)
+	assert.False(t, more)
+	require.Len(t, page, 1)
+	assert.Equal(t, "app/db/2026-01-01/030000.sql.gz", page[0].Key)
+
+	page, more = pageBackups(files, 4, 2)
+	assert.False(t, more)
+	assert.Empty(t, page)
+
+	// The listing itself stays in storage order
+	assert.Equal(t, "app/db/2026-01-01/030000.sql.gz", files[0].Key)
+}
+
+func TestBackupsPageURL(t *testing.T) {
+	assert.Equal(t, "/backups?container=app&page=2", backupsPageURL("app", "", 2, defaultBackupsPerPage))
+	assert.Equal(t, "/backups?config=db+main&container=app&page=3&per_page=10", backupsPageURL("app", "db main", 3, 10))
+}
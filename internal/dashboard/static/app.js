@@ -13,19 +13,150 @@ function hideDeleteModal() {
 
 // Restore Modal Functions
 function showRestoreModal(container, key) {
+    pendingUpload = null;
     document.getElementById('restoreModal').classList.remove('hidden');
     document.getElementById('restoreModal').classList.add('flex');
+    document.getElementById('restoreTitle').textContent = 'Restore Backup';
     document.getElementById('restoreBackupKey').textContent = key;
     document.getElementById('restoreForm').action = '/api/backup/restore?container=' + encodeURIComponent(container) + '&key=' + encodeURIComponent(key);
 }
 
+// Restores the newest backup of a config. The key is shown in the modal and sent
+// along, so the server refuses if a newer backup appeared in the meantime.
+function showRestoreLatestModal(container, config, key) {
+    showRestoreModal(container, key);
+    document.getElementById('restoreTitle').textContent = 'Restore Latest Backup of ' + config;
+    document.getElementById('restoreForm').action = '/api/backup/restore-latest?container=' + encodeURIComponent(container) + '&config=' + encodeURIComponent(config) + '&key=' + encodeURIComponent(key);
+}
+
 function hideRestoreModal() {
     document.getElementById('restoreModal').classList.add('hidden');
     document.getElementById('restoreModal').classList.remove('flex');
 }
 
+// Upload form waiting for the restore modal's confirmation, if any
+var pendingUpload = null;
+
+// Confirms the restore of an uploaded file in the restore modal. Confirming
+// submits the upload form instead of the modal's own form.
+function showUploadRestoreModal() {
+    var form = document.getElementById('uploadRestoreForm');
+    if (!form.reportValidity()) {
+        return;
+    }
+    var config = form.elements['config'].value;
+    showRestoreModal(form.dataset.container, form.elements['file'].files[0].name);
+    pendingUpload = form;
+    document.getElementById('restoreTitle').textContent = 'Restore Uploaded Backup with ' + config;
+}
+
+// Progress Polling
+// Polls running backups/restores for the container shown on the page. This keeps
+// running while a restore form submission is waiting for the server to respond.
+function renderProgress(panel, operations) {
+    panel.textContent = '';
+    if (operations.length === 0) {
+        panel.classList.add('hidden');
+        return;
+    }
+    panel.classList.remove('hidden');
+
+    operations.forEach(function(op) {
+        var row = document.createElement('div');
+        row.className = 'mt-2';
+
+        var label = document.createElement('div');
+        label.className = 'flex justify-between text-xs text-gray-500 dark:text-gray-400';
+
+        var name = document.createElement('span');
+        name.textContent = (op.operation === 'restore' ? 'Restoring ' : 'Backing up ') + (op.key || op.config);
+
+        var status = document.createElement('span');
+        status.textContent = op.size + ', ' + op.entries + ' entries' + (op.percent >= 0 ? ' (' + Math.round(op.percent) + '%)' : '');
+
+        label.appendChild(name);
+        label.appendChild(status);
+        row.appendChild(label);
+
+        var track = document.createElement('div');
+        track.className = 'mt-1 w-full h-2 rounded-full bg-gray-100 dark:bg-gray-700 overflow-hidden';
+        var bar = document.createElement('div');
+        bar.className = 'h-2 rounded-full bg-primary';
+        // Without a known total, show an indeterminate full bar next to the bytes so far
+        bar.style.width = (op.percent >= 0 ? op.percent : 100) + '%';
+        if (op.percent < 0) {
+            bar.style.opacity = '0.4';
+        }
+        track.appendChild(bar);
+        row.appendChild(track);
+
+        panel.appendChild(row);
+    });
+}
+
+function pollProgress(panel) {
+    fetch('/api/progress?container=' + encodeURIComponent(panel.dataset.container))
+        .then(function(res) { return res.ok ? res.json() : { operations: [] }; })
+        .then(function(data) { renderProgress(panel, data.operations || []); })
+        .catch(function() {})
+        .finally(function() { setTimeout(function() { pollProgress(panel); }, 2000); });
+}
+
+// Job Status Polling
+// Keeps the state badges on the dashboard index current without reloading the page
+var jobStateClasses = {
+    queued: 'bg-yellow-100 dark:bg-yellow-900 text-yellow-600 dark:text-yellow-400',
+    running: 'bg-blue-100 dark:bg-blue-900 text-blue-800 dark:text-blue-200',
+    succeeded: 'bg-green-100 dark:bg-green-900 text-green-800 dark:text-green-200',
+    failed: 'bg-red-100 dark:bg-red-900 text-red-800 dark:text-red-200',
+    idle: 'bg-gray-100 dark:bg-gray-600 text-gray-600 dark:text-gray-200'
+};
+
+function findJobElement(attr, id) {
+    return document.querySelector('[' + attr + '="' + CSS.escape(id) + '"]');
+}
+
+function renderJobs(jobs) {
+    jobs.forEach(function(job) {
+        var id = job.container + '/' + job.config;
+
+        var badge = findJobElement('data-job-state', id);
+        if (badge) {
+            badge.textContent = job.state;
+            badge.className = 'ml-2 px-2 inline-flex text-xs leading-5 font-semibold rounded-full ' + (jobStateClasses[job.state] || jobStateClasses.idle);
+        }
+
+        var detail = findJobElement('data-job-detail', id);
+        if (detail) {
+            detail.textContent = job.detail;
+        }
+
+        var next = findJobElement('data-job-next', id);
+        if (next && job.next) {
+            next.textContent = job.next;
+        }
+    });
+}
+
+function pollJobs() {
+    fetch('/api/jobs')
+        .then(function(res) { return res.ok ? res.json() : { jobs: [] }; })
+        .then(function(data) { renderJobs(data.jobs || []); })
+        .catch(function() {})
+        .finally(function() { setTimeout(pollJobs, 2000); });
+}
+
 // Event Listeners
 document.addEventListener('DOMContentLoaded', function() {
+    var progressPanel = document.getElementById('progressPanel');
+    if (progressPanel) {
+        pollProgress(progressPanel);
+    }
+
+    if (document.querySelector('[data-job-state]')) {
+        pollJobs();
+    }
+
     // Close modal on escape key
     document.addEventListener('keydown', function(e) {
         if (e.key === 'Escape') {
@@ -50,6 +181,17 @@ document.addEventListener('DOMContentLoaded', function() {
         });
     }
 
+    var restoreForm = document.getElementById('restoreForm');
+    if (restoreForm) {
+        restoreForm.addEventListener('submit', function(e) {
+            if (pendingUpload) {
+                e.preventDefault();
+                pendingUpload.elements['confirm'].value = 'yes';
+                pendingUpload.submit();
+            }
+        });
+    }
+
     var restoreModal = document.getElementById('restoreModal');
     if (restoreModal) {
         restoreModal.addEventListener('click', function(e) {
@@ -11,9 +11,13 @@ type BackupConfigInfo struct {
 	Name       string
 	BackupType string
 	Schedule   string
-	Retention  int
+	Retention  string
 	Storage    string
+	Fallback   []string // Failover pools, tried in order when Storage fails
+	Mirrors    []string // Pools every backup is copied to
 	NextRun    string
+	State      string // idle, queued, running, succeeded or failed
+	Detail     string // Short description of the state, e.g. progress or last error
 }
 
 // ContainerInfo contains information about a container
@@ -36,10 +40,24 @@ type IndexData struct {
 
 // BackupsData contains data for the backups page
 type BackupsData struct {
-	ContainerName string
-	ConfigNames   []string                // Ordered list of config names
-	BackupGroups  map[string][]BackupInfo // Backups grouped by config name
-	Flash         *FlashMessage
+	ContainerName  string
+	ConfigNames    []string                // Ordered list of the config names shown
+	BackupGroups   map[string][]BackupInfo // Current page of backups grouped by config name, newest first
+	AllConfigs     []string                // Names of all configs with backups, for the filter
+	ConfigFilter   string                  // Config the page is filtered to, empty for all
+	Pagination     Pagination
+	LatestKeys     map[string]string // Newest backup key per config name
+	RestoreConfigs []string          // Backup configs an uploaded file can be restored with
+	Failures       []FailureInfo     // Recent failures across configs, newest first
+	Flash          *FlashMessage
+}
+
+// Pagination describes the page of backups shown for each config
+type Pagination struct {
+	Page    int
+	PerPage int
+	PrevURL string // Empty on the first page
+	NextURL string // Empty when no config has more backups
 }
 
 // BackupInfo contains information about a backup
@@ -50,7 +68,31 @@ type BackupInfo struct {
 	LastModified string
 }
 
+// FailureInfo contains information about a failed backup run
+type FailureInfo struct {
+	ConfigName string
+	Time       string
+	Category   string
+	Error      string
+}
+
 // NotificationInfo contains information about a notification provider
 type NotificationInfo struct {
 	Name string
 }
+
+// JobStateClass returns the badge classes used for a job state
+func JobStateClass(state string) string {
+	switch state {
+	case "queued":
+		return "bg-yellow-100 dark:bg-yellow-900 text-yellow-600 dark:text-yellow-400"
+	case "running":
+		return "bg-blue-100 dark:bg-blue-900 text-blue-800 dark:text-blue-200"
+	case "succeeded":
+		return "bg-green-100 dark:bg-green-900 text-green-800 dark:text-green-200"
+	case "failed":
+		return "bg-red-100 dark:bg-red-900 text-red-800 dark:text-red-200"
+	default:
+		return "bg-gray-100 dark:bg-gray-600 text-gray-600 dark:text-gray-200"
+	}
+}
@@ -1,9 +1,12 @@
 package docker
 
 import (
+	"archive/tar"
 	"bytes"
 	"context"
 	"io"
+	"path"
+	"strconv"
 	"strings"
 	"time"
 
@@ -21,17 +24,55 @@ type MountInfo struct {
 	Name        string // Volume name (for volume mounts)
 	Source      string // Host path
 	Destination string // Container path
+
+	// Origin is the name of the volume a sandbox mount stands in for, empty otherwise
+	Origin string
+}
+
+// SourceName returns the name of the volume backups of this mount are stored
+// under: the volume it stands in for in a sandbox, otherwise its own name
+func (m MountInfo) SourceName() string {
+	if m.Origin != "" {
+		return m.Origin
+	}
+	return m.Name
 }
 
 // ContainerInfo holds relevant container information
 type ContainerInfo struct {
 	ID        string
 	Name      string
+	Image     string
 	Labels    map[string]string
 	Env       map[string]string
 	NetworkIP string
 	Running   bool
 	Mounts    []MountInfo
+	Node      string // Node the container runs on, empty for the single-host setup
+}
+
+// Key identifies the container across all nodes
+func (c *ContainerInfo) Key() string {
+	return QualifyID(c.Node, c.ID)
+}
+
+// MountAt returns the mount containing the container path p. For nested
+// mounts the deepest one wins.
+func (c *ContainerInfo) MountAt(p string) (MountInfo, bool) {
+	p = path.Clean(p)
+
+	var found MountInfo
+	var ok bool
+	for _, mount := range c.Mounts {
+		dest := path.Clean(mount.Destination)
+		if p != dest && !strings.HasPrefix(p, strings.TrimSuffix(dest, "/")+"/") {
+			continue
+		}
+		if !ok || len(dest) > len(path.Clean(found.Destination)) {
+			found, ok = mount, true
+		}
+	}
+	return found, ok
 }
 
 // VolumeInfo holds relevant volume information
@@ -45,25 +86,69 @@ type VolumeInfo struct {
 // Client wraps the Docker API client
 type Client struct {
 	cli *client.Client
+
+	journal *StopJournal // Records the containers stopped for backups, may be nil
+	node    string       // Name of the node in the journal, see MultiClient.SetStopJournal
 }
 
-// NewClient creates a new Docker client
+// TLSOptions are the files used to connect to tcp:// hosts over TLS. They
+// are ignored for other hosts.
+type TLSOptions struct {
+	CAFile   string // CA that signed the daemon certificate
+	CertFile string // Client certificate (PEM)
+	KeyFile  string // Private key of the client certificate (PEM)
+}
+
+func (o TLSOptions) enabled() bool {
+	return o.CAFile != "" || o.CertFile != "" || o.KeyFile != ""
+}
+
+// NewClient creates a new Docker client. Besides the hosts the Docker client
+// supports, host may be ssh://[user@]host[:port] to reach a remote daemon
+// through the ssh binary, like the docker CLI does.
 func NewClient(host string) (*Client, error) {
+	return NewClientWithTLS(host, TLSOptions{})
+}
+
+// NewClientWithTLS creates a new Docker client that connects to tcp:// hosts
+// over TLS with the given files
+func NewClientWithTLS(host string, tlsOpts TLSOptions) (*Client, error) {
+	c, err := newClient(host, tlsOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify connection
+	if _, err := c.cli.Ping(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// newClient creates a Docker client without checking that the host is reachable
+func newClient(host string, tlsOpts TLSOptions) (*Client, error) {
 	opts := []client.Opt{
 		client.WithAPIVersionNegotiation(),
 	}
 
-	if host != "" {
+	switch {
+	case strings.HasPrefix(host, "ssh://"):
+		dialer, err := sshDialer(host)
+		if err != nil {
+			return nil, err
+		}
+		// The host only names the daemon in requests, the dialer connects
+		opts = append(opts, client.WithHost("http://docker.example.com"), client.WithDialContext(dialer))
+	case host != "":
 		opts = append(opts, client.WithHost(host))
 	}
 
-	cli, err := client.NewClientWithOpts(opts...)
-	if err != nil {
-		return nil, err
+	if tlsOpts.enabled() && strings.HasPrefix(host, "tcp://") {
+		opts = append(opts, client.WithTLSClientConfig(tlsOpts.CAFile, tlsOpts.CertFile, tlsOpts.KeyFile))
 	}
 
-	// Verify connection
-	_, err = cli.Ping(context.Background())
+	cli, err := client.NewClientWithOpts(opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -76,10 +161,17 @@ func (c *Client) Close() error {
 	return c.cli.Close()
 }
 
-// ListContainers returns all running containers
-func (c *Client) ListContainers(ctx context.Context) ([]ContainerInfo, error) {
+// Ping checks that the Docker daemon responds
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.cli.Ping(ctx)
+	return err
+}
+
+// ListContainers returns all running containers, and the stopped ones too if
+// includeStopped is set
+func (c *Client) ListContainers(ctx context.Context, includeStopped bool) ([]ContainerInfo, error) {
 	containers, err := c.cli.ContainerList(ctx, container.ListOptions{
-		All: false, // Only running containers
+		All: includeStopped,
 	})
 	if err != nil {
 		return nil, err
@@ -131,7 +223,7 @@ func (c *Client) GetContainer(ctx context.Context, containerID string) (*Contain
 	var mounts []MountInfo
 	for _, m := range inspect.Mounts {
 		mounts = append(mounts, MountInfo{
-			Type:        string(m.Type),
+			Type:        mountType(m),
 			Name:        m.Name,
 			Source:      m.Source,
 			Destination: m.Destination,
@@ -141,6 +233,7 @@ func (c *Client) GetContainer(ctx context.Context, containerID string) (*Contain
 	return &ContainerInfo{
 		ID:        inspect.ID,
 		Name:      name,
+		Image:     inspect.Config.Image,
 		Labels:    inspect.Config.Labels,
 		Env:       env,
 		NetworkIP: networkIP,
@@ -149,29 +242,80 @@ func (c *Client) GetContainer(ctx context.Context, containerID string) (*Contain
 	}, nil
 }
 
-// WatchEvents returns a channel of container events
+// WatchEvents returns a channel of container events. Podman's event actions
+// are passed on as reported, see normalizeEvent.
 func (c *Client) WatchEvents(ctx context.Context) (<-chan events.Message, <-chan error) {
 	filterArgs := filters.NewArgs()
 	filterArgs.Add("type", "container")
 	filterArgs.Add("event", "start")
 	filterArgs.Add("event", "stop")
 	filterArgs.Add("event", "die")
+	filterArgs.Add("event", "destroy")
+	filterArgs.Add("event", string(podmanActionDied))
 
 	return c.cli.Events(ctx, events.ListOptions{
 		Filters: filterArgs,
 	})
 }
 
+// maxStderrSize bounds how much stderr is kept of an exec
+const maxStderrSize = 64 * 1024
+
+// maxErrorOutput bounds the command output quoted in an error message
+const maxErrorOutput = 4 * 1024
+
 // ExecResult contains the result of a container exec
 type ExecResult struct {
 	ExitCode int
-	Output   string
+	Output   string // Combined stdout and stderr (not set by ExecWithOutput, truncated by RunHelper)
+	Stderr   string // Stderr only, the last 64 KiB of it
 }
 
-// Exec runs a command in a container and pipes stdin to it
-func (c *Client) Exec(ctx context.Context, containerID string, cmd []string, stdin io.Reader) (*ExecResult, error) {
+// ErrorOutput returns the stderr of the command for an error message, or the
+// combined output if it wrote nothing to stderr. Long output is cut down to
+// its end, where the reason for the failure usually is.
+func (r *ExecResult) ErrorOutput() string {
+	out := strings.TrimSpace(r.Stderr)
+	if out == "" {
+		out = strings.TrimSpace(r.Output)
+	}
+	if len(out) > maxErrorOutput {
+		out = "[...] " + strings.ToValidUTF8(out[len(out)-maxErrorOutput:], "")
+	}
+	return out
+}
+
+// tailBuffer keeps the last max bytes written to it. Error messages usually
+// end up at the end of the output, so the head is what gets dropped.
+type tailBuffer struct {
+	buf       []byte
+	max       int
+	truncated bool
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.max {
+		t.buf = append(t.buf[:0:0], t.buf[len(t.buf)-t.max:]...)
+		t.truncated = true
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	if t.truncated {
+		return "[...] " + string(t.buf)
+	}
+	return string(t.buf)
+}
+
+// Exec runs a command in a container and pipes stdin to it. env holds extra
+// environment variables (KEY=value) for the command, which unlike arguments
+// aren't visible to other processes in the container.
+func (c *Client) Exec(ctx context.Context, containerID string, cmd []string, env []string, stdin io.Reader) (*ExecResult, error) {
 	execConfig := container.ExecOptions{
 		Cmd:          cmd,
+		Env:          env,
 		AttachStdin:  stdin != nil,
 		AttachStdout: true,
 		AttachStderr: true,
@@ -196,9 +340,10 @@ func (c *Client) Exec(ctx context.Context, containerID string, cmd []string, std
 		}()
 	}
 
-	// Read output - demultiplex Docker stream
-	var stdout, stderr bytes.Buffer
-	_, err = stdcopy.StdCopy(&stdout, &stderr, resp.Reader)
+	// Read output - demultiplex Docker stream, keep the tail of stderr
+	var stdout bytes.Buffer
+	stderr := &tailBuffer{max: maxStderrSize}
+	_, err = stdcopy.StdCopy(&stdout, stderr, resp.Reader)
 	if err != nil {
 		return nil, err
 	}
@@ -211,47 +356,56 @@ func (c *Client) Exec(ctx context.Context, containerID string, cmd []string, std
 
 	// Combine stdout and stderr for output
 	output := stdout.String()
-	if stderr.Len() > 0 {
+	if len(stderr.buf) > 0 {
 		output += stderr.String()
 	}
 
 	return &ExecResult{
 		ExitCode: inspectResp.ExitCode,
 		Output:   output,
+		Stderr:   stderr.String(),
 	}, nil
 }
 
-func (c *Client) ExecWithOutput(ctx context.Context, containerID string, cmd []string, stdout io.Writer) (int, error) {
+// ExecWithOutput runs a command in a container and streams its stdout to the given writer.
+// Stderr is captured (bounded to the last 64 KiB) and returned in the result.
+// env is passed like for Exec.
+func (c *Client) ExecWithOutput(ctx context.Context, containerID string, cmd []string, env []string, stdout io.Writer) (*ExecResult, error) {
 	execConfig := container.ExecOptions{
 		Cmd:          cmd,
+		Env:          env,
 		AttachStdout: true,
 		AttachStderr: true,
 	}
 
 	execID, err := c.cli.ContainerExecCreate(ctx, containerID, execConfig)
 	if err != nil {
-		return -1, err
+		return nil, err
 	}
 
 	resp, err := c.cli.ContainerExecAttach(ctx, execID.ID, container.ExecStartOptions{})
 	if err != nil {
-		return -1, err
+		return nil, err
 	}
 	defer resp.Close()
 
-	// Demultiplex Docker stream - write stdout to writer, discard stderr
-	_, err = stdcopy.StdCopy(stdout, io.Discard, resp.Reader)
+	// Demultiplex Docker stream - write stdout to writer, keep the tail of stderr
+	stderr := &tailBuffer{max: maxStderrSize}
+	_, err = stdcopy.StdCopy(stdout, stderr, resp.Reader)
 	if err != nil {
-		return -1, err
+		return nil, err
 	}
 
 	// Get exit code
 	inspectResp, err := c.cli.ContainerExecInspect(ctx, execID.ID)
 	if err != nil {
-		return -1, err
+		return nil, err
 	}
 
-	return inspectResp.ExitCode, nil
+	return &ExecResult{
+		ExitCode: inspectResp.ExitCode,
+		Stderr:   stderr.String(),
+	}, nil
 }
 
 // ListVolumes returns all Docker volumes
@@ -317,7 +471,7 @@ func (c *Client) GetContainersUsingVolume(ctx context.Context, volumeName string
 	var result []ContainerInfo
 	for _, ctr := range containers {
 		info, err := c.GetContainer(ctx, ctr.ID)
-		if err != nil {
+		if err != nil || !usesVolume(info, volumeName) {
 			continue
 		}
 		result = append(result, *info)
@@ -335,11 +489,73 @@ func (c *Client) CopyFromContainer(ctx context.Context, containerID, srcPath str
 	return reader, nil
 }
 
+// PathOwner returns the owner of a file or directory inside the container
+func (c *Client) PathOwner(ctx context.Context, containerID, p string) (uid, gid int, err error) {
+	reader, err := c.CopyFromContainer(ctx, containerID, p)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	// The first entry is p itself
+	header, err := tar.NewReader(reader).Next()
+	if err != nil {
+		return 0, 0, err
+	}
+	return header.Uid, header.Gid, nil
+}
+
 // CopyToContainer extracts the given tar stream into dstPath inside the container
 func (c *Client) CopyToContainer(ctx context.Context, containerID, dstPath string, content io.Reader) error {
 	return c.cli.CopyToContainer(ctx, containerID, dstPath, content, container.CopyToContainerOptions{})
 }
 
+// LogOptions selects the part of a container's logs returned by GetLogs
+type LogOptions struct {
+	Since      time.Time // Zero for the whole log
+	Tail       int       // Number of lines from the end, 0 for all
+	Timestamps bool      // Prefix each line with its RFC3339Nano timestamp
+}
+
+// GetLogs streams a container's logs to stdout and stderr. Containers with a TTY
+// have a single stream, which is written to stdout.
+func (c *Client) GetLogs(ctx context.Context, containerID string, opts LogOptions, stdout, stderr io.Writer) error {
+	inspect, err := c.cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return err
+	}
+
+	logOpts := container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Timestamps: opts.Timestamps,
+		Tail:       "all",
+	}
+	if opts.Tail > 0 {
+		logOpts.Tail = strconv.Itoa(opts.Tail)
+	}
+	if !opts.Since.IsZero() {
+		logOpts.Since = strconv.FormatInt(opts.Since.Unix(), 10)
+	}
+
+	reader, err := c.cli.ContainerLogs(ctx, containerID, logOpts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	if inspect.Config != nil && inspect.Config.Tty {
+		_, err = io.Copy(stdout, reader)
+		return err
+	}
+	_, err = stdcopy.StdCopy(stdout, stderr, reader)
+	return err
+}
+
 // StopContainer stops a container with the given timeout
 func (c *Client) StopContainer(ctx context.Context, containerID string, timeout time.Duration) error {
 	timeoutSeconds := int(timeout.Seconds())
@@ -0,0 +1,66 @@
+package docker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTailBuffer_KeepsEverythingBelowLimit(t *testing.T) {
+	buf := &tailBuffer{max: 16}
+
+	_, _ = buf.Write([]byte("access "))
+	_, _ = buf.Write([]byte("denied"))
+
+	assert.Equal(t, "access denied", buf.String())
+}
+
+func TestTailBuffer_KeepsTail(t *testing.T) {
+	buf := &tailBuffer{max: 16}
+
+	_, _ = buf.Write([]byte(strings.Repeat("noise ", 10)))
+	n, err := buf.Write([]byte("unknown database"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 16, n)
+	assert.Equal(t, "[...] unknown database", buf.String())
+}
+
+func TestExecResult_ErrorOutput(t *testing.T) {
+	result := &ExecResult{Output: "INSERT 0 1\npermission denied\n", Stderr: "permission denied\n"}
+	assert.Equal(t, "permission denied", result.ErrorOutput())
+
+	result = &ExecResult{Output: "  no such file\n"}
+	assert.Equal(t, "no such file", result.ErrorOutput(), "falls back to the combined output")
+
+	result = &ExecResult{Stderr: strings.Repeat("x", 2*maxErrorOutput) + "relation does not exist"}
+	out := result.ErrorOutput()
+	assert.Len(t, out, len("[...] ")+maxErrorOutput)
+	assert.True(t, strings.HasPrefix(out, "[...] "))
+	assert.True(t, strings.HasSuffix(out, "relation does not exist"))
+}
+
+func TestContainerInfo_MountAt(t *testing.T) {
+	container := &ContainerInfo{
+		Mounts: []MountInfo{
+			{Type: "volume", Name: "app", Destination: "/app"},
+			{Type: "bind", Source: "/srv/uploads", Destination: "/app/uploads/"},
+		},
+	}
+
+	mount, ok := container.MountAt("/app/db/app.sqlite")
+	assert.True(t, ok)
+	assert.Equal(t, "app", mount.Name)
+
+	mount, ok = container.MountAt("/app/uploads/a.png")
+	assert.True(t, ok)
+	assert.Equal(t, "/srv/uploads", mount.Source)
+
+	mount, ok = container.MountAt("/app")
+	assert.True(t, ok)
+	assert.Equal(t, "app", mount.Name)
+
+	_, ok = container.MountAt("/application/data.db")
+	assert.False(t, ok)
+}
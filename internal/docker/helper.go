@@ -0,0 +1,130 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// DefaultHelperImage is the image used for helper containers unless configured otherwise
+const DefaultHelperImage = "alpine:latest"
+
+// HelperContainer describes a short-lived container that operates on volumes
+// while the containers using them are stopped
+type HelperContainer struct {
+	Image   string            // Image to run, defaults to DefaultHelperImage
+	Cmd     []string          // Command to run
+	Volumes map[string]string // Volume name -> mount path inside the helper
+}
+
+// RunHelper runs a helper container to completion and removes it afterwards.
+// The image is pulled if it isn't available locally.
+func (c *Client) RunHelper(ctx context.Context, helper HelperContainer) (*ExecResult, error) {
+	img := helper.Image
+	if img == "" {
+		img = DefaultHelperImage
+	}
+
+	if err := c.ensureImage(ctx, img); err != nil {
+		return nil, err
+	}
+
+	mounts := make([]mount.Mount, 0, len(helper.Volumes))
+	for name, target := range helper.Volumes {
+		mounts = append(mounts, mount.Mount{
+			Type:   mount.TypeVolume,
+			Source: name,
+			Target: target,
+		})
+	}
+
+	created, err := c.cli.ContainerCreate(ctx, &container.Config{
+		Image:  img,
+		Cmd:    helper.Cmd,
+		Labels: map[string]string{"docker-backup.helper": "true"},
+	}, &container.HostConfig{
+		Mounts: mounts,
+	}, nil, nil, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create helper container: %w", err)
+	}
+	defer func() {
+		// Use a fresh context so the helper is removed even if ctx was cancelled
+		removeCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := c.cli.ContainerRemove(removeCtx, created.ID, container.RemoveOptions{Force: true}); err != nil {
+			slog.Warn("failed to remove helper container", "container", created.ID, "error", err)
+		}
+	}()
+
+	waitCh, errCh := c.cli.ContainerWait(ctx, created.ID, container.WaitConditionNextExit)
+
+	if err := c.cli.ContainerStart(ctx, created.ID, container.StartOptions{}); err != nil {
+		return nil, fmt.Errorf("failed to start helper container: %w", err)
+	}
+
+	var exitCode int
+	select {
+	case resp := <-waitCh:
+		if resp.Error != nil {
+			return nil, fmt.Errorf("helper container failed: %s", resp.Error.Message)
+		}
+		exitCode = int(resp.StatusCode)
+	case err := <-errCh:
+		return nil, fmt.Errorf("failed to wait for helper container: %w", err)
+	}
+
+	logs, err := c.cli.ContainerLogs(ctx, created.ID, container.LogsOptions{ShowStdout: true, ShowStderr: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read helper container logs: %w", err)
+	}
+	defer func() {
+		_ = logs.Close()
+	}()
+
+	output := &tailBuffer{max: maxStderrSize}
+	stderr := &tailBuffer{max: maxStderrSize}
+	if _, err := stdcopy.StdCopy(output, io.MultiWriter(output, stderr), logs); err != nil {
+		return nil, fmt.Errorf("failed to read helper container logs: %w", err)
+	}
+
+	return &ExecResult{
+		ExitCode: exitCode,
+		Output:   output.String(),
+		Stderr:   stderr.String(),
+	}, nil
+}
+
+// ensureImage pulls img unless it is already present
+func (c *Client) ensureImage(ctx context.Context, img string) error {
+	if _, err := c.cli.ImageInspect(ctx, img); err == nil {
+		return nil
+	} else if !client.IsErrNotFound(err) {
+		return fmt.Errorf("failed to inspect image %s: %w", img, err)
+	}
+
+	slog.Info("pulling image", "image", img)
+
+	reader, err := c.cli.ImagePull(ctx, img, image.PullOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to pull image %s: %w", img, err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	// The pull only completes once the progress stream has been consumed
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return fmt.Errorf("failed to pull image %s: %w", img, err)
+	}
+
+	return nil
+}
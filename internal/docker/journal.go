@@ -0,0 +1,209 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// restartTimeout bounds starting stopped containers again, which also happens
+// after the backup's context was cancelled
+const restartTimeout = 2 * time.Minute
+
+// StoppedContainer is a container docker-backup stopped and has not started again
+type StoppedContainer struct {
+	Node string `json:"node,omitempty"`
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// StopJournal records the containers stopped for a backup or restore in a
+// file until they are started again. A daemon that was killed in between
+// starts them at its next startup, see MultiClient.RestartStopped.
+type StopJournal struct {
+	path    string
+	mu      sync.Mutex
+	stopped []StoppedContainer
+}
+
+// OpenStopJournal loads the journal at path, which doesn't need to exist
+func OpenStopJournal(path string) (*StopJournal, error) {
+	j := &StopJournal{path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stop journal: %w", err)
+	}
+	if err := json.Unmarshal(data, &j.stopped); err != nil {
+		return nil, fmt.Errorf("failed to parse stop journal %s: %w", path, err)
+	}
+	return j, nil
+}
+
+// Stopped returns the recorded containers
+func (j *StopJournal) Stopped() []StoppedContainer {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return slices.Clone(j.stopped)
+}
+
+// add records a container before it is stopped
+func (j *StopJournal) add(entry StoppedContainer) error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if slices.Contains(j.stopped, entry) {
+		return nil
+	}
+	j.stopped = append(j.stopped, entry)
+	return j.save()
+}
+
+// remove forgets a container once it runs again
+func (j *StopJournal) remove(entry StoppedContainer) error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.stopped = slices.DeleteFunc(j.stopped, func(e StoppedContainer) bool {
+		return e.Node == entry.Node && e.ID == entry.ID
+	})
+	return j.save()
+}
+
+// save writes the journal through a temporary file, so a crash never leaves
+// a partial one behind. An empty journal removes the file.
+func (j *StopJournal) save() error {
+	if len(j.stopped) == 0 {
+		if err := os.Remove(j.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove stop journal: %w", err)
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(j.stopped)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(j.path), filepath.Base(j.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write stop journal: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write stop journal: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write stop journal: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), j.path); err != nil {
+		return fmt.Errorf("failed to write stop journal: %w", err)
+	}
+	return nil
+}
+
+// stopContainer stops a container using one of the volumes of a backup or
+// restore, recording it in the journal first so it is started again even if
+// the daemon dies before it does
+func (c *Client) stopContainer(ctx context.Context, ctr ContainerInfo, timeout time.Duration) error {
+	entry := StoppedContainer{Node: c.node, ID: ctr.ID, Name: ctr.Name}
+	if err := c.journal.add(entry); err != nil {
+		slog.Warn("failed to record stopped container", "container", ctr.Name, "error", err)
+	}
+	if err := c.StopContainer(ctx, ctr.ID, timeout); err != nil {
+		if err := c.journal.remove(entry); err != nil {
+			slog.Warn("failed to update stop journal", "container", ctr.Name, "error", err)
+		}
+		return err
+	}
+	return nil
+}
+
+// restartContainer starts a container stopped by stopContainer again. It
+// also runs when ctx was cancelled, e.g. by a shutdown during the backup.
+func (c *Client) restartContainer(ctx context.Context, containerID string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), restartTimeout)
+	defer cancel()
+
+	if err := c.StartContainer(ctx, containerID); err != nil {
+		slog.Warn("failed to restart container after backup/restore",
+			"container", containerID,
+			"error", err,
+		)
+		return
+	}
+	if err := c.journal.remove(StoppedContainer{Node: c.node, ID: containerID}); err != nil {
+		slog.Warn("failed to update stop journal", "container", containerID, "error", err)
+	}
+}
+
+// SetStopJournal records the containers stopped through any node in j
+func (m *MultiClient) SetStopJournal(j *StopJournal) {
+	for _, n := range m.nodes {
+		n.Client.journal = j
+		n.Client.node = n.Name
+	}
+}
+
+// RestartStopped starts the containers of the journal that are still
+// stopped, e.g. because the daemon was killed during a backup. Containers
+// that no longer exist, or whose node isn't watched anymore, are forgotten.
+func (m *MultiClient) RestartStopped(ctx context.Context, j *StopJournal) {
+	for _, entry := range j.Stopped() {
+		var c *Client
+		for _, n := range m.nodes {
+			if n.Name == entry.Node {
+				c = n.Client
+			}
+		}
+		if c == nil {
+			slog.Warn("forgetting stopped container of unknown docker node", "container", entry.Name, "node", entry.Node)
+			if err := j.remove(entry); err != nil {
+				slog.Warn("failed to update stop journal", "container", entry.Name, "error", err)
+			}
+			continue
+		}
+
+		info, err := c.GetContainer(ctx, entry.ID)
+		switch {
+		case client.IsErrNotFound(err):
+			slog.Info("container stopped for a backup no longer exists", "container", entry.Name)
+			if err := j.remove(entry); err != nil {
+				slog.Warn("failed to update stop journal", "container", entry.Name, "error", err)
+			}
+			continue
+		case err != nil:
+			slog.Warn("failed to inspect container stopped for a backup", "container", entry.Name, "error", err)
+			continue
+		case info.Running:
+			if err := j.remove(entry); err != nil {
+				slog.Warn("failed to update stop journal", "container", entry.Name, "error", err)
+			}
+			continue
+		}
+
+		slog.Info("restarting container left stopped by an interrupted backup or restore", "container", entry.Name)
+		c.restartContainer(ctx, entry.ID)
+	}
+}
@@ -0,0 +1,56 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStopJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "docker-backup.stopped.json")
+
+	j, err := OpenStopJournal(path)
+	require.NoError(t, err)
+	assert.Empty(t, j.Stopped())
+
+	app := StoppedContainer{ID: "abc", Name: "app"}
+	worker := StoppedContainer{Node: "node1", ID: "def", Name: "worker"}
+	require.NoError(t, j.add(app))
+	require.NoError(t, j.add(worker))
+	require.NoError(t, j.add(app))
+
+	// A new daemon finds the containers left stopped
+	reopened, err := OpenStopJournal(path)
+	require.NoError(t, err)
+	assert.Equal(t, []StoppedContainer{app, worker}, reopened.Stopped())
+
+	require.NoError(t, j.remove(StoppedContainer{ID: "abc"}))
+	assert.Equal(t, []StoppedContainer{worker}, j.Stopped())
+
+	require.NoError(t, j.remove(worker))
+	assert.Empty(t, j.Stopped())
+	_, err = os.Stat(path)
+	assert.ErrorIs(t, err, os.ErrNotExist, "an empty journal removes its file")
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Empty(t, entries, "no temporary files are left behind")
+}
+
+func TestStopJournal_Nil(t *testing.T) {
+	var j *StopJournal
+	assert.NoError(t, j.add(StoppedContainer{ID: "abc"}))
+	assert.NoError(t, j.remove(StoppedContainer{ID: "abc"}))
+	assert.Empty(t, j.Stopped())
+}
+
+func TestOpenStopJournal_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "docker-backup.stopped.json")
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0644))
+
+	_, err := OpenStopJournal(path)
+	assert.ErrorContains(t, err, "failed to parse stop journal")
+}
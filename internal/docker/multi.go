@@ -0,0 +1,171 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// NodeSeparator joins a container name and the node it runs on, e.g. "app@node1"
+const NodeSeparator = "@"
+
+// Node is a named Docker endpoint
+type Node struct {
+	Name   string
+	Client *Client
+}
+
+// MultiClient manages the Docker endpoints the daemon watches. With a single
+// unnamed node IDs and names are used unchanged; containers of named nodes are
+// qualified with the node so identical containers on two nodes don't collide.
+type MultiClient struct {
+	nodes []*Node
+}
+
+// NewMultiClient wraps the given nodes. A single node may be unnamed.
+func NewMultiClient(nodes ...*Node) *MultiClient {
+	return &MultiClient{nodes: nodes}
+}
+
+// SingleClient wraps one client as the only, unnamed node
+func SingleClient(c *Client) *MultiClient {
+	return NewMultiClient(&Node{Client: c})
+}
+
+// ConnectNodes creates a client for each node without waiting for it to be
+// reachable, so a node that is down at startup doesn't keep the others from
+// being watched. hosts maps node names to Docker hosts, tlsOpts applies to
+// the nodes with a tcp:// host.
+func ConnectNodes(names []string, hosts map[string]string, tlsOpts TLSOptions) (*MultiClient, error) {
+	m := &MultiClient{}
+	for _, name := range names {
+		c, err := newClient(hosts[name], tlsOpts)
+		if err != nil {
+			_ = m.Close()
+			return nil, fmt.Errorf("failed to create client for node %q: %w", name, err)
+		}
+		if _, err := c.cli.Ping(context.Background()); err != nil {
+			slog.Warn("docker node is not reachable, will retry on the next sync", "node", name, "error", err)
+		}
+		m.nodes = append(m.nodes, &Node{Name: name, Client: c})
+	}
+	return m, nil
+}
+
+// Nodes returns the managed nodes
+func (m *MultiClient) Nodes() []*Node {
+	return m.nodes
+}
+
+// Close closes the clients of all nodes
+func (m *MultiClient) Close() error {
+	var errs []error
+	for _, n := range m.nodes {
+		errs = append(errs, n.Client.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Ping checks that the Docker daemon of every node responds
+func (m *MultiClient) Ping(ctx context.Context) error {
+	var errs []error
+	for _, n := range m.nodes {
+		if err := n.Client.Ping(ctx); err != nil {
+			if n.Name != "" {
+				err = fmt.Errorf("node %s: %w", n.Name, err)
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ListContainers returns the running containers of all nodes, and the stopped
+// ones too if includeStopped is set. Nodes that can't be listed are reported
+// in the map, keyed by node name, and don't affect the others. The error is
+// only set when no node could be listed.
+func (m *MultiClient) ListContainers(ctx context.Context, includeStopped bool) ([]ContainerInfo, map[string]error, error) {
+	var result []ContainerInfo
+	var failed map[string]error
+
+	for _, n := range m.nodes {
+		containers, err := n.Client.ListContainers(ctx, includeStopped)
+		if err != nil {
+			if failed == nil {
+				failed = make(map[string]error)
+			}
+			failed[n.Name] = err
+			continue
+		}
+		for i := range containers {
+			result = append(result, *n.qualify(&containers[i]))
+		}
+	}
+
+	if len(failed) > 0 && len(failed) == len(m.nodes) {
+		errs := make([]error, 0, len(failed))
+		for name, err := range failed {
+			if name != "" {
+				err = fmt.Errorf("node %s: %w", name, err)
+			}
+			errs = append(errs, err)
+		}
+		return nil, failed, errors.Join(errs...)
+	}
+
+	return result, failed, nil
+}
+
+// GetContainer inspects the container with the given key (see ContainerInfo.Key)
+// and returns it together with the client of its node
+func (m *MultiClient) GetContainer(ctx context.Context, key string) (*Client, *ContainerInfo, error) {
+	node, id := SplitID(key)
+	n := m.node(node)
+	if n == nil {
+		return nil, nil, fmt.Errorf("unknown docker node %q", node)
+	}
+
+	info, err := n.Client.GetContainer(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return n.Client, n.qualify(info), nil
+}
+
+func (m *MultiClient) node(name string) *Node {
+	for _, n := range m.nodes {
+		if n.Name == name {
+			return n
+		}
+	}
+	return nil
+}
+
+// qualify tags info with the node and appends the node to its name
+func (n *Node) qualify(info *ContainerInfo) *ContainerInfo {
+	if n.Name != "" {
+		info.Node = n.Name
+		info.Name = info.Name + NodeSeparator + n.Name
+	}
+	return info
+}
+
+// QualifyID returns the key of a container on node. IDs of the unnamed node
+// are returned unchanged.
+func QualifyID(node, id string) string {
+	if node == "" {
+		return id
+	}
+	return node + "/" + id
+}
+
+// SplitID splits a container key into its node and container ID
+func SplitID(key string) (node, id string) {
+	node, id, ok := strings.Cut(key, "/")
+	if !ok {
+		return "", key
+	}
+	return node, id
+}
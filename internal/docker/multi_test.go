@@ -0,0 +1,52 @@
+package docker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQualifyID(t *testing.T) {
+	assert.Equal(t, "abc", QualifyID("", "abc"))
+	assert.Equal(t, "node1/abc", QualifyID("node1", "abc"))
+
+	node, id := SplitID("node1/abc")
+	assert.Equal(t, "node1", node)
+	assert.Equal(t, "abc", id)
+
+	node, id = SplitID("abc")
+	assert.Empty(t, node)
+	assert.Equal(t, "abc", id)
+}
+
+func TestNode_Qualify(t *testing.T) {
+	info := (&Node{Name: "node1"}).qualify(&ContainerInfo{ID: "abc", Name: "app"})
+	assert.Equal(t, "app@node1", info.Name)
+	assert.Equal(t, "node1/abc", info.Key())
+
+	// The single-host setup keeps names and IDs unchanged
+	info = (&Node{}).qualify(&ContainerInfo{ID: "abc", Name: "app"})
+	assert.Equal(t, "app", info.Name)
+	assert.Equal(t, "abc", info.Key())
+}
+
+func TestMultiClient_ListContainers_UnreachableNodes(t *testing.T) {
+	clients, err := ConnectNodes([]string{"a", "b"}, map[string]string{
+		"a": "tcp://127.0.0.1:1",
+		"b": "tcp://127.0.0.1:1",
+	}, TLSOptions{})
+	require.NoError(t, err, "unreachable nodes don't prevent startup")
+	defer func() {
+		_ = clients.Close()
+	}()
+
+	containers, failed, err := clients.ListContainers(context.Background(), false)
+	assert.Error(t, err)
+	assert.Empty(t, containers)
+	assert.Len(t, failed, 2)
+
+	_, _, err = clients.GetContainer(context.Background(), "c/abc")
+	assert.ErrorContains(t, err, `unknown docker node "c"`)
+}
@@ -0,0 +1,47 @@
+package docker
+
+import (
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+)
+
+// Podman serves the Docker API on its socket (unix:///run/podman/podman.sock,
+// or $XDG_RUNTIME_DIR/podman/podman.sock when rootless), but some answers
+// differ from Docker's depending on the version. The shims below keep them in
+// line, so the rest of the daemon only sees Docker's behavior.
+
+// podmanActionDied is the action some Podman versions report instead of "die"
+const podmanActionDied events.Action = "died"
+
+// normalizeEvent maps Podman's container event actions to Docker's
+func normalizeEvent(event events.Message) events.Message {
+	if event.Type == events.ContainerEventType && event.Action == podmanActionDied {
+		event.Action = events.ActionDie
+	}
+	return event
+}
+
+// mountType returns the type of a mount point. Podman may leave it empty, or
+// report named volumes as binds; a volume is recognized by its name and
+// driver, which Docker only sets for volumes.
+func mountType(m container.MountPoint) string {
+	if m.Name != "" && m.Driver != "" {
+		return "volume"
+	}
+	if m.Type == "" {
+		return "bind"
+	}
+	return string(m.Type)
+}
+
+// usesVolume reports whether the container mounts the named volume. Podman
+// versions without the volume filter of the container list return every
+// container, so the result is checked again.
+func usesVolume(info *ContainerInfo, volumeName string) bool {
+	for _, m := range info.Mounts {
+		if m.Type == "volume" && m.Name == volumeName {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFakePodman serves the Docker-compatible API the way Podman versions
+// that differ from Docker answer it: named volumes reported as binds, mounts
+// without a type, "died" events and a container list ignoring the volume
+// filter
+func newFakePodman(t *testing.T) *Client {
+	t.Helper()
+	inspect := map[string]any{
+		"db": map[string]any{
+			"Id":     "db",
+			"Name":   "db",
+			"Config": map[string]any{"Labels": map[string]string{"docker-backup.enable": "true"}},
+			"State":  map[string]any{"Running": true},
+			"Mounts": []map[string]any{
+				{"Type": "bind", "Name": "pgdata", "Driver": "local", "Source": "/var/lib/containers/storage/volumes/pgdata/_data", "Destination": "/var/lib/postgresql/data"},
+				{"Source": "/srv/db/conf", "Destination": "/etc/postgresql"},
+			},
+		},
+		"web": map[string]any{
+			"Id":     "web",
+			"Name":   "web",
+			"Config": map[string]any{},
+			"State":  map[string]any{"Running": true},
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Api-Version", "1.41")
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/events"):
+			assert.Contains(t, r.URL.Query().Get("filters"), `"died"`)
+			enc := json.NewEncoder(w)
+			_ = enc.Encode(map[string]any{"Type": "container", "Action": "start", "Actor": map[string]any{"ID": "db"}})
+			_ = enc.Encode(map[string]any{"Type": "container", "Action": "died", "Actor": map[string]any{"ID": "db"}})
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			_ = json.NewEncoder(w).Encode([]map[string]any{{"Id": "db"}, {"Id": "web"}})
+		case strings.HasSuffix(r.URL.Path, "/json"):
+			parts := strings.Split(r.URL.Path, "/")
+			_ = json.NewEncoder(w).Encode(inspect[parts[len(parts)-2]])
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewClient("tcp://" + server.Listener.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestPodman_MountTypes(t *testing.T) {
+	client := newFakePodman(t)
+
+	info, err := client.GetContainer(context.Background(), "db")
+	require.NoError(t, err)
+	require.Len(t, info.Mounts, 2)
+	assert.Equal(t, "volume", info.Mounts[0].Type)
+	assert.Equal(t, "pgdata", info.Mounts[0].Name)
+	assert.Equal(t, "bind", info.Mounts[1].Type)
+
+	mount, ok := info.MountAt("/var/lib/postgresql/data/base")
+	require.True(t, ok)
+	assert.Equal(t, "pgdata", mount.Name)
+}
+
+func TestPodman_GetContainersUsingVolume(t *testing.T) {
+	client := newFakePodman(t)
+
+	containers, err := client.GetContainersUsingVolume(context.Background(), "pgdata")
+	require.NoError(t, err)
+	require.Len(t, containers, 1, "containers the filter should have removed are skipped")
+	assert.Equal(t, "db", containers[0].ID)
+}
+
+func TestPodman_Events(t *testing.T) {
+	client := newFakePodman(t)
+
+	received := make(chan events.Message, 10)
+	watcher := NewWatcher(SingleClient(client), func(_ context.Context, event events.Message) {
+		if event.Action != "sync" {
+			received <- event
+		}
+	}, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watcher.Start(ctx)
+
+	var actions []events.Action
+	for len(actions) < 2 {
+		select {
+		case event := <-received:
+			assert.Equal(t, "db", event.Actor.ID)
+			actions = append(actions, event.Action)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no events received")
+		}
+	}
+	assert.Equal(t, []events.Action{events.ActionStart, events.ActionDie}, actions)
+}
+
+func TestMountType_Docker(t *testing.T) {
+	assert.Equal(t, "volume", mountType(container.MountPoint{Type: "volume", Name: "pgdata", Driver: "local"}))
+	assert.Equal(t, "bind", mountType(container.MountPoint{Type: "bind", Source: "/srv/conf"}))
+	assert.Equal(t, "tmpfs", mountType(container.MountPoint{Type: "tmpfs"}))
+}
@@ -0,0 +1,102 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+)
+
+// SandboxLabel marks throwaway containers created for restore tests, its
+// value is the name of the container they copy
+const SandboxLabel = "docker-backup.sandbox"
+
+// CreateSandbox creates and starts a throwaway copy of the container
+// sourceID to restore backups into, from img or the source's image if img is
+// empty. The copy gets the source's environment, command and user, but none
+// of its labels, ports or networks, and each mount is replaced by a fresh
+// anonymous volume, so nothing done in the sandbox reaches the source's data.
+// The returned info describes the sandbox with every mount standing in for
+// the source's, see MountInfo.Origin. Remove it with RemoveSandbox.
+func (c *Client) CreateSandbox(ctx context.Context, sourceID, img string) (*ContainerInfo, error) {
+	source, err := c.cli.ContainerInspect(ctx, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	if img == "" {
+		img = source.Config.Image
+	}
+	if err := c.ensureImage(ctx, img); err != nil {
+		return nil, err
+	}
+
+	origins := make(map[string]MountInfo, len(source.Mounts))
+	mounts := make([]mount.Mount, 0, len(source.Mounts))
+	for _, m := range source.Mounts {
+		origin := MountInfo{Type: mountType(m), Name: m.Name, Destination: m.Destination}
+		origins[path.Clean(m.Destination)] = origin
+
+		sandboxMount := mount.Mount{Type: mount.TypeVolume, Target: m.Destination}
+		if origin.Type == "tmpfs" {
+			sandboxMount.Type = mount.TypeTmpfs
+		}
+		mounts = append(mounts, sandboxMount)
+	}
+
+	sourceName := strings.TrimPrefix(source.Name, "/")
+	name := fmt.Sprintf("%s-restore-test-%s", sourceName, strconv.FormatInt(time.Now().UnixNano(), 36))
+	created, err := c.cli.ContainerCreate(ctx, &container.Config{
+		Image:      img,
+		Env:        source.Config.Env,
+		Cmd:        source.Config.Cmd,
+		Entrypoint: source.Config.Entrypoint,
+		User:       source.Config.User,
+		WorkingDir: source.Config.WorkingDir,
+		Labels:     map[string]string{SandboxLabel: sourceName},
+	}, &container.HostConfig{
+		// No network, so the copy can't reach the services the source talks to
+		NetworkMode: "none",
+		Mounts:      mounts,
+	}, nil, nil, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sandbox container: %w", err)
+	}
+
+	info, err := c.startSandbox(ctx, created.ID)
+	if err != nil {
+		// The sandbox is of no use to the caller if it didn't start
+		if removeErr := c.RemoveSandbox(created.ID); removeErr != nil {
+			slog.Warn("failed to remove sandbox container", "container", created.ID, "error", removeErr)
+		}
+		return nil, err
+	}
+
+	for i, m := range info.Mounts {
+		if origin, ok := origins[path.Clean(m.Destination)]; ok {
+			info.Mounts[i].Type = origin.Type
+			info.Mounts[i].Origin = origin.Name
+		}
+	}
+	return info, nil
+}
+
+func (c *Client) startSandbox(ctx context.Context, id string) (*ContainerInfo, error) {
+	if err := c.cli.ContainerStart(ctx, id, container.StartOptions{}); err != nil {
+		return nil, fmt.Errorf("failed to start sandbox container: %w", err)
+	}
+	return c.GetContainer(ctx, id)
+}
+
+// RemoveSandbox removes a sandbox container and its volumes. It doesn't take
+// a context, so the sandbox is removed even if the restore test was cancelled.
+func (c *Client) RemoveSandbox(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return c.cli.ContainerRemove(ctx, id, container.RemoveOptions{Force: true, RemoveVolumes: true})
+}
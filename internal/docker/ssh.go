@@ -0,0 +1,164 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// sshConnectTimeout bounds how long ssh waits for the remote host
+const sshConnectTimeout = 30
+
+// sshArgs returns the ssh arguments that run "docker system dial-stdio" on
+// the host of an ssh://[user@]host[:port] URL, the way the docker CLI does
+func sshArgs(host string) ([]string, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ssh host %q: %w", host, err)
+	}
+	if u.Scheme != "ssh" || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid ssh host %q: expected ssh://[user@]host[:port]", host)
+	}
+	if _, ok := u.User.Password(); ok {
+		return nil, fmt.Errorf("invalid ssh host %q: passwords are not supported, use a key", host)
+	}
+	if u.Path != "" && u.Path != "/" || u.RawQuery != "" {
+		return nil, fmt.Errorf("invalid ssh host %q: unexpected path or query", host)
+	}
+
+	// BatchMode keeps ssh from waiting for a password prompt nobody answers
+	args := []string{"-o", "BatchMode=yes", "-o", fmt.Sprintf("ConnectTimeout=%d", sshConnectTimeout)}
+	if user := u.User.Username(); user != "" {
+		args = append(args, "-l", user)
+	}
+	if port := u.Port(); port != "" {
+		args = append(args, "-p", port)
+	}
+	return append(args, "--", u.Hostname(), "docker", "system", "dial-stdio"), nil
+}
+
+// sshDialer returns a dialer that connects to the Docker daemon of an ssh://
+// host through the ssh binary. Every connection runs its own ssh process.
+func sshDialer(host string) (func(ctx context.Context, network, addr string) (net.Conn, error), error) {
+	args, err := sshArgs(host)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return newCommandConn("ssh", args...)
+	}, nil
+}
+
+// commandConn is a net.Conn over the stdin and stdout of a command
+type commandConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	stderr *tailBuffer
+
+	done    chan struct{}
+	waitErr error
+
+	closeOnce sync.Once
+}
+
+func newCommandConn(name string, args ...string) (*commandConn, error) {
+	// Not bound to the dial context, the connection outlives the request
+	// that opened it in the HTTP client's pool
+	cmd := exec.Command(name, args...)
+	c := &commandConn{cmd: cmd, stderr: &tailBuffer{max: maxErrorOutput}, done: make(chan struct{})}
+	cmd.Stderr = c.stderr
+
+	// Own pipes instead of StdinPipe and StdoutPipe, which Wait closes while
+	// the output may still be read
+	stdinR, stdinW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		_ = stdinR.Close()
+		_ = stdinW.Close()
+		return nil, err
+	}
+	cmd.Stdin, cmd.Stdout = stdinR, stdoutW
+	c.stdin, c.stdout = stdinW, stdoutR
+
+	err = cmd.Start()
+	_ = stdinR.Close()
+	_ = stdoutW.Close()
+	if err != nil {
+		_ = stdinW.Close()
+		_ = stdoutR.Close()
+		return nil, fmt.Errorf("failed to start %s: %w", name, err)
+	}
+
+	go func() {
+		c.waitErr = cmd.Wait()
+		close(c.done)
+	}()
+	return c, nil
+}
+
+// Read reports why the command exited when its output ends with an error
+func (c *commandConn) Read(p []byte) (int, error) {
+	n, err := c.stdout.Read(p)
+	if err == nil {
+		return n, nil
+	}
+	select {
+	case <-c.done:
+	case <-time.After(time.Second):
+		return n, err
+	}
+	if c.waitErr != nil {
+		if out := c.stderr.String(); out != "" {
+			return n, fmt.Errorf("%s: %w: %s", c.cmd.Path, c.waitErr, out)
+		}
+		return n, fmt.Errorf("%s: %w", c.cmd.Path, c.waitErr)
+	}
+	return n, err
+}
+
+func (c *commandConn) Write(p []byte) (int, error) {
+	return c.stdin.Write(p)
+}
+
+// CloseWrite closes stdin, so an attached exec sees the end of its input
+func (c *commandConn) CloseWrite() error {
+	return c.stdin.Close()
+}
+
+func (c *commandConn) Close() error {
+	c.closeOnce.Do(func() {
+		_ = c.stdin.Close()
+		select {
+		case <-c.done:
+		default:
+			_ = c.cmd.Process.Kill()
+			<-c.done
+		}
+		_ = c.stdout.Close()
+	})
+	return nil
+}
+
+func (c *commandConn) LocalAddr() net.Addr  { return commandAddr{} }
+func (c *commandConn) RemoteAddr() net.Addr { return commandAddr{} }
+
+// Deadlines are not supported by pipes to a process, the HTTP client's
+// context cancellation closes the connection instead
+func (c *commandConn) SetDeadline(time.Time) error      { return nil }
+func (c *commandConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *commandConn) SetWriteDeadline(time.Time) error { return nil }
+
+type commandAddr struct{}
+
+func (commandAddr) Network() string { return "command" }
+func (commandAddr) String() string  { return "command" }
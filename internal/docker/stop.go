@@ -0,0 +1,81 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// StopVolumeUsers stops all running containers using any of the given volumes,
+// so their contents can be read or replaced while nothing writes to them. The
+// returned function starts the stopped containers again, even after ctx was
+// cancelled. If a container fails to stop, the ones stopped so far are started
+// again and the error is returned. Stopped containers are recorded in the stop
+// journal until they run again.
+func (c *Client) StopVolumeUsers(ctx context.Context, volumeNames []string, timeout time.Duration) (func(ctx context.Context), error) {
+	seen := make(map[string]bool)
+	var stopped []string
+
+	restart := func(ctx context.Context) {
+		for _, containerID := range stopped {
+			c.restartContainer(ctx, containerID)
+		}
+	}
+
+	for _, volumeName := range volumeNames {
+		containers, err := c.GetContainersUsingVolume(ctx, volumeName)
+		if err != nil {
+			slog.Warn("failed to get containers using volume",
+				"volume", volumeName,
+				"error", err,
+			)
+			continue
+		}
+
+		for _, ctr := range containers {
+			if seen[ctr.ID] {
+				continue
+			}
+			seen[ctr.ID] = true
+
+			if !ctr.Running {
+				continue
+			}
+
+			slog.Debug("stopping container using volume",
+				"container", ctr.Name,
+				"volume", volumeName,
+			)
+			if err := c.stopContainer(ctx, ctr, timeout); err != nil {
+				restart(ctx)
+				return nil, fmt.Errorf("failed to stop container %s: %w", ctr.Name, err)
+			}
+			stopped = append(stopped, ctr.ID)
+		}
+	}
+
+	return restart, nil
+}
+
+// StopPathUsers stops the containers using the storage behind the container
+// path p: every user of the volume if p is on a named volume, otherwise just
+// the container itself. The returned function starts them again.
+func (c *Client) StopPathUsers(ctx context.Context, container *ContainerInfo, p string, timeout time.Duration) (func(ctx context.Context), error) {
+	if mount, ok := container.MountAt(p); ok && mount.Type == "volume" && mount.Name != "" {
+		return c.StopVolumeUsers(ctx, []string{mount.Name}, timeout)
+	}
+
+	if !container.Running {
+		return func(context.Context) {}, nil
+	}
+
+	slog.Debug("stopping container", "container", container.Name)
+	if err := c.stopContainer(ctx, *container, timeout); err != nil {
+		return nil, fmt.Errorf("failed to stop container %s: %w", container.Name, err)
+	}
+
+	return func(ctx context.Context) {
+		c.restartContainer(ctx, container.ID)
+	}, nil
+}
@@ -11,17 +11,17 @@ import (
 // EventHandler is called when a container event occurs
 type EventHandler func(ctx context.Context, event events.Message)
 
-// Watcher monitors Docker container events
+// Watcher monitors Docker container events on all nodes
 type Watcher struct {
-	client       *Client
+	clients      *MultiClient
 	handler      EventHandler
 	pollInterval time.Duration
 }
 
 // NewWatcher creates a new container watcher
-func NewWatcher(client *Client, handler EventHandler, pollInterval time.Duration) *Watcher {
+func NewWatcher(clients *MultiClient, handler EventHandler, pollInterval time.Duration) *Watcher {
 	return &Watcher{
-		client:       client,
+		clients:      clients,
 		handler:      handler,
 		pollInterval: pollInterval,
 	}
@@ -29,14 +29,16 @@ func NewWatcher(client *Client, handler EventHandler, pollInterval time.Duration
 
 // Start begins watching for container events
 func (w *Watcher) Start(ctx context.Context) {
-	// Start event stream
-	go w.watchEvents(ctx)
+	// Start one event stream per node so each reconnects on its own
+	for _, node := range w.clients.Nodes() {
+		go w.watchEvents(ctx, node)
+	}
 
 	// Also do periodic polling as a fallback
 	go w.pollContainers(ctx)
 }
 
-func (w *Watcher) watchEvents(ctx context.Context) {
+func (w *Watcher) watchEvents(ctx context.Context, node *Node) {
 	for {
 		select {
 		case <-ctx.Done():
@@ -44,7 +46,7 @@ func (w *Watcher) watchEvents(ctx context.Context) {
 		default:
 		}
 
-		eventsChan, errChan := w.client.WatchEvents(ctx)
+		eventsChan, errChan := node.Client.WatchEvents(ctx)
 
 	innerLoop:
 		for {
@@ -52,10 +54,12 @@ func (w *Watcher) watchEvents(ctx context.Context) {
 			case <-ctx.Done():
 				return
 			case event := <-eventsChan:
+				event = normalizeEvent(event)
+				event.Actor.ID = QualifyID(node.Name, event.Actor.ID)
 				w.handler(ctx, event)
 			case err := <-errChan:
 				if err != nil {
-					slog.Warn("docker event stream error, reconnecting", "error", err)
+					slog.Warn("docker event stream error, reconnecting", "node", node.Name, "error", err)
 					time.Sleep(5 * time.Second)
 				}
 				break innerLoop
@@ -0,0 +1,115 @@
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// AgeExtension is appended to the key of backups encrypted with age
+const AgeExtension = ".age"
+
+// ageMagic starts every binary age file
+var ageMagic = []byte("age-encryption.org/")
+
+// Age encrypts backups to age recipients and decrypts them with age identities.
+// Either side may be missing: a host that only restores needs no recipients,
+// and a host that only writes backups doesn't need the private key.
+type Age struct {
+	recipients []age.Recipient
+	identities []age.Identity
+}
+
+// ParseAge parses age recipient public keys ("age1...") and reads the
+// identities from identityFile, a file in the format written by age-keygen.
+// Without recipients and identity file a nil Age is returned, which means
+// age encryption is disabled.
+func ParseAge(recipients []string, identityFile string) (*Age, error) {
+	a := &Age{}
+	for _, spec := range recipients {
+		for _, recipient := range strings.Split(spec, ",") {
+			recipient = strings.TrimSpace(recipient)
+			if recipient == "" {
+				continue
+			}
+			r, err := age.ParseX25519Recipient(recipient)
+			if err != nil {
+				return nil, fmt.Errorf("invalid age recipient: %w", err)
+			}
+			a.recipients = append(a.recipients, r)
+		}
+	}
+
+	if identityFile != "" {
+		f, err := os.Open(identityFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open age identity file: %w", err)
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+
+		identities, err := age.ParseIdentities(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse age identity file %s: %w", identityFile, err)
+		}
+		a.identities = identities
+	}
+
+	if len(a.recipients) == 0 && len(a.identities) == 0 {
+		return nil, nil
+	}
+	return a, nil
+}
+
+// Recipients returns the number of recipients new backups are encrypted to
+func (a *Age) Recipients() int {
+	return len(a.recipients)
+}
+
+// CanEncrypt reports whether recipients are configured
+func (a *Age) CanEncrypt() bool {
+	return a != nil && len(a.recipients) > 0
+}
+
+// CanDecrypt reports whether an identity is configured
+func (a *Age) CanDecrypt() bool {
+	return a != nil && len(a.identities) > 0
+}
+
+// IsAge reports whether r starts with an age header. Nothing is consumed from r.
+func IsAge(r *bufio.Reader) bool {
+	head, err := r.Peek(len(ageMagic))
+	return err == nil && bytes.Equal(head, ageMagic)
+}
+
+// Encrypt returns a writer that encrypts everything written to it to all
+// recipients and writes it to w. Close must be called to write the final
+// chunk; it does not close w.
+func (a *Age) Encrypt(w io.Writer) (io.WriteCloser, error) {
+	if !a.CanEncrypt() {
+		return nil, fmt.Errorf("no age recipients configured")
+	}
+	enc, err := age.Encrypt(w, a.recipients...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start age encryption: %w", err)
+	}
+	return enc, nil
+}
+
+// Decrypt returns a reader of the plaintext of the age file read from r
+func (a *Age) Decrypt(r io.Reader) (io.Reader, error) {
+	if !a.CanDecrypt() {
+		return nil, fmt.Errorf("backup is encrypted with age but no age identity is configured")
+	}
+	plain, err := age.Decrypt(r, a.identities...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt age backup: %w", err)
+	}
+	return plain, nil
+}
@@ -0,0 +1,85 @@
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeIdentityFile(t *testing.T, identity *age.X25519Identity) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "key.txt")
+	content := "# created: 2026-01-15T03:00:00Z\n# public key: " + identity.Recipient().String() + "\n" + identity.String() + "\n"
+	require.NoError(t, os.WriteFile(p, []byte(content), 0600))
+	return p
+}
+
+func TestParseAge_Disabled(t *testing.T) {
+	a, err := ParseAge(nil, "")
+	require.NoError(t, err)
+	assert.Nil(t, a)
+	assert.False(t, a.CanEncrypt())
+	assert.False(t, a.CanDecrypt())
+}
+
+func TestParseAge_Invalid(t *testing.T) {
+	_, err := ParseAge([]string{"age1notakey"}, "")
+	assert.Error(t, err)
+
+	_, err = ParseAge(nil, filepath.Join(t.TempDir(), "missing.txt"))
+	assert.Error(t, err)
+
+	p := filepath.Join(t.TempDir(), "key.txt")
+	require.NoError(t, os.WriteFile(p, []byte("AGE-SECRET-KEY-INVALID\n"), 0600))
+	_, err = ParseAge(nil, p)
+	assert.Error(t, err)
+}
+
+func TestAge_RoundTrip(t *testing.T) {
+	first, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	second, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	writer, err := ParseAge([]string{first.Recipient().String() + ", " + second.Recipient().String()}, "")
+	require.NoError(t, err)
+	assert.True(t, writer.CanEncrypt())
+	assert.False(t, writer.CanDecrypt())
+	assert.Equal(t, 2, writer.Recipients())
+
+	var buf bytes.Buffer
+	w, err := writer.Encrypt(&buf)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("archive"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	assert.True(t, IsAge(bufio.NewReader(bytes.NewReader(buf.Bytes()))))
+	assert.False(t, IsAge(bufio.NewReader(bytes.NewReader([]byte("plain archive")))))
+
+	_, err = writer.Decrypt(bytes.NewReader(buf.Bytes()))
+	assert.ErrorContains(t, err, "no age identity")
+
+	// Any of the recipients can decrypt
+	reader, err := ParseAge(nil, writeIdentityFile(t, second))
+	require.NoError(t, err)
+	plain, err := reader.Decrypt(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	got, err := io.ReadAll(plain)
+	require.NoError(t, err)
+	assert.Equal(t, "archive", string(got))
+
+	other, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	wrong, err := ParseAge(nil, writeIdentityFile(t, other))
+	require.NoError(t, err)
+	_, err = wrong.Decrypt(bytes.NewReader(buf.Bytes()))
+	assert.Error(t, err)
+}
@@ -0,0 +1,189 @@
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKey(t *testing.T) string {
+	t.Helper()
+	key := make([]byte, KeySize)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func encrypt(t *testing.T, k *Keyring, plain []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := k.Encrypt(&buf)
+	require.NoError(t, err)
+	_, err = w.Write(plain)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func decrypt(k *Keyring, data []byte) ([]byte, error) {
+	r, err := k.Decrypt(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestParseKeyring(t *testing.T) {
+	k1, k2 := testKey(t), testKey(t)
+
+	k, err := ParseKeyring("2025="+k1+", 2026="+k2, "")
+	require.NoError(t, err)
+	assert.Equal(t, "2026", k.Current())
+	assert.Equal(t, []string{"2025", "2026"}, k.IDs())
+	assert.True(t, k.Has("2025"))
+	assert.False(t, k.Has("2024"))
+
+	k, err = ParseKeyring("2025="+k1+",2026="+k2, "2025")
+	require.NoError(t, err)
+	assert.Equal(t, "2025", k.Current())
+
+	k, err = ParseKeyring("", "")
+	require.NoError(t, err)
+	assert.Nil(t, k)
+
+	short := base64.StdEncoding.EncodeToString([]byte("short"))
+	for name, tc := range map[string]struct{ spec, current string }{
+		"current without keys": {"", "a"},
+		"missing separator":    {"a" + k1, ""},
+		"empty id":             {"=" + k1, ""},
+		"invalid id":           {"a b=" + k1, ""},
+		"duplicate id":         {"a=" + k1 + ",a=" + k2, ""},
+		"invalid base64":       {"a=not-base64!", ""},
+		"wrong size":           {"a=" + short, ""},
+		"unknown current":      {"a=" + k1, "b"},
+		"only separators":      {",,", ""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseKeyring(tc.spec, tc.current)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestEncryptDecrypt_RoundTrip(t *testing.T) {
+	k, err := ParseKeyring("a="+testKey(t), "")
+	require.NoError(t, err)
+
+	big := make([]byte, 3*chunkSize+123)
+	_, err = rand.Read(big)
+	require.NoError(t, err)
+
+	for name, plain := range map[string][]byte{
+		"empty":          {},
+		"small":          []byte("hello world"),
+		"exact chunk":    big[:chunkSize],
+		"exact chunks":   big[:2*chunkSize],
+		"several chunks": big,
+	} {
+		t.Run(name, func(t *testing.T) {
+			data := encrypt(t, k, plain)
+			assert.False(t, bytes.Contains(data, []byte("hello")))
+
+			got, err := decrypt(k, data)
+			require.NoError(t, err)
+			assert.Equal(t, len(plain), len(got))
+			assert.True(t, bytes.Equal(plain, got))
+		})
+	}
+}
+
+func TestDecrypt_SelectsKeyByID(t *testing.T) {
+	k1, k2 := testKey(t), testKey(t)
+
+	old, err := ParseKeyring("old="+k1, "")
+	require.NoError(t, err)
+	data := encrypt(t, old, []byte("written with the old key"))
+
+	rotated, err := ParseKeyring("old="+k1+",new="+k2, "")
+	require.NoError(t, err)
+	require.Equal(t, "new", rotated.Current())
+
+	got, err := decrypt(rotated, data)
+	require.NoError(t, err)
+	assert.Equal(t, "written with the old key", string(got))
+
+	newOnly, err := ParseKeyring("new="+k2, "")
+	require.NoError(t, err)
+	_, err = decrypt(newOnly, data)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestDecrypt_DetectsTampering(t *testing.T) {
+	k, err := ParseKeyring("a="+testKey(t), "")
+	require.NoError(t, err)
+
+	plain := make([]byte, 2*chunkSize+10)
+	data := encrypt(t, k, plain)
+
+	flipped := bytes.Clone(data)
+	flipped[len(flipped)-20] ^= 1
+	_, err = decrypt(k, flipped)
+	assert.Error(t, err)
+
+	// Dropping the final chunk leaves a stream ending on a non-final chunk
+	headerLen := len(magic) + 2 + 1 + saltSize + noncePrefixSize
+	sealed := chunkSize + 16
+	_, err = decrypt(k, data[:headerLen+2*sealed])
+	assert.Error(t, err)
+
+	_, err = decrypt(k, data[:len(data)-5])
+	assert.Error(t, err)
+
+	// The key ID is authenticated as part of the header
+	other, err := ParseKeyring("b="+testKey(t), "")
+	require.NoError(t, err)
+	_, err = decrypt(other, data)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestDecrypt_WrongKeyWithSameID(t *testing.T) {
+	k1, err := ParseKeyring("a="+testKey(t), "")
+	require.NoError(t, err)
+	k2, err := ParseKeyring("a="+testKey(t), "")
+	require.NoError(t, err)
+
+	_, err = decrypt(k2, encrypt(t, k1, []byte("secret")))
+	assert.ErrorContains(t, err, "wrong key")
+}
+
+func TestPeekKeyID(t *testing.T) {
+	k, err := ParseKeyring("key-1="+testKey(t), "")
+	require.NoError(t, err)
+
+	br := bufio.NewReader(bytes.NewReader(encrypt(t, k, []byte("data"))))
+	id, encrypted, err := PeekKeyID(br)
+	require.NoError(t, err)
+	assert.True(t, encrypted)
+	assert.Equal(t, "key-1", id)
+
+	// Peeking leaves the header for Decrypt
+	r, err := k.Decrypt(br)
+	require.NoError(t, err)
+	got, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(got))
+
+	for _, plain := range []string{"", "abc", "plain archive contents"} {
+		id, encrypted, err := PeekKeyID(bufio.NewReader(strings.NewReader(plain)))
+		require.NoError(t, err)
+		assert.False(t, encrypted)
+		assert.Empty(t, id)
+	}
+}
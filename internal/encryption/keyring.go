@@ -0,0 +1,121 @@
+// Package encryption encrypts backup archives before they are stored and
+// decrypts them on restore. Every encrypted object starts with a header naming
+// the key it was written with, so keys can be rotated while older backups stay
+// readable.
+package encryption
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// KeySize is the size of a master key in bytes
+const KeySize = 32
+
+// maxKeyIDLen is the longest key ID the header can hold
+const maxKeyIDLen = 255
+
+// Keyring holds the master keys by ID and the key new backups are written with
+type Keyring struct {
+	keys    map[string][]byte
+	current string
+}
+
+// ParseKeyring parses a comma-separated list of id=base64key pairs. current
+// selects the key new backups use; when empty the last listed key is used.
+// An empty spec returns a nil keyring, which means encryption is disabled.
+func ParseKeyring(spec, current string) (*Keyring, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		if current != "" {
+			return nil, fmt.Errorf("current encryption key %q set but no encryption keys configured", current)
+		}
+		return nil, nil
+	}
+
+	k := &Keyring{keys: make(map[string][]byte)}
+	var last string
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		// Split on the first "=" only, base64 padding uses it too
+		id, encoded, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid encryption key entry (expected id=key)")
+		}
+		id = strings.TrimSpace(id)
+		if err := validateKeyID(id); err != nil {
+			return nil, err
+		}
+		if _, exists := k.keys[id]; exists {
+			return nil, fmt.Errorf("encryption key %q listed twice", id)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key %q: %w", id, err)
+		}
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("invalid encryption key %q: must be %d bytes, got %d", id, KeySize, len(key))
+		}
+
+		k.keys[id] = key
+		last = id
+	}
+
+	if len(k.keys) == 0 {
+		return nil, fmt.Errorf("no encryption keys found")
+	}
+
+	k.current = last
+	if current != "" {
+		if _, ok := k.keys[current]; !ok {
+			return nil, fmt.Errorf("current encryption key %q is not in the keyring", current)
+		}
+		k.current = current
+	}
+
+	return k, nil
+}
+
+// validateKeyID checks that id is usable in the object header and in logs
+func validateKeyID(id string) error {
+	if id == "" {
+		return fmt.Errorf("encryption key ID must not be empty")
+	}
+	if len(id) > maxKeyIDLen {
+		return fmt.Errorf("encryption key ID %q is longer than %d characters", id, maxKeyIDLen)
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return fmt.Errorf("invalid encryption key ID %q: only letters, digits, '-', '_' and '.' are allowed", id)
+		}
+	}
+	return nil
+}
+
+// Current returns the ID of the key new backups are encrypted with
+func (k *Keyring) Current() string {
+	return k.current
+}
+
+// IDs returns the IDs of all keys in the keyring, sorted
+func (k *Keyring) IDs() []string {
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Has reports whether the keyring holds the key with the given ID
+func (k *Keyring) Has(id string) bool {
+	_, ok := k.keys[id]
+	return ok
+}
@@ -0,0 +1,306 @@
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Extension is appended to the key of encrypted backups
+const Extension = ".enc"
+
+// Object layout:
+//
+//	magic (6) | version (1) | key ID length (1) | key ID | salt (32) | nonce prefix (7)
+//	chunk...
+//
+// Each chunk is up to chunkSize bytes of plaintext sealed with AES-256-GCM under a
+// key derived from the master key and the salt. The nonce is the prefix, a
+// big-endian chunk counter and a flag marking the final chunk, so chunks can't be
+// reordered, dropped or truncated without failing authentication. The header is
+// the additional data of every chunk.
+const (
+	formatVersion   = 1
+	saltSize        = 32
+	noncePrefixSize = 7
+	chunkSize       = 64 << 10
+	kdfInfo         = "docker-backup archive v1"
+)
+
+var magic = []byte("DBKENC")
+
+// ErrUnknownKey is returned when an object was encrypted with a key that is not in the keyring
+var ErrUnknownKey = errors.New("encryption key not in keyring")
+
+// PeekKeyID reports whether r starts with an encrypted object header and returns
+// the ID of the key it was written with. Nothing is consumed from r.
+func PeekKeyID(r *bufio.Reader) (string, bool, error) {
+	head, err := r.Peek(len(magic))
+	if err != nil || !bytes.Equal(head, magic) {
+		// Short or empty input is left for the reader of the plain archive to report
+		return "", false, nil
+	}
+
+	fixed, err := r.Peek(len(magic) + 2)
+	if err != nil {
+		return "", true, fmt.Errorf("failed to read encryption header: %w", err)
+	}
+	if version := fixed[len(magic)]; version != formatVersion {
+		return "", true, fmt.Errorf("unsupported encryption format version %d", version)
+	}
+	idLen := int(fixed[len(magic)+1])
+
+	full, err := r.Peek(len(magic) + 2 + idLen)
+	if err != nil {
+		return "", true, fmt.Errorf("failed to read encryption header: %w", err)
+	}
+	return string(full[len(magic)+2:]), true, nil
+}
+
+// Encrypt returns a writer that encrypts everything written to it with the
+// current key and writes it to w. Close must be called to write the final
+// chunk; it does not close w.
+func (k *Keyring) Encrypt(w io.Writer) (io.WriteCloser, error) {
+	salt := make([]byte, saltSize)
+	prefix := make([]byte, noncePrefixSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	id := k.current
+	header := make([]byte, 0, len(magic)+2+len(id)+saltSize+noncePrefixSize)
+	header = append(header, magic...)
+	header = append(header, formatVersion, byte(len(id)))
+	header = append(header, id...)
+	header = append(header, salt...)
+	header = append(header, prefix...)
+
+	aead, err := newAEAD(k.keys[id], salt)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return nil, fmt.Errorf("failed to write encryption header: %w", err)
+	}
+
+	return &writer{
+		dst:    w,
+		aead:   aead,
+		aad:    header,
+		prefix: prefix,
+		buf:    make([]byte, 0, chunkSize),
+		out:    make([]byte, 0, chunkSize+aead.Overhead()),
+	}, nil
+}
+
+// Decrypt reads the header from r and returns a reader of the plaintext. The
+// key is looked up by the ID in the header; ErrUnknownKey is returned if the
+// keyring doesn't have it.
+func (k *Keyring) Decrypt(r io.Reader) (io.Reader, error) {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+
+	id, encrypted, err := PeekKeyID(br)
+	if err != nil {
+		return nil, err
+	}
+	if !encrypted {
+		return nil, fmt.Errorf("not an encrypted backup")
+	}
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("backup was encrypted with key %q: %w", id, ErrUnknownKey)
+	}
+
+	header := make([]byte, len(magic)+2+len(id)+saltSize+noncePrefixSize)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("failed to read encryption header: %w", err)
+	}
+	salt := header[len(header)-saltSize-noncePrefixSize : len(header)-noncePrefixSize]
+	prefix := header[len(header)-noncePrefixSize:]
+
+	aead, err := newAEAD(key, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &reader{
+		src:    br,
+		aead:   aead,
+		aad:    header,
+		prefix: prefix,
+		buf:    make([]byte, chunkSize+aead.Overhead()),
+	}, nil
+}
+
+// newAEAD derives the object key from the master key and salt
+func newAEAD(master, salt []byte) (cipher.AEAD, error) {
+	key, err := hkdf.Key(sha256.New, master, salt, kdfInfo, KeySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive encryption key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return aead, nil
+}
+
+// nonce builds the nonce of a chunk
+func nonce(prefix []byte, counter uint32, last bool) []byte {
+	n := make([]byte, 0, noncePrefixSize+5)
+	n = append(n, prefix...)
+	n = binary.BigEndian.AppendUint32(n, counter)
+	if last {
+		return append(n, 1)
+	}
+	return append(n, 0)
+}
+
+// writer seals plaintext in chunks
+type writer struct {
+	dst     io.Writer
+	aead    cipher.AEAD
+	aad     []byte
+	prefix  []byte
+	counter uint32
+	buf     []byte // pending plaintext
+	out     []byte // sealed chunk
+	closed  bool
+	err     error
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.closed {
+		return 0, fmt.Errorf("write to closed encryption writer")
+	}
+
+	written := 0
+	for len(p) > 0 {
+		// A full chunk is only flushed once more data arrives, so the final
+		// chunk written by Close is never empty unless the whole stream is
+		if len(w.buf) == chunkSize {
+			if err := w.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(w.buf[len(w.buf):chunkSize], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (w *writer) flush(last bool) error {
+	if w.counter == math.MaxUint32 {
+		w.err = fmt.Errorf("encrypted stream exceeds the maximum size")
+		return w.err
+	}
+	w.out = w.aead.Seal(w.out[:0], nonce(w.prefix, w.counter, last), w.buf, w.aad)
+	if _, err := w.dst.Write(w.out); err != nil {
+		w.err = fmt.Errorf("failed to write encrypted data: %w", err)
+		return w.err
+	}
+	w.counter++
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Close writes the final chunk
+func (w *writer) Close() error {
+	if w.closed {
+		return w.err
+	}
+	w.closed = true
+	if w.err != nil {
+		return w.err
+	}
+	return w.flush(true)
+}
+
+// reader opens chunks as they are read
+type reader struct {
+	src     *bufio.Reader
+	aead    cipher.AEAD
+	aad     []byte
+	prefix  []byte
+	counter uint32
+	buf     []byte // sealed chunk
+	plain   []byte // opened, not yet returned plaintext
+	done    bool
+	err     error
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.next(); err != nil {
+			r.err = err
+		}
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+// next reads and opens the following chunk
+func (r *reader) next() error {
+	n, err := io.ReadFull(r.src, r.buf)
+	last := false
+	switch {
+	case err == io.ErrUnexpectedEOF:
+		last = true
+	case err == io.EOF:
+		return fmt.Errorf("encrypted backup is truncated")
+	case err != nil:
+		return fmt.Errorf("failed to read encrypted data: %w", err)
+	default:
+		// A full chunk is the last one if nothing follows it
+		if _, err := r.src.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			return fmt.Errorf("failed to read encrypted data: %w", err)
+		}
+	}
+
+	if n < r.aead.Overhead() {
+		return fmt.Errorf("encrypted backup is truncated")
+	}
+
+	plain, err := r.aead.Open(r.buf[:0], nonce(r.prefix, r.counter, last), r.buf[:n], r.aad)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt backup: wrong key or corrupted data")
+	}
+	r.counter++
+	r.plain = plain
+	r.done = last
+	return nil
+}
@@ -0,0 +1,155 @@
+// Package health serves the liveness and readiness probes of the daemon for
+// orchestrators such as Kubernetes and Docker Compose.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// checkTimeout bounds each readiness check, a check that hangs counts as failed
+const checkTimeout = 10 * time.Second
+
+// cacheTTL is how long a readiness result is reused, so frequent probes don't
+// list every storage pool each time
+const cacheTTL = 5 * time.Second
+
+// Check reports whether a dependency of the daemon is usable
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// CheckResult is the outcome of one check in a readiness response
+type CheckResult struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// Response is the body of both probes
+type Response struct {
+	Status string        `json:"status"` // "ok" or "unavailable"
+	Checks []CheckResult `json:"checks,omitempty"`
+}
+
+// Server serves /healthz and /readyz
+type Server struct {
+	addr   string
+	checks []Check
+	server *http.Server
+	now    func() time.Time
+
+	mu        sync.Mutex
+	cached    []CheckResult
+	checkedAt time.Time
+}
+
+// NewServer creates a health server on addr whose readiness probe runs checks
+func NewServer(addr string, checks ...Check) *Server {
+	return &Server{
+		addr:   addr,
+		checks: checks,
+		now:    time.Now,
+	}
+}
+
+// Handler returns the handler serving both probes
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.handleLive)
+	mux.HandleFunc("/readyz", s.handleReady)
+	return mux
+}
+
+// Start serves the probes until Shutdown is called
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+
+	s.server = &http.Server{
+		Handler:      s.Handler(),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: checkTimeout + 5*time.Second,
+	}
+
+	slog.Info("starting health server", "addr", s.addr)
+	return s.server.Serve(listener)
+}
+
+// Shutdown stops the server
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.server == nil {
+		return nil
+	}
+	return s.server.Shutdown(ctx)
+}
+
+// handleLive answers as long as the daemon serves requests at all
+func (s *Server) handleLive(w http.ResponseWriter, r *http.Request) {
+	writeResponse(w, http.StatusOK, Response{Status: "ok"})
+}
+
+// handleReady runs the checks and answers 503 if any of them failed
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	results := s.results(r.Context())
+
+	status, code := "ok", http.StatusOK
+	for _, result := range results {
+		if !result.OK {
+			status, code = "unavailable", http.StatusServiceUnavailable
+			break
+		}
+	}
+	writeResponse(w, code, Response{Status: status, Checks: results})
+}
+
+// results returns the outcome of the checks, running them concurrently unless
+// a recent result can be reused
+func (s *Server) results(ctx context.Context) []CheckResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cached != nil && s.now().Sub(s.checkedAt) < cacheTTL {
+		return s.cached
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	results := make([]CheckResult, len(s.checks))
+	var wg sync.WaitGroup
+	for i, check := range s.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = CheckResult{Name: check.Name, OK: true}
+			if err := check.Run(checkCtx); err != nil {
+				results[i].OK = false
+				results[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Checks cut short by a probe that gave up don't say anything about the daemon
+	if ctx.Err() == nil {
+		s.cached = results
+		s.checkedAt = s.now()
+	}
+	return results
+}
+
+func writeResponse(w http.ResponseWriter, code int, resp Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,57 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func probe(t *testing.T, s *Server, path string) (int, Response) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var resp Response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return rec.Code, resp
+}
+
+func TestServer_Live(t *testing.T) {
+	s := NewServer(":0", Check{Name: "docker", Run: func(context.Context) error { return errors.New("down") }})
+
+	code, resp := probe(t, s, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", resp.Status)
+}
+
+func TestServer_Ready(t *testing.T) {
+	var storageErr error
+	s := NewServer(":0",
+		Check{Name: "docker", Run: func(context.Context) error { return nil }},
+		Check{Name: "storage:s3", Run: func(context.Context) error { return storageErr }},
+	)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	code, resp := probe(t, s, "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, Response{Status: "ok", Checks: []CheckResult{{Name: "docker", OK: true}, {Name: "storage:s3", OK: true}}}, resp)
+
+	// Results are reused for a moment
+	storageErr = errors.New("access denied")
+	code, _ = probe(t, s, "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+
+	now = now.Add(cacheTTL)
+	code, resp = probe(t, s, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "unavailable", resp.Status)
+	assert.Equal(t, CheckResult{Name: "storage:s3", Error: "access denied"}, resp.Checks[1])
+}
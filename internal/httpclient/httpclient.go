@@ -0,0 +1,162 @@
+// Package httpclient builds the HTTP clients used for outgoing HTTPS calls such
+// as OIDC discovery and notifications.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// caExtensions are the file extensions read from a CA directory
+var caExtensions = []string{".pem", ".crt", ".cer"}
+
+// Options configures the clients built by New
+type Options struct {
+	// CAFile is a PEM file or directory of PEM files trusted in addition to the system CAs
+	CAFile string
+	// Timeout bounds a request including its retries, 0 disables it
+	Timeout time.Duration
+	// DialTimeout bounds establishing a connection
+	DialTimeout time.Duration
+	// ResponseHeaderTimeout bounds waiting for the response headers of one attempt
+	ResponseHeaderTimeout time.Duration
+	// Retry decides how failed attempts are retried
+	Retry RetryPolicy
+}
+
+// DefaultOptions returns the options used for outgoing calls unless configured otherwise
+func DefaultOptions() Options {
+	return Options{
+		Timeout:               60 * time.Second,
+		DialTimeout:           10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		Retry:                 DefaultRetryPolicy(),
+	}
+}
+
+// New returns a client with pooled connections, the timeouts in opts and
+// retries of transient failures. It trusts the system CAs plus the CAs in
+// opts.CAFile.
+func New(opts Options) (*http.Client, error) {
+	transport, err := NewTransport(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Transport: NewRetryTransport(transport, opts.Retry),
+		Timeout:   opts.Timeout,
+	}, nil
+}
+
+// NewTransport returns a pooling transport with the dial, TLS and response
+// header settings of opts. It does not retry.
+func NewTransport(opts Options) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   opts.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.ResponseHeaderTimeout = opts.ResponseHeaderTimeout
+	transport.MaxIdleConnsPerHost = 10
+
+	if opts.CAFile != "" {
+		pool, err := LoadCertPool(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs:    pool,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	return transport, nil
+}
+
+// LoadCertPool returns the system cert pool with the certificates in path
+// added. path is a PEM file or a directory whose .pem, .crt and .cer files are
+// read; subdirectories are ignored.
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificates: %w", err)
+	}
+
+	if !info.IsDir() {
+		if err := appendPEMFile(pool, path); err != nil {
+			return nil, err
+		}
+		return pool, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA directory %s: %w", path, err)
+	}
+
+	loaded := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !hasCAExtension(entry.Name()) {
+			continue
+		}
+		if err := appendPEMFile(pool, filepath.Join(path, entry.Name())); err != nil {
+			return nil, err
+		}
+		loaded++
+	}
+
+	if loaded == 0 {
+		return nil, fmt.Errorf("no CA certificates found in %s (expected %s files)", path, strings.Join(caExtensions, ", "))
+	}
+
+	return pool, nil
+}
+
+func appendPEMFile(pool *x509.CertPool, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read CA file %s: %w", path, err)
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return fmt.Errorf("no PEM certificates found in %s", path)
+	}
+	return nil
+}
+
+func hasCAExtension(name string) bool {
+	return slices.Contains(caExtensions, strings.ToLower(filepath.Ext(name)))
+}
+
+// TLSConfig returns a copy of the TLS settings client dials with, so non-HTTP
+// connections can trust the same CAs. It returns nil when the client only
+// trusts the system CAs.
+func TLSConfig(client *http.Client) *tls.Config {
+	if client == nil {
+		return nil
+	}
+	next := client.Transport
+	if retry, ok := next.(*retryTransport); ok {
+		next = retry.next
+	}
+	transport, ok := next.(*http.Transport)
+	if !ok || transport.TLSClientConfig == nil || transport.TLSClientConfig.RootCAs == nil {
+		return nil
+	}
+	cfg := transport.TLSClientConfig.Clone()
+	cfg.NextProtos = nil // Set up for HTTP/2 by the transport
+	return cfg
+}
@@ -0,0 +1,102 @@
+package httpclient
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeServerCA(t *testing.T, server *httptest.Server, path string) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+}
+
+func TestNew_TrustsCAFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	writeServerCA(t, server, caFile)
+
+	client, err := New(Options{CAFile: caFile})
+	require.NoError(t, err)
+
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	// The default client does not know the test CA
+	_, err = http.DefaultClient.Get(server.URL)
+	assert.Error(t, err)
+}
+
+func TestNew_TrustsCADirectory(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	writeServerCA(t, server, filepath.Join(dir, "internal.crt"))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("not a certificate"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "old"), 0o755))
+
+	client, err := New(Options{CAFile: dir})
+	require.NoError(t, err)
+
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+}
+
+func TestNew_WithoutCA(t *testing.T) {
+	client, err := New(DefaultOptions())
+	require.NoError(t, err)
+	assert.Equal(t, 60*time.Second, client.Timeout)
+	assert.IsType(t, &retryTransport{}, client.Transport)
+}
+
+func TestTLSConfig(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	writeServerCA(t, server, caFile)
+
+	client, err := New(Options{CAFile: caFile, Retry: DefaultRetryPolicy()})
+	require.NoError(t, err)
+	cfg := TLSConfig(client)
+	require.NotNil(t, cfg)
+	assert.NotNil(t, cfg.RootCAs)
+	assert.Empty(t, cfg.NextProtos, "HTTP/2 is not offered to other protocols")
+
+	client, err = New(DefaultOptions())
+	require.NoError(t, err)
+	assert.Nil(t, TLSConfig(client))
+	assert.Nil(t, TLSConfig(nil))
+}
+
+func TestLoadCertPool_Errors(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := LoadCertPool(filepath.Join(dir, "missing.pem"))
+	assert.Error(t, err)
+
+	invalid := filepath.Join(dir, "invalid.pem")
+	require.NoError(t, os.WriteFile(invalid, []byte("garbage"), 0o644))
+	_, err = LoadCertPool(invalid)
+	assert.ErrorContains(t, err, "no PEM certificates found")
+
+	empty := t.TempDir()
+	_, err = LoadCertPool(empty)
+	assert.ErrorContains(t, err, "no CA certificates found")
+}
@@ -0,0 +1,114 @@
+package httpclient
+
+import (
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how a request is retried after a network error or a
+// transient status (429 or 5xx). Waits grow exponentially from BaseDelay, are
+// capped at MaxDelay and jittered so clients don't retry in lockstep.
+type RetryPolicy struct {
+	// Retries is the number of attempts after the first one, 0 disables retries
+	Retries   int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// DefaultRetryPolicy returns the policy used unless configured otherwise
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		Retries:   3,
+		BaseDelay: 500 * time.Millisecond,
+		MaxDelay:  10 * time.Second,
+	}
+}
+
+// delay returns the wait before retry number attempt (starting at 0). A
+// Retry-After header is honoured, but never beyond MaxDelay.
+func (p RetryPolicy) delay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return min(time.Duration(seconds)*time.Second, p.MaxDelay)
+		}
+	}
+
+	d := p.MaxDelay
+	if attempt < 32 {
+		d = min(p.BaseDelay<<attempt, p.MaxDelay)
+	}
+	if d <= 0 {
+		return 0
+	}
+	// Equal jitter: at least half of the backoff, so retries still spread out
+	return d/2 + rand.N(d/2+1)
+}
+
+type retryTransport struct {
+	next   http.RoundTripper
+	policy RetryPolicy
+}
+
+// NewRetryTransport wraps next so transient failures are retried according to
+// policy. Requests whose body can't be replayed are sent once.
+func NewRetryTransport(next http.RoundTripper, policy RetryPolicy) http.RoundTripper {
+	if policy.Retries <= 0 {
+		return next
+	}
+	return &retryTransport{next: next, policy: policy}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	for attempt := 0; ; attempt++ {
+		attemptReq := req
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(req.Context())
+			attemptReq.Body = body
+		}
+
+		resp, err := t.next.RoundTrip(attemptReq)
+		if !replayable || attempt >= t.policy.Retries || !retryable(req, resp, err) {
+			return resp, err
+		}
+
+		wait := t.policy.delay(attempt, resp)
+		if resp != nil {
+			// Drain so the connection can be reused for the next attempt
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			_ = resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	if err != nil {
+		// A cancelled request fails with a network error too, but trying again is pointless
+		return req.Context().Err() == nil
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
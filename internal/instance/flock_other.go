@@ -0,0 +1,10 @@
+//go:build !unix
+
+package instance
+
+import "os"
+
+// tryLock always succeeds on platforms without flock, so no overlap is ever reported
+func tryLock(_ *os.File, _ bool) (bool, error) {
+	return true, nil
+}
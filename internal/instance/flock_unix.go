@@ -0,0 +1,31 @@
+//go:build unix
+
+package instance
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// tryLock attempts a non-blocking flock on file. An exclusive lock is kept until
+// the file is closed; a shared probe lock is released right away.
+func tryLock(file *os.File, exclusive bool) (bool, error) {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+
+	if err := syscall.Flock(int(file.Fd()), how|syscall.LOCK_NB); err != nil {
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if !exclusive {
+		_ = syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,126 @@
+// Package instance provides advisory coordination between docker-backup daemons
+// running on the same host.
+package instance
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const lockFilePrefix = "docker-backup-"
+const lockFileSuffix = ".lock"
+
+// ErrPrefixInUse is returned when another live instance holds the lock for the same label prefix
+var ErrPrefixInUse = errors.New("label prefix is already in use by another docker-backup instance")
+
+// Lock is an advisory lock held for the lifetime of a daemon instance.
+// The lock file records the label prefix so other instances can detect overlapping scopes.
+type Lock struct {
+	file *os.File
+	path string
+}
+
+// Acquire takes the instance lock for labelPrefix in dir
+func Acquire(dir, labelPrefix string) (*Lock, error) {
+	path := lockPath(dir, labelPrefix)
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	locked, err := tryLock(file, true)
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	if !locked {
+		_ = file.Close()
+		return nil, fmt.Errorf("%w (lock file %s)", ErrPrefixInUse, path)
+	}
+
+	if err := file.Truncate(0); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to write lock file: %w", err)
+	}
+	if _, err := file.WriteAt([]byte(labelPrefix+"\n"), 0); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to write lock file: %w", err)
+	}
+
+	return &Lock{file: file, path: path}, nil
+}
+
+// Release releases the lock and removes the lock file
+func (l *Lock) Release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	// Close first, an open file can't be removed on every platform
+	err := l.file.Close()
+	l.file = nil
+	_ = os.Remove(l.path)
+	return err
+}
+
+// Overlapping returns the label prefixes of other live instances in dir whose
+// scope overlaps with labelPrefix, e.g. "docker-backup" and "docker-backup.staging"
+func Overlapping(dir, labelPrefix string) []string {
+	matches, err := filepath.Glob(filepath.Join(dir, lockFilePrefix+"*"+lockFileSuffix))
+	if err != nil {
+		return nil
+	}
+
+	own := lockPath(dir, labelPrefix)
+
+	var overlapping []string
+	for _, path := range matches {
+		if path == own {
+			continue
+		}
+
+		other, live := readLiveLock(path)
+		if !live || !PrefixesOverlap(labelPrefix, other) {
+			continue
+		}
+		overlapping = append(overlapping, other)
+	}
+
+	return overlapping
+}
+
+// PrefixesOverlap reports whether labels matched by one prefix can also be matched by the other
+func PrefixesOverlap(a, b string) bool {
+	return a == b || strings.HasPrefix(a, b+".") || strings.HasPrefix(b, a+".")
+}
+
+// readLiveLock returns the prefix recorded in a lock file and whether its owner is still running
+func readLiveLock(path string) (string, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	// A lock we can take ourselves belongs to an instance that is gone
+	free, err := tryLock(file, false)
+	if err != nil || free {
+		return "", false
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+
+	return strings.TrimSpace(string(data)), true
+}
+
+func lockPath(dir, labelPrefix string) string {
+	return filepath.Join(dir, lockFilePrefix+labelPrefix+lockFileSuffix)
+}
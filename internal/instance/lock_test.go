@@ -0,0 +1,69 @@
+package instance
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquire_SamePrefixTwice(t *testing.T) {
+	dir := t.TempDir()
+
+	lock, err := Acquire(dir, "docker-backup")
+	require.NoError(t, err)
+
+	_, err = Acquire(dir, "docker-backup")
+	assert.ErrorIs(t, err, ErrPrefixInUse)
+
+	require.NoError(t, lock.Release())
+
+	lock, err = Acquire(dir, "docker-backup")
+	require.NoError(t, err)
+	require.NoError(t, lock.Release())
+}
+
+func TestAcquire_DifferentPrefixes(t *testing.T) {
+	dir := t.TempDir()
+
+	a, err := Acquire(dir, "docker-backup")
+	require.NoError(t, err)
+	defer func() { _ = a.Release() }()
+
+	b, err := Acquire(dir, "docker-backup-staging")
+	require.NoError(t, err)
+	defer func() { _ = b.Release() }()
+
+	assert.Empty(t, Overlapping(dir, "docker-backup"))
+	assert.Empty(t, Overlapping(dir, "docker-backup-staging"))
+}
+
+func TestOverlapping_NestedPrefix(t *testing.T) {
+	dir := t.TempDir()
+
+	nested, err := Acquire(dir, "docker-backup.staging")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"docker-backup.staging"}, Overlapping(dir, "docker-backup"))
+
+	require.NoError(t, nested.Release())
+
+	assert.Empty(t, Overlapping(dir, "docker-backup"))
+}
+
+func TestPrefixesOverlap(t *testing.T) {
+	tests := []struct {
+		a, b    string
+		overlap bool
+	}{
+		{"docker-backup", "docker-backup", true},
+		{"docker-backup", "docker-backup.staging", true},
+		{"docker-backup.staging", "docker-backup", true},
+		{"docker-backup", "docker-backup-staging", false},
+		{"backup", "docker-backup", false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.overlap, PrefixesOverlap(tt.a, tt.b), "%s / %s", tt.a, tt.b)
+	}
+}
@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCounterVec(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("test_errors_total", "Test errors.", "pool", "operation")
+
+	c.Inc("local", "store")
+	c.Add(2, "local", "store")
+	c.Inc("s3", "get")
+
+	assert.Equal(t, float64(3), c.Value("local", "store"))
+	assert.Equal(t, float64(1), c.Value("s3", "get"))
+	assert.Panics(t, func() { c.Inc("local") })
+	assert.Panics(t, func() { c.Add(-1, "local", "store") })
+}
+
+func TestHistogramVec(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogramVec("test_duration_seconds", "Test durations.", []float64{1, 0.1}, "op")
+
+	h.Observe(0.05, "get")
+	h.Observe(0.5, "get")
+	h.Observe(5, "get")
+
+	assert.Equal(t, uint64(3), h.Count("get"))
+
+	var out strings.Builder
+	require.NoError(t, r.WriteText(&out))
+
+	assert.Equal(t, `# HELP test_duration_seconds Test durations.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{op="get",le="0.1"} 1
+test_duration_seconds_bucket{op="get",le="1"} 2
+test_duration_seconds_bucket{op="get",le="+Inf"} 3
+test_duration_seconds_sum{op="get"} 5.55
+test_duration_seconds_count{op="get"} 3
+`, out.String())
+}
+
+func TestRegistry_WriteText(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("test_total", "Line one\nline two.", "name")
+	c.Inc(`quote"and\slash`)
+	c.Inc("a")
+
+	var out strings.Builder
+	require.NoError(t, r.WriteText(&out))
+
+	assert.Equal(t, `# HELP test_total Line one\nline two.
+# TYPE test_total counter
+test_total{name="a"} 1
+test_total{name="quote\"and\\slash"} 1
+`, out.String())
+
+	assert.Panics(t, func() { r.NewCounterVec("test_total", "again") })
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("test_total", "Test.").Inc()
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "test_total 1\n")
+}
@@ -0,0 +1,87 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// healthchecksNotifier pings a Healthchecks.io style check URL: <url>/start
+// when a backup begins, <url> when it succeeds and <url>/fail when it fails.
+// The external service alerts when an expected ping doesn't arrive.
+type healthchecksNotifier struct {
+	name   string
+	url    string
+	client *http.Client
+}
+
+// newHealthchecksNotifier creates a notifier from a DSN of the form
+// healthchecks://HOST/PATH, e.g. healthchecks://hc-ping.com/UUID. The check is
+// pinged over HTTPS unless the DSN has scheme=http.
+func newHealthchecksNotifier(name string, dsn *url.URL, client *http.Client) (*healthchecksNotifier, error) {
+	if dsn.Host == "" {
+		return nil, fmt.Errorf("healthchecks notifier requires a host")
+	}
+	if strings.Trim(dsn.Path, "/") == "" {
+		return nil, fmt.Errorf("healthchecks notifier requires the check path, e.g. healthchecks://hc-ping.com/UUID")
+	}
+
+	scheme := "https"
+	switch val := dsn.Query().Get("scheme"); val {
+	case "", "https":
+	case "http":
+		scheme = "http"
+	default:
+		return nil, fmt.Errorf("invalid 'scheme' option %q, expected http or https", val)
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &healthchecksNotifier{
+		name:   name,
+		url:    scheme + "://" + dsn.Host + strings.TrimRight(dsn.Path, "/"),
+		client: client,
+	}, nil
+}
+
+func (n *healthchecksNotifier) Name() string {
+	return n.name
+}
+
+// Send pings the check for backup events. Restores are not scheduled jobs and
+// are ignored.
+func (n *healthchecksNotifier) Send(ctx context.Context, event Event) error {
+	var suffix string
+	switch event.Type {
+	case EventBackupStarted:
+		suffix = "/start"
+	case EventBackupCompleted, EventBackupRecovered:
+		suffix = ""
+	case EventBackupFailed:
+		suffix = "/fail"
+	default:
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, n.url+suffix, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create healthchecks request: %w", err)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to ping healthchecks: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("healthchecks ping %s returned status %d", req.URL.Path, resp.StatusCode)
+	}
+	return nil
+}
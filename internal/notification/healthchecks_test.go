@@ -0,0 +1,65 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthchecksNotifier_Send(t *testing.T) {
+	var pings []string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pings = append(pings, r.Method+" "+r.URL.Path)
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "https://")
+	notifier, err := CreateNotifierFromDSN("cron", "healthchecks://"+host+"/ping/abc-123/", server.Client())
+	require.NoError(t, err)
+	assert.Equal(t, "cron", notifier.Name())
+
+	ctx := context.Background()
+	for _, event := range []Event{
+		{Type: EventBackupStarted},
+		{Type: EventBackupCompleted},
+		{Type: EventBackupFailed, Error: errors.New("disk full")},
+		{Type: EventBackupRecovered},
+		{Type: EventRestoreCompleted},
+	} {
+		require.NoError(t, notifier.Send(ctx, event), event.Type)
+	}
+
+	assert.Equal(t, []string{
+		"GET /ping/abc-123/start",
+		"GET /ping/abc-123",
+		"GET /ping/abc-123/fail",
+		"GET /ping/abc-123",
+	}, pings, "restores are not pinged")
+
+	notifier, err = CreateNotifierFromDSN("cron", "healthchecks://"+host+"/broken", server.Client())
+	require.NoError(t, err)
+	assert.ErrorContains(t, notifier.Send(ctx, Event{Type: EventBackupCompleted}), "status 404")
+}
+
+func TestCreateNotifierFromDSN_HealthchecksOptions(t *testing.T) {
+	notifier, err := CreateNotifierFromDSN("cron", "healthchecks://hc.internal:8000/ping/abc?scheme=http", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "http://hc.internal:8000/ping/abc", notifier.(*healthchecksNotifier).url)
+
+	for name, dsn := range map[string]string{
+		"missing path":   "healthchecks://hc-ping.com",
+		"invalid scheme": "healthchecks://hc-ping.com/abc?scheme=ftp",
+	} {
+		_, err := CreateNotifierFromDSN("cron", dsn, nil)
+		assert.Error(t, err, name)
+	}
+}
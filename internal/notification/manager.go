@@ -9,6 +9,7 @@ import (
 // Manager manages multiple notifiers and dispatches events
 type Manager struct {
 	notifiers map[string]Notifier
+	instance  string
 	mu        sync.RWMutex
 }
 
@@ -26,6 +27,13 @@ func (m *Manager) AddNotifier(name string, notifier Notifier) {
 	m.notifiers[name] = notifier
 }
 
+// SetInstance sets the instance name filled into events that don't carry one
+func (m *Manager) SetInstance(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.instance = name
+}
+
 // Notify sends an event to specified notifiers (or none if providers is empty)
 func (m *Manager) Notify(ctx context.Context, event Event, providers []string) {
 	if len(providers) == 0 {
@@ -33,6 +41,9 @@ func (m *Manager) Notify(ctx context.Context, event Event, providers []string) {
 	}
 
 	m.mu.RLock()
+	if event.Instance == "" {
+		event.Instance = m.instance
+	}
 	notifiers := make(map[string]Notifier)
 	for _, name := range providers {
 		if notifier, ok := m.notifiers[name]; ok {
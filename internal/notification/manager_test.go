@@ -333,6 +333,7 @@ func TestEventTypes(t *testing.T) {
 		EventBackupStarted,
 		EventBackupCompleted,
 		EventBackupFailed,
+		EventBackupRecovered,
 		EventRestoreStarted,
 		EventRestoreCompleted,
 		EventRestoreFailed,
@@ -349,3 +350,36 @@ func TestEventTypes(t *testing.T) {
 		seen[et] = true
 	}
 }
+
+func TestFormatEventMessage_Recovered(t *testing.T) {
+	msg := formatEventMessage(Event{
+		Type:          EventBackupRecovered,
+		ContainerName: "postgres",
+		BackupType:    "postgres",
+		Failures:      3,
+	})
+
+	assert.Contains(t, msg, "Backup Recovered")
+	assert.Contains(t, msg, "Consecutive failures: 3")
+}
+
+func TestManager_Notify_Instance(t *testing.T) {
+	mgr := NewManager()
+	mgr.SetInstance("host-a")
+	var got []string
+	var mu sync.Mutex
+	mgr.AddNotifier("test", &mockNotifier{name: "test", sendFunc: func(ctx context.Context, event Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, event.Instance)
+		return nil
+	}})
+
+	mgr.Notify(context.Background(), Event{Type: EventBackupFailed, ContainerName: "postgres"}, []string{"test"})
+	mgr.Notify(context.Background(), Event{Type: EventBackupFailed, ContainerName: "postgres", Instance: "host-b"}, []string{"test"})
+	assert.Equal(t, []string{"host-a", "host-b"}, got)
+
+	msg := formatEventMessage(Event{Type: EventBackupFailed, Instance: "host-a", ContainerName: "postgres"})
+	assert.Contains(t, msg, "Instance: host-a\nContainer: postgres")
+	assert.NotContains(t, formatEventMessage(Event{Type: EventBackupFailed, ContainerName: "postgres"}), "Instance")
+}
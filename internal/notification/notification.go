@@ -8,9 +8,12 @@ import (
 // Event represents a backup event that can be notified
 type Event struct {
 	Type          EventType
+	Instance      string // Name of the daemon that sent the event, see Manager.SetInstance
 	ContainerName string
 	BackupType    string
 	BackupKey     string
+	Storage       string // Pool the backup was written to, set on completed backups
+	Failures      int    // Consecutive failed runs, set when a failure threshold is configured
 	Size          int64
 	Duration      time.Duration
 	Error         error
@@ -24,6 +27,7 @@ const (
 	EventBackupStarted    EventType = "backup_started"
 	EventBackupCompleted  EventType = "backup_completed"
 	EventBackupFailed     EventType = "backup_failed"
+	EventBackupRecovered  EventType = "backup_recovered" // First success after a notified failure streak
 	EventRestoreStarted   EventType = "restore_started"
 	EventRestoreCompleted EventType = "restore_completed"
 	EventRestoreFailed    EventType = "restore_failed"
@@ -0,0 +1,35 @@
+package notification
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateNotifierFromDSN_UsesClient(t *testing.T) {
+	var path string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "https://")
+	notifier, err := CreateNotifierFromDSN("team", "discord://token@"+host+"?webhook_id=123", server.Client())
+	require.NoError(t, err)
+	assert.Equal(t, "team", notifier.Name())
+
+	// The test server's certificate is only trusted by its own client
+	require.NoError(t, notifier.Send(context.Background(), Event{Type: EventBackupCompleted, ContainerName: "db"}))
+	assert.Equal(t, "/api/webhooks/123/token", path)
+}
+
+func TestCreateNotifierFromDSN_UnsupportedScheme(t *testing.T) {
+	_, err := CreateNotifierFromDSN("x", "carrier-pigeon://default", nil)
+	assert.ErrorContains(t, err, `unsupported scheme "carrier-pigeon"`)
+}
@@ -0,0 +1,155 @@
+// Package pathfilter decides which entries of a directory tree go into a
+// backup, based on include and exclude glob patterns.
+//
+// Patterns use path.Match syntax on slash-separated paths relative to the
+// tree's root, plus "**", which matches any number of path segments. A pattern
+// without a slash, such as "*.log" or "node_modules", matches an entry of that
+// name at any depth. A trailing slash ("logs/") restricts a pattern to
+// directories. A directory that matches also matches everything below it.
+package pathfilter
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Filter keeps the entries matching one of its include patterns, or all
+// entries if there are none, minus those matching one of its exclude patterns
+type Filter struct {
+	include []pattern
+	exclude []pattern
+}
+
+type pattern struct {
+	segments []string
+	dirOnly  bool
+}
+
+// New parses include and exclude patterns
+func New(include, exclude []string) (*Filter, error) {
+	f := &Filter{}
+
+	for _, p := range include {
+		parsed, err := parse(p)
+		if err != nil {
+			return nil, err
+		}
+		f.include = append(f.include, parsed)
+	}
+	for _, p := range exclude {
+		parsed, err := parse(p)
+		if err != nil {
+			return nil, err
+		}
+		f.exclude = append(f.exclude, parsed)
+	}
+
+	return f, nil
+}
+
+func parse(p string) (pattern, error) {
+	raw := p
+	p = strings.TrimPrefix(strings.TrimSpace(p), "/")
+
+	var parsed pattern
+	if strings.HasSuffix(p, "/") {
+		parsed.dirOnly = true
+		p = strings.TrimRight(p, "/")
+	}
+	if p == "" {
+		return pattern{}, fmt.Errorf("invalid pattern %q: empty", raw)
+	}
+
+	parsed.segments = strings.Split(p, "/")
+	if len(parsed.segments) == 1 && parsed.segments[0] != "**" {
+		// Unanchored, matches at any depth
+		parsed.segments = []string{"**", parsed.segments[0]}
+	}
+
+	for _, segment := range parsed.segments {
+		if _, err := path.Match(segment, ""); err != nil {
+			return pattern{}, fmt.Errorf("invalid pattern %q: %w", raw, err)
+		}
+	}
+
+	return parsed, nil
+}
+
+// Empty reports whether the filter keeps every entry
+func (f *Filter) Empty() bool {
+	return f == nil || (len(f.include) == 0 && len(f.exclude) == 0)
+}
+
+// Keep reports whether the entry at the root-relative path rel goes into the
+// backup. The root itself ("" or ".") is always kept.
+func (f *Filter) Keep(rel string, isDir bool) bool {
+	if f.Empty() {
+		return true
+	}
+
+	rel = strings.Trim(path.Clean("/"+rel), "/")
+	if rel == "" {
+		return true
+	}
+	segments := strings.Split(rel, "/")
+
+	if matchesAny(f.exclude, segments, isDir) {
+		return false
+	}
+	return len(f.include) == 0 || matchesAny(f.include, segments, isDir)
+}
+
+// Excluded reports whether the directory or file at rel and everything below
+// it is excluded, so a walk can skip it without looking at its contents
+func (f *Filter) Excluded(rel string, isDir bool) bool {
+	if f.Empty() {
+		return false
+	}
+
+	rel = strings.Trim(path.Clean("/"+rel), "/")
+	if rel == "" {
+		return false
+	}
+	return matchesAny(f.exclude, strings.Split(rel, "/"), isDir)
+}
+
+// matchesAny reports whether the entry or one of its parent directories
+// matches one of the patterns
+func matchesAny(patterns []pattern, segments []string, isDir bool) bool {
+	for i := 1; i <= len(segments); i++ {
+		entryIsDir := isDir || i < len(segments)
+		for _, p := range patterns {
+			if p.dirOnly && !entryIsDir {
+				continue
+			}
+			if matchSegments(p.segments, segments[:i]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
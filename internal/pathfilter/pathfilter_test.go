@@ -0,0 +1,85 @@
+package pathfilter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter_Exclude(t *testing.T) {
+	f, err := New(nil, []string{"*.log", "cache/**", "node_modules", "logs/"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		rel   string
+		isDir bool
+		keep  bool
+	}{
+		{"app.php", false, true},
+		{"error.log", false, false},
+		{"var/log/error.log", false, false},
+		{"cache", true, false},
+		{"cache/a/b.bin", false, false},
+		{"node_modules", true, false},
+		{"web/node_modules/left-pad/index.js", false, false},
+		{"logs", true, false},
+		{"logs/today.txt", false, false},
+		{"logs", false, true}, // A file named logs is not matched by logs/
+		{"", true, true},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.keep, f.Keep(tt.rel, tt.isDir), tt.rel)
+	}
+}
+
+func TestFilter_Include(t *testing.T) {
+	f, err := New([]string{"config/*.yml", "certs/"}, []string{"secret.yml"})
+	require.NoError(t, err)
+
+	assert.True(t, f.Keep("config/app.yml", false))
+	assert.False(t, f.Keep("config/app.json", false))
+	assert.False(t, f.Keep("config", true))
+	assert.True(t, f.Keep("certs", true))
+	assert.True(t, f.Keep("certs/live/cert.pem", false))
+	assert.False(t, f.Keep("config/secret.yml", false))
+	assert.True(t, f.Keep(".", true))
+}
+
+func TestFilter_DoubleStar(t *testing.T) {
+	f, err := New(nil, []string{"a/**/z.txt"})
+	require.NoError(t, err)
+
+	assert.False(t, f.Keep("a/z.txt", false))
+	assert.False(t, f.Keep("a/b/c/z.txt", false))
+	assert.True(t, f.Keep("b/z.txt", false))
+}
+
+func TestFilter_Excluded(t *testing.T) {
+	f, err := New([]string{"src/**"}, []string{"tmp"})
+	require.NoError(t, err)
+
+	// Only exclude patterns allow skipping a whole subtree
+	assert.True(t, f.Excluded("tmp", true))
+	assert.True(t, f.Excluded("deep/tmp/x", false))
+	assert.False(t, f.Excluded("other", true))
+}
+
+func TestFilter_Empty(t *testing.T) {
+	f, err := New(nil, nil)
+	require.NoError(t, err)
+	assert.True(t, f.Empty())
+	assert.True(t, f.Keep("anything/at/all", false))
+
+	var nilFilter *Filter
+	assert.True(t, nilFilter.Keep("x", false))
+}
+
+func TestNew_InvalidPattern(t *testing.T) {
+	_, err := New([]string{"[a-"}, nil)
+	assert.Error(t, err)
+
+	_, err = New(nil, []string{"/"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,226 @@
+// Package progress tracks the progress of running backup and restore operations
+// so it can be reported to the dashboard and included in notifications.
+package progress
+
+import (
+	"context"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Operation identifies what kind of work a tracker reports on
+type Operation string
+
+const (
+	OperationBackup  Operation = "backup"
+	OperationRestore Operation = "restore"
+)
+
+// Snapshot is a point-in-time view of a running operation
+type Snapshot struct {
+	ID            string    `json:"id"`
+	Operation     Operation `json:"operation"`
+	ContainerName string    `json:"container"`
+	ConfigName    string    `json:"config,omitempty"`
+	BackupKey     string    `json:"key,omitempty"`
+	Entries       int64     `json:"entries"`
+	Bytes         int64     `json:"bytes"`
+	TotalBytes    int64     `json:"total_bytes,omitempty"` // 0 when the total is unknown
+	StartedAt     time.Time `json:"started_at"`
+}
+
+// Percent returns the completed percentage, or -1 if the total size is unknown
+func (s Snapshot) Percent() float64 {
+	if s.TotalBytes <= 0 {
+		return -1
+	}
+	p := float64(s.Bytes) / float64(s.TotalBytes) * 100
+	if p > 100 {
+		p = 100
+	}
+	return p
+}
+
+// Tracker records the progress of a single operation. All methods are safe
+// to call on a nil Tracker, so backup types can report progress without
+// checking whether anyone is listening.
+type Tracker struct {
+	id            string
+	registry      *Registry
+	operation     Operation
+	containerName string
+	configName    string
+	backupKey     string
+	startedAt     time.Time
+
+	entries atomic.Int64
+	bytes   atomic.Int64
+	total   atomic.Int64
+}
+
+// AddEntry records that one more archive entry (file, table, ...) was processed
+func (t *Tracker) AddEntry() {
+	if t == nil {
+		return
+	}
+	t.entries.Add(1)
+}
+
+// AddBytes records that n more bytes were processed
+func (t *Tracker) AddBytes(n int64) {
+	if t == nil {
+		return
+	}
+	t.bytes.Add(n)
+}
+
+// SetTotal sets the expected number of bytes, enabling a percentage
+func (t *Tracker) SetTotal(n int64) {
+	if t == nil {
+		return
+	}
+	t.total.Store(n)
+}
+
+// Bytes returns the number of bytes processed so far
+func (t *Tracker) Bytes() int64 {
+	if t == nil {
+		return 0
+	}
+	return t.bytes.Load()
+}
+
+// Writer wraps w so every write is counted towards the tracker's bytes
+func (t *Tracker) Writer(w io.Writer) io.Writer {
+	if t == nil {
+		return w
+	}
+	return &countingWriter{w: w, t: t}
+}
+
+// Reader wraps r so every read is counted towards the tracker's bytes
+func (t *Tracker) Reader(r io.Reader) io.Reader {
+	if t == nil {
+		return r
+	}
+	return &countingReader{r: r, t: t}
+}
+
+// Snapshot returns the tracker's current state
+func (t *Tracker) Snapshot() Snapshot {
+	if t == nil {
+		return Snapshot{}
+	}
+	return Snapshot{
+		ID:            t.id,
+		Operation:     t.operation,
+		ContainerName: t.containerName,
+		ConfigName:    t.configName,
+		BackupKey:     t.backupKey,
+		Entries:       t.entries.Load(),
+		Bytes:         t.bytes.Load(),
+		TotalBytes:    t.total.Load(),
+		StartedAt:     t.startedAt,
+	}
+}
+
+// Done removes the tracker from its registry
+func (t *Tracker) Done() {
+	if t == nil || t.registry == nil {
+		return
+	}
+	t.registry.remove(t.id)
+}
+
+type countingWriter struct {
+	w io.Writer
+	t *Tracker
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.t.AddBytes(int64(n))
+	return n, err
+}
+
+type countingReader struct {
+	r io.Reader
+	t *Tracker
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.t.AddBytes(int64(n))
+	return n, err
+}
+
+// Registry holds the trackers of all running operations
+type Registry struct {
+	mu       sync.Mutex
+	trackers map[string]*Tracker
+	nextID   uint64
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		trackers: make(map[string]*Tracker),
+	}
+}
+
+// Start registers a new running operation and returns its tracker
+func (r *Registry) Start(op Operation, containerName, configName, backupKey string) *Tracker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	t := &Tracker{
+		id:            strconv.FormatUint(r.nextID, 10),
+		registry:      r,
+		operation:     op,
+		containerName: containerName,
+		configName:    configName,
+		backupKey:     backupKey,
+		startedAt:     time.Now(),
+	}
+	r.trackers[t.id] = t
+	return t
+}
+
+// List returns snapshots of all running operations, oldest first
+func (r *Registry) List() []Snapshot {
+	r.mu.Lock()
+	snapshots := make([]Snapshot, 0, len(r.trackers))
+	for _, t := range r.trackers {
+		snapshots = append(snapshots, t.Snapshot())
+	}
+	r.mu.Unlock()
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].StartedAt.Before(snapshots[j].StartedAt)
+	})
+	return snapshots
+}
+
+func (r *Registry) remove(id string) {
+	r.mu.Lock()
+	delete(r.trackers, id)
+	r.mu.Unlock()
+}
+
+type contextKey struct{}
+
+// WithTracker returns a context carrying t
+func WithTracker(ctx context.Context, t *Tracker) context.Context {
+	return context.WithValue(ctx, contextKey{}, t)
+}
+
+// FromContext returns the tracker carried by ctx, or nil
+func FromContext(ctx context.Context) *Tracker {
+	t, _ := ctx.Value(contextKey{}).(*Tracker)
+	return t
+}
@@ -0,0 +1,81 @@
+package progress
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_StartAndDone(t *testing.T) {
+	r := NewRegistry()
+
+	tracker := r.Start(OperationRestore, "db", "dump", "db/dump/2024-01-15/030000.tar.zst")
+	tracker.AddEntry()
+	tracker.AddBytes(512)
+
+	snapshots := r.List()
+	require.Len(t, snapshots, 1)
+	assert.Equal(t, OperationRestore, snapshots[0].Operation)
+	assert.Equal(t, "db", snapshots[0].ContainerName)
+	assert.Equal(t, int64(1), snapshots[0].Entries)
+	assert.Equal(t, int64(512), snapshots[0].Bytes)
+
+	tracker.Done()
+	assert.Empty(t, r.List())
+}
+
+func TestTracker_Writer(t *testing.T) {
+	tracker := NewRegistry().Start(OperationBackup, "db", "dump", "")
+
+	var buf bytes.Buffer
+	w := tracker.Writer(&buf)
+	_, err := w.Write([]byte("hello world"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "hello world", buf.String())
+	assert.Equal(t, int64(11), tracker.Bytes())
+}
+
+func TestTracker_Reader(t *testing.T) {
+	tracker := NewRegistry().Start(OperationRestore, "db", "dump", "")
+	tracker.SetTotal(11)
+
+	data, err := io.ReadAll(tracker.Reader(strings.NewReader("hello world")))
+	require.NoError(t, err)
+
+	assert.Equal(t, "hello world", string(data))
+	assert.Equal(t, float64(100), tracker.Snapshot().Percent())
+}
+
+func TestTracker_NilIsSafe(t *testing.T) {
+	var tracker *Tracker
+
+	tracker.AddEntry()
+	tracker.AddBytes(10)
+	tracker.SetTotal(100)
+	tracker.Done()
+
+	var buf bytes.Buffer
+	assert.Same(t, &buf, tracker.Writer(&buf))
+	assert.Same(t, &buf, tracker.Reader(&buf))
+	assert.Equal(t, int64(0), tracker.Bytes())
+}
+
+func TestSnapshot_Percent(t *testing.T) {
+	assert.Equal(t, float64(-1), Snapshot{Bytes: 10}.Percent())
+	assert.Equal(t, float64(25), Snapshot{Bytes: 25, TotalBytes: 100}.Percent())
+	assert.Equal(t, float64(100), Snapshot{Bytes: 150, TotalBytes: 100}.Percent())
+}
+
+func TestFromContext(t *testing.T) {
+	assert.Nil(t, FromContext(context.Background()))
+
+	tracker := NewRegistry().Start(OperationBackup, "db", "dump", "")
+	ctx := WithTracker(context.Background(), tracker)
+	assert.Same(t, tracker, FromContext(ctx))
+}
@@ -3,48 +3,89 @@ package retention
 import (
 	"context"
 	"log/slog"
+	"path"
 	"sort"
+	"time"
 
 	"github.com/shyim/docker-backup/internal/storage"
 )
 
+// Policy decides which backups of a prefix are deleted. A backup is deleted
+// as soon as one of the limits is exceeded, so the strictest limit wins,
+// except for the newest MinKeep backups which are always kept.
+type Policy struct {
+	KeepCount    int           // Keep the newest KeepCount backups, 0 disables the count limit
+	MaxAge       time.Duration // Delete backups older than MaxAge, 0 disables the age limit
+	MaxTotalSize int64         // Delete the oldest backups once the sizes add up to more, 0 disables the size limit
+	MinKeep      int           // Never delete the newest MinKeep backups, whatever the limits say
+}
+
 // Manager handles retention policy enforcement
 type Manager struct {
 	poolManager *storage.PoolManager
+	now         func() time.Time
+	dryRun      bool
+	onDelete    func(storageName string, file storage.BackupFile)
 }
 
 // New creates a new retention manager
 func New(poolManager *storage.PoolManager) *Manager {
 	return &Manager{
 		poolManager: poolManager,
+		now:         time.Now,
 	}
 }
 
-func (m *Manager) Enforce(ctx context.Context, storageName, prefix string, keepCount int) (int, error) {
+// SetDryRun makes Enforce only log the backups it would delete. It must be
+// called before retention is enforced.
+func (m *Manager) SetDryRun(dryRun bool) {
+	m.dryRun = dryRun
+}
+
+// SetOnDelete sets a function called for every backup Enforce deleted. It
+// must be called before retention is enforced.
+func (m *Manager) SetOnDelete(fn func(storageName string, file storage.BackupFile)) {
+	m.onDelete = fn
+}
+
+// Preview returns the backups below prefix that Enforce would delete, newest
+// first, without deleting them
+func (m *Manager) Preview(ctx context.Context, storageName, prefix string, policy Policy) ([]storage.BackupFile, error) {
 	store, err := m.poolManager.GetForContainer(storageName)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	// List all backups for this prefix
-	files, err := store.List(ctx, prefix)
+	expired, _, err := m.plan(ctx, store, prefix, policy)
+	return expired, err
+}
+
+// Enforce deletes the backups below prefix that the policy doesn't keep and
+// returns how many were deleted. In dry-run mode it only logs them.
+func (m *Manager) Enforce(ctx context.Context, storageName, prefix string, policy Policy) (int, error) {
+	store, err := m.poolManager.GetForContainer(storageName)
 	if err != nil {
 		return 0, err
 	}
 
-	if len(files) <= keepCount {
-		return 0, nil // Nothing to delete
+	expired, checksums, err := m.plan(ctx, store, prefix, policy)
+	if err != nil {
+		return 0, err
 	}
 
-	// Sort by modification time (newest first)
-	sort.Slice(files, func(i, j int) bool {
-		return files[i].LastModified.After(files[j].LastModified)
-	})
+	if m.dryRun {
+		for _, file := range expired {
+			slog.Info("retention dry run, would delete backup",
+				"key", file.Key,
+				"age", file.LastModified,
+			)
+		}
+		return 0, nil
+	}
 
 	// Delete old backups
 	deleted := 0
-	for i := keepCount; i < len(files); i++ {
-		file := files[i]
+	for _, file := range expired {
 		if err := store.Delete(ctx, file.Key); err != nil {
 			slog.Warn("failed to delete old backup",
 				"key", file.Key,
@@ -52,12 +93,110 @@ func (m *Manager) Enforce(ctx context.Context, storageName, prefix string, keepC
 			)
 			continue
 		}
+		if checksum := storage.ChecksumKey(file.Key); checksums[checksum] {
+			if err := store.Delete(ctx, checksum); err != nil {
+				slog.Warn("failed to delete checksum of old backup",
+					"key", checksum,
+					"error", err,
+				)
+			}
+		}
 		deleted++
 		slog.Info("deleted old backup",
 			"key", file.Key,
 			"age", file.LastModified,
 		)
+		if m.onDelete != nil {
+			m.onDelete(storageName, file)
+		}
 	}
 
 	return deleted, nil
 }
+
+// plan lists the backups below prefix and returns those the policy doesn't
+// keep, newest first, along with the checksum sidecars found next to them
+func (m *Manager) plan(ctx context.Context, store storage.Storage, prefix string, policy Policy) ([]storage.BackupFile, map[string]bool, error) {
+	listed, err := store.List(ctx, prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Checksum sidecars don't count as backups, they go with their backup
+	checksums := make(map[string]bool)
+	for _, file := range listed {
+		if storage.IsChecksum(file.Key) {
+			checksums[file.Key] = true
+		}
+	}
+	files := storage.WithoutChecksums(listed)
+
+	// Sort by modification time (newest first)
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].LastModified.After(files[j].LastModified)
+	})
+
+	return m.expired(files, policy), checksums, nil
+}
+
+// expired returns the files of a newest-first list the policy doesn't keep
+func (m *Manager) expired(files []storage.BackupFile, policy Policy) []storage.BackupFile {
+	var cutoff time.Time
+	if policy.MaxAge > 0 {
+		cutoff = m.now().Add(-policy.MaxAge)
+	}
+
+	var expired []storage.BackupFile
+	var totalSize int64
+	for i, file := range files {
+		if i < policy.MinKeep {
+			totalSize += file.Size
+			continue
+		}
+		if policy.KeepCount > 0 && i >= policy.KeepCount {
+			expired = append(expired, file)
+			continue
+		}
+
+		// The newest backup is kept even if it alone is over the size limit
+		totalSize += file.Size
+		if policy.MaxTotalSize > 0 && i > 0 && totalSize > policy.MaxTotalSize {
+			expired = append(expired, file)
+			continue
+		}
+
+		if cutoff.IsZero() {
+			continue
+		}
+
+		// The age comes from the key, a copied or re-uploaded backup keeps its date
+		created, ok := backupTime(file.Key)
+		if !ok {
+			slog.Warn("skipping backup with unrecognized key in age-based retention",
+				"key", file.Key,
+			)
+			continue
+		}
+		if created.Before(cutoff) {
+			expired = append(expired, file)
+		}
+	}
+
+	return expired
+}
+
+// backupTime parses the creation time from a key of the form
+// <container>/<config>/YYYY-MM-DD/HHMMSS<extension>
+func backupTime(key string) (time.Time, bool) {
+	base := path.Base(key)
+	date := path.Base(path.Dir(key))
+	if len(base) < 6 {
+		return time.Time{}, false
+	}
+
+	t, err := time.ParseInLocation("2006-01-02 150405", date+" "+base[:6], time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
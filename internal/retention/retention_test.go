@@ -0,0 +1,261 @@
+package retention
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/config"
+	"github.com/shyim/docker-backup/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStorageType creates fakeStorage pools and keeps them by pool name so tests can fill them
+type fakeStorageType struct{}
+
+var fakePools = map[string]*fakeStorage{}
+
+func (fakeStorageType) Name() string { return "retention-test" }
+func (fakeStorageType) Create(poolName string, options map[string]string) (storage.Storage, error) {
+	s := &fakeStorage{}
+	fakePools[poolName] = s
+	return s, nil
+}
+
+// fakeStorage lists a fixed set of files and records deletions
+type fakeStorage struct {
+	files   []storage.BackupFile
+	deleted []string
+}
+
+func (s *fakeStorage) Store(ctx context.Context, key string, reader io.Reader) error {
+	return nil
+}
+
+func (s *fakeStorage) List(ctx context.Context, prefix string) ([]storage.BackupFile, error) {
+	return append([]storage.BackupFile(nil), s.files...), nil
+}
+
+func (s *fakeStorage) Delete(ctx context.Context, key string) error {
+	s.deleted = append(s.deleted, key)
+	return nil
+}
+
+func (s *fakeStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return nil, io.EOF
+}
+
+func init() {
+	storage.Register(fakeStorageType{})
+}
+
+// now is the fixed time the tests enforce retention at
+var now = time.Date(2026, 3, 31, 12, 0, 0, 0, time.Local)
+
+func newTestManager(t *testing.T, files ...storage.BackupFile) (*Manager, *fakeStorage) {
+	t.Helper()
+	pm, err := storage.NewPoolManager(map[string]*config.StoragePool{
+		"local": {Name: "local", Type: "retention-test"},
+	}, "local")
+	require.NoError(t, err)
+	fakePools["local"].files = files
+
+	m := New(pm)
+	m.now = func() time.Time { return now }
+	return m, fakePools["local"]
+}
+
+// daily returns one backup per day, the newest created days before now
+func daily(days ...int) []storage.BackupFile {
+	files := make([]storage.BackupFile, 0, len(days))
+	for _, d := range days {
+		created := now.AddDate(0, 0, -d)
+		files = append(files, storage.BackupFile{
+			Key:          "app/db/" + created.Format("2006-01-02") + "/" + created.Format("150405") + ".sql.zst",
+			LastModified: created,
+		})
+	}
+	return files
+}
+
+func TestEnforce_KeepCount(t *testing.T) {
+	files := daily(1, 2, 3, 4, 5)
+	m, store := newTestManager(t, files...)
+
+	deleted, err := m.Enforce(context.Background(), "local", "app/db/", Policy{KeepCount: 3})
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+	assert.Equal(t, []string{files[3].Key, files[4].Key}, store.deleted)
+}
+
+func TestEnforce_OnDelete(t *testing.T) {
+	files := daily(1, 2, 3)
+	m, _ := newTestManager(t, files...)
+
+	var reported []string
+	m.SetOnDelete(func(storageName string, file storage.BackupFile) {
+		assert.Equal(t, "local", storageName)
+		reported = append(reported, file.Key)
+	})
+
+	_, err := m.Enforce(context.Background(), "local", "app/db/", Policy{KeepCount: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{files[1].Key, files[2].Key}, reported)
+}
+
+func TestEnforce_ChecksumSidecars(t *testing.T) {
+	files := daily(1, 2, 3)
+	listed := append([]storage.BackupFile(nil), files...)
+	for _, f := range files {
+		listed = append(listed, storage.BackupFile{Key: storage.ChecksumKey(f.Key), LastModified: f.LastModified})
+	}
+	m, store := newTestManager(t, listed...)
+
+	deleted, err := m.Enforce(context.Background(), "local", "app/db/", Policy{KeepCount: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted, "sidecars are not counted as backups")
+	assert.Equal(t, []string{files[2].Key, storage.ChecksumKey(files[2].Key)}, store.deleted)
+}
+
+func TestEnforce_MaxAge(t *testing.T) {
+	files := daily(1, 30, 89, 91, 200)
+	// Listed in storage order, Enforce sorts them itself
+	m, store := newTestManager(t, files[3], files[0], files[4], files[2], files[1])
+
+	deleted, err := m.Enforce(context.Background(), "local", "app/db/", Policy{MaxAge: 90 * 24 * time.Hour})
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+	assert.Equal(t, []string{files[3].Key, files[4].Key}, store.deleted)
+}
+
+func TestEnforce_MaxAgeUsesKeyTime(t *testing.T) {
+	// Copied to this storage yesterday, but created 120 days ago
+	files := daily(120)
+	files[0].LastModified = now.AddDate(0, 0, -1)
+	m, store := newTestManager(t, files...)
+
+	deleted, err := m.Enforce(context.Background(), "local", "app/db/", Policy{MaxAge: 90 * 24 * time.Hour})
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	assert.Equal(t, []string{files[0].Key}, store.deleted)
+}
+
+func TestEnforce_MaxAgeSkipsUnparsableKeys(t *testing.T) {
+	old := now.AddDate(-1, 0, 0)
+	m, store := newTestManager(t,
+		storage.BackupFile{Key: "app/db/backup.sql.zst", LastModified: old},
+		storage.BackupFile{Key: "app/db/2025-13-01/030000.sql.zst", LastModified: old},
+		storage.BackupFile{Key: "app/db/2025-01-01/03.sql.zst", LastModified: old},
+	)
+
+	deleted, err := m.Enforce(context.Background(), "local", "app/db/", Policy{MaxAge: 90 * 24 * time.Hour})
+	require.NoError(t, err)
+	assert.Equal(t, 0, deleted)
+	assert.Empty(t, store.deleted)
+}
+
+// sized gives the files the sizes in order
+func sized(files []storage.BackupFile, sizes ...int64) []storage.BackupFile {
+	for i := range files {
+		files[i].Size = sizes[i]
+	}
+	return files
+}
+
+func TestEnforce_MaxTotalSize(t *testing.T) {
+	files := sized(daily(1, 2, 3, 4, 5), 40, 30, 20, 20, 10)
+	m, store := newTestManager(t, files...)
+
+	deleted, err := m.Enforce(context.Background(), "local", "app/db/", Policy{MaxTotalSize: 100})
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted, "40+30+20 fit, the fourth backup exceeds the cap")
+	assert.Equal(t, []string{files[3].Key, files[4].Key}, store.deleted)
+}
+
+func TestEnforce_MaxTotalSizeKeepsNewest(t *testing.T) {
+	files := sized(daily(1, 2), 500, 10)
+	m, store := newTestManager(t, files...)
+
+	deleted, err := m.Enforce(context.Background(), "local", "app/db/", Policy{MaxTotalSize: 100})
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	assert.Equal(t, []string{files[1].Key}, store.deleted)
+}
+
+func TestEnforce_MaxTotalSizeWithKeepCount(t *testing.T) {
+	// The count is stricter than the size cap
+	files := sized(daily(1, 2, 3, 4, 5), 10, 10, 10, 10, 10)
+	m, store := newTestManager(t, files...)
+
+	deleted, err := m.Enforce(context.Background(), "local", "app/db/", Policy{KeepCount: 2, MaxTotalSize: 100})
+	require.NoError(t, err)
+	assert.Equal(t, 3, deleted)
+	assert.Equal(t, []string{files[2].Key, files[3].Key, files[4].Key}, store.deleted)
+
+	// The size cap is stricter than the count
+	files = sized(daily(1, 2, 3, 4, 5), 60, 60, 10, 10, 10)
+	m, store = newTestManager(t, files...)
+
+	deleted, err = m.Enforce(context.Background(), "local", "app/db/", Policy{KeepCount: 4, MaxTotalSize: 100})
+	require.NoError(t, err)
+	assert.Equal(t, 4, deleted)
+	assert.Equal(t, []string{files[1].Key, files[2].Key, files[3].Key, files[4].Key}, store.deleted)
+}
+
+func TestEnforce_MinKeep(t *testing.T) {
+	// Every limit on its own would delete all but the newest backup, or all of them
+	files := sized(daily(100, 101, 102, 103, 104), 60, 60, 60, 60, 60)
+	for name, policy := range map[string]Policy{
+		"count": {KeepCount: 1, MinKeep: 3},
+		"age":   {MaxAge: 30 * 24 * time.Hour, MinKeep: 3},
+		"size":  {MaxTotalSize: 10, MinKeep: 3},
+		"all":   {KeepCount: 1, MaxAge: 24 * time.Hour, MaxTotalSize: 10, MinKeep: 3},
+	} {
+		m, store := newTestManager(t, files...)
+
+		deleted, err := m.Enforce(context.Background(), "local", "app/db/", policy)
+		require.NoError(t, err, name)
+		assert.Equal(t, 2, deleted, name)
+		assert.Equal(t, []string{files[3].Key, files[4].Key}, store.deleted, "the newest min-keep backups are left: %s", name)
+	}
+
+	// Fewer backups than min-keep are never touched
+	m, store := newTestManager(t, files[:2]...)
+	deleted, err := m.Enforce(context.Background(), "local", "app/db/", Policy{MaxAge: time.Hour, MinKeep: 3})
+	require.NoError(t, err)
+	assert.Equal(t, 0, deleted)
+	assert.Empty(t, store.deleted)
+}
+
+func TestBackupTime(t *testing.T) {
+	created, ok := backupTime("app/db/2026-01-15/030405.tar.zst.age")
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2026, 1, 15, 3, 4, 5, 0, time.Local), created)
+
+	for _, key := range []string{"", "app/db/030405.tar.zst", "app/db/2026-01-15/3.tar", "app/db/2026-01-15/ab0405.tar"} {
+		_, ok := backupTime(key)
+		assert.False(t, ok, key)
+	}
+}
+
+func TestPreview(t *testing.T) {
+	files := daily(1, 2, 3, 4)
+	m, store := newTestManager(t, files...)
+
+	expired, err := m.Preview(context.Background(), "local", "app/db/", Policy{KeepCount: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []storage.BackupFile{files[2], files[3]}, expired)
+	assert.Empty(t, store.deleted)
+}
+
+func TestEnforce_DryRun(t *testing.T) {
+	m, store := newTestManager(t, daily(1, 2, 3, 4)...)
+	m.SetDryRun(true)
+
+	deleted, err := m.Enforce(context.Background(), "local", "app/db/", Policy{KeepCount: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 0, deleted)
+	assert.Empty(t, store.deleted)
+}
@@ -2,7 +2,9 @@ package scheduler
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"math/rand/v2"
 	"sync"
 	"time"
 
@@ -12,32 +14,125 @@ import (
 // JobFunc is the function signature for scheduled jobs
 type JobFunc func(ctx context.Context)
 
+// parser accepts standard 5-field cron expressions and descriptors such as
+// @daily or @every 6h
+var parser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// ValidateSchedule checks that schedule is a cron expression the scheduler accepts
+func ValidateSchedule(schedule string) error {
+	_, err := parser.Parse(schedule)
+	return err
+}
+
+// cancelGrace is how long Shutdown waits for jobs to return after cancelling them
+const cancelGrace = 10 * time.Second
+
 // Scheduler manages cron jobs for container backups
 type Scheduler struct {
-	cron *cron.Cron
-	jobs map[string]cron.EntryID // containerID -> entryID
-	mu   sync.RWMutex
+	cron   *cron.Cron
+	ctx    context.Context // parent of every job's context, cancelled by Shutdown
+	cancel context.CancelFunc
+	jobs   map[string]cron.EntryID // containerID -> entryID
+	loc    *time.Location          // Time zone of schedules without CRON_TZ
+	// running holds a lock per job key that is held while the job runs. It
+	// outlives the cron entry so a job re-added during a rescan still sees
+	// its previous run.
+	running map[string]*sync.Mutex
+	jitter  time.Duration // Upper bound of the random delay before each run
+	// stopping is closed when the scheduler stops, runs still waiting for
+	// their jitter delay are dropped then
+	stopping chan struct{}
+	stopOnce sync.Once
+	// delayed counts the runs added with RunAfter that haven't returned yet
+	delayed sync.WaitGroup
+	mu      sync.RWMutex
+}
+
+// New creates a new scheduler evaluating schedules in the local time zone.
+// Jobs run with a context derived from ctx, so they are cancelled together with it.
+func New(ctx context.Context) *Scheduler {
+	return NewInLocation(ctx, time.Local)
 }
 
-// New creates a new scheduler
-func New() *Scheduler {
+// NewInLocation creates a new scheduler evaluating schedules in loc. A
+// schedule prefixed with CRON_TZ=<zone> uses that zone instead.
+func NewInLocation(ctx context.Context, loc *time.Location) *Scheduler {
+	ctx, cancel := context.WithCancel(ctx)
 	return &Scheduler{
-		cron: cron.New(cron.WithParser(cron.NewParser(
-			cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow,
-		))),
-		jobs: make(map[string]cron.EntryID),
+		cron:     cron.New(cron.WithParser(parser), cron.WithLocation(loc)),
+		ctx:      ctx,
+		cancel:   cancel,
+		jobs:     make(map[string]cron.EntryID),
+		loc:      loc,
+		running:  make(map[string]*sync.Mutex),
+		stopping: make(chan struct{}),
 	}
 }
 
+// MissedRun reports whether schedule was due at some point after last and up
+// to now, i.e. whether a run was missed since the backup taken at last
+func (s *Scheduler) MissedRun(schedule string, last, now time.Time) (bool, error) {
+	sched, err := parser.Parse(schedule)
+	if err != nil {
+		return false, err
+	}
+	return !sched.Next(last.In(s.loc)).After(now), nil
+}
+
+// SetJitter delays every scheduled run by a random duration up to jitter, so
+// jobs sharing a schedule don't all start at once. It must be called before
+// jobs are added.
+func (s *Scheduler) SetJitter(jitter time.Duration) {
+	s.jitter = jitter
+}
+
 // Start begins the scheduler
 func (s *Scheduler) Start() {
 	s.cron.Start()
 	slog.Info("scheduler started")
 }
 
-// Stop gracefully stops the scheduler and waits for running jobs
+// Stop gracefully stops the scheduler. The returned context is done once the
+// running jobs, including delayed runs already started, have returned.
 func (s *Scheduler) Stop() context.Context {
-	return s.cron.Stop()
+	s.stopOnce.Do(func() { close(s.stopping) })
+	cronDone := s.cron.Stop()
+
+	ctx, done := context.WithCancel(context.Background())
+	go func() {
+		<-cronDone.Done()
+		s.delayed.Wait()
+		done()
+	}()
+	return ctx
+}
+
+// Shutdown stops the scheduler and waits up to timeout for running jobs to
+// finish. Jobs still running then have their context cancelled and get a short
+// grace period to return; an error means some of them didn't.
+func (s *Scheduler) Shutdown(timeout time.Duration) error {
+	done := s.Stop()
+	defer s.cancel()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done.Done():
+		return nil
+	case <-timer.C:
+	}
+
+	slog.Warn("cancelling running jobs after shutdown timeout", "timeout", timeout)
+	s.cancel()
+
+	grace := time.NewTimer(cancelGrace)
+	defer grace.Stop()
+	select {
+	case <-done.Done():
+		return nil
+	case <-grace.C:
+		return fmt.Errorf("jobs still running %s after they were cancelled", cancelGrace)
+	}
 }
 
 // AddJob schedules a backup job for a container
@@ -50,12 +145,7 @@ func (s *Scheduler) AddJob(containerID, schedule string, job JobFunc) error {
 		delete(s.jobs, containerID)
 	}
 
-	ctx := context.Background()
-	wrappedJob := func() {
-		job(ctx)
-	}
-
-	entryID, err := s.cron.AddFunc(schedule, wrappedJob)
+	entryID, err := s.cron.AddJob(schedule, s.wrap(s.exclusive(containerID, job, nil)))
 	if err != nil {
 		return err
 	}
@@ -66,6 +156,91 @@ func (s *Scheduler) AddJob(containerID, schedule string, job JobFunc) error {
 	return nil
 }
 
+// wrap adapts job to cron, running it with the scheduler's context after the
+// jitter delay. A run still waiting when the scheduler stops is dropped.
+func (s *Scheduler) wrap(job JobFunc) cron.Job {
+	jitter := s.jitter
+	return cron.FuncJob(func() {
+		if jitter > 0 {
+			delay := time.NewTimer(rand.N(jitter))
+			defer delay.Stop()
+			select {
+			case <-delay.C:
+			case <-s.stopping:
+				return
+			case <-s.ctx.Done():
+				return
+			}
+		}
+		job(s.ctx)
+	})
+}
+
+// exclusive makes job skip a run while the previous run for the same key is
+// still going, so a slow backup isn't started a second time on top of itself.
+// skipped, if set, is called instead of job for a skipped run. Must be called
+// with s.mu held.
+func (s *Scheduler) exclusive(key string, job JobFunc, skipped func()) JobFunc {
+	lock, ok := s.running[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.running[key] = lock
+	}
+
+	return func(ctx context.Context) {
+		if !lock.TryLock() {
+			slog.Warn("skipping scheduled job, previous run still in progress", "job", key)
+			if skipped != nil {
+				skipped()
+			}
+			return
+		}
+		defer lock.Unlock()
+
+		job(ctx)
+	}
+}
+
+// RunAfter runs job once after delay, under the same overlap protection as the
+// scheduled job with the given key: the run is skipped while another run of
+// key is still going. A run still waiting when the scheduler stops is dropped.
+// dropped, if set, is called instead of job when the run is skipped or
+// dropped, and shutdown waits for it to return.
+func (s *Scheduler) RunAfter(key string, delay time.Duration, job JobFunc, dropped func()) {
+	if dropped == nil {
+		dropped = func() {}
+	}
+
+	select {
+	case <-s.stopping:
+		dropped()
+		return
+	default:
+	}
+
+	s.mu.Lock()
+	run := s.exclusive(key, job, dropped)
+	s.mu.Unlock()
+
+	s.delayed.Add(1)
+	go func() {
+		defer s.delayed.Done()
+
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-s.stopping:
+			dropped()
+			return
+		case <-s.ctx.Done():
+			dropped()
+			return
+		}
+		run(s.ctx)
+	}()
+}
+
 // RemoveJob removes a scheduled job for a container
 func (s *Scheduler) RemoveJob(containerID string) {
 	s.mu.Lock()
@@ -76,6 +251,13 @@ func (s *Scheduler) RemoveJob(containerID string) {
 		delete(s.jobs, containerID)
 		slog.Debug("removed scheduled job", "container_id", containerID)
 	}
+
+	// Keep the lock of a job that is still running, it has to block the
+	// run of a job re-added under the same key
+	if lock, ok := s.running[containerID]; ok && lock.TryLock() {
+		delete(s.running, containerID)
+		lock.Unlock()
+	}
 }
 
 // UpdateJob updates an existing job's schedule
@@ -2,23 +2,25 @@ package scheduler
 
 import (
 	"context"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/robfig/cron/v3"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func TestNew(t *testing.T) {
-	s := New()
+	s := New(context.Background())
 	require.NotNil(t, s, "expected non-nil scheduler")
 	assert.NotNil(t, s.cron, "expected cron instance")
 	assert.NotNil(t, s.jobs, "expected jobs map")
 }
 
 func TestAddJob(t *testing.T) {
-	s := New()
+	s := New(context.Background())
 	s.Start()
 	defer s.Stop()
 
@@ -29,14 +31,14 @@ func TestAddJob(t *testing.T) {
 }
 
 func TestAddJob_InvalidSchedule(t *testing.T) {
-	s := New()
+	s := New(context.Background())
 
 	err := s.AddJob("container1", "invalid cron", func(ctx context.Context) {})
 	assert.Error(t, err, "expected error for invalid cron schedule")
 }
 
 func TestAddJob_ReplacesExisting(t *testing.T) {
-	s := New()
+	s := New(context.Background())
 	s.Start()
 	defer s.Stop()
 
@@ -59,7 +61,7 @@ func TestAddJob_ReplacesExisting(t *testing.T) {
 }
 
 func TestRemoveJob(t *testing.T) {
-	s := New()
+	s := New(context.Background())
 	s.Start()
 	defer s.Stop()
 
@@ -73,14 +75,14 @@ func TestRemoveJob(t *testing.T) {
 }
 
 func TestRemoveJob_NonExistent(t *testing.T) {
-	s := New()
+	s := New(context.Background())
 
 	// Should not panic
 	s.RemoveJob("nonexistent")
 }
 
 func TestHasJob(t *testing.T) {
-	s := New()
+	s := New(context.Background())
 	s.Start()
 	defer s.Stop()
 
@@ -93,7 +95,7 @@ func TestHasJob(t *testing.T) {
 }
 
 func TestJobCount(t *testing.T) {
-	s := New()
+	s := New(context.Background())
 	s.Start()
 	defer s.Stop()
 
@@ -110,7 +112,7 @@ func TestJobCount(t *testing.T) {
 }
 
 func TestListJobs(t *testing.T) {
-	s := New()
+	s := New(context.Background())
 	s.Start()
 	defer s.Stop()
 
@@ -131,14 +133,14 @@ func TestListJobs(t *testing.T) {
 }
 
 func TestListJobs_Empty(t *testing.T) {
-	s := New()
+	s := New(context.Background())
 
 	jobs := s.ListJobs()
 	assert.Empty(t, jobs)
 }
 
 func TestUpdateJob(t *testing.T) {
-	s := New()
+	s := New(context.Background())
 	s.Start()
 	defer s.Stop()
 
@@ -161,7 +163,7 @@ func TestUpdateJob(t *testing.T) {
 }
 
 func TestScheduler_ConcurrentAccess(t *testing.T) {
-	s := New()
+	s := New(context.Background())
 	s.Start()
 	defer s.Stop()
 
@@ -212,7 +214,7 @@ func TestScheduler_ConcurrentAccess(t *testing.T) {
 }
 
 func TestScheduler_StartStop(t *testing.T) {
-	s := New()
+	s := New(context.Background())
 
 	s.Start()
 
@@ -226,7 +228,7 @@ func TestScheduler_StartStop(t *testing.T) {
 }
 
 func TestScheduler_ValidCronSchedules(t *testing.T) {
-	s := New()
+	s := New(context.Background())
 
 	schedules := []string{
 		"* * * * *",     // Every minute
@@ -237,6 +239,14 @@ func TestScheduler_ValidCronSchedules(t *testing.T) {
 		"0 0 * * 0",     // Every Sunday
 		"0 0 1 * *",     // First day of every month
 		"30 4 1,15 * *", // 4:30 AM on 1st and 15th
+		"@yearly",       // Midnight on January 1st
+		"@monthly",      // Midnight on the first day of the month
+		"@weekly",       // Midnight on Sunday
+		"@daily",        // Every day at midnight
+		"@midnight",     // Same as @daily
+		"@hourly",       // Every hour
+		"@every 6h",     // Every 6 hours from when the job was added
+		"@every 1h30m",  // Every 90 minutes
 	}
 
 	for _, schedule := range schedules {
@@ -249,7 +259,7 @@ func TestScheduler_ValidCronSchedules(t *testing.T) {
 }
 
 func TestScheduler_InvalidCronSchedules(t *testing.T) {
-	s := New()
+	s := New(context.Background())
 
 	schedules := []string{
 		"",
@@ -261,6 +271,9 @@ func TestScheduler_InvalidCronSchedules(t *testing.T) {
 		"* * 32 * *",  // Invalid day
 		"* * * 13 *",  // Invalid month
 		"* * * * 7",   // Invalid day of week (should be 0-6)
+		"@sometimes",  // Unknown descriptor
+		"@every",      // Missing interval
+		"@every 6",    // Interval without unit
 	}
 
 	for _, schedule := range schedules {
@@ -271,3 +284,240 @@ func TestScheduler_InvalidCronSchedules(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateSchedule(t *testing.T) {
+	assert.NoError(t, ValidateSchedule("0 3 * * *"))
+	assert.Error(t, ValidateSchedule("0 3 * *"))
+	assert.Error(t, ValidateSchedule("not a schedule"))
+	assert.NoError(t, ValidateSchedule("@daily"))
+	assert.NoError(t, ValidateSchedule("@every 6h"))
+}
+
+func TestScheduler_JobContextFollowsParent(t *testing.T) {
+	parent, cancel := context.WithCancel(context.Background())
+	s := New(parent)
+
+	var jobCtx context.Context
+	s.wrap(func(ctx context.Context) { jobCtx = ctx }).Run()
+	require.NotNil(t, jobCtx)
+	assert.NoError(t, jobCtx.Err())
+
+	cancel()
+	assert.ErrorIs(t, jobCtx.Err(), context.Canceled, "jobs are cancelled with the daemon's context")
+}
+
+func TestScheduler_ShutdownCancelsJobsAfterTimeout(t *testing.T) {
+	s := New(context.Background())
+
+	started := make(chan struct{})
+	var once sync.Once
+	var cancelled atomic.Bool
+	// cron.Every keeps the test fast, the 5-field parser only allows minutes
+	s.cron.Schedule(cron.Every(time.Second), s.wrap(func(ctx context.Context) {
+		once.Do(func() { close(started) })
+		<-ctx.Done()
+		cancelled.Store(true)
+	}))
+	s.Start()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the job did not start")
+	}
+
+	start := time.Now()
+	require.NoError(t, s.Shutdown(100*time.Millisecond))
+	assert.True(t, cancelled.Load(), "the running job was cancelled")
+	assert.Less(t, time.Since(start), cancelGrace, "shutdown doesn't wait for the grace period once jobs returned")
+}
+
+func TestScheduler_ShutdownWaitsForJobs(t *testing.T) {
+	s := New(context.Background())
+	s.Start()
+
+	require.NoError(t, s.Shutdown(time.Second))
+	assert.Error(t, s.ctx.Err(), "the job context is released after shutdown")
+}
+
+func TestScheduler_SkipsOverlappingRuns(t *testing.T) {
+	s := New(context.Background())
+
+	var runs atomic.Int32
+	release := make(chan struct{})
+	require.NoError(t, s.AddJob("container1", "0 3 * * *", func(ctx context.Context) {
+		runs.Add(1)
+		<-release
+	}))
+
+	entry := s.cron.Entry(s.jobs["container1"])
+	done := make(chan struct{})
+	go func() {
+		entry.Job.Run()
+		close(done)
+	}()
+	require.Eventually(t, func() bool { return runs.Load() == 1 }, time.Second, time.Millisecond)
+
+	// The second trigger returns straight away while the first one still runs
+	entry.Job.Run()
+	close(release)
+	<-done
+
+	assert.Equal(t, int32(1), runs.Load(), "the overlapping run was skipped")
+
+	entry.Job.Run()
+	assert.Equal(t, int32(2), runs.Load(), "the job runs again once the previous run finished")
+}
+
+func TestScheduler_NextRunHonorsLocation(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	s := NewInLocation(context.Background(), berlin)
+	s.Start()
+	defer s.Stop()
+
+	require.NoError(t, s.AddJob("container1", "0 3 * * *", func(ctx context.Context) {}))
+	next := s.ListJobs()["container1"].NextRun.In(berlin)
+	assert.Equal(t, 3, next.Hour(), "3 AM in the scheduler's time zone")
+	assert.Equal(t, 0, next.Minute())
+}
+
+func TestScheduler_CronTZOverridesLocation(t *testing.T) {
+	s := NewInLocation(context.Background(), time.UTC)
+	s.Start()
+	defer s.Stop()
+
+	require.NoError(t, s.AddJob("container1", "CRON_TZ=America/New_York 30 4 * * *", func(ctx context.Context) {}))
+
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	next := s.ListJobs()["container1"].NextRun.In(newYork)
+	assert.Equal(t, 4, next.Hour(), "4:30 AM in New York, whatever the scheduler's zone")
+	assert.Equal(t, 30, next.Minute())
+}
+
+func TestScheduler_JitterDelaysRuns(t *testing.T) {
+	s := New(context.Background())
+	s.SetJitter(50 * time.Millisecond)
+
+	ran := make(chan time.Time, 1)
+	start := time.Now()
+	go s.wrap(func(ctx context.Context) { ran <- time.Now() }).Run()
+
+	select {
+	case at := <-ran:
+		assert.Less(t, at.Sub(start), 50*time.Millisecond+time.Second, "the delay stays below the jitter")
+	case <-time.After(5 * time.Second):
+		t.Fatal("the job did not run")
+	}
+}
+
+func TestScheduler_ShutdownDropsJitteredRuns(t *testing.T) {
+	s := New(context.Background())
+	s.SetJitter(time.Hour)
+
+	var ran atomic.Bool
+	done := make(chan struct{})
+	go func() {
+		s.wrap(func(ctx context.Context) { ran.Store(true) }).Run()
+		close(done)
+	}()
+
+	require.NoError(t, s.Shutdown(time.Second))
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the waiting run was not dropped")
+	}
+	assert.False(t, ran.Load(), "a run waiting for its jitter is dropped on shutdown")
+}
+
+func TestScheduler_RunAfter(t *testing.T) {
+	s := New(context.Background())
+
+	ran := make(chan time.Time, 1)
+	start := time.Now()
+	s.RunAfter("container1", 20*time.Millisecond, func(ctx context.Context) { ran <- time.Now() }, func() {
+		t.Error("the delayed run was dropped")
+	})
+
+	select {
+	case at := <-ran:
+		assert.GreaterOrEqual(t, at.Sub(start), 20*time.Millisecond)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the delayed run did not happen")
+	}
+}
+
+func TestScheduler_RunAfterSkipsOverlappingRuns(t *testing.T) {
+	s := New(context.Background())
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	require.NoError(t, s.AddJob("container1", "0 3 * * *", func(ctx context.Context) {
+		close(started)
+		<-release
+	}))
+	entry := s.cron.Entry(s.jobs["container1"])
+	go entry.Job.Run()
+	<-started
+
+	var ran, dropped atomic.Bool
+	s.RunAfter("container1", 0, func(ctx context.Context) { ran.Store(true) }, func() { dropped.Store(true) })
+	assert.Eventually(t, dropped.Load, 5*time.Second, time.Millisecond, "the skipped run is reported")
+	close(release)
+	require.NoError(t, s.Shutdown(time.Second))
+	assert.False(t, ran.Load(), "the delayed run was skipped while the scheduled run was going")
+}
+
+func TestScheduler_ShutdownWaitsForDelayedRuns(t *testing.T) {
+	s := New(context.Background())
+
+	started := make(chan struct{})
+	var finished atomic.Bool
+	s.RunAfter("container1", 0, func(ctx context.Context) {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		finished.Store(true)
+	}, nil)
+	<-started
+
+	require.NoError(t, s.Shutdown(time.Second))
+	assert.True(t, finished.Load(), "shutdown waited for the running delayed run")
+}
+
+func TestScheduler_ShutdownDropsDelayedRuns(t *testing.T) {
+	s := New(context.Background())
+
+	var ran atomic.Bool
+	var dropped atomic.Int32
+	s.RunAfter("container1", time.Hour, func(ctx context.Context) { ran.Store(true) }, func() { dropped.Add(1) })
+	require.NoError(t, s.Shutdown(time.Second))
+	assert.Equal(t, int32(1), dropped.Load(), "shutdown waits for the dropped run to be reported")
+
+	s.RunAfter("container1", 0, func(ctx context.Context) { ran.Store(true) }, func() { dropped.Add(1) })
+	time.Sleep(20 * time.Millisecond)
+	assert.False(t, ran.Load(), "delayed runs are dropped on shutdown and not added afterwards")
+	assert.Equal(t, int32(2), dropped.Load())
+}
+
+func TestScheduler_MissedRun(t *testing.T) {
+	s := NewInLocation(context.Background(), time.UTC)
+	last := time.Date(2026, 3, 1, 3, 0, 30, 0, time.UTC)
+
+	missed, err := s.MissedRun("0 3 * * *", last, last.Add(23*time.Hour))
+	require.NoError(t, err)
+	assert.False(t, missed, "the next run is still ahead")
+
+	missed, err = s.MissedRun("0 3 * * *", last, last.Add(25*time.Hour))
+	require.NoError(t, err)
+	assert.True(t, missed, "the run of the next day was missed")
+
+	missed, err = s.MissedRun("@every 6h", last, last.Add(7*time.Hour))
+	require.NoError(t, err)
+	assert.True(t, missed)
+
+	_, err = s.MissedRun("invalid", last, last)
+	assert.Error(t, err)
+}
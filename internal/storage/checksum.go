@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ChecksumSuffix is appended to a backup key to name the sidecar object that
+// holds the backup's SHA-256 digest
+const ChecksumSuffix = ".sha256"
+
+// ChecksumKey returns the key of the checksum sidecar of a backup
+func ChecksumKey(key string) string {
+	return key + ChecksumSuffix
+}
+
+// IsChecksum reports whether key names a checksum sidecar rather than a backup
+func IsChecksum(key string) bool {
+	return strings.HasSuffix(key, ChecksumSuffix)
+}
+
+// WithoutChecksums returns files without the checksum sidecars, for listings
+// that should only show backups
+func WithoutChecksums(files []BackupFile) []BackupFile {
+	backups := files[:0:0]
+	for _, f := range files {
+		if !IsChecksum(f.Key) {
+			backups = append(backups, f)
+		}
+	}
+	return backups
+}
+
+// FormatChecksum returns the sidecar content for a backup in the format of
+// sha256sum, so a downloaded backup can be checked with `sha256sum -c`
+func FormatChecksum(key, sum string) []byte {
+	return []byte(sum + "  " + path.Base(key) + "\n")
+}
+
+// ParseChecksum returns the hex digest from sidecar content
+func ParseChecksum(data []byte) (string, error) {
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("checksum file is empty")
+	}
+	sum := strings.ToLower(fields[0])
+	if decoded, err := hex.DecodeString(sum); err != nil || len(decoded) != 32 {
+		return "", fmt.Errorf("checksum file does not hold a SHA-256 digest")
+	}
+	return sum, nil
+}
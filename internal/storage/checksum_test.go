@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecksum_RoundTrip(t *testing.T) {
+	sum := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	key := "postgres/db/2026-01-15/030000.sql.zst"
+
+	content := FormatChecksum(key, sum)
+	assert.Equal(t, sum+"  030000.sql.zst\n", string(content))
+
+	parsed, err := ParseChecksum(content)
+	require.NoError(t, err)
+	assert.Equal(t, sum, parsed)
+
+	for name, data := range map[string]string{
+		"empty":     "",
+		"not hex":   "xyz  030000.sql.zst\n",
+		"too short": "9f86d081  030000.sql.zst\n",
+	} {
+		_, err := ParseChecksum([]byte(data))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestWithoutChecksums(t *testing.T) {
+	files := []BackupFile{
+		{Key: "app/db/2026-01-15/030000.sql.zst"},
+		{Key: ChecksumKey("app/db/2026-01-15/030000.sql.zst")},
+		{Key: "app/db/2026-01-16/030000.sql.zst"},
+	}
+
+	assert.Equal(t, []BackupFile{files[0], files[2]}, WithoutChecksums(files))
+	assert.Len(t, files, 3, "the input is not modified")
+	assert.True(t, IsChecksum(files[1].Key))
+	assert.False(t, IsChecksum(files[0].Key))
+}
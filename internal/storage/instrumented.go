@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/metrics"
+)
+
+var (
+	operationDuration = metrics.Default.NewHistogramVec(
+		"docker_backup_storage_operation_duration_seconds",
+		"Duration of storage pool operations. For get it covers opening the object, not reading it.",
+		metrics.DefaultBuckets,
+		"pool", "operation",
+	)
+	operationErrors = metrics.Default.NewCounterVec(
+		"docker_backup_storage_operation_errors_total",
+		"Failed storage pool operations.",
+		"pool", "operation",
+	)
+)
+
+// Storage operation names used as the operation label
+const (
+	opStore  = "store"
+	opGet    = "get"
+	opList   = "list"
+	opDelete = "delete"
+)
+
+// instrumentedStorage records latency and errors of every call to the wrapped pool
+type instrumentedStorage struct {
+	Storage
+	pool string
+}
+
+// Instrument wraps s so its operations are exported as metrics labelled with the pool name.
+// The wrapper passes StoreOptions and ListPage on to backends that support them.
+func Instrument(pool string, s Storage) Storage {
+	return &instrumentedStorage{Storage: s, pool: pool}
+}
+
+func (s *instrumentedStorage) observe(op string, start time.Time, err error) {
+	operationDuration.Observe(time.Since(start).Seconds(), s.pool, op)
+	if err != nil {
+		operationErrors.Inc(s.pool, op)
+	}
+}
+
+func (s *instrumentedStorage) Store(ctx context.Context, key string, reader io.Reader) error {
+	start := time.Now()
+	err := s.Storage.Store(ctx, key, reader)
+	s.observe(opStore, start, err)
+	return err
+}
+
+func (s *instrumentedStorage) StoreWithOptions(ctx context.Context, key string, reader io.Reader, opts StoreOptions) error {
+	start := time.Now()
+	err := Store(ctx, s.Storage, key, reader, opts)
+	s.observe(opStore, start, err)
+	return err
+}
+
+func (s *instrumentedStorage) List(ctx context.Context, prefix string) ([]BackupFile, error) {
+	start := time.Now()
+	files, err := s.Storage.List(ctx, prefix)
+	s.observe(opList, start, err)
+	return files, err
+}
+
+func (s *instrumentedStorage) ListPage(ctx context.Context, prefix, marker string, limit int) ([]BackupFile, string, error) {
+	start := time.Now()
+	files, next, err := ListPage(ctx, s.Storage, prefix, marker, limit)
+	s.observe(opList, start, err)
+	return files, next, err
+}
+
+func (s *instrumentedStorage) IsTree(key string) bool {
+	return IsTree(s.Storage, key)
+}
+
+func (s *instrumentedStorage) Delete(ctx context.Context, key string) error {
+	start := time.Now()
+	err := s.Storage.Delete(ctx, key)
+	s.observe(opDelete, start, err)
+	return err
+}
+
+func (s *instrumentedStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	start := time.Now()
+	reader, err := s.Storage.Get(ctx, key)
+	s.observe(opGet, start, err)
+	return reader, err
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStorage records calls and fails Delete
+type fakeStorage struct {
+	stored  []string
+	options *StoreOptions
+}
+
+func (f *fakeStorage) Store(_ context.Context, key string, _ io.Reader) error {
+	f.stored = append(f.stored, key)
+	return nil
+}
+
+func (f *fakeStorage) List(context.Context, string) ([]BackupFile, error) {
+	return []BackupFile{{Key: "a"}}, nil
+}
+
+func (f *fakeStorage) Delete(context.Context, string) error {
+	return errors.New("delete failed")
+}
+
+func (f *fakeStorage) Get(context.Context, string) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(nil)), nil
+}
+
+// fakeOptionsStorage additionally supports StoreOptions
+type fakeOptionsStorage struct {
+	fakeStorage
+}
+
+func (f *fakeOptionsStorage) StoreWithOptions(_ context.Context, key string, _ io.Reader, opts StoreOptions) error {
+	f.stored = append(f.stored, key)
+	f.options = &opts
+	return nil
+}
+
+func TestInstrument_RecordsOperations(t *testing.T) {
+	ctx := context.Background()
+	inner := &fakeStorage{}
+	s := Instrument("instrumented-test", inner)
+
+	require.NoError(t, s.Store(ctx, "k1", bytes.NewReader(nil)))
+	files, err := s.List(ctx, "")
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+	_, err = s.Get(ctx, "k1")
+	require.NoError(t, err)
+	assert.EqualError(t, s.Delete(ctx, "k1"), "delete failed")
+
+	for _, op := range []string{opStore, opList, opGet, opDelete} {
+		assert.Equal(t, uint64(1), operationDuration.Count("instrumented-test", op), op)
+	}
+	assert.Equal(t, float64(0), operationErrors.Value("instrumented-test", opStore))
+	assert.Equal(t, float64(1), operationErrors.Value("instrumented-test", opDelete))
+}
+
+func TestInstrument_PassesStoreOptions(t *testing.T) {
+	ctx := context.Background()
+
+	withOptions := &fakeOptionsStorage{}
+	s := Instrument("instrumented-options", withOptions)
+	require.NoError(t, Store(ctx, s, "k1", bytes.NewReader(nil), StoreOptions{Size: 42}))
+	require.NotNil(t, withOptions.options)
+	assert.Equal(t, int64(42), withOptions.options.Size)
+
+	// Backends without StoreOptions support still get a plain Store
+	plain := &fakeStorage{}
+	s = Instrument("instrumented-plain", plain)
+	require.NoError(t, Store(ctx, s, "k2", bytes.NewReader(nil), StoreOptions{Size: 42}))
+	assert.Equal(t, []string{"k2"}, plain.stored)
+	assert.Equal(t, uint64(1), operationDuration.Count("instrumented-plain", opStore))
+}
@@ -32,12 +32,23 @@ func NewPoolManager(pools map[string]*config.StoragePool, defaultPool string) (*
 			return nil, fmt.Errorf("failed to create storage pool %q: %w", name, err)
 		}
 
-		pm.pools[name] = storage
+		pm.pools[name] = Instrument(name, storage)
 	}
 
 	return pm, nil
 }
 
+// SetRetryPolicy retries the operations of every pool after transient errors,
+// see WithRetry. It must be called before the pools are used.
+func (pm *PoolManager) SetRetryPolicy(policy RetryPolicy) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	for name, s := range pm.pools {
+		pm.pools[name] = WithRetry(name, s, policy)
+	}
+}
+
 // Get returns a storage pool by name
 func (pm *PoolManager) Get(name string) (Storage, error) {
 	pm.mu.RLock()
@@ -68,6 +79,14 @@ func (pm *PoolManager) GetForContainer(storageName string) (Storage, error) {
 	return pm.GetDefault()
 }
 
+// PoolName returns the name of the pool GetForContainer resolves storageName to
+func (pm *PoolManager) PoolName(storageName string) string {
+	if storageName != "" {
+		return storageName
+	}
+	return pm.defaultPool
+}
+
 // List returns all pool names
 func (pm *PoolManager) List() []string {
 	pm.mu.RLock()
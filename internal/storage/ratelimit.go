@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"io"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimiter caps the throughput of uploads with a token bucket holding one
+// second worth of bytes. It is shared by every reader it wraps, so the limit
+// holds across concurrent backups and storage pools.
+type RateLimiter struct {
+	limiter *rate.Limiter
+}
+
+// NewRateLimiter returns a limiter allowing bytesPerSecond bytes per second
+func NewRateLimiter(bytesPerSecond int64) *RateLimiter {
+	burst := int(min(bytesPerSecond, int64(1<<30)))
+	return &RateLimiter{limiter: rate.NewLimiter(rate.Limit(bytesPerSecond), burst)}
+}
+
+// Reader wraps r so reading from it waits for the limiter. A reader that can
+// seek still can, so retries rewinding the upload keep working. A nil
+// RateLimiter returns r unchanged.
+func (l *RateLimiter) Reader(ctx context.Context, r io.Reader) io.Reader {
+	if l == nil {
+		return r
+	}
+	limited := &rateLimitedReader{ctx: ctx, r: r, limiter: l.limiter}
+	if seeker, ok := r.(io.Seeker); ok {
+		return &rateLimitedReadSeeker{rateLimitedReader: limited, seeker: seeker}
+	}
+	return limited
+}
+
+type rateLimitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rate.Limiter
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	// WaitN fails for more bytes than the bucket holds
+	if burst := r.limiter.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.WaitN(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+type rateLimitedReadSeeker struct {
+	*rateLimitedReader
+	seeker io.Seeker
+}
+
+func (r *rateLimitedReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	return r.seeker.Seek(offset, whence)
+}
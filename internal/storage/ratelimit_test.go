@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter_LimitsCopy(t *testing.T) {
+	const limit = 256 << 10
+	data := bytes.Repeat([]byte("x"), limit*3/2)
+
+	// The bucket starts full, so the first second worth is read right away
+	// and the remaining half second worth is throttled
+	limiter := NewRateLimiter(limit)
+	start := time.Now()
+	var out bytes.Buffer
+	n, err := io.Copy(&out, limiter.Reader(context.Background(), bytes.NewReader(data)))
+	elapsed := time.Since(start)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), n)
+	assert.Equal(t, data, out.Bytes())
+	assert.GreaterOrEqual(t, elapsed, 400*time.Millisecond)
+	assert.Less(t, elapsed, 2*time.Second)
+}
+
+func TestRateLimiter_SharedAcrossReaders(t *testing.T) {
+	const limit = 256 << 10
+	limiter := NewRateLimiter(limit)
+
+	// The first reader drains the bucket, the second has to wait for it to refill
+	_, err := io.Copy(io.Discard, limiter.Reader(context.Background(), bytes.NewReader(make([]byte, limit))))
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = io.Copy(io.Discard, limiter.Reader(context.Background(), bytes.NewReader(make([]byte, limit/2))))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+}
+
+func TestRateLimiter_KeepsSeeking(t *testing.T) {
+	limiter := NewRateLimiter(1 << 20)
+
+	seekable := limiter.Reader(context.Background(), strings.NewReader("data"))
+	seeker, ok := seekable.(io.Seeker)
+	require.True(t, ok)
+	_, err := io.ReadAll(seekable)
+	require.NoError(t, err)
+	_, err = seeker.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	content, err := io.ReadAll(seekable)
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(content))
+
+	_, ok = limiter.Reader(context.Background(), io.MultiReader(strings.NewReader("data"))).(io.Seeker)
+	assert.False(t, ok)
+}
+
+func TestRateLimiter_Cancelled(t *testing.T) {
+	limiter := NewRateLimiter(1024)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := io.Copy(io.Discard, limiter.Reader(ctx, bytes.NewReader(make([]byte, 4096))))
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRateLimiter_Nil(t *testing.T) {
+	var limiter *RateLimiter
+	reader := strings.NewReader("data")
+	assert.Same(t, reader, limiter.Reader(context.Background(), reader))
+}
@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how storage operations are retried after a transient
+// error. Waits grow exponentially from BaseDelay, are capped at MaxDelay and
+// jittered so pools don't retry in lockstep.
+type RetryPolicy struct {
+	// Retries is the number of attempts after the first one, 0 disables retries
+	Retries   int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// delay returns the wait before retry number attempt, starting at 0
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.MaxDelay
+	if attempt < 32 {
+		d = min(p.BaseDelay<<attempt, p.MaxDelay)
+	}
+	if d <= 0 {
+		return 0
+	}
+	// Equal jitter: at least half of the backoff, so retries still spread out
+	return d/2 + rand.N(d/2+1)
+}
+
+// retryingStorage retries the operations of the wrapped pool after transient errors
+type retryingStorage struct {
+	Storage
+	pool   string
+	policy RetryPolicy
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+// WithRetry wraps s so operations failing with a transient error, such as a
+// timeout or a 5xx response, are retried according to policy. A Store is only
+// retried when its reader can seek back to where it started, and a Get only
+// while opening the object, not while it is read. Like Instrument, the
+// wrapper passes StoreOptions and ListPage on to backends that support them.
+func WithRetry(pool string, s Storage, policy RetryPolicy) Storage {
+	if policy.Retries <= 0 {
+		return s
+	}
+	return &retryingStorage{Storage: s, pool: pool, policy: policy, sleep: sleepContext}
+}
+
+// do runs fn until it succeeds, fails permanently or the retries are used up.
+// rewind prepares another attempt and returns false if there can't be one.
+func (s *retryingStorage) do(ctx context.Context, op, key string, rewind func() bool, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= s.policy.Retries || ctx.Err() != nil || !IsTransient(err) {
+			return err
+		}
+		if rewind != nil && !rewind() {
+			return err
+		}
+
+		wait := s.policy.delay(attempt)
+		slog.Warn("storage operation failed, retrying",
+			"storage", s.pool,
+			"operation", op,
+			"key", key,
+			"attempt", attempt+1,
+			"retry_in", wait,
+			"error", err,
+		)
+		// A cancelled wait reports the failure of the operation, not the cancellation
+		if s.sleep(ctx, wait) != nil {
+			return err
+		}
+	}
+}
+
+// seekRewind returns a function moving reader back to its current offset, or
+// nil and false if it can't seek
+func seekRewind(reader io.Reader) (func() bool, bool) {
+	seeker, ok := reader.(io.Seeker)
+	if !ok {
+		return nil, false
+	}
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, false
+	}
+	return func() bool {
+		_, err := seeker.Seek(start, io.SeekStart)
+		return err == nil
+	}, true
+}
+
+func (s *retryingStorage) Store(ctx context.Context, key string, reader io.Reader) error {
+	rewind, ok := seekRewind(reader)
+	if !ok {
+		return s.Storage.Store(ctx, key, reader)
+	}
+	return s.do(ctx, opStore, key, rewind, func() error {
+		return s.Storage.Store(ctx, key, reader)
+	})
+}
+
+func (s *retryingStorage) StoreWithOptions(ctx context.Context, key string, reader io.Reader, opts StoreOptions) error {
+	rewind, ok := seekRewind(reader)
+	if !ok {
+		return Store(ctx, s.Storage, key, reader, opts)
+	}
+	return s.do(ctx, opStore, key, rewind, func() error {
+		return Store(ctx, s.Storage, key, reader, opts)
+	})
+}
+
+func (s *retryingStorage) List(ctx context.Context, prefix string) ([]BackupFile, error) {
+	var files []BackupFile
+	err := s.do(ctx, opList, prefix, nil, func() error {
+		var err error
+		files, err = s.Storage.List(ctx, prefix)
+		return err
+	})
+	return files, err
+}
+
+func (s *retryingStorage) ListPage(ctx context.Context, prefix, marker string, limit int) ([]BackupFile, string, error) {
+	var files []BackupFile
+	var next string
+	err := s.do(ctx, opList, prefix, nil, func() error {
+		var err error
+		files, next, err = ListPage(ctx, s.Storage, prefix, marker, limit)
+		return err
+	})
+	return files, next, err
+}
+
+func (s *retryingStorage) IsTree(key string) bool {
+	return IsTree(s.Storage, key)
+}
+
+func (s *retryingStorage) Delete(ctx context.Context, key string) error {
+	return s.do(ctx, opDelete, key, nil, func() error {
+		return s.Storage.Delete(ctx, key)
+	})
+}
+
+func (s *retryingStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	var reader io.ReadCloser
+	err := s.do(ctx, opGet, key, nil, func() error {
+		var err error
+		reader, err = s.Storage.Get(ctx, key)
+		return err
+	})
+	return reader, err
+}
+
+// IsTransient reports whether err is likely to go away when the operation is
+// tried again: network errors, timeouts, and 408, 429 and 5xx responses.
+// Missing objects, denied access and cancelled contexts are permanent.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	// The HTTP status decides over the network error carrying it, e.g. in the AWS SDK
+	var status interface{ HTTPStatusCode() int }
+	if errors.As(err, &status) && status.HTTPStatusCode() != 0 {
+		code := status.HTTPStatusCode()
+		return code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, syscall.ETIMEDOUT) {
+		return true
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
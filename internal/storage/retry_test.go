@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// statusError mimics the HTTP response errors of the S3 SDK
+type statusError struct{ code int }
+
+func (e statusError) Error() string       { return fmt.Sprintf("status %d", e.code) }
+func (e statusError) HTTPStatusCode() int { return e.code }
+
+// flakyStorage fails the first failures calls of every operation with err
+type flakyStorage struct {
+	fakeStorage
+	err      error
+	failures int
+	calls    int
+	bodies   []string
+}
+
+func (f *flakyStorage) fail() error {
+	f.calls++
+	if f.calls <= f.failures {
+		return f.err
+	}
+	return nil
+}
+
+func (f *flakyStorage) Store(_ context.Context, key string, reader io.Reader) error {
+	data, _ := io.ReadAll(reader)
+	f.bodies = append(f.bodies, string(data))
+	return f.fail()
+}
+
+func (f *flakyStorage) List(context.Context, string) ([]BackupFile, error) {
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	return []BackupFile{{Key: "a"}}, nil
+}
+
+func (f *flakyStorage) Delete(context.Context, string) error {
+	return f.fail()
+}
+
+func (f *flakyStorage) Get(context.Context, string) (io.ReadCloser, error) {
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	return io.NopCloser(strings.NewReader("data")), nil
+}
+
+func newRetrying(inner Storage, retries int) (*retryingStorage, *[]time.Duration) {
+	var waits []time.Duration
+	s := WithRetry("retry-test", inner, RetryPolicy{Retries: retries, BaseDelay: time.Second, MaxDelay: 4 * time.Second}).(*retryingStorage)
+	s.sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	return s, &waits
+}
+
+func TestWithRetry_RetriesTransientErrors(t *testing.T) {
+	ctx := context.Background()
+	inner := &flakyStorage{err: statusError{503}, failures: 2}
+	s, waits := newRetrying(inner, 3)
+
+	files, err := s.List(ctx, "")
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+	assert.Equal(t, 3, inner.calls)
+	require.Len(t, *waits, 2)
+	assert.GreaterOrEqual(t, (*waits)[0], 500*time.Millisecond)
+	assert.LessOrEqual(t, (*waits)[0], time.Second)
+	assert.GreaterOrEqual(t, (*waits)[1], time.Second)
+	assert.LessOrEqual(t, (*waits)[1], 2*time.Second)
+}
+
+func TestWithRetry_GivesUpAfterRetries(t *testing.T) {
+	inner := &flakyStorage{err: statusError{500}, failures: 10}
+	s, _ := newRetrying(inner, 2)
+
+	err := s.Delete(context.Background(), "k")
+	assert.Equal(t, statusError{500}, err)
+	assert.Equal(t, 3, inner.calls)
+}
+
+func TestWithRetry_PermanentErrorsAreNotRetried(t *testing.T) {
+	for _, err := range []error{statusError{403}, statusError{404}, os.ErrNotExist, errors.New("access denied")} {
+		inner := &flakyStorage{err: err, failures: 1}
+		s, _ := newRetrying(inner, 3)
+
+		_, got := s.Get(context.Background(), "k")
+		assert.Equal(t, err, got)
+		assert.Equal(t, 1, inner.calls, "%v", err)
+	}
+}
+
+func TestWithRetry_StoreRewindsReader(t *testing.T) {
+	inner := &flakyStorage{err: syscall.ECONNRESET, failures: 1}
+	s, _ := newRetrying(inner, 3)
+
+	reader := bytes.NewReader([]byte("xxbackup"))
+	_, err := reader.Seek(2, io.SeekStart)
+	require.NoError(t, err)
+
+	require.NoError(t, s.Store(context.Background(), "k", reader))
+	assert.Equal(t, []string{"backup", "backup"}, inner.bodies)
+}
+
+func TestWithRetry_StoreWithoutSeekerIsNotRetried(t *testing.T) {
+	inner := &flakyStorage{err: syscall.ECONNRESET, failures: 1}
+	s, _ := newRetrying(inner, 3)
+
+	err := s.Store(context.Background(), "k", io.MultiReader(strings.NewReader("backup")))
+	assert.ErrorIs(t, err, syscall.ECONNRESET)
+	assert.Equal(t, 1, inner.calls)
+}
+
+func TestWithRetry_StopsWhenContextIsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	inner := &flakyStorage{err: statusError{503}, failures: 10}
+	s := WithRetry("retry-test", inner, RetryPolicy{Retries: 5, BaseDelay: time.Hour, MaxDelay: time.Hour})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.List(ctx, "")
+		done <- err
+	}()
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.Equal(t, statusError{503}, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("retry did not stop after the context was cancelled")
+	}
+}
+
+func TestWithRetry_Disabled(t *testing.T) {
+	inner := &flakyStorage{}
+	assert.Same(t, Storage(inner), WithRetry("retry-test", inner, RetryPolicy{}))
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("boom"), false},
+		{context.Canceled, false},
+		{context.DeadlineExceeded, true},
+		{os.ErrNotExist, false},
+		{statusError{400}, false},
+		{statusError{401}, false},
+		{statusError{403}, false},
+		{statusError{404}, false},
+		{statusError{408}, true},
+		{statusError{429}, true},
+		{statusError{500}, true},
+		{statusError{503}, true},
+		{fmt.Errorf("upload: %w", statusError{502}), true},
+		{io.ErrUnexpectedEOF, true},
+		{fmt.Errorf("write: %w", syscall.EPIPE), true},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, true},
+		{&net.DNSError{Err: "no such host", IsNotFound: true}, false},
+		{&net.DNSError{Err: "timeout", IsTimeout: true}, true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, IsTransient(tt.err), "%v", tt.err)
+	}
+}
+
+func TestPoolManager_SetRetryPolicy(t *testing.T) {
+	inner := &flakyStorage{err: statusError{503}, failures: 1}
+	pm := &PoolManager{pools: map[string]Storage{"main": inner}}
+	pm.SetRetryPolicy(RetryPolicy{Retries: 1})
+
+	s, err := pm.Get("main")
+	require.NoError(t, err)
+	require.NoError(t, s.Delete(context.Background(), "k"))
+	assert.Equal(t, 2, inner.calls)
+}
@@ -2,7 +2,10 @@ package storage
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -28,6 +31,102 @@ type Storage interface {
 	Get(ctx context.Context, key string) (io.ReadCloser, error)
 }
 
+// StoreOptions carries optional hints about an object being stored
+type StoreOptions struct {
+	// Size is the total number of bytes that will be read, or 0 if unknown
+	Size int64
+	// Tags are object tags for backends that support them (e.g. S3), ignored by others
+	Tags map[string]string
+	// Tree marks the data as a zstd-compressed tar of files, which backends
+	// may store unpacked (local pools with format=tree)
+	Tree bool
+}
+
+// OptionsStorer is implemented by backends that can make use of StoreOptions,
+// e.g. to reject a write early or pick an upload strategy
+type OptionsStorer interface {
+	StoreWithOptions(ctx context.Context, key string, reader io.Reader, opts StoreOptions) error
+}
+
+// Store saves backup data using StoreWithOptions when the backend supports it
+// and falls back to a plain Store otherwise
+func Store(ctx context.Context, s Storage, key string, reader io.Reader, opts StoreOptions) error {
+	if optStore, ok := s.(OptionsStorer); ok {
+		return optStore.StoreWithOptions(ctx, key, reader, opts)
+	}
+	return s.Store(ctx, key, reader)
+}
+
+// TreeReporter is implemented by backends that may store data passed with
+// StoreOptions.Tree unpacked. Get re-creates an archive of such a tree, which
+// holds the same files as the stored one but not the same bytes.
+type TreeReporter interface {
+	IsTree(key string) bool
+}
+
+// IsTree reports whether the backend stored key unpacked, see TreeReporter
+func IsTree(s Storage, key string) bool {
+	if reporter, ok := s.(TreeReporter); ok {
+		return reporter.IsTree(key)
+	}
+	return false
+}
+
+// Pager is implemented by backends that can list a prefix page by page
+// without loading every object first, e.g. S3 with its native pagination
+type Pager interface {
+	// ListPage returns up to limit backups matching the prefix whose keys sort
+	// after marker, in key order. nextMarker is passed as marker to get the
+	// next page and is empty after the last one.
+	ListPage(ctx context.Context, prefix, marker string, limit int) (files []BackupFile, nextMarker string, err error)
+}
+
+// ListPage returns a page of backups using the backend's ListPage when it
+// supports paging and cuts it from a full List otherwise
+func ListPage(ctx context.Context, s Storage, prefix, marker string, limit int) ([]BackupFile, string, error) {
+	if pager, ok := s.(Pager); ok {
+		return pager.ListPage(ctx, prefix, marker, limit)
+	}
+
+	files, err := s.List(ctx, prefix)
+	if err != nil {
+		return nil, "", err
+	}
+	files, next := Page(files, marker, limit)
+	return files, next, nil
+}
+
+// Page cuts the page after marker from a full listing, for backends that
+// emulate ListPage
+func Page(files []BackupFile, marker string, limit int) ([]BackupFile, string) {
+	sorted := slices.Clone(files)
+	slices.SortFunc(sorted, func(a, b BackupFile) int { return strings.Compare(a.Key, b.Key) })
+
+	start, _ := slices.BinarySearchFunc(sorted, marker, func(f BackupFile, marker string) int {
+		if f.Key <= marker {
+			return -1
+		}
+		return 1
+	})
+	sorted = sorted[start:]
+	if limit <= 0 || len(sorted) <= limit {
+		return sorted, ""
+	}
+	return sorted[:limit], sorted[limit-1].Key
+}
+
+// checkPrefix is listed to test storage access; no backup lives under it, so
+// the listing stays cheap on object stores
+const checkPrefix = ".docker-backup-check/"
+
+// Check tests that s can be reached and listed without reading any backups
+func Check(ctx context.Context, s Storage) error {
+	if _, _, err := ListPage(ctx, s, checkPrefix, "", 1); err != nil {
+		return fmt.Errorf("failed to list: %w", err)
+	}
+	return nil
+}
+
 // StorageType creates Storage instances from configuration.
 // Each storage backend implements this interface to provide factory functionality.
 type StorageType interface {
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPage(t *testing.T) {
+	files := []BackupFile{{Key: "app/db/3"}, {Key: "app/db/1"}, {Key: "app/db/4"}, {Key: "app/db/2"}}
+
+	page, next := Page(files, "", 3)
+	assert.Equal(t, []BackupFile{{Key: "app/db/1"}, {Key: "app/db/2"}, {Key: "app/db/3"}}, page)
+	assert.Equal(t, "app/db/3", next)
+
+	page, next = Page(files, next, 3)
+	assert.Equal(t, []BackupFile{{Key: "app/db/4"}}, page)
+	assert.Empty(t, next)
+
+	page, next = Page(files, "app/db/2", 2)
+	assert.Equal(t, []BackupFile{{Key: "app/db/3"}, {Key: "app/db/4"}}, page)
+	assert.Empty(t, next)
+
+	page, _ = Page(files, "", 0)
+	assert.Len(t, page, 4)
+	assert.Equal(t, "app/db/3", files[0].Key, "the listing itself is not reordered")
+}
+
+func TestListPage_FallsBackToList(t *testing.T) {
+	files, next, err := ListPage(context.Background(), Instrument("list-page-test", &fakeStorage{}), "", "", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []BackupFile{{Key: "a"}}, files)
+	assert.Empty(t, next)
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	maxTags           = 10
+	maxTagKeyLength   = 128
+	maxTagValueLength = 256
+)
+
+// ParseTags parses a comma-separated list of key=value object tags, e.g.
+// "retention=30d,tier=cold". Keys and values follow the S3 tagging rules:
+// letters, digits, spaces and + - = . _ : / @.
+func ParseTags(s string) (map[string]string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	tags := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid tag %q: expected key=value", pair)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		if key == "" {
+			return nil, fmt.Errorf("invalid tag %q: key must not be empty", pair)
+		}
+		if len(key) > maxTagKeyLength {
+			return nil, fmt.Errorf("invalid tag %q: key exceeds %d characters", pair, maxTagKeyLength)
+		}
+		if len(value) > maxTagValueLength {
+			return nil, fmt.Errorf("invalid tag %q: value exceeds %d characters", pair, maxTagValueLength)
+		}
+		if strings.HasPrefix(strings.ToLower(key), "aws:") {
+			return nil, fmt.Errorf("invalid tag %q: the aws: prefix is reserved", pair)
+		}
+		if err := validateTagChars(key); err != nil {
+			return nil, fmt.Errorf("invalid tag key %q: %w", key, err)
+		}
+		if err := validateTagChars(value); err != nil {
+			return nil, fmt.Errorf("invalid tag value %q: %w", value, err)
+		}
+		if _, exists := tags[key]; exists {
+			return nil, fmt.Errorf("duplicate tag key %q", key)
+		}
+
+		tags[key] = value
+	}
+
+	if len(tags) > maxTags {
+		return nil, fmt.Errorf("too many tags: %d given, at most %d allowed", len(tags), maxTags)
+	}
+
+	return tags, nil
+}
+
+func validateTagChars(s string) error {
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune(" +-=._:/@", r):
+		default:
+			return fmt.Errorf("character %q is not allowed", r)
+		}
+	}
+	return nil
+}
+
+// SortedTagKeys returns the tag keys in lexical order
+func SortedTagKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTags(t *testing.T) {
+	tags, err := ParseTags("retention=30d, tier=cold,owner=team@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"retention": "30d",
+		"tier":      "cold",
+		"owner":     "team@example.com",
+	}, tags)
+}
+
+func TestParseTags_Empty(t *testing.T) {
+	tags, err := ParseTags("")
+	require.NoError(t, err)
+	assert.Nil(t, tags)
+}
+
+func TestParseTags_EmptyValue(t *testing.T) {
+	tags, err := ParseTags("archived=")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"archived": ""}, tags)
+}
+
+func TestParseTags_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"missing value separator": "retention",
+		"empty key":               "=30d",
+		"reserved prefix":         "aws:foo=bar",
+		"invalid character":       "tier=cold&hot",
+		"duplicate key":           "tier=cold,tier=hot",
+		"too many tags":           "a=1,b=2,c=3,d=4,e=5,f=6,g=7,h=8,i=9,j=10,k=11",
+	}
+
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseTags(input)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSortedTagKeys(t *testing.T) {
+	assert.Equal(t, []string{"a", "b", "c"}, SortedTagKeys(map[string]string{"c": "", "a": "", "b": ""}))
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package local
+
+// availableBytes is not implemented on this platform, so the min-free guard is skipped
+func availableBytes(path string) (int64, error) {
+	return 0, errFreeSpaceUnsupported
+}
@@ -0,0 +1,15 @@
+//go:build unix
+
+package local
+
+import "syscall"
+
+// availableBytes returns the number of bytes available to unprivileged users
+// on the filesystem containing path
+func availableBytes(path string) (int64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
@@ -2,6 +2,7 @@ package local
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -9,6 +10,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/shyim/docker-backup/internal/config"
 	"github.com/shyim/docker-backup/internal/storage"
 )
 
@@ -16,6 +18,10 @@ func init() {
 	storage.Register(&LocalStorageType{})
 }
 
+// errFreeSpaceUnsupported is returned by availableBytes on platforms where
+// free space cannot be determined
+var errFreeSpaceUnsupported = errors.New("free space check not supported on this platform")
+
 // LocalStorageType is the factory for local storage
 type LocalStorageType struct{}
 
@@ -36,9 +42,29 @@ func (t *LocalStorageType) Create(poolName string, options map[string]string) (s
 		return nil, fmt.Errorf("failed to create storage directory: %w", err)
 	}
 
+	var minFree int64
+	if val := options["min-free"]; val != "" {
+		size, err := config.ParseSize(val)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'min-free' option: %w", err)
+		}
+		minFree = size
+	}
+
+	format := options["format"]
+	switch format {
+	case "":
+		format = FormatArchive
+	case FormatArchive, FormatTree:
+	default:
+		return nil, fmt.Errorf("invalid 'format' option %q: must be %q or %q", format, FormatArchive, FormatTree)
+	}
+
 	return &LocalStorage{
 		basePath: path,
 		poolName: poolName,
+		minFree:  minFree,
+		format:   format,
 	}, nil
 }
 
@@ -46,12 +72,28 @@ func (t *LocalStorageType) Create(poolName string, options map[string]string) (s
 type LocalStorage struct {
 	basePath string
 	poolName string
+	minFree  int64  // Bytes that must remain free after a write, 0 disables the check
+	format   string // FormatArchive or FormatTree
 }
 
 // Store saves backup data to the local filesystem
 func (l *LocalStorage) Store(ctx context.Context, key string, reader io.Reader) error {
+	return l.StoreWithOptions(ctx, key, reader, storage.StoreOptions{})
+}
+
+// StoreWithOptions saves backup data to the local filesystem, refusing the
+// write up front when the pool doesn't have enough free space for it
+func (l *LocalStorage) StoreWithOptions(ctx context.Context, key string, reader io.Reader, opts storage.StoreOptions) error {
+	if err := l.checkFreeSpace(opts.Size); err != nil {
+		return err
+	}
+
 	fullPath := filepath.Join(l.basePath, key)
 
+	if l.format == FormatTree && opts.Tree {
+		return storeTree(ctx, fullPath, key, reader)
+	}
+
 	// Create parent directories
 	dir := filepath.Dir(fullPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -77,6 +119,35 @@ func (l *LocalStorage) Store(ctx context.Context, key string, reader io.Reader)
 	return nil
 }
 
+// checkFreeSpace verifies that size more bytes fit into the pool while keeping
+// at least minFree bytes available
+func (l *LocalStorage) checkFreeSpace(size int64) error {
+	if l.minFree == 0 && size == 0 {
+		return nil
+	}
+
+	available, err := availableBytes(l.basePath)
+	if err != nil {
+		if errors.Is(err, errFreeSpaceUnsupported) {
+			return nil
+		}
+		return fmt.Errorf("failed to determine free space: %w", err)
+	}
+
+	if size > available {
+		return fmt.Errorf("insufficient free space in storage pool %q: backup needs %s but only %s available",
+			l.poolName, config.FormatSize(size), config.FormatSize(available))
+	}
+
+	if l.minFree > 0 && available-size < l.minFree {
+		return fmt.Errorf("insufficient free space in storage pool %q: %s available, %s required for the backup plus min-free of %s (short by %s)",
+			l.poolName, config.FormatSize(available), config.FormatSize(size), config.FormatSize(l.minFree),
+			config.FormatSize(l.minFree+size-available))
+	}
+
+	return nil
+}
+
 // List returns all backups matching the prefix
 func (l *LocalStorage) List(ctx context.Context, prefix string) ([]storage.BackupFile, error) {
 	searchPath := filepath.Join(l.basePath, prefix)
@@ -87,15 +158,24 @@ func (l *LocalStorage) List(ctx context.Context, prefix string) ([]storage.Backu
 			return err
 		}
 
-		if info.IsDir() {
-			return nil
-		}
-
 		relPath, err := filepath.Rel(l.basePath, path)
 		if err != nil {
 			return err
 		}
 
+		if info.IsDir() {
+			// Trees still being extracted are not backups yet
+			if strings.HasSuffix(path, treePartialSuffix) {
+				return filepath.SkipDir
+			}
+			if path == l.basePath || !isTree(path) {
+				return nil
+			}
+			// A tree is listed as one backup, its contents are not
+			files = append(files, treeFile(path, relPath))
+			return filepath.SkipDir
+		}
+
 		if prefix != "" {
 			relDir := filepath.Dir(relPath)
 			if relDir != prefix && relPath != prefix && !strings.HasPrefix(relPath, prefix+string(filepath.Separator)) {
@@ -139,6 +219,17 @@ func (l *LocalStorage) List(ctx context.Context, prefix string) ([]storage.Backu
 	return files, nil
 }
 
+// ListPage returns a page of the backups matching the prefix in key order. The
+// directory tree is walked in full, the page is cut from the result.
+func (l *LocalStorage) ListPage(ctx context.Context, prefix, marker string, limit int) ([]storage.BackupFile, string, error) {
+	files, err := l.List(ctx, prefix)
+	if err != nil {
+		return nil, "", err
+	}
+	files, next := storage.Page(files, marker, limit)
+	return files, next, nil
+}
+
 // matchesPrefix checks if a file key matches the given prefix pattern
 func matchesPrefix(key, prefix string) bool {
 	// Normalize separators
@@ -157,7 +248,11 @@ func matchesPrefix(key, prefix string) bool {
 func (l *LocalStorage) Delete(ctx context.Context, key string) error {
 	fullPath := filepath.Join(l.basePath, key)
 
-	if err := os.Remove(fullPath); err != nil {
+	if isTree(fullPath) {
+		if err := os.RemoveAll(fullPath); err != nil {
+			return fmt.Errorf("failed to delete tree: %w", err)
+		}
+	} else if err := os.Remove(fullPath); err != nil {
 		if os.IsNotExist(err) {
 			return nil // Already deleted
 		}
@@ -176,10 +271,19 @@ func (l *LocalStorage) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
+// IsTree reports whether key was stored as a tree-format backup
+func (l *LocalStorage) IsTree(key string) bool {
+	return isTree(filepath.Join(l.basePath, key))
+}
+
 // Get retrieves a backup file for reading
 func (l *LocalStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
 	fullPath := filepath.Join(l.basePath, key)
 
+	if isTree(fullPath) {
+		return openTree(ctx, fullPath), nil
+	}
+
 	file, err := os.Open(fullPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
@@ -9,6 +9,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/shyim/docker-backup/internal/storage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -59,6 +60,60 @@ func TestLocalStorageType_Create_CreatesDirectory(t *testing.T) {
 	assert.DirExists(t, newDir)
 }
 
+func TestLocalStorageType_Create_MinFree(t *testing.T) {
+	st := &LocalStorageType{}
+	s, err := st.Create("test-pool", map[string]string{
+		"path":     t.TempDir(),
+		"min-free": "5GB",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(5<<30), s.(*LocalStorage).minFree)
+}
+
+func TestLocalStorageType_Create_InvalidMinFree(t *testing.T) {
+	st := &LocalStorageType{}
+	_, err := st.Create("test-pool", map[string]string{
+		"path":     t.TempDir(),
+		"min-free": "lots",
+	})
+
+	assert.ErrorContains(t, err, "min-free")
+}
+
+func TestLocalStorage_Store_BelowMinFree(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := &LocalStorage{basePath: tmpDir, poolName: "local", minFree: 1 << 62}
+
+	err := store.Store(context.Background(), "container/backup.sql", strings.NewReader("data"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `storage pool "local"`)
+	assert.Contains(t, err.Error(), "min-free")
+
+	assert.NoFileExists(t, filepath.Join(tmpDir, "container/backup.sql"))
+}
+
+func TestLocalStorage_StoreWithOptions_SizeDoesNotFit(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := &LocalStorage{basePath: tmpDir, poolName: "local"}
+
+	err := store.StoreWithOptions(context.Background(), "container/backup.sql", strings.NewReader("data"), storage.StoreOptions{Size: 1 << 62})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "insufficient free space")
+
+	assert.NoFileExists(t, filepath.Join(tmpDir, "container/backup.sql"))
+}
+
+func TestLocalStorage_StoreWithOptions_Fits(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := &LocalStorage{basePath: tmpDir, poolName: "local", minFree: 1}
+
+	err := storage.Store(context.Background(), store, "container/backup.sql", strings.NewReader("data"), storage.StoreOptions{Size: 4})
+	require.NoError(t, err)
+
+	assert.FileExists(t, filepath.Join(tmpDir, "container/backup.sql"))
+}
+
 func TestLocalStorage_Store(t *testing.T) {
 	tmpDir := t.TempDir()
 	storage := &LocalStorage{basePath: tmpDir}
@@ -329,3 +384,32 @@ func TestLocalStorage_LargeFile(t *testing.T) {
 	require.NoError(t, err)
 	assert.Len(t, retrieved, len(data))
 }
+
+func TestLocalStorage_ListPage(t *testing.T) {
+	tmpDir := t.TempDir()
+	storage := &LocalStorage{basePath: tmpDir}
+
+	for _, f := range []string{
+		"container1/db/2024-01-15/030000.sql",
+		"container1/db/2024-01-16/030000.sql",
+		"container1/db/2024-01-17/030000.sql",
+		"container2/files/2024-01-15/030000.tar",
+	} {
+		fullPath := filepath.Join(tmpDir, f)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+		require.NoError(t, os.WriteFile(fullPath, []byte("data"), 0644))
+	}
+
+	ctx := context.Background()
+	results, next, err := storage.ListPage(ctx, "container1/db", "", 2)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "container1/db/2024-01-15/030000.sql", results[0].Key)
+	assert.Equal(t, "container1/db/2024-01-16/030000.sql", next)
+
+	results, next, err = storage.ListPage(ctx, "container1/db", next, 2)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "container1/db/2024-01-17/030000.sql", results[0].Key)
+	assert.Empty(t, next)
+}
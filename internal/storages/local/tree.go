@@ -0,0 +1,281 @@
+package local
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/shyim/docker-backup/internal/storage"
+)
+
+// Pool formats
+const (
+	// FormatArchive stores every backup as a single file (the default)
+	FormatArchive = "archive"
+	// FormatTree extracts backups stored with StoreOptions.Tree into a
+	// directory for inspection
+	FormatTree = "tree"
+)
+
+const (
+	// treeMarker marks a directory as a tree-format backup; it is written last,
+	// so only complete trees are listed
+	treeMarker = ".docker-backup-tree"
+	// treePartialSuffix is appended to a tree's directory while it is extracted
+	treePartialSuffix = ".tree-partial"
+)
+
+// isTree reports whether dir holds a tree-format backup
+func isTree(dir string) bool {
+	info, err := os.Lstat(filepath.Join(dir, treeMarker))
+	return err == nil && info.Mode().IsRegular()
+}
+
+// storeTree extracts the zstd-compressed tar in reader into dir. Entries are
+// written through an os.Root, so neither their names nor symlinks can place
+// files outside the tree.
+func storeTree(ctx context.Context, dir, key string, reader io.Reader) (err error) {
+	partial := dir + treePartialSuffix
+	if err := os.RemoveAll(partial); err != nil {
+		return fmt.Errorf("failed to clean up partial tree: %w", err)
+	}
+	if err := os.MkdirAll(partial, 0755); err != nil {
+		return fmt.Errorf("failed to create directories: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = os.RemoveAll(partial)
+		}
+	}()
+
+	root, err := os.OpenRoot(partial)
+	if err != nil {
+		return fmt.Errorf("failed to open tree: %w", err)
+	}
+	defer func() {
+		_ = root.Close()
+	}()
+
+	if err := extractTree(ctx, root, reader); err != nil {
+		return err
+	}
+
+	marker := fmt.Sprintf("docker-backup tree-format backup of %s\n", key)
+	if err := root.WriteFile(treeMarker, []byte(marker), 0644); err != nil {
+		return fmt.Errorf("failed to write tree marker: %w", err)
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to replace existing backup: %w", err)
+	}
+	if err := os.Rename(partial, dir); err != nil {
+		return fmt.Errorf("failed to finish tree: %w", err)
+	}
+	return nil
+}
+
+func extractTree(ctx context.Context, root *os.Root, reader io.Reader) error {
+	zstdReader, err := zstd.NewReader(reader)
+	if err != nil {
+		return fmt.Errorf("failed to create zstd reader: %w", err)
+	}
+	defer zstdReader.Close()
+
+	// Directory times are set last, as writing their contents changes them
+	dirTimes := make(map[string]time.Time)
+
+	tarReader := tar.NewReader(zstdReader)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		name := path.Clean(strings.TrimPrefix(header.Name, "/"))
+		if name == "." {
+			continue
+		}
+		if name == treeMarker {
+			return fmt.Errorf("archive contains reserved entry %q", treeMarker)
+		}
+
+		if err := root.MkdirAll(path.Dir(name), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", name, err)
+		}
+
+		mode := fs.FileMode(header.Mode).Perm()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := root.MkdirAll(name, 0755); err != nil {
+				return fmt.Errorf("failed to create directory %s: %w", name, err)
+			}
+			if err := root.Chmod(name, mode); err != nil {
+				return fmt.Errorf("failed to set mode of %s: %w", name, err)
+			}
+			dirTimes[name] = header.ModTime
+		case tar.TypeReg:
+			file, err := root.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+			if err != nil {
+				return fmt.Errorf("failed to create %s: %w", name, err)
+			}
+			_, err = io.Copy(file, tarReader)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return fmt.Errorf("failed to write %s: %w", name, err)
+			}
+		case tar.TypeSymlink:
+			if err := root.Symlink(header.Linkname, name); err != nil {
+				return fmt.Errorf("failed to create symlink %s: %w", name, err)
+			}
+		case tar.TypeLink:
+			if err := root.Link(path.Clean(strings.TrimPrefix(header.Linkname, "/")), name); err != nil {
+				return fmt.Errorf("failed to create hardlink %s: %w", name, err)
+			}
+		default:
+			slog.Warn("skipping unsupported archive entry in tree-format backup", "entry", name, "type", string(header.Typeflag))
+			continue
+		}
+
+		// Ownership is kept where the daemon may set it, i.e. when running as root
+		_ = root.Lchown(name, header.Uid, header.Gid)
+		if header.Typeflag == tar.TypeReg {
+			_ = root.Chtimes(name, header.ModTime, header.ModTime)
+		}
+	}
+
+	for name, modTime := range dirTimes {
+		_ = root.Chtimes(name, modTime, modTime)
+	}
+	return nil
+}
+
+// openTree streams a tree-format backup as a zstd-compressed tar, the format
+// it was stored from, so restores don't need to know about trees
+func openTree(ctx context.Context, dir string) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeTree(ctx, dir, pw))
+	}()
+	return pr
+}
+
+func writeTree(ctx context.Context, dir string, w io.Writer) error {
+	zstdWriter, err := zstd.NewWriter(w)
+	if err != nil {
+		return fmt.Errorf("failed to create zstd writer: %w", err)
+	}
+	tarWriter := tar.NewWriter(zstdWriter)
+
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." || rel == treeMarker {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		var link string
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+
+		// Hardlinks are not detected and come back as separate files
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		file, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+		_, err = io.Copy(tarWriter, file)
+		return err
+	})
+	if err != nil {
+		_ = zstdWriter.Close()
+		return fmt.Errorf("failed to read tree: %w", err)
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return fmt.Errorf("failed to close tar writer: %w", err)
+	}
+	if err := zstdWriter.Close(); err != nil {
+		return fmt.Errorf("failed to close zstd writer: %w", err)
+	}
+	return nil
+}
+
+// treeFile describes a tree-format backup as a single backup file, created
+// when its marker was written
+func treeFile(dir, key string) storage.BackupFile {
+	file := storage.BackupFile{Key: key, Size: treeSize(dir)}
+	if marker, err := os.Stat(filepath.Join(dir, treeMarker)); err == nil {
+		file.LastModified = marker.ModTime()
+	}
+	return file
+}
+
+// treeSize returns the total size of the files in a tree
+func treeSize(dir string) int64 {
+	var size int64
+	_ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() && d.Name() != treeMarker {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,172 @@
+package local
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/shyim/docker-backup/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type tarEntry struct {
+	header  tar.Header
+	content string
+}
+
+func buildArchive(t *testing.T, entries []tarEntry) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw, err := zstd.NewWriter(&buf)
+	require.NoError(t, err)
+	tw := tar.NewWriter(zw)
+	for _, e := range entries {
+		header := e.header
+		header.Size = int64(len(e.content))
+		require.NoError(t, tw.WriteHeader(&header))
+		_, err := tw.Write([]byte(e.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+// readArchive returns the archive's entries by name with the content of regular files
+func readArchive(t *testing.T, r io.Reader) map[string]tarEntry {
+	t.Helper()
+
+	zr, err := zstd.NewReader(r)
+	require.NoError(t, err)
+	defer zr.Close()
+
+	entries := make(map[string]tarEntry)
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		entries[header.Name] = tarEntry{header: *header, content: string(content)}
+	}
+	return entries
+}
+
+func newTreeStorage(t *testing.T) (*LocalStorage, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	s, err := (&LocalStorageType{}).Create("tree", map[string]string{"path": dir, "format": FormatTree})
+	require.NoError(t, err)
+	return s.(*LocalStorage), dir
+}
+
+func TestLocalStorageType_Create_InvalidFormat(t *testing.T) {
+	_, err := (&LocalStorageType{}).Create("test-pool", map[string]string{"path": t.TempDir(), "format": "zip"})
+	assert.Error(t, err)
+}
+
+func TestLocalStorage_Tree_RoundTrip(t *testing.T) {
+	s, dir := newTreeStorage(t)
+	ctx := context.Background()
+	key := "app/files/2026-01-15/030000.tar.zst"
+
+	archive := buildArchive(t, []tarEntry{
+		{header: tar.Header{Name: "data/", Typeflag: tar.TypeDir, Mode: 0755}},
+		{header: tar.Header{Name: "data/config.json", Typeflag: tar.TypeReg, Mode: 0600}, content: `{"debug":true}`},
+		{header: tar.Header{Name: "data/current", Typeflag: tar.TypeSymlink, Linkname: "config.json"}},
+	})
+	require.NoError(t, s.StoreWithOptions(ctx, key, bytes.NewReader(archive), storage.StoreOptions{Tree: true}))
+
+	// The backup is inspectable on disk
+	content, err := os.ReadFile(filepath.Join(dir, key, "data", "config.json"))
+	require.NoError(t, err)
+	assert.Equal(t, `{"debug":true}`, string(content))
+	assert.FileExists(t, filepath.Join(dir, key, treeMarker))
+	assert.True(t, s.IsTree(key))
+
+	files, err := s.List(ctx, "app/")
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, key, files[0].Key)
+	assert.Equal(t, int64(len(`{"debug":true}`)), files[0].Size)
+
+	reader, err := s.Get(ctx, key)
+	require.NoError(t, err)
+	entries := readArchive(t, reader)
+	require.NoError(t, reader.Close())
+
+	assert.NotContains(t, entries, treeMarker)
+	assert.Equal(t, `{"debug":true}`, entries["data/config.json"].content)
+	assert.Equal(t, int64(0600), entries["data/config.json"].header.Mode&0777)
+	assert.Equal(t, byte(tar.TypeDir), entries["data/"].header.Typeflag)
+	assert.Equal(t, "config.json", entries["data/current"].header.Linkname)
+
+	require.NoError(t, s.Delete(ctx, key))
+	assert.NoDirExists(t, filepath.Join(dir, "app"))
+}
+
+func TestLocalStorage_Tree_OtherBackupsStayFiles(t *testing.T) {
+	s, dir := newTreeStorage(t)
+	ctx := context.Background()
+
+	// Only data stored with the tree option is extracted, whatever its key
+	archive := buildArchive(t, []tarEntry{{header: tar.Header{Name: "dump.sql", Typeflag: tar.TypeReg, Mode: 0644}, content: "dump"}})
+	require.NoError(t, s.StoreWithOptions(ctx, "app/db/2026-01-15/030000.tar.zst", bytes.NewReader(archive), storage.StoreOptions{Size: int64(len(archive))}))
+	require.NoError(t, s.Store(ctx, "app/db/2026-01-15/040000.sql.zst", bytes.NewReader([]byte("dump"))))
+
+	for _, key := range []string{"app/db/2026-01-15/030000.tar.zst", "app/db/2026-01-15/040000.sql.zst"} {
+		info, err := os.Stat(filepath.Join(dir, key))
+		require.NoError(t, err)
+		assert.True(t, info.Mode().IsRegular(), key)
+		assert.False(t, s.IsTree(key), key)
+	}
+}
+
+func TestLocalStorage_Tree_RejectsEscapes(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []tarEntry
+	}{
+		{
+			name:    "parent directory",
+			entries: []tarEntry{{header: tar.Header{Name: "../evil.txt", Typeflag: tar.TypeReg, Mode: 0644}, content: "x"}},
+		},
+		{
+			name: "through symlink",
+			entries: []tarEntry{
+				{header: tar.Header{Name: "out", Typeflag: tar.TypeSymlink, Linkname: "/tmp"}},
+				{header: tar.Header{Name: "out/evil.txt", Typeflag: tar.TypeReg, Mode: 0644}, content: "x"},
+			},
+		},
+		{
+			name:    "marker",
+			entries: []tarEntry{{header: tar.Header{Name: treeMarker, Typeflag: tar.TypeReg, Mode: 0644}, content: "x"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, dir := newTreeStorage(t)
+			key := "app/files/2026-01-15/030000.tar.zst"
+
+			err := s.StoreWithOptions(context.Background(), key, bytes.NewReader(buildArchive(t, tt.entries)), storage.StoreOptions{Tree: true})
+			assert.Error(t, err)
+
+			// Nothing is left behind, not even a partial tree
+			assert.NoDirExists(t, filepath.Join(dir, key))
+			assert.NoDirExists(t, filepath.Join(dir, key+treePartialSuffix))
+			assert.NoFileExists(t, filepath.Join(dir, "app/files/2026-01-15/evil.txt"))
+		})
+	}
+}
@@ -6,4 +6,5 @@ import (
 	// Import all storage backends for self-registration
 	_ "github.com/shyim/docker-backup/internal/storages/local"
 	_ "github.com/shyim/docker-backup/internal/storages/s3"
+	_ "github.com/shyim/docker-backup/internal/storages/sftp"
 )
@@ -4,12 +4,17 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/url"
+	"path"
+	"slices"
 	"sort"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/transfermanager"
+	tmtypes "github.com/aws/aws-sdk-go-v2/feature/s3/transfermanager/types"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/shyim/docker-backup/internal/storage"
 )
@@ -34,9 +39,7 @@ func (t *S3StorageType) Create(poolName string, options map[string]string) (stor
 	}
 
 	region := options["region"]
-	if region == "" {
-		region = "us-east-1"
-	}
+	profile := options["profile"]
 
 	endpoint := options["endpoint"]
 	accessKey := options["access-key"]
@@ -45,13 +48,33 @@ func (t *S3StorageType) Create(poolName string, options map[string]string) (stor
 
 	prefix := options["prefix"]
 
+	sse, kmsKeyID, err := parseEncryption(options["sse"], options["kms-key-id"])
+	if err != nil {
+		return nil, err
+	}
+	storageClass, err := parseStorageClass(options["storage-class"])
+	if err != nil {
+		return nil, err
+	}
+
 	ctx := context.Background()
 
 	// Build AWS config
 	var cfgOpts []func(*config.LoadOptions) error
-	cfgOpts = append(cfgOpts, config.WithRegion(region))
 
-	// Use static credentials if provided
+	// Without a profile the region always defaults to us-east-1, with one the
+	// profile's region is used unless a region is set explicitly
+	if region == "" && profile == "" {
+		region = "us-east-1"
+	}
+	if region != "" {
+		cfgOpts = append(cfgOpts, config.WithRegion(region))
+	}
+
+	// Credentials: static keys, then the named profile, then the default chain
+	if profile != "" {
+		cfgOpts = append(cfgOpts, config.WithSharedConfigProfile(profile))
+	}
 	if accessKey != "" && secretKey != "" {
 		cfgOpts = append(cfgOpts, config.WithCredentialsProvider(
 			credentials.NewStaticCredentialsProvider(accessKey, secretKey, ""),
@@ -60,8 +83,14 @@ func (t *S3StorageType) Create(poolName string, options map[string]string) (stor
 
 	cfg, err := config.LoadDefaultConfig(ctx, cfgOpts...)
 	if err != nil {
+		if profile != "" {
+			return nil, fmt.Errorf("failed to load AWS profile %q for pool %q: %w", profile, poolName, err)
+		}
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
 
 	// Build S3 client options
 	var s3Opts []func(*s3.Options)
@@ -82,14 +111,70 @@ func (t *S3StorageType) Create(poolName string, options map[string]string) (stor
 	uploader := transfermanager.New(client)
 
 	return &S3Storage{
-		client:   client,
-		uploader: uploader,
-		bucket:   bucket,
-		prefix:   prefix,
-		poolName: poolName,
+		client:       client,
+		uploader:     uploader,
+		bucket:       bucket,
+		prefix:       prefix,
+		poolName:     poolName,
+		sse:          sse,
+		kmsKeyID:     kmsKeyID,
+		storageClass: storageClass,
 	}, nil
 }
 
+// parseEncryption validates the server-side encryption options. A KMS key
+// can only be given together with a KMS algorithm.
+func parseEncryption(sse, kmsKeyID string) (tmtypes.ServerSideEncryption, string, error) {
+	switch algorithm := tmtypes.ServerSideEncryption(sse); algorithm {
+	case "":
+		if kmsKeyID != "" {
+			return "", "", fmt.Errorf("S3 option 'kms-key-id' requires 'sse' set to %q", tmtypes.ServerSideEncryptionAwsKms)
+		}
+		return "", "", nil
+	case tmtypes.ServerSideEncryptionAes256:
+		if kmsKeyID != "" {
+			return "", "", fmt.Errorf("S3 option 'kms-key-id' requires 'sse' set to %q, not %q", tmtypes.ServerSideEncryptionAwsKms, sse)
+		}
+		return algorithm, "", nil
+	case tmtypes.ServerSideEncryptionAwsKms, tmtypes.ServerSideEncryptionAwsKmsDsse:
+		return algorithm, kmsKeyID, nil
+	default:
+		return "", "", fmt.Errorf("invalid S3 option 'sse' %q: must be %q, %q or %q", sse,
+			tmtypes.ServerSideEncryptionAes256, tmtypes.ServerSideEncryptionAwsKms, tmtypes.ServerSideEncryptionAwsKmsDsse)
+	}
+}
+
+// storageClasses are the values accepted by the storage-class option
+var storageClasses = []tmtypes.StorageClass{
+	tmtypes.StorageClassStandard,
+	tmtypes.StorageClassReducedRedundancy,
+	tmtypes.StorageClassStandardIa,
+	tmtypes.StorageClassOnezoneIa,
+	tmtypes.StorageClassIntelligentTiering,
+	tmtypes.StorageClassGlacier,
+	tmtypes.StorageClassDeepArchive,
+	tmtypes.StorageClassOutposts,
+	tmtypes.StorageClassGlacierIr,
+	tmtypes.StorageClassSnow,
+	tmtypes.StorageClassExpressOnezone,
+}
+
+// parseStorageClass validates the storage-class option, empty keeps the bucket default
+func parseStorageClass(class string) (tmtypes.StorageClass, error) {
+	if class == "" {
+		return "", nil
+	}
+	if slices.Contains(storageClasses, tmtypes.StorageClass(class)) {
+		return tmtypes.StorageClass(class), nil
+	}
+
+	names := make([]string, len(storageClasses))
+	for i, c := range storageClasses {
+		names[i] = string(c)
+	}
+	return "", fmt.Errorf("invalid S3 option 'storage-class' %q: must be one of %s", class, strings.Join(names, ", "))
+}
+
 // S3Storage implements Storage for S3-compatible backends
 type S3Storage struct {
 	client   *s3.Client
@@ -97,18 +182,42 @@ type S3Storage struct {
 	bucket   string
 	prefix   string
 	poolName string
+
+	// Applied to every upload, empty values keep the bucket defaults
+	sse          tmtypes.ServerSideEncryption
+	kmsKeyID     string
+	storageClass tmtypes.StorageClass
 }
 
 // Store saves backup data to S3 using multipart upload for streaming
 func (s *S3Storage) Store(ctx context.Context, key string, reader io.Reader) error {
+	return s.StoreWithOptions(ctx, key, reader, storage.StoreOptions{})
+}
+
+// StoreWithOptions saves backup data to S3, applying any object tags
+func (s *S3Storage) StoreWithOptions(ctx context.Context, key string, reader io.Reader, opts storage.StoreOptions) error {
 	fullKey := s.fullKey(key)
 
-	_, err := s.uploader.UploadObject(ctx, &transfermanager.UploadObjectInput{
+	input := &transfermanager.UploadObjectInput{
 		Bucket:      aws.String(s.bucket),
 		Key:         aws.String(fullKey),
 		Body:        reader,
-		ContentType: aws.String("application/gzip"),
-	})
+		ContentType: aws.String(contentType(key)),
+	}
+	if len(opts.Tags) > 0 {
+		input.Tagging = aws.String(encodeTagging(opts.Tags))
+	}
+	if s.sse != "" {
+		input.ServerSideEncryption = s.sse
+	}
+	if s.kmsKeyID != "" {
+		input.SSEKMSKeyID = aws.String(s.kmsKeyID)
+	}
+	if s.storageClass != "" {
+		input.StorageClass = s.storageClass
+	}
+
+	_, err := s.uploader.UploadObject(ctx, input)
 	if err != nil {
 		return fmt.Errorf("failed to upload to S3: %w", err)
 	}
@@ -133,13 +242,8 @@ func (s *S3Storage) List(ctx context.Context, prefix string) ([]storage.BackupFi
 		}
 
 		for _, obj := range page.Contents {
-			relKey := *obj.Key
-			if s.prefix != "" {
-				relKey = relKey[len(s.prefix)+1:]
-			}
-
 			files = append(files, storage.BackupFile{
-				Key:          relKey,
+				Key:          s.relKey(*obj.Key),
 				Size:         *obj.Size,
 				LastModified: *obj.LastModified,
 			})
@@ -153,6 +257,44 @@ func (s *S3Storage) List(ctx context.Context, prefix string) ([]storage.BackupFi
 	return files, nil
 }
 
+// ListPage returns a page of the backups matching the prefix in key order,
+// fetching only that page from S3
+func (s *S3Storage) ListPage(ctx context.Context, prefix, marker string, limit int) ([]storage.BackupFile, string, error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(s.fullKey(prefix)),
+	}
+	if marker != "" {
+		input.StartAfter = aws.String(s.fullKey(marker))
+	}
+	if limit > 0 {
+		input.MaxKeys = aws.Int32(int32(min(limit, 1000)))
+	}
+
+	var files []storage.BackupFile
+	paginator := s3.NewListObjectsV2Paginator(s.client, input)
+	for paginator.HasMorePages() && (limit <= 0 || len(files) < limit) {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to list objects: %w", err)
+		}
+
+		for _, obj := range page.Contents {
+			files = append(files, storage.BackupFile{
+				Key:          s.relKey(*obj.Key),
+				Size:         *obj.Size,
+				LastModified: *obj.LastModified,
+			})
+		}
+	}
+
+	if limit <= 0 || len(files) < limit || (len(files) == limit && !paginator.HasMorePages()) {
+		return files, "", nil
+	}
+	files = files[:limit]
+	return files, files[limit-1].Key, nil
+}
+
 // Delete removes a backup from S3
 func (s *S3Storage) Delete(ctx context.Context, key string) error {
 	fullKey := s.fullKey(key)
@@ -183,6 +325,37 @@ func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error)
 	return result.Body, nil
 }
 
+// contentTypes maps the outermost extension of a backup key to its media type
+var contentTypes = map[string]string{
+	".zst": "application/zstd",
+	".gz":  "application/gzip",
+	".tar": "application/x-tar",
+}
+
+// contentType returns the media type of a backup by its key. Encrypted
+// backups end in their encryption extension and are plain binary data.
+func contentType(key string) string {
+	if ct, ok := contentTypes[path.Ext(key)]; ok {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+// encodeTagging encodes tags as URL query parameters, the format S3 expects in
+// the x-amz-tagging header
+func encodeTagging(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for _, k := range storage.SortedTagKeys(tags) {
+		pairs = append(pairs, tagEscape(k)+"="+tagEscape(tags[k]))
+	}
+	return strings.Join(pairs, "&")
+}
+
+// tagEscape percent-encodes s, using %20 rather than + for spaces
+func tagEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
 // fullKey returns the full S3 key including any prefix
 func (s *S3Storage) fullKey(key string) string {
 	if s.prefix == "" {
@@ -190,3 +363,11 @@ func (s *S3Storage) fullKey(key string) string {
 	}
 	return s.prefix + "/" + key
 }
+
+// relKey strips the pool prefix from an object key
+func (s *S3Storage) relKey(fullKey string) string {
+	if s.prefix == "" {
+		return fullKey
+	}
+	return fullKey[len(s.prefix)+1:]
+}
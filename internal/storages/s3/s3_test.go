@@ -0,0 +1,281 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeTagging(t *testing.T) {
+	tags := map[string]string{
+		"tier":      "cold storage",
+		"retention": "30d",
+		"owner":     "ops+db@example.com",
+		"path":      "a/b=c",
+	}
+
+	assert.Equal(t, "owner=ops%2Bdb%40example.com&path=a%2Fb%3Dc&retention=30d&tier=cold%20storage", encodeTagging(tags))
+}
+
+func writeSharedConfig(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+
+	credentials := filepath.Join(dir, "credentials")
+	require.NoError(t, os.WriteFile(credentials, []byte("[backup]\naws_access_key_id = AKIDPROFILE\naws_secret_access_key = profile-secret\n"), 0o600))
+	configFile := filepath.Join(dir, "config")
+	require.NoError(t, os.WriteFile(configFile, []byte("[profile backup]\nregion = eu-central-1\n"), 0o600))
+
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentials)
+	t.Setenv("AWS_CONFIG_FILE", configFile)
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+}
+
+func TestCreate_Profile(t *testing.T) {
+	writeSharedConfig(t)
+	factory := &S3StorageType{}
+
+	store, err := factory.Create("s3", map[string]string{"bucket": "b", "profile": "backup"})
+	require.NoError(t, err)
+	client := store.(*S3Storage).client
+	assert.Equal(t, "eu-central-1", client.Options().Region)
+
+	creds, err := client.Options().Credentials.Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "AKIDPROFILE", creds.AccessKeyID)
+
+	// Explicit options take precedence over the profile
+	store, err = factory.Create("s3", map[string]string{
+		"bucket":     "b",
+		"profile":    "backup",
+		"region":     "us-west-2",
+		"access-key": "AKIDSTATIC",
+		"secret-key": "static-secret",
+	})
+	require.NoError(t, err)
+	client = store.(*S3Storage).client
+	assert.Equal(t, "us-west-2", client.Options().Region)
+
+	creds, err = client.Options().Credentials.Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "AKIDSTATIC", creds.AccessKeyID)
+}
+
+func TestCreate_UnknownProfile(t *testing.T) {
+	writeSharedConfig(t)
+
+	_, err := (&S3StorageType{}).Create("offsite", map[string]string{"bucket": "b", "profile": "missing"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `failed to load AWS profile "missing" for pool "offsite"`)
+}
+
+func TestCreate_DefaultRegion(t *testing.T) {
+	writeSharedConfig(t)
+
+	store, err := (&S3StorageType{}).Create("s3", map[string]string{"bucket": "b"})
+	require.NoError(t, err)
+	assert.Equal(t, "us-east-1", store.(*S3Storage).client.Options().Region)
+}
+
+func TestContentType(t *testing.T) {
+	assert.Equal(t, "application/zstd", contentType("app/db/2026-01-15/030000.tar.zst"))
+	assert.Equal(t, "application/gzip", contentType("app/db/2026-01-15/030000.tar.gz"))
+	assert.Equal(t, "application/x-tar", contentType("app/db/2026-01-15/030000.tar"))
+	assert.Equal(t, "application/octet-stream", contentType("app/db/2026-01-15/030000.tar.zst.enc"))
+	assert.Equal(t, "application/octet-stream", contentType("app/db/2026-01-15/030000.bin"))
+}
+
+// fakeS3 records the upload requests it receives and accepts them
+type fakeS3 struct {
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, _ = io.Copy(io.Discard, r.Body)
+	f.mu.Lock()
+	f.requests = append(f.requests, r)
+	f.mu.Unlock()
+	w.Header().Set("ETag", `"d41d8cd98f00b204e9800998ecf8427e"`)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (f *fakeS3) puts() []*http.Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var puts []*http.Request
+	for _, r := range f.requests {
+		if r.Method == http.MethodPut {
+			puts = append(puts, r)
+		}
+	}
+	return puts
+}
+
+func newFakeS3Storage(t *testing.T, options map[string]string) (*S3Storage, *fakeS3) {
+	t.Helper()
+	writeSharedConfig(t)
+
+	fake := &fakeS3{}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	opts := map[string]string{
+		"bucket":     "backups",
+		"endpoint":   server.URL,
+		"path-style": "true",
+		"access-key": "AKIDTEST",
+		"secret-key": "test-secret",
+	}
+	for k, v := range options {
+		opts[k] = v
+	}
+
+	store, err := (&S3StorageType{}).Create("s3", opts)
+	require.NoError(t, err)
+	return store.(*S3Storage), fake
+}
+
+func TestStore_ContentType(t *testing.T) {
+	store, fake := newFakeS3Storage(t, nil)
+
+	require.NoError(t, store.Store(context.Background(), "app/db/2026-01-15/030000.tar.zst", strings.NewReader("archive")))
+	require.NoError(t, store.Store(context.Background(), "app/db/2026-01-15/040000.tar.zst.age", strings.NewReader("archive")))
+
+	puts := fake.puts()
+	require.Len(t, puts, 2)
+	assert.Equal(t, "/backups/app/db/2026-01-15/030000.tar.zst", puts[0].URL.Path)
+	assert.Equal(t, "application/zstd", puts[0].Header.Get("Content-Type"))
+	assert.Equal(t, "application/octet-stream", puts[1].Header.Get("Content-Type"))
+}
+
+func TestCreate_EncryptionOptions(t *testing.T) {
+	writeSharedConfig(t)
+	factory := &S3StorageType{}
+
+	store, err := factory.Create("s3", map[string]string{"bucket": "b", "sse": "aws:kms", "kms-key-id": "alias/backups", "storage-class": "STANDARD_IA"})
+	require.NoError(t, err)
+	s := store.(*S3Storage)
+	assert.Equal(t, "aws:kms", string(s.sse))
+	assert.Equal(t, "alias/backups", s.kmsKeyID)
+	assert.Equal(t, "STANDARD_IA", string(s.storageClass))
+
+	for name, opts := range map[string]map[string]string{
+		"unknown sse":             {"bucket": "b", "sse": "rot13"},
+		"kms key without sse":     {"bucket": "b", "kms-key-id": "alias/backups"},
+		"kms key with AES256":     {"bucket": "b", "sse": "AES256", "kms-key-id": "alias/backups"},
+		"unknown storage class":   {"bucket": "b", "storage-class": "COLD"},
+		"lowercase storage class": {"bucket": "b", "storage-class": "glacier"},
+	} {
+		_, err := factory.Create("s3", opts)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestStore_EncryptionHeaders(t *testing.T) {
+	store, fake := newFakeS3Storage(t, map[string]string{"sse": "aws:kms", "kms-key-id": "alias/backups", "storage-class": "GLACIER"})
+	require.NoError(t, store.Store(context.Background(), "app/db/2026-01-15/030000.tar.zst", strings.NewReader("archive")))
+
+	plain, plainFake := newFakeS3Storage(t, nil)
+	require.NoError(t, plain.Store(context.Background(), "app/db/2026-01-15/030000.tar.zst", strings.NewReader("archive")))
+
+	puts := fake.puts()
+	require.Len(t, puts, 1)
+	assert.Equal(t, "aws:kms", puts[0].Header.Get("X-Amz-Server-Side-Encryption"))
+	assert.Equal(t, "alias/backups", puts[0].Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"))
+	assert.Equal(t, "GLACIER", puts[0].Header.Get("X-Amz-Storage-Class"))
+
+	puts = plainFake.puts()
+	require.Len(t, puts, 1)
+	assert.Empty(t, puts[0].Header.Get("X-Amz-Server-Side-Encryption"))
+	assert.Empty(t, puts[0].Header.Get("X-Amz-Storage-Class"))
+}
+
+// listS3 answers ListObjectsV2 requests from a fixed set of keys
+func listS3(t *testing.T, keys []string) (*S3Storage, *[]url.Values) {
+	t.Helper()
+	writeSharedConfig(t)
+
+	var queries []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		queries = append(queries, query)
+		maxKeys, err := strconv.Atoi(query.Get("max-keys"))
+		if err != nil {
+			maxKeys = 1000
+		}
+
+		var contents strings.Builder
+		count := 0
+		truncated := false
+		for _, key := range keys {
+			if !strings.HasPrefix(key, query.Get("prefix")) || key <= query.Get("start-after") {
+				continue
+			}
+			if count == maxKeys {
+				truncated = true
+				break
+			}
+			count++
+			fmt.Fprintf(&contents, "<Contents><Key>%s</Key><Size>7</Size><LastModified>2026-01-15T03:00:00.000Z</LastModified></Contents>", key)
+		}
+		token := ""
+		if truncated {
+			token = "<NextContinuationToken>more</NextContinuationToken>"
+		}
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><ListBucketResult><Name>backups</Name><KeyCount>%d</KeyCount><IsTruncated>%t</IsTruncated>%s%s</ListBucketResult>`, count, truncated, token, contents.String())
+	}))
+	t.Cleanup(server.Close)
+
+	store, err := (&S3StorageType{}).Create("s3", map[string]string{
+		"bucket":     "backups",
+		"endpoint":   server.URL,
+		"path-style": "true",
+		"access-key": "AKIDTEST",
+		"secret-key": "test-secret",
+		"prefix":     "pool",
+	})
+	require.NoError(t, err)
+	return store.(*S3Storage), &queries
+}
+
+func TestListPage(t *testing.T) {
+	store, queries := listS3(t, []string{
+		"pool/app/db/2026-01-13/030000.tar.zst",
+		"pool/app/db/2026-01-14/030000.tar.zst",
+		"pool/app/db/2026-01-15/030000.tar.zst",
+		"pool/app/files/2026-01-15/030000.tar.zst",
+	})
+	ctx := context.Background()
+
+	files, next, err := store.ListPage(ctx, "app/db/", "", 2)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, "app/db/2026-01-13/030000.tar.zst", files[0].Key)
+	assert.Equal(t, int64(7), files[0].Size)
+	assert.Equal(t, "app/db/2026-01-14/030000.tar.zst", next)
+	assert.Equal(t, "2", (*queries)[0].Get("max-keys"))
+	assert.Equal(t, "pool/app/db/", (*queries)[0].Get("prefix"))
+
+	files, next, err = store.ListPage(ctx, "app/db/", next, 2)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "app/db/2026-01-15/030000.tar.zst", files[0].Key)
+	assert.Empty(t, next)
+	assert.Equal(t, "pool/app/db/2026-01-14/030000.tar.zst", (*queries)[1].Get("start-after"))
+}
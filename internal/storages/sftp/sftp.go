@@ -0,0 +1,357 @@
+package sftp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/shyim/docker-backup/internal/storage"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func init() {
+	storage.Register(&SFTPStorageType{})
+}
+
+// dialTimeout limits connecting and the SSH handshake
+const dialTimeout = 30 * time.Second
+
+// partialSuffix marks uploads that haven't finished yet. They are renamed to
+// their key once complete, so a broken upload is never listed as a backup.
+const partialSuffix = ".partial"
+
+// SFTPStorageType is the factory for SFTP storage
+type SFTPStorageType struct{}
+
+// Name returns the storage type identifier
+func (t *SFTPStorageType) Name() string {
+	return "sftp"
+}
+
+// Create instantiates a new SFTP storage from options. The connection is only
+// opened on first use.
+func (t *SFTPStorageType) Create(poolName string, options map[string]string) (storage.Storage, error) {
+	host := options["host"]
+	if host == "" {
+		return nil, fmt.Errorf("SFTP storage requires 'host' option")
+	}
+	user := options["user"]
+	if user == "" {
+		return nil, fmt.Errorf("SFTP storage requires 'user' option")
+	}
+
+	port := 22
+	if val := options["port"]; val != "" {
+		p, err := strconv.Atoi(val)
+		if err != nil || p < 1 || p > 65535 {
+			return nil, fmt.Errorf("invalid 'port' option %q", val)
+		}
+		port = p
+	}
+
+	var auth []ssh.AuthMethod
+	if keyFile := options["private-key"]; keyFile != "" {
+		signer, err := loadPrivateKey(keyFile, options["private-key-passphrase"])
+		if err != nil {
+			return nil, err
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if password := options["password"]; password != "" {
+		auth = append(auth, ssh.Password(password))
+	}
+	if len(auth) == 0 {
+		return nil, fmt.Errorf("SFTP storage requires 'password' or 'private-key' option")
+	}
+
+	hostKeyCallback, err := hostKeyCallback(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SFTPStorage{
+		addr:     net.JoinHostPort(host, strconv.Itoa(port)),
+		basePath: path.Clean("/" + strings.TrimPrefix(options["path"], "/")),
+		relative: !strings.HasPrefix(options["path"], "/"),
+		poolName: poolName,
+		sshConfig: &ssh.ClientConfig{
+			User:            user,
+			Auth:            auth,
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         dialTimeout,
+		},
+	}, nil
+}
+
+// loadPrivateKey reads a private key from a file
+func loadPrivateKey(keyFile, passphrase string) (ssh.Signer, error) {
+	pem, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read 'private-key' file: %w", err)
+	}
+
+	var signer ssh.Signer
+	if passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(pem, []byte(passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(pem)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse 'private-key' file %s: %w", keyFile, err)
+	}
+	return signer, nil
+}
+
+// hostKeyCallback verifies the server against a known_hosts file or a pinned
+// host key fingerprint. Skipping the check has to be requested explicitly.
+func hostKeyCallback(options map[string]string) (ssh.HostKeyCallback, error) {
+	knownHosts := options["known-hosts"]
+	fingerprint := options["host-key"]
+	insecure := options["insecure-ignore-host-key"] == "true"
+
+	switch {
+	case knownHosts != "" && fingerprint != "":
+		return nil, fmt.Errorf("SFTP storage accepts only one of 'known-hosts' and 'host-key'")
+	case knownHosts != "":
+		callback, err := knownhosts.New(knownHosts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read 'known-hosts' file: %w", err)
+		}
+		return callback, nil
+	case fingerprint != "":
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if got := ssh.FingerprintSHA256(key); got != fingerprint {
+				return fmt.Errorf("host key of %s is %s, expected %s", hostname, got, fingerprint)
+			}
+			return nil
+		}, nil
+	case insecure:
+		return ssh.InsecureIgnoreHostKey(), nil
+	default:
+		return nil, fmt.Errorf("SFTP storage requires 'known-hosts' or 'host-key' option to verify the server (or 'insecure-ignore-host-key=true')")
+	}
+}
+
+// SFTPStorage implements Storage for a directory on an SSH server
+type SFTPStorage struct {
+	addr      string
+	basePath  string // Cleaned, always starting with "/"
+	relative  bool   // basePath is relative to the login directory
+	poolName  string
+	sshConfig *ssh.ClientConfig
+
+	mu     sync.Mutex
+	conn   *ssh.Client
+	client *sftp.Client
+}
+
+// sftpClient returns the open connection, dialing a new one when there is none
+func (s *SFTPStorage) sftpClient(ctx context.Context) (*sftp.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client != nil {
+		return s.client, nil
+	}
+
+	dialer := net.Dialer{Timeout: dialTimeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", s.addr, err)
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(netConn, s.addr, s.sshConfig)
+	if err != nil {
+		_ = netConn.Close()
+		return nil, fmt.Errorf("failed to establish SSH connection to %s: %w", s.addr, err)
+	}
+	conn := ssh.NewClient(sshConn, chans, reqs)
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to start SFTP session on %s: %w", s.addr, err)
+	}
+
+	if s.relative {
+		wd, err := client.Getwd()
+		if err != nil {
+			_ = client.Close()
+			_ = conn.Close()
+			return nil, fmt.Errorf("failed to determine SFTP login directory: %w", err)
+		}
+		s.basePath = path.Join(wd, s.basePath)
+		s.relative = false
+	}
+
+	s.conn = conn
+	s.client = client
+
+	// Drop the connection once the server goes away, the next call redials
+	go func() {
+		_ = conn.Wait()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.conn == conn {
+			s.conn = nil
+			s.client = nil
+		}
+	}()
+
+	return client, nil
+}
+
+// Close closes the connection to the server, if any
+func (s *SFTPStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client == nil {
+		return nil
+	}
+	_ = s.client.Close()
+	err := s.conn.Close()
+	s.client = nil
+	s.conn = nil
+	return err
+}
+
+func (s *SFTPStorage) fullPath(key string) string {
+	return path.Join(s.basePath, key)
+}
+
+// Store uploads backup data, creating the remote parent directories
+func (s *SFTPStorage) Store(ctx context.Context, key string, reader io.Reader) error {
+	client, err := s.sftpClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	fullPath := s.fullPath(key)
+	if err := client.MkdirAll(path.Dir(fullPath)); err != nil {
+		return fmt.Errorf("failed to create directories: %w", err)
+	}
+
+	tmpPath := fullPath + partialSuffix
+	file, err := client.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+
+	if _, err := io.Copy(file, reader); err != nil {
+		_ = file.Close()
+		_ = client.Remove(tmpPath) // Clean up on failure
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		_ = client.Remove(tmpPath)
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	if err := client.PosixRename(tmpPath, fullPath); err != nil {
+		// Servers without the posix-rename extension refuse to overwrite
+		_ = client.Remove(fullPath)
+		if err := client.Rename(tmpPath, fullPath); err != nil {
+			_ = client.Remove(tmpPath)
+			return fmt.Errorf("failed to rename uploaded file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// List returns all backups matching the prefix, newest first
+func (s *SFTPStorage) List(ctx context.Context, prefix string) ([]storage.BackupFile, error) {
+	client, err := s.sftpClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []storage.BackupFile
+	walker := client.Walk(s.basePath)
+	for walker.Step() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := walker.Err(); err != nil {
+			if walker.Path() == s.basePath && errors.Is(err, fs.ErrNotExist) {
+				return files, nil
+			}
+			return nil, fmt.Errorf("failed to list files: %w", err)
+		}
+
+		info := walker.Stat()
+		if info.IsDir() || strings.HasSuffix(walker.Path(), partialSuffix) {
+			continue
+		}
+
+		key := strings.TrimPrefix(strings.TrimPrefix(walker.Path(), s.basePath), "/")
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		files = append(files, storage.BackupFile{
+			Key:          key,
+			Size:         info.Size(),
+			LastModified: info.ModTime(),
+		})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].LastModified.After(files[j].LastModified)
+	})
+
+	return files, nil
+}
+
+// Delete removes a backup file and the parent directories it leaves empty
+func (s *SFTPStorage) Delete(ctx context.Context, key string) error {
+	client, err := s.sftpClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	fullPath := s.fullPath(key)
+	if err := client.Remove(fullPath); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil // Already deleted
+		}
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+
+	dir := path.Dir(fullPath)
+	for dir != s.basePath && strings.HasPrefix(dir, s.basePath+"/") {
+		if err := client.RemoveDirectory(dir); err != nil {
+			break // Directory not empty or other error
+		}
+		dir = path.Dir(dir)
+	}
+
+	return nil
+}
+
+// Get retrieves a backup file for reading
+func (s *SFTPStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	client, err := s.sftpClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := client.Open(s.fullPath(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	return file, nil
+}
@@ -0,0 +1,201 @@
+package sftp
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shyim/docker-backup/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"golang.org/x/crypto/ssh"
+)
+
+func writePrivateKey(t *testing.T) string {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	block, err := ssh.MarshalPrivateKey(key, "")
+	require.NoError(t, err)
+
+	p := filepath.Join(t.TempDir(), "id_ed25519")
+	require.NoError(t, os.WriteFile(p, pem.EncodeToMemory(block), 0600))
+	return p
+}
+
+func TestCreate_Options(t *testing.T) {
+	factory := &SFTPStorageType{}
+	base := map[string]string{"host": "backup.example.com", "user": "backup", "password": "secret", "insecure-ignore-host-key": "true"}
+
+	with := func(changes map[string]string) map[string]string {
+		opts := make(map[string]string)
+		for k, v := range base {
+			opts[k] = v
+		}
+		for k, v := range changes {
+			if v == "" {
+				delete(opts, k)
+			} else {
+				opts[k] = v
+			}
+		}
+		return opts
+	}
+
+	store, err := factory.Create("offsite", with(map[string]string{"path": "/srv/backups/"}))
+	require.NoError(t, err)
+	s := store.(*SFTPStorage)
+	assert.Equal(t, "backup.example.com:22", s.addr)
+	assert.Equal(t, "/srv/backups", s.basePath)
+	assert.False(t, s.relative)
+
+	store, err = factory.Create("offsite", with(map[string]string{"port": "2222", "path": "backups"}))
+	require.NoError(t, err)
+	s = store.(*SFTPStorage)
+	assert.Equal(t, "backup.example.com:2222", s.addr)
+	assert.True(t, s.relative)
+
+	store, err = factory.Create("offsite", with(map[string]string{"password": "", "private-key": writePrivateKey(t)}))
+	require.NoError(t, err)
+	assert.Len(t, store.(*SFTPStorage).sshConfig.Auth, 1)
+
+	for name, opts := range map[string]map[string]string{
+		"missing host":         with(map[string]string{"host": ""}),
+		"missing user":         with(map[string]string{"user": ""}),
+		"no credentials":       with(map[string]string{"password": ""}),
+		"invalid port":         with(map[string]string{"port": "ssh"}),
+		"missing key file":     with(map[string]string{"private-key": "/nonexistent/id_ed25519"}),
+		"no host verification": with(map[string]string{"insecure-ignore-host-key": ""}),
+		"both host checks":     with(map[string]string{"host-key": "SHA256:abc", "known-hosts": "/etc/ssh/ssh_known_hosts"}),
+	} {
+		_, err := factory.Create("offsite", opts)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestHostKeyCallback_Fingerprint(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	key, err := ssh.NewPublicKey(pub)
+	require.NoError(t, err)
+
+	callback, err := hostKeyCallback(map[string]string{"host-key": ssh.FingerprintSHA256(key)})
+	require.NoError(t, err)
+	assert.NoError(t, callback("backup.example.com:22", nil, key))
+
+	callback, err = hostKeyCallback(map[string]string{"host-key": "SHA256:something-else"})
+	require.NoError(t, err)
+	assert.Error(t, callback("backup.example.com:22", nil, key))
+}
+
+// TestSFTPStorage_Integration stores, lists, reads and deletes backups on a
+// dockerized OpenSSH server via testcontainers
+func TestSFTPStorage_Integration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+
+	req := testcontainers.ContainerRequest{
+		Image:        "atmoz/sftp:alpine",
+		Cmd:          []string{"backup:secret:::upload"},
+		ExposedPorts: []string{"22/tcp"},
+		WaitingFor:   wait.ForListeningPort("22/tcp").WithStartupTimeout(60 * time.Second),
+	}
+
+	ctr, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	require.NoError(t, err)
+	defer func() {
+		if err := ctr.Terminate(ctx); err != nil {
+			t.Logf("failed to terminate container: %v", err)
+		}
+	}()
+
+	host, err := ctr.Host(ctx)
+	require.NoError(t, err)
+	port, err := ctr.MappedPort(ctx, "22/tcp")
+	require.NoError(t, err)
+
+	store, err := (&SFTPStorageType{}).Create("offsite", map[string]string{
+		"host":                     host,
+		"port":                     port.Port(),
+		"user":                     "backup",
+		"password":                 "secret",
+		"path":                     "upload/backups",
+		"insecure-ignore-host-key": "true",
+	})
+	require.NoError(t, err)
+	defer func() {
+		_ = store.(*SFTPStorage).Close()
+	}()
+
+	files, err := store.List(ctx, "")
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	keys := []string{
+		"app/db/2026-01-15/030000.tar.zst",
+		"app/db/2026-01-16/030000.tar.zst",
+		"other/files/2026-01-16/040000.tar.zst",
+	}
+	for i, key := range keys {
+		data := fmt.Sprintf("backup %d", i)
+		require.NoError(t, storage.Store(ctx, store, key, strings.NewReader(data), storage.StoreOptions{}))
+		// Modification times have second precision
+		time.Sleep(1100 * time.Millisecond)
+	}
+
+	files, err = store.List(ctx, "app/")
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, keys[1], files[0].Key, "newest first")
+	assert.Equal(t, keys[0], files[1].Key)
+	assert.Equal(t, int64(len("backup 1")), files[0].Size)
+
+	reader, err := store.Get(ctx, keys[2])
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	assert.Equal(t, "backup 2", string(data))
+
+	// Overwriting a key replaces the file
+	require.NoError(t, store.Store(ctx, keys[2], bytes.NewReader([]byte("replaced"))))
+	reader, err = store.Get(ctx, keys[2])
+	require.NoError(t, err)
+	data, err = io.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	assert.Equal(t, "replaced", string(data))
+
+	require.NoError(t, store.Delete(ctx, keys[2]))
+	require.NoError(t, store.Delete(ctx, keys[2]), "deleting twice is not an error")
+
+	files, err = store.List(ctx, "")
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+
+	// The emptied directories were pruned up to the base path
+	s := store.(*SFTPStorage)
+	client, err := s.sftpClient(ctx)
+	require.NoError(t, err)
+	_, err = client.Stat(s.fullPath("other"))
+	assert.True(t, os.IsNotExist(err), "empty parent directories should be removed")
+	_, err = client.Stat(s.basePath)
+	assert.NoError(t, err, "the base path is kept")
+}